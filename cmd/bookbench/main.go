@@ -0,0 +1,126 @@
+// Command bookbench measures the latency of POST /v1/bookings/:id/book against a running
+// API, to check the booking path against its latency budget (BOOKING_LATENCY_BUDGET_MS) and
+// to compare builds: run it against the old and the new server with the same flags on a
+// freshly seeded database and compare the percentiles. It signs in as the users written by
+// the seed command and books -quantity tickets per request for a general admission event,
+// spreading requests over the users round robin, with retries disabled so every request is
+// timed once. Sign-in goes through the auth rate limit, so it is slow for many users.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/samirwankhede/lewly-pgpyewj/pkg/client"
+)
+
+type result struct {
+	latency time.Duration
+	outcome string
+}
+
+func main() {
+	base := flag.String("base", "http://localhost:8080", "API base URL")
+	eventID := flag.String("event", "", "general admission event to book (required)")
+	seed := flag.Int64("seed", 1, "seed the users were written with")
+	users := flag.Int("users", 20, "number of seeded users to book as")
+	password := flag.String("password", "demo-password", "password of the seeded users")
+	requests := flag.Int("requests", 1000, "number of booking requests")
+	concurrency := flag.Int("concurrency", 20, "requests in flight at once")
+	quantity := flag.Int("quantity", 1, "tickets per booking")
+	budget := flag.Duration("budget", 150*time.Millisecond, "latency budget to report against")
+	flag.Parse()
+
+	if *eventID == "" || *users < 1 || *requests < 1 || *concurrency < 1 || *quantity < 1 {
+		fmt.Fprintln(os.Stderr, "-event is required; -users, -requests, -concurrency and -quantity must be positive")
+		os.Exit(2)
+	}
+	ctx := context.Background()
+
+	clients := make([]*client.Client, 0, *users)
+	for i := 0; i < *users; i++ {
+		// Sign-in retries through 429s from the auth rate limit; bookings are never retried
+		login := client.New(*base, client.WithRetry(10, time.Second))
+		session, err := login.Login(ctx, fmt.Sprintf("demo%d.user%d@example.com", *seed, i), *password)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "sign in as seeded user %d: %v\n", i, err)
+			os.Exit(1)
+		}
+		clients = append(clients, client.New(*base, client.WithToken(session.Token), client.WithRetry(0, 0)))
+	}
+
+	jobs := make(chan int)
+	results := make(chan result, *requests)
+	var wg sync.WaitGroup
+	for w := 0; w < *concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range jobs {
+				c := clients[n%len(clients)]
+				start := time.Now()
+				res, err := c.BookQuantity(ctx, *eventID, *quantity)
+				results <- result{latency: time.Since(start), outcome: outcome(res, err)}
+			}
+		}()
+	}
+	start := time.Now()
+	for n := 0; n < *requests; n++ {
+		jobs <- n
+	}
+	close(jobs)
+	wg.Wait()
+	elapsed := time.Since(start)
+	close(results)
+
+	var latencies []time.Duration
+	outcomes := map[string]int{}
+	over := 0
+	for r := range results {
+		latencies = append(latencies, r.latency)
+		outcomes[r.outcome]++
+		if r.latency > *budget {
+			over++
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	fmt.Printf("%d requests in %s (%.0f req/s), concurrency %d\n", len(latencies), elapsed.Round(time.Millisecond), float64(len(latencies))/elapsed.Seconds(), *concurrency)
+	fmt.Printf("p50 %s  p90 %s  p99 %s  max %s\n", percentile(latencies, 50), percentile(latencies, 90), percentile(latencies, 99), latencies[len(latencies)-1])
+	fmt.Printf("over the %s budget: %d (%.2f%%)\n", *budget, over, 100*float64(over)/float64(len(latencies)))
+	names := make([]string, 0, len(outcomes))
+	for name := range outcomes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("  %-16s %d\n", name, outcomes[name])
+	}
+}
+
+// outcome names a response by booking status, or by HTTP status for errors.
+func outcome(res *client.BookingResult, err error) string {
+	var apiErr *client.APIError
+	switch {
+	case errors.As(err, &apiErr):
+		return fmt.Sprintf("http_%d", apiErr.StatusCode)
+	case err != nil:
+		return "network_error"
+	}
+	return res.Status
+}
+
+// percentile returns the nearest-rank p-th percentile of sorted latencies.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	i := (len(sorted)*p+99)/100 - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i].Round(10 * time.Microsecond)
+}
@@ -0,0 +1,240 @@
+// Command bootstrap provisions the infrastructure state the services expect but don't create
+// themselves, so a fresh or drifted environment can be brought in line by rerunning it: the
+// Kafka topics with their partition counts, the Postgres indexes hot queries depend on, and a
+// sanity check of the Redis keyspace. Run it after migrations; every step is idempotent.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/config"
+	kafkax "github.com/samirwankhede/lewly-pgpyewj/internal/kafka"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/logger"
+	redisx "github.com/samirwankhede/lewly-pgpyewj/internal/redis"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store"
+	storeEvents "github.com/samirwankhede/lewly-pgpyewj/internal/store/events"
+)
+
+// hotIndex is an index a hot query depends on. The same indexes are created by migrations;
+// bootstrap recreates them if they were dropped or left invalid by a failed build.
+type hotIndex struct {
+	name string
+	def  string
+}
+
+var hotIndexes = []hotIndex{
+	// Idempotency-Key lookup before reserving tokens
+	{"idx_bookings_idempotency_key", "bookings (idempotency_key) WHERE idempotency_key IS NOT NULL"},
+	{"idx_bookings_event_status", "bookings (event_id, status)"},
+	{"idx_seats_event_status", "seats (event_id, status)"},
+	// Waitlist head for promotion
+	{"idx_waitlist_event_position", "waitlist (event_id, position)"},
+}
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "report what would change without changing anything")
+	partitions := flag.Int("partitions", 12, "partitions of the bookings topic; bounds how many workers consume in parallel")
+	dlqPartitions := flag.Int("dlq-partitions", 1, "partitions of the bookings-dlq topic")
+	replication := flag.Int("replication", 1, "replication factor of created topics")
+	skipKafka := flag.Bool("skip-kafka", false, "don't provision Kafka topics")
+	skipPostgres := flag.Bool("skip-postgres", false, "don't provision Postgres indexes")
+	skipRedis := flag.Bool("skip-redis", false, "don't check Redis")
+	flag.Parse()
+
+	_ = godotenv.Load()
+	cfg := config.Load()
+	log := logger.New(cfg.Env)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	failed := 0
+	var topics, indexes, warnings int
+	if !*skipKafka {
+		specs := []kafkax.TopicSpec{
+			{Name: "bookings", Partitions: *partitions, ReplicationFactor: *replication},
+			{Name: "bookings-dlq", Partitions: *dlqPartitions, ReplicationFactor: *replication},
+		}
+		n, errs := ensureTopics(ctx, log, strings.Split(cfg.KafkaBrokers, ","), specs, *dryRun)
+		topics += n
+		failed += errs
+	}
+
+	var db *store.DB
+	if !*skipPostgres || !*skipRedis {
+		var err error
+		db, err = store.NewDB(ctx, cfg.PostgresURL, int32(cfg.MaxBatchDBConnections), store.WithSlowQueryLog(log, cfg.SlowQueryThreshold), store.WithApplicationName("evently-batch"))
+		if err != nil {
+			log.Fatal("db", zap.Error(err))
+		}
+		defer db.Close()
+	}
+	if !*skipPostgres {
+		n, errs := ensureIndexes(ctx, log, db, *dryRun)
+		indexes += n
+		failed += errs
+	}
+	if !*skipRedis {
+		n, errs := checkRedis(ctx, log, cfg, db)
+		warnings += n
+		failed += errs
+	}
+
+	fmt.Printf("bootstrap complete at %s: %d topics and %d indexes provisioned%s, %d redis warnings, %d failures\n",
+		time.Now().Format(time.RFC3339), topics, indexes, dryRunNote(*dryRun), warnings, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func dryRunNote(dryRun bool) string {
+	if dryRun {
+		return " (dry run)"
+	}
+	return ""
+}
+
+// ensureTopics creates missing topics and grows ones with fewer partitions than specified. A
+// topic with more partitions, or another replication factor, is reported but left alone,
+// since Kafka can't shrink a topic or change replication without a reassignment.
+func ensureTopics(ctx context.Context, log *zap.Logger, brokers []string, specs []kafkax.TopicSpec, dryRun bool) (int, int) {
+	changed, failed := 0, 0
+	for _, spec := range specs {
+		have, replicas, err := kafkax.TopicLayout(ctx, brokers, spec.Name)
+		if err != nil {
+			log.Error("read topic", zap.Error(err), zap.String("topic", spec.Name))
+			failed++
+			continue
+		}
+		switch {
+		case have == 0:
+			log.Info("creating topic", zap.String("topic", spec.Name), zap.Int("partitions", spec.Partitions), zap.Int("replication", spec.ReplicationFactor), zap.Bool("dry_run", dryRun))
+			if !dryRun {
+				err = kafkax.CreateTopic(ctx, brokers, spec)
+			}
+		case have < spec.Partitions:
+			log.Info("adding partitions", zap.String("topic", spec.Name), zap.Int("was", have), zap.Int("now", spec.Partitions), zap.Bool("dry_run", dryRun))
+			if !dryRun {
+				err = kafkax.AddPartitions(ctx, brokers, spec.Name, spec.Partitions)
+			}
+		default:
+			if have > spec.Partitions {
+				log.Warn("topic has more partitions than specified", zap.String("topic", spec.Name), zap.Int("partitions", have), zap.Int("specified", spec.Partitions))
+			}
+			if replicas != spec.ReplicationFactor {
+				log.Warn("topic replication differs", zap.String("topic", spec.Name), zap.Int("replication", replicas), zap.Int("specified", spec.ReplicationFactor))
+			}
+			continue
+		}
+		if err != nil {
+			log.Error("provision topic", zap.Error(err), zap.String("topic", spec.Name))
+			failed++
+			continue
+		}
+		changed++
+	}
+	return changed, failed
+}
+
+// ensureIndexes creates the hot indexes that are missing and rebuilds invalid ones. Tables are
+// locked against writes while an index builds, so run it off-peak on a live database.
+func ensureIndexes(ctx context.Context, log *zap.Logger, db *store.DB, dryRun bool) (int, int) {
+	changed, failed := 0, 0
+	for _, idx := range hotIndexes {
+		var exists, valid bool
+		err := db.Pool.QueryRow(ctx, `
+			SELECT count(*) > 0, COALESCE(bool_and(i.indisvalid), false)
+			FROM pg_class c
+			JOIN pg_index i ON i.indexrelid = c.oid
+			WHERE c.relname = $1 AND pg_table_is_visible(c.oid)
+		`, idx.name).Scan(&exists, &valid)
+		if err != nil {
+			log.Error("read index", zap.Error(err), zap.String("index", idx.name))
+			failed++
+			continue
+		}
+		if exists && valid {
+			continue
+		}
+		log.Info("creating index", zap.String("index", idx.name), zap.Bool("rebuild", exists), zap.Bool("dry_run", dryRun))
+		if dryRun {
+			changed++
+			continue
+		}
+		if exists {
+			if _, err := db.Pool.Exec(ctx, `DROP INDEX IF EXISTS `+idx.name); err != nil {
+				log.Error("drop invalid index", zap.Error(err), zap.String("index", idx.name))
+				failed++
+				continue
+			}
+		}
+		if _, err := db.Pool.Exec(ctx, `CREATE INDEX IF NOT EXISTS `+idx.name+` ON `+idx.def); err != nil {
+			log.Error("create index", zap.Error(err), zap.String("index", idx.name))
+			failed++
+			continue
+		}
+		changed++
+	}
+	return changed, failed
+}
+
+// checkRedis reports Redis settings and keys that break booking admission. It changes nothing:
+// a live event without a token counter, or with a corrupt one, is fixed by redis_rebuild.
+func checkRedis(ctx context.Context, log *zap.Logger, cfg config.Config, db *store.DB) (int, int) {
+	tokens := redisx.NewTokenBucket(cfg.RedisAddr)
+	defer tokens.Close()
+	if err := tokens.GetClient().Ping(ctx).Err(); err != nil {
+		log.Error("redis ping", zap.Error(err))
+		return 0, 1
+	}
+
+	warnings := 0
+	// Token counters and timeout markers have no expiry, so only allkeys-* policies evict them
+	policy, err := tokens.EvictionPolicy(ctx)
+	if err != nil {
+		log.Warn("read redis eviction policy", zap.Error(err))
+		warnings++
+	} else if strings.HasPrefix(policy, "allkeys-") {
+		log.Warn("redis may evict token counters under memory pressure; use noeviction", zap.String("maxmemory_policy", policy))
+		warnings++
+	}
+
+	bad, err := tokens.BadTokenCounters(ctx)
+	if err != nil {
+		log.Error("scan token counters", zap.Error(err))
+		return warnings, 1
+	}
+	for _, id := range bad {
+		log.Warn("token counter is not a plain non-negative integer", zap.String("event_id", id))
+	}
+	warnings += len(bad)
+
+	expected, err := storeEvents.NewEventsRepository(db, log).ExpectedTokensLive(ctx)
+	if err != nil {
+		log.Error("compute expected tokens", zap.Error(err))
+		return warnings, 1
+	}
+	have, err := tokens.EventIDsWithTokens(ctx)
+	if err != nil {
+		log.Error("list token counters", zap.Error(err))
+		return warnings, 1
+	}
+	present := make(map[string]bool, len(have))
+	for _, id := range have {
+		present[id] = true
+	}
+	for id := range expected {
+		if !present[id] {
+			log.Warn("live event has no token counter; run redis_rebuild", zap.String("event_id", id))
+			warnings++
+		}
+	}
+	return warnings, 0
+}
@@ -0,0 +1,223 @@
+// Command dlq_replay lists the messages parked in bookings-dlq and sends chosen ones back to
+// the bookings topic once whatever made them fail has been fixed. It reads the DLQ without a
+// consumer group, so listing changes nothing; replayed messages stay in the DLQ as well,
+// since Kafka can't delete single messages.
+//
+//	dlq_replay [-booking ID] [-event ID] [-reason R] [-json]
+//	dlq_replay -replay (-select 0:12,0:40 | -booking ID | -event ID | -reason R | -all) [-dry-run]
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/segmentio/kafka-go"
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/config"
+	kafkax "github.com/samirwankhede/lewly-pgpyewj/internal/kafka"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/logger"
+)
+
+// entry is a dead-lettered message as listed.
+type entry struct {
+	Partition int       `json:"partition"`
+	Offset    int64     `json:"offset"`
+	Type      string    `json:"type"`
+	BookingID string    `json:"booking_id"`
+	EventID   string    `json:"event_id"`
+	Reason    string    `json:"reason"`
+	Error     string    `json:"error"`
+	Attempts  int       `json:"attempts"`
+	Replays   int       `json:"replays"`
+	Time      time.Time `json:"time"`
+
+	msg kafka.Message
+}
+
+// filter picks the messages to list or replay. Zero values match everything.
+type filter struct {
+	bookingID string
+	eventID   string
+	reason    string
+	selected  map[string]bool // "partition:offset"
+}
+
+func (f filter) empty() bool {
+	return f.bookingID == "" && f.eventID == "" && f.reason == "" && len(f.selected) == 0
+}
+
+func (f filter) match(e *entry) bool {
+	if f.bookingID != "" && e.BookingID != f.bookingID {
+		return false
+	}
+	if f.eventID != "" && e.EventID != f.eventID {
+		return false
+	}
+	if f.reason != "" && e.Reason != f.reason {
+		return false
+	}
+	if len(f.selected) > 0 && !f.selected[fmt.Sprintf("%d:%d", e.Partition, e.Offset)] {
+		return false
+	}
+	return true
+}
+
+func main() {
+	dlqTopic := flag.String("dlq", "bookings-dlq", "topic to read dead-lettered messages from")
+	topic := flag.String("topic", "bookings", "topic to replay messages to")
+	bookingID := flag.String("booking", "", "only messages for this booking ID")
+	eventID := flag.String("event", "", "only messages for this event ID")
+	reason := flag.String("reason", "", "only messages dead-lettered for this reason (processing_error, schema_validation, interrupted)")
+	sel := flag.String("select", "", "only these messages, as comma-separated partition:offset pairs from the listing")
+	replay := flag.Bool("replay", false, "publish the matching messages to -topic instead of listing them")
+	all := flag.Bool("all", false, "with -replay and no other filter, replay every message in the DLQ")
+	maxReplays := flag.Int("max-replays", 3, "with -replay, skip messages already replayed this many times (0 for no limit)")
+	dryRun := flag.Bool("dry-run", false, "with -replay, report what would be replayed without publishing")
+	asJSON := flag.Bool("json", false, "list messages as JSON lines")
+	timeout := flag.Duration("timeout", 2*time.Minute, "give up after this long")
+	flag.Parse()
+
+	_ = godotenv.Load()
+	cfg := config.Load()
+	log := logger.New(cfg.Env)
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	f := filter{bookingID: *bookingID, eventID: *eventID, reason: *reason}
+	if *sel != "" {
+		selected, err := parseSelection(*sel)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "dlq_replay:", err)
+			os.Exit(2)
+		}
+		f.selected = selected
+	}
+	if *replay && f.empty() && !*all {
+		fmt.Fprintln(os.Stderr, "dlq_replay: -replay needs -select, -booking, -event or -reason, or -all for the whole DLQ")
+		os.Exit(2)
+	}
+
+	brokers := strings.Split(cfg.KafkaBrokers, ",")
+	var matched []*entry
+	err := kafkax.ReadTopic(ctx, brokers, *dlqTopic, func(m kafka.Message) error {
+		if e := newEntry(m); f.match(e) {
+			matched = append(matched, e)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatal("read DLQ", zap.Error(err), zap.String("topic", *dlqTopic))
+	}
+
+	if !*replay {
+		if err := list(matched, *asJSON); err != nil {
+			log.Fatal("list", zap.Error(err))
+		}
+		return
+	}
+
+	producer := kafkax.NewProducer(brokers, *topic)
+	defer producer.Close()
+	replayed, skipped, failed := 0, 0, 0
+	for _, e := range matched {
+		if *maxReplays > 0 && e.Replays >= *maxReplays {
+			log.Warn("skipping message replayed too often", zap.Int("partition", e.Partition), zap.Int64("offset", e.Offset), zap.String("booking_id", e.BookingID), zap.Int("replays", e.Replays))
+			skipped++
+			continue
+		}
+		log.Info("replaying message", zap.Int("partition", e.Partition), zap.Int64("offset", e.Offset), zap.String("type", e.Type), zap.String("booking_id", e.BookingID), zap.Int("replay", e.Replays+1), zap.Bool("dry_run", *dryRun))
+		if *dryRun {
+			replayed++
+			continue
+		}
+		if err := producer.Publish(ctx, e.msg.Key, e.msg.Value, kafkax.ReplayHeaders(e.msg)...); err != nil {
+			log.Error("replay message", zap.Error(err), zap.Int("partition", e.Partition), zap.Int64("offset", e.Offset))
+			failed++
+			continue
+		}
+		replayed++
+	}
+
+	fmt.Printf("dlq replay complete at %s: %d of %d matching messages replayed to %s, %d skipped at -max-replays, %d failed\n",
+		time.Now().Format(time.RFC3339), replayed, len(matched), *topic, skipped, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// newEntry describes m. A message that no longer decodes is still listed, with what could
+// be read of it.
+func newEntry(m kafka.Message) *entry {
+	e := &entry{
+		Partition: m.Partition,
+		Offset:    m.Offset,
+		Reason:    kafkax.Header(m, kafkax.DLQReasonHeader),
+		Error:     kafkax.Header(m, kafkax.DLQErrorHeader),
+		Attempts:  kafkax.Attempts(m),
+		Replays:   kafkax.ReplayCount(m),
+		Time:      m.Time,
+		msg:       m,
+	}
+	env, _ := kafkax.DecodeMessage(m)
+	e.Type = env.Type
+	var p struct {
+		BookingID string `json:"booking_id"`
+		EventID   string `json:"event_id"`
+	}
+	if json.Unmarshal(env.Payload, &p) == nil {
+		e.BookingID, e.EventID = p.BookingID, p.EventID
+	}
+	return e
+}
+
+func list(entries []*entry, asJSON bool) error {
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		for _, e := range entries {
+			if err := enc.Encode(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "MESSAGE\tTIME\tTYPE\tBOOKING\tEVENT\tREASON\tATTEMPTS\tREPLAYS\tERROR")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%d:%d\t%s\t%s\t%s\t%s\t%s\t%d\t%d\t%s\n",
+			e.Partition, e.Offset, e.Time.Format(time.RFC3339), e.Type, e.BookingID, e.EventID, e.Reason, e.Attempts, e.Replays, e.Error)
+	}
+	fmt.Fprintf(w, "%d messages\n", len(entries))
+	return w.Flush()
+}
+
+// parseSelection parses comma-separated partition:offset pairs.
+func parseSelection(s string) (map[string]bool, error) {
+	out := map[string]bool{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		p, o, ok := strings.Cut(part, ":")
+		if !ok {
+			return nil, errors.New("-select takes partition:offset pairs, e.g. 0:12,0:40")
+		}
+		partition, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, fmt.Errorf("-select: bad partition in %q", part)
+		}
+		offset, err := strconv.ParseInt(o, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("-select: bad offset in %q", part)
+		}
+		out[fmt.Sprintf("%d:%d", partition, offset)] = true
+	}
+	return out, nil
+}
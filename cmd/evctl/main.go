@@ -0,0 +1,580 @@
+// Command evctl is an operator CLI for the Evently admin API.
+//
+//	evctl [-url URL] [-api-key KEY] [-json] <resource> <action> [args]
+//
+//	evctl events list [-limit N] [-offset N]
+//	evctl events create [-allow-duplicate] -f event.json
+//	evctl events cancel <event-id>
+//	evctl events refund <event-id>
+//	evctl events capture <event-id>
+//	evctl events merge <duplicate-id> <into-id>
+//	evctl events add-seats [-tier T] <event-id> <label>...
+//	evctl invitees import <event-id> <file.csv|->
+//	evctl invitees list <event-id>
+//	evctl bookings inspect <booking-id>
+//	evctl bookings finalize <booking-id>
+//	evctl bookings capture <booking-id>
+//	evctl tokens resync <event-id>
+//	evctl users promote <email|user-id>
+//	evctl mail templates [name]
+//	evctl mail test-send <template> <to>
+//	evctl notifications list [-limit N] [-offset N]
+//	evctl notifications get <batch-id>
+//	evctl jobs list [-kind K] [-state S] [-limit N] [-offset N]
+//	evctl jobs get <job-id>
+//
+// The URL and key default to EVCTL_URL and EVCTL_API_KEY; the key must be one of the
+// server's ADMIN_API_KEYS.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/samirwankhede/lewly-pgpyewj/pkg/client"
+)
+
+const usage = `usage: evctl [-url URL] [-api-key KEY] [-json] <command>
+
+commands:
+  events list [-limit N] [-offset N]
+  events create [-allow-duplicate] -f event.json
+  events cancel <event-id>
+  events refund <event-id>
+  events capture <event-id>
+  events merge <duplicate-id> <into-id>
+  events add-seats [-tier T] <event-id> <label>...
+  invitees import <event-id> <file.csv|->
+  invitees list <event-id>
+  bookings inspect <booking-id>
+  bookings finalize <booking-id>
+  bookings capture <booking-id>
+  tokens resync <event-id>
+  users promote <email|user-id>
+  mail templates [name]
+  mail test-send <template> <to>
+  notifications list [-limit N] [-offset N]
+  notifications get <batch-id>
+  jobs list [-kind K] [-state S] [-limit N] [-offset N]
+  jobs get <job-id>
+`
+
+type cli struct {
+	c      *client.Client
+	asJSON bool
+	out    io.Writer
+}
+
+func main() {
+	fs := flag.NewFlagSet("evctl", flag.ExitOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	baseURL := fs.String("url", getenv("EVCTL_URL", "http://localhost:8080"), "API base URL")
+	apiKey := fs.String("api-key", os.Getenv("EVCTL_API_KEY"), "admin API key")
+	asJSON := fs.Bool("json", false, "print raw JSON")
+	timeout := fs.Duration("timeout", 30*time.Second, "overall request timeout")
+	_ = fs.Parse(os.Args[1:])
+
+	args := fs.Args()
+	if len(args) < 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *apiKey == "" {
+		fmt.Fprintln(os.Stderr, "evctl: missing API key; set EVCTL_API_KEY or pass -api-key")
+		os.Exit(2)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	app := &cli{
+		c:      client.New(*baseURL, client.WithAPIKey(*apiKey), client.WithUserAgent("evctl")),
+		asJSON: *asJSON,
+		out:    os.Stdout,
+	}
+	if err := app.run(ctx, args[0], args[1], args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, "evctl:", err)
+		if errors.Is(err, errUsage) {
+			fs.Usage()
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+}
+
+var errUsage = errors.New("invalid arguments")
+
+func (a *cli) run(ctx context.Context, resource, action string, args []string) error {
+	switch resource + " " + action {
+	case "events list":
+		return a.eventsList(ctx, args)
+	case "events create":
+		return a.eventsCreate(ctx, args)
+	case "events cancel":
+		id, err := oneArg(args)
+		if err != nil {
+			return err
+		}
+		job, err := a.c.CancelEvent(ctx, id)
+		if err != nil {
+			return err
+		}
+		if a.asJSON {
+			return a.printJSON(job)
+		}
+		fmt.Fprintf(a.out, "cancelling event %s in job %s; follow with: evctl jobs get %s\n", id, job.ID, job.ID)
+		fmt.Fprintf(a.out, "refund its paid bookings with: evctl events refund %s\n", id)
+		return nil
+	case "events refund":
+		id, err := oneArg(args)
+		if err != nil {
+			return err
+		}
+		job, err := a.c.RefundCancelledEvent(ctx, id)
+		if err != nil {
+			return err
+		}
+		if a.asJSON {
+			return a.printJSON(job)
+		}
+		fmt.Fprintf(a.out, "refunding event %s in job %s; follow with: evctl jobs get %s\n", id, job.ID, job.ID)
+		return nil
+	case "events capture":
+		id, err := oneArg(args)
+		if err != nil {
+			return err
+		}
+		job, err := a.c.CaptureEventPayments(ctx, id)
+		if err != nil {
+			return err
+		}
+		if a.asJSON {
+			return a.printJSON(job)
+		}
+		fmt.Fprintf(a.out, "capturing payments of event %s in job %s; follow with: evctl jobs get %s\n", id, job.ID, job.ID)
+		return nil
+	case "events merge":
+		if len(args) != 2 {
+			return errUsage
+		}
+		res, err := a.c.MergeEvents(ctx, args[0], args[1])
+		if err != nil {
+			return err
+		}
+		if a.asJSON {
+			return a.printJSON(res)
+		}
+		fmt.Fprintf(a.out, "merged %s into %s: %d bookings, %d waitlist entries, %d likes moved\n", res.SourceID, res.TargetID, res.BookingsMoved, res.WaitlistMoved, res.LikesMoved)
+		return nil
+	case "events add-seats":
+		return a.eventsAddSeats(ctx, args)
+	case "invitees import":
+		return a.inviteesImport(ctx, args)
+	case "invitees list":
+		return a.inviteesList(ctx, args)
+	case "bookings inspect":
+		id, err := oneArg(args)
+		if err != nil {
+			return err
+		}
+		b, err := a.c.GetBooking(ctx, id)
+		if err != nil {
+			return err
+		}
+		return a.printBooking(b)
+	case "bookings finalize":
+		id, err := oneArg(args)
+		if err != nil {
+			return err
+		}
+		if err := a.c.RequeueFinalize(ctx, id); err != nil {
+			return err
+		}
+		fmt.Fprintf(a.out, "finalization of booking %s requeued\n", id)
+		return nil
+	case "bookings capture":
+		id, err := oneArg(args)
+		if err != nil {
+			return err
+		}
+		p, err := a.c.CapturePayment(ctx, id)
+		if err != nil {
+			return err
+		}
+		if a.asJSON {
+			return a.printJSON(p)
+		}
+		fmt.Fprintf(a.out, "captured %.2f %s for booking %s\n", p.Amount, p.Currency, id)
+		return nil
+	case "tokens resync":
+		id, err := oneArg(args)
+		if err != nil {
+			return err
+		}
+		res, err := a.c.ResyncTokens(ctx, id)
+		if err != nil {
+			return err
+		}
+		if a.asJSON {
+			return a.printJSON(res)
+		}
+		fmt.Fprintf(a.out, "event %s tokens: %d -> %d\n", res.EventID, res.Before, res.After)
+		for _, p := range res.Phases {
+			fmt.Fprintf(a.out, "  phase %s tokens: %d -> %d\n", p.Name, p.Before, p.After)
+		}
+		return nil
+	case "users promote":
+		return a.usersPromote(ctx, args)
+	case "mail templates":
+		return a.mailTemplates(ctx, args)
+	case "mail test-send":
+		if len(args) != 2 {
+			return errUsage
+		}
+		to, err := a.c.SendTestMail(ctx, args[0], args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(a.out, "test %s email sent to %s\n", args[0], to)
+		return nil
+	case "jobs list":
+		return a.jobsList(ctx, args)
+	case "jobs get":
+		id, err := oneArg(args)
+		if err != nil {
+			return err
+		}
+		j, err := a.c.GetJob(ctx, id)
+		if err != nil {
+			return err
+		}
+		return a.printJob(j)
+	case "notifications list":
+		return a.notificationsList(ctx, args)
+	case "notifications get":
+		id, err := oneArg(args)
+		if err != nil {
+			return err
+		}
+		b, err := a.c.NotificationBatch(ctx, id)
+		if err != nil {
+			return err
+		}
+		if a.asJSON {
+			return a.printJSON(b)
+		}
+		fmt.Fprintf(a.out, "batch %s (%s): %s, %d/%d sent, %d failed\n", b.ID, b.Kind, b.Status, b.Sent, b.Total, b.Failed)
+		return nil
+	}
+	return fmt.Errorf("%w: unknown command %q", errUsage, resource+" "+action)
+}
+
+func (a *cli) eventsList(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("events list", flag.ContinueOnError)
+	limit := fs.Int("limit", 20, "page size")
+	offset := fs.Int("offset", 0, "page offset")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	events, _, err := a.c.ListAllEvents(ctx, client.ListOptions{Limit: *limit, Offset: *offset})
+	if err != nil {
+		return err
+	}
+	if a.asJSON {
+		return a.printJSON(events)
+	}
+	w := tabwriter.NewWriter(a.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tSTATUS\tSTART\tCAPACITY\tRESERVED")
+	for _, e := range events {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\n", e.ID, e.Name, e.Status, e.StartTime.Format(time.RFC3339), e.Capacity, e.Reserved)
+	}
+	return w.Flush()
+}
+
+func (a *cli) eventsAddSeats(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("events add-seats", flag.ContinueOnError)
+	tier := fs.String("tier", "", "price tier of the new seats")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() < 2 {
+		return errUsage
+	}
+	res, err := a.c.IncreaseCapacity(ctx, fs.Arg(0), fs.Args()[1:], *tier)
+	if err != nil {
+		return err
+	}
+	if a.asJSON {
+		return a.printJSON(res)
+	}
+	fmt.Fprintf(a.out, "capacity of %s raised from %d to %d: %d seats offered to the waitlist, %d put on sale\n",
+		res.EventID, res.PreviousCapacity, res.NewCapacity, res.WaitlistOffers, res.TokensReleased)
+	return nil
+}
+
+func (a *cli) jobsList(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("jobs list", flag.ContinueOnError)
+	kind := fs.String("kind", "", "only jobs of this kind")
+	state := fs.String("state", "", "only jobs in this state")
+	limit := fs.Int("limit", 20, "page size")
+	offset := fs.Int("offset", 0, "page offset")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	jobs, _, err := a.c.ListJobs(ctx, client.JobFilter{Kind: *kind, State: *state}, client.ListOptions{Limit: *limit, Offset: *offset})
+	if err != nil {
+		return err
+	}
+	if a.asJSON {
+		return a.printJSON(jobs)
+	}
+	w := tabwriter.NewWriter(a.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tKIND\tSTATE\tPROCESSED\tTOTAL\tFAILED\tCREATED")
+	for _, j := range jobs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\t%s\n", j.ID, j.Kind, j.State, j.Processed, j.Total, j.Failed, j.CreatedAt.Format(time.RFC3339))
+	}
+	return w.Flush()
+}
+
+func (a *cli) printJob(j *client.Job) error {
+	if a.asJSON {
+		return a.printJSON(j)
+	}
+	fmt.Fprintf(a.out, "job %s (%s): %s, %d/%d processed, %d succeeded, %d failed\n", j.ID, j.Kind, j.State, j.Processed, j.Total, j.Succeeded, j.Failed)
+	if j.Error != nil {
+		fmt.Fprintf(a.out, "error: %s\n", *j.Error)
+	}
+	for _, e := range j.ErrorSamples {
+		fmt.Fprintf(a.out, "  %s\n", e)
+	}
+	if len(j.Result) > 0 {
+		fmt.Fprintf(a.out, "result: %s\n", j.Result)
+	}
+	return nil
+}
+
+func (a *cli) notificationsList(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("notifications list", flag.ContinueOnError)
+	limit := fs.Int("limit", 20, "page size")
+	offset := fs.Int("offset", 0, "page offset")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	batches, _, err := a.c.NotificationBatches(ctx, client.ListOptions{Limit: *limit, Offset: *offset})
+	if err != nil {
+		return err
+	}
+	if a.asJSON {
+		return a.printJSON(batches)
+	}
+	w := tabwriter.NewWriter(a.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tKIND\tSTATUS\tSENT\tFAILED\tTOTAL\tCREATED")
+	for _, b := range batches {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\t%s\n", b.ID, b.Kind, b.Status, b.Sent, b.Failed, b.Total, b.CreatedAt.Format(time.RFC3339))
+	}
+	return w.Flush()
+}
+
+func (a *cli) eventsCreate(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("events create", flag.ContinueOnError)
+	file := fs.String("f", "", "JSON file describing the event, - for stdin")
+	allowDuplicate := fs.Bool("allow-duplicate", false, "create even if an event with the same name, venue and start exists")
+	if err := fs.Parse(args); err != nil || *file == "" {
+		return errUsage
+	}
+	var raw []byte
+	var err error
+	if *file == "-" {
+		raw, err = io.ReadAll(os.Stdin)
+	} else {
+		raw, err = os.ReadFile(*file)
+	}
+	if err != nil {
+		return err
+	}
+	var req client.CreateEventRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return fmt.Errorf("parse %s: %w", *file, err)
+	}
+	if *allowDuplicate {
+		req.AllowDuplicate = true
+	}
+	e, err := a.c.CreateEvent(ctx, req)
+	if err != nil {
+		return err
+	}
+	if a.asJSON {
+		return a.printJSON(e)
+	}
+	fmt.Fprintf(a.out, "created event %s (%s)\n", e.ID, e.Name)
+	return nil
+}
+
+// inviteesImport uploads a CSV of invitee emails to a private event and prints each code.
+func (a *cli) inviteesImport(ctx context.Context, args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+	var raw []byte
+	var err error
+	if args[1] == "-" {
+		raw, err = io.ReadAll(os.Stdin)
+	} else {
+		raw, err = os.ReadFile(args[1])
+	}
+	if err != nil {
+		return err
+	}
+	job, err := a.c.ImportInvitees(ctx, args[0], raw)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "importing in job %s\n", job.ID)
+	if job, err = a.c.WaitJob(ctx, job.ID, time.Second); err != nil {
+		return err
+	}
+	var res client.InviteeImport
+	if err := json.Unmarshal(job.Result, &res); err != nil {
+		return fmt.Errorf("decode import result: %w", err)
+	}
+	if a.asJSON {
+		return a.printJSON(res)
+	}
+	w := tabwriter.NewWriter(a.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "EMAIL\tCODE\tNEW USER\tALREADY INVITED")
+	for _, i := range res.Invitees {
+		fmt.Fprintf(w, "%s\t%s\t%t\t%t\n", i.Email, i.Code, i.NewUser, i.AlreadyInvited)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	for _, r := range res.Rejected {
+		fmt.Fprintf(a.out, "line %d: %s: %s\n", r.Line, r.Email, r.Reason)
+	}
+	fmt.Fprintf(a.out, "%d invited (%d new accounts, %d existing), %d rejected\n", res.Invited, res.Created, res.Matched, len(res.Rejected))
+	return nil
+}
+
+func (a *cli) inviteesList(ctx context.Context, args []string) error {
+	id, err := oneArg(args)
+	if err != nil {
+		return err
+	}
+	res, err := a.c.ListInvitees(ctx, id)
+	if err != nil {
+		return err
+	}
+	if a.asJSON {
+		return a.printJSON(res)
+	}
+	w := tabwriter.NewWriter(a.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "EMAIL\tCODE\tREDEEMED\tBOOKED")
+	for _, i := range res.Invitees {
+		redeemed := "-"
+		if i.RedeemedAt != nil {
+			redeemed = i.RedeemedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%t\n", i.Email, i.Code, redeemed, i.Booked)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "%d issued, %d redeemed, %d booked\n", res.Issued, res.Redeemed, res.Booked)
+	return nil
+}
+
+func (a *cli) usersPromote(ctx context.Context, args []string) error {
+	who, err := oneArg(args)
+	if err != nil {
+		return err
+	}
+	userID := who
+	if strings.Contains(who, "@") {
+		u, err := a.c.GetUserByEmail(ctx, who)
+		if err != nil {
+			return err
+		}
+		userID = u.ID
+	}
+	if err := a.c.PromoteUser(ctx, userID); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "user %s promoted to admin\n", userID)
+	return nil
+}
+
+// mailTemplates lists the templates, or prints one rendered with sample data when named.
+func (a *cli) mailTemplates(ctx context.Context, args []string) error {
+	if len(args) > 1 {
+		return errUsage
+	}
+	templates, err := a.c.MailTemplates(ctx)
+	if err != nil {
+		return err
+	}
+	if len(args) == 1 {
+		for _, t := range templates {
+			if t.Name == args[0] {
+				if a.asJSON {
+					return a.printJSON(t)
+				}
+				fmt.Fprintf(a.out, "Subject: %s\n%s", t.Subject, t.Body)
+				return nil
+			}
+		}
+		return fmt.Errorf("no template %q", args[0])
+	}
+	if a.asJSON {
+		return a.printJSON(templates)
+	}
+	w := tabwriter.NewWriter(a.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tDESCRIPTION")
+	for _, t := range templates {
+		fmt.Fprintf(w, "%s\t%s\n", t.Name, t.Description)
+	}
+	return w.Flush()
+}
+
+func (a *cli) printBooking(b *client.Booking) error {
+	if a.asJSON {
+		return a.printJSON(b)
+	}
+	w := tabwriter.NewWriter(a.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "ID\t%s\n", b.ID)
+	fmt.Fprintf(w, "EVENT\t%s\n", b.EventID)
+	fmt.Fprintf(w, "USER\t%s\n", b.UserID)
+	fmt.Fprintf(w, "STATUS\t%s\n", b.Status)
+	fmt.Fprintf(w, "PAYMENT\t%s (%.2f paid)\n", b.PaymentStatus, b.AmountPaid)
+	fmt.Fprintf(w, "SEATS\t%s\n", strings.Join(b.Seats, ", "))
+	fmt.Fprintf(w, "CREATED\t%s\n", b.CreatedAt.Format(time.RFC3339))
+	fmt.Fprintf(w, "UPDATED\t%s\n", b.UpdatedAt.Format(time.RFC3339))
+	return w.Flush()
+}
+
+func (a *cli) printJSON(v any) error {
+	enc := json.NewEncoder(a.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func oneArg(args []string) (string, error) {
+	if len(args) != 1 || args[0] == "" {
+		return "", errUsage
+	}
+	return args[0], nil
+}
+
+func getenv(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
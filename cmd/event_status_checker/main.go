@@ -12,9 +12,21 @@ import (
 
 	"github.com/samirwankhede/lewly-pgpyewj/internal/config"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/logger"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/mailer"
+	redisx "github.com/samirwankhede/lewly-pgpyewj/internal/redis"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/service/events"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/service/fx"
+	mailerService "github.com/samirwankhede/lewly-pgpyewj/internal/service/mailer"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/store"
+	brandingrepo "github.com/samirwankhede/lewly-pgpyewj/internal/store/branding"
 	eventsrepo "github.com/samirwankhede/lewly-pgpyewj/internal/store/events"
+	fxrepo "github.com/samirwankhede/lewly-pgpyewj/internal/store/fx"
+	notificationsrepo "github.com/samirwankhede/lewly-pgpyewj/internal/store/notifications"
+	prewarmsrepo "github.com/samirwankhede/lewly-pgpyewj/internal/store/prewarms"
+	seatsrepo "github.com/samirwankhede/lewly-pgpyewj/internal/store/seats"
+	snapshotsrepo "github.com/samirwankhede/lewly-pgpyewj/internal/store/snapshots"
+	usersrepo "github.com/samirwankhede/lewly-pgpyewj/internal/store/users"
+	waitlistrepo "github.com/samirwankhede/lewly-pgpyewj/internal/store/waitlist"
 )
 
 func main() {
@@ -24,7 +36,7 @@ func main() {
 	ctx := context.Background()
 
 	// Connect to database
-	db, err := store.NewDB(ctx, cfg.PostgresURL, int32(cfg.MaxDBConnections))
+	db, err := store.NewDB(ctx, cfg.PostgresURL, int32(cfg.MaxBatchDBConnections), store.WithSlowQueryLog(log, cfg.SlowQueryThreshold), store.WithApplicationName("evently-batch"))
 	if err != nil {
 		log.Fatal("Failed to connect to database", zap.Error(err))
 	}
@@ -33,8 +45,24 @@ func main() {
 	// Create events repository
 	eventsRepo := eventsrepo.NewEventsRepository(db, log)
 
+	seatsRepo := seatsrepo.NewSeatsRepository(db, log)
+	tokens := redisx.NewTokenBucket(cfg.RedisAddr)
+	defer tokens.Close()
+
+	// Waitlist closure emails go through the mail queue; the worker sends them
+	mailerSender := mailerService.NewBrandedSender(&mailer.SMTPSender{
+		Host: cfg.SMTPHost,
+		Port: cfg.SMTPPort,
+		User: cfg.SMTPUser,
+		Pass: cfg.SMTPPass,
+		From: cfg.SMTPFrom,
+	}, mailerService.NewBrands(log, brandingrepo.NewBrandingRepository(db, log)))
+	mailQueue := mailerService.NewMailQueue(log, notificationsrepo.NewNotificationsRepository(db, log), mailerSender, cfg.MailMaxAttempts, cfg.MailRetryBase, cfg.MailRetryMax)
+	mailerSvc := mailerService.NewMailerService(log, mailerSender).WithQueue(mailQueue)
+
 	// Create event status checker
-	statusChecker := events.NewEventStatusChecker(log, eventsRepo)
+	statusChecker := events.NewEventStatusChecker(log, eventsRepo, seatsRepo, tokens, cfg.SeatsArchiveAfter).
+		WithWaitlistExpiry(waitlistrepo.NewWaitlistRepository(db, log), usersrepo.NewUsersRepository(db, log), mailerSvc, cfg.WaitlistCloseLead)
 
 	// Run initial check
 	log.Info("Running initial expired events check")
@@ -42,6 +70,32 @@ func main() {
 	if err != nil {
 		log.Error("Initial check failed", zap.Error(err))
 	}
+	_, _ = statusChecker.CloseWaitlists(ctx)
+	_, _ = statusChecker.ArchiveFinishedEventSeats(ctx)
+	_, _ = statusChecker.SweepOrphanKeys(ctx)
+
+	// Inventory snapshots for point-in-time debugging
+	snapshotter := events.NewInventorySnapshotter(log, snapshotsrepo.NewSnapshotsRepository(db, log), tokens)
+	_, _ = snapshotter.TakeSnapshots(ctx)
+
+	// Scheduled on-sales are warmed up ahead of time; the events service writes the same
+	// Redis cache entries the API reads
+	eventsSvc := events.NewEventsService(log, eventsRepo, tokens).WithStatsCache(cfg.EventStatsCacheTTL).WithCache(cfg.EventCacheTTL)
+	prewarmer := events.NewPrewarmer(log, prewarmsrepo.NewPrewarmsRepository(db, log), eventsSvc, tokens, cfg.PrewarmLead)
+	_, _ = prewarmer.Run(ctx)
+
+	// Unsold quantity of ended sale phases rolls into the next phase
+	salePhases := events.NewSalePhases(log, eventsRepo, tokens)
+	_, _ = salePhases.Run(ctx)
+
+	// Daily FX snapshots for display prices; without a provider URL prices show in the event currency only
+	var fetcher *fx.Fetcher
+	if cfg.FXRatesURL != "" {
+		fetcher = fx.NewFetcher(log, fxrepo.NewFXRepository(db, log), cfg.FXRatesURL)
+		if _, err := fetcher.Fetch(ctx); err != nil {
+			log.Error("Initial FX fetch failed", zap.Error(err))
+		}
+	}
 
 	// Set up graceful shutdown
 	sigChan := make(chan os.Signal, 1)
@@ -50,6 +104,12 @@ func main() {
 	// Start periodic checking (every 5 minutes)
 	checkInterval := 5 * time.Minute
 	go statusChecker.RunPeriodicCheck(ctx, checkInterval)
+	go snapshotter.RunPeriodic(ctx, cfg.SnapshotInterval)
+	go prewarmer.RunPeriodic(ctx, cfg.PrewarmInterval)
+	go salePhases.RunPeriodic(ctx, cfg.SalePhaseRolloverInterval)
+	if fetcher != nil {
+		go fetcher.RunPeriodic(ctx, cfg.FXFetchInterval)
+	}
 
 	log.Info("Event status checker started", zap.Duration("check_interval", checkInterval))
 
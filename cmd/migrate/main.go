@@ -0,0 +1,114 @@
+// Command migrate applies the embedded SQL migrations to the database at POSTGRES_URL.
+//
+//	migrate up          apply every pending migration
+//	migrate up N        apply the next N
+//	migrate down N      roll back the last N
+//	migrate goto V      migrate up or down to version V
+//	migrate version     print the current version
+//	migrate force V     mark the database as at V without running anything, after fixing a
+//	                    failed migration by hand
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/joho/godotenv"
+
+	"github.com/samirwankhede/lewly-pgpyewj/cmd/migrate/migrations"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/config"
+)
+
+func main() {
+	_ = godotenv.Load()
+	database := flag.String("database", config.Load().PostgresURL, "Postgres URL to migrate")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: migrate [-database URL] up [N] | down N | goto V | version | force V")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	m, err := migrations.Open(*database)
+	if err != nil {
+		fail(err)
+	}
+	defer m.Close()
+	m.Log = stderrLogger{}
+
+	cmd, args := flag.Arg(0), flag.Args()[1:]
+	switch cmd {
+	case "up":
+		if len(args) == 0 {
+			err = m.Up()
+		} else {
+			err = m.Steps(count(args))
+		}
+	case "down":
+		// Rolling everything back drops every table, so it must be asked for by count
+		if len(args) == 0 {
+			fail(errors.New("down needs the number of migrations to roll back"))
+		}
+		err = m.Steps(-count(args))
+	case "goto":
+		err = m.Migrate(uint(count(args)))
+	case "force":
+		if len(args) == 0 {
+			fail(errors.New("force needs a version"))
+		}
+		v, convErr := strconv.Atoi(args[0])
+		if convErr != nil {
+			fail(fmt.Errorf("bad version %q", args[0]))
+		}
+		err = m.Force(v)
+	case "version":
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		fail(err)
+	}
+
+	v, dirty, err := m.Version()
+	switch {
+	case errors.Is(err, migrate.ErrNilVersion):
+		fmt.Println("version: none")
+	case err != nil:
+		fail(err)
+	case dirty:
+		fmt.Printf("version: %d (dirty)\n", v)
+	default:
+		fmt.Printf("version: %d\n", v)
+	}
+}
+
+// count parses the first argument as a positive number of migrations.
+func count(args []string) int {
+	if len(args) == 0 {
+		fail(errors.New("missing number"))
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n <= 0 {
+		fail(fmt.Errorf("bad number %q", args[0]))
+	}
+	return n
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "migrate:", err)
+	os.Exit(1)
+}
+
+// stderrLogger prints each migration as it runs.
+type stderrLogger struct{}
+
+func (stderrLogger) Printf(format string, v ...any) { fmt.Fprintf(os.Stderr, format, v...) }
+func (stderrLogger) Verbose() bool                  { return false }
@@ -0,0 +1,20 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- SEATS_ARCHIVE - cold storage for seats of finished (expired/cancelled) events.
+-- Rows are moved here by the event status checker so the hot seats partitions
+-- and their indexes only hold seats of live events.
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS seats_archive (
+    id UUID NOT NULL,
+    event_id UUID NOT NULL,
+    seat_label TEXT,
+    status TEXT,
+    held_until TIMESTAMPTZ NULL,
+    held_by_booking UUID NULL,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    archived_at TIMESTAMPTZ DEFAULT now(),
+    PRIMARY KEY(event_id, id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_seats_archive_event_label ON seats_archive (event_id, seat_label);
@@ -0,0 +1,2 @@
+-- +migrate Down
+ALTER TABLE users DROP COLUMN IF EXISTS role_version;
@@ -0,0 +1,4 @@
+-- +migrate Up
+-- role_version is embedded in JWTs and bumped on every role change so admin
+-- tokens issued before a demotion stop being honoured.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role_version INT NOT NULL DEFAULT 1;
@@ -0,0 +1,5 @@
+-- +migrate Down
+DROP TABLE IF EXISTS organizer_follows;
+DROP INDEX IF EXISTS idx_events_organizer_start;
+ALTER TABLE events DROP COLUMN IF EXISTS organizer_id;
+DROP TABLE IF EXISTS organizers;
@@ -0,0 +1,30 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- ORGANIZERS - public profiles that own events and can be followed
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS organizers (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    website TEXT NOT NULL DEFAULT '',
+    logo_url TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE TRIGGER organizers_set_updated_at BEFORE UPDATE ON organizers
+FOR EACH ROW EXECUTE FUNCTION set_updated_at_column();
+
+ALTER TABLE events ADD COLUMN IF NOT EXISTS organizer_id UUID REFERENCES organizers(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_events_organizer_start ON events (organizer_id, start_time);
+
+--------------------------------------------------------------------------------
+-- ORGANIZER_FOLLOWS (who gets notified when an organizer publishes an event)
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS organizer_follows (
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    organizer_id UUID REFERENCES organizers(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    PRIMARY KEY (user_id, organizer_id)
+);
+CREATE INDEX IF NOT EXISTS idx_organizer_follows_organizer ON organizer_follows(organizer_id);
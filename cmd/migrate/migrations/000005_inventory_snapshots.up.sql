@@ -0,0 +1,19 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- INVENTORY_SNAPSHOTS - periodic record of what the system believed about each
+-- live event's inventory (Postgres counters alongside Redis tokens), used to
+-- reconstruct state when investigating oversells.
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS inventory_snapshots (
+    id BIGSERIAL PRIMARY KEY,
+    event_id UUID NOT NULL,
+    capacity INT NOT NULL,
+    reserved INT NOT NULL,
+    held INT NOT NULL,
+    tokens_remaining INT NOT NULL,
+    pending_bookings INT NOT NULL,
+    waitlist_size INT NOT NULL,
+    taken_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_inventory_snapshots_event_taken ON inventory_snapshots (event_id, taken_at);
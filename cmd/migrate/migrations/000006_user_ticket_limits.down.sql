@@ -0,0 +1,5 @@
+-- +migrate Down
+ALTER TABLE organizers DROP COLUMN IF EXISTS user_ticket_window_hours;
+ALTER TABLE organizers DROP COLUMN IF EXISTS max_tickets_per_user;
+ALTER TABLE events DROP COLUMN IF EXISTS user_ticket_window_hours;
+ALTER TABLE events DROP COLUMN IF EXISTS max_tickets_per_user;
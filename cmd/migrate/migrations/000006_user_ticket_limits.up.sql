@@ -0,0 +1,11 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- Rolling per-user ticket limits. An event-level limit applies to that event
+-- only; an organizer-level limit is shared across all of the organizer's events.
+-- NULL means no limit; the window defaults to 24 hours.
+--------------------------------------------------------------------------------
+ALTER TABLE events ADD COLUMN IF NOT EXISTS max_tickets_per_user INT NULL CHECK (max_tickets_per_user > 0);
+ALTER TABLE events ADD COLUMN IF NOT EXISTS user_ticket_window_hours INT NULL CHECK (user_ticket_window_hours > 0);
+
+ALTER TABLE organizers ADD COLUMN IF NOT EXISTS max_tickets_per_user INT NULL CHECK (max_tickets_per_user > 0);
+ALTER TABLE organizers ADD COLUMN IF NOT EXISTS user_ticket_window_hours INT NULL CHECK (user_ticket_window_hours > 0);
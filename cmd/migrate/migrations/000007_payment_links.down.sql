@@ -0,0 +1,3 @@
+-- +migrate Down
+DROP TABLE IF EXISTS payment_link_clicks;
+DROP TABLE IF EXISTS payment_links;
@@ -0,0 +1,27 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- PAYMENT_LINKS - short codes (/p/:code) for emailed payment URLs. A link stops
+-- resolving once the booking's payment window has passed.
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS payment_links (
+    code TEXT PRIMARY KEY,
+    booking_id UUID NOT NULL,
+    target_url TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_payment_links_booking ON payment_links (booking_id);
+
+--------------------------------------------------------------------------------
+-- PAYMENT_LINK_CLICKS - every resolution of a short link, for funnel analytics
+-- and support
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS payment_link_clicks (
+    id BIGSERIAL PRIMARY KEY,
+    code TEXT NOT NULL REFERENCES payment_links(code) ON DELETE CASCADE,
+    booking_id UUID NOT NULL,
+    expired BOOLEAN NOT NULL DEFAULT FALSE,
+    user_agent TEXT NOT NULL DEFAULT '',
+    clicked_at TIMESTAMPTZ DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_payment_link_clicks_booking ON payment_link_clicks (booking_id, clicked_at);
@@ -0,0 +1,6 @@
+-- +migrate Down
+DROP INDEX IF EXISTS idx_events_geo;
+ALTER TABLE events DROP COLUMN IF EXISTS longitude;
+ALTER TABLE events DROP COLUMN IF EXISTS latitude;
+DROP EXTENSION IF EXISTS earthdistance;
+DROP EXTENSION IF EXISTS cube;
@@ -0,0 +1,13 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- Venue coordinates for "events near me". earthdistance (on top of cube) gives
+-- a GiST-indexable ll_to_earth() so radius searches don't scan every event.
+--------------------------------------------------------------------------------
+CREATE EXTENSION IF NOT EXISTS cube;
+CREATE EXTENSION IF NOT EXISTS earthdistance;
+
+ALTER TABLE events ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION NULL CHECK (latitude BETWEEN -90 AND 90);
+ALTER TABLE events ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION NULL CHECK (longitude BETWEEN -180 AND 180);
+
+CREATE INDEX IF NOT EXISTS idx_events_geo ON events USING gist (ll_to_earth(latitude, longitude))
+    WHERE latitude IS NOT NULL AND longitude IS NOT NULL;
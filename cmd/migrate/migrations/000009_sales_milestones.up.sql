@@ -0,0 +1,16 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- SALES_MILESTONES - per-event sales goals (percent of capacity sold, 100 = sold
+-- out). crossed_at is set once, by whichever payment first pushes sales past the
+-- milestone, and the organizer is notified by email and/or webhook.
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS sales_milestones (
+    id BIGSERIAL PRIMARY KEY,
+    event_id UUID NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    percent INT NOT NULL CHECK (percent BETWEEN 1 AND 100),
+    notify_email TEXT,
+    webhook_url TEXT,
+    crossed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    CONSTRAINT unique_event_milestone UNIQUE (event_id, percent)
+);
@@ -0,0 +1,4 @@
+-- +migrate Down
+ALTER TABLE events DROP COLUMN IF EXISTS likes_enabled;
+ALTER TABLE events DROP COLUMN IF EXISTS seat_selection_enabled;
+ALTER TABLE events DROP COLUMN IF EXISTS waitlist_enabled;
@@ -0,0 +1,9 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- Per-event feature toggles. Existing events keep every feature on.
+-- seat_selection_enabled = false means general admission: buyers ask for a
+-- quantity and seats are assigned from the available ones.
+--------------------------------------------------------------------------------
+ALTER TABLE events ADD COLUMN IF NOT EXISTS waitlist_enabled BOOLEAN NOT NULL DEFAULT true;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS seat_selection_enabled BOOLEAN NOT NULL DEFAULT true;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS likes_enabled BOOLEAN NOT NULL DEFAULT true;
@@ -0,0 +1,13 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- This index is deliberately not unique, and idempotency does not rely on it.
+-- Correctness relies on the UNIQUE constraint unique_event_idempotency
+-- (event_id, idempotency_key) from 000001: bookings is hash-partitioned by
+-- event_id, so a unique index has to include it, and that constraint is what
+-- rejects a second insert with the same key, which CreatePending turns into
+-- the existing booking. Dropping it would let concurrent retries create
+-- duplicates. This index only serves the key-only lookup done before
+-- reserving tokens.
+--------------------------------------------------------------------------------
+CREATE INDEX IF NOT EXISTS idx_bookings_idempotency_key ON bookings (idempotency_key)
+    WHERE idempotency_key IS NOT NULL;
@@ -0,0 +1,2 @@
+-- +migrate Down
+DROP INDEX IF EXISTS idx_events_duplicate_lookup;
@@ -0,0 +1,7 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- Serves the create-time duplicate check (same name + venue + start_time).
+-- Not unique: admins can create a deliberate duplicate with allow_duplicate.
+--------------------------------------------------------------------------------
+CREATE INDEX IF NOT EXISTS idx_events_duplicate_lookup
+    ON events (lower(btrim(name)), lower(btrim(venue)), start_time);
@@ -0,0 +1,2 @@
+-- +migrate Down
+ALTER TABLE bookings DROP COLUMN IF EXISTS source;
@@ -0,0 +1,7 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- The channel a booking was made through: web, mobile, box_office, waitlist
+-- (promoted from the waitlist) or partner:<key id>. Earlier bookings have no
+-- record of it and are counted as web.
+--------------------------------------------------------------------------------
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS source TEXT NOT NULL DEFAULT 'web';
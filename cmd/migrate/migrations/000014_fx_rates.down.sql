@@ -0,0 +1,10 @@
+-- +migrate Down
+ALTER TABLE bookings DROP COLUMN IF EXISTS fx_as_of;
+ALTER TABLE bookings DROP COLUMN IF EXISTS fx_rate;
+ALTER TABLE bookings DROP COLUMN IF EXISTS display_amount;
+ALTER TABLE bookings DROP COLUMN IF EXISTS display_currency;
+ALTER TABLE bookings DROP COLUMN IF EXISTS amount_due;
+ALTER TABLE bookings DROP COLUMN IF EXISTS currency;
+DROP TABLE IF EXISTS fx_rates;
+ALTER TABLE users DROP COLUMN IF EXISTS preferred_currency;
+ALTER TABLE events DROP COLUMN IF EXISTS currency;
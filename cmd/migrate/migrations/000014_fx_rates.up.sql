@@ -0,0 +1,26 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- Multi-currency display prices. Events are priced and charged in their base
+-- currency; buyers can see prices converted to a preferred currency using a
+-- daily FX snapshot. A booking records both the charge and the converted amount
+-- shown to the buyer, along with the rate and snapshot date used.
+--------------------------------------------------------------------------------
+ALTER TABLE events ADD COLUMN IF NOT EXISTS currency TEXT NOT NULL DEFAULT 'USD';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS preferred_currency TEXT NOT NULL DEFAULT '';
+
+-- One row per currency per day: units of currency per one unit of base
+CREATE TABLE IF NOT EXISTS fx_rates (
+    as_of DATE NOT NULL,
+    currency TEXT NOT NULL,
+    base TEXT NOT NULL,
+    rate NUMERIC(20,10) NOT NULL CHECK (rate > 0),
+    fetched_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (as_of, currency)
+);
+
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS currency TEXT NOT NULL DEFAULT 'USD';
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS amount_due NUMERIC(12,2) NOT NULL DEFAULT 0;
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS display_currency TEXT;
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS display_amount NUMERIC(12,2);
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS fx_rate NUMERIC(20,10);
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS fx_as_of DATE;
@@ -0,0 +1,3 @@
+-- +migrate Down
+DROP TABLE IF EXISTS event_invitations;
+ALTER TABLE events DROP COLUMN IF EXISTS private;
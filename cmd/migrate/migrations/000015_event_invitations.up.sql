@@ -0,0 +1,20 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- Private events and invitations. A private event is left out of public
+-- listings and can only be booked by users holding a redeemed invitation.
+-- Admins import invitees in bulk; each gets a unique code, and redeemed_at
+-- records when the invitee claimed it.
+--------------------------------------------------------------------------------
+ALTER TABLE events ADD COLUMN IF NOT EXISTS private BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS event_invitations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    event_id UUID NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    redeemed_at TIMESTAMPTZ,
+    UNIQUE (event_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_invitations_user ON event_invitations(user_id);
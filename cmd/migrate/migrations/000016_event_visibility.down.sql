@@ -0,0 +1,6 @@
+-- +migrate Down
+DROP INDEX IF EXISTS idx_events_public_start;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS private BOOLEAN NOT NULL DEFAULT false;
+-- Unlisted events have no equivalent and become public again
+UPDATE events SET private = true WHERE visibility = 'private';
+ALTER TABLE events DROP COLUMN IF EXISTS visibility;
@@ -0,0 +1,13 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- Event visibility replaces the private flag. Public events are listed;
+-- unlisted events are left out of listings and search but open to anyone with
+-- the link; private events are hidden and need an invitation code, checked when
+-- the event is viewed and again when it is booked.
+--------------------------------------------------------------------------------
+ALTER TABLE events ADD COLUMN IF NOT EXISTS visibility TEXT NOT NULL DEFAULT 'public'
+    CHECK (visibility IN ('public', 'unlisted', 'private'));
+UPDATE events SET visibility = 'private' WHERE private;
+ALTER TABLE events DROP COLUMN IF EXISTS private;
+
+CREATE INDEX IF NOT EXISTS idx_events_public_start ON events(start_time) WHERE visibility = 'public';
@@ -0,0 +1,3 @@
+-- +migrate Down
+DROP TABLE IF EXISTS notification_recipients;
+DROP TABLE IF EXISTS notification_batches;
@@ -0,0 +1,37 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- Notification batches. Mass emails (event cancellations, new event notices to
+-- followers) are queued as a batch with one row per recipient and sent by a
+-- rate-limited dispatcher. Recipients are claimed in chunks with a lease, so a
+-- crashed sender's claims are picked up again and a restart resumes the batch
+-- where it stopped.
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS notification_batches (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    kind TEXT NOT NULL,
+    event_id UUID REFERENCES events(id) ON DELETE SET NULL,
+    subject TEXT NOT NULL,
+    body TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'sending' CHECK (status IN ('sending', 'done')),
+    total INT NOT NULL DEFAULT 0,
+    sent INT NOT NULL DEFAULT 0,
+    failed INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    completed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_batches_unfinished ON notification_batches(created_at) WHERE status = 'sending';
+
+CREATE TABLE IF NOT EXISTS notification_recipients (
+    batch_id UUID NOT NULL REFERENCES notification_batches(id) ON DELETE CASCADE,
+    email TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sending', 'sent', 'failed')),
+    attempts INT NOT NULL DEFAULT 0,
+    claimed_at TIMESTAMPTZ,
+    sent_at TIMESTAMPTZ,
+    last_error TEXT,
+    PRIMARY KEY (batch_id, email)
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_recipients_open ON notification_recipients(batch_id, status) WHERE status IN ('pending', 'sending');
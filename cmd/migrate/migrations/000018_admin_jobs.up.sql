@@ -0,0 +1,27 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- Admin jobs. Long-running admin operations (event cancellation, cancellation
+-- refunds, invitee imports) run in the background and record their state,
+-- progress counters and a sample of item errors here for admins to poll. A job
+-- whose server stops heartbeating it is marked failed.
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS admin_jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    kind TEXT NOT NULL,
+    event_id UUID REFERENCES events(id) ON DELETE SET NULL,
+    state TEXT NOT NULL DEFAULT 'queued' CHECK (state IN ('queued', 'running', 'completed', 'failed')),
+    total INT NOT NULL DEFAULT 0,
+    processed INT NOT NULL DEFAULT 0,
+    succeeded INT NOT NULL DEFAULT 0,
+    failed INT NOT NULL DEFAULT 0,
+    error_samples JSONB NOT NULL DEFAULT '[]',
+    result JSONB,
+    error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    started_at TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_admin_jobs_created ON admin_jobs(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_admin_jobs_active ON admin_jobs(updated_at) WHERE state IN ('queued', 'running');
@@ -0,0 +1,2 @@
+-- +migrate Down
+ALTER TABLE events DROP COLUMN IF EXISTS no_single_seat;
@@ -0,0 +1,6 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- no_single_seat stops bookings from stranding a lone empty seat between
+-- taken ones in a row. Off by default, so existing events sell as before.
+--------------------------------------------------------------------------------
+ALTER TABLE events ADD COLUMN IF NOT EXISTS no_single_seat BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,9 @@
+-- +migrate Down
+-- Authorized and voided bookings have no equivalent; nothing was charged for either
+UPDATE bookings SET payment_status = 'failed' WHERE payment_status IN ('authorized', 'voided');
+ALTER TABLE bookings DROP CONSTRAINT IF EXISTS bookings_payment_status_check;
+ALTER TABLE bookings ADD CONSTRAINT bookings_payment_status_check
+    CHECK (payment_status IN ('pending', 'paid', 'failed', 'refunded'));
+ALTER TABLE events DROP COLUMN IF EXISTS capture_at;
+ALTER TABLE events DROP COLUMN IF EXISTS payment_capture;
+DROP TABLE IF EXISTS payments;
@@ -0,0 +1,42 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- Payments. One row per attempt to take money for a booking. Events that
+-- capture immediately charge at booking ('captured' straight away); events with
+-- payment_capture = 'manual' only authorize the card ('authorized') and capture
+-- when the organizer confirms or automatically at capture_at (start_time when
+-- unset). 'capturing' is a claim on an authorization while its capture is in
+-- flight; a claim older than a few minutes was abandoned and is taken over.
+-- Authorizations are voided when their booking is cancelled or times out.
+-- bookings is partitioned on (event_id, id), so booking_id is not a foreign key.
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS payments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    booking_id UUID NOT NULL,
+    event_id UUID NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    payment_id TEXT NOT NULL,
+    provider_ref TEXT,
+    amount NUMERIC(12,2) NOT NULL,
+    currency TEXT NOT NULL,
+    state TEXT NOT NULL CHECK (state IN ('authorized', 'capturing', 'captured', 'voided', 'refunded', 'failed')),
+    error TEXT,
+    claimed_at TIMESTAMPTZ,
+    authorized_at TIMESTAMPTZ,
+    captured_at TIMESTAMPTZ,
+    voided_at TIMESTAMPTZ,
+    refunded_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- At most one live payment per booking
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payments_live_booking ON payments(booking_id)
+    WHERE state IN ('authorized', 'capturing', 'captured');
+CREATE INDEX IF NOT EXISTS idx_payments_event_state ON payments(event_id, state);
+
+ALTER TABLE events ADD COLUMN IF NOT EXISTS payment_capture TEXT NOT NULL DEFAULT 'immediate'
+    CHECK (payment_capture IN ('immediate', 'manual'));
+ALTER TABLE events ADD COLUMN IF NOT EXISTS capture_at TIMESTAMPTZ;
+
+ALTER TABLE bookings DROP CONSTRAINT IF EXISTS bookings_payment_status_check;
+ALTER TABLE bookings ADD CONSTRAINT bookings_payment_status_check
+    CHECK (payment_status IN ('pending', 'authorized', 'paid', 'voided', 'failed', 'refunded'));
@@ -0,0 +1,6 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- Availability counts and seat assignment filter seats by event and status;
+-- idx_seats_event_label only narrows them to the event.
+--------------------------------------------------------------------------------
+CREATE INDEX IF NOT EXISTS idx_seats_event_status ON seats (event_id, status);
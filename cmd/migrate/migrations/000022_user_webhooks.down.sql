@@ -0,0 +1,3 @@
+-- +migrate Down
+DROP TABLE IF EXISTS user_webhook_deliveries;
+DROP TABLE IF EXISTS user_webhooks;
@@ -0,0 +1,41 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- Personal webhooks. A user registers URLs that are POSTed their own bookings'
+-- status changes (calendar sync bots, travel tools). Each change is queued as a
+-- delivery per matching webhook in the same call that announces it, then sent
+-- by a dispatcher that claims due deliveries with a lease and retries failures
+-- with backoff. A webhook whose deliveries keep failing is disabled.
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS user_webhooks (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    event_types TEXT[] NOT NULL DEFAULT '{}',   -- empty: every type
+    active BOOLEAN NOT NULL DEFAULT true,
+    consecutive_failures INT NOT NULL DEFAULT 0,
+    disabled_reason TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_webhooks_user ON user_webhooks(user_id);
+
+CREATE TABLE IF NOT EXISTS user_webhook_deliveries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    webhook_id UUID NOT NULL REFERENCES user_webhooks(id) ON DELETE CASCADE,
+    booking_id UUID NOT NULL,
+    event_type TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sending', 'delivered', 'failed')),
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    claimed_at TIMESTAMPTZ,
+    response_status INT,
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    delivered_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_webhook_deliveries_due ON user_webhook_deliveries(next_attempt_at) WHERE status IN ('pending', 'sending');
+CREATE INDEX IF NOT EXISTS idx_user_webhook_deliveries_webhook ON user_webhook_deliveries(webhook_id, created_at DESC);
@@ -0,0 +1,6 @@
+-- +migrate Down
+DROP INDEX IF EXISTS idx_bookings_payment_deferred;
+
+ALTER TABLE bookings
+    DROP COLUMN IF EXISTS payment_resume_claimed_at,
+    DROP COLUMN IF EXISTS payment_deferred_at;
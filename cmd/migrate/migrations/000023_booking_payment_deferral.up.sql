@@ -0,0 +1,13 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- Bookings finalized while the payment service is failing its health checks keep
+-- their seats but get no payment link yet. payment_deferred_at marks them until
+-- the worker sends the link; payment_resume_claimed_at leases one to a worker.
+--------------------------------------------------------------------------------
+ALTER TABLE bookings
+    ADD COLUMN IF NOT EXISTS payment_deferred_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS payment_resume_claimed_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_bookings_payment_deferred
+    ON bookings (payment_deferred_at)
+    WHERE payment_deferred_at IS NOT NULL;
@@ -0,0 +1,3 @@
+-- +migrate Down
+DROP TABLE IF EXISTS event_subscription_matches;
+DROP TABLE IF EXISTS event_subscriptions;
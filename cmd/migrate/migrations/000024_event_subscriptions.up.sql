@@ -0,0 +1,35 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- Event subscriptions. A user follows a category ("music") or a tag ("jazz",
+-- "bangalore"), matched against an event's metadata tags and venue, and hears
+-- about newly published public events that match: an alert right away, or a
+-- periodic digest. Each event reaches a user once, recorded as a match; digest
+-- matches wait unsent until the user's digest goes out.
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS event_subscriptions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL CHECK (kind IN ('category', 'tag')),
+    term TEXT NOT NULL,   -- lower-cased
+    delivery TEXT NOT NULL DEFAULT 'digest' CHECK (delivery IN ('instant', 'digest')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (user_id, kind, term)
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_subscriptions_term ON event_subscriptions(kind, term);
+
+CREATE TABLE IF NOT EXISTS event_subscription_matches (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    event_id UUID NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    subscription_id UUID REFERENCES event_subscriptions(id) ON DELETE SET NULL,
+    delivery TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    claimed_at TIMESTAMPTZ,
+    sent_at TIMESTAMPTZ,
+    PRIMARY KEY (user_id, event_id)
+);
+
+-- Digest sender: unsent matches by user
+CREATE INDEX IF NOT EXISTS idx_event_subscription_matches_unsent
+    ON event_subscription_matches(user_id, created_at)
+    WHERE sent_at IS NULL;
@@ -0,0 +1,4 @@
+-- +migrate Down
+DROP INDEX IF EXISTS idx_bookings_created_at;
+
+ALTER TABLE bookings DROP COLUMN IF EXISTS payment_provider;
@@ -0,0 +1,10 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- payment_provider is the provider whose webhook last reported on the booking's
+-- payment (in progress or succeeded); NULL until one does. The worker groups
+-- pending bookings by age and recent conversion by it, so a provider leaving
+-- bookings stuck at payment shows up before their holds run out.
+--------------------------------------------------------------------------------
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS payment_provider TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_bookings_created_at ON bookings (created_at);
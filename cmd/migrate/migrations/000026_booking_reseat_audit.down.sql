@@ -0,0 +1,5 @@
+-- +migrate Down
+DELETE FROM booking_audit WHERE action = 'reseated';
+ALTER TABLE booking_audit DROP CONSTRAINT IF EXISTS booking_audit_action_check;
+ALTER TABLE booking_audit ADD CONSTRAINT booking_audit_action_check
+    CHECK (action IN ('created', 'cancelled', 'waitlisted', 'expired', 'finalized', 'promoted'));
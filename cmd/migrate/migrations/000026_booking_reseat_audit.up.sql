@@ -0,0 +1,10 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- Support can move a booking to other seats (a broken seat, a dispute) through
+-- POST /admin/bookings/:id/reseat. Each move is written to booking_audit as a
+-- 'reseated' row whose payload holds the old and new seats, the reason given
+-- and the admin who made it.
+--------------------------------------------------------------------------------
+ALTER TABLE booking_audit DROP CONSTRAINT IF EXISTS booking_audit_action_check;
+ALTER TABLE booking_audit ADD CONSTRAINT booking_audit_action_check
+    CHECK (action IN ('created', 'cancelled', 'waitlisted', 'expired', 'finalized', 'promoted', 'reseated'));
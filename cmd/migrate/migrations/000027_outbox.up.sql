@@ -0,0 +1,25 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- OUTBOX - Kafka messages written in the same transaction as the change they
+-- announce, so a booking is never committed without its finalize message. The
+-- worker's relay claims unpublished rows oldest first (claimed_at is a lease,
+-- taken over once it runs out), publishes them and sets published_at; a relay
+-- that dies between the two publishes the row again, so delivery is
+-- at-least-once. Published rows are deleted after a day. envelope is the
+-- message's JSON encoded Kafka envelope; the relay re-encodes it with the
+-- configured codec.
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS outbox (
+    id BIGSERIAL PRIMARY KEY,
+    topic TEXT NOT NULL,
+    message_key TEXT NOT NULL,
+    envelope JSONB NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    claimed_at TIMESTAMPTZ,
+    published_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_unpublished ON outbox (id) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_published_at ON outbox (published_at) WHERE published_at IS NOT NULL;
@@ -0,0 +1,26 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- PROVIDER_EVENTS - payment provider webhook events (Stripe payment_intent.*)
+-- kept for the API to apply after acknowledging them. The webhook endpoint
+-- only verifies and stores the event, keyed by the provider's event ID so
+-- redeliveries are dropped; a background loop claims unprocessed events
+-- (claimed_at is a lease) and records the payment and books the booking.
+-- Events still failing after a few attempts are left with their last error
+-- for an operator.
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS provider_events (
+    id TEXT PRIMARY KEY,
+    provider TEXT NOT NULL,
+    type TEXT NOT NULL,
+    booking_id UUID,
+    provider_ref TEXT NOT NULL,
+    amount NUMERIC(12,2) NOT NULL,
+    currency TEXT NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    claimed_at TIMESTAMPTZ,
+    processed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_provider_events_unprocessed ON provider_events (created_at) WHERE processed_at IS NULL;
@@ -0,0 +1,2 @@
+-- +migrate Down
+DROP INDEX IF EXISTS idx_users_oauth_identity;
@@ -0,0 +1,7 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- OAuth sign-in looks users up by (oauth_provider, oauth_sub); an identity can
+-- belong to one user only. Password users keep both columns empty.
+--------------------------------------------------------------------------------
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_oauth_identity ON users (oauth_provider, oauth_sub)
+    WHERE oauth_sub <> '';
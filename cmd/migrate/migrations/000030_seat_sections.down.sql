@@ -0,0 +1,13 @@
+-- +migrate Down
+ALTER TABLE seats_archive DROP COLUMN IF EXISTS position;
+ALTER TABLE seats DROP COLUMN IF EXISTS position;
+
+ALTER TABLE seats_archive DROP COLUMN IF EXISTS tier;
+ALTER TABLE seats_archive DROP COLUMN IF EXISTS row_label;
+ALTER TABLE seats_archive DROP COLUMN IF EXISTS section;
+
+ALTER TABLE seats DROP COLUMN IF EXISTS tier;
+ALTER TABLE seats DROP COLUMN IF EXISTS row_label;
+ALTER TABLE seats DROP COLUMN IF EXISTS section;
+
+DROP TABLE IF EXISTS event_price_tiers;
@@ -0,0 +1,25 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- SEAT SECTIONS - seats carry the section and row they sit in, the price tier
+-- they are sold at and their position in the layout, for drawing the map. A
+-- tier's price replaces the event's ticket_price for its seats; seats without
+-- a tier (flat label lists, events created before tiers) are sold at
+-- ticket_price. The archive keeps the same columns so cloning a finished event
+-- keeps its seat map.
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS event_price_tiers (
+    event_id UUID NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    price NUMERIC(12,2) NOT NULL CHECK (price >= 0),
+    PRIMARY KEY (event_id, name)
+);
+
+ALTER TABLE seats ADD COLUMN IF NOT EXISTS section TEXT NOT NULL DEFAULT '';
+ALTER TABLE seats ADD COLUMN IF NOT EXISTS row_label TEXT NOT NULL DEFAULT '';
+ALTER TABLE seats ADD COLUMN IF NOT EXISTS tier TEXT NOT NULL DEFAULT '';
+ALTER TABLE seats ADD COLUMN IF NOT EXISTS position INT NOT NULL DEFAULT 0;
+
+ALTER TABLE seats_archive ADD COLUMN IF NOT EXISTS section TEXT NOT NULL DEFAULT '';
+ALTER TABLE seats_archive ADD COLUMN IF NOT EXISTS row_label TEXT NOT NULL DEFAULT '';
+ALTER TABLE seats_archive ADD COLUMN IF NOT EXISTS tier TEXT NOT NULL DEFAULT '';
+ALTER TABLE seats_archive ADD COLUMN IF NOT EXISTS position INT NOT NULL DEFAULT 0;
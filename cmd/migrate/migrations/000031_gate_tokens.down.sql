@@ -0,0 +1,3 @@
+-- +migrate Down
+DROP TABLE IF EXISTS check_ins;
+DROP TABLE IF EXISTS gate_tokens;
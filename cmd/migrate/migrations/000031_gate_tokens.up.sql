@@ -0,0 +1,33 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- GATE_TOKENS - credentials of gate staff devices, each scoped to one event.
+-- Admins issue a token for a window (valid_from .. expires_at); only its
+-- SHA-256 is kept, so the token itself is shown once, when it is issued.
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS gate_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    event_id UUID NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    name TEXT NOT NULL DEFAULT '',
+    token_hash BYTEA NOT NULL UNIQUE,
+    issued_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    valid_from TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_gate_tokens_event ON gate_tokens (event_id, created_at);
+
+--------------------------------------------------------------------------------
+-- CHECK_INS - one row per booking admitted at a gate; the primary key makes a
+-- second scan of the same ticket a no-op. bookings is keyed by (event_id, id),
+-- so that is what the booking reference names.
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS check_ins (
+    booking_id UUID PRIMARY KEY,
+    event_id UUID NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    gate_token_id UUID REFERENCES gate_tokens(id) ON DELETE SET NULL,
+    checked_in_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    FOREIGN KEY (event_id, booking_id) REFERENCES bookings (event_id, id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_check_ins_event ON check_ins (event_id, checked_in_at);
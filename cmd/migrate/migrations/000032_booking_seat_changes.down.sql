@@ -0,0 +1,6 @@
+-- +migrate Down
+DROP TABLE IF EXISTS payment_adjustments;
+DELETE FROM booking_audit WHERE action = 'seats_changed';
+ALTER TABLE booking_audit DROP CONSTRAINT IF EXISTS booking_audit_action_check;
+ALTER TABLE booking_audit ADD CONSTRAINT booking_audit_action_check
+    CHECK (action IN ('created', 'cancelled', 'waitlisted', 'expired', 'finalized', 'promoted', 'reseated'));
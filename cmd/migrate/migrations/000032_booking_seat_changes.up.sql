@@ -0,0 +1,29 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- Customers can change the seats of their own booking through
+-- PUT /v1/bookings/:id/seats. Each change is written to booking_audit as a
+-- 'seats_changed' row with the old and new seats and amounts.
+--------------------------------------------------------------------------------
+ALTER TABLE booking_audit DROP CONSTRAINT IF EXISTS booking_audit_action_check;
+ALTER TABLE booking_audit ADD CONSTRAINT booking_audit_action_check
+    CHECK (action IN ('created', 'cancelled', 'waitlisted', 'expired', 'finalized', 'promoted', 'reseated', 'seats_changed'));
+
+--------------------------------------------------------------------------------
+-- PAYMENT_ADJUSTMENTS - money moved for a paid booking after its payment: the
+-- extra charge for dearer seats, or the partial refund for cheaper ones.
+-- Refunds are taken against the booking's live payment (provider_ref); extra
+-- charges are payments of their own, with the caller's payment_id. Like
+-- payment_links, booking_id has no foreign key: bookings is keyed by
+-- (event_id, id).
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS payment_adjustments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    booking_id UUID NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('charge', 'refund')),
+    amount NUMERIC(12,2) NOT NULL CHECK (amount > 0),
+    currency TEXT NOT NULL,
+    payment_id TEXT,
+    provider_ref TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_payment_adjustments_booking ON payment_adjustments (booking_id, created_at);
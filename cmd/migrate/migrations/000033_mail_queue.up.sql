@@ -0,0 +1,26 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- MAIL_QUEUE - single emails (payment requests, OTPs, waitlist promotions...)
+-- are queued here instead of being sent inline, and the worker drains the
+-- queue. A failed send is retried with exponential backoff (next_attempt_at)
+-- until max attempts; mail that runs out of attempts or is rejected outright
+-- is left 'dead' for admins to inspect and requeue. Claims carry a lease, so
+-- mail a crashed worker had claimed is sent again.
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS mail_queue (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    to_email TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    body TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sending', 'sent', 'dead')),
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    claimed_at TIMESTAMPTZ,
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    sent_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_mail_queue_due ON mail_queue(next_attempt_at) WHERE status IN ('pending', 'sending');
+CREATE INDEX IF NOT EXISTS idx_mail_queue_status ON mail_queue(status, created_at);
@@ -0,0 +1,2 @@
+-- +migrate Down
+DROP TABLE IF EXISTS seat_integrity_issues;
@@ -0,0 +1,21 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- SEAT_INTEGRITY_ISSUES - bookings whose seats can't be trusted. A payment for
+-- a booking without valid seats is refused and flagged here ('payment'); the
+-- worker's integrity check flags booked bookings whose stored seats don't match
+-- the seats the seats table assigns them ('check'), and resolves its flags once
+-- they match again. Admins list open issues and resolve payment flags by hand.
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS seat_integrity_issues (
+    booking_id UUID PRIMARY KEY,
+    event_id UUID NOT NULL,
+    source TEXT NOT NULL CHECK (source IN ('payment', 'check')),
+    reason TEXT NOT NULL,
+    stored_seats JSONB NOT NULL DEFAULT '[]'::jsonb,
+    assigned_seats JSONB NOT NULL DEFAULT '[]'::jsonb,
+    detected_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_seen_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    resolved_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_seat_integrity_issues_open ON seat_integrity_issues(detected_at) WHERE resolved_at IS NULL;
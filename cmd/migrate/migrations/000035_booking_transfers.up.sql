@@ -0,0 +1,27 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- BOOKING_TRANSFERS - a booked booking handed to someone else. The owner names
+-- the recipient's email and the recipient gets a claim link carrying a token;
+-- only its sha256 hash is stored. Accepting it, signed in as that email, moves
+-- the booking to the recipient and writes a 'transferred' row to booking_audit.
+-- A booking has at most one pending transfer.
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS booking_transfers (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    booking_id UUID NOT NULL,
+    event_id UUID NOT NULL,
+    from_user_id UUID NOT NULL,
+    to_email TEXT NOT NULL,
+    to_user_id UUID,
+    token_hash BYTEA NOT NULL UNIQUE,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'cancelled')),
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    accepted_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_booking_transfers_pending ON booking_transfers (booking_id) WHERE status = 'pending';
+
+ALTER TABLE booking_audit DROP CONSTRAINT IF EXISTS booking_audit_action_check;
+ALTER TABLE booking_audit ADD CONSTRAINT booking_audit_action_check
+    CHECK (action IN ('created', 'cancelled', 'waitlisted', 'expired', 'finalized', 'promoted', 'reseated', 'seats_changed', 'transferred'));
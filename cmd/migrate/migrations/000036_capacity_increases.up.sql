@@ -0,0 +1,21 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- CAPACITY_INCREASES - seats added to an event mid-sale. Each row is one
+-- increase: the seats inserted, the capacity before and after, how many of the
+-- new seats were offered to the waitlist and how many went back on sale as
+-- Redis tokens. Its ID keys the waitlist offers, so a retried offer pass
+-- finds the bookings it already created.
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS capacity_increases (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    event_id UUID NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    seats JSONB NOT NULL,
+    previous_capacity INT NOT NULL,
+    new_capacity INT NOT NULL,
+    waitlist_offers INT NOT NULL DEFAULT 0,
+    tokens_released INT NOT NULL DEFAULT 0,
+    admin_id UUID,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_capacity_increases_event ON capacity_increases(event_id, created_at);
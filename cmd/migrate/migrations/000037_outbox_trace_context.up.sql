@@ -0,0 +1,7 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- OUTBOX.TRACE_CONTEXT - the W3C trace context (traceparent, tracestate) of the
+-- request that wrote the message, so the relay's Kafka publish and the worker
+-- that consumes it continue the request's trace. NULL when it wasn't traced.
+--------------------------------------------------------------------------------
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS trace_context JSONB;
@@ -0,0 +1,6 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- IDX_EVENTS_FEED - the syndication feed walks public events in (updated_at, id)
+-- order and starts delta syncs from an updated_at, so both are one index scan.
+--------------------------------------------------------------------------------
+CREATE INDEX IF NOT EXISTS idx_events_feed ON events(updated_at, id) WHERE visibility = 'public';
@@ -0,0 +1,7 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- IDX_BOOKINGS_EVENT_STATUS_UPDATED - the admin sales curve buckets an event's
+-- booked bookings by updated_at, their finalization time, so it reads them from
+-- the index in time order instead of sorting the event's bookings.
+--------------------------------------------------------------------------------
+CREATE INDEX IF NOT EXISTS idx_bookings_event_status_updated ON bookings (event_id, status, updated_at);
@@ -0,0 +1,4 @@
+-- +migrate Down
+DROP INDEX IF EXISTS idx_events_venue_trgm;
+DROP INDEX IF EXISTS idx_events_search;
+ALTER TABLE events DROP COLUMN IF EXISTS search_vector;
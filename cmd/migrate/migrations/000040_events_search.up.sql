@@ -0,0 +1,17 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- EVENTS.SEARCH_VECTOR - what GET /v1/events/search matches: the name (weight
+-- A), venue and category (B) and every string in metadata (C), stemmed as
+-- English. Generated, so it can never drift from the columns. Queries that
+-- match nothing fall back to trigram similarity on name and venue, for typos;
+-- the name already has a trigram index, the venue gets one here.
+--------------------------------------------------------------------------------
+ALTER TABLE events ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', coalesce(name, '')), 'A') ||
+    setweight(to_tsvector('english', coalesce(venue, '')), 'B') ||
+    setweight(to_tsvector('english', coalesce(category, '')), 'B') ||
+    setweight(jsonb_to_tsvector('english', coalesce(metadata, '{}'::jsonb), '["string"]'), 'C')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_events_search ON events USING gin (search_vector);
+CREATE INDEX IF NOT EXISTS idx_events_venue_trgm ON events USING gin (venue gin_trgm_ops);
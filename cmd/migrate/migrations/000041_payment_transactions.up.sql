@@ -0,0 +1,21 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- PAYMENT_TRANSACTIONS - every provider transaction ID a payment callback has
+-- been processed under, so a callback delivered twice is processed once. A
+-- callback claims its ID (claimed_at is a lease) before charging; duplicates
+-- get the stored result back once it completes, and 409 while it is still in
+-- flight. A claim whose callback failed without settling is deleted so the
+-- provider's retry runs again; one left by a crash is taken over after the
+-- lease. An ID is only ever good for the booking it was first seen with.
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS payment_transactions (
+    payment_id TEXT PRIMARY KEY,
+    booking_id UUID NOT NULL,
+    amount NUMERIC(12,2) NOT NULL,
+    result JSONB,
+    claimed_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    completed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_transactions_booking ON payment_transactions (booking_id);
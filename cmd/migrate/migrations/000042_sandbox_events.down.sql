@@ -0,0 +1,5 @@
+-- +migrate Down
+DROP TABLE IF EXISTS sandbox_testers;
+DROP INDEX IF EXISTS idx_events_sandbox;
+ALTER TABLE events DROP CONSTRAINT IF EXISTS events_sandbox_not_public;
+ALTER TABLE events DROP COLUMN IF EXISTS sandbox;
@@ -0,0 +1,20 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- Sandbox events - fully working test events an organizer rehearses an on-sale
+-- with. A sandbox event is never public, so listings, search, feeds and
+-- subscription alerts never see it, and it is left out of analytics. Only
+-- admins and the users on its SANDBOX_TESTERS allowlist can book it or join
+-- its waitlist. The flag is set at creation and never changes; the real event
+-- is created separately.
+--------------------------------------------------------------------------------
+ALTER TABLE events ADD COLUMN IF NOT EXISTS sandbox BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE events DROP CONSTRAINT IF EXISTS events_sandbox_not_public;
+ALTER TABLE events ADD CONSTRAINT events_sandbox_not_public CHECK (NOT sandbox OR visibility <> 'public');
+CREATE INDEX IF NOT EXISTS idx_events_sandbox ON events (id) WHERE sandbox;
+
+CREATE TABLE IF NOT EXISTS sandbox_testers (
+    event_id UUID NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (event_id, user_id)
+);
@@ -0,0 +1,4 @@
+-- +migrate Down
+DROP INDEX IF EXISTS idx_waitlist_event_position_id;
+DROP INDEX IF EXISTS idx_bookings_user_created_id;
+DROP INDEX IF EXISTS idx_events_public_start_id;
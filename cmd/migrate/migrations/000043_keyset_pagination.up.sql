@@ -0,0 +1,10 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- Keyset pagination - lists paged by cursor seek past the last row they
+-- returned instead of skipping OFFSET rows, so they need an index on the sort
+-- key with the ID as tie-breaker: public events by start time, a user's
+-- bookings newest first, and an event's waitlist by position.
+--------------------------------------------------------------------------------
+CREATE INDEX IF NOT EXISTS idx_events_public_start_id ON events (start_time, id) WHERE visibility = 'public';
+CREATE INDEX IF NOT EXISTS idx_bookings_user_created_id ON bookings (user_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_waitlist_event_position_id ON waitlist (event_id, position, id);
@@ -0,0 +1,4 @@
+-- +migrate Down
+DROP INDEX IF EXISTS idx_events_waitlist_open;
+ALTER TABLE waitlist DROP COLUMN IF EXISTS expired_at;
+ALTER TABLE events DROP COLUMN IF EXISTS waitlist_closed_at;
@@ -0,0 +1,18 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- Waitlist expiry - an event's waitlist closes when it starts, or
+-- WAITLIST_CLOSE_BEFORE_MINUTES earlier. The event status checker stamps
+-- events.waitlist_closed_at and marks the entries still waiting expired, and
+-- their users are told. Joins and promotions check the stamp and the start time
+-- themselves, so nothing is promoted into a started event even if the checker
+-- is behind. Events that already started are closed here without emails.
+--------------------------------------------------------------------------------
+ALTER TABLE events ADD COLUMN IF NOT EXISTS waitlist_closed_at TIMESTAMPTZ NULL;
+ALTER TABLE waitlist ADD COLUMN IF NOT EXISTS expired_at TIMESTAMPTZ NULL;
+
+UPDATE waitlist w SET expired_at = now()
+FROM events e
+WHERE e.id = w.event_id AND e.start_time <= now() AND NOT w.opted_out AND w.expired_at IS NULL;
+UPDATE events SET waitlist_closed_at = now() WHERE start_time <= now() AND waitlist_closed_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_events_waitlist_open ON events (start_time) WHERE waitlist_closed_at IS NULL;
@@ -0,0 +1,20 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- ONSALE_PREWARMS - on-sales an admin scheduled to be warmed up. Once
+-- sale_start_time is within PREWARM_LEAD_MINUTES, the event status checker
+-- loads the event into the caches, checks its seats, token bucket and Redis,
+-- and stores the checks as a readiness report: status becomes 'ready' if every
+-- check passed and 'degraded' if any failed. Rescheduling clears the report.
+-- The schedule only drives the warm-up; it does not gate bookings.
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS onsale_prewarms (
+    event_id UUID PRIMARY KEY REFERENCES events(id) ON DELETE CASCADE,
+    sale_start_time TIMESTAMPTZ NOT NULL,
+    status TEXT NOT NULL DEFAULT 'scheduled' CHECK (status IN ('scheduled', 'ready', 'degraded')),
+    checks JSONB NULL,
+    warmed_at TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_onsale_prewarms_start ON onsale_prewarms (sale_start_time);
@@ -0,0 +1,7 @@
+-- +migrate Down
+DROP INDEX IF EXISTS idx_events_owner;
+ALTER TABLE events DROP COLUMN IF EXISTS owner_id;
+ALTER TABLE users DROP COLUMN IF EXISTS organizer_id;
+UPDATE users SET role = 'user', role_version = role_version + 1 WHERE role = 'organizer';
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('user', 'admin'));
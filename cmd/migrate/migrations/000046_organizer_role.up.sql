@@ -0,0 +1,16 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- ORGANIZER ROLE - accounts that run their own events. An organizer creates,
+-- updates, cancels and sees the analytics of the events they own
+-- (events.owner_id) and nothing else. users.organizer_id is the public
+-- organizer profile their events are published under, set by the admin who
+-- granted the role. Events created by admins have no owner.
+--------------------------------------------------------------------------------
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('user', 'admin', 'organizer'));
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS organizer_id UUID NULL REFERENCES organizers(id) ON DELETE SET NULL;
+
+ALTER TABLE events ADD COLUMN IF NOT EXISTS owner_id UUID NULL REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_events_owner ON events (owner_id, start_time) WHERE owner_id IS NOT NULL;
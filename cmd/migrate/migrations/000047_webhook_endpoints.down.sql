@@ -0,0 +1,3 @@
+-- +migrate Down
+DROP TABLE IF EXISTS webhook_endpoint_deliveries;
+DROP TABLE IF EXISTS webhook_endpoints;
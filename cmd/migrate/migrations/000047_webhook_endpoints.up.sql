@@ -0,0 +1,41 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- Integration webhooks. Admins register endpoint URLs for third-party systems
+-- (CRMs, accounting, door staff tools) that are POSTed platform-wide booking and
+-- event changes. Like personal webhooks, each change is queued as a delivery per
+-- subscribed endpoint and sent by a dispatcher that retries failures with
+-- backoff; the deliveries table doubles as the endpoint's delivery log.
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS webhook_endpoints (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    event_types TEXT[] NOT NULL DEFAULT '{}',   -- empty: every type
+    active BOOLEAN NOT NULL DEFAULT true,
+    consecutive_failures INT NOT NULL DEFAULT 0,
+    disabled_reason TEXT,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS webhook_endpoint_deliveries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    endpoint_id UUID NOT NULL REFERENCES webhook_endpoints(id) ON DELETE CASCADE,
+    event_type TEXT NOT NULL,
+    booking_id UUID,          -- set for booking.* types
+    event_id UUID NOT NULL,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sending', 'delivered', 'failed')),
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    claimed_at TIMESTAMPTZ,
+    response_status INT,
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    delivered_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_endpoint_deliveries_due ON webhook_endpoint_deliveries(next_attempt_at) WHERE status IN ('pending', 'sending');
+CREATE INDEX IF NOT EXISTS idx_webhook_endpoint_deliveries_endpoint ON webhook_endpoint_deliveries(endpoint_id, created_at DESC);
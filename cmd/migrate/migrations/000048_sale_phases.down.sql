@@ -0,0 +1,4 @@
+-- +migrate Down
+ALTER TABLE bookings DROP COLUMN IF EXISTS sale_phase_id;
+
+DROP TABLE IF EXISTS event_sale_phases;
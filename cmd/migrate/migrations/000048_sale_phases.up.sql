@@ -0,0 +1,32 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- SALE PHASES - an event can be sold in consecutive phases (early bird,
+-- regular, door), each with its own price, sale window and quantity. A phase's
+-- pool is quantity plus what earlier phases rolled into it minus what it rolled
+-- on: once a phase's window ends, its unsold quantity moves to the next phase.
+-- Bookings record the phase they were sold in; its price replaces the event's
+-- ticket_price for seats without a price tier. Events without phases are sold
+-- as before, from capacity alone.
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS event_sale_phases (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    event_id UUID NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    position INT NOT NULL,
+    price NUMERIC(12,2) NOT NULL CHECK (price >= 0),
+    quantity INT NOT NULL CHECK (quantity >= 0),
+    rolled_in INT NOT NULL DEFAULT 0,
+    rolled_out INT NOT NULL DEFAULT 0,
+    starts_at TIMESTAMPTZ NOT NULL,
+    ends_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (event_id, name),
+    UNIQUE (event_id, position),
+    CHECK (starts_at < ends_at)
+);
+
+-- Ended phases with quantity still to roll over
+CREATE INDEX IF NOT EXISTS idx_event_sale_phases_ends_at ON event_sale_phases (ends_at);
+
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS sale_phase_id UUID REFERENCES event_sale_phases(id);
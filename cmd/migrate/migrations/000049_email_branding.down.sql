@@ -0,0 +1,5 @@
+-- +migrate Down
+ALTER TABLE notification_batches DROP COLUMN IF EXISTS organizer_id;
+ALTER TABLE mail_queue DROP COLUMN IF EXISTS organizer_id;
+
+DROP TABLE IF EXISTS email_branding;
@@ -0,0 +1,25 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- EMAIL BRANDING - the name, footer, support address, logo and legal text
+-- emails are signed with. The row without an organizer is the platform's; an
+-- organizer's row overrides it, field by field, for emails about their events.
+-- Fields left empty fall back to the platform's, and the platform's to the
+-- built-in defaults. Branding is applied when an email is sent, so queued and
+-- broadcast emails carry the branding current at the time.
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS email_branding (
+    organizer_id UUID REFERENCES organizers(id) ON DELETE CASCADE,
+    name TEXT NOT NULL DEFAULT '',
+    footer TEXT NOT NULL DEFAULT '',
+    support_email TEXT NOT NULL DEFAULT '',
+    logo_url TEXT NOT NULL DEFAULT '',
+    legal_text TEXT NOT NULL DEFAULT '',
+    updated_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    -- One platform row (organizer_id NULL) and one per organizer
+    UNIQUE NULLS NOT DISTINCT (organizer_id)
+);
+
+-- The organizer whose branding a queued or broadcast email is sent with
+ALTER TABLE mail_queue ADD COLUMN IF NOT EXISTS organizer_id UUID REFERENCES organizers(id) ON DELETE SET NULL;
+ALTER TABLE notification_batches ADD COLUMN IF NOT EXISTS organizer_id UUID REFERENCES organizers(id) ON DELETE SET NULL;
@@ -0,0 +1,10 @@
+-- +migrate Down
+DROP INDEX IF EXISTS idx_provider_events_booking;
+DROP INDEX IF EXISTS idx_webhook_endpoint_deliveries_booking;
+DROP INDEX IF EXISTS idx_user_webhook_deliveries_booking;
+
+DROP INDEX IF EXISTS idx_mail_queue_booking;
+ALTER TABLE mail_queue DROP COLUMN IF EXISTS booking_id;
+
+DROP TABLE IF EXISTS message_journal;
+DROP TABLE IF EXISTS booking_events;
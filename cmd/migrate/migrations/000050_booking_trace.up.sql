@@ -0,0 +1,48 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- BOOKING TRACE - GET /admin/bookings/:id/trace puts together what happened to
+-- a booking from every table that records part of it. Three of those are
+-- added here:
+--   booking_events   every status transition announced to clients (payment
+--                    requested, received, delayed, expired, ...), which until
+--                    now only went over Redis pub/sub and to webhooks
+--   message_journal  one row per Kafka message the finalizer handled: its
+--                    partition and offset, how many attempts it took and how
+--                    it ended (succeeded, dead_lettered, failed, abandoned)
+--   mail_queue.booking_id  the booking a queued email is about, if any
+-- and the webhook and provider event tables get booking_id indexes so the trace
+-- doesn't scan them.
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS booking_events (
+    id BIGSERIAL PRIMARY KEY,
+    booking_id UUID NOT NULL,
+    type TEXT NOT NULL,
+    status TEXT NOT NULL,
+    expires_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_booking_events_booking ON booking_events (booking_id, created_at);
+
+CREATE TABLE IF NOT EXISTS message_journal (
+    id BIGSERIAL PRIMARY KEY,
+    booking_id UUID,          -- NULL for messages that couldn't be decoded
+    topic TEXT NOT NULL,
+    partition INT NOT NULL,
+    message_offset BIGINT NOT NULL,
+    type TEXT NOT NULL,
+    outcome TEXT NOT NULL CHECK (outcome IN ('succeeded', 'dead_lettered', 'failed', 'abandoned')),
+    attempts INT NOT NULL,
+    error TEXT,
+    duration_ms INT NOT NULL,
+    handled_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_journal_booking ON message_journal (booking_id, handled_at);
+
+ALTER TABLE mail_queue ADD COLUMN IF NOT EXISTS booking_id UUID;
+CREATE INDEX IF NOT EXISTS idx_mail_queue_booking ON mail_queue (booking_id) WHERE booking_id IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_user_webhook_deliveries_booking ON user_webhook_deliveries (booking_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_endpoint_deliveries_booking ON webhook_endpoint_deliveries (booking_id) WHERE booking_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_provider_events_booking ON provider_events (booking_id) WHERE booking_id IS NOT NULL;
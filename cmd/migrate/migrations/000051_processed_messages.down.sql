@@ -0,0 +1,7 @@
+-- +migrate Down
+DELETE FROM message_journal WHERE outcome = 'duplicate';
+ALTER TABLE message_journal DROP CONSTRAINT IF EXISTS message_journal_outcome_check;
+ALTER TABLE message_journal ADD CONSTRAINT message_journal_outcome_check
+    CHECK (outcome IN ('succeeded', 'dead_lettered', 'failed', 'abandoned'));
+
+DROP TABLE IF EXISTS processed_messages;
@@ -0,0 +1,25 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- PROCESSED MESSAGES - a ledger of the Kafka messages the finalizer has handled
+-- successfully, keyed by topic, message key, partition and offset. The
+-- finalizer commits offsets after handling, so a worker dying in between had
+-- the message redelivered and its emails sent and payment timeout scheduled
+-- again. Messages are now recorded here before their offset is committed and
+-- looked up before they are handled; a redelivered one is committed without
+-- being handled again, and journaled as a duplicate. Rows are purged after a
+-- week, longer than a message waits for its commit.
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS processed_messages (
+    topic TEXT NOT NULL,
+    message_key TEXT NOT NULL,
+    partition INT NOT NULL,
+    message_offset BIGINT NOT NULL,
+    processed_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (topic, message_key, partition, message_offset)
+);
+
+CREATE INDEX IF NOT EXISTS idx_processed_messages_processed_at ON processed_messages (processed_at);
+
+ALTER TABLE message_journal DROP CONSTRAINT IF EXISTS message_journal_outcome_check;
+ALTER TABLE message_journal ADD CONSTRAINT message_journal_outcome_check
+    CHECK (outcome IN ('succeeded', 'dead_lettered', 'failed', 'abandoned', 'duplicate'));
@@ -0,0 +1,30 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- EVENT_MEMBERS - co-organizers an event's owner invited to help manage it. An
+-- invitation is emailed with a claim link and becomes an active membership once
+-- accepted by a user signed in with the invited address. Each member holds a
+-- subset of the event permissions:
+--   edit_event       update the event's details
+--   view_analytics   the event's analytics and sales curve
+--   manage_check_in  issue, list and revoke gate tokens
+--   trigger_refunds  refund every paid booking of the event
+-- Cancelling the event and managing its members stay with the owner.
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS event_members (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    event_id UUID NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    email TEXT NOT NULL,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,   -- set once accepted
+    permissions TEXT[] NOT NULL,
+    status TEXT NOT NULL DEFAULT 'invited' CHECK (status IN ('invited', 'active')),
+    token_hash BYTEA UNIQUE,                               -- cleared once accepted
+    invited_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMPTZ NOT NULL,                       -- of the invitation
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    accepted_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_event_members_email ON event_members (event_id, lower(email));
+CREATE UNIQUE INDEX IF NOT EXISTS idx_event_members_user ON event_members (event_id, user_id) WHERE user_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_event_members_user_events ON event_members (user_id) WHERE user_id IS NOT NULL;
@@ -0,0 +1,24 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- REFUNDS - every refund paid back for a booking's cancellation or its event's,
+-- with the breakdown it was worked out from: each refunded seat's share of
+-- what the booking paid and of the cancellation fee, in the minor unit of the
+-- booking's currency, and the rounding policy that split them. Refunds were
+-- only the payment's state before, and the fee a flat subtraction from the
+-- amount paid. payment_id is empty for bookings paid before payments were
+-- recorded, which are refunded by booking ID. booking_id has no foreign key,
+-- as bookings is keyed by (event_id, id).
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS refunds (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    booking_id UUID NOT NULL,
+    payment_id UUID REFERENCES payments(id) ON DELETE SET NULL,
+    reason TEXT NOT NULL CHECK (reason IN ('booking_cancelled', 'event_cancelled')),
+    currency TEXT NOT NULL,
+    amount NUMERIC(12,2) NOT NULL CHECK (amount >= 0),
+    cancellation_fee NUMERIC(12,2) NOT NULL DEFAULT 0 CHECK (cancellation_fee >= 0),
+    breakdown JSONB NOT NULL,
+    provider_ref TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_refunds_booking ON refunds (booking_id, created_at);
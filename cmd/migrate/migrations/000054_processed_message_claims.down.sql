@@ -0,0 +1,3 @@
+-- +migrate Down
+DELETE FROM processed_messages WHERE status = 'processing';
+ALTER TABLE processed_messages DROP COLUMN IF EXISTS status;
@@ -0,0 +1,13 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- PROCESSED MESSAGES become claims. The finalizer recorded a message only after
+-- handling it, so a worker dying between the two still had it redelivered and
+-- its emails and payment timeout repeated. A message is now claimed as
+-- 'processing' before it is handled and marked 'processed' after; a failed
+-- attempt drops its claim so the redelivery handles it again. A redelivered
+-- message still 'processing' was interrupted partway by a crash, and is
+-- dead-lettered with reason 'interrupted' for an operator to check and replay
+-- rather than handled again. processed_at is when the row last changed.
+--------------------------------------------------------------------------------
+ALTER TABLE processed_messages ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'processed'
+    CHECK (status IN ('processing', 'processed'));
@@ -0,0 +1,5 @@
+-- +migrate Down
+UPDATE refunds SET reason = 'booking_cancelled' WHERE reason = 'seats_cancelled';
+ALTER TABLE refunds DROP CONSTRAINT IF EXISTS refunds_reason_check;
+ALTER TABLE refunds ADD CONSTRAINT refunds_reason_check
+    CHECK (reason IN ('booking_cancelled', 'event_cancelled'));
@@ -0,0 +1,11 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- REFUNDS - 'seats_cancelled' refunds pay back some of a paid booking's seats
+-- when its owner gives them up and keeps the rest. The breakdown only lists
+-- the cancelled seats; the booking keeps its payment, now lower by what those
+-- seats paid, and later refunds split the cancellation fee left after the fees
+-- already charged.
+--------------------------------------------------------------------------------
+ALTER TABLE refunds DROP CONSTRAINT IF EXISTS refunds_reason_check;
+ALTER TABLE refunds ADD CONSTRAINT refunds_reason_check
+    CHECK (reason IN ('booking_cancelled', 'event_cancelled', 'seats_cancelled'));
@@ -0,0 +1,3 @@
+-- +migrate Down
+ALTER TABLE processed_messages DROP COLUMN IF EXISTS claimed_at;
+ALTER TABLE processed_messages DROP COLUMN IF EXISTS claimed_by;
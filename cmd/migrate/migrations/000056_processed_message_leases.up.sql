@@ -0,0 +1,12 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- PROCESSED MESSAGE LEASES. A redelivered message still 'processing' was
+-- dead-lettered as interrupted, but a rebalance redelivers messages whose
+-- first consumer is still handling them, which then ran twice, and a crashed
+-- worker's booking stayed pending until an operator replayed it. A claim now
+-- records the worker holding it and when it was last renewed: the holder
+-- renews it while handling the message, a redelivery waits while it is live,
+-- and takes the claim over once it has gone unrenewed past the lease.
+--------------------------------------------------------------------------------
+ALTER TABLE processed_messages ADD COLUMN IF NOT EXISTS claimed_by TEXT;
+ALTER TABLE processed_messages ADD COLUMN IF NOT EXISTS claimed_at TIMESTAMPTZ NOT NULL DEFAULT now();
@@ -0,0 +1,13 @@
+-- +migrate Up
+--------------------------------------------------------------------------------
+-- OUTBOX.FAILED_AT - a row the relay could not publish in OUTBOX_MAX_ATTEMPTS
+-- tries, such as one its topic rejects or whose envelope can't be re-encoded,
+-- was claimed and retried on every pass for good. It is now set aside with
+-- failed_at and its last_error, no longer claimed or counted in the backlog,
+-- until an operator clears failed_at and attempts to publish it again.
+--------------------------------------------------------------------------------
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS failed_at TIMESTAMPTZ;
+
+DROP INDEX IF EXISTS idx_outbox_unpublished;
+CREATE INDEX IF NOT EXISTS idx_outbox_unpublished ON outbox (id) WHERE published_at IS NULL AND failed_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_failed ON outbox (id) WHERE failed_at IS NOT NULL;
@@ -0,0 +1,65 @@
+// Package migrations embeds the SQL migrations in this directory and applies them with
+// golang-migrate. Versions are tracked in the schema_migrations table, the same one the
+// migrate CLI writes, so databases migrated either way stay interchangeable.
+package migrations
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"go.uber.org/zap"
+)
+
+//go:embed *.sql
+var FS embed.FS
+
+// Open returns a migrator for the embedded migrations against the Postgres database at
+// databaseURL. Close it when done.
+func Open(databaseURL string) (*migrate.Migrate, error) {
+	src, err := iofs.New(FS, ".")
+	if err != nil {
+		return nil, err
+	}
+	m, err := migrate.NewWithSourceInstance("iofs", src, databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("migrations: %w", err)
+	}
+	return m, nil
+}
+
+// Up applies every migration not yet applied. Concurrent callers, such as several API
+// instances starting at once, wait on the driver's advisory lock and find nothing left to
+// do. A database left dirty by a failed migration is refused until it is fixed by hand and
+// forced to a version.
+func Up(databaseURL string, log *zap.Logger) error {
+	m, err := Open(databaseURL)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	from, dirty, err := m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("migrations: database is dirty at version %d; fix it and run migrate force", from)
+	}
+	if err := m.Up(); err != nil {
+		if errors.Is(err, migrate.ErrNoChange) {
+			log.Info("Database schema is up to date", zap.Uint("version", from))
+			return nil
+		}
+		return err
+	}
+	to, _, err := m.Version()
+	if err != nil {
+		return err
+	}
+	log.Info("Migrated database", zap.Uint("from_version", from), zap.Uint("to_version", to))
+	return nil
+}
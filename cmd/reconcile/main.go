@@ -9,6 +9,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/samirwankhede/lewly-pgpyewj/internal/config"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/lock"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/logger"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/metrics"
 	redisx "github.com/samirwankhede/lewly-pgpyewj/internal/redis"
@@ -21,7 +22,7 @@ func main() {
 	log := logger.New(cfg.Env)
 	ctx := context.Background()
 
-	db, err := store.NewDB(ctx, cfg.PostgresURL, int32(cfg.MaxDBConnections))
+	db, err := store.NewDB(ctx, cfg.PostgresURL, int32(cfg.MaxBatchDBConnections), store.WithSlowQueryLog(log, cfg.SlowQueryThreshold), store.WithApplicationName("evently-batch"))
 	if err != nil {
 		log.Fatal("db", zap.Error(err))
 	}
@@ -65,35 +66,51 @@ func main() {
 	}
 	rows.Close()
 
-	// Now reconcile event_capacity vs Redis tokens
-	rows, err = db.Pool.Query(ctx, `SELECT event_id, capacity, reserved_count FROM event_capacity`)
+	// Now reconcile event_capacity vs Redis tokens, one event at a time under its lock so a
+	// concurrent cancellation or payment timeout isn't undone
+	rows, err = db.Pool.Query(ctx, `SELECT event_id FROM event_capacity`)
 	if err != nil {
 		log.Fatal("query event_capacity", zap.Error(err))
 	}
-	defer rows.Close()
-
+	var ids []string
 	for rows.Next() {
 		var id string
-		var capacity, reserved int
-		if err := rows.Scan(&id, &capacity, &reserved); err != nil {
+		if err := rows.Scan(&id); err != nil {
 			log.Error("scan event_capacity", zap.Error(err))
 			continue
 		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	locks := lock.New(db, cfg.EventLockWait, cfg.EventLockHold)
+	for _, id := range ids {
+		err := locks.Event(ctx, "reconcile", id, func(ctx context.Context) error {
+			var capacity, reserved int
+			err := db.Pool.QueryRow(ctx, `SELECT capacity, reserved_count FROM event_capacity WHERE event_id = $1`, id).Scan(&capacity, &reserved)
+			if err != nil {
+				return err
+			}
 
-		desired := capacity - reserved
-		rem, _ := tokens.Remaining(ctx, id)
-		if rem != desired {
-			diff := desired - rem
-			if diff > 0 {
-				_ = tokens.Release(ctx, id, diff)
-			} else if diff < 0 {
-				// consume extra tokens
-				for i := 0; i < -diff; i++ {
-					_, _ = tokens.Reserve(ctx, id, 1)
+			desired := capacity - reserved
+			rem, _ := tokens.Remaining(ctx, id)
+			if rem != desired {
+				diff := desired - rem
+				if diff > 0 {
+					_ = tokens.Release(ctx, id, diff)
+				} else if diff < 0 {
+					// consume extra tokens
+					for i := 0; i < -diff; i++ {
+						_, _ = tokens.Reserve(ctx, id, 1)
+					}
 				}
+				metrics.ReconciliationFixesTotal.Inc()
+				log.Info("reconciled", zap.String("event", id), zap.Int("desired", desired), zap.Int("was", rem))
 			}
-			metrics.ReconciliationFixesTotal.Inc()
-			log.Info("reconciled", zap.String("event", id), zap.Int("desired", desired), zap.Int("was", rem))
+			return nil
+		})
+		if err != nil {
+			log.Error("reconcile event", zap.Error(err), zap.String("event_id", id))
 		}
 	}
 	fmt.Println("reconciliation complete at", time.Now())
@@ -0,0 +1,115 @@
+// Command redis_rebuild regenerates Redis state from Postgres after Redis lost data
+// (failover, flush): every live event's token bucket, and the payment-timeout markers and
+// schedule of pending bookings. Pending bookings whose payment window already closed are
+// scheduled as due now, so the worker expires them.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/joho/godotenv"
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/config"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/logger"
+	redisx "github.com/samirwankhede/lewly-pgpyewj/internal/redis"
+	workerService "github.com/samirwankhede/lewly-pgpyewj/internal/service/worker"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store"
+	storeBookings "github.com/samirwankhede/lewly-pgpyewj/internal/store/bookings"
+	storeEvents "github.com/samirwankhede/lewly-pgpyewj/internal/store/events"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "report what would change without writing to Redis")
+	skipTimeouts := flag.Bool("skip-timeouts", false, "only rebuild token buckets")
+	flag.Parse()
+
+	_ = godotenv.Load()
+	cfg := config.Load()
+	log := logger.New(cfg.Env)
+	ctx := context.Background()
+
+	db, err := store.NewDB(ctx, cfg.PostgresURL, int32(cfg.MaxBatchDBConnections), store.WithSlowQueryLog(log, cfg.SlowQueryThreshold), store.WithApplicationName("evently-batch"))
+	if err != nil {
+		log.Fatal("db", zap.Error(err))
+	}
+	defer db.Close()
+	tokens := redisx.NewTokenBucket(cfg.RedisAddr)
+	defer tokens.Close()
+	timeouts := redisx.NewTimeoutBucket(cfg.RedisAddr)
+	defer timeouts.Close()
+
+	eventsRepo := storeEvents.NewEventsRepository(db, log)
+	bookingsRepo := storeBookings.NewBookingsRepository(db, log)
+
+	// Token buckets: capacity minus the seats of pending and booked bookings
+	expected, err := eventsRepo.ExpectedTokensLive(ctx)
+	if err != nil {
+		log.Fatal("compute expected tokens", zap.Error(err))
+	}
+	rebuilt := 0
+	for eventID, want := range expected {
+		had, err := tokens.Remaining(ctx, eventID)
+		if err != nil {
+			log.Error("read tokens", zap.Error(err), zap.String("event_id", eventID))
+			continue
+		}
+		if had == want {
+			continue
+		}
+		log.Info("rebuilding tokens", zap.String("event_id", eventID), zap.Int("was", had), zap.Int("now", want), zap.Bool("dry_run", *dryRun))
+		if *dryRun {
+			rebuilt++
+			continue
+		}
+		if err := tokens.InitTokens(ctx, eventID, want); err != nil {
+			log.Error("set tokens", zap.Error(err), zap.String("event_id", eventID))
+			continue
+		}
+		rebuilt++
+	}
+
+	markers, expired := 0, 0
+	if !*skipTimeouts {
+		markers, expired = rebuildTimeouts(ctx, log, bookingsRepo, timeouts, *dryRun)
+	}
+
+	fmt.Printf("redis rebuild complete at %s: %d of %d token buckets reset, %d timeout markers restored, %d overdue bookings due to time out\n",
+		time.Now().Format(time.RFC3339), rebuilt, len(expected), markers, expired)
+}
+
+// rebuildTimeouts restores the payment-timeout marker of every pending booking, which puts it
+// back on the timeout schedule. Bookings already past the payment window are due at once, so
+// the worker's poller expires them on its next pass.
+func rebuildTimeouts(ctx context.Context, log *zap.Logger, bookingsRepo *storeBookings.BookingsRepository, timeouts *redisx.TimeoutBucket, dryRun bool) (int, int) {
+	pending, err := bookingsRepo.ListPending(ctx)
+	if err != nil {
+		log.Fatal("list pending bookings", zap.Error(err))
+	}
+
+	markers, expired := 0, 0
+	cutoff := time.Now().Add(-workerService.PaymentWindow)
+	for _, b := range pending {
+		overdue := b.UpdatedAt.Before(cutoff)
+		if dryRun {
+			markers++
+			if overdue {
+				expired++
+			}
+			continue
+		}
+
+		if err := timeouts.AddBooking(ctx, b.EventID, b.ID, b.UpdatedAt.Add(workerService.PaymentWindow)); err != nil {
+			log.Error("restore timeout marker", zap.Error(err), zap.String("booking_id", b.ID))
+			continue
+		}
+		markers++
+		if overdue {
+			expired++
+		}
+	}
+	return markers, expired
+}
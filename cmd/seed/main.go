@@ -0,0 +1,555 @@
+// Command seed fills a database with demo data for staging environments and load tests:
+// organizers, users, events at a fixed set of venues with generated seat maps, bookings in
+// every state and waitlists on sold-out events. Everything is derived from -seed, so the same
+// seed always writes the same rows, and databases seeded with different seeds don't collide.
+// Schedules are relative to -base (today by default); pending bookings are created at seeding
+// time so they are still inside their payment window. Run it after migrations, then run
+// redis_rebuild to load the token buckets and payment timeouts of the seeded bookings.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/joho/godotenv"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/config"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/logger"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store"
+)
+
+// namespace scopes the name-based UUIDs of seeded rows.
+var namespace = uuid.MustParse("5b0f1c0e-8f4e-4c43-9d8a-3a6f0c2e7d19")
+
+var errAlreadySeeded = errors.New("already seeded")
+
+type venue struct {
+	name     string
+	lat, lon float64
+}
+
+var (
+	venues = []venue{
+		{"Riverside Arena", 51.5072, -0.1276},
+		{"The Old Mill", 53.4808, -2.2426},
+		{"Harbour Hall", 40.7128, -74.0060},
+		{"Granite Amphitheatre", 37.7749, -122.4194},
+		{"Lakeview Pavilion", 41.8781, -87.6298},
+		{"Palace Theatre", 19.0760, 72.8777},
+		{"Northern Lights Dome", 59.9139, 10.7522},
+		{"Civic Centre", -33.8688, 151.2093},
+	}
+	categories   = []string{"concert", "conference", "comedy", "theatre", "sports", "festival"}
+	adjectives   = []string{"Midnight", "Electric", "Golden", "Silent", "Neon", "Wild", "Velvet", "Crimson"}
+	nouns        = []string{"Sessions", "Live", "Summit", "Nights", "Showcase", "Revue", "Weekender", "Open"}
+	firstNames   = []string{"Asha", "Ben", "Chen", "Dana", "Emeka", "Farah", "Gabriel", "Hana", "Ivan", "Jia", "Kofi", "Lena"}
+	lastNames    = []string{"Patel", "Okafor", "Smith", "Garcia", "Kim", "Novak", "Haddad", "Silva", "Mehta", "Larsen"}
+	organizerTag = []string{"Live", "Presents", "Events", "Productions", "Collective"}
+	prices       = []float64{25, 40, 60, 90, 150}
+	currencies   = []string{"USD", "USD", "USD", "EUR", "INR"}
+	sources      = []string{"web", "web", "web", "mobile", "mobile", "box_office"}
+)
+
+type user struct {
+	id, name, email string
+}
+
+type organizer struct {
+	id, name, description, website string
+}
+
+type event struct {
+	id, name, venue, category, status, currency, organizerID string
+	start, end                                               time.Time
+	capacity, reserved, maxPerBooking, likes                 int
+	price, lat, lon                                          float64
+}
+
+type seat struct {
+	eventID, label, status, bookingID string
+}
+
+type booking struct {
+	id, userID, eventID, status, paymentStatus, currency, source string
+	seats                                                        []string
+	amountDue, amountPaid                                        float64
+	createdAt                                                    time.Time
+}
+
+type payment struct {
+	bookingID, eventID, ref, currency, state string
+	amount                                   float64
+	createdAt                                time.Time
+}
+
+type waitlistEntry struct {
+	eventID, userID string
+	position        int
+	createdAt       time.Time
+}
+
+type capacity struct {
+	eventID                  string
+	capacity, reserved, held int
+}
+
+type like struct {
+	userID, eventID string
+}
+
+// dataset is everything one seed writes.
+type dataset struct {
+	users      []user
+	organizers []organizer
+	events     []event
+	seats      []seat
+	bookings   []booking
+	payments   []payment
+	waitlist   []waitlistEntry
+	capacities []capacity
+	likes      []like
+}
+
+type params struct {
+	seed               int64
+	base               time.Time
+	now                time.Time
+	users              int
+	organizers         int
+	eventsPerOrganizer int
+	rows               int
+	seatsPerRow        int
+}
+
+func main() {
+	seed := flag.Int64("seed", 1, "seed the data is derived from; the same seed writes the same rows")
+	base := flag.String("base", "", "date (YYYY-MM-DD) event schedules are relative to; defaults to today (UTC)")
+	users := flag.Int("users", 300, "number of users")
+	organizers := flag.Int("organizers", 4, "number of organizers")
+	eventsPerOrganizer := flag.Int("events-per-organizer", 6, "events per organizer")
+	rows := flag.Int("rows", 12, "seat rows of the largest event (at most 26)")
+	seatsPerRow := flag.Int("seats-per-row", 20, "seats per row")
+	password := flag.String("password", "demo-password", "password of every seeded user")
+	dryRun := flag.Bool("dry-run", false, "report what would be written without writing it")
+	flag.Parse()
+
+	_ = godotenv.Load()
+	cfg := config.Load()
+	log := logger.New(cfg.Env)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	p := params{
+		seed:               *seed,
+		now:                time.Now().UTC(),
+		users:              *users,
+		organizers:         *organizers,
+		eventsPerOrganizer: *eventsPerOrganizer,
+		rows:               *rows,
+		seatsPerRow:        *seatsPerRow,
+	}
+	p.base = p.now.Truncate(24 * time.Hour)
+	if *base != "" {
+		t, err := time.Parse("2006-01-02", *base)
+		if err != nil {
+			log.Fatal("parse -base", zap.Error(err))
+		}
+		p.base = t
+	}
+	if p.users < 1 || p.organizers < 1 || p.eventsPerOrganizer < 1 || p.rows < 1 || p.rows > 26 || p.seatsPerRow < 1 {
+		log.Fatal("users, organizers, events-per-organizer, rows and seats-per-row must be positive, and rows at most 26")
+	}
+
+	data := generate(p)
+	summary := fmt.Sprintf("%d users, %d organizers, %d events, %d seats, %d bookings, %d payments, %d waitlist entries",
+		len(data.users), len(data.organizers), len(data.events), len(data.seats), len(data.bookings), len(data.payments), len(data.waitlist))
+	if *dryRun {
+		fmt.Printf("seed %d would write %s (dry run)\n", p.seed, summary)
+		return
+	}
+
+	// Every seeded user shares one hash; bcrypt salts it, so it differs between runs
+	hash, err := bcrypt.GenerateFromPassword([]byte(*password), bcrypt.DefaultCost)
+	if err != nil {
+		log.Fatal("hash password", zap.Error(err))
+	}
+
+	db, err := store.NewDB(ctx, cfg.PostgresURL, int32(cfg.MaxBatchDBConnections), store.WithSlowQueryLog(log, cfg.SlowQueryThreshold), store.WithApplicationName("evently-batch"))
+	if err != nil {
+		log.Fatal("db", zap.Error(err))
+	}
+	defer db.Close()
+
+	err = db.WithTx(ctx, func(tx pgx.Tx) error {
+		return write(ctx, tx, data, string(hash))
+	})
+	if errors.Is(err, errAlreadySeeded) {
+		fmt.Printf("seed %d is already in the database; nothing written\n", p.seed)
+		return
+	}
+	if err != nil {
+		log.Error("write seed data", zap.Error(err))
+		os.Exit(1)
+	}
+	fmt.Printf("seed %d complete at %s: wrote %s; run redis_rebuild to load token buckets\n", p.seed, time.Now().Format(time.RFC3339), summary)
+}
+
+// id derives the UUID of the n-th row of a kind from the seed.
+func id(seed int64, kind string, n int) string {
+	return uuid.NewSHA1(namespace, []byte(fmt.Sprintf("%d/%s/%d", seed, kind, n))).String()
+}
+
+func pick[T any](rng *rand.Rand, items []T) T {
+	return items[rng.Intn(len(items))]
+}
+
+// generate builds the dataset. Upcoming events are sold to between 15% and 90% of capacity,
+// or sold out with a waitlist; past events are expired with their bookings settled, and a few
+// upcoming ones are cancelled with every booking refunded.
+func generate(p params) *dataset {
+	rng := rand.New(rand.NewSource(p.seed))
+	d := &dataset{}
+
+	for i := 0; i < p.users; i++ {
+		d.users = append(d.users, user{
+			id:    id(p.seed, "user", i),
+			name:  pick(rng, firstNames) + " " + pick(rng, lastNames),
+			email: fmt.Sprintf("demo%d.user%d@example.com", p.seed, i),
+		})
+	}
+
+	for o := 0; o < p.organizers; o++ {
+		name := pick(rng, adjectives) + " " + pick(rng, organizerTag)
+		org := organizer{
+			id:          id(p.seed, "organizer", o),
+			name:        name,
+			description: "Demo organizer " + name,
+			website:     fmt.Sprintf("https://example.com/organizers/%d-%d", p.seed, o),
+		}
+		d.organizers = append(d.organizers, org)
+
+		for j := 0; j < p.eventsPerOrganizer; j++ {
+			d.addEvent(rng, p, org.id, len(d.events))
+		}
+	}
+	return d
+}
+
+func (d *dataset) addEvent(rng *rand.Rand, p params, organizerID string, n int) {
+	v := pick(rng, venues)
+	days := rng.Intn(120) - 30
+	start := p.base.AddDate(0, 0, days).Add(time.Duration(17+rng.Intn(5)) * time.Hour)
+	status := "upcoming"
+	switch {
+	case days < 0:
+		status = "expired"
+	case rng.Intn(15) == 0:
+		status = "cancelled"
+	}
+
+	rows := p.rows/2 + rng.Intn(p.rows-p.rows/2) + 1
+	labels := make([]string, 0, rows*p.seatsPerRow)
+	for r := 0; r < rows; r++ {
+		for s := 1; s <= p.seatsPerRow; s++ {
+			labels = append(labels, fmt.Sprintf("%c%d", 'A'+r, s))
+		}
+	}
+
+	e := event{
+		id:            id(p.seed, "event", n),
+		name:          pick(rng, adjectives) + " " + pick(rng, nouns),
+		venue:         v.name,
+		category:      pick(rng, categories),
+		status:        status,
+		currency:      pick(rng, currencies),
+		organizerID:   organizerID,
+		start:         start,
+		end:           start.Add(3 * time.Hour),
+		capacity:      len(labels),
+		maxPerBooking: 4 + rng.Intn(5),
+		price:         pick(rng, prices),
+		lat:           v.lat,
+		lon:           v.lon,
+	}
+
+	soldOut := status == "upcoming" && rng.Intn(5) == 0
+	fill := 0.15 + rng.Float64()*0.75
+	if soldOut {
+		fill = 1
+	}
+	target := int(fill * float64(len(labels)))
+	taken := make(map[string]string, target)
+	var booked, held int
+	for next, b := 0, 0; next < target; b++ {
+		size := 1 + rng.Intn(e.maxPerBooking)
+		if next+size > target {
+			size = target - next
+		}
+		bk := booking{
+			id:        id(p.seed, fmt.Sprintf("event/%d/booking", n), b),
+			userID:    pick(rng, d.users).id,
+			eventID:   e.id,
+			status:    bookingStatus(rng, status, soldOut),
+			currency:  e.currency,
+			source:    pick(rng, sources),
+			seats:     labels[next : next+size],
+			amountDue: e.price * float64(size),
+			createdAt: start.AddDate(0, 0, -1-rng.Intn(30)),
+		}
+		if bk.createdAt.After(p.now) {
+			bk.createdAt = p.now.Add(-time.Duration(rng.Intn(72*60)) * time.Minute)
+		}
+		next += size
+
+		switch bk.status {
+		case "booked":
+			bk.paymentStatus, bk.amountPaid = "paid", bk.amountDue
+			d.payments = append(d.payments, payment{bk.id, e.id, "ch_" + id(p.seed, "payment", len(d.payments)), e.currency, "captured", bk.amountDue, bk.createdAt})
+			for _, l := range bk.seats {
+				taken[l] = bk.id
+			}
+			booked += size
+			e.reserved++
+		case "pending":
+			// Created now so the payment window is still open after redis_rebuild
+			bk.paymentStatus, bk.createdAt = "pending", p.now.Add(-time.Duration(rng.Intn(10))*time.Minute)
+			held += size
+		case "cancelled":
+			bk.paymentStatus = "refunded"
+			d.payments = append(d.payments, payment{bk.id, e.id, "ch_" + id(p.seed, "payment", len(d.payments)), e.currency, "refunded", bk.amountDue, bk.createdAt})
+		case "expired":
+			bk.paymentStatus = "failed"
+		}
+		d.bookings = append(d.bookings, bk)
+	}
+
+	for _, l := range labels {
+		s := seat{eventID: e.id, label: l, status: "available"}
+		if b, ok := taken[l]; ok {
+			s.status, s.bookingID = "booked", b
+		}
+		d.seats = append(d.seats, s)
+	}
+	d.capacities = append(d.capacities, capacity{e.id, e.capacity, booked, held})
+
+	if soldOut {
+		for i, u := range rng.Perm(len(d.users))[:min(len(d.users), 5+rng.Intn(16))] {
+			d.waitlist = append(d.waitlist, waitlistEntry{e.id, d.users[u].id, i + 1, p.now.Add(-time.Duration(i+1) * time.Hour)})
+		}
+	}
+	for _, u := range rng.Perm(len(d.users))[:rng.Intn(len(d.users)/4+1)] {
+		d.likes = append(d.likes, like{d.users[u].id, e.id})
+		e.likes++
+	}
+	d.events = append(d.events, e)
+}
+
+// bookingStatus picks a booking's status. Sold-out events only have bookings that hold seats,
+// so their token bucket is empty; cancelled events have every booking cancelled.
+func bookingStatus(rng *rand.Rand, eventStatus string, soldOut bool) string {
+	roll := rng.Intn(100)
+	switch {
+	case eventStatus == "cancelled":
+		return "cancelled"
+	case eventStatus == "expired":
+		if roll < 88 {
+			return "booked"
+		}
+		return "cancelled"
+	case soldOut:
+		if roll < 92 {
+			return "booked"
+		}
+		return "pending"
+	case roll < 78:
+		return "booked"
+	case roll < 86:
+		return "pending"
+	case roll < 95:
+		return "cancelled"
+	default:
+		return "expired"
+	}
+}
+
+// write inserts the dataset, one statement per table. It returns errAlreadySeeded if the
+// seed's first organizer exists, so rerunning a seed is a no-op.
+func write(ctx context.Context, tx pgx.Tx, d *dataset, passwordHash string) error {
+	var exists bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM organizers WHERE id = $1)`, d.organizers[0].id).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return errAlreadySeeded
+	}
+
+	var ids, names, emails []string
+	for _, u := range d.users {
+		ids, names, emails = append(ids, u.id), append(names, u.name), append(emails, u.email)
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO users (id, name, email, password_hash, role)
+		SELECT id, name, email, $4, 'user'
+		FROM unnest($1::uuid[], $2::text[], $3::text[]) AS u(id, name, email)
+	`, ids, names, emails, passwordHash); err != nil {
+		return fmt.Errorf("users: %w", err)
+	}
+
+	var descriptions, websites []string
+	ids, names = nil, nil
+	for _, o := range d.organizers {
+		ids, names = append(ids, o.id), append(names, o.name)
+		descriptions, websites = append(descriptions, o.description), append(websites, o.website)
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO organizers (id, name, description, website)
+		SELECT * FROM unnest($1::uuid[], $2::text[], $3::text[], $4::text[])
+	`, ids, names, descriptions, websites); err != nil {
+		return fmt.Errorf("organizers: %w", err)
+	}
+
+	var ev struct {
+		ids, names, venues, categories, statuses, currencies, organizers []string
+		starts, ends                                                     []time.Time
+		capacities, reserved, maxPerBooking, likes                       []int
+		prices, lats, lons                                               []float64
+	}
+	for _, e := range d.events {
+		ev.ids, ev.names, ev.venues = append(ev.ids, e.id), append(ev.names, e.name), append(ev.venues, e.venue)
+		ev.categories, ev.statuses = append(ev.categories, e.category), append(ev.statuses, e.status)
+		ev.currencies, ev.organizers = append(ev.currencies, e.currency), append(ev.organizers, e.organizerID)
+		ev.starts, ev.ends = append(ev.starts, e.start), append(ev.ends, e.end)
+		ev.capacities, ev.reserved = append(ev.capacities, e.capacity), append(ev.reserved, e.reserved)
+		ev.maxPerBooking, ev.likes = append(ev.maxPerBooking, e.maxPerBooking), append(ev.likes, e.likes)
+		ev.prices, ev.lats, ev.lons = append(ev.prices, e.price), append(ev.lats, e.lat), append(ev.lons, e.lon)
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO events (id, name, venue, category, status, currency, organizer_id, start_time, end_time,
+		                    capacity, reserved, maximum_tickets_per_booking, likes, ticket_price, latitude, longitude)
+		SELECT id, name, venue, category, status, currency, organizer_id, start_time, end_time,
+		       capacity, reserved, max_per_booking, likes, price::numeric, lat, lon
+		FROM unnest($1::uuid[], $2::text[], $3::text[], $4::text[], $5::text[], $6::text[], $7::uuid[],
+		            $8::timestamptz[], $9::timestamptz[], $10::int[], $11::int[], $12::int[], $13::int[],
+		            $14::float8[], $15::float8[], $16::float8[])
+		     AS e(id, name, venue, category, status, currency, organizer_id, start_time, end_time,
+		          capacity, reserved, max_per_booking, likes, price, lat, lon)
+	`, ev.ids, ev.names, ev.venues, ev.categories, ev.statuses, ev.currencies, ev.organizers, ev.starts, ev.ends,
+		ev.capacities, ev.reserved, ev.maxPerBooking, ev.likes, ev.prices, ev.lats, ev.lons); err != nil {
+		return fmt.Errorf("events: %w", err)
+	}
+
+	var events, labels, statuses, bookingIDs []string
+	for _, s := range d.seats {
+		events, labels = append(events, s.eventID), append(labels, s.label)
+		statuses, bookingIDs = append(statuses, s.status), append(bookingIDs, s.bookingID)
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO seats (event_id, seat_label, status, held_by_booking)
+		SELECT event_id, label, status, NULLIF(booking_id, '')::uuid
+		FROM unnest($1::uuid[], $2::text[], $3::text[], $4::text[]) AS s(event_id, label, status, booking_id)
+	`, events, labels, statuses, bookingIDs); err != nil {
+		return fmt.Errorf("seats: %w", err)
+	}
+
+	var capEvents []string
+	var caps, reserved, held []int
+	for _, c := range d.capacities {
+		capEvents, caps = append(capEvents, c.eventID), append(caps, c.capacity)
+		reserved, held = append(reserved, c.reserved), append(held, c.held)
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO event_capacity (event_id, capacity, reserved_count, held_count)
+		SELECT * FROM unnest($1::uuid[], $2::int[], $3::int[], $4::int[])
+	`, capEvents, caps, reserved, held); err != nil {
+		return fmt.Errorf("event capacity: %w", err)
+	}
+
+	var bk struct {
+		ids, users, events, statuses, paymentStatuses, currencies, sources, seats, keys []string
+		due, paid                                                                       []float64
+		created                                                                         []time.Time
+	}
+	for _, b := range d.bookings {
+		seats, err := json.Marshal(b.seats)
+		if err != nil {
+			return err
+		}
+		bk.ids, bk.users, bk.events = append(bk.ids, b.id), append(bk.users, b.userID), append(bk.events, b.eventID)
+		bk.statuses, bk.paymentStatuses = append(bk.statuses, b.status), append(bk.paymentStatuses, b.paymentStatus)
+		bk.currencies, bk.sources = append(bk.currencies, b.currency), append(bk.sources, b.source)
+		bk.seats, bk.keys = append(bk.seats, string(seats)), append(bk.keys, "seed-"+b.id)
+		bk.due, bk.paid, bk.created = append(bk.due, b.amountDue), append(bk.paid, b.amountPaid), append(bk.created, b.createdAt)
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO bookings (id, user_id, event_id, status, payment_status, currency, source, seats, idempotency_key,
+		                      amount_due, amount_paid, created_at, updated_at)
+		SELECT id, user_id, event_id, status, payment_status, currency, source, seats::jsonb, key,
+		       due::numeric, paid::numeric, created_at, created_at
+		FROM unnest($1::uuid[], $2::uuid[], $3::uuid[], $4::text[], $5::text[], $6::text[], $7::text[], $8::text[],
+		            $9::text[], $10::float8[], $11::float8[], $12::timestamptz[])
+		     AS b(id, user_id, event_id, status, payment_status, currency, source, seats, key, due, paid, created_at)
+	`, bk.ids, bk.users, bk.events, bk.statuses, bk.paymentStatuses, bk.currencies, bk.sources, bk.seats,
+		bk.keys, bk.due, bk.paid, bk.created); err != nil {
+		return fmt.Errorf("bookings: %w", err)
+	}
+
+	var pm struct {
+		bookings, events, refs, currencies, states []string
+		amounts                                    []float64
+		created                                    []time.Time
+	}
+	for _, p := range d.payments {
+		pm.bookings, pm.events, pm.refs = append(pm.bookings, p.bookingID), append(pm.events, p.eventID), append(pm.refs, p.ref)
+		pm.currencies, pm.states = append(pm.currencies, p.currency), append(pm.states, p.state)
+		pm.amounts, pm.created = append(pm.amounts, p.amount), append(pm.created, p.createdAt)
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO payments (booking_id, event_id, payment_id, provider_ref, amount, currency, state,
+		                      captured_at, refunded_at, created_at, updated_at)
+		SELECT booking_id, event_id, 'seed', ref, amount::numeric, currency, state,
+		       created_at, CASE WHEN state = 'refunded' THEN created_at + interval '1 day' END, created_at, created_at
+		FROM unnest($1::uuid[], $2::uuid[], $3::text[], $4::text[], $5::text[], $6::float8[], $7::timestamptz[])
+		     AS p(booking_id, event_id, ref, currency, state, amount, created_at)
+	`, pm.bookings, pm.events, pm.refs, pm.currencies, pm.states, pm.amounts, pm.created); err != nil {
+		return fmt.Errorf("payments: %w", err)
+	}
+
+	var wl struct {
+		events, users []string
+		positions     []int
+		created       []time.Time
+	}
+	for _, w := range d.waitlist {
+		wl.events, wl.users = append(wl.events, w.eventID), append(wl.users, w.userID)
+		wl.positions, wl.created = append(wl.positions, w.position), append(wl.created, w.createdAt)
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO waitlist (event_id, user_id, position, opted_out, created_at)
+		SELECT event_id, user_id, position, false, created_at
+		FROM unnest($1::uuid[], $2::uuid[], $3::int[], $4::timestamptz[]) AS w(event_id, user_id, position, created_at)
+	`, wl.events, wl.users, wl.positions, wl.created); err != nil {
+		return fmt.Errorf("waitlist: %w", err)
+	}
+
+	var likeUsers, likeEvents []string
+	for _, l := range d.likes {
+		likeUsers, likeEvents = append(likeUsers, l.userID), append(likeEvents, l.eventID)
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO event_likes (user_id, event_id)
+		SELECT * FROM unnest($1::uuid[], $2::uuid[])
+	`, likeUsers, likeEvents); err != nil {
+		return fmt.Errorf("likes: %w", err)
+	}
+	return nil
+}
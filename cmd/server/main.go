@@ -14,18 +14,52 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 
+	"github.com/samirwankhede/lewly-pgpyewj/cmd/migrate/migrations"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/api"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/config"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/logger"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/middleware"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/store"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/tracing"
 )
 
 func main() {
 	_ = godotenv.Load()
 
 	cfg := config.Load()
-	log := logger.New(cfg.Env)
+	base, err := logger.NewWithOptions(logger.Options{
+		Env:        cfg.Env,
+		Level:      cfg.LogLevel,
+		Components: cfg.LogLevels,
+		File:       cfg.LogFile,
+		MaxSizeMB:  cfg.LogFileMaxMB,
+		MaxBackups: cfg.LogFileMaxBackups,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "logger:", err)
+		os.Exit(1)
+	}
+	log := logger.Component(base, "api")
+	defer log.Sync()
+
+	shutdownTracing, err := tracing.Setup(context.Background(), log, tracing.Options{
+		ServiceName:   "evently-api",
+		Endpoint:      cfg.OTLPEndpoint,
+		Headers:       tracing.ParseHeaders(cfg.OTLPHeaders),
+		SamplePercent: cfg.TraceSamplePercent,
+		Environment:   cfg.Env,
+	})
+	if err != nil {
+		log.Fatal("tracing setup", zap.Error(err))
+	}
+
+	// The schema must be current before anything queries it; a failed migration stops the
+	// server rather than letting it run against a half-migrated database
+	if cfg.AutoMigrate {
+		if err := migrations.Up(cfg.PostgresURL, log); err != nil {
+			log.Fatal("auto-migrate", zap.Error(err))
+		}
+	}
 
 	// Create default admin user
 	db, err := store.NewDB(context.Background(), cfg.PostgresURL, int32(cfg.MaxDBConnections))
@@ -43,9 +77,12 @@ func main() {
 	gin.SetMode(gin.ReleaseMode)
 	r := gin.New()
 	r.Use(gin.Recovery())
+	r.Use(middleware.TracingMiddleware())
 	r.Use(middleware.RequestLogger(log))
 
-	api.RegisterRoutes(r, log)
+	// Background loops run until the server has shut down
+	loopsCtx, stopLoops := context.WithCancel(context.Background())
+	checker, waitLoops := api.RegisterRoutes(loopsCtx, r, log)
 
 	// metrics endpoint
 	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
@@ -58,6 +95,10 @@ func main() {
 		MaxHeaderBytes: 1 << 20,
 	}
 
+	// /readyz fails until Postgres, Redis and Kafka have all answered
+	readyCtx, stopWaiting := context.WithCancel(context.Background())
+	go checker.WaitReady(readyCtx, log, time.Second)
+
 	go func() {
 		log.Info("server starting", zap.Int("port", cfg.HTTPPort))
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -68,11 +109,28 @@ func main() {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
+	stopWaiting()
+	checker.Drain()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Error("server shutdown error", zap.Error(err))
 	}
+	// No request can start work for the loops now; give the ones mid-pass time to finish
+	stopLoops()
+	stopped := make(chan struct{})
+	go func() {
+		waitLoops()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		log.Warn("background loops still running at shutdown")
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		log.Error("tracing shutdown error", zap.Error(err))
+	}
 	log.Info("server exited")
 }
@@ -2,61 +2,189 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
 	"os/signal"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 
 	"github.com/samirwankhede/lewly-pgpyewj/internal/config"
 	kafkax "github.com/samirwankhede/lewly-pgpyewj/internal/kafka"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/lock"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/logger"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/mailer"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/payments"
 	redisx "github.com/samirwankhede/lewly-pgpyewj/internal/redis"
+	bookingsService "github.com/samirwankhede/lewly-pgpyewj/internal/service/bookings"
+	fxService "github.com/samirwankhede/lewly-pgpyewj/internal/service/fx"
 	mailerService "github.com/samirwankhede/lewly-pgpyewj/internal/service/mailer"
+	paymentService "github.com/samirwankhede/lewly-pgpyewj/internal/service/payment"
+	paymentLinksService "github.com/samirwankhede/lewly-pgpyewj/internal/service/paymentlinks"
+	waitlistService "github.com/samirwankhede/lewly-pgpyewj/internal/service/waitlist"
+	webhooksService "github.com/samirwankhede/lewly-pgpyewj/internal/service/webhooks"
 	workerService "github.com/samirwankhede/lewly-pgpyewj/internal/service/worker"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/store"
 	storeBookings "github.com/samirwankhede/lewly-pgpyewj/internal/store/bookings"
+	storeBranding "github.com/samirwankhede/lewly-pgpyewj/internal/store/branding"
 	storeEvents "github.com/samirwankhede/lewly-pgpyewj/internal/store/events"
+	storeFX "github.com/samirwankhede/lewly-pgpyewj/internal/store/fx"
+	storeJournal "github.com/samirwankhede/lewly-pgpyewj/internal/store/journal"
+	storeLedger "github.com/samirwankhede/lewly-pgpyewj/internal/store/ledger"
+	storeNotifications "github.com/samirwankhede/lewly-pgpyewj/internal/store/notifications"
+	storeOutbox "github.com/samirwankhede/lewly-pgpyewj/internal/store/outbox"
+	storePaymentLinks "github.com/samirwankhede/lewly-pgpyewj/internal/store/paymentlinks"
+	storePayments "github.com/samirwankhede/lewly-pgpyewj/internal/store/payments"
 	storeUsers "github.com/samirwankhede/lewly-pgpyewj/internal/store/users"
 	storeWaitlist "github.com/samirwankhede/lewly-pgpyewj/internal/store/waitlist"
+	storeWebhooks "github.com/samirwankhede/lewly-pgpyewj/internal/store/webhooks"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/tracing"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/worker"
 )
 
 func main() {
 	_ = godotenv.Load()
 	cfg := config.Load()
-	log := logger.New(cfg.Env)
+	base, err := logger.NewWithOptions(logger.Options{
+		Env:        cfg.Env,
+		Level:      cfg.LogLevel,
+		Components: cfg.LogLevels,
+		File:       cfg.LogFile,
+		MaxSizeMB:  cfg.LogFileMaxMB,
+		MaxBackups: cfg.LogFileMaxBackups,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "logger:", err)
+		os.Exit(1)
+	}
+	log := logger.Component(base, "worker")
+	defer log.Sync()
 	log.Info("worker starting")
 
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
+	// Background loops run until ctx is done, and the worker waits for them before closing
+	// the producer and database they use
+	var loops sync.WaitGroup
+	goLoop := func(run func(ctx context.Context)) {
+		loops.Add(1)
+		go func() {
+			defer loops.Done()
+			run(ctx)
+		}()
+	}
+
+	shutdownTracing, err := tracing.Setup(ctx, log, tracing.Options{
+		ServiceName:   "evently-worker",
+		Endpoint:      cfg.OTLPEndpoint,
+		Headers:       tracing.ParseHeaders(cfg.OTLPHeaders),
+		SamplePercent: cfg.TraceSamplePercent,
+		Environment:   cfg.Env,
+	})
+	if err != nil {
+		log.Fatal("tracing setup", zap.Error(err))
+	}
+	// Flush spans still batched when the worker stops
+	defer func() {
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(flushCtx); err != nil {
+			log.Error("tracing shutdown error", zap.Error(err))
+		}
+	}()
 
 	bookingTimeoutStore := redisx.NewTimeoutBucket(cfg.RedisAddr)
-	db, err := store.NewDB(ctx, cfg.PostgresURL, int32(cfg.MaxDBConnections))
+	// Status transitions are pushed to clients streaming /v1/bookings/:id/events
+	bookingEvents := redisx.NewBookingEvents(cfg.RedisAddr)
+	defer bookingEvents.Close()
+	// Log levels changed through the API's /admin/log-levels apply here too
+	goLoop(func(ctx context.Context) { logger.ListenLevels(ctx, log, bookingTimeoutStore.GetClient()) })
+	// Repositories log as the "store" component, apart from the worker itself
+	storeLog := logger.Component(log, "store")
+	db, err := store.NewDB(ctx, cfg.PostgresURL, int32(cfg.MaxDBConnections), store.WithSlowQueryLog(storeLog, cfg.SlowQueryThreshold))
 	if err != nil {
 		log.Fatal("db connect", zap.Error(err))
 	}
 	defer db.Close()
 
 	// Create repositories
-	bookingsRepo := storeBookings.NewBookingsRepository(db, log)
-	eventsRepo := storeEvents.NewEventsRepository(db, log)
-	waitlistRepo := storeWaitlist.NewWaitlistRepository(db, log)
-	usersRepository := storeUsers.NewUsersRepository(db, log)
+	bookingsRepo := storeBookings.NewBookingsRepository(db, storeLog)
+	eventsRepo := storeEvents.NewEventsRepository(db, storeLog)
+	waitlistRepo := storeWaitlist.NewWaitlistRepository(db, storeLog)
+	usersRepository := storeUsers.NewUsersRepository(db, storeLog)
+
+	// Transitions the worker announces (payment requested, expired, promoted) are queued for
+	// users' webhooks and integration endpoints too. Deliveries queued here and by the API are
+	// sent from the workers, each claiming its own
+	webhooksSvc := webhooksService.NewWebhooksService(log, storeWebhooks.NewWebhooksRepository(db, storeLog), cfg.UserWebhookAllowLocal)
+	goLoop(func(ctx context.Context) { webhooksSvc.Run(ctx, cfg.UserWebhookInterval) })
+	bookingEvents.OnPublish(webhooksSvc.Enqueue)
+	// and kept for the bookings' traces
+	bookingEvents.OnPublish(bookingsService.EventRecorder(log, bookingsRepo))
 
 	// Create mailer service
-	mailerSender := &mailer.SMTPSender{
+	// Emails are signed with the platform's or their organizer's branding as they're sent
+	mailerSender := mailerService.NewBrandedSender(&mailer.SMTPSender{
 		Host: cfg.SMTPHost,
 		Port: cfg.SMTPPort,
 		User: cfg.SMTPUser,
 		Pass: cfg.SMTPPass,
 		From: cfg.SMTPFrom,
+	}, mailerService.NewBrands(log, storeBranding.NewBrandingRepository(db, storeLog)))
+	// Single emails, the API's included, are queued and sent from here with retries
+	mailQueue := mailerService.NewMailQueue(log, storeNotifications.NewNotificationsRepository(db, storeLog), mailerSender, cfg.MailMaxAttempts, cfg.MailRetryBase, cfg.MailRetryMax)
+	goLoop(func(ctx context.Context) { mailQueue.Run(ctx, cfg.MailQueuePollInterval) })
+	mailerSvc := mailerService.NewMailerService(log, mailerSender).WithQueue(mailQueue)
+
+	// Emailed payment links are shortened to /p/:code on the API
+	linksSvc := paymentLinksService.NewPaymentLinksService(log, storePaymentLinks.NewPaymentLinksRepository(db, storeLog), cfg.PaymentURL)
+
+	// Promoted waitlist bookings are finalized through the same topic
+	codec, err := kafkax.CodecFor(cfg.KafkaCodec)
+	if err != nil {
+		log.Warn("unknown kafka codec, falling back to json", zap.Error(err))
+		codec = kafkax.JSONCodec{}
 	}
-	mailerSvc := mailerService.NewMailerService(log, mailerSender)
+	producer := kafkax.NewProducer([]string{cfg.KafkaBrokers}, "bookings").WithCodec(codec)
+	defer producer.Close()
+	promoter := waitlistService.NewPromoter(log, waitlistRepo, eventsRepo, usersRepository, producer, mailerSvc, bookingEvents)
 
 	// Create finalize service
-	finalizeSvc := workerService.NewFinalizeService(log, bookingsRepo, eventsRepo, usersRepository, waitlistRepo, cfg.PaymentURL, mailerSvc, bookingTimeoutStore)
+	fxRates := fxService.NewRates(log, storeFX.NewFXRepository(db, storeLog))
+	// Timed-out bookings have any card authorization voided with the API's provider
+	paymentSvc := paymentService.NewPaymentService(log, bookingsRepo, eventsRepo, nil, bookingEvents, nil,
+		storePayments.NewPaymentsRepository(db, storeLog), payments.NewProvider(log, cfg.StripeSecretKey, cfg.StripeAPIURL))
+	// Payment links wait while the payment service fails its health probes
+	paymentHealth := paymentService.NewHealth(log, cfg.PaymentHealthURL, cfg.PaymentHealthLatency)
+	finalizeSvc := workerService.NewFinalizeService(log, bookingsRepo, eventsRepo, usersRepository, promoter, cfg.PaymentURL, mailerSvc, bookingTimeoutStore, linksSvc, bookingEvents).
+		WithRates(fxRates).
+		WithPayments(paymentSvc).
+		WithHealth(paymentHealth, cfg.PaymentDeferMax).
+		WithTimeouts(producer).
+		WithEventLocks(lock.New(db, cfg.EventLockWait, cfg.EventLockHold))
+	goLoop(func(ctx context.Context) { paymentHealth.Run(ctx, cfg.PaymentHealthInterval) })
+	goLoop(func(ctx context.Context) { finalizeSvc.RunDeferred(ctx, cfg.PaymentHealthInterval) })
+	// Payment timeouts are scheduled in Redis and fired by every worker's poller
+	goLoop(func(ctx context.Context) { finalizeSvc.RunTimeouts(ctx, cfg.TimeoutPollInterval) })
+	// Pending-booking ages and conversion per payment provider; a provider leaving bookings
+	// stuck at payment alerts the admin
+	conversionMonitor := paymentService.NewConversionMonitor(log, bookingsRepo, mailerSvc, cfg.AdminEmail,
+		float64(cfg.ConversionAlertPercent), cfg.ConversionAlertMin)
+	goLoop(func(ctx context.Context) { conversionMonitor.Run(ctx, cfg.PaymentMetricsInterval) })
+	// Booked bookings whose stored seats don't match the seats table are flagged to the admin
+	seatIntegrity := paymentService.NewSeatIntegrity(log, bookingsRepo, mailerSvc, cfg.AdminEmail)
+	goLoop(func(ctx context.Context) { seatIntegrity.Run(ctx, cfg.SeatIntegrityInterval) })
+	// New bookings' finalize messages are written to the outbox with the booking; the relay
+	// publishes them, and sets aside those that keep failing
+	outboxRelay := workerService.NewOutboxRelay(log, storeOutbox.NewOutboxRepository(db, storeLog), producer).
+		WithMaxAttempts(cfg.OutboxMaxAttempts)
+	goLoop(func(ctx context.Context) { outboxRelay.Run(ctx, cfg.OutboxPollInterval) })
 
 	// Create Kafka consumer and producer
 	consumer := kafkax.NewConsumer([]string{cfg.KafkaBrokers}, "evently-finalizer", "bookings")
@@ -64,10 +192,32 @@ func main() {
 	dlq := kafkax.NewProducer([]string{cfg.KafkaBrokers}, "bookings-dlq")
 	defer dlq.Close()
 
+	// Prometheus scrapes the worker separately from the API
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	metricsSrv := &http.Server{Addr: fmt.Sprintf(":%d", cfg.WorkerMetricsPort), Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := metricsSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("metrics server", zap.Error(err))
+		}
+	}()
+	defer metricsSrv.Close()
+
 	// Create and run finalizer
-	f := worker.NewFinalizer(log, finalizeSvc, consumer, dlq, cfg.MaxWorkerRoutineCount)
+	// Failed messages are retried in place before they're dead-lettered, messages handled but
+	// not committed before a crash aren't handled again, and how each message was handled is
+	// journaled for its booking's trace. On shutdown, messages being handled are given time to
+	// finish and be committed
+	f := worker.NewFinalizer(log, finalizeSvc, consumer, dlq, cfg.MaxWorkerRoutineCount).
+		WithRetries(cfg.WorkerMaxAttempts, cfg.WorkerRetryBase, cfg.WorkerRetryMax).
+		WithDrain(cfg.WorkerDrainTimeout).
+		WithLedger(storeLedger.NewLedgerRepository(db, storeLog)).
+		WithJournal(storeJournal.NewJournalRepository(db, storeLog))
+	goLoop(func(ctx context.Context) { f.RunDLQDepthGauge(ctx, cfg.DLQDepthInterval) })
+	goLoop(f.RunLedgerPurge)
+	// Returns once drained, before the consumer and database are closed
 	_ = f.Run(ctx)
-
-	<-ctx.Done()
+	cancel()
+	loops.Wait()
 	log.Info("worker stopped")
 }
@@ -1,12 +1,28 @@
 package admin
 
 import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/api/response"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/lock"
 	jwtMiddleware "github.com/samirwankhede/lewly-pgpyewj/internal/middleware"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/service/admin"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/service/fx"
+	mailer "github.com/samirwankhede/lewly-pgpyewj/internal/service/mailer"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/service/organizers"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/service/simulation"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store"
+	storeAdmin "github.com/samirwankhede/lewly-pgpyewj/internal/store/admin"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/notifications"
 )
 
 type AdminHandler struct {
@@ -25,9 +41,45 @@ func (h *AdminHandler) Register(r *gin.Engine) {
 		g.POST("/events", h.createEvent)
 		g.PUT("/events/:id", h.updateEvent)
 		g.POST("/events/:id/cancel", h.cancelEvent)
+		g.GET("/events/:id/snapshots", h.snapshots)
+		g.GET("/events/:id/bookings/export", h.exportBookings)
+		g.POST("/events/:id/simulate", h.simulate)
+		g.POST("/events/:id/clone", h.cloneEvent)
+		g.POST("/events/:id/tokens/resync", h.resyncTokens)
+		g.POST("/events/:id/merge", h.mergeEvent)
+		g.POST("/events/:id/capacity", h.increaseCapacity)
+		g.GET("/events/:id/capacity", h.capacityIncreases)
+		g.GET("/events/:id/sales-curve", h.salesCurve)
+		g.PUT("/events/:id/sale-phases", h.setSalePhases)
+		g.GET("/events/:id/sale-phases", h.salePhases)
+		g.POST("/events/:id/invitees", h.importInvitees)
+		g.GET("/events/:id/invitees", h.listInvitees)
+		g.GET("/events/:id/sandbox/testers", h.sandboxTesters)
+		g.POST("/events/:id/sandbox/testers", h.addSandboxTester)
+		g.DELETE("/events/:id/sandbox/testers/:userId", h.removeSandboxTester)
+		g.PUT("/events/:id/prewarm", h.schedulePrewarm)
+		g.GET("/events/:id/prewarm", h.prewarm)
+		g.DELETE("/events/:id/prewarm", h.cancelPrewarm)
+		g.GET("/prewarms", h.prewarms)
 		g.GET("/analytics", h.summary)
+		g.GET("/analytics/compare", h.compare)
+		g.GET("/mail/templates", h.mailTemplates)
+		g.POST("/mail/test-send", h.testSendMail)
+		g.GET("/mail/queue", h.mailQueue)
+		g.POST("/mail/queue/:id/requeue", h.requeueMail)
+		g.GET("/branding", h.branding)
+		g.PUT("/branding", h.setBranding)
+		g.GET("/organizers/:id/branding", h.branding)
+		g.PUT("/organizers/:id/branding", h.setBranding)
+		g.DELETE("/organizers/:id/branding", h.deleteBranding)
+		g.GET("/notifications", h.notificationBatches)
+		g.GET("/notifications/:id", h.notificationBatch)
+		g.GET("/jobs", h.jobs)
+		g.GET("/jobs/:id", h.job)
 		g.POST("/users/:id/admin", h.createAdmin)
 		g.DELETE("/users/:id/admin", h.removeAdmin)
+		g.POST("/users/:id/organizer", h.grantOrganizer)
+		g.DELETE("/users/:id/organizer", h.revokeOrganizer)
 		g.DELETE("/users/:id", h.removeUser)
 		g.GET("/users/get-user", h.getUserByEmail)
 	}
@@ -36,15 +88,127 @@ func (h *AdminHandler) Register(r *gin.Engine) {
 func (h *AdminHandler) createEvent(c *gin.Context) {
 	var in admin.AdminEvent
 	if err := c.ShouldBindJSON(&in); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 	e, err := h.svc.CreateEvent(c, in)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if errors.Is(err, admin.ErrInvalidSeats) || err == fx.ErrInvalidCurrency || err == admin.ErrInvalidVisibility || err == admin.ErrInvalidPaymentCapture || err == admin.ErrSandboxPublic {
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err == admin.ErrDuplicateEvent && e != nil {
+			response.JSON(c, http.StatusConflict, gin.H{"error": err.Error(), "existing_event_id": e.ID, "hint": "set allow_duplicate to create it anyway"})
+			return
+		}
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	response.JSON(c, http.StatusCreated, e)
+}
+
+func (h *AdminHandler) cloneEvent(c *gin.Context) {
+	var in admin.CloneEventRequest
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	e, err := h.svc.CloneEvent(c.Request.Context(), c.Param("id"), in)
+	if err != nil {
+		if err == admin.ErrEventNotFound {
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Event not found"})
+			return
+		}
+		if err == organizers.ErrOrganizerNotFound {
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Organizer not found"})
+			return
+		}
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	response.JSON(c, http.StatusCreated, e)
+}
+
+func (h *AdminHandler) resyncTokens(c *gin.Context) {
+	res, err := h.svc.ResyncTokens(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if err == admin.ErrEventNotFound {
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Event not found"})
+			return
+		}
+		if errors.Is(err, lock.ErrTimeout) {
+			response.JSON(c, http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	response.JSON(c, http.StatusOK, res)
+}
+
+// mergeEvent folds the event in the path, an accidental duplicate, into the "into" event.
+func (h *AdminHandler) mergeEvent(c *gin.Context) {
+	var in struct {
+		Into string `json:"into" binding:"required,uuid"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	res, err := h.svc.MergeEvents(c.Request.Context(), c.Param("id"), in.Into)
+	if err != nil {
+		switch err {
+		case admin.ErrEventNotFound:
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Event not found"})
+		case admin.ErrMergeSameEvent:
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		case admin.ErrMergeEventClosed:
+			response.JSON(c, http.StatusConflict, gin.H{"error": err.Error()})
+		case admin.ErrMergePending, admin.ErrMergeSeatConflict:
+			response.JSON(c, http.StatusConflict, gin.H{"error": err.Error(), "pending_bookings": res.PendingBookings, "conflicting_seats": res.ConflictingSeats})
+		default:
+			response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	response.JSON(c, http.StatusOK, res)
+}
+
+// increaseCapacity adds seats to an event mid-sale.
+func (h *AdminHandler) increaseCapacity(c *gin.Context) {
+	var in admin.CapacityRequest
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	res, err := h.svc.IncreaseCapacity(c.Request.Context(), c.Param("id"), in, c.GetString("uid"))
+	if err != nil {
+		switch {
+		case err == admin.ErrEventNotFound:
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Event not found"})
+		case errors.Is(err, admin.ErrInvalidSeats):
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		case err == admin.ErrCapacityEventClosed:
+			response.JSON(c, http.StatusConflict, gin.H{"error": err.Error()})
+		case err == admin.ErrSeatsExist:
+			response.JSON(c, http.StatusConflict, gin.H{"error": err.Error(), "existing_seats": res.ExistingSeats})
+		case errors.Is(err, lock.ErrTimeout):
+			response.JSON(c, http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		default:
+			response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	response.JSON(c, http.StatusOK, res)
+}
+
+func (h *AdminHandler) capacityIncreases(c *gin.Context) {
+	list, err := h.svc.CapacityIncreases(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusCreated, e)
+	response.JSON(c, http.StatusOK, gin.H{"increases": list})
 }
 
 func (h *AdminHandler) summary(c *gin.Context) {
@@ -57,7 +221,7 @@ func (h *AdminHandler) summary(c *gin.Context) {
 	} else {
 		from, err = time.Parse(time.RFC3339, fromStr)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "bad from"})
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": "bad from"})
 			return
 		}
 	}
@@ -66,72 +230,611 @@ func (h *AdminHandler) summary(c *gin.Context) {
 	} else {
 		to, err = time.Parse(time.RFC3339, toStr)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "bad to"})
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": "bad to"})
 			return
 		}
 	}
 	a, err := h.svc.GetSummary(c.Request.Context(), from, to)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	response.JSON(c, http.StatusOK, a)
+}
+
+func (h *AdminHandler) compare(c *gin.Context) {
+	ids := strings.Split(c.Query("event_ids"), ",")
+	for _, id := range ids {
+		if _, err := uuid.Parse(strings.TrimSpace(id)); err != nil {
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": "event_ids must be comma-separated event ids"})
+			return
+		}
+	}
+	res, err := h.svc.CompareEvents(c.Request.Context(), ids)
+	if err != nil {
+		if err == admin.ErrInvalidComparison {
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"events": res})
+}
+
+// exportBookings writes the event's bookings as CSV, one row per booking. The header row is
+// written with the first booking, so a missing event still gets a JSON 404; after that an
+// error can only cut the download short.
+func (h *AdminHandler) exportBookings(c *gin.Context) {
+	eventID := c.Param("id")
+	w := csv.NewWriter(c.Writer)
+	started := false
+	begin := func() error {
+		started = true
+		c.Header("Content-Type", "text/csv")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="bookings-%s.csv"`, eventID))
+		c.Status(http.StatusOK)
+		return w.Write(exportHeader)
+	}
+	err := h.svc.ExportBookings(c.Request.Context(), eventID, func(b *storeAdmin.ExportedBooking) error {
+		if !started {
+			if err := begin(); err != nil {
+				return err
+			}
+		}
+		displayAmount := ""
+		if b.DisplayAmount != nil {
+			displayAmount = strconv.FormatFloat(*b.DisplayAmount, 'f', 2, 64)
+		}
+		return w.Write([]string{
+			b.ID, b.UserID, b.UserEmail, b.Status, strings.Join(b.Seats, " "),
+			strconv.FormatFloat(b.AmountPaid, 'f', 2, 64), b.Currency, b.DisplayCurrency, displayAmount,
+			b.PaymentStatus, b.Source,
+			b.CreatedAt.UTC().Format(time.RFC3339),
+		})
+	})
+	if err != nil && !started {
+		if errors.Is(err, admin.ErrEventNotFound) {
+			response.JSON(c, http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !started {
+		_ = begin()
+	}
+	w.Flush()
+}
+
+var exportHeader = []string{"booking_id", "user_id", "email", "status", "seats", "amount_paid", "currency", "display_currency", "display_amount", "payment_status", "source", "created_at"}
+
+func (h *AdminHandler) snapshots(c *gin.Context) {
+	eventID := c.Param("id")
+	fromStr := c.Query("from")
+	toStr := c.Query("to")
+	var from, to time.Time
+	var err error
+	if fromStr == "" {
+		from = time.Now().Add(-7 * 24 * time.Hour)
+	} else {
+		from, err = time.Parse(time.RFC3339, fromStr)
+		if err != nil {
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": "bad from"})
+			return
+		}
+	}
+	if toStr == "" {
+		to = time.Now()
+	} else {
+		to, err = time.Parse(time.RFC3339, toStr)
+		if err != nil {
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": "bad to"})
+			return
+		}
+	}
+	snaps, err := h.svc.ListInventorySnapshots(c.Request.Context(), eventID, from, to)
+	if err != nil {
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"event_id": eventID, "snapshots": snaps})
+}
+
+func (h *AdminHandler) salesCurve(c *gin.Context) {
+	curve, err := h.svc.SalesCurve(c.Request.Context(), c.Param("id"), c.DefaultQuery("interval", "day"), c.DefaultQuery("tz", "UTC"))
+	if err != nil {
+		if errors.Is(err, admin.ErrInvalidSalesCurve) {
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err == admin.ErrEventNotFound {
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Event not found"})
+			return
+		}
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	response.JSON(c, http.StatusOK, curve)
+}
+
+func (h *AdminHandler) simulate(c *gin.Context) {
+	eventID := c.Param("id")
+	var params simulation.Params
+	if err := c.ShouldBindJSON(&params); err != nil {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	res, err := h.svc.SimulateOnSale(c.Request.Context(), eventID, params)
+	if err != nil {
+		if err == simulation.ErrInvalidParams {
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": "arrival_rate must be positive and payment_conversion between 0 and 1"})
+			return
+		}
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, a)
+	response.JSON(c, http.StatusOK, res)
 }
 
 func (h *AdminHandler) updateEvent(c *gin.Context) {
 	eventID := c.Param("id")
 	var updates map[string]interface{}
 	if err := c.ShouldBindJSON(&updates); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	err := h.svc.UpdateEvent(c.Request.Context(), eventID, updates)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		var colErr *store.ColumnError
+		if err == admin.ErrInvalidVisibility || err == admin.ErrInvalidPaymentCapture || err == admin.ErrSandboxPublic || err == store.ErrNoUpdates || errors.As(err, &colErr) {
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Event updated successfully"})
+	response.JSON(c, http.StatusOK, gin.H{"message": "Event updated successfully"})
 }
 
 func (h *AdminHandler) cancelEvent(c *gin.Context) {
 	eventID := c.Param("id")
-	err := h.svc.CancelEvent(c.Request.Context(), eventID)
+	job, err := h.svc.CancelEvent(c.Request.Context(), eventID)
+	if err != nil {
+		if err == admin.ErrEventNotFound {
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Event not found"})
+			return
+		}
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	// Cancellation runs in the background; refunds are a separate job from the payments endpoint
+	response.JSON(c, http.StatusAccepted, job)
+}
+
+// jobs lists background admin jobs, optionally filtered by ?kind= and ?state=.
+func (h *AdminHandler) jobs(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	list, err := h.svc.Jobs(c.Request.Context(), c.Query("kind"), c.Query("state"), limit, offset)
+	if err != nil {
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	response.Page(c, "jobs", list, limit, offset)
+}
+
+func (h *AdminHandler) job(c *gin.Context) {
+	if _, err := uuid.Parse(c.Param("id")); err != nil {
+		response.JSON(c, http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	job, err := h.svc.Job(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if job == nil {
+		response.JSON(c, http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	response.JSON(c, http.StatusOK, job)
+}
+
+// notificationBatches lists broadcast emails and how far each got.
+func (h *AdminHandler) notificationBatches(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	batches, err := h.svc.NotificationBatches(c.Request.Context(), limit, offset)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Event cancelled successfully, Please Process refund through payments endpoint"})
+	response.Page(c, "batches", batches, limit, offset)
+}
+
+func (h *AdminHandler) notificationBatch(c *gin.Context) {
+	if _, err := uuid.Parse(c.Param("id")); err != nil {
+		response.JSON(c, http.StatusNotFound, gin.H{"error": "Notification batch not found"})
+		return
+	}
+	batch, err := h.svc.NotificationBatch(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if batch == nil {
+		response.JSON(c, http.StatusNotFound, gin.H{"error": "Notification batch not found"})
+		return
+	}
+	response.JSON(c, http.StatusOK, batch)
+}
+
+// mailTemplates previews every email template, or the one named by ?name=, rendered with
+// sample data.
+func (h *AdminHandler) mailTemplates(c *gin.Context) {
+	if name := c.Query("name"); name != "" {
+		t, err := h.svc.MailTemplate(name)
+		if err != nil {
+			response.JSON(c, http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		response.JSON(c, http.StatusOK, t)
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"templates": h.svc.MailTemplates()})
+}
+
+func (h *AdminHandler) testSendMail(c *gin.Context) {
+	var req struct {
+		Template string `json:"template" binding:"required"`
+		To       string `json:"to" binding:"omitempty,email"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	to, err := h.svc.SendTestMail(c.Request.Context(), c.GetString("uid"), req.Template, req.To)
+	if err != nil {
+		switch {
+		case errors.Is(err, mailer.ErrUnknownTemplate):
+			response.JSON(c, http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, admin.ErrTestMailRecipient):
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"message": "Test email sent", "template": req.Template, "to": to})
+}
+
+// mailQueue lists queued single emails, e.g. ?status=dead for the ones that gave up.
+func (h *AdminHandler) mailQueue(c *gin.Context) {
+	status := c.Query("status")
+	switch status {
+	case "", notifications.MailPending, notifications.MailSending, notifications.MailSent, notifications.MailDead:
+	default:
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": "status must be pending, sending, sent or dead"})
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	list, counts, err := h.svc.QueuedMail(c.Request.Context(), status, limit, offset)
+	if err != nil {
+		if err == admin.ErrNoMailQueue {
+			response.JSON(c, http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"mail": list, "counts": counts, "limit": limit, "offset": offset})
+}
+
+func (h *AdminHandler) requeueMail(c *gin.Context) {
+	if _, err := uuid.Parse(c.Param("id")); err != nil {
+		response.JSON(c, http.StatusNotFound, gin.H{"error": "Queued email not found"})
+		return
+	}
+	m, err := h.svc.RequeueMail(c.Request.Context(), c.GetString("uid"), c.Param("id"))
+	if err != nil {
+		switch err {
+		case admin.ErrNoMailQueue, mailer.ErrMailNotFound:
+			response.JSON(c, http.StatusNotFound, gin.H{"error": err.Error()})
+		case mailer.ErrMailNotDead:
+			response.JSON(c, http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	response.JSON(c, http.StatusOK, m)
+}
+
+// maxInviteeUpload caps the size of an invitee CSV.
+const maxInviteeUpload = 5 << 20
+
+// importInvitees takes the invitee CSV either as a multipart "file" field or as the raw
+// request body.
+func (h *AdminHandler) importInvitees(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxInviteeUpload)
+	body := io.Reader(c.Request.Body)
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		fh, err := c.FormFile("file")
+		if err != nil {
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": "file is required"})
+			return
+		}
+		f, err := fh.Open()
+		if err != nil {
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		defer f.Close()
+		body = f
+	}
+
+	job, err := h.svc.ImportInvitees(c.Request.Context(), c.Param("id"), body)
+	if err != nil {
+		switch {
+		case err == admin.ErrEventNotFound:
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Event not found"})
+		case err == admin.ErrEventNotPrivate:
+			response.JSON(c, http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, admin.ErrInvalidInvitees):
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	response.JSON(c, http.StatusAccepted, job)
+}
+
+func (h *AdminHandler) listInvitees(c *gin.Context) {
+	res, err := h.svc.ListInvitees(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		switch err {
+		case admin.ErrEventNotFound:
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Event not found"})
+		case admin.ErrEventNotPrivate:
+			response.JSON(c, http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	response.JSON(c, http.StatusOK, res)
+}
+
+func (h *AdminHandler) sandboxTesters(c *gin.Context) {
+	testers, err := h.svc.SandboxTesters(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		sandboxError(c, err)
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"event_id": c.Param("id"), "testers": testers})
+}
+
+func (h *AdminHandler) addSandboxTester(c *gin.Context) {
+	var in struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	tester, err := h.svc.AddSandboxTester(c.Request.Context(), c.Param("id"), in.Email)
+	if err != nil {
+		sandboxError(c, err)
+		return
+	}
+	response.JSON(c, http.StatusCreated, tester)
+}
+
+func (h *AdminHandler) removeSandboxTester(c *gin.Context) {
+	if err := h.svc.RemoveSandboxTester(c.Request.Context(), c.Param("id"), c.Param("userId")); err != nil {
+		sandboxError(c, err)
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"message": "Sandbox tester removed"})
+}
+
+func sandboxError(c *gin.Context, err error) {
+	switch err {
+	case admin.ErrEventNotFound:
+		response.JSON(c, http.StatusNotFound, gin.H{"error": "Event not found"})
+	case admin.ErrUserNotFound:
+		response.JSON(c, http.StatusNotFound, gin.H{"error": err.Error()})
+	case admin.ErrEventNotSandbox:
+		response.JSON(c, http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// setSalePhases replaces the phases the event is sold in.
+func (h *AdminHandler) setSalePhases(c *gin.Context) {
+	var in struct {
+		Phases []admin.SalePhaseInput `json:"phases" binding:"dive"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	phases, err := h.svc.SetSalePhases(c.Request.Context(), c.Param("id"), in.Phases)
+	if err != nil {
+		salePhaseError(c, err)
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"phases": phases})
+}
+
+func (h *AdminHandler) salePhases(c *gin.Context) {
+	phases, err := h.svc.SalePhases(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		salePhaseError(c, err)
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"phases": phases})
+}
+
+func salePhaseError(c *gin.Context, err error) {
+	switch {
+	case err == admin.ErrEventNotFound:
+		response.JSON(c, http.StatusNotFound, gin.H{"error": "Event not found"})
+	case errors.Is(err, admin.ErrInvalidSalePhases):
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err == admin.ErrSalePhaseInUse, err == admin.ErrEventOver:
+		response.JSON(c, http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// schedulePrewarm sets when the event goes on sale, for the status checker to warm it up
+// beforehand.
+func (h *AdminHandler) schedulePrewarm(c *gin.Context) {
+	var in struct {
+		SaleStartTime time.Time `json:"sale_start_time" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	p, err := h.svc.SchedulePrewarm(c.Request.Context(), c.Param("id"), in.SaleStartTime)
+	if err != nil {
+		prewarmError(c, err)
+		return
+	}
+	response.JSON(c, http.StatusOK, p)
+}
+
+func (h *AdminHandler) prewarm(c *gin.Context) {
+	p, err := h.svc.Prewarm(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		prewarmError(c, err)
+		return
+	}
+	response.JSON(c, http.StatusOK, p)
+}
+
+func (h *AdminHandler) cancelPrewarm(c *gin.Context) {
+	if err := h.svc.CancelPrewarm(c.Request.Context(), c.Param("id")); err != nil {
+		prewarmError(c, err)
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"message": "On-sale unscheduled"})
+}
+
+// prewarms is the on-sale readiness board: scheduled on-sales from since (default the last
+// 24 hours) on, soonest first, with their readiness reports.
+func (h *AdminHandler) prewarms(c *gin.Context) {
+	since := time.Now().Add(-24 * time.Hour)
+	if s := c.Query("since"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": "bad since"})
+			return
+		}
+		since = t
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	list, err := h.svc.Prewarms(c.Request.Context(), since, limit, offset)
+	if err != nil {
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	response.Page(c, "prewarms", list, limit, offset)
+}
+
+func prewarmError(c *gin.Context, err error) {
+	switch err {
+	case admin.ErrEventNotFound:
+		response.JSON(c, http.StatusNotFound, gin.H{"error": "Event not found"})
+	case admin.ErrPrewarmNotFound:
+		response.JSON(c, http.StatusNotFound, gin.H{"error": err.Error()})
+	case admin.ErrSaleStartPassed, admin.ErrSaleStartAfterShow:
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+	case admin.ErrEventOver:
+		response.JSON(c, http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
 }
 
 func (h *AdminHandler) createAdmin(c *gin.Context) {
 	userID := c.Param("id")
 	err := h.svc.CreateAdminFromUser(c.Request.Context(), userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "User promoted to admin successfully"})
+	response.JSON(c, http.StatusOK, gin.H{"message": "User promoted to admin successfully"})
 }
 
 func (h *AdminHandler) removeAdmin(c *gin.Context) {
 	userID := c.Param("id")
 	err := h.svc.RemoveAdmin(c.Request.Context(), userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"message": "Admin privileges removed successfully"})
+}
+
+// grantOrganizer makes the user an organizer, optionally publishing under an organizer
+// profile.
+func (h *AdminHandler) grantOrganizer(c *gin.Context) {
+	var req struct {
+		OrganizerID *string `json:"organizer_id"`
+	}
+	// The body is optional
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	err := h.svc.GrantOrganizer(c.Request.Context(), c.Param("id"), req.OrganizerID)
+	if err != nil {
+		switch err {
+		case organizers.ErrOrganizerNotFound:
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Organizer not found"})
+		case admin.ErrCannotGrantOrganizer:
+			response.JSON(c, http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"message": "User promoted to organizer successfully"})
+}
+
+func (h *AdminHandler) revokeOrganizer(c *gin.Context) {
+	err := h.svc.RevokeOrganizer(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if err == admin.ErrNotOrganizer {
+			response.JSON(c, http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Admin privileges removed successfully"})
+	response.JSON(c, http.StatusOK, gin.H{"message": "Organizer privileges removed successfully"})
 }
 
 func (h *AdminHandler) removeUser(c *gin.Context) {
 	userID := c.Param("id")
 	err := h.svc.RemoveUser(c.Request.Context(), userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "User removed successfully"})
+	response.JSON(c, http.StatusOK, gin.H{"message": "User removed successfully"})
 }
 
 func (h *AdminHandler) getUserByEmail(c *gin.Context) {
@@ -140,13 +843,13 @@ func (h *AdminHandler) getUserByEmail(c *gin.Context) {
 	}
 	var email Email
 	if err := c.ShouldBindJSON(&email); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 	user, err := h.svc.GetUserByEmail(c.Request.Context(), email.Email)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, user)
+	response.JSON(c, http.StatusOK, user)
 }
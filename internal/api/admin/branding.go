@@ -0,0 +1,89 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/api/response"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/service/admin"
+	mailer "github.com/samirwankhede/lewly-pgpyewj/internal/service/mailer"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/service/organizers"
+)
+
+// brandingOrganizer returns the organizer in the path, nil for the platform's routes. It
+// reports false, having responded, for an ID that can't be an organizer's.
+func brandingOrganizer(c *gin.Context) (*string, bool) {
+	id := c.Param("id")
+	if id == "" {
+		return nil, true
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		response.JSON(c, http.StatusNotFound, gin.H{"error": "Organizer not found"})
+		return nil, false
+	}
+	return &id, true
+}
+
+// branding returns the platform's email branding, or an organizer's, with what emails are
+// signed with once fallbacks are applied.
+func (h *AdminHandler) branding(c *gin.Context) {
+	organizerID, ok := brandingOrganizer(c)
+	if !ok {
+		return
+	}
+	settings, err := h.svc.Branding(c.Request.Context(), organizerID)
+	if err != nil {
+		brandingError(c, err)
+		return
+	}
+	response.JSON(c, http.StatusOK, settings)
+}
+
+func (h *AdminHandler) setBranding(c *gin.Context) {
+	organizerID, ok := brandingOrganizer(c)
+	if !ok {
+		return
+	}
+	var in admin.BrandingInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	settings, err := h.svc.SetBranding(c.Request.Context(), c.GetString("uid"), organizerID, in)
+	if err != nil {
+		brandingError(c, err)
+		return
+	}
+	response.JSON(c, http.StatusOK, settings)
+}
+
+func (h *AdminHandler) deleteBranding(c *gin.Context) {
+	organizerID, ok := brandingOrganizer(c)
+	if !ok {
+		return
+	}
+	deleted, err := h.svc.DeleteBranding(c.Request.Context(), c.GetString("uid"), *organizerID)
+	if err != nil {
+		brandingError(c, err)
+		return
+	}
+	if !deleted {
+		response.JSON(c, http.StatusNotFound, gin.H{"error": "Organizer has no branding"})
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"message": "Organizer branding removed; their emails use the platform's"})
+}
+
+func brandingError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, mailer.ErrInvalidBranding):
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err == organizers.ErrOrganizerNotFound:
+		response.JSON(c, http.StatusNotFound, gin.H{"error": "Organizer not found"})
+	default:
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,74 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/api/response"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/logger"
+	jwtMiddleware "github.com/samirwankhede/lewly-pgpyewj/internal/middleware"
+)
+
+// LogLevelsHandler lets admins read and change log levels at runtime. Changes are published
+// over Redis so every API and worker process applies them, not just the one serving the
+// request; they last until the process restarts.
+type LogLevelsHandler struct {
+	log    *zap.Logger
+	client *redis.Client
+	secret string
+}
+
+func NewLogLevelsHandler(log *zap.Logger, client *redis.Client, secret string) *LogLevelsHandler {
+	return &LogLevelsHandler{log: log, client: client, secret: secret}
+}
+
+func (h *LogLevelsHandler) Register(r *gin.Engine) {
+	g := r.Group("/admin")
+	g.Use(jwtMiddleware.Middleware(h.secret, true))
+	{
+		g.GET("/log-levels", h.get)
+		g.PUT("/log-levels", h.set)
+	}
+}
+
+func (h *LogLevelsHandler) get(c *gin.Context) {
+	response.JSON(c, http.StatusOK, gin.H{"levels": logger.Levels()})
+}
+
+func (h *LogLevelsHandler) set(c *gin.Context) {
+	var req struct {
+		Component string `json:"component" binding:"required"`
+		// Level is debug, info, warn or error; empty drops the component's override
+		Level string `json:"level"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Level != "" {
+		var l zapcore.Level
+		if err := l.Set(req.Level); err != nil {
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else if req.Component == logger.DefaultComponent {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": "the default level can't be dropped"})
+		return
+	}
+	// Applied here at once rather than waiting for this process's own listener
+	if err := logger.SetLevel(req.Component, req.Level); err != nil {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := logger.PublishLevel(c.Request.Context(), h.client, req.Component, req.Level); err != nil {
+		h.log.Error("Failed to publish log level change", zap.Error(err))
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": "level changed on this instance only"})
+		return
+	}
+	h.log.Info("log level set", zap.String("component", req.Component), zap.String("level", req.Level), zap.String("by", c.GetString("uid")))
+	response.JSON(c, http.StatusOK, gin.H{"levels": logger.Levels()})
+}
@@ -0,0 +1,64 @@
+package admin
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/api/response"
+	jwtMiddleware "github.com/samirwankhede/lewly-pgpyewj/internal/middleware"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/service/payment"
+)
+
+// SeatIntegrityHandler lets admins review bookings flagged for untrustworthy seats.
+type SeatIntegrityHandler struct {
+	log       *zap.Logger
+	integrity *payment.SeatIntegrity
+	secret    string
+}
+
+func NewSeatIntegrityHandler(log *zap.Logger, integrity *payment.SeatIntegrity, secret string) *SeatIntegrityHandler {
+	return &SeatIntegrityHandler{log: log, integrity: integrity, secret: secret}
+}
+
+func (h *SeatIntegrityHandler) Register(r *gin.Engine) {
+	g := r.Group("/admin")
+	g.Use(jwtMiddleware.Middleware(h.secret, true))
+	{
+		g.GET("/seat-integrity", h.list)
+		g.POST("/seat-integrity/:bookingId/resolve", h.resolve)
+	}
+}
+
+// list returns open issues, or resolved ones too with ?all=true.
+func (h *SeatIntegrityHandler) list(c *gin.Context) {
+	all, _ := strconv.ParseBool(c.DefaultQuery("all", "false"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	issues, err := h.integrity.Issues(c.Request.Context(), all, limit, offset)
+	if err != nil {
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	response.Page(c, "issues", issues, limit, offset)
+}
+
+func (h *SeatIntegrityHandler) resolve(c *gin.Context) {
+	if _, err := uuid.Parse(c.Param("bookingId")); err != nil {
+		response.JSON(c, http.StatusNotFound, gin.H{"error": payment.ErrIssueNotFound.Error()})
+		return
+	}
+	if err := h.integrity.Resolve(c.Request.Context(), c.Param("bookingId")); err != nil {
+		if err == payment.ErrIssueNotFound {
+			response.JSON(c, http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.log.Info("Seat integrity issue resolved", zap.String("booking_id", c.Param("bookingId")), zap.String("by", c.GetString("uid")))
+	response.JSON(c, http.StatusOK, gin.H{"message": "Issue resolved", "booking_id": c.Param("bookingId")})
+}
@@ -1,33 +1,63 @@
 package auth
 
 import (
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 
+	"github.com/samirwankhede/lewly-pgpyewj/internal/api/response"
 	authMiddleware "github.com/samirwankhede/lewly-pgpyewj/internal/middleware"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/oauth"
 	authService "github.com/samirwankhede/lewly-pgpyewj/internal/service/auth"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/service/fx"
 )
 
+// oauthStateCookie ties a Google callback to the browser that started the login.
+const oauthStateCookie = "oauth_state"
+
 type AuthHandler struct {
 	log    *zap.Logger
 	svc    *authService.AuthService
 	secret string
+	limit  gin.HandlerFunc
+	// sessionCookie sets signed-in browsers' session cookie, when sessions are in use
+	sessionCookie bool
 }
 
 func NewAuthHandler(log *zap.Logger, svc *authService.AuthService, secret string) *AuthHandler {
 	return &AuthHandler{log: log, svc: svc, secret: secret}
 }
 
+// WithRateLimit adds limit to the routes that take credentials or OTPs, on top of the
+// global limit.
+func (h *AuthHandler) WithRateLimit(limit gin.HandlerFunc) *AuthHandler {
+	h.limit = limit
+	return h
+}
+
+// WithSessionCookie has sign-ins also set the session ID as a cookie, for browsers, and
+// logout clear it. Use it with AuthService.WithSessions.
+func (h *AuthHandler) WithSessionCookie() *AuthHandler {
+	h.sessionCookie = true
+	return h
+}
+
 func (h *AuthHandler) Register(r *gin.Engine) {
 	auth := r.Group("/v1/auth")
+	if h.limit != nil {
+		auth.Use(h.limit)
+	}
 	{
 		auth.POST("/signup", h.signup)
 		auth.POST("/login", h.login)
 		auth.POST("/logout", h.logout)
 		auth.POST("/password/request-otp", h.requestPasswordChangeOTP)
 		auth.POST("/password/verify-otp", h.verifyPasswordChangeOTP)
+		auth.GET("/oauth/google", h.googleLogin)
+		auth.GET("/oauth/google/callback", h.googleCallback)
 	}
 
 	// Protected routes
@@ -36,191 +66,348 @@ func (h *AuthHandler) Register(r *gin.Engine) {
 	{
 		protected.GET("/profile", h.getProfile)
 		protected.PUT("/profile", h.updateProfile)
-		protected.PUT("/password", h.changePassword)
+		if h.limit != nil {
+			protected.PUT("/password", h.limit, h.changePassword)
+			protected.POST("/merge", h.limit, h.requestMerge)
+			protected.POST("/merge/confirm", h.limit, h.confirmMerge)
+		} else {
+			protected.PUT("/password", h.changePassword)
+			protected.POST("/merge", h.requestMerge)
+			protected.POST("/merge/confirm", h.confirmMerge)
+		}
 	}
 }
 
 func (h *AuthHandler) signup(c *gin.Context) {
 	var req authService.SignupRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	resp, err := h.svc.Signup(c.Request.Context(), req)
 	if err != nil {
 		if err == authService.ErrUserExists {
-			c.JSON(http.StatusConflict, gin.H{"error": "User already exists"})
+			response.JSON(c, http.StatusConflict, gin.H{"error": "User already exists"})
 			return
 		}
 		h.log.Error("Signup failed", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, resp)
+	h.setSessionCookie(c, resp)
+	response.JSON(c, http.StatusCreated, resp)
 }
 
 func (h *AuthHandler) login(c *gin.Context) {
 	var req authService.LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	resp, err := h.svc.Login(c.Request.Context(), req)
 	if err != nil {
 		if err == authService.ErrInvalidCredentials {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+			response.JSON(c, http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 			return
 		}
 		if err == authService.ErrUserNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "User not found"})
 			return
 		}
 		h.log.Error("Login failed", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
 		return
 	}
 
-	c.JSON(http.StatusOK, resp)
+	h.setSessionCookie(c, resp)
+	response.JSON(c, http.StatusOK, resp)
 }
 
 func (h *AuthHandler) logout(c *gin.Context) {
-	// In a stateless JWT system, logout is typically handled client-side
-	// by removing the token. We could implement token blacklisting here if needed.
-	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+	token, ok := authMiddleware.RequestToken(c)
+	if !ok {
+		response.JSON(c, http.StatusUnauthorized, gin.H{"error": "missing bearer token"})
+		return
+	}
+
+	err := h.svc.Logout(c.Request.Context(), token)
+	if err != nil {
+		if err == authService.ErrInvalidToken {
+			response.JSON(c, http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			return
+		}
+		h.log.Error("Logout failed", zap.Error(err))
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	if h.sessionCookie {
+		c.SetCookie(authMiddleware.SessionCookie, "", -1, "/", "", c.Request.TLS != nil, true)
+	}
+	response.JSON(c, http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// setSessionCookie sets the cookie carrying the session resp signed in to.
+func (h *AuthHandler) setSessionCookie(c *gin.Context, resp *authService.LoginResponse) {
+	if !h.sessionCookie {
+		return
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(authMiddleware.SessionCookie, resp.Token, int(time.Until(resp.Expires).Seconds()), "/", "", c.Request.TLS != nil, true)
+}
+
+func (h *AuthHandler) googleLogin(c *gin.Context) {
+	url, state, err := h.svc.StartGoogleLogin(c.Request.Context())
+	if err != nil {
+		if err == authService.ErrOAuthDisabled {
+			response.JSON(c, http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		h.log.Error("Start google login failed", zap.Error(err))
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthStateCookie, state, 600, "/v1/auth/oauth", "", c.Request.TLS != nil, true)
+	c.Redirect(http.StatusFound, url)
+}
+
+func (h *AuthHandler) googleCallback(c *gin.Context) {
+	if e := c.Query("error"); e != "" {
+		response.JSON(c, http.StatusUnauthorized, gin.H{"error": "Google sign-in was not completed: " + e})
+		return
+	}
+	state := c.Query("state")
+	if cookie, err := c.Cookie(oauthStateCookie); err != nil || cookie != state {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": authService.ErrInvalidOAuthState.Error()})
+		return
+	}
+	c.SetCookie(oauthStateCookie, "", -1, "/v1/auth/oauth", "", c.Request.TLS != nil, true)
+
+	resp, err := h.svc.FinishGoogleLogin(c.Request.Context(), state, c.Query("code"))
+	if err != nil {
+		switch {
+		case err == authService.ErrOAuthDisabled:
+			response.JSON(c, http.StatusNotFound, gin.H{"error": err.Error()})
+		case err == authService.ErrInvalidOAuthState, errors.Is(err, oauth.ErrExchange):
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		case err == authService.ErrOAuthEmailUnverified:
+			response.JSON(c, http.StatusForbidden, gin.H{"error": err.Error()})
+		case err == authService.ErrOAuthAccountLinked:
+			response.JSON(c, http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			h.log.Error("Google login failed", zap.Error(err))
+			response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+
+	h.setSessionCookie(c, resp)
+	response.JSON(c, http.StatusOK, resp)
 }
 
 func (h *AuthHandler) getProfile(c *gin.Context) {
 	userID := c.GetString("uid")
 	if userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		response.JSON(c, http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
 	profile, err := h.svc.GetProfile(c.Request.Context(), userID)
 	if err != nil {
 		if err == authService.ErrUserNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "User not found"})
 			return
 		}
 		h.log.Error("Get profile failed", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
 		return
 	}
 
-	c.JSON(http.StatusOK, profile)
+	response.JSON(c, http.StatusOK, profile)
 }
 
 func (h *AuthHandler) updateProfile(c *gin.Context) {
 	userID := c.GetString("uid")
 	if userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		response.JSON(c, http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
 	var req struct {
 		Name  string `json:"name"`
 		Phone string `json:"phone"`
+		// PreferredCurrency is left unchanged when omitted; "" clears it
+		PreferredCurrency *string `json:"preferred_currency"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	err := h.svc.UpdateProfile(c.Request.Context(), userID, req.Name, req.Phone)
+	err := h.svc.UpdateProfile(c.Request.Context(), userID, req.Name, req.Phone, req.PreferredCurrency)
 	if err != nil {
 		if err == authService.ErrUserNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if err == fx.ErrInvalidCurrency {
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 		h.log.Error("Update profile failed", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Profile updated successfully"})
+	response.JSON(c, http.StatusOK, gin.H{"message": "Profile updated successfully"})
 }
 
 func (h *AuthHandler) changePassword(c *gin.Context) {
 	userID := c.GetString("uid")
 	if userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		response.JSON(c, http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
 	var req authService.PasswordChangeRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	err := h.svc.ChangePassword(c.Request.Context(), userID, req)
 	if err != nil {
 		if err == authService.ErrInvalidCredentials {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid current password"})
+			response.JSON(c, http.StatusUnauthorized, gin.H{"error": "Invalid current password"})
 			return
 		}
 		if err == authService.ErrOAuthUser {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Password change not allowed for OAuth users"})
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": "Password change not allowed for OAuth users"})
 			return
 		}
 		if err == authService.ErrUserNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "User not found"})
 			return
 		}
 		h.log.Error("Change password failed", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	response.JSON(c, http.StatusOK, gin.H{"message": "Password changed successfully"})
+}
+
+func (h *AuthHandler) requestMerge(c *gin.Context) {
+	userID := c.GetString("uid")
+	if userID == "" {
+		response.JSON(c, http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req authService.MergeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.svc.RequestAccountMerge(c.Request.Context(), userID, req)
+	if err != nil {
+		if err == authService.ErrMergeSameUser {
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err == authService.ErrUserNotFound {
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		h.log.Error("Request account merge failed", zap.Error(err))
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	// Always return success to prevent email enumeration
+	response.JSON(c, http.StatusOK, gin.H{"message": "If the account exists, confirmation codes have been sent to both addresses"})
+}
+
+func (h *AuthHandler) confirmMerge(c *gin.Context) {
+	userID := c.GetString("uid")
+	if userID == "" {
+		response.JSON(c, http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req authService.MergeConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.svc.ConfirmAccountMerge(c.Request.Context(), userID, req)
+	if err != nil {
+		if err == authService.ErrInvalidMergeCode {
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err == authService.ErrUserNotFound {
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		h.log.Error("Confirm account merge failed", zap.Error(err))
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
+	response.JSON(c, http.StatusOK, res)
 }
 
 func (h *AuthHandler) requestPasswordChangeOTP(c *gin.Context) {
 	var req authService.OTPRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	err := h.svc.RequestPasswordChangeOTP(c.Request.Context(), req)
 	if err != nil {
 		h.log.Error("Request password change OTP failed", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
 		return
 	}
 
 	// Always return success to prevent email enumeration
-	c.JSON(http.StatusOK, gin.H{"message": "If the email exists, an OTP has been sent"})
+	response.JSON(c, http.StatusOK, gin.H{"message": "If the email exists, an OTP has been sent"})
 }
 
 func (h *AuthHandler) verifyPasswordChangeOTP(c *gin.Context) {
 	var req authService.OTPVerifyRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	err := h.svc.VerifyPasswordChangeOTP(c.Request.Context(), req)
 	if err != nil {
 		if err == authService.ErrInvalidOTP {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired OTP"})
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": "Invalid or expired OTP"})
 			return
 		}
 		if err == authService.ErrOAuthUser {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Password change not allowed for OAuth users"})
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": "Password change not allowed for OAuth users"})
 			return
 		}
 		if err == authService.ErrUserNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "User not found"})
 			return
 		}
 		h.log.Error("Verify password change OTP failed", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
+	response.JSON(c, http.StatusOK, gin.H{"message": "Password changed successfully"})
 }
@@ -0,0 +1,210 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	authMiddleware "github.com/samirwankhede/lewly-pgpyewj/internal/middleware"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/oauth"
+	redisx "github.com/samirwankhede/lewly-pgpyewj/internal/redis"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/redis/redistest"
+	authService "github.com/samirwankhede/lewly-pgpyewj/internal/service/auth"
+)
+
+// oauthRouter serves the auth routes with Google pointed at a token endpoint that counts
+// exchanges and rejects every code.
+func oauthRouter(t *testing.T, redisAddr string) (*gin.Engine, *authService.AuthService, *atomic.Int32) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	exchanges := &atomic.Int32{}
+	google := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		exchanges.Add(1)
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+	}))
+	t.Cleanup(google.Close)
+
+	svc := authService.NewAuthService(zap.NewNop(), nil, redisx.NewTokenBucket(redisAddr), "secret", nil).
+		WithGoogle(&oauth.Google{ClientID: "client", ClientSecret: "client-secret", RedirectURL: "https://evently.test/callback",
+			TokenURL: google.URL, UserInfoURL: google.URL})
+	r := gin.New()
+	NewAuthHandler(zap.NewNop(), svc, "secret").Register(r)
+	return r, svc, exchanges
+}
+
+func callback(r *gin.Engine, query url.Values, cookie string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/v1/auth/oauth/google/callback?"+query.Encode(), nil)
+	if cookie != "" {
+		req.AddCookie(&http.Cookie{Name: oauthStateCookie, Value: cookie})
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestGoogleCallbackRequiresTheBrowsersState(t *testing.T) {
+	// Nothing here gets as far as Redis
+	r, _, exchanges := oauthRouter(t, "127.0.0.1:1")
+
+	tests := []struct {
+		name   string
+		query  url.Values
+		cookie string
+		want   int
+	}{
+		{name: "no state cookie", query: url.Values{"state": {"s1"}, "code": {"c"}}, want: http.StatusBadRequest},
+		{name: "state of another browser", query: url.Values{"state": {"s1"}, "code": {"c"}}, cookie: "s2", want: http.StatusBadRequest},
+		{name: "no state", query: url.Values{"code": {"c"}}, cookie: "s1", want: http.StatusBadRequest},
+		{name: "consent refused", query: url.Values{"error": {"access_denied"}, "state": {"s1"}}, cookie: "s1", want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := callback(r, tt.query, tt.cookie); w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+	if n := exchanges.Load(); n != 0 {
+		t.Errorf("%d codes exchanged, want 0", n)
+	}
+}
+
+func TestGoogleCallbackStateIsSingleUse(t *testing.T) {
+	r, svc, exchanges := oauthRouter(t, redistest.Addr(t))
+
+	// A state the server never issued is refused even when the cookie matches
+	if w := callback(r, url.Values{"state": {"forged"}, "code": {"c"}}, "forged"); w.Code != http.StatusBadRequest {
+		t.Errorf("forged state: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if n := exchanges.Load(); n != 0 {
+		t.Fatalf("forged state: %d codes exchanged, want 0", n)
+	}
+
+	_, state, err := svc.StartGoogleLogin(context.Background())
+	if err != nil {
+		t.Fatalf("StartGoogleLogin: %v", err)
+	}
+	query := url.Values{"state": {state}, "code": {"c"}}
+	// Google rejects the code, but the state is used up by trying it
+	if w := callback(r, query, state); w.Code != http.StatusBadRequest {
+		t.Errorf("first callback: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if n := exchanges.Load(); n != 1 {
+		t.Fatalf("first callback: %d codes exchanged, want 1", n)
+	}
+	if w := callback(r, query, state); w.Code != http.StatusBadRequest {
+		t.Errorf("replayed callback: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if n := exchanges.Load(); n != 1 {
+		t.Errorf("replayed callback: %d codes exchanged, want 1", n)
+	}
+}
+
+func TestConfirmMergeNeedsBothCodes(t *testing.T) {
+	addr := redistest.Addr(t)
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() { _ = client.Close() })
+
+	svc := authService.NewAuthService(zap.NewNop(), nil, redisx.NewTokenBucket(addr), "secret", nil)
+	r := gin.New()
+	NewAuthHandler(zap.NewNop(), svc, "secret").Register(r)
+
+	userID := uuid.NewString()
+	key := "account_merge:" + userID
+	t.Cleanup(func() { _ = client.Del(ctx, key).Err() })
+	pending := `{"merged_id":"` + uuid.NewString() + `","code":"111111","merged_code":"222222"}`
+	if err := client.Set(ctx, key, pending, time.Minute).Err(); err != nil {
+		t.Fatalf("seed merge: %v", err)
+	}
+	token, err := authMiddleware.Issue("secret", userID, false, 0, time.Hour)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	confirm := func(token, body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/v1/auth/merge/confirm", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	both := `{"code":"111111","merged_code":"222222"}`
+
+	if code := confirm("", both); code != http.StatusUnauthorized {
+		t.Errorf("signed out: status = %d, want %d", code, http.StatusUnauthorized)
+	}
+	// Only the signed-in account's code: someone who knows the other account's email alone
+	// can't take it over
+	if code := confirm(token, `{"code":"111111","merged_code":"000000"}`); code != http.StatusBadRequest {
+		t.Errorf("wrong merged code: status = %d, want %d", code, http.StatusBadRequest)
+	}
+	// and a wrong guess uses the request up, so codes can't be guessed one at a time
+	if code := confirm(token, both); code != http.StatusBadRequest {
+		t.Errorf("after a wrong guess: status = %d, want %d", code, http.StatusBadRequest)
+	}
+	if n, err := client.Exists(ctx, key).Result(); err != nil || n != 0 {
+		t.Errorf("pending merge still stored: %d, %v", n, err)
+	}
+}
+
+func TestLogoutRevokesTheToken(t *testing.T) {
+	addr := redistest.Addr(t)
+	gin.SetMode(gin.TestMode)
+	blacklist := authMiddleware.NewTokenBlacklist(redis.NewClient(&redis.Options{Addr: addr}))
+	authMiddleware.UseTokenBlacklist(blacklist)
+	t.Cleanup(func() { authMiddleware.UseTokenBlacklist(nil) })
+
+	svc := authService.NewAuthService(zap.NewNop(), nil, redisx.NewTokenBucket(addr), "secret", nil).WithTokenBlacklist(blacklist)
+	r := gin.New()
+	NewAuthHandler(zap.NewNop(), svc, "secret").Register(r)
+	r.GET("/whoami", authMiddleware.UserMiddleware("secret"), func(c *gin.Context) { c.String(http.StatusOK, c.GetString("uid")) })
+	do := func(method, path, token string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	userID := uuid.NewString()
+	token, err := authMiddleware.Issue("secret", userID, false, 0, time.Hour)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	other, err := authMiddleware.Issue("secret", userID, false, 0, time.Hour)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	if code := do(http.MethodGet, "/whoami", token); code != http.StatusOK {
+		t.Fatalf("before logout: status = %d, want %d", code, http.StatusOK)
+	}
+	if code := do(http.MethodPost, "/v1/auth/logout", token); code != http.StatusOK {
+		t.Fatalf("logout: status = %d, want %d", code, http.StatusOK)
+	}
+	if code := do(http.MethodGet, "/whoami", token); code != http.StatusUnauthorized {
+		t.Errorf("after logout: status = %d, want %d", code, http.StatusUnauthorized)
+	}
+	// Only the token signed out with is revoked, not the user's other sign-ins
+	if code := do(http.MethodGet, "/whoami", other); code != http.StatusOK {
+		t.Errorf("other token: status = %d, want %d", code, http.StatusOK)
+	}
+	if code := do(http.MethodPost, "/v1/auth/logout", "not-a-jwt.x.y"); code != http.StatusUnauthorized {
+		t.Errorf("logout with a forged token: status = %d, want %d", code, http.StatusUnauthorized)
+	}
+}
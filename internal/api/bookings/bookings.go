@@ -1,25 +1,45 @@
 package bookings
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 
+	"github.com/samirwankhede/lewly-pgpyewj/internal/api/response"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/cursor"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/lock"
 	jwtMiddleware "github.com/samirwankhede/lewly-pgpyewj/internal/middleware"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/service/bookings"
+	eventsService "github.com/samirwankhede/lewly-pgpyewj/internal/service/events"
+	paymentService "github.com/samirwankhede/lewly-pgpyewj/internal/service/payment"
+	storeBookings "github.com/samirwankhede/lewly-pgpyewj/internal/store/bookings"
 )
 
+// sseHeartbeat is how often an idle booking event stream sends a keep-alive comment.
+const sseHeartbeat = 15 * time.Second
+
 type BookingsHandler struct {
-	svc    *bookings.BookingsService
-	secret string
+	svc     *bookings.BookingsService
+	secret  string
+	cursors *cursor.Codec
 }
 
 func NewBookingsHandler(svc *bookings.BookingsService, secret string) *BookingsHandler {
 	return &BookingsHandler{svc: svc, secret: secret}
 }
 
+// WithCursors pages the user's bookings with cursors encoded by codec; without it only the
+// first page is served.
+func (h *BookingsHandler) WithCursors(codec *cursor.Codec) *BookingsHandler {
+	h.cursors = codec
+	return h
+}
+
 func (h *BookingsHandler) Register(r *gin.Engine) {
 	// Protected routes
 	protected := r.Group("/v1/bookings")
@@ -27,72 +47,385 @@ func (h *BookingsHandler) Register(r *gin.Engine) {
 	{
 		protected.POST("/:id/book", h.book)
 		protected.GET("/:id/status", h.getStatus)
+		protected.POST("/status", h.getStatuses)
+		protected.GET("/:id/events", h.streamEvents)
 		protected.POST("/:id/cancel", h.cancel)
+		protected.PUT("/:id/seats", h.changeSeats)
+		protected.POST("/:id/seats/cancel", h.cancelSeats)
+		protected.POST("/:id/transfer", h.transfer)
+		protected.DELETE("/:id/transfer", h.cancelTransfer)
 		protected.GET("/user-bookings", h.listUserBookings)
 	}
+
+	// Claim links sent to a transfer's recipient
+	transfers := r.Group("/v1/transfers")
+	transfers.Use(jwtMiddleware.Middleware(h.secret, false))
+	{
+		transfers.GET("/:token", h.previewTransfer)
+		transfers.POST("/:token/accept", h.acceptTransfer)
+	}
+
+	admin := r.Group("/admin/bookings")
+	admin.Use(jwtMiddleware.Middleware(h.secret, true))
+	{
+		admin.GET("/:id", h.inspect)
+		admin.GET("/:id/trace", h.trace)
+		admin.POST("/:id/finalize", h.requeueFinalize)
+		admin.POST("/:id/reseat", h.reseat)
+	}
+}
+
+func (h *BookingsHandler) inspect(c *gin.Context) {
+	b, err := h.svc.GetBooking(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if b == nil {
+		response.JSON(c, http.StatusNotFound, gin.H{"error": "Booking not found"})
+		return
+	}
+	response.JSON(c, http.StatusOK, b)
+}
+
+// trace returns the booking with everything recorded about it, oldest first.
+func (h *BookingsHandler) trace(c *gin.Context) {
+	t, err := h.svc.Trace(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, bookings.ErrBookingNotFound) {
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Booking not found"})
+			return
+		}
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	response.JSON(c, http.StatusOK, t)
+}
+
+func (h *BookingsHandler) requeueFinalize(c *gin.Context) {
+	b, err := h.svc.RequeueFinalize(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		switch err {
+		case bookings.ErrBookingNotFound:
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Booking not found"})
+		case bookings.ErrBookingNotPending:
+			response.JSON(c, http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	response.JSON(c, http.StatusAccepted, gin.H{"message": "Finalization requeued", "booking_id": b.ID})
+}
+
+func (h *BookingsHandler) reseat(c *gin.Context) {
+	var req struct {
+		Seats  []string `json:"seats" binding:"required"`
+		Reason string   `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	b, err := h.svc.Reseat(c.Request.Context(), c.Param("id"), req.Seats, c.GetString("uid"), req.Reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, bookings.ErrValidation), errors.Is(err, bookings.ErrSeatCountChanged), errors.Is(err, bookings.ErrUnknownSeats):
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, bookings.ErrBookingNotFound):
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Booking not found"})
+		case errors.Is(err, bookings.ErrBookingNotActive), errors.Is(err, bookings.ErrSeatsTaken), errors.Is(err, eventsService.ErrEventArchived):
+			response.JSON(c, http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	response.JSON(c, http.StatusOK, b)
+}
+
+// changeSeats moves the user's booking to other seats, charging or refunding the difference.
+func (h *BookingsHandler) changeSeats(c *gin.Context) {
+	var req struct {
+		Seats []string `json:"seats" binding:"required"`
+		// PaymentID pays the difference when a paid booking moves to dearer seats
+		PaymentID string `json:"payment_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	res, err := h.svc.ChangeSeats(c.Request.Context(), c.Param("id"), c.GetString("uid"), req.Seats, req.PaymentID)
+	if err != nil {
+		switch {
+		case errors.Is(err, bookings.ErrValidation), errors.Is(err, bookings.ErrSeatCountChanged), errors.Is(err, bookings.ErrUnknownSeats):
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, bookings.ErrBookingNotFound):
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Booking not found"})
+		case errors.Is(err, paymentService.ErrPaymentIDMissing), errors.Is(err, paymentService.ErrPaymentFailed):
+			response.JSON(c, http.StatusPaymentRequired, gin.H{"error": err.Error()})
+		case errors.Is(err, bookings.ErrBookingNotActive), errors.Is(err, bookings.ErrSeatsTaken), errors.Is(err, bookings.ErrSeatSelectionDisabled),
+			errors.Is(err, bookings.ErrAuthorizedPriceChange), errors.Is(err, eventsService.ErrEventArchived):
+			response.JSON(c, http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	response.JSON(c, http.StatusOK, res)
+}
+
+// cancelSeats gives up some of the user's booking's seats, refunding them if it was paid.
+func (h *BookingsHandler) cancelSeats(c *gin.Context) {
+	var req struct {
+		Seats []string `json:"seats" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	res, err := h.svc.CancelSeats(c.Request.Context(), c.Param("id"), c.GetString("uid"), req.Seats)
+	if err != nil {
+		switch {
+		case errors.Is(err, bookings.ErrValidation), errors.Is(err, bookings.ErrUnknownSeats), errors.Is(err, bookings.ErrAllSeatsCancelled):
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, bookings.ErrBookingNotFound):
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Booking not found"})
+		case errors.Is(err, bookings.ErrBookingNotActive), errors.Is(err, bookings.ErrAuthorizedPriceChange):
+			response.JSON(c, http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, lock.ErrTimeout):
+			response.JSON(c, http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		default:
+			response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	response.JSON(c, http.StatusOK, res)
+}
+
+func (h *BookingsHandler) transfer(c *gin.Context) {
+	var req struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	t, err := h.svc.Transfer(c.Request.Context(), c.Param("id"), c.GetString("uid"), req.Email)
+	if err != nil {
+		writeTransferError(c, err)
+		return
+	}
+	response.JSON(c, http.StatusCreated, t)
+}
+
+func (h *BookingsHandler) cancelTransfer(c *gin.Context) {
+	if err := h.svc.CancelTransfer(c.Request.Context(), c.Param("id"), c.GetString("uid")); err != nil {
+		writeTransferError(c, err)
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"status": "cancelled"})
+}
+
+func (h *BookingsHandler) previewTransfer(c *gin.Context) {
+	p, err := h.svc.PreviewTransfer(c.Request.Context(), c.Param("token"), c.GetString("uid"))
+	if err != nil {
+		writeTransferError(c, err)
+		return
+	}
+	response.JSON(c, http.StatusOK, p)
+}
+
+func (h *BookingsHandler) acceptTransfer(c *gin.Context) {
+	b, err := h.svc.AcceptTransfer(c.Request.Context(), c.Param("token"), c.GetString("uid"))
+	if err != nil {
+		writeTransferError(c, err)
+		return
+	}
+	response.JSON(c, http.StatusOK, b)
+}
+
+func writeTransferError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, bookings.ErrTransferToSelf):
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, bookings.ErrTransferRecipient):
+		response.JSON(c, http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, bookings.ErrBookingNotFound):
+		response.JSON(c, http.StatusNotFound, gin.H{"error": "Booking not found"})
+	case errors.Is(err, bookings.ErrTransferNotFound):
+		response.JSON(c, http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, bookings.ErrNotTransferable), errors.Is(err, bookings.ErrTransferClosed):
+		response.JSON(c, http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
 }
 
 func (h *BookingsHandler) book(c *gin.Context) {
 	eventID := c.Param("id")
 	userID := c.GetString("uid")
-	IdempotencyKey := uuid.NewString() //This Part should be handled by another service - currently we're just creating a new uuid
+	// Clients that retry send the same Idempotency-Key; without one every request is a new booking
+	IdempotencyKey := c.GetHeader("Idempotency-Key")
+	if IdempotencyKey == "" {
+		IdempotencyKey = uuid.NewString()
+	}
+	if len(IdempotencyKey) > 255 {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": "idempotency key too long"})
+		return
+	}
+	// Seat selection events take seat labels or a quantity, general admission events a quantity
 	type Seats struct {
-		Seats []string `json:"seats" binding:"required"`
+		Seats    []string `json:"seats"`
+		Quantity int      `json:"quantity" binding:"omitempty,gt=0"`
+		// InvitationCode admits the user to a private event
+		InvitationCode string `json:"invitation_code"`
 	}
 	var seats Seats
 	if err := c.ShouldBindJSON(&seats); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 	if userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing user id"})
+		response.JSON(c, http.StatusUnauthorized, gin.H{"error": "missing user id"})
 		return
 	}
 	if eventID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "missing event id"})
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": "missing event id"})
+		return
+	}
+	resp, code, err := h.svc.Create(c, eventID, userID, c.GetString("channel"), &IdempotencyKey, seats.Seats, seats.Quantity, seats.InvitationCode)
+	if err != nil {
+		if errors.Is(err, bookings.ErrLatencyBudget) || errors.Is(err, bookings.ErrSeatContention) {
+			c.Header("Retry-After", "1")
+		}
+		response.JSON(c, code, gin.H{"error": err.Error()})
 		return
 	}
-	resp, code, err := h.svc.Create(c, eventID, userID, &IdempotencyKey, seats.Seats)
+	response.JSON(c, code, resp)
+}
+
+// streamEvents is a server-sent event stream of the booking's status transitions. It starts
+// with a "status" event carrying the current status and ends once the booking is settled.
+func (h *BookingsHandler) streamEvents(c *gin.Context) {
+	ctx := c.Request.Context()
+	b, events, err := h.svc.Watch(ctx, c.Param("id"), c.GetString("uid"))
 	if err != nil {
-		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		if err == bookings.ErrBookingNotFound {
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Booking not found"})
+			return
+		}
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// Stop reverse proxies (nginx) from buffering the stream
+	c.Header("X-Accel-Buffering", "no")
+
+	// The server's WriteTimeout would cut the stream; a payment window is 15 minutes
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	c.SSEvent("status", gin.H{"booking_id": b.ID, "status": b.Status})
+	c.Writer.Flush()
+	if b.Status.Settled() || events == nil {
 		return
 	}
-	c.JSON(code, resp)
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			// A comment line keeps idle connections from being closed by proxies
+			_, _ = c.Writer.WriteString(": ping\n\n")
+			c.Writer.Flush()
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			c.SSEvent(e.Type, e)
+			c.Writer.Flush()
+			if e.Status.Settled() {
+				return
+			}
+		}
+	}
 }
 
 func (h *BookingsHandler) getStatus(c *gin.Context) {
 	id := c.Param("id")
 	status, err := h.svc.GetBookingStatus(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	if status == "" {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+		response.JSON(c, http.StatusNotFound, gin.H{"error": "Booking not found"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"status": status})
+	response.JSON(c, http.StatusOK, gin.H{"status": status})
+}
+
+func (h *BookingsHandler) getStatuses(c *gin.Context) {
+	var in struct {
+		BookingIDs []string `json:"booking_ids" binding:"required,min=1,dive,uuid"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(in.BookingIDs) > bookings.MaxBatchStatusIDs {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("at most %d booking ids per request", bookings.MaxBatchStatusIDs)})
+		return
+	}
+	statuses, notFound, err := h.svc.GetBookingStatuses(c.Request.Context(), c.GetString("uid"), in.BookingIDs)
+	if err != nil {
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"statuses": statuses, "not_found": notFound})
 }
 
 func (h *BookingsHandler) listUserBookings(c *gin.Context) {
 	userID := c.GetString("uid")
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
 	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	var pos storeBookings.UserPosition
+	given, ok := h.cursors.Bind(c, &pos)
+	if !ok {
+		return
+	}
+	var after *storeBookings.UserPosition
+	if given {
+		after, offset = &pos, 0
+	}
 
-	bookings, err := h.svc.ListUserBookings(c.Request.Context(), userID, limit, offset)
+	// One booking over the page tells whether another page follows
+	bookings, err := h.svc.ListUserBookings(c.Request.Context(), userID, limit+1, offset, after)
+	if err != nil {
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	bookings, last := cursor.Trim(bookings, limit, storeBookings.Position)
+	next, err := h.cursors.Next(last)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"bookings": bookings, "limit": limit, "offset": offset})
+	response.CursorPage(c, "bookings", bookings, limit, offset, next)
 }
 
 func (h *BookingsHandler) cancel(c *gin.Context) {
 	id := c.Param("id")
 	resp, code, err := h.svc.Cancel(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(code, gin.H{"error": err.Error()})
+		response.JSON(c, code, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(code, resp)
+	response.JSON(c, code, resp)
 }
@@ -0,0 +1,179 @@
+package checkin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/api/response"
+	jwtMiddleware "github.com/samirwankhede/lewly-pgpyewj/internal/middleware"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/service/checkin"
+	storeCheckIn "github.com/samirwankhede/lewly-pgpyewj/internal/store/checkin"
+)
+
+// GateTokenHeader carries a gate device's token on gate routes.
+const GateTokenHeader = "X-Gate-Token"
+
+type CheckInHandler struct {
+	log    *zap.Logger
+	svc    *checkin.CheckInService
+	secret string
+	// access is nil unless event owners and co-organizers manage their events' gate tokens
+	access jwtMiddleware.EventAccess
+}
+
+func NewCheckInHandler(log *zap.Logger, svc *checkin.CheckInService, secret string) *CheckInHandler {
+	return &CheckInHandler{log: log, svc: svc, secret: secret}
+}
+
+// WithEventAccess lets event owners, and co-organizers with the manage_check_in permission,
+// manage the event's gate tokens from the organizer console as admins do.
+func (h *CheckInHandler) WithEventAccess(access jwtMiddleware.EventAccess) *CheckInHandler {
+	h.access = access
+	return h
+}
+
+func (h *CheckInHandler) Register(r *gin.Engine) {
+	gate := r.Group("/v1/gate")
+	gate.Use(h.gateAuth)
+	{
+		gate.GET("/session", h.session)
+		gate.POST("/scan", h.scan)
+	}
+
+	admin := r.Group("/admin/events")
+	admin.Use(jwtMiddleware.Middleware(h.secret, true))
+	{
+		admin.POST("/:id/gate-tokens", h.issue)
+		admin.GET("/:id/gate-tokens", h.list)
+		admin.DELETE("/:id/gate-tokens/:tokenId", h.revoke)
+	}
+
+	if h.access != nil {
+		organizer := r.Group("/v1/organizer/events")
+		organizer.Use(jwtMiddleware.UserMiddleware(h.secret), jwtMiddleware.RequireEventPermission(h.access, jwtMiddleware.PermManageCheckIn))
+		{
+			organizer.POST("/:id/gate-tokens", h.issue)
+			organizer.GET("/:id/gate-tokens", h.list)
+			organizer.DELETE("/:id/gate-tokens/:tokenId", h.revoke)
+		}
+	}
+}
+
+// gateAuth authenticates a gate device by its token and sets "gate" to the token, which
+// scopes everything the device does to the token's event.
+func (h *CheckInHandler) gateAuth(c *gin.Context) {
+	secret := c.GetHeader(GateTokenHeader)
+	if secret == "" {
+		response.Abort(c, http.StatusUnauthorized, gin.H{"error": "missing gate token"})
+		return
+	}
+	t, err := h.svc.Authenticate(c.Request.Context(), secret)
+	if err != nil {
+		switch err {
+		case checkin.ErrInvalidToken, checkin.ErrTokenRevoked, checkin.ErrTokenNotValid:
+			response.Abort(c, http.StatusUnauthorized, gin.H{"error": err.Error()})
+		default:
+			h.log.Error("Gate token lookup failed", zap.Error(err))
+			response.Abort(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+	c.Set("gate", t)
+	c.Next()
+}
+
+func (h *CheckInHandler) session(c *gin.Context) {
+	response.JSON(c, http.StatusOK, c.MustGet("gate"))
+}
+
+func (h *CheckInHandler) scan(c *gin.Context) {
+	var req struct {
+		BookingID string `json:"booking_id" binding:"required,uuid"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	gate := c.MustGet("gate").(*storeCheckIn.GateToken)
+	res, err := h.svc.Scan(c.Request.Context(), gate, req.BookingID)
+	if err != nil {
+		switch err {
+		case checkin.ErrBookingNotFound:
+			response.JSON(c, http.StatusNotFound, gin.H{"error": err.Error()})
+		case checkin.ErrWrongEvent, checkin.ErrOutsideWindow:
+			response.JSON(c, http.StatusForbidden, gin.H{"error": err.Error()})
+		case checkin.ErrNotBooked:
+			response.JSON(c, http.StatusConflict, gin.H{"error": err.Error()})
+		case checkin.ErrAlreadyCheckedIn:
+			response.JSON(c, http.StatusConflict, gin.H{"error": err.Error(), "checked_in_at": res.CheckedInAt})
+		default:
+			h.log.Error("Gate scan failed", zap.Error(err), zap.String("booking_id", req.BookingID))
+			response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+	response.JSON(c, http.StatusOK, res)
+}
+
+func (h *CheckInHandler) issue(c *gin.Context) {
+	if _, err := uuid.Parse(c.Param("id")); err != nil {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": "invalid event id"})
+		return
+	}
+	var req checkin.IssueTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	t, err := h.svc.IssueToken(c.Request.Context(), c.Param("id"), c.GetString("uid"), req)
+	if err != nil {
+		switch err {
+		case checkin.ErrEventNotFound:
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Event not found"})
+		case checkin.ErrInvalidTokenTTL, checkin.ErrInvalidTokenName:
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		case checkin.ErrEventOver:
+			response.JSON(c, http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	response.JSON(c, http.StatusCreated, t)
+}
+
+func (h *CheckInHandler) list(c *gin.Context) {
+	if _, err := uuid.Parse(c.Param("id")); err != nil {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": "invalid event id"})
+		return
+	}
+	tokens, err := h.svc.ListTokens(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"event_id": c.Param("id"), "gate_tokens": tokens})
+}
+
+func (h *CheckInHandler) revoke(c *gin.Context) {
+	if _, err := uuid.Parse(c.Param("id")); err != nil {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": "invalid event id"})
+		return
+	}
+	if _, err := uuid.Parse(c.Param("tokenId")); err != nil {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": "invalid gate token id"})
+		return
+	}
+	if err := h.svc.RevokeToken(c.Request.Context(), c.Param("id"), c.Param("tokenId")); err != nil {
+		if err == checkin.ErrTokenNotFound {
+			response.JSON(c, http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"message": "Gate token revoked"})
+}
@@ -0,0 +1,114 @@
+package checkin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/service/checkin"
+	storeBookings "github.com/samirwankhede/lewly-pgpyewj/internal/store/bookings"
+	storeCheckIn "github.com/samirwankhede/lewly-pgpyewj/internal/store/checkin"
+	storeEvents "github.com/samirwankhede/lewly-pgpyewj/internal/store/events"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/storetest"
+)
+
+func TestGateTokensAreScopedToTheirEvent(t *testing.T) {
+	db := storetest.DB(t)
+	ctx := context.Background()
+	gin.SetMode(gin.TestMode)
+
+	var userID string
+	if err := db.Pool.QueryRow(ctx, `INSERT INTO users (email) VALUES ($1) RETURNING id`, uuid.NewString()+"@example.com").Scan(&userID); err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+	// Both events are under way, so their gates are open
+	event := func(name string) string {
+		var id string
+		err := db.Pool.QueryRow(ctx, `INSERT INTO events (name, capacity, start_time, end_time, status) VALUES ($1, 10, $2, $3, 'ongoing') RETURNING id`,
+			name, time.Now().Add(-time.Hour), time.Now().Add(time.Hour)).Scan(&id)
+		if err != nil {
+			t.Fatalf("insert event: %v", err)
+		}
+		return id
+	}
+	booking := func(eventID string) string {
+		var id string
+		err := db.Pool.QueryRow(ctx, `INSERT INTO bookings (user_id, event_id, status, seats) VALUES ($1, $2, 'booked', '["A1"]') RETURNING id`,
+			userID, eventID).Scan(&id)
+		if err != nil {
+			t.Fatalf("insert booking: %v", err)
+		}
+		return id
+	}
+	hall, other := event("Gate hall"), event("Gate other")
+	t.Cleanup(func() {
+		for _, id := range []string{hall, other} {
+			_, _ = db.Pool.Exec(ctx, `DELETE FROM check_ins WHERE event_id = $1`, id)
+			_, _ = db.Pool.Exec(ctx, `DELETE FROM bookings WHERE event_id = $1`, id)
+			_, _ = db.Pool.Exec(ctx, `DELETE FROM events WHERE id = $1`, id)
+		}
+		_, _ = db.Pool.Exec(ctx, `DELETE FROM users WHERE id = $1`, userID)
+	})
+	hallBooking, otherBooking := booking(hall), booking(other)
+
+	svc := checkin.NewCheckInService(zap.NewNop(), storeCheckIn.NewCheckInRepository(db, zap.NewNop()),
+		storeEvents.NewEventsRepository(db, zap.NewNop()), storeBookings.NewBookingsRepository(db, zap.NewNop()), 24*time.Hour, time.Hour)
+	r := gin.New()
+	NewCheckInHandler(zap.NewNop(), svc, "secret").Register(r)
+
+	issue := func(req checkin.IssueTokenRequest) string {
+		tok, err := svc.IssueToken(ctx, hall, "", req)
+		if err != nil {
+			t.Fatalf("IssueToken: %v", err)
+		}
+		return tok.Token
+	}
+	valid := issue(checkin.IssueTokenRequest{Name: "door 1"})
+	later := time.Now().Add(time.Hour)
+	notYet := issue(checkin.IssueTokenRequest{Name: "door 2", ValidFrom: &later})
+	revoked, err := svc.IssueToken(ctx, hall, "", checkin.IssueTokenRequest{Name: "door 3"})
+	if err != nil {
+		t.Fatalf("IssueToken: %v", err)
+	}
+	if err := svc.RevokeToken(ctx, hall, revoked.ID); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+
+	scan := func(token, bookingID string) int {
+		req := httptest.NewRequest(http.MethodPost, "/v1/gate/scan", strings.NewReader(`{"booking_id":"`+bookingID+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set(GateTokenHeader, token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		booking string
+		want    int
+	}{
+		{name: "no token", booking: hallBooking, want: http.StatusUnauthorized},
+		{name: "unknown token", token: checkin.TokenPrefix + "unknown", booking: hallBooking, want: http.StatusUnauthorized},
+		{name: "revoked token", token: revoked.Token, booking: hallBooking, want: http.StatusUnauthorized},
+		{name: "token not valid yet", token: notYet, booking: hallBooking, want: http.StatusUnauthorized},
+		{name: "booking for another event", token: valid, booking: otherBooking, want: http.StatusForbidden},
+		{name: "booking for the token's event", token: valid, booking: hallBooking, want: http.StatusOK},
+		{name: "ticket scanned twice", token: valid, booking: hallBooking, want: http.StatusConflict},
+	}
+	for _, tt := range tests {
+		if code := scan(tt.token, tt.booking); code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, code, tt.want)
+		}
+	}
+}
@@ -3,45 +3,76 @@ package events
 import (
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 
+	"github.com/samirwankhede/lewly-pgpyewj/internal/api/response"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/cursor"
 	jwtMiddleware "github.com/samirwankhede/lewly-pgpyewj/internal/middleware"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/service/events"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/service/fx"
+	storeEvents "github.com/samirwankhede/lewly-pgpyewj/internal/store/events"
+)
+
+const (
+	defaultNearbyRadiusKm = 25
+	maxNearbyRadiusKm     = 500
+	maxSearchQueryLen     = 200
 )
 
 type EventsHandler struct {
-	log    *zap.Logger
-	svc    *events.EventsService
-	secret string
+	log     *zap.Logger
+	svc     *events.EventsService
+	secret  string
+	cursors *cursor.Codec
 }
 
 func NewEventsHandler(log *zap.Logger, svc *events.EventsService, secret string) *EventsHandler {
 	return &EventsHandler{log: log, svc: svc, secret: secret}
 }
 
+// WithCursors pages event lists with cursors encoded by codec; without it only the first
+// page is served.
+func (h *EventsHandler) WithCursors(codec *cursor.Codec) *EventsHandler {
+	h.cursors = codec
+	return h
+}
+
 func (h *EventsHandler) Register(r *gin.Engine) {
 	r.GET("/v1/events", h.list)
 	r.GET("/v1/events/all", h.listAll)
 	r.GET("/v1/events/upcoming", h.listUpcoming)
 	r.GET("/v1/events/popular", h.listPopular)
+	r.GET("/v1/events/nearby", h.listNearby)
+	r.GET("/v1/events/search", h.search)
 	r.GET("/v1/events/:id", h.get)
-	r.GET("/v1/events/:id/seats", h.getAvailableSeats)
+	r.GET("/v1/events/:id/seats", h.getSeatMap)
+	r.GET("/v1/events/:id/sale-phases", h.salePhases)
+	r.GET("/v1/events/:id/stats", h.stats)
 
-	// Protected routes for liking events
+	// Protected routes for liking events and redeeming invitations
 	protected := r.Group("/v1/events")
 	protected.Use(jwtMiddleware.Middleware(h.secret, false))
 	{
 		protected.POST("/:id/like", h.likeEvent)
 		protected.DELETE("/:id/like", h.unlikeEvent)
+		protected.POST("/:id/invitations/redeem", h.redeemInvitation)
 	}
 }
 
 func (h *EventsHandler) list(c *gin.Context) {
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
 	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	after, ok := h.listPosition(c)
+	if !ok {
+		return
+	}
+	if after != nil {
+		offset = 0
+	}
 	q := c.Query("q")
 	var fromPtr, toPtr *time.Time
 	if v := c.Query("from"); v != "" {
@@ -54,36 +85,194 @@ func (h *EventsHandler) list(c *gin.Context) {
 			toPtr = &t
 		}
 	}
-	items, err := h.svc.List(c.Request.Context(), limit, offset, q, fromPtr, toPtr)
+	// One event over the page tells whether another page follows
+	items, err := h.svc.List(c.Request.Context(), limit+1, offset, after, q, fromPtr, toPtr)
+	if err != nil {
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.listPage(c, items, limit, offset)
+}
+
+// listPosition reads the cursor of a listing ordered by start time: nil without one, false
+// after writing a 400 for a bad one. A cursor replaces offset.
+func (h *EventsHandler) listPosition(c *gin.Context) (*storeEvents.ListPosition, bool) {
+	var pos storeEvents.ListPosition
+	given, ok := h.cursors.Bind(c, &pos)
+	if !ok || !given {
+		return nil, ok
+	}
+	return &pos, true
+}
+
+// listPage writes a page of a listing ordered by start time, fetched with one event over
+// limit, with the cursor of the next page if there is one.
+func (h *EventsHandler) listPage(c *gin.Context, items []*storeEvents.Event, limit, offset int) {
+	items, last := cursor.Trim(items, limit, storeEvents.Position)
+	next, err := h.cursors.Next(last)
+	if err != nil {
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.localize(c, items...) {
+		return
+	}
+	response.CursorPage(c, "events", items, limit, offset, next)
+}
+
+func (h *EventsHandler) listNearby(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	lat, err := strconv.ParseFloat(c.Query("lat"), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": "lat must be between -90 and 90"})
+		return
+	}
+	lng, err := strconv.ParseFloat(c.Query("lng"), 64)
+	if err != nil || lng < -180 || lng > 180 {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": "lng must be between -180 and 180"})
+		return
+	}
+	radius, err := strconv.ParseFloat(c.DefaultQuery("radius", strconv.Itoa(defaultNearbyRadiusKm)), 64)
+	if err != nil || radius <= 0 || radius > maxNearbyRadiusKm {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": "radius must be between 0 and 500 km"})
+		return
+	}
+
+	f := storeEvents.NearbyFilter{Latitude: lat, Longitude: lng, RadiusKm: radius, Category: c.Query("category")}
+	if v := c.Query("from"); v != "" {
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			f.From = &t
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			f.To = &t
+		}
+	}
+	if v := c.Query("min_price"); v != "" {
+		if p, err := strconv.ParseFloat(v, 64); err == nil {
+			f.MinPrice = &p
+		}
+	}
+	if v := c.Query("max_price"); v != "" {
+		if p, err := strconv.ParseFloat(v, 64); err == nil {
+			f.MaxPrice = &p
+		}
+	}
+
+	items, err := h.svc.ListNearby(c.Request.Context(), f, limit, offset)
+	if err != nil {
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	evs := make([]*storeEvents.Event, len(items))
+	for i, item := range items {
+		evs[i] = item.Event
+	}
+	if !h.localize(c, evs...) {
+		return
+	}
+	response.Page(c, "events", items, limit, offset)
+}
+
+// search serves GET /v1/events/search: full-text search with ranking, falling back to
+// similar names and venues when the query matches nothing.
+func (h *EventsHandler) search(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" || len(q) > maxSearchQueryLen {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": "q is required and at most 200 characters"})
+		return
+	}
+
+	f := storeEvents.SearchFilter{Query: q, Category: c.Query("category")}
+	if v := c.Query("min_price"); v != "" {
+		p, err := strconv.ParseFloat(v, 64)
+		if err != nil || p < 0 {
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": "min_price must be a non-negative number"})
+			return
+		}
+		f.MinPrice = &p
+	}
+	if v := c.Query("max_price"); v != "" {
+		p, err := strconv.ParseFloat(v, 64)
+		if err != nil || p < 0 {
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": "max_price must be a non-negative number"})
+			return
+		}
+		f.MaxPrice = &p
+	}
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": "from must be an RFC 3339 timestamp"})
+			return
+		}
+		f.From = &t
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": "to must be an RFC 3339 timestamp"})
+			return
+		}
+		f.To = &t
+	}
+
+	items, err := h.svc.Search(c.Request.Context(), f, limit, offset)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		h.log.Error("Failed to search events", zap.Error(err), zap.String("q", q))
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	evs := make([]*storeEvents.Event, len(items))
+	for i, item := range items {
+		evs[i] = item.Event
+	}
+	if !h.localize(c, evs...) {
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"events": items, "limit": limit, "offset": offset})
+	response.Page(c, "events", items, limit, offset)
 }
 
 func (h *EventsHandler) listAll(c *gin.Context) {
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
 	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	after, ok := h.listPosition(c)
+	if !ok {
+		return
+	}
+	if after != nil {
+		offset = 0
+	}
 
-	items, err := h.svc.ListAll(c.Request.Context(), limit, offset)
+	items, err := h.svc.ListAll(c.Request.Context(), limit+1, offset, after)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"events": items, "limit": limit, "offset": offset})
+	h.listPage(c, items, limit, offset)
 }
 
 func (h *EventsHandler) listUpcoming(c *gin.Context) {
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
 	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	after, ok := h.listPosition(c)
+	if !ok {
+		return
+	}
+	if after != nil {
+		offset = 0
+	}
 
-	items, err := h.svc.ListUpcoming(c.Request.Context(), limit, offset)
+	items, err := h.svc.ListUpcoming(c.Request.Context(), limit+1, offset, after)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"events": items, "limit": limit, "offset": offset})
+	h.listPage(c, items, limit, offset)
 }
 
 func (h *EventsHandler) listPopular(c *gin.Context) {
@@ -92,60 +281,174 @@ func (h *EventsHandler) listPopular(c *gin.Context) {
 
 	items, err := h.svc.ListPopular(c.Request.Context(), limit, offset)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"events": items, "limit": limit, "offset": offset})
+	if !h.localize(c, items...) {
+		return
+	}
+	response.Page(c, "events", items, limit, offset)
 }
 
 func (h *EventsHandler) get(c *gin.Context) {
 	id := c.Param("id")
-	e, rem, err := h.svc.Get(c.Request.Context(), id)
+	// Private events need the invitation code from the invite link
+	e, rem, err := h.svc.Get(c.Request.Context(), id, c.Query("code"))
+	if err != nil {
+		response.JSON(c, http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if e == nil {
+		response.JSON(c, http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	if !h.localize(c, e) {
+		return
+	}
+	// Ended events stay readable as a history page with how they sold
+	attendance, err := h.svc.Attendance(c.Request.Context(), e)
+	if err != nil {
+		h.log.Error("Failed to load event attendance", zap.Error(err), zap.String("event_id", id))
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if attendance != nil {
+		response.JSON(c, http.StatusOK, gin.H{"event": e, "tokens_remaining": rem, "archived": true, "attendance": attendance})
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"event": e, "tokens_remaining": rem, "archived": false})
+}
+
+// localize adds display prices in the currency query parameter's currency, if one was
+// given. On an invalid or unsupported currency it writes a 400 and returns false.
+func (h *EventsHandler) localize(c *gin.Context, evs ...*storeEvents.Event) bool {
+	currency := c.Query("currency")
+	if currency == "" {
+		return true
+	}
+	err := h.svc.Localize(c.Request.Context(), currency, evs...)
+	switch {
+	case err == nil:
+		return true
+	case err == fx.ErrInvalidCurrency || err == fx.ErrUnsupportedCurrency:
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error(), "currency": currency})
+	default:
+		h.log.Error("Failed to convert display prices", zap.Error(err))
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+	return false
+}
+
+// stats serves the event's public stats for social proof on marketing pages.
+func (h *EventsHandler) stats(c *gin.Context) {
+	id := c.Param("id")
+	st, err := h.svc.Stats(c.Request.Context(), id, c.Query("code"))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		h.log.Error("Failed to load event stats", zap.Error(err), zap.String("event_id", id))
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	if st == nil {
+		response.JSON(c, http.StatusNotFound, gin.H{"error": "Event not found"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"event": e, "tokens_remaining": rem})
+	response.JSON(c, http.StatusOK, st)
 }
 
-func (h *EventsHandler) getAvailableSeats(c *gin.Context) {
+func (h *EventsHandler) getSeatMap(c *gin.Context) {
 	id := c.Param("id")
-	seats, err := h.svc.GetAvailableSeats(c.Request.Context(), id)
+	seatMap, err := h.svc.GetSeatMap(c.Request.Context(), id, c.Query("code"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		response.JSON(c, featureErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"seats": seats})
+	response.JSON(c, http.StatusOK, seatMap)
 }
 
 func (h *EventsHandler) likeEvent(c *gin.Context) {
 	id := c.Param("id")
 	userID := c.GetString("uid")
 	if userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		response.JSON(c, http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
 	err := h.svc.LikeEvent(c.Request.Context(), id, userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		response.JSON(c, featureErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Event liked successfully"})
+	response.JSON(c, http.StatusOK, gin.H{"message": "Event liked successfully"})
 }
 
 func (h *EventsHandler) unlikeEvent(c *gin.Context) {
 	id := c.Param("id")
 	userID := c.GetString("uid")
 	if userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		response.JSON(c, http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
 	err := h.svc.UnlikeEvent(c.Request.Context(), id, userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		response.JSON(c, featureErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"message": "Event unliked successfully"})
+}
+
+// featureErrorStatus maps errors from calls that depend on an event's feature toggles.
+// salePhases lists the phases the event is sold in, with which is on sale.
+func (h *EventsHandler) salePhases(c *gin.Context) {
+	phases, err := h.svc.SalePhases(c.Request.Context(), c.Param("id"), c.Query("code"))
+	if err != nil {
+		response.JSON(c, featureErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"phases": phases})
+}
+
+func featureErrorStatus(err error) int {
+	switch err {
+	case events.ErrEventNotFound:
+		return http.StatusNotFound
+	case events.ErrLikesDisabled, events.ErrSeatSelectionDisabled:
+		return http.StatusForbidden
+	case events.ErrEventArchived:
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+type redeemInvitationRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// redeemInvitation claims an invitation to a private event for the signed-in user.
+func (h *EventsHandler) redeemInvitation(c *gin.Context) {
+	id := c.Param("id")
+	userID := c.GetString("uid")
+	if userID == "" {
+		response.JSON(c, http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	var req redeemInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	inv, err := h.svc.RedeemInvitation(c.Request.Context(), id, userID, req.Code)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch err {
+		case events.ErrInvitationNotFound:
+			status = http.StatusNotFound
+		case events.ErrInvitationNotYours:
+			status = http.StatusForbidden
+		}
+		response.JSON(c, status, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Event unliked successfully"})
+	response.JSON(c, http.StatusOK, inv)
 }
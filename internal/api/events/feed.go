@@ -0,0 +1,311 @@
+package events
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/cursor"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/service/events"
+	storeEvents "github.com/samirwankhede/lewly-pgpyewj/internal/store/events"
+)
+
+// feedMaxAge is how long aggregators and caches may reuse a feed response without asking;
+// after that a conditional request costs a query but no body.
+const feedMaxAge = 60
+
+// FeedHandler serves the public event catalog for syndication: JSON, Atom and RSS views of
+// the same pages. Responses have the same shape whatever Accept-Version says, carry an ETag
+// and answer If-None-Match with 304.
+type FeedHandler struct {
+	log     *zap.Logger
+	svc     *events.EventsService
+	baseURL string
+	limit   gin.HandlerFunc
+	cursors *cursor.Codec
+}
+
+// NewFeedHandler serves the feed; baseURL is the public API address entries link to.
+func NewFeedHandler(log *zap.Logger, svc *events.EventsService, baseURL string) *FeedHandler {
+	return &FeedHandler{log: log, svc: svc, baseURL: strings.TrimRight(baseURL, "/")}
+}
+
+// WithCursors pages the feed with cursors encoded by codec; without it only the first page
+// is served.
+func (h *FeedHandler) WithCursors(codec *cursor.Codec) *FeedHandler {
+	h.cursors = codec
+	return h
+}
+
+// WithRateLimit adds limit to the feed routes, on top of the global limit.
+func (h *FeedHandler) WithRateLimit(limit gin.HandlerFunc) *FeedHandler {
+	h.limit = limit
+	return h
+}
+
+func (h *FeedHandler) Register(r *gin.Engine) {
+	feed := r.Group("/v1/feed")
+	if h.limit != nil {
+		feed.Use(h.limit)
+	}
+	{
+		feed.GET("/events.json", h.serve("json"))
+		feed.GET("/events.atom", h.serve("atom"))
+		feed.GET("/events.rss", h.serve("rss"))
+	}
+}
+
+func (h *FeedHandler) serve(format string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(events.DefaultFeedLimit)))
+		if limit < 1 || limit > events.MaxFeedLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", events.MaxFeedLimit)})
+			return
+		}
+		var since *time.Time
+		if v := c.Query("updated_since"); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "updated_since must be an RFC 3339 timestamp"})
+				return
+			}
+			since = &t
+		}
+		var pos storeEvents.FeedPosition
+		given, ok := h.cursors.Bind(c, &pos)
+		if !ok {
+			return
+		}
+		var after *storeEvents.FeedPosition
+		if given {
+			after = &pos
+		}
+
+		page, err := h.svc.Feed(c.Request.Context(), since, after, limit)
+		if err != nil {
+			h.log.Error("Failed to build event feed", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build feed"})
+			return
+		}
+		// Cursors are re-sealed with a fresh expiry each time, so the ETag is taken over what
+		// the page holds rather than its bytes
+		etag := feedETag(format, page)
+		c.Header("ETag", etag)
+		c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", feedMaxAge))
+		if etagMatches(c.GetHeader("If-None-Match"), etag) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+
+		// A nil *FeedPosition in an any isn't nil
+		var position any
+		if page.Next != nil {
+			position = page.Next
+		}
+		next, err := h.cursors.Next(position)
+		if err != nil {
+			h.log.Error("Failed to encode feed cursor", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build feed"})
+			return
+		}
+		nextURL := ""
+		if next != "" {
+			q := c.Request.URL.Query()
+			q.Set(cursor.QueryParam, next)
+			nextURL = h.baseURL + c.Request.URL.Path + "?" + q.Encode()
+			c.Header("Link", "<"+nextURL+">; rel=\"next\"")
+		}
+
+		var body []byte
+		var contentType string
+		switch format {
+		case "atom":
+			body, err = h.atom(page, c.Request.URL.Path, nextURL)
+			contentType = "application/atom+xml; charset=utf-8"
+		case "rss":
+			body, err = h.rss(page)
+			contentType = "application/rss+xml; charset=utf-8"
+		default:
+			body, err = h.json(page, next)
+			contentType = "application/json; charset=utf-8"
+		}
+		if err != nil {
+			h.log.Error("Failed to encode event feed", zap.Error(err), zap.String("format", format))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build feed"})
+			return
+		}
+		c.Data(http.StatusOK, contentType, body)
+	}
+}
+
+// feedETag identifies a page by its format and each event's ID and updated_at, which
+// change whenever anything the feed shows does.
+func feedETag(format string, page *events.FeedPage) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s|%t", format, page.Next != nil)
+	if page.SyncFrom != nil {
+		fmt.Fprintf(h, "|%d", page.SyncFrom.UnixNano())
+	}
+	for _, e := range page.Events {
+		fmt.Fprintf(h, "|%s@%d", e.ID, e.UpdatedAt.UnixNano())
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, weakly compared.
+func etagMatches(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *FeedHandler) eventURL(id string) string {
+	return h.baseURL + "/v1/events/" + url.PathEscape(id)
+}
+
+func (h *FeedHandler) json(page *events.FeedPage, next string) ([]byte, error) {
+	type jsonEvent struct {
+		*events.FeedEvent
+		URL string `json:"url"`
+	}
+	out := struct {
+		Events []jsonEvent `json:"events"`
+		// NextCursor fetches the next page; empty on the last one
+		NextCursor string `json:"next_cursor,omitempty"`
+		// NextUpdatedSince starts the next delta sync; set on the last page
+		NextUpdatedSince *time.Time `json:"next_updated_since,omitempty"`
+	}{Events: make([]jsonEvent, len(page.Events)), NextCursor: next, NextUpdatedSince: page.SyncFrom}
+	for i, e := range page.Events {
+		out.Events[i] = jsonEvent{FeedEvent: e, URL: h.eventURL(e.ID)}
+	}
+	return json.Marshal(out)
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	ID        string        `xml:"id"`
+	Title     string        `xml:"title"`
+	Updated   string        `xml:"updated"`
+	Published string        `xml:"published"`
+	Link      atomLink      `xml:"link"`
+	Category  *atomCategory `xml:"category,omitempty"`
+	Summary   string        `xml:"summary"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+// atom renders the page as an Atom feed. Its updated time is the newest entry's, so the
+// same page always renders to the same bytes and keeps its ETag.
+func (h *FeedHandler) atom(page *events.FeedPage, path, next string) ([]byte, error) {
+	feed := atomFeed{
+		ID:      h.baseURL + path,
+		Title:   "Evently events",
+		Updated: time.Unix(0, 0).UTC().Format(time.RFC3339),
+		Links:   []atomLink{{Rel: "self", Href: h.baseURL + path}},
+	}
+	if next != "" {
+		feed.Links = append(feed.Links, atomLink{Rel: "next", Href: next})
+	}
+	for _, e := range page.Events {
+		entry := atomEntry{
+			ID:        "urn:uuid:" + e.ID,
+			Title:     e.Name,
+			Updated:   e.UpdatedAt.UTC().Format(time.RFC3339Nano),
+			Published: e.CreatedAt.UTC().Format(time.RFC3339Nano),
+			Link:      atomLink{Href: h.eventURL(e.ID)},
+			Summary:   feedSummary(e),
+		}
+		if e.Category != "" {
+			entry.Category = &atomCategory{Term: e.Category}
+		}
+		feed.Entries = append(feed.Entries, entry)
+		feed.Updated = entry.Updated
+	}
+	return marshalXML(feed)
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Category    string  `xml:"category,omitempty"`
+	Description string  `xml:"description"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name `xml:"rss"`
+	Version string   `xml:"version,attr"`
+	Channel struct {
+		Title       string    `xml:"title"`
+		Link        string    `xml:"link"`
+		Description string    `xml:"description"`
+		Items       []rssItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+// rss renders the page as RSS 2.0. RSS has no next-page link; the Link header carries it.
+func (h *FeedHandler) rss(page *events.FeedPage) ([]byte, error) {
+	feed := rssFeed{Version: "2.0"}
+	feed.Channel.Title = "Evently events"
+	feed.Channel.Link = h.baseURL + "/v1/events"
+	feed.Channel.Description = "Public events, most recently updated last"
+	for _, e := range page.Events {
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title: e.Name,
+			Link:  h.eventURL(e.ID),
+			// A new GUID per update, so readers show a changed event again
+			GUID:        rssGUID{Value: e.ID + "@" + e.UpdatedAt.UTC().Format(time.RFC3339Nano)},
+			PubDate:     e.UpdatedAt.UTC().Format(time.RFC1123Z),
+			Category:    e.Category,
+			Description: feedSummary(e),
+		})
+	}
+	return marshalXML(feed)
+}
+
+func feedSummary(e *events.FeedEvent) string {
+	return fmt.Sprintf("%s, %s. %s %.2f. Status: %s.", e.Venue, e.StartTime.UTC().Format(time.RFC1123), e.Currency, e.TicketPrice, e.Status)
+}
+
+func marshalXML(v any) ([]byte, error) {
+	body, err := xml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
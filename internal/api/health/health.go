@@ -0,0 +1,38 @@
+package health
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/health"
+)
+
+// HealthHandler serves the probe endpoints. They answer in the same shape whatever
+// Accept-Version says, since load balancers and orchestrators read them, not clients.
+type HealthHandler struct {
+	checker *health.Checker
+}
+
+func NewHealthHandler(checker *health.Checker) *HealthHandler {
+	return &HealthHandler{checker: checker}
+}
+
+func (h *HealthHandler) Register(r *gin.Engine) {
+	r.GET("/healthz", h.live)
+	r.GET("/readyz", h.ready)
+}
+
+// live answers as long as the process serves HTTP; restarting it won't fix a dependency.
+func (h *HealthHandler) live(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func (h *HealthHandler) ready(c *gin.Context) {
+	report := h.checker.Ready(c.Request.Context())
+	status := http.StatusOK
+	if report.Status != health.StatusReady {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
@@ -0,0 +1,66 @@
+package milestones
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/api/response"
+	jwtMiddleware "github.com/samirwankhede/lewly-pgpyewj/internal/middleware"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/service/milestones"
+)
+
+type MilestonesHandler struct {
+	log    *zap.Logger
+	svc    *milestones.MilestonesService
+	secret string
+}
+
+func NewMilestonesHandler(log *zap.Logger, svc *milestones.MilestonesService, secret string) *MilestonesHandler {
+	return &MilestonesHandler{log: log, svc: svc, secret: secret}
+}
+
+func (h *MilestonesHandler) Register(r *gin.Engine) {
+	admin := r.Group("/admin/events")
+	admin.Use(jwtMiddleware.Middleware(h.secret, true))
+	{
+		admin.GET("/:id/milestones", h.list)
+		admin.PUT("/:id/milestones", h.set)
+	}
+}
+
+func (h *MilestonesHandler) list(c *gin.Context) {
+	eventID := c.Param("id")
+	ms, err := h.svc.List(c.Request.Context(), eventID)
+	if err != nil {
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"event_id": eventID, "milestones": ms})
+}
+
+func (h *MilestonesHandler) set(c *gin.Context) {
+	eventID := c.Param("id")
+	var in struct {
+		Milestones []milestones.MilestoneInput `json:"milestones" binding:"dive"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ms, err := h.svc.Set(c.Request.Context(), eventID, in.Milestones)
+	if err != nil {
+		switch err {
+		case milestones.ErrEventNotFound:
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Event not found"})
+		case milestones.ErrInvalidMilestone:
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": "milestone percents must be unique between 1 and 100 and webhook_url an http(s) URL"})
+		default:
+			h.log.Error("Set milestones failed", zap.Error(err))
+			response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"event_id": eventID, "milestones": ms})
+}
@@ -0,0 +1,183 @@
+package organizers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/api/response"
+	jwtMiddleware "github.com/samirwankhede/lewly-pgpyewj/internal/middleware"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/service/admin"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/service/fx"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store"
+)
+
+// ConsoleHandler serves the organizer console: organizers manage and analyze the events
+// they own, co-organizers what they were given on the events they were invited to, and
+// nothing else.
+type ConsoleHandler struct {
+	log    *zap.Logger
+	svc    *admin.AdminService
+	secret string
+}
+
+func NewConsoleHandler(log *zap.Logger, svc *admin.AdminService, secret string) *ConsoleHandler {
+	return &ConsoleHandler{log: log, svc: svc, secret: secret}
+}
+
+func (h *ConsoleHandler) Register(r *gin.Engine) {
+	g := r.Group("/v1/organizer")
+	g.Use(jwtMiddleware.UserMiddleware(h.secret))
+
+	organizer := g.Group("")
+	organizer.Use(jwtMiddleware.RequireOrganizer())
+	{
+		organizer.POST("/events", h.createEvent)
+		organizer.GET("/events", h.events)
+		organizer.GET("/analytics", h.analytics)
+	}
+
+	// Cancelling the event and choosing its co-organizers stay with the owner
+	owned := organizer.Group("/events/:id")
+	owned.Use(jwtMiddleware.RequireEventOwner(h.svc.EventOwnership))
+	{
+		owned.POST("/cancel", h.cancelEvent)
+		owned.GET("/members", h.members)
+		owned.POST("/members", h.inviteMember)
+		owned.PUT("/members/:memberId", h.updateMember)
+		owned.DELETE("/members/:memberId", h.removeMember)
+	}
+
+	// Co-organizers reach the rest with the permission it needs, whatever their role
+	shared := g.Group("/events/:id")
+	{
+		shared.PUT("", jwtMiddleware.RequireEventPermission(h.svc.EventAccess, jwtMiddleware.PermEditEvent), h.updateEvent)
+		shared.GET("/analytics", jwtMiddleware.RequireEventPermission(h.svc.EventAccess, jwtMiddleware.PermViewAnalytics), h.eventAnalytics)
+		shared.GET("/sales-curve", jwtMiddleware.RequireEventPermission(h.svc.EventAccess, jwtMiddleware.PermViewAnalytics), h.salesCurve)
+	}
+
+	g.GET("/memberships", h.memberships)
+	g.GET("/member-invites/:token", h.previewMemberInvite)
+	g.POST("/member-invites/:token/accept", h.acceptMemberInvite)
+}
+
+func (h *ConsoleHandler) createEvent(c *gin.Context) {
+	var in admin.AdminEvent
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	e, err := h.svc.CreateOwnedEvent(c.Request.Context(), c.GetString("uid"), in)
+	if err != nil {
+		if errors.Is(err, admin.ErrInvalidSeats) || err == fx.ErrInvalidCurrency || err == admin.ErrInvalidVisibility || err == admin.ErrInvalidPaymentCapture || err == admin.ErrSandboxPublic {
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err == admin.ErrDuplicateEvent && e != nil {
+			response.JSON(c, http.StatusConflict, gin.H{"error": err.Error(), "existing_event_id": e.ID, "hint": "set allow_duplicate to create it anyway"})
+			return
+		}
+		h.log.Error("Create owned event failed", zap.Error(err))
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	response.JSON(c, http.StatusCreated, e)
+}
+
+func (h *ConsoleHandler) events(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	evs, err := h.svc.OwnedEvents(c.Request.Context(), c.GetString("uid"), limit, offset)
+	if err != nil {
+		h.log.Error("List owned events failed", zap.Error(err))
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"events": evs})
+}
+
+// analytics computes the comparison metrics of the organizer's events, paged like events.
+func (h *ConsoleHandler) analytics(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	res, err := h.svc.OwnedAnalytics(c.Request.Context(), c.GetString("uid"), limit, offset)
+	if err != nil {
+		h.log.Error("Owned analytics failed", zap.Error(err))
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"events": res})
+}
+
+func (h *ConsoleHandler) updateEvent(c *gin.Context) {
+	var updates map[string]interface{}
+	if err := c.ShouldBindJSON(&updates); err != nil {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err := h.svc.UpdateOwnedEvent(c.Request.Context(), c.Param("id"), updates)
+	if err != nil {
+		var colErr *store.ColumnError
+		if err == admin.ErrOrganizerField {
+			response.JSON(c, http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if err == admin.ErrInvalidVisibility || err == admin.ErrInvalidPaymentCapture || err == admin.ErrSandboxPublic || err == store.ErrNoUpdates || errors.As(err, &colErr) {
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		h.log.Error("Update owned event failed", zap.Error(err))
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"message": "Event updated successfully"})
+}
+
+func (h *ConsoleHandler) cancelEvent(c *gin.Context) {
+	job, err := h.svc.CancelEvent(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if err == admin.ErrEventNotFound {
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Event not found"})
+			return
+		}
+		h.log.Error("Cancel owned event failed", zap.Error(err))
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	response.JSON(c, http.StatusAccepted, job)
+}
+
+func (h *ConsoleHandler) eventAnalytics(c *gin.Context) {
+	res, err := h.svc.EventAnalytics(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if err == admin.ErrEventNotFound {
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Event not found"})
+			return
+		}
+		h.log.Error("Event analytics failed", zap.Error(err))
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	response.JSON(c, http.StatusOK, res)
+}
+
+func (h *ConsoleHandler) salesCurve(c *gin.Context) {
+	curve, err := h.svc.SalesCurve(c.Request.Context(), c.Param("id"), c.DefaultQuery("interval", "day"), c.DefaultQuery("tz", "UTC"))
+	if err != nil {
+		if errors.Is(err, admin.ErrInvalidSalesCurve) {
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err == admin.ErrEventNotFound {
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Event not found"})
+			return
+		}
+		h.log.Error("Sales curve failed", zap.Error(err))
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	response.JSON(c, http.StatusOK, curve)
+}
@@ -0,0 +1,131 @@
+package organizers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/api/response"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/service/admin"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/members"
+)
+
+type memberRequest struct {
+	Email       string   `json:"email"`
+	Permissions []string `json:"permissions"`
+}
+
+func (h *ConsoleHandler) members(c *gin.Context) {
+	list, err := h.svc.EventMembers(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.log.Error("List event members failed", zap.Error(err))
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"event_id": c.Param("id"), "members": list})
+}
+
+// inviteMember emails an invitation to co-organize the event.
+func (h *ConsoleHandler) inviteMember(c *gin.Context) {
+	var req memberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	m, err := h.svc.InviteMember(c.Request.Context(), c.Param("id"), c.GetString("uid"), req.Email, req.Permissions)
+	if err != nil {
+		switch {
+		case errors.Is(err, admin.ErrInvalidMember):
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		case err == admin.ErrEventNotFound:
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Event not found"})
+		case err == admin.ErrMemberIsOwner, err == members.ErrMemberExists:
+			response.JSON(c, http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			h.log.Error("Invite event member failed", zap.Error(err))
+			response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+	response.JSON(c, http.StatusCreated, m)
+}
+
+func (h *ConsoleHandler) updateMember(c *gin.Context) {
+	var req memberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	m, err := h.svc.UpdateMember(c.Request.Context(), c.Param("id"), c.Param("memberId"), req.Permissions)
+	if err != nil {
+		switch {
+		case errors.Is(err, admin.ErrInvalidMember):
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		case err == admin.ErrMemberNotFound:
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Member not found"})
+		default:
+			h.log.Error("Update event member failed", zap.Error(err))
+			response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+	response.JSON(c, http.StatusOK, m)
+}
+
+func (h *ConsoleHandler) removeMember(c *gin.Context) {
+	if err := h.svc.RemoveMember(c.Request.Context(), c.Param("id"), c.Param("memberId")); err != nil {
+		if err == admin.ErrMemberNotFound {
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Member not found"})
+			return
+		}
+		h.log.Error("Remove event member failed", zap.Error(err))
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"message": "Member removed"})
+}
+
+// memberships lists the events the user co-organizes, with what they may do on each.
+func (h *ConsoleHandler) memberships(c *gin.Context) {
+	list, err := h.svc.Memberships(c.Request.Context(), c.GetString("uid"))
+	if err != nil {
+		h.log.Error("List memberships failed", zap.Error(err))
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"memberships": list})
+}
+
+func (h *ConsoleHandler) previewMemberInvite(c *gin.Context) {
+	p, err := h.svc.PreviewMemberInvite(c.Request.Context(), c.Param("token"), c.GetString("uid"))
+	if err != nil {
+		h.memberInviteError(c, err)
+		return
+	}
+	response.JSON(c, http.StatusOK, p)
+}
+
+func (h *ConsoleHandler) acceptMemberInvite(c *gin.Context) {
+	m, err := h.svc.AcceptMemberInvite(c.Request.Context(), c.Param("token"), c.GetString("uid"))
+	if err != nil {
+		h.memberInviteError(c, err)
+		return
+	}
+	response.JSON(c, http.StatusOK, m)
+}
+
+func (h *ConsoleHandler) memberInviteError(c *gin.Context, err error) {
+	switch err {
+	case admin.ErrMemberNotFound:
+		response.JSON(c, http.StatusNotFound, gin.H{"error": "Invitation not found"})
+	case admin.ErrMemberRecipient:
+		response.JSON(c, http.StatusForbidden, gin.H{"error": err.Error()})
+	case admin.ErrMemberInviteClosed, members.ErrMemberExists:
+		response.JSON(c, http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		h.log.Error("Member invitation failed", zap.Error(err))
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	}
+}
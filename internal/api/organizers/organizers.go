@@ -0,0 +1,104 @@
+package organizers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/api/response"
+	jwtMiddleware "github.com/samirwankhede/lewly-pgpyewj/internal/middleware"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/service/organizers"
+)
+
+type OrganizersHandler struct {
+	log    *zap.Logger
+	svc    *organizers.OrganizersService
+	secret string
+}
+
+func NewOrganizersHandler(log *zap.Logger, svc *organizers.OrganizersService, secret string) *OrganizersHandler {
+	return &OrganizersHandler{log: log, svc: svc, secret: secret}
+}
+
+func (h *OrganizersHandler) Register(r *gin.Engine) {
+	r.GET("/v1/organizers/:id", h.get)
+
+	protected := r.Group("/v1/organizers")
+	protected.Use(jwtMiddleware.Middleware(h.secret, false))
+	{
+		protected.POST("/:id/follow", h.follow)
+		protected.DELETE("/:id/follow", h.unfollow)
+	}
+
+	admin := r.Group("/admin/organizers")
+	admin.Use(jwtMiddleware.Middleware(h.secret, true))
+	{
+		admin.POST("", h.create)
+	}
+}
+
+func (h *OrganizersHandler) get(c *gin.Context) {
+	id := c.Param("id")
+	profile, err := h.svc.GetProfile(c.Request.Context(), id)
+	if err != nil {
+		if err == organizers.ErrOrganizerNotFound {
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Organizer not found"})
+			return
+		}
+		h.log.Error("Get organizer failed", zap.Error(err))
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	response.JSON(c, http.StatusOK, profile)
+}
+
+func (h *OrganizersHandler) follow(c *gin.Context) {
+	id := c.Param("id")
+	userID := c.GetString("uid")
+	if userID == "" {
+		response.JSON(c, http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	err := h.svc.Follow(c.Request.Context(), id, userID)
+	if err != nil {
+		if err == organizers.ErrOrganizerNotFound {
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Organizer not found"})
+			return
+		}
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"message": "Organizer followed successfully"})
+}
+
+func (h *OrganizersHandler) unfollow(c *gin.Context) {
+	id := c.Param("id")
+	userID := c.GetString("uid")
+	if userID == "" {
+		response.JSON(c, http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	err := h.svc.Unfollow(c.Request.Context(), id, userID)
+	if err != nil {
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"message": "Organizer unfollowed successfully"})
+}
+
+func (h *OrganizersHandler) create(c *gin.Context) {
+	var req organizers.CreateOrganizerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	o, err := h.svc.Create(c.Request.Context(), req)
+	if err != nil {
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	response.JSON(c, http.StatusCreated, o)
+}
@@ -1,33 +1,125 @@
 package payment
 
 import (
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 
+	"github.com/samirwankhede/lewly-pgpyewj/internal/api/response"
 	jwtMiddleware "github.com/samirwankhede/lewly-pgpyewj/internal/middleware"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/payments"
+	redisx "github.com/samirwankhede/lewly-pgpyewj/internal/redis"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/service/payment"
+	storePayments "github.com/samirwankhede/lewly-pgpyewj/internal/store/payments"
 )
 
 type PaymentHandler struct {
-	log    *zap.Logger
-	svc    *payment.PaymentService
-	secret string
+	log              *zap.Logger
+	svc              *payment.PaymentService
+	secret           string
+	webhookSecrets   jwtMiddleware.WebhookSecrets
+	webhookTolerance time.Duration
+	// access is nil unless event owners and co-organizers refund their events
+	access jwtMiddleware.EventAccess
 }
 
-func NewPaymentHandler(log *zap.Logger, svc *payment.PaymentService, secret string) *PaymentHandler {
-	return &PaymentHandler{log: log, svc: svc, secret: secret}
+func NewPaymentHandler(log *zap.Logger, svc *payment.PaymentService, secret string, webhookSecrets jwtMiddleware.WebhookSecrets, webhookTolerance time.Duration) *PaymentHandler {
+	return &PaymentHandler{log: log, svc: svc, secret: secret, webhookSecrets: webhookSecrets, webhookTolerance: webhookTolerance}
+}
+
+// PaymentWebhook is the provider-neutral body of a payment webhook.
+type PaymentWebhook struct {
+	Type      string  `json:"type" binding:"required"`
+	BookingID string  `json:"booking_id" binding:"required"`
+	Amount    float64 `json:"amount"`
+	PaymentID string  `json:"payment_id"`
+}
+
+// WithEventAccess lets event owners, and co-organizers with the trigger_refunds permission,
+// refund their event's paid bookings from the organizer console as admins do.
+func (h *PaymentHandler) WithEventAccess(access jwtMiddleware.EventAccess) *PaymentHandler {
+	h.access = access
+	return h
 }
 
 func (h *PaymentHandler) Register(r *gin.Engine) {
 	payments := r.Group("/v1/payment")
 	payments.GET("/booking", h.processBookingPayment)
 	payments.GET("/refund", h.processRefund)
+	payments.POST("/webhooks/:provider", jwtMiddleware.WebhookSignature(h.webhookSecrets, h.webhookTolerance), h.webhook)
+	payments.POST("/webhook", jwtMiddleware.ProviderWebhookSignature("stripe", h.webhookSecrets, h.webhookTolerance), h.stripeWebhook)
+	payments.POST("/intents", jwtMiddleware.Middleware(h.secret, false), h.createIntent)
+	payments.POST("/extend", jwtMiddleware.Middleware(h.secret, false), h.extendHold)
 	payments.Use(jwtMiddleware.Middleware(h.secret, true))
 	{
 		payments.POST("/events/:id/refund", h.processEventCancellationRefund)
+		payments.POST("/events/:id/capture", h.captureEventPayments)
+		payments.POST("/bookings/:id/capture", h.capturePayment)
+	}
+
+	if h.access != nil {
+		r.POST("/v1/organizer/events/:id/refund", jwtMiddleware.UserMiddleware(h.secret), jwtMiddleware.RequireEventPermission(h.access, jwtMiddleware.PermRefunds), h.processEventCancellationRefund)
+	}
+}
+
+// Webhook types a provider sends while the buyer is still completing a payment (e.g. a 3DS
+// challenge); they extend the booking's payment window.
+var paymentInProgressTypes = map[string]bool{
+	"payment.processing":      true,
+	"payment.requires_action": true,
+}
+
+// extendHold lets the payment page ask for more time once the provider reports the
+// payment is in progress. Only the booking's owner can extend it.
+func (h *PaymentHandler) extendHold(c *gin.Context) {
+	userID := c.GetString("uid")
+	if userID == "" {
+		response.JSON(c, http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	var in struct {
+		BookingID string `json:"booking_id" binding:"required"`
+		// Seconds defaults to the maximum extension
+		Seconds int `json:"seconds" binding:"omitempty,gt=0"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ext, err := h.svc.ExtendHold(c.Request.Context(), in.BookingID, userID, time.Duration(in.Seconds)*time.Second)
+	if err != nil {
+		status := extendErrorStatus(err)
+		if status == http.StatusInternalServerError {
+			h.log.Error("Payment window extension failed", zap.Error(err), zap.String("booking_id", in.BookingID))
+			response.JSON(c, status, gin.H{"error": "Internal server error"})
+			return
+		}
+		response.JSON(c, status, gin.H{"error": err.Error()})
+		return
+	}
+	response.JSON(c, http.StatusOK, ext)
+}
+
+func extendErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, payment.ErrBookingNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, payment.ErrInvalidExtension):
+		return http.StatusBadRequest
+	case errors.Is(err, payment.ErrAlreadyPaid), errors.Is(err, payment.ErrNotPending),
+		errors.Is(err, redisx.ErrAlreadyExtended), errors.Is(err, redisx.ErrNoPaymentWindow),
+		errors.Is(err, redisx.ErrPaymentWindowOver):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
 	}
 }
 
@@ -41,69 +133,279 @@ func (h *PaymentHandler) processBookingPayment(c *gin.Context) {
 		PaymentID: payment_id,
 	}
 	if amt == float64(-1) || err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Error with amount parameter"})
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": "Error with amount parameter"})
 		return
 	}
 
 	resp, err := h.svc.ProcessBookingPayment(c.Request.Context(), req)
 	if err != nil {
 		if err == payment.ErrBookingNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Booking not found"})
 			return
 		}
 		if err == payment.ErrInvalidAmount {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amount"})
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": "Invalid amount"})
 			return
 		}
 		if err == payment.ErrAlreadyPaid {
-			c.JSON(http.StatusConflict, gin.H{"error": "Booking already paid"})
+			response.JSON(c, http.StatusConflict, gin.H{"error": "Booking already paid"})
+			return
+		}
+		if err == payment.ErrInvalidSeats || err == payment.ErrTransactionReused || err == payment.ErrPaymentInProgress {
+			response.JSON(c, http.StatusConflict, gin.H{"error": err.Error()})
 			return
 		}
 		h.log.Error("Payment processing failed", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
 		return
 	}
 
 	if resp.Success {
-		c.JSON(http.StatusOK, resp)
+		response.JSON(c, http.StatusOK, resp)
 	} else {
-		c.JSON(http.StatusPaymentRequired, resp)
+		response.JSON(c, http.StatusPaymentRequired, resp)
+	}
+}
+
+// webhook applies a provider's signed payment notification. Calls only reach it once the
+// signature middleware verified them. Duplicates are acknowledged so providers stop retrying.
+func (h *PaymentHandler) webhook(c *gin.Context) {
+	var in PaymentWebhook
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// The worker's pending-booking and conversion metrics are grouped by provider
+	h.svc.NoteProvider(c.Request.Context(), in.BookingID, c.GetString("webhook_provider"))
+	if paymentInProgressTypes[in.Type] {
+		h.extendFromWebhook(c, in)
+		return
+	}
+	if in.Type != "payment.succeeded" {
+		response.JSON(c, http.StatusOK, gin.H{"message": "ignored", "type": in.Type})
+		return
+	}
+
+	resp, err := h.svc.ProcessBookingPayment(c.Request.Context(), payment.PaymentRequest{
+		BookingID: in.BookingID,
+		Amount:    in.Amount,
+		PaymentID: in.PaymentID,
+	})
+	if err != nil {
+		switch err {
+		case payment.ErrAlreadyPaid:
+			response.JSON(c, http.StatusOK, gin.H{"message": "already processed", "booking_id": in.BookingID})
+		case payment.ErrBookingNotFound:
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Booking not found"})
+		case payment.ErrInvalidAmount:
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": "Invalid amount"})
+		case payment.ErrInvalidSeats, payment.ErrTransactionReused:
+			response.JSON(c, http.StatusConflict, gin.H{"error": err.Error()})
+		case payment.ErrPaymentInProgress:
+			// Not acknowledged, so the provider retries and gets the result once it is in
+			response.JSON(c, http.StatusConflict, gin.H{"error": err.Error(), "booking_id": in.BookingID})
+		default:
+			h.log.Error("Payment webhook failed", zap.Error(err), zap.String("provider", c.GetString("webhook_provider")))
+			response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+	if resp.Success {
+		response.JSON(c, http.StatusOK, resp)
+	} else {
+		response.JSON(c, http.StatusPaymentRequired, resp)
+	}
+}
+
+// stripeWebhook takes Stripe's own PaymentIntent events. Money-moving events are stored and
+// acknowledged at once; the payment service books the booking in the background, so Stripe
+// never times out waiting on it. Redelivered events are acknowledged and dropped.
+func (h *PaymentHandler) stripeWebhook(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": "could not read body"})
+		return
+	}
+	e, pi, err := payments.ParseStripeIntentEvent(body)
+	if err != nil {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if pi == nil {
+		response.JSON(c, http.StatusOK, gin.H{"message": "ignored", "type": e.Type})
+		return
+	}
+	// Only intents created for a booking carry its ID
+	var bookingID *string
+	if id := pi.Metadata["booking_id"]; id != "" {
+		if _, err := uuid.Parse(id); err == nil {
+			bookingID = &id
+			h.svc.NoteProvider(c.Request.Context(), id, "stripe")
+		}
+	}
+
+	switch e.Type {
+	case "payment_intent.processing", "payment_intent.requires_action":
+		if bookingID == nil {
+			break
+		}
+		h.extendFromWebhook(c, PaymentWebhook{Type: e.Type, BookingID: *bookingID, PaymentID: pi.ID})
+		return
+	case payment.EventPaymentSucceeded, payment.EventPaymentAuthorized:
+		amount := pi.AmountReceived
+		if e.Type == payment.EventPaymentAuthorized {
+			amount = pi.AmountCapturable
+		}
+		recorded, err := h.svc.RecordProviderEvent(c.Request.Context(), &storePayments.ProviderEvent{
+			ID:          e.ID,
+			Provider:    "stripe",
+			Type:        e.Type,
+			BookingID:   bookingID,
+			ProviderRef: pi.ID,
+			Amount:      payments.FromMinor(amount, pi.Currency),
+			Currency:    strings.ToUpper(pi.Currency),
+		})
+		if err != nil {
+			h.log.Error("Failed to record Stripe event", zap.Error(err), zap.String("event_id", e.ID))
+			response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+		if !recorded {
+			response.JSON(c, http.StatusOK, gin.H{"message": "already received", "event_id": e.ID})
+			return
+		}
+		response.JSON(c, http.StatusAccepted, gin.H{"message": "accepted", "event_id": e.ID})
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"message": "ignored", "type": e.Type})
+}
+
+// createIntent starts a provider payment for the caller's pending booking and returns the
+// client secret the payment page confirms it with.
+func (h *PaymentHandler) createIntent(c *gin.Context) {
+	userID := c.GetString("uid")
+	if userID == "" {
+		response.JSON(c, http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	var in struct {
+		BookingID string `json:"booking_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	intent, err := h.svc.CreateIntent(c.Request.Context(), in.BookingID, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, payment.ErrBookingNotFound):
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Booking not found"})
+		case errors.Is(err, payment.ErrAlreadyPaid), errors.Is(err, payment.ErrNotPending), errors.Is(err, payment.ErrInvalidSeats):
+			response.JSON(c, http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, payment.ErrIntentsUnsupported):
+			response.JSON(c, http.StatusNotImplemented, gin.H{"error": err.Error()})
+		case errors.Is(err, payments.ErrDeclined):
+			response.JSON(c, http.StatusPaymentRequired, gin.H{"error": err.Error()})
+		default:
+			h.log.Error("Failed to create payment intent", zap.Error(err), zap.String("booking_id", in.BookingID))
+			response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"booking_id": in.BookingID, "intent": intent})
+}
+
+// extendFromWebhook extends the booking's payment window by the maximum. A repeated signal
+// for the same payment is acknowledged like a duplicate, so providers stop retrying.
+func (h *PaymentHandler) extendFromWebhook(c *gin.Context, in PaymentWebhook) {
+	ext, err := h.svc.ExtendHold(c.Request.Context(), in.BookingID, "", 0)
+	switch {
+	case err == nil:
+		response.JSON(c, http.StatusOK, ext)
+	case errors.Is(err, redisx.ErrAlreadyExtended):
+		response.JSON(c, http.StatusOK, gin.H{"message": "already extended", "booking_id": in.BookingID})
+	case extendErrorStatus(err) == http.StatusInternalServerError:
+		h.log.Error("Payment webhook failed", zap.Error(err), zap.String("provider", c.GetString("webhook_provider")))
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	default:
+		response.JSON(c, extendErrorStatus(err), gin.H{"error": err.Error()})
 	}
 }
 
 func (h *PaymentHandler) processRefund(c *gin.Context) {
 	BookingID := c.Query("booking_id")
 	if BookingID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Booking not found"})
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": "Booking not found"})
 	}
 
 	resp, err := h.svc.ProcessCancellationRefund(c.Request.Context(), BookingID)
 	if err != nil {
 		if err == payment.ErrBookingNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Booking not found"})
 			return
 		}
 		h.log.Error("Refund processing failed", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
 		return
 	}
 
 	if resp.Success {
-		c.JSON(http.StatusOK, resp)
+		response.JSON(c, http.StatusOK, resp)
 	} else {
-		c.JSON(http.StatusPaymentRequired, resp)
+		response.JSON(c, http.StatusPaymentRequired, resp)
 	}
 }
 
 func (h *PaymentHandler) processEventCancellationRefund(c *gin.Context) {
 	eventID := c.Param("id")
 
-	err := h.svc.ProcessEventCancellationRefund(c.Request.Context(), eventID)
+	job, err := h.svc.ProcessEventCancellationRefund(c.Request.Context(), eventID)
 	if err != nil {
+		if err == payment.ErrEventNotFound {
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Event not found"})
+			return
+		}
 		h.log.Error("Event cancellation refund failed", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Event cancellation refunds processed successfully"})
+	// Refunds run in the background; poll /admin/jobs/:id for progress
+	response.JSON(c, http.StatusAccepted, job)
+}
+
+// captureEventPayments charges every authorization of a manual-capture event, for an
+// organizer confirming it before its capture time.
+func (h *PaymentHandler) captureEventPayments(c *gin.Context) {
+	job, err := h.svc.CaptureEventPayments(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if err == payment.ErrEventNotFound {
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Event not found"})
+			return
+		}
+		h.log.Error("Event payment capture failed", zap.Error(err))
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	// Captures run in the background; poll /admin/jobs/:id for progress
+	response.JSON(c, http.StatusAccepted, job)
+}
+
+// capturePayment charges one booking's authorization, for an organizer confirming it.
+func (h *PaymentHandler) capturePayment(c *gin.Context) {
+	p, err := h.svc.CapturePayment(c.Request.Context(), c.Param("id"))
+	switch {
+	case err == nil:
+		response.JSON(c, http.StatusOK, p)
+	case errors.Is(err, payment.ErrBookingNotFound):
+		response.JSON(c, http.StatusNotFound, gin.H{"error": "Booking not found"})
+	case errors.Is(err, payment.ErrAlreadyPaid), errors.Is(err, payment.ErrNotAuthorized):
+		response.JSON(c, http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, payment.ErrPaymentFailed):
+		response.JSON(c, http.StatusPaymentRequired, gin.H{"error": err.Error()})
+	default:
+		h.log.Error("Payment capture failed", zap.Error(err), zap.String("booking_id", c.Param("id")))
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	}
 }
@@ -0,0 +1,60 @@
+package paymentlinks
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/api/response"
+	jwtMiddleware "github.com/samirwankhede/lewly-pgpyewj/internal/middleware"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/service/paymentlinks"
+)
+
+type PaymentLinksHandler struct {
+	log    *zap.Logger
+	svc    *paymentlinks.PaymentLinksService
+	secret string
+}
+
+func NewPaymentLinksHandler(log *zap.Logger, svc *paymentlinks.PaymentLinksService, secret string) *PaymentLinksHandler {
+	return &PaymentLinksHandler{log: log, svc: svc, secret: secret}
+}
+
+func (h *PaymentLinksHandler) Register(r *gin.Engine) {
+	r.GET("/p/:code", h.resolve)
+
+	admin := r.Group("/admin/bookings")
+	admin.Use(jwtMiddleware.Middleware(h.secret, true))
+	{
+		admin.GET("/:id/payment-link-clicks", h.clicks)
+	}
+}
+
+func (h *PaymentLinksHandler) resolve(c *gin.Context) {
+	target, err := h.svc.Resolve(c.Request.Context(), c.Param("code"), c.Request.UserAgent())
+	if err != nil {
+		if err == paymentlinks.ErrLinkNotFound {
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Payment link not found"})
+			return
+		}
+		if err == paymentlinks.ErrLinkExpired {
+			response.JSON(c, http.StatusGone, gin.H{"error": "Payment link expired"})
+			return
+		}
+		h.log.Error("Resolve payment link failed", zap.Error(err))
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.Redirect(http.StatusFound, target)
+}
+
+func (h *PaymentLinksHandler) clicks(c *gin.Context) {
+	bookingID := c.Param("id")
+	clicks, err := h.svc.ListClicks(c.Request.Context(), bookingID)
+	if err != nil {
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"booking_id": bookingID, "clicks": clicks})
+}
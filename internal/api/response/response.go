@@ -0,0 +1,128 @@
+// Package response writes handler responses in either the original per-endpoint shapes (v1)
+// or the uniform v2 envelope, chosen per request by the Accept-Version header.
+//
+// The v2 envelope is always {"data": ..., "meta": {...}, "error": {...}}: data carries the
+// payload, meta.pagination is set for paged lists, and error is set instead of data on failure.
+package response
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// VersionHeader selects the response format; "2" or "v2" opts into the envelope.
+	VersionHeader = "Accept-Version"
+	// ServedVersionHeader reports which format the response was written in.
+	ServedVersionHeader = "API-Version"
+)
+
+type Envelope struct {
+	Data  any    `json:"data"`
+	Meta  *Meta  `json:"meta,omitempty"`
+	Error *Error `json:"error,omitempty"`
+}
+
+type Meta struct {
+	Pagination *Pagination `json:"pagination,omitempty"`
+}
+
+type Pagination struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+	Count  int `json:"count"`
+	// NextCursor fetches the next page of lists paged by cursor; it is left out on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+type Error struct {
+	// Code is the snake_case HTTP status text, e.g. "not_found".
+	Code    string         `json:"code"`
+	Message string         `json:"message"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+// V2 reports whether the client asked for the envelope.
+func V2(c *gin.Context) bool {
+	v := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(c.GetHeader(VersionHeader))), "v")
+	return v == "2"
+}
+
+// JSON writes body with status code. In v2, a gin.H body on a 4xx/5xx status becomes the
+// envelope's error, its "error" key the message and other keys details; anything else is data.
+func JSON(c *gin.Context, code int, body any) {
+	if !V2(c) {
+		c.JSON(code, body)
+		return
+	}
+	c.Header(ServedVersionHeader, "2")
+	c.JSON(code, envelope(code, body))
+}
+
+// Abort is JSON followed by aborting the handler chain, for middleware.
+func Abort(c *gin.Context, code int, body any) {
+	if !V2(c) {
+		c.AbortWithStatusJSON(code, body)
+		return
+	}
+	c.Header(ServedVersionHeader, "2")
+	c.AbortWithStatusJSON(code, envelope(code, body))
+}
+
+// Page writes a paged list. v1 keeps the {key: items, limit, offset} shape; v2 puts the
+// items in data and the paging in meta.pagination.
+func Page[T any](c *gin.Context, key string, items []T, limit, offset int) {
+	CursorPage(c, key, items, limit, offset, "")
+}
+
+// CursorPage is Page for lists that can also be paged by cursor. next is the cursor of the
+// following page, empty on the last one; v1 adds it as next_cursor.
+func CursorPage[T any](c *gin.Context, key string, items []T, limit, offset int, next string) {
+	if !V2(c) {
+		body := gin.H{key: items, "limit": limit, "offset": offset}
+		if next != "" {
+			body["next_cursor"] = next
+		}
+		c.JSON(http.StatusOK, body)
+		return
+	}
+	if items == nil {
+		items = []T{}
+	}
+	c.Header(ServedVersionHeader, "2")
+	c.JSON(http.StatusOK, Envelope{
+		Data: items,
+		Meta: &Meta{Pagination: &Pagination{Limit: limit, Offset: offset, Count: len(items), NextCursor: next}},
+	})
+}
+
+func envelope(code int, body any) Envelope {
+	if code < http.StatusBadRequest {
+		return Envelope{Data: body}
+	}
+	h, ok := body.(gin.H)
+	if !ok {
+		// Typed failure bodies (e.g. a declined payment) stay in data alongside the error
+		return Envelope{Data: body, Error: &Error{Code: errorCode(code), Message: http.StatusText(code)}}
+	}
+	e := &Error{Code: errorCode(code), Message: http.StatusText(code)}
+	for k, v := range h {
+		if k == "error" {
+			if msg, ok := v.(string); ok {
+				e.Message = msg
+				continue
+			}
+		}
+		if e.Details == nil {
+			e.Details = make(map[string]any)
+		}
+		e.Details[k] = v
+	}
+	return Envelope{Error: e}
+}
+
+func errorCode(code int) string {
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(code)), " ", "_")
+}
@@ -3,6 +3,9 @@ package api
 import (
 	"context"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -10,31 +13,91 @@ import (
 	"github.com/samirwankhede/lewly-pgpyewj/internal/api/admin"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/api/auth"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/api/bookings"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/api/checkin"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/api/events"
+	healthHandler "github.com/samirwankhede/lewly-pgpyewj/internal/api/health"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/api/milestones"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/api/organizers"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/api/payment"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/api/paymentlinks"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/api/subscriptions"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/api/waitlist"
+	walletHandler "github.com/samirwankhede/lewly-pgpyewj/internal/api/wallet"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/api/webhooks"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/config"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/cursor"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/health"
 	kafkax "github.com/samirwankhede/lewly-pgpyewj/internal/kafka"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/lock"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/logger"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/mailer"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/middleware"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/oauth"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/payments"
 	redisx "github.com/samirwankhede/lewly-pgpyewj/internal/redis"
 	adminService "github.com/samirwankhede/lewly-pgpyewj/internal/service/admin"
 	authService "github.com/samirwankhede/lewly-pgpyewj/internal/service/auth"
 	bookingsService "github.com/samirwankhede/lewly-pgpyewj/internal/service/bookings"
+	checkInService "github.com/samirwankhede/lewly-pgpyewj/internal/service/checkin"
 	eventsService "github.com/samirwankhede/lewly-pgpyewj/internal/service/events"
+	fxService "github.com/samirwankhede/lewly-pgpyewj/internal/service/fx"
+	jobsService "github.com/samirwankhede/lewly-pgpyewj/internal/service/jobs"
 	mailerService "github.com/samirwankhede/lewly-pgpyewj/internal/service/mailer"
+	milestonesService "github.com/samirwankhede/lewly-pgpyewj/internal/service/milestones"
+	organizersService "github.com/samirwankhede/lewly-pgpyewj/internal/service/organizers"
 	paymentService "github.com/samirwankhede/lewly-pgpyewj/internal/service/payment"
+	paymentLinksService "github.com/samirwankhede/lewly-pgpyewj/internal/service/paymentlinks"
+	subscriptionsService "github.com/samirwankhede/lewly-pgpyewj/internal/service/subscriptions"
+	waitlistService "github.com/samirwankhede/lewly-pgpyewj/internal/service/waitlist"
+	walletService "github.com/samirwankhede/lewly-pgpyewj/internal/service/wallet"
+	webhooksService "github.com/samirwankhede/lewly-pgpyewj/internal/service/webhooks"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/store"
 	storeAdmin "github.com/samirwankhede/lewly-pgpyewj/internal/store/admin"
 	storeBookings "github.com/samirwankhede/lewly-pgpyewj/internal/store/bookings"
+	storeBranding "github.com/samirwankhede/lewly-pgpyewj/internal/store/branding"
+	storeCheckIn "github.com/samirwankhede/lewly-pgpyewj/internal/store/checkin"
 	storeEvents "github.com/samirwankhede/lewly-pgpyewj/internal/store/events"
+	storeFX "github.com/samirwankhede/lewly-pgpyewj/internal/store/fx"
+	storeInvitations "github.com/samirwankhede/lewly-pgpyewj/internal/store/invitations"
+	storeJobs "github.com/samirwankhede/lewly-pgpyewj/internal/store/jobs"
+	storeMembers "github.com/samirwankhede/lewly-pgpyewj/internal/store/members"
+	storeMilestones "github.com/samirwankhede/lewly-pgpyewj/internal/store/milestones"
+	storeNotifications "github.com/samirwankhede/lewly-pgpyewj/internal/store/notifications"
+	storeOrganizers "github.com/samirwankhede/lewly-pgpyewj/internal/store/organizers"
+	storePaymentLinks "github.com/samirwankhede/lewly-pgpyewj/internal/store/paymentlinks"
+	storePayments "github.com/samirwankhede/lewly-pgpyewj/internal/store/payments"
+	storePrewarms "github.com/samirwankhede/lewly-pgpyewj/internal/store/prewarms"
 	storeSeats "github.com/samirwankhede/lewly-pgpyewj/internal/store/seats"
+	storeSnapshots "github.com/samirwankhede/lewly-pgpyewj/internal/store/snapshots"
+	storeSubscriptions "github.com/samirwankhede/lewly-pgpyewj/internal/store/subscriptions"
 	storeUsers "github.com/samirwankhede/lewly-pgpyewj/internal/store/users"
 	storeWaitlist "github.com/samirwankhede/lewly-pgpyewj/internal/store/waitlist"
+	storeWebhooks "github.com/samirwankhede/lewly-pgpyewj/internal/store/webhooks"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/wallet"
 )
 
-// RegisterRoutes wires all HTTP routes.
-func RegisterRoutes(r *gin.Engine, log *zap.Logger) {
+// tokenGaugeInterval is how often per-event Redis token counts are sampled into metrics.
+const tokenGaugeInterval = 15 * time.Second
+
+// loops runs the API's background loops until ctx is done.
+type loops struct {
+	ctx context.Context
+	wg  sync.WaitGroup
+}
+
+func (l *loops) Go(run func(ctx context.Context)) {
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		run(l.ctx)
+	}()
+}
+
+// RegisterRoutes wires all HTTP routes and starts the background loops, which run until ctx
+// is done. It returns the readiness checker, which refuses readiness until the caller runs
+// WaitReady, and a wait that returns once every loop has stopped.
+func RegisterRoutes(ctx context.Context, r *gin.Engine, log *zap.Logger) (*health.Checker, func()) {
+	bg := &loops{ctx: ctx}
 	r.Use(middleware.MetricsMiddleware())
 	r.GET("/", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
@@ -42,7 +105,7 @@ func RegisterRoutes(r *gin.Engine, log *zap.Logger) {
 			"description": "A scalable event booking platform with concurrency-safe ticketing, waitlists, and admin analytics.",
 			"version":     "1.0.0",
 			"docs":        "/docs",
-			"endpoints":   []string{"/v1/health", "/v1/events", "/v1/bookings", "/v1/waitlist", "/admin"},
+			"endpoints":   []string{"/v1/health", "/v1/events", "/v1/bookings", "/v1/waitlist", "/v1/organizers", "/admin"},
 		})
 	})
 	r.GET("/v1/health", func(c *gin.Context) {
@@ -51,50 +114,250 @@ func RegisterRoutes(r *gin.Engine, log *zap.Logger) {
 
 	RegisterDocs(r)
 	cfg := config.Load()
-	// global rate limit (demo)
-	r.Use(middleware.HybridRateLimit(redisx.NewTokenBucket(cfg.RedisAddr).GetClient(), 50, 100))
+	// Probes are registered ahead of the rate limits so on-sale traffic can't fail them;
+	// dependency checks are added below as the clients are created
+	checker := health.NewChecker(cfg.HealthCheckTimeout)
+	healthHandler.NewHealthHandler(checker).Register(r)
+	// List cursors are signed with their own secret when one is set, else the JWT secret
+	cursorSecret := cfg.CursorSecret
+	if cursorSecret == "" {
+		cursorSecret = cfg.JWTSigningSecret
+	}
+	cursors, err := cursor.NewCodec(cursorSecret, cfg.CursorEncrypt, cfg.CursorTTL)
+	if err != nil {
+		log.Error("cursor codec disabled, lists fail past their first page", zap.Error(err))
+	}
+	// Every route gets the public limit, which keeps serving on an in-memory limit if Redis is
+	// down; auth routes add a stricter one that rejects instead unless configured otherwise
+	limiterClient := redisx.NewTokenBucket(cfg.RedisAddr).GetClient()
+	r.Use(middleware.PolicyRateLimit(limiterClient, middleware.RateLimitPolicy{
+		Name: "public", RPS: cfg.RateLimitPublicRPS, Burst: cfg.RateLimitPublicBurst, FailClosed: cfg.RateLimitPublicClosed,
+	}))
+	authLimit := middleware.PolicyRateLimit(limiterClient, middleware.RateLimitPolicy{
+		Name: "auth", RPS: cfg.RateLimitAuthRPS, Burst: cfg.RateLimitAuthBurst, FailClosed: cfg.RateLimitAuthClosed,
+	})
+	// Aggregators sync the catalog through the feed; a page can be 500 events, so they get
+	// fewer requests than interactive clients
+	feedLimit := middleware.PolicyRateLimit(limiterClient, middleware.RateLimitPolicy{
+		Name: "feed", RPS: cfg.RateLimitFeedRPS, Burst: cfg.RateLimitFeedBurst,
+	})
+	// Bodies are capped per route before binding: credentials are tiny, event creation can
+	// list up to 100000 seats; BODY_LIMIT_ROUTES overrides any of these
+	bodyLimits := middleware.BodyLimits{
+		Default:  cfg.BodyLimitDefault,
+		MaxDepth: cfg.JSONMaxDepth,
+		Routes: map[string]int64{
+			"POST /v1/auth/signup":               4 << 10,
+			"POST /v1/auth/login":                4 << 10,
+			"POST /v1/auth/password/request-otp": 4 << 10,
+			"POST /v1/auth/password/verify-otp":  4 << 10,
+			"POST /v1/bookings/:id/book":         16 << 10,
+			"PUT /v1/bookings/:id/seats":         16 << 10,
+			"POST /admin/events":                 8 << 20,
+			"PUT /admin/events/:id":              8 << 20,
+			"POST /admin/events/:id/invitees":    6 << 20,
+			"POST /v1/organizer/events":          8 << 20,
+			"PUT /v1/organizer/events/:id":       8 << 20,
+		},
+	}
+	for route, limit := range middleware.ParseRouteLimits(strings.Split(cfg.BodyLimitRoutes, ",")) {
+		bodyLimits.Routes[route] = limit
+	}
+	r.Use(middleware.BodyLimit(bodyLimits))
 
 	// DI wiring for all services
-	db, err := store.NewDB(context.Background(), cfg.PostgresURL, int32(cfg.MaxDBConnections))
+	// Repositories log as the "store" component, so LOG_LEVELS can quieten or open up queries
+	// apart from the API
+	storeLog := logger.Component(log, "store")
+	pools, err := store.NewPools(ctx, cfg.PostgresURL, int32(cfg.MaxDBConnections), int32(cfg.MaxBatchDBConnections), store.WithSlowQueryLog(storeLog, cfg.SlowQueryThreshold))
 	if err == nil {
 		// When DB is unavailable, endpoints will still serve 500 gracefully.
+		db := pools.Interactive
+		checker.Add("postgres", db.Pool.Ping).Add("postgres_batch", pools.Batch.Pool.Ping)
 
 		// Create repositories
-		eventsRepo := storeEvents.NewEventsRepository(db, log)
-		bookingsRepo := storeBookings.NewBookingsRepository(db, log)
-		usersRepo := storeUsers.NewUsersRepository(db, log)
-		waitlistRepo := storeWaitlist.NewWaitlistRepository(db, log)
-		adminRepo := storeAdmin.NewAdminRepository(db, log)
-		seatsRepo := storeSeats.NewSeatsRepository(db, log)
+		eventsRepo := storeEvents.NewEventsRepository(db, storeLog)
+		bookingsRepo := storeBookings.NewBookingsRepository(db, storeLog)
+		usersRepo := storeUsers.NewUsersRepository(db, storeLog)
+		waitlistRepo := storeWaitlist.NewWaitlistRepository(db, storeLog)
+		// Analytics scans run on the batch pool so they can't starve booking transactions
+		adminRepo := storeAdmin.NewAdminRepository(pools.Batch, storeLog)
+		seatsRepo := storeSeats.NewSeatsRepository(db, storeLog)
+		organizersRepo := storeOrganizers.NewOrganizersRepository(db, storeLog)
+		paymentLinksRepo := storePaymentLinks.NewPaymentLinksRepository(db, storeLog)
+		milestonesRepo := storeMilestones.NewMilestonesRepository(db, storeLog)
+		snapshotsRepo := storeSnapshots.NewSnapshotsRepository(pools.Batch, storeLog)
+		fxRepo := storeFX.NewFXRepository(db, storeLog)
+		invitationsRepo := storeInvitations.NewInvitationsRepository(db, storeLog)
+		notificationsRepo := storeNotifications.NewNotificationsRepository(db, storeLog)
+		jobsRepo := storeJobs.NewJobsRepository(db, storeLog)
+		paymentsRepo := storePayments.NewPaymentsRepository(db, storeLog)
+		webhooksRepo := storeWebhooks.NewWebhooksRepository(db, storeLog)
+		subscriptionsRepo := storeSubscriptions.NewSubscriptionsRepository(db, storeLog)
+		checkInRepo := storeCheckIn.NewCheckInRepository(db, storeLog)
 
 		// Create Redis client and mailer
 		tokens := redisx.NewTokenBucket(cfg.RedisAddr)
-		mailerSender := &mailer.SMTPSender{
+		checker.Add("redis", func(ctx context.Context) error { return tokens.GetClient().Ping(ctx).Err() })
+		bookingEvents := redisx.NewBookingEvents(cfg.RedisAddr)
+		// Users' personal webhooks get every booking transition the API announces; the worker
+		// delivers them
+		webhooksSvc := webhooksService.NewWebhooksService(log, webhooksRepo, cfg.UserWebhookAllowLocal)
+		bookingEvents.OnPublish(webhooksSvc.Enqueue)
+		// and every transition is kept for the booking's trace
+		bookingEvents.OnPublish(bookingsService.EventRecorder(log, bookingsRepo))
+
+		// Admin checks use a role cache invalidated over Redis pub/sub
+		roleCache := middleware.NewRoleCache(log, usersRepo.GetRole, tokens.GetClient(), cfg.RoleCacheTTL)
+		bg.Go(roleCache.Listen)
+		// Log levels changed through /admin/log-levels on any instance apply here too
+		bg.Go(func(ctx context.Context) { logger.ListenLevels(ctx, log, tokens.GetClient()) })
+		bg.Go(func(ctx context.Context) { tokens.RunTokenGauge(ctx, tokenGaugeInterval) })
+		middleware.UseRoleCache(roleCache)
+		// Logged-out tokens are refused until they expire
+		tokenBlacklist := middleware.NewTokenBlacklist(tokens.GetClient())
+		middleware.UseTokenBlacklist(tokenBlacklist)
+		middleware.UseAdminAPIKeys(strings.Split(cfg.AdminAPIKeys, ","))
+		middleware.UsePartnerAPIKeys(strings.Split(cfg.PartnerAPIKeys, ","))
+		// Emails are signed with the platform's or their organizer's branding as they're sent
+		brands := mailerService.NewBrands(log, storeBranding.NewBrandingRepository(db, storeLog))
+		mailerSender := mailerService.NewBrandedSender(&mailer.SMTPSender{
 			Host: cfg.SMTPHost,
 			Port: cfg.SMTPPort,
 			User: cfg.SMTPUser,
 			Pass: cfg.SMTPPass,
 			From: cfg.SMTPFrom,
-		}
-		mailerSvc := mailerService.NewMailerService(log, mailerSender)
+		}, brands)
+		// Mass emails go out in rate-limited batches that resume after a restart
+		dispatcher := mailerService.NewDispatcher(log, notificationsRepo, mailerSender, cfg.NotifyWorkers, cfg.NotifyRatePerSecond)
+		bg.Go(func(ctx context.Context) { dispatcher.Run(ctx, cfg.NotifyResumeInterval) })
+		// Single emails are queued in Postgres for the worker to send with retries
+		mailQueue := mailerService.NewMailQueue(log, notificationsRepo, mailerSender, cfg.MailMaxAttempts, cfg.MailRetryBase, cfg.MailRetryMax)
+		mailerSvc := mailerService.NewMailerService(log, mailerSender).WithDispatcher(dispatcher).WithQueue(mailQueue).WithBrands(brands)
 
 		// Create services
-		eventsSvc := eventsService.NewEventsService(log, eventsRepo, tokens)
-		authSvc := authService.NewAuthService(log, usersRepo, tokens, cfg.JWTSigningSecret, mailerSvc)
-		producer := kafkax.NewProducer([]string{cfg.KafkaBrokers}, "bookings")
-		bookingsSvc := bookingsService.NewBookingsService(log, bookingsRepo, eventsRepo, usersRepo, tokens, producer, waitlistRepo, mailerSvc, cfg.PaymentURL)
-		paymentSvc := paymentService.NewPaymentService(log, bookingsRepo, eventsRepo)
-		adminSvc := adminService.NewAdminService(log, eventsRepo, usersRepo, bookingsRepo, adminRepo, seatsRepo, tokens, mailerSvc)
+		// Long-running admin operations run as jobs admins poll at /admin/jobs/:id
+		jobRunner := jobsService.NewRunner(log, jobsRepo, cfg.AdminJobConcurrency)
+		bg.Go(jobRunner.Run)
+		fxRates := fxService.NewRates(log, fxRepo)
+		eventsSvc := eventsService.NewEventsService(log, eventsRepo, tokens).WithRates(fxRates).WithInvitations(invitationsRepo).
+			WithAvailability(cfg.AvailabilityLimited).WithStatsCache(cfg.EventStatsCacheTTL).
+			WithCache(cfg.EventCacheTTL)
+		// Listings read availability badges the job keeps in Redis instead of counting seats
+		bg.Go(func(ctx context.Context) { eventsSvc.RunAvailability(ctx, cfg.AvailabilityInterval) })
+		authSvc := authService.NewAuthService(log, usersRepo, tokens, cfg.JWTSigningSecret, mailerSvc).
+			WithGoogle(&oauth.Google{ClientID: cfg.GoogleClientID, ClientSecret: cfg.GoogleClientSecret, RedirectURL: cfg.GoogleRedirectURL}).
+			WithTokenBlacklist(tokenBlacklist)
+		authHandler := auth.NewAuthHandler(log, authSvc, cfg.JWTSigningSecret).WithRateLimit(authLimit)
+		// In session mode sign-ins get opaque session IDs kept in Redis; JWTs issued before
+		// the switch stay valid until they expire
+		if cfg.AuthMode == "session" {
+			sessionStore := middleware.NewSessionStore(tokens.GetClient(), cfg.SessionTTL)
+			middleware.UseSessionStore(sessionStore)
+			authSvc.WithSessions(sessionStore)
+			authHandler.WithSessionCookie()
+		} else if cfg.AuthMode != "jwt" {
+			log.Warn("unknown AUTH_MODE, issuing JWTs", zap.String("auth_mode", cfg.AuthMode))
+		}
+		codec, err := kafkax.CodecFor(cfg.KafkaCodec)
+		if err != nil {
+			log.Warn("unknown kafka codec, falling back to json", zap.Error(err))
+			codec = kafkax.JSONCodec{}
+		}
+		producer := kafkax.NewProducer([]string{cfg.KafkaBrokers}, "bookings").WithCodec(codec)
+		checker.Add("kafka", producer.Ping)
+		// Cancellations, payment timeouts and token resyncs of an event run one at a time
+		eventLocks := lock.New(db, cfg.EventLockWait, cfg.EventLockHold)
+		// Falls back to Postgres admission while Redis is failing, if REDIS_FALLBACK_ENABLED
+		admission := bookingsService.NewAdmission(log, db, tokens, eventsRepo, cfg.RedisFallbackEnabled, cfg.RedisFallbackRPS)
+		bg.Go(func(ctx context.Context) { admission.Run(ctx, cfg.RedisProbeInterval) })
+		// Cancellations hand freed seats to the waitlist through the same promoter as worker timeouts
+		promoter := waitlistService.NewPromoter(log, waitlistRepo, eventsRepo, usersRepo, producer, mailerSvc, bookingEvents)
+		bookingsSvc := bookingsService.NewBookingsService(log, bookingsRepo, eventsRepo, usersRepo, tokens, producer, waitlistRepo, mailerSvc, cfg.PaymentURL, bookingEvents, promoter, admission).
+			WithInvitations(invitationsRepo).
+			WithSeatHolds(cfg.SeatHoldTTL).
+			WithEventCache(cfg.BookingEventCacheTTL).
+			WithLatencyBudget(cfg.BookingLatencyBudget).
+			WithEventLocks(eventLocks).
+			WithTransferTTL(cfg.TransferClaimTTL).
+			OnCreate(webhooksSvc.BookingCreated)
+		milestonesSvc := milestonesService.NewMilestonesService(log, milestonesRepo, eventsRepo, mailerSvc, cfg.MilestoneWebhookSecret)
+		paymentProvider := payments.NewProvider(log, cfg.StripeSecretKey, cfg.StripeAPIURL)
+		// Wallet passes are offered in each format whose signing credentials are configured;
+		// emailed pass links are signed with the JWT secret
+		walletSvc := walletService.NewWalletService(log, bookingsRepo, eventsRepo, usersRepo, cfg.PaymentURL, cfg.JWTSigningSecret)
+		if cfg.WalletApplePassTypeID != "" {
+			apple, err := wallet.NewApplePasses(wallet.AppleConfig{PassTypeID: cfg.WalletApplePassTypeID, TeamID: cfg.WalletAppleTeamID,
+				Organization: cfg.WalletOrganization, Cert: cfg.WalletAppleCert, Key: cfg.WalletAppleKey, WWDRCert: cfg.WalletAppleWWDRCert, Icon: cfg.WalletAppleIcon})
+			if err != nil {
+				log.Error("Apple Wallet passes disabled", zap.Error(err))
+			} else {
+				walletSvc.WithApple(apple)
+			}
+		}
+		if cfg.WalletGoogleIssuerID != "" {
+			google, err := wallet.NewGooglePasses(wallet.GoogleConfig{IssuerID: cfg.WalletGoogleIssuerID, ServiceAccount: cfg.WalletGoogleServiceAccount,
+				Organization: cfg.WalletOrganization, Key: cfg.WalletGoogleKey})
+			if err != nil {
+				log.Error("Google Wallet passes disabled", zap.Error(err))
+			} else {
+				walletSvc.WithGoogle(google)
+			}
+		}
+		// Payments for bookings without valid seats are refused and flagged to the admin
+		seatIntegrity := paymentService.NewSeatIntegrity(log, bookingsRepo, mailerSvc, cfg.AdminEmail)
+		paymentSvc := paymentService.NewPaymentService(log, bookingsRepo, eventsRepo, milestonesSvc, bookingEvents, jobRunner, paymentsRepo, paymentProvider).
+			WithHoldExtension(redisx.NewTimeoutBucket(cfg.RedisAddr), cfg.PaymentExtensionMax).
+			WithSeatIntegrity(seatIntegrity).
+			WithConfirmations(usersRepo, mailerSvc, walletSvc)
+		// Manual-capture events are charged once their capture time passes
+		bg.Go(func(ctx context.Context) { paymentSvc.RunCaptures(ctx, cfg.PaymentCaptureInterval) })
+		// Stripe webhook events are acknowledged first and applied here
+		bg.Go(func(ctx context.Context) { paymentSvc.RunProviderEvents(ctx, cfg.ProviderEventInterval) })
+		// Cancelling an authorized booking voids its authorization
+		bookingsSvc.WithPayments(paymentSvc)
+		paymentLinksSvc := paymentLinksService.NewPaymentLinksService(log, paymentLinksRepo, cfg.PaymentURL)
+		organizersSvc := organizersService.NewOrganizersService(log, organizersRepo, eventsRepo, mailerSvc).WithAvailability(tokens)
+		// New public events are matched against users' category and tag subscriptions
+		subscriptionsSvc := subscriptionsService.NewSubscriptionsService(log, subscriptionsRepo, mailerSvc, cfg.PaymentURL, cfg.SubscriptionDigest)
+		bg.Go(subscriptionsSvc.RunDigests)
+		adminSvc := adminService.NewAdminService(log, eventsRepo, usersRepo, bookingsRepo, adminRepo, seatsRepo, tokens, mailerSvc, organizersSvc, snapshotsRepo, jobRunner, cfg.EventDuplicateCheck).
+			WithInvitations(invitationsRepo, cfg.PaymentURL).
+			WithNotifications(notificationsRepo).
+			WithEventLocks(eventLocks).
+			WithPromoter(promoter).
+			WithPrewarms(storePrewarms.NewPrewarmsRepository(db, storeLog)).
+			WithBrands(brands).
+			WithMembers(storeMembers.NewMembersRepository(db, storeLog), cfg.PaymentURL).
+			OnPublish(subscriptionsSvc.MatchEvent).
+			OnCancel(webhooksSvc.EventCancelled)
+		// Gate devices scan tickets with event-scoped tokens admins issue, not user accounts
+		checkInSvc := checkInService.NewCheckInService(log, checkInRepo, eventsRepo, bookingsRepo, cfg.GateTokenMaxTTL, cfg.CheckInOpensBefore)
 
 		// Register handlers
-		events.NewEventsHandler(log, eventsSvc, cfg.JWTSigningSecret).Register(r)
-		auth.NewAuthHandler(log, authSvc, cfg.JWTSigningSecret).Register(r)
-		bookings.NewBookingsHandler(bookingsSvc, cfg.JWTSigningSecret).Register(r)
-		waitlist.NewWaitlistHandler(waitlistRepo, cfg.JWTSigningSecret).Register(r)
-		payment.NewPaymentHandler(log, paymentSvc, cfg.JWTSigningSecret).Register(r)
+		events.NewEventsHandler(log, eventsSvc, cfg.JWTSigningSecret).WithCursors(cursors).Register(r)
+		events.NewFeedHandler(log, eventsSvc, cfg.PaymentURL).WithRateLimit(feedLimit).WithCursors(cursors).Register(r)
+		authHandler.Register(r)
+		bookings.NewBookingsHandler(bookingsSvc, cfg.JWTSigningSecret).WithCursors(cursors).Register(r)
+		waitlistSvc := waitlistService.NewWaitlistService(waitlistRepo, eventsRepo, invitationsRepo)
+		waitlist.NewWaitlistHandler(waitlistRepo, waitlistSvc, cfg.JWTSigningSecret).WithCursors(cursors).Register(r)
+		payment.NewPaymentHandler(log, paymentSvc, cfg.JWTSigningSecret, middleware.ParseWebhookSecrets(cfg.PaymentWebhookSecrets), cfg.WebhookTolerance).WithEventAccess(adminSvc.EventAccess).Register(r)
 		admin.NewAdminHandler(adminSvc, cfg.JWTSigningSecret).Register(r)
+		organizers.NewOrganizersHandler(log, organizersSvc, cfg.JWTSigningSecret).Register(r)
+		organizers.NewConsoleHandler(log, adminSvc, cfg.JWTSigningSecret).Register(r)
+		paymentlinks.NewPaymentLinksHandler(log, paymentLinksSvc, cfg.JWTSigningSecret).Register(r)
+		milestones.NewMilestonesHandler(log, milestonesSvc, cfg.JWTSigningSecret).Register(r)
+		webhooks.NewWebhooksHandler(log, webhooksSvc, cfg.JWTSigningSecret).Register(r)
+		subscriptions.NewSubscriptionsHandler(log, subscriptionsSvc, cfg.JWTSigningSecret).Register(r)
+		checkin.NewCheckInHandler(log, checkInSvc, cfg.JWTSigningSecret).WithEventAccess(adminSvc.EventAccess).Register(r)
+		walletHandler.NewWalletHandler(log, walletSvc, cfg.JWTSigningSecret).Register(r)
+		admin.NewSeatIntegrityHandler(log, seatIntegrity, cfg.JWTSigningSecret).Register(r)
+		admin.NewLogLevelsHandler(log, tokens.GetClient(), cfg.JWTSigningSecret).Register(r)
 
 	} else {
 		log.Warn("db init failed", zap.Error(err))
+		// Nothing but the probes is served; keep the instance out of the load balancer
+		checker.Add("postgres", func(context.Context) error { return err })
 	}
+	return checker, bg.wg.Wait
 }
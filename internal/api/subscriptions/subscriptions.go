@@ -0,0 +1,103 @@
+package subscriptions
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/api/response"
+	jwtMiddleware "github.com/samirwankhede/lewly-pgpyewj/internal/middleware"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/service/subscriptions"
+)
+
+type SubscriptionsHandler struct {
+	log    *zap.Logger
+	svc    *subscriptions.SubscriptionsService
+	secret string
+}
+
+func NewSubscriptionsHandler(log *zap.Logger, svc *subscriptions.SubscriptionsService, secret string) *SubscriptionsHandler {
+	return &SubscriptionsHandler{log: log, svc: svc, secret: secret}
+}
+
+func (h *SubscriptionsHandler) Register(r *gin.Engine) {
+	protected := r.Group("/v1/subscriptions")
+	protected.Use(jwtMiddleware.Middleware(h.secret, false))
+	{
+		protected.POST("", h.subscribe)
+		protected.GET("", h.list)
+		protected.DELETE("/:id", h.unsubscribe)
+	}
+}
+
+// userID returns the caller, answering 401 when the token has none (admin API keys).
+func userID(c *gin.Context) (string, bool) {
+	uid := c.GetString("uid")
+	if uid == "" {
+		response.JSON(c, http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return "", false
+	}
+	return uid, true
+}
+
+func (h *SubscriptionsHandler) subscribe(c *gin.Context) {
+	uid, ok := userID(c)
+	if !ok {
+		return
+	}
+	var in subscriptions.SubscriptionInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	sub, err := h.svc.Subscribe(c.Request.Context(), uid, in)
+	if err != nil {
+		switch err {
+		case subscriptions.ErrInvalidSubscription:
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		case subscriptions.ErrTooManySubscriptions:
+			response.JSON(c, http.StatusConflict, gin.H{"error": "subscription limit reached; remove one first"})
+		default:
+			h.log.Error("Subscribe failed", zap.Error(err))
+			response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+	response.JSON(c, http.StatusCreated, sub)
+}
+
+func (h *SubscriptionsHandler) list(c *gin.Context) {
+	uid, ok := userID(c)
+	if !ok {
+		return
+	}
+	list, err := h.svc.List(c.Request.Context(), uid)
+	if err != nil {
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"subscriptions": list})
+}
+
+func (h *SubscriptionsHandler) unsubscribe(c *gin.Context) {
+	uid, ok := userID(c)
+	if !ok {
+		return
+	}
+	id := c.Param("id")
+	if _, err := uuid.Parse(id); err != nil {
+		response.JSON(c, http.StatusNotFound, gin.H{"error": "Subscription not found"})
+		return
+	}
+	if err := h.svc.Unsubscribe(c.Request.Context(), uid, id); err != nil {
+		if err == subscriptions.ErrSubscriptionNotFound {
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Subscription not found"})
+			return
+		}
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"message": "Unsubscribed"})
+}
@@ -6,17 +6,30 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/samirwankhede/lewly-pgpyewj/internal/api/response"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/cursor"
 	jwtMiddleware "github.com/samirwankhede/lewly-pgpyewj/internal/middleware"
+	eventsService "github.com/samirwankhede/lewly-pgpyewj/internal/service/events"
+	waitlistService "github.com/samirwankhede/lewly-pgpyewj/internal/service/waitlist"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/store/waitlist"
 )
 
 type WaitlistHandler struct {
 	repo   *waitlist.WaitlistRepository
+	svc    *waitlistService.WaitlistService
 	secret string
+	// cursors encodes the list's page cursors; without it only the first page is served
+	cursors *cursor.Codec
 }
 
-func NewWaitlistHandler(repo *waitlist.WaitlistRepository, secret string) *WaitlistHandler {
-	return &WaitlistHandler{repo: repo, secret: secret}
+func NewWaitlistHandler(repo *waitlist.WaitlistRepository, svc *waitlistService.WaitlistService, secret string) *WaitlistHandler {
+	return &WaitlistHandler{repo: repo, svc: svc, secret: secret}
+}
+
+// WithCursors pages the waitlist with cursors encoded by codec.
+func (h *WaitlistHandler) WithCursors(codec *cursor.Codec) *WaitlistHandler {
+	h.cursors = codec
+	return h
 }
 
 func (h *WaitlistHandler) Register(r *gin.Engine) {
@@ -35,43 +48,68 @@ func (h *WaitlistHandler) Register(r *gin.Engine) {
 func (h *WaitlistHandler) join(c *gin.Context) {
 	eventID := c.Param("event_id")
 	userID := c.GetString("uid")
-	pos, err := h.repo.Add(c.Request.Context(), eventID, userID)
+	pos, err := h.svc.Join(c.Request.Context(), eventID, userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		switch err {
+		case waitlistService.ErrEventNotFound:
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Event not found"})
+		case waitlistService.ErrWaitlistDisabled, waitlistService.ErrInvitationRequired, waitlistService.ErrSandboxTesterOnly:
+			response.JSON(c, http.StatusForbidden, gin.H{"error": err.Error()})
+		case eventsService.ErrEventArchived, waitlistService.ErrWaitlistClosed:
+			response.JSON(c, http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"position": pos})
+	response.JSON(c, http.StatusOK, gin.H{"position": pos})
 }
 
 func (h *WaitlistHandler) optout(c *gin.Context) {
 	eventID := c.Param("event_id")
 	userID := c.GetString("uid")
 	if err := h.repo.OptOut(c.Request.Context(), eventID, userID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"opted_out": true})
+	response.JSON(c, http.StatusOK, gin.H{"opted_out": true})
 }
 
 func (h *WaitlistHandler) getCount(c *gin.Context) {
 	eventID := c.Param("event_id")
 	count, err := h.repo.Count(c.Request.Context(), eventID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"count": count})
+	response.JSON(c, http.StatusOK, gin.H{"count": count})
 }
 
 func (h *WaitlistHandler) list(c *gin.Context) {
 	eventID := c.Param("event_id")
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
 	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	var pos waitlist.ListPosition
+	given, ok := h.cursors.Bind(c, &pos)
+	if !ok {
+		return
+	}
+	var after *waitlist.ListPosition
+	if given {
+		after, offset = &pos, 0
+	}
 
-	entries, err := h.repo.ListByEvent(c.Request.Context(), eventID, limit, offset)
+	// One entry over the page tells whether another page follows
+	entries, err := h.repo.ListByEvent(c.Request.Context(), eventID, limit+1, offset, after)
+	if err != nil {
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	entries, last := cursor.Trim(entries, limit, waitlist.Position)
+	next, err := h.cursors.Next(last)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"waitlist": entries, "limit": limit, "offset": offset})
+	response.CursorPage(c, "waitlist", entries, limit, offset, next)
 }
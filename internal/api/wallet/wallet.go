@@ -0,0 +1,87 @@
+package wallet
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/api/response"
+	jwtMiddleware "github.com/samirwankhede/lewly-pgpyewj/internal/middleware"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/service/wallet"
+)
+
+type WalletHandler struct {
+	log    *zap.Logger
+	svc    *wallet.WalletService
+	secret string
+}
+
+func NewWalletHandler(log *zap.Logger, svc *wallet.WalletService, secret string) *WalletHandler {
+	return &WalletHandler{log: log, svc: svc, secret: secret}
+}
+
+func (h *WalletHandler) Register(r *gin.Engine) {
+	r.GET("/v1/bookings/:id/wallet", h.authenticate(), h.pass)
+}
+
+// authenticate lets signed links from confirmation emails through without signing in; the
+// service checks their signature.
+func (h *WalletHandler) authenticate() gin.HandlerFunc {
+	auth := jwtMiddleware.Middleware(h.secret, false)
+	return func(c *gin.Context) {
+		if c.Query("sig") != "" {
+			c.Next()
+			return
+		}
+		auth(c)
+	}
+}
+
+// pass serves the booking's Apple Wallet pass, or redirects to Google Wallet to save it.
+func (h *WalletHandler) pass(c *gin.Context) {
+	var link *wallet.Link
+	if sig := c.Query("sig"); sig != "" {
+		link = &wallet.Link{Expires: c.Query("expires"), Sig: sig}
+	}
+	bookingID := c.Param("id")
+	format := c.DefaultQuery("format", wallet.FormatApple)
+
+	switch format {
+	case wallet.FormatApple:
+		pkpass, err := h.svc.ApplePass(c.Request.Context(), bookingID, c.GetString("uid"), link)
+		if err != nil {
+			h.fail(c, err)
+			return
+		}
+		c.Header("Content-Disposition", `attachment; filename="`+bookingID+`.pkpass"`)
+		c.Data(http.StatusOK, "application/vnd.apple.pkpass", pkpass)
+	case wallet.FormatGoogle:
+		saveURL, err := h.svc.GoogleSaveURL(c.Request.Context(), bookingID, c.GetString("uid"), link)
+		if err != nil {
+			h.fail(c, err)
+			return
+		}
+		c.Redirect(http.StatusSeeOther, saveURL)
+	default:
+		h.fail(c, wallet.ErrUnknownFormat)
+	}
+}
+
+func (h *WalletHandler) fail(c *gin.Context, err error) {
+	switch err {
+	case wallet.ErrUnknownFormat:
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+	case wallet.ErrFormatDisabled:
+		response.JSON(c, http.StatusNotFound, gin.H{"error": err.Error(), "formats": h.svc.Formats()})
+	case wallet.ErrBookingNotFound:
+		response.JSON(c, http.StatusNotFound, gin.H{"error": "Booking not found"})
+	case wallet.ErrNotFinalized:
+		response.JSON(c, http.StatusConflict, gin.H{"error": err.Error()})
+	case wallet.ErrInvalidLink:
+		response.JSON(c, http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		h.log.Error("Wallet pass failed", zap.Error(err), zap.String("booking_id", c.Param("id")))
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	}
+}
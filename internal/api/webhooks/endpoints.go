@@ -0,0 +1,95 @@
+package webhooks
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/api/response"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/service/webhooks"
+)
+
+// Integration endpoints are admin-registered webhooks for third-party systems; their routes
+// mirror the personal webhook routes under /admin/webhooks.
+
+func (h *WebhooksHandler) createEndpoint(c *gin.Context) {
+	var in webhooks.EndpointInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	e, err := h.svc.RegisterEndpoint(c.Request.Context(), c.GetString("uid"), in)
+	if err != nil {
+		if err == webhooks.ErrInvalidWebhook {
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": "url must be an https URL and event_types among " + strings.Join(webhooks.EndpointEventTypes, ", ")})
+			return
+		}
+		h.log.Error("Register webhook endpoint failed", zap.Error(err))
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	response.JSON(c, http.StatusCreated, e)
+}
+
+func (h *WebhooksHandler) listEndpoints(c *gin.Context) {
+	list, err := h.svc.ListEndpoints(c.Request.Context())
+	if err != nil {
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"endpoints": list, "event_types": webhooks.EndpointEventTypes})
+}
+
+func (h *WebhooksHandler) deleteEndpoint(c *gin.Context) {
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+	if err := h.svc.DeleteEndpoint(c.Request.Context(), id); err != nil {
+		if err == webhooks.ErrWebhookNotFound {
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Webhook not found"})
+			return
+		}
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"message": "Webhook endpoint deleted"})
+}
+
+func (h *WebhooksHandler) enableEndpoint(c *gin.Context) {
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+	e, err := h.svc.EnableEndpoint(c.Request.Context(), id)
+	if err != nil {
+		if err == webhooks.ErrWebhookNotFound {
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Webhook not found"})
+			return
+		}
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	response.JSON(c, http.StatusOK, e)
+}
+
+func (h *WebhooksHandler) endpointDeliveries(c *gin.Context) {
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	list, err := h.svc.EndpointDeliveries(c.Request.Context(), id, limit)
+	if err != nil {
+		if err == webhooks.ErrWebhookNotFound {
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Webhook not found"})
+			return
+		}
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"endpoint_id": id, "deliveries": list})
+}
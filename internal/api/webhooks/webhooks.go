@@ -0,0 +1,169 @@
+package webhooks
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/api/response"
+	jwtMiddleware "github.com/samirwankhede/lewly-pgpyewj/internal/middleware"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/service/webhooks"
+)
+
+type WebhooksHandler struct {
+	log    *zap.Logger
+	svc    *webhooks.WebhooksService
+	secret string
+}
+
+func NewWebhooksHandler(log *zap.Logger, svc *webhooks.WebhooksService, secret string) *WebhooksHandler {
+	return &WebhooksHandler{log: log, svc: svc, secret: secret}
+}
+
+func (h *WebhooksHandler) Register(r *gin.Engine) {
+	protected := r.Group("/v1/webhooks")
+	protected.Use(jwtMiddleware.Middleware(h.secret, false))
+	{
+		protected.POST("", h.create)
+		protected.GET("", h.list)
+		protected.DELETE("/:id", h.delete)
+		protected.POST("/:id/enable", h.enable)
+		protected.GET("/:id/deliveries", h.deliveries)
+	}
+
+	admin := r.Group("/admin/webhooks")
+	admin.Use(jwtMiddleware.Middleware(h.secret, true))
+	{
+		admin.POST("", h.createEndpoint)
+		admin.GET("", h.listEndpoints)
+		admin.DELETE("/:id", h.deleteEndpoint)
+		admin.POST("/:id/enable", h.enableEndpoint)
+		admin.GET("/:id/deliveries", h.endpointDeliveries)
+	}
+}
+
+// userID returns the caller, answering 401 when the token has none (admin API keys).
+func userID(c *gin.Context) (string, bool) {
+	uid := c.GetString("uid")
+	if uid == "" {
+		response.JSON(c, http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return "", false
+	}
+	return uid, true
+}
+
+// webhookID returns the :id param, answering 404 when it can't be a webhook.
+func webhookID(c *gin.Context) (string, bool) {
+	id := c.Param("id")
+	if _, err := uuid.Parse(id); err != nil {
+		response.JSON(c, http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return "", false
+	}
+	return id, true
+}
+
+func (h *WebhooksHandler) create(c *gin.Context) {
+	uid, ok := userID(c)
+	if !ok {
+		return
+	}
+	var in webhooks.WebhookInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	w, err := h.svc.Register(c.Request.Context(), uid, in)
+	if err != nil {
+		switch err {
+		case webhooks.ErrInvalidWebhook:
+			response.JSON(c, http.StatusBadRequest, gin.H{"error": "url must be an https URL and event_types among " + strings.Join(webhooks.EventTypes, ", ")})
+		case webhooks.ErrTooManyWebhooks:
+			response.JSON(c, http.StatusConflict, gin.H{"error": "webhook limit reached; delete one first"})
+		default:
+			h.log.Error("Register webhook failed", zap.Error(err))
+			response.JSON(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+	response.JSON(c, http.StatusCreated, w)
+}
+
+func (h *WebhooksHandler) list(c *gin.Context) {
+	uid, ok := userID(c)
+	if !ok {
+		return
+	}
+	list, err := h.svc.List(c.Request.Context(), uid)
+	if err != nil {
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"webhooks": list, "event_types": webhooks.EventTypes})
+}
+
+func (h *WebhooksHandler) delete(c *gin.Context) {
+	uid, ok := userID(c)
+	if !ok {
+		return
+	}
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+	if err := h.svc.Delete(c.Request.Context(), uid, id); err != nil {
+		if err == webhooks.ErrWebhookNotFound {
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Webhook not found"})
+			return
+		}
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"message": "Webhook deleted"})
+}
+
+func (h *WebhooksHandler) enable(c *gin.Context) {
+	uid, ok := userID(c)
+	if !ok {
+		return
+	}
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+	w, err := h.svc.Enable(c.Request.Context(), uid, id)
+	if err != nil {
+		if err == webhooks.ErrWebhookNotFound {
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Webhook not found"})
+			return
+		}
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	response.JSON(c, http.StatusOK, w)
+}
+
+func (h *WebhooksHandler) deliveries(c *gin.Context) {
+	uid, ok := userID(c)
+	if !ok {
+		return
+	}
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	list, err := h.svc.Deliveries(c.Request.Context(), uid, id, limit)
+	if err != nil {
+		if err == webhooks.ErrWebhookNotFound {
+			response.JSON(c, http.StatusNotFound, gin.H{"error": "Webhook not found"})
+			return
+		}
+		response.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	response.JSON(c, http.StatusOK, gin.H{"webhook_id": id, "deliveries": list})
+}
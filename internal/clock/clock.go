@@ -0,0 +1,142 @@
+// Package clock abstracts time so payment windows, event expiry and periodic jobs can be
+// driven by a fake clock in tests instead of waiting in real time.
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the subset of the time package the services use.
+type Clock interface {
+	Now() time.Time
+	// After is time.After: the channel receives the current time once d has elapsed.
+	After(d time.Duration) <-chan time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker is a *time.Ticker whose channel is a method, so fakes can provide it.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Real is the wall clock.
+type Real struct{}
+
+func (Real) Now() time.Time                         { return time.Now() }
+func (Real) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (Real) NewTicker(d time.Duration) Ticker       { return realTicker{time.NewTicker(d)} }
+
+type realTicker struct{ t *time.Ticker }
+
+func (r realTicker) C() <-chan time.Time { return r.t.C }
+func (r realTicker) Stop()               { r.t.Stop() }
+
+// Fake is a Clock that only moves when Advance or Set is called. Timers and tickers that
+// come due fire in deadline order, with Now at their deadline as each fires. It is safe
+// for concurrent use.
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*waiter
+}
+
+type waiter struct {
+	at    time.Time
+	every time.Duration // 0 for After
+	ch    chan time.Time
+}
+
+// NewFake returns a Fake set to now.
+func NewFake(now time.Time) *Fake { return &Fake{now: now} }
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	return f.add(d, 0).ch
+}
+
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	return &fakeTicker{f: f, w: f.add(d, d)}
+}
+
+func (f *Fake) add(d, every time.Duration) *waiter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	// Buffered like time.Timer's channel, so firing never blocks Advance
+	w := &waiter{at: f.now.Add(d), every: every, ch: make(chan time.Time, 1)}
+	if d <= 0 && every == 0 {
+		w.ch <- f.now
+		return w
+	}
+	f.waiters = append(f.waiters, w)
+	return w
+}
+
+// Advance moves the clock forward by d and fires everything that came due.
+func (f *Fake) Advance(d time.Duration) {
+	f.Set(f.Now().Add(d))
+}
+
+// Set moves the clock to t, which must not be before the current time.
+func (f *Fake) Set(t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if t.Before(f.now) {
+		panic("clock: Fake moved backwards")
+	}
+	for {
+		sort.SliceStable(f.waiters, func(i, j int) bool { return f.waiters[i].at.Before(f.waiters[j].at) })
+		if len(f.waiters) == 0 || f.waiters[0].at.After(t) {
+			break
+		}
+		w := f.waiters[0]
+		f.now = w.at
+		// A ticker nobody reads drops ticks, like time.Ticker
+		select {
+		case w.ch <- f.now:
+		default:
+		}
+		if w.every > 0 {
+			w.at = w.at.Add(w.every)
+		} else {
+			f.waiters = f.waiters[1:]
+		}
+	}
+	f.now = t
+}
+
+// Waiters reports how many timers and tickers are pending, so a test can wait until the
+// code under test is blocked on the clock before advancing it.
+func (f *Fake) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.waiters)
+}
+
+type fakeTicker struct {
+	f *Fake
+	w *waiter
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.w.ch }
+
+func (t *fakeTicker) Stop() {
+	t.f.mu.Lock()
+	defer t.f.mu.Unlock()
+	for i, w := range t.f.waiters {
+		if w == t.w {
+			t.f.waiters = append(t.f.waiters[:i], t.f.waiters[i+1:]...)
+			break
+		}
+	}
+}
@@ -0,0 +1,203 @@
+// Package cursor turns pagination positions into opaque tokens for public list endpoints.
+//
+// A token is base64url(version | expiry | payload | HMAC-SHA256). The payload is the JSON
+// position (e.g. created_at and id of the last row), AES-GCM sealed when encryption is on,
+// so clients can neither read internal IDs out of a cursor nor forge one. Tokens expire,
+// which bounds how long a leaked cursor works and lets the position format change.
+package cursor
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/api/response"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/clock"
+)
+
+// QueryParam is where list endpoints take the cursor.
+const QueryParam = "cursor"
+
+const (
+	versionSigned    byte = 1
+	versionEncrypted byte = 2
+	headerLen             = 1 + 8 // version, expiry (unix seconds)
+	// maxTokenLen keeps a hostile query string from making us decode megabytes
+	maxTokenLen = 2048
+)
+
+var (
+	ErrInvalid = errors.New("invalid cursor")
+	ErrExpired = errors.New("cursor has expired")
+	// ErrDisabled is a next page with no codec to encode its cursor
+	ErrDisabled = errors.New("cursors are not enabled")
+)
+
+// Codec signs, and optionally encrypts, cursor tokens. It is safe for concurrent use.
+type Codec struct {
+	signKey []byte
+	aead    cipher.AEAD // nil when tokens are only signed
+	ttl     time.Duration
+	clock   clock.Clock
+}
+
+// NewCodec derives separate signing and encryption keys from secret. With encrypt false
+// the position is readable (base64) but tamper-proof; with it true it is also hidden.
+// Signed-only tokens stay valid once encryption is switched on, so turning it on doesn't
+// break cursors already handed out.
+func NewCodec(secret string, encrypt bool, ttl time.Duration) (*Codec, error) {
+	if secret == "" {
+		return nil, errors.New("cursor: empty secret")
+	}
+	c := &Codec{signKey: derive(secret, "cursor-sign"), ttl: ttl, clock: clock.Real{}}
+	if encrypt {
+		block, err := aes.NewCipher(derive(secret, "cursor-encrypt"))
+		if err != nil {
+			return nil, err
+		}
+		if c.aead, err = cipher.NewGCM(block); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// WithClock replaces the wall clock used for expiry.
+func (c *Codec) WithClock(clk clock.Clock) *Codec {
+	c.clock = clk
+	return c
+}
+
+func derive(secret, label string) []byte {
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write([]byte(label))
+	return m.Sum(nil)
+}
+
+// Encode returns a token for position, which must marshal to JSON.
+func (c *Codec) Encode(position any) (string, error) {
+	payload, err := json.Marshal(position)
+	if err != nil {
+		return "", err
+	}
+	header := make([]byte, headerLen, headerLen+len(payload)+64)
+	header[0] = versionSigned
+	binary.BigEndian.PutUint64(header[1:], uint64(c.clock.Now().Add(c.ttl).Unix()))
+	if c.aead != nil {
+		header[0] = versionEncrypted
+		nonce := make([]byte, c.aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return "", err
+		}
+		payload = c.aead.Seal(nonce, nonce, payload, header)
+	}
+	token := append(header, payload...)
+	token = append(token, c.mac(token)...)
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// Decode verifies token and unmarshals its position into v. Every malformed, tampered or
+// foreign token is ErrInvalid; a genuine one past its expiry is ErrExpired.
+func (c *Codec) Decode(token string, v any) error {
+	if len(token) > maxTokenLen {
+		return ErrInvalid
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(raw) < headerLen+sha256.Size {
+		return ErrInvalid
+	}
+	body, sum := raw[:len(raw)-sha256.Size], raw[len(raw)-sha256.Size:]
+	if !hmac.Equal(sum, c.mac(body)) {
+		return ErrInvalid
+	}
+	header, payload := body[:headerLen], body[headerLen:]
+
+	switch header[0] {
+	case versionSigned:
+	case versionEncrypted:
+		if c.aead == nil || len(payload) < c.aead.NonceSize() {
+			return ErrInvalid
+		}
+		nonce, sealed := payload[:c.aead.NonceSize()], payload[c.aead.NonceSize():]
+		if payload, err = c.aead.Open(nil, nonce, sealed, header); err != nil {
+			return ErrInvalid
+		}
+	default:
+		return ErrInvalid
+	}
+
+	expiry := time.Unix(int64(binary.BigEndian.Uint64(header[1:])), 0)
+	if c.clock.Now().After(expiry) {
+		return ErrExpired
+	}
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return ErrInvalid
+	}
+	return nil
+}
+
+func (c *Codec) mac(b []byte) []byte {
+	m := hmac.New(sha256.New, c.signKey)
+	m.Write(b)
+	return m.Sum(nil)
+}
+
+// Next encodes the position of the next page. It returns "" when there is no next page
+// (position nil) so handlers can pass it straight through, and ErrDisabled when there is one
+// but no codec, so a list never silently loses its later pages.
+func (c *Codec) Next(position any) (string, error) {
+	if position == nil {
+		return "", nil
+	}
+	if c == nil {
+		return "", ErrDisabled
+	}
+	return c.Encode(position)
+}
+
+// Trim cuts a list fetched with one row over limit back to limit. If there was a row over,
+// more pages follow and it returns the position after the last row kept, taken by at, for
+// Next; otherwise the position is nil.
+func Trim[T, P any](items []T, limit int, at func(T) P) ([]T, any) {
+	if limit <= 0 || len(items) <= limit {
+		return items, nil
+	}
+	items = items[:limit]
+	return items, at(items[limit-1])
+}
+
+// Bind decodes the request's cursor query parameter into v. It reports whether one was
+// given; on a bad cursor, or any cursor when c is nil, it writes a 400 telling the client to
+// restart from the first page and returns ok false.
+func (c *Codec) Bind(gc *gin.Context, v any) (given, ok bool) {
+	token := gc.Query(QueryParam)
+	if token == "" {
+		return false, true
+	}
+	if c == nil {
+		response.JSON(gc, http.StatusBadRequest, gin.H{"error": ErrInvalid.Error(), "reason": ErrDisabled.Error()})
+		return true, false
+	}
+	if err := c.Decode(token, v); err != nil {
+		reason := "malformed or tampered"
+		if errors.Is(err, ErrExpired) {
+			reason = "expired"
+		}
+		response.JSON(gc, http.StatusBadRequest, gin.H{"error": err.Error(), "reason": reason, "hint": "restart from the first page without a cursor"})
+		return true, false
+	}
+	return true, true
+}
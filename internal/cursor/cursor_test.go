@@ -0,0 +1,189 @@
+package cursor
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/clock"
+)
+
+type position struct {
+	CreatedAt time.Time `json:"created_at"`
+	ID        string    `json:"id"`
+}
+
+var testPosition = position{CreatedAt: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC), ID: "7b0c7a52-7a0e-4d3a-9c55-2f0f4c1f9a11"}
+
+func newCodec(t *testing.T, secret string, encrypt bool, clk clock.Clock) *Codec {
+	t.Helper()
+	c, err := NewCodec(secret, encrypt, time.Hour)
+	if err != nil {
+		t.Fatalf("NewCodec: %v", err)
+	}
+	return c.WithClock(clk)
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, encrypt := range []bool{false, true} {
+		c := newCodec(t, "secret", encrypt, clock.Real{})
+		token, err := c.Encode(testPosition)
+		if err != nil {
+			t.Fatalf("encrypt=%v: Encode: %v", encrypt, err)
+		}
+		var got position
+		if err := c.Decode(token, &got); err != nil {
+			t.Fatalf("encrypt=%v: Decode: %v", encrypt, err)
+		}
+		if !got.CreatedAt.Equal(testPosition.CreatedAt) || got.ID != testPosition.ID {
+			t.Errorf("encrypt=%v: Decode = %+v, want %+v", encrypt, got, testPosition)
+		}
+	}
+}
+
+func TestDecodeRejects(t *testing.T) {
+	clk := clock.NewFake(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	signed := newCodec(t, "secret", false, clk)
+	sealed := newCodec(t, "secret", true, clk)
+	signedToken, err := signed.Encode(testPosition)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	sealedToken, err := sealed.Encode(testPosition)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	// flip changes one byte of the decoded token at i, counted from the end when negative
+	flip := func(token string, i int) string {
+		raw, err := base64.RawURLEncoding.DecodeString(token)
+		if err != nil {
+			t.Fatalf("decode token: %v", err)
+		}
+		if i < 0 {
+			i += len(raw)
+		}
+		raw[i] ^= 0x01
+		return base64.RawURLEncoding.EncodeToString(raw)
+	}
+
+	tests := []struct {
+		name  string
+		codec *Codec
+		token string
+		want  error
+	}{
+		{"tampered payload", signed, flip(signedToken, headerLen+2), ErrInvalid},
+		{"tampered expiry", signed, flip(signedToken, 8), ErrInvalid},
+		{"tampered version", signed, flip(signedToken, 0), ErrInvalid},
+		{"tampered mac", signed, flip(signedToken, -1), ErrInvalid},
+		{"tampered ciphertext", sealed, flip(sealedToken, headerLen+sealed.aead.NonceSize()+1), ErrInvalid},
+		{"wrong key", newCodec(t, "other secret", false, clk), signedToken, ErrInvalid},
+		{"wrong key, encrypted", newCodec(t, "other secret", true, clk), sealedToken, ErrInvalid},
+		{"encrypted token without encryption", signed, sealedToken, ErrInvalid},
+		{"not base64", signed, "not a cursor!", ErrInvalid},
+		{"truncated", signed, signedToken[:10], ErrInvalid},
+		{"too long", signed, string(make([]byte, maxTokenLen+1)), ErrInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got position
+			if err := tt.codec.Decode(tt.token, &got); !errors.Is(err, tt.want) {
+				t.Errorf("Decode = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignedTokensSurviveEncryption(t *testing.T) {
+	signed := newCodec(t, "secret", false, clock.Real{})
+	token, err := signed.Encode(testPosition)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	var got position
+	if err := newCodec(t, "secret", true, clock.Real{}).Decode(token, &got); err != nil {
+		t.Errorf("Decode with encryption on = %v, want nil", err)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	clk := clock.NewFake(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	c := newCodec(t, "secret", true, clk)
+	token, err := c.Encode(testPosition)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	var got position
+	clk.Advance(59 * time.Minute)
+	if err := c.Decode(token, &got); err != nil {
+		t.Errorf("Decode before expiry = %v, want nil", err)
+	}
+	clk.Advance(2 * time.Minute)
+	if err := c.Decode(token, &got); !errors.Is(err, ErrExpired) {
+		t.Errorf("Decode after expiry = %v, want ErrExpired", err)
+	}
+}
+
+func TestNext(t *testing.T) {
+	c := newCodec(t, "secret", false, clock.Real{})
+	if token, err := c.Next(nil); token != "" || err != nil {
+		t.Errorf("Next(nil) = %q, %v, want no cursor", token, err)
+	}
+	if token, err := c.Next(testPosition); token == "" || err != nil {
+		t.Errorf("Next = %q, %v, want a cursor", token, err)
+	}
+
+	var disabled *Codec
+	if token, err := disabled.Next(nil); token != "" || err != nil {
+		t.Errorf("nil codec Next(nil) = %q, %v, want no cursor", token, err)
+	}
+	if _, err := disabled.Next(testPosition); !errors.Is(err, ErrDisabled) {
+		t.Errorf("nil codec Next = %v, want ErrDisabled", err)
+	}
+}
+
+func TestBind(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c := newCodec(t, "secret", true, clock.Real{})
+	token, err := c.Encode(testPosition)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	tests := []struct {
+		name        string
+		codec       *Codec
+		query       string
+		given, ok   bool
+		wantStatus  int
+		wantDecoded bool
+	}{
+		{"no cursor", c, "", false, true, http.StatusOK, false},
+		{"valid cursor", c, token, true, true, http.StatusOK, true},
+		{"tampered cursor", c, token[:len(token)-2] + "AA", true, false, http.StatusBadRequest, false},
+		{"cursors disabled", nil, token, true, false, http.StatusBadRequest, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			gc, _ := gin.CreateTestContext(w)
+			gc.Request = httptest.NewRequest(http.MethodGet, "/v1/events?"+QueryParam+"="+tt.query, nil)
+			var got position
+			given, ok := tt.codec.Bind(gc, &got)
+			if given != tt.given || ok != tt.ok {
+				t.Errorf("Bind = %v, %v, want %v, %v", given, ok, tt.given, tt.ok)
+			}
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantDecoded && got.ID != testPosition.ID {
+				t.Errorf("decoded %+v, want %+v", got, testPosition)
+			}
+		})
+	}
+}
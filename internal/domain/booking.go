@@ -0,0 +1,29 @@
+package domain
+
+import "time"
+
+// Booking is a row of the bookings table.
+type Booking struct {
+	ID             string        `json:"id"`
+	UserID         string        `json:"user_id"`
+	EventID        string        `json:"event_id"`
+	Status         BookingStatus `json:"status"`
+	Seats          Seats         `json:"seats"`
+	IdempotencyKey string        `json:"idempotency_key,omitempty"`
+	AmountPaid     float64       `json:"amount_paid"`
+	PaymentStatus  string        `json:"payment_status"`
+	Source         string        `json:"source"` // channel: web, mobile, box_office, waitlist or partner:<key id>
+	// Currency and AmountDue are the charge, in the event's currency. The Display fields are
+	// what the buyer was shown in their preferred currency, with the FX rate and snapshot date.
+	Currency        string     `json:"currency"`
+	AmountDue       float64    `json:"amount_due"`
+	DisplayCurrency *string    `json:"display_currency,omitempty"`
+	DisplayAmount   *float64   `json:"display_amount,omitempty"`
+	FXRate          *float64   `json:"fx_rate,omitempty"`
+	FXAsOf          *time.Time `json:"fx_as_of,omitempty"`
+	// SalePhaseID is the sale phase the booking was sold in, for events sold in phases
+	SalePhaseID *string   `json:"sale_phase_id,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Version     int       `json:"version"`
+}
@@ -0,0 +1,48 @@
+// Package domain holds the types every layer shares: bookings, events and seats as stored
+// in Postgres, served by the API and carried in worker messages, with typed statuses in
+// place of bare strings. Store packages alias these types, so a booking scanned in the store
+// is the booking the API encodes; nothing in between needs to re-parse it.
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Seats is the list of seat labels a booking holds. It is stored as a jsonb array and
+// encodes as a JSON array of strings everywhere, so seats never reach a client as a base64
+// blob of raw JSON. A nil Seats encodes as [], not null.
+type Seats []string
+
+func (s Seats) MarshalJSON() ([]byte, error) {
+	if s == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal([]string(s))
+}
+
+func (s *Seats) UnmarshalJSON(data []byte) error {
+	var labels []string
+	if err := json.Unmarshal(data, &labels); err != nil {
+		return fmt.Errorf("seats must be a JSON array of labels: %w", err)
+	}
+	*s = labels
+	return nil
+}
+
+// ParseSeats decodes seats from raw JSON, such as a message payload or an audit row.
+// Empty input is no seats.
+func ParseSeats(raw []byte) (Seats, error) {
+	if len(raw) == 0 {
+		return Seats{}, nil
+	}
+	var s Seats
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, err
+	}
+	if s == nil {
+		// A JSON null
+		s = Seats{}
+	}
+	return s, nil
+}
@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Event is a row of the events table. Visibility and PaymentCapture take the constants of
+// the events store.
+type Event struct {
+	ID                       string          `json:"id"`
+	Name                     string          `json:"name"`
+	Venue                    string          `json:"venue"`
+	StartTime                time.Time       `json:"start_time"`
+	EndTime                  time.Time       `json:"end_time"`
+	Category                 string          `json:"category"`
+	Capacity                 int             `json:"capacity"`
+	Reserved                 int             `json:"reserved"`
+	Metadata                 json.RawMessage `json:"metadata"`
+	Status                   EventStatus     `json:"status"`
+	TicketPrice              float64         `json:"ticket_price"`
+	CancellationFee          float64         `json:"cancellation_fee"`
+	Likes                    int             `json:"likes"`
+	MaximumTicketsPerBooking int             `json:"maximum_tickets_per_booking"`
+	OrganizerID              *string         `json:"organizer_id,omitempty"`
+	MaxTicketsPerUser        *int            `json:"max_tickets_per_user,omitempty"`
+	UserTicketWindowHours    *int            `json:"user_ticket_window_hours,omitempty"`
+	Latitude                 *float64        `json:"latitude,omitempty"`
+	Longitude                *float64        `json:"longitude,omitempty"`
+	// Feature toggles; clients hide the matching UI when one is off
+	WaitlistEnabled      bool `json:"waitlist_enabled"`
+	SeatSelectionEnabled bool `json:"seat_selection_enabled"`
+	LikesEnabled         bool `json:"likes_enabled"`
+	// NoSingleSeat stops bookings from leaving a lone empty seat between taken ones in a row
+	NoSingleSeat bool `json:"no_single_seat"`
+	// PaymentCapture is one of the Capture constants; manual-capture events are charged at
+	// CaptureAt, or StartTime when that's unset, unless the organizer confirms sooner
+	PaymentCapture string     `json:"payment_capture"`
+	CaptureAt      *time.Time `json:"capture_at,omitempty"`
+	// Currency is the ISO 4217 code prices are set and charged in
+	Currency string `json:"currency"`
+	// Visibility is one of the Visibility constants
+	Visibility string `json:"visibility"`
+	// Sandbox events are rehearsals: never public, left out of analytics and only bookable
+	// by admins and the event's testers
+	Sandbox bool `json:"sandbox,omitempty"`
+	// OwnerID is the organizer account that manages the event; nil for events created by admins
+	OwnerID   *string   `json:"owner_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// DisplayPrice is filled in when a caller asks for prices in another currency
+	DisplayPrice *DisplayPrice `json:"display_price,omitempty"`
+	// Availability is the precomputed badge on listings: available, limited or sold_out
+	Availability string `json:"availability,omitempty"`
+}
+
+// DisplayPrice is an event's prices converted at a daily FX rate. It is for display
+// only: bookings are still charged in the event's Currency.
+type DisplayPrice struct {
+	Currency        string    `json:"currency"`
+	TicketPrice     float64   `json:"ticket_price"`
+	CancellationFee float64   `json:"cancellation_fee"`
+	Rate            float64   `json:"rate"`
+	AsOf            time.Time `json:"as_of"`
+}
@@ -0,0 +1,18 @@
+package domain
+
+import "time"
+
+// Seat is a row of the seats table.
+type Seat struct {
+	ID            string     `json:"id"`
+	EventID       string     `json:"event_id"`
+	SeatLabel     string     `json:"seat_label"`
+	Section       string     `json:"section,omitempty"`
+	Row           string     `json:"row,omitempty"`
+	Tier          string     `json:"tier,omitempty"`
+	Status        string     `json:"status"`
+	HeldUntil     *time.Time `json:"held_until,omitempty"`
+	HeldByBooking *string    `json:"held_by_booking,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,57 @@
+package domain
+
+// BookingStatus is where a booking is in its lifecycle; the values match the bookings
+// status check constraint.
+type BookingStatus string
+
+const (
+	BookingPending    BookingStatus = "pending"
+	BookingBooked     BookingStatus = "booked"
+	BookingCancelled  BookingStatus = "cancelled"
+	BookingWaitlisted BookingStatus = "waitlisted"
+	BookingExpired    BookingStatus = "expired"
+)
+
+// Valid reports whether s is one of the BookingStatus constants.
+func (s BookingStatus) Valid() bool {
+	switch s {
+	case BookingPending, BookingBooked, BookingCancelled, BookingWaitlisted, BookingExpired:
+		return true
+	}
+	return false
+}
+
+// Active reports whether a booking in status s holds its seats.
+func (s BookingStatus) Active() bool {
+	return s == BookingPending || s == BookingBooked
+}
+
+// Settled reports whether a booking in status s can't change any more on its own.
+func (s BookingStatus) Settled() bool {
+	return s == BookingBooked || s == BookingCancelled || s == BookingExpired
+}
+
+// EventStatus is where an event is in its lifecycle; the values match the events status
+// check constraint.
+type EventStatus string
+
+const (
+	EventUpcoming  EventStatus = "upcoming"
+	EventOngoing   EventStatus = "ongoing"
+	EventCancelled EventStatus = "cancelled"
+	EventExpired   EventStatus = "expired"
+)
+
+// Valid reports whether s is one of the EventStatus constants.
+func (s EventStatus) Valid() bool {
+	switch s {
+	case EventUpcoming, EventOngoing, EventCancelled, EventExpired:
+		return true
+	}
+	return false
+}
+
+// Over reports whether an event in status s is cancelled or expired.
+func (s EventStatus) Over() bool {
+	return s == EventCancelled || s == EventExpired
+}
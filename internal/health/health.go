@@ -0,0 +1,150 @@
+// Package health answers liveness and readiness probes. Liveness only says the process is
+// serving HTTP; readiness pings every dependency the API needs to serve bookings (Postgres,
+// Redis, Kafka), each under its own timeout, so a load balancer stops routing to an instance
+// that can't reach one of them.
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/metrics"
+)
+
+// Check pings one dependency, returning nil if it is reachable.
+type Check func(ctx context.Context) error
+
+// Readiness states reported by Checker.Ready.
+const (
+	StatusReady       = "ready"
+	StatusStarting    = "starting"
+	StatusUnavailable = "unavailable"
+	StatusDraining    = "draining"
+)
+
+// Result is the outcome of one dependency's check.
+type Result struct {
+	Status    string `json:"status"` // up or down
+	LatencyMS int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Report is a readiness answer: the overall status and each dependency's result.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker runs the registered checks. It refuses readiness until WaitReady has seen every
+// dependency reachable once, and again after Drain, so an instance joins the load balancer
+// only once it can serve and leaves it before it stops serving.
+type Checker struct {
+	timeout time.Duration
+	checks  []namedCheck
+
+	started  atomic.Bool
+	draining atomic.Bool
+}
+
+// NewChecker returns a checker that gives each check at most timeout.
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Add registers a dependency check. Checks must be added before the checker is served.
+func (c *Checker) Add(name string, check Check) *Checker {
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+	return c
+}
+
+// Check runs every check concurrently and reports each result; the status is ready only
+// when all of them pass.
+func (c *Checker) Check(ctx context.Context) Report {
+	report := Report{Status: StatusReady, Checks: make(map[string]Result, len(c.checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, nc := range c.checks {
+		wg.Add(1)
+		go func(nc namedCheck) {
+			defer wg.Done()
+			res := c.run(ctx, nc)
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[nc.name] = res
+			if res.Status != "up" {
+				report.Status = StatusUnavailable
+			}
+		}(nc)
+	}
+	wg.Wait()
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, nc namedCheck) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	start := time.Now()
+	err := nc.check(ctx)
+	res := Result{Status: "up", LatencyMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		res.Status, res.Error = "down", err.Error()
+		metrics.DependencyUp.WithLabelValues(nc.name).Set(0)
+	} else {
+		metrics.DependencyUp.WithLabelValues(nc.name).Set(1)
+	}
+	return res
+}
+
+// Ready is the readiness answer: starting until WaitReady succeeds, draining after Drain,
+// otherwise the live check.
+func (c *Checker) Ready(ctx context.Context) Report {
+	switch {
+	case c.draining.Load():
+		return Report{Status: StatusDraining, Checks: map[string]Result{}}
+	case !c.started.Load():
+		report := c.Check(ctx)
+		report.Status = StatusStarting
+		return report
+	}
+	return c.Check(ctx)
+}
+
+// WaitReady checks the dependencies every interval until all of them pass once, then lets
+// readiness through. It logs what is still unreachable on each attempt and returns early,
+// still not ready, when ctx is done.
+func (c *Checker) WaitReady(ctx context.Context, log *zap.Logger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		report := c.Check(ctx)
+		if report.Status == StatusReady {
+			c.started.Store(true)
+			log.Info("Dependencies reachable, accepting traffic")
+			return
+		}
+		for name, res := range report.Checks {
+			if res.Status != "up" {
+				log.Warn("Waiting for dependency", zap.String("dependency", name), zap.String("error", res.Error))
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Drain fails readiness from now on, for shutdown.
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
@@ -0,0 +1,243 @@
+package kafkax
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// ContentTypeHeader names the message header that tells consumers which codec encoded the value.
+const ContentTypeHeader = "content-type"
+
+// Codec encodes envelopes to and from the Kafka message value.
+type Codec interface {
+	ContentType() string
+	Marshal(e Envelope) ([]byte, error)
+	Unmarshal(b []byte) (Envelope, error)
+}
+
+// CodecFor returns the codec configured by name ("json" or "protobuf").
+func CodecFor(name string) (Codec, error) {
+	switch name {
+	case "", "json":
+		return JSONCodec{}, nil
+	case "protobuf", "proto":
+		return ProtoCodec{}, nil
+	}
+	return nil, fmt.Errorf("unknown kafka codec: %s", name)
+}
+
+func codecForContentType(ct string) (Codec, error) {
+	switch ct {
+	case "", JSONCodec{}.ContentType():
+		return JSONCodec{}, nil
+	case ProtoCodec{}.ContentType():
+		return ProtoCodec{}, nil
+	}
+	return nil, fmt.Errorf("unsupported content type: %s", ct)
+}
+
+// DecodeMessage picks the codec from the message's content-type header (JSON when absent)
+// and validates the decoded envelope against its schema.
+func DecodeMessage(m kafka.Message) (Envelope, error) {
+	var ct string
+	for _, h := range m.Headers {
+		if h.Key == ContentTypeHeader {
+			ct = string(h.Value)
+		}
+	}
+	c, err := codecForContentType(ct)
+	if err != nil {
+		return Envelope{}, &ValidationError{Details: []string{err.Error()}}
+	}
+	e, err := c.Unmarshal(m.Value)
+	if err != nil {
+		return e, err
+	}
+	return e, Validate(e)
+}
+
+// JSONCodec is the default, human-readable encoding.
+type JSONCodec struct{}
+
+func (JSONCodec) ContentType() string { return "application/json" }
+
+func (JSONCodec) Marshal(e Envelope) ([]byte, error) { return json.Marshal(e) }
+
+func (JSONCodec) Unmarshal(b []byte) (Envelope, error) {
+	var e Envelope
+	if err := json.Unmarshal(b, &e); err != nil {
+		return e, &ValidationError{Details: []string{"malformed json: " + err.Error()}}
+	}
+	if e.Version == 0 && len(e.Payload) == 0 {
+		// Legacy message: the whole body is the payload.
+		e.Payload = b
+	}
+	return e, nil
+}
+
+// ProtoCodec encodes envelopes in the Protobuf wire format without generated code.
+// Payload fields are numbered by their x-proto-field annotation in the type's schema, which
+// the append-only compatibility policy keeps stable across versions.
+//
+//	message Envelope {
+//	  string type = 1;
+//	  int64 version = 2;
+//	  int64 produced_at_unix_nano = 3;
+//	  string producer = 4;
+//	  bytes payload = 5; // fields numbered by schema position
+//	}
+type ProtoCodec struct{}
+
+const (
+	protoFieldType = iota + 1
+	protoFieldVersion
+	protoFieldProducedAt
+	protoFieldProducer
+	protoFieldPayload
+)
+
+var errMalformedProto = errors.New("malformed protobuf")
+
+func (ProtoCodec) ContentType() string { return "application/x-protobuf" }
+
+func (ProtoCodec) Marshal(e Envelope) ([]byte, error) {
+	fields, err := schemaFields(e.Type, e.Version)
+	if err != nil {
+		return nil, err
+	}
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(e.Payload, &payload); err != nil {
+		return nil, err
+	}
+
+	var p []byte
+	for _, f := range fields {
+		raw, ok := payload[f.Name]
+		if !ok || string(raw) == "null" {
+			continue
+		}
+		num := protowire.Number(f.Number)
+		switch f.Kind {
+		case kindString:
+			var s string
+			if err := json.Unmarshal(raw, &s); err != nil {
+				return nil, fmt.Errorf("%s: %w", f.Name, err)
+			}
+			p = protowire.AppendTag(p, num, protowire.BytesType)
+			p = protowire.AppendString(p, s)
+		case kindArray:
+			var items []string
+			if err := json.Unmarshal(raw, &items); err != nil {
+				return nil, fmt.Errorf("%s: %w", f.Name, err)
+			}
+			for _, s := range items {
+				p = protowire.AppendTag(p, num, protowire.BytesType)
+				p = protowire.AppendString(p, s)
+			}
+		}
+	}
+
+	var b []byte
+	b = protowire.AppendTag(b, protoFieldType, protowire.BytesType)
+	b = protowire.AppendString(b, e.Type)
+	b = protowire.AppendTag(b, protoFieldVersion, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(e.Version))
+	b = protowire.AppendTag(b, protoFieldProducedAt, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(e.ProducedAt.UnixNano()))
+	b = protowire.AppendTag(b, protoFieldProducer, protowire.BytesType)
+	b = protowire.AppendString(b, e.Producer)
+	b = protowire.AppendTag(b, protoFieldPayload, protowire.BytesType)
+	b = protowire.AppendBytes(b, p)
+	return b, nil
+}
+
+func (ProtoCodec) Unmarshal(b []byte) (Envelope, error) {
+	var e Envelope
+	var payload []byte
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return e, &ValidationError{Details: []string{errMalformedProto.Error()}}
+		}
+		b = b[n:]
+		switch {
+		case num == protoFieldType && typ == protowire.BytesType:
+			e.Type, n = protowire.ConsumeString(b)
+		case num == protoFieldVersion && typ == protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(b)
+			e.Version = int(v)
+		case num == protoFieldProducedAt && typ == protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(b)
+			e.ProducedAt = time.Unix(0, int64(v)).UTC()
+		case num == protoFieldProducer && typ == protowire.BytesType:
+			e.Producer, n = protowire.ConsumeString(b)
+		case num == protoFieldPayload && typ == protowire.BytesType:
+			payload, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return e, &ValidationError{Details: []string{errMalformedProto.Error()}}
+		}
+		b = b[n:]
+	}
+
+	fields, err := schemaFields(e.Type, e.Version)
+	if err != nil {
+		return e, &ValidationError{Type: e.Type, Version: e.Version, Details: []string{err.Error()}}
+	}
+	out := map[string]any{}
+	for len(payload) > 0 {
+		num, typ, n := protowire.ConsumeTag(payload)
+		if n < 0 {
+			return e, &ValidationError{Type: e.Type, Version: e.Version, Details: []string{errMalformedProto.Error()}}
+		}
+		payload = payload[n:]
+		idx := int(num) - 1
+		if idx < 0 || idx >= len(fields) || typ != protowire.BytesType {
+			// Unknown fields from newer producers are skipped.
+			n = protowire.ConsumeFieldValue(num, typ, payload)
+		} else {
+			var s string
+			s, n = protowire.ConsumeString(payload)
+			f := fields[idx]
+			if f.Kind == kindArray {
+				items, _ := out[f.Name].([]string)
+				out[f.Name] = append(items, s)
+			} else {
+				out[f.Name] = s
+			}
+		}
+		if n < 0 {
+			return e, &ValidationError{Type: e.Type, Version: e.Version, Details: []string{errMalformedProto.Error()}}
+		}
+		payload = payload[n:]
+	}
+	for _, f := range fields {
+		// An empty repeated field is indistinguishable from an absent one on the wire.
+		if _, ok := out[f.Name]; !ok && f.Kind == kindArray {
+			out[f.Name] = []string{}
+		}
+	}
+	e.Payload, err = json.Marshal(out)
+	return e, err
+}
+
+func schemaFields(typ string, version int) ([]field, error) {
+	s, ok := schemas[typ]
+	if !ok {
+		return nil, fmt.Errorf("unknown message type: %s", typ)
+	}
+	v, ok := s.Versions[version]
+	if !ok {
+		return nil, fmt.Errorf("unknown version %d for %s", version, typ)
+	}
+	return v.fields, nil
+}
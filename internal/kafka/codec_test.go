@@ -0,0 +1,71 @@
+package kafkax
+
+import (
+	"bytes"
+	"testing"
+)
+
+// bookingEnvelope is a finalize_booking message as the API publishes it.
+func bookingEnvelope(tb testing.TB) Envelope {
+	tb.Helper()
+	e, err := NewEnvelope(TypeFinalizeBooking, "api", map[string]any{
+		"booking_id":      "7b0c7a52-7a0e-4d3a-9c55-2f0f4c1f9a11",
+		"event_id":        "3e2a9d4c-1b7f-4f4e-8a6d-0c5b9e8f7a21",
+		"user_id":         "9f8e7d6c-5b4a-4392-8170-6f5e4d3c2b1a",
+		"seats":           []string{"A1", "A2", "A3", "A4"},
+		"idempotency_key": "checkout-5d41402abc4b2a76b9719d911017c592",
+	})
+	if err != nil {
+		tb.Fatalf("NewEnvelope: %v", err)
+	}
+	return e
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	want := bookingEnvelope(t)
+	for _, c := range []Codec{JSONCodec{}, ProtoCodec{}} {
+		t.Run(c.ContentType(), func(t *testing.T) {
+			b, err := c.Marshal(want)
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			got, err := c.Unmarshal(b)
+			if err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if got.Type != want.Type || got.Version != want.Version || got.Producer != want.Producer ||
+				!got.ProducedAt.Equal(want.ProducedAt) || !bytes.Equal(got.Payload, want.Payload) {
+				t.Errorf("round trip = %+v, want %+v", got, want)
+			}
+			if err := Validate(got); err != nil {
+				t.Errorf("Validate: %v", err)
+			}
+		})
+	}
+}
+
+// benchmarkCodec marshals and unmarshals a booking envelope, reporting the encoded size as
+// the bytes processed per operation.
+func benchmarkCodec(b *testing.B, c Codec) {
+	e := bookingEnvelope(b)
+	encoded, err := c.Marshal(e)
+	if err != nil {
+		b.Fatalf("Marshal: %v", err)
+	}
+	b.SetBytes(int64(len(encoded)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		out, err := c.Marshal(e)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := c.Unmarshal(out); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkJSONCodec(b *testing.B) { benchmarkCodec(b, JSONCodec{}) }
+
+func BenchmarkProtoCodec(b *testing.B) { benchmarkCodec(b, ProtoCodec{}) }
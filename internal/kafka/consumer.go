@@ -2,7 +2,6 @@ package kafkax
 
 import (
 	"context"
-	"encoding/json"
 
 	"github.com/segmentio/kafka-go"
 )
@@ -30,14 +29,3 @@ func (c *Consumer) Commit(ctx context.Context, m kafka.Message) error {
 }
 
 func (c *Consumer) Close() error { return c.reader.Close() }
-
-// Envelope is a generic event schema.
-type Envelope struct {
-	Type string `json:"type"`
-}
-
-func ParseEnvelope(b []byte) (Envelope, error) {
-	var e Envelope
-	err := json.Unmarshal(b, &e)
-	return e, err
-}
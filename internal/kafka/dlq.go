@@ -0,0 +1,139 @@
+package kafkax
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Headers the finalizer adds to a message it dead-letters, and the replay count dlq_replay
+// adds when it sends one back.
+const (
+	DLQReasonHeader          = "dlq-reason"
+	DLQErrorHeader           = "dlq-error"
+	DLQAttemptsHeader        = "dlq-attempts"
+	DLQSourceTopicHeader     = "dlq-source-topic"
+	DLQSourcePartitionHeader = "dlq-source-partition"
+	DLQSourceOffsetHeader    = "dlq-source-offset"
+	ReplayCountHeader        = "replay-count"
+)
+
+// Header returns the value of m's last header named key, or "".
+func Header(m kafka.Message, key string) string {
+	v := ""
+	for _, h := range m.Headers {
+		if h.Key == key {
+			v = string(h.Value)
+		}
+	}
+	return v
+}
+
+// ReplayCount is how many times m has been replayed from the DLQ.
+func ReplayCount(m kafka.Message) int {
+	n, _ := strconv.Atoi(Header(m, ReplayCountHeader))
+	return n
+}
+
+// Attempts is how many times the finalizer tried m before dead-lettering it; 1 for messages
+// dead-lettered before it retried.
+func Attempts(m kafka.Message) int {
+	n, err := strconv.Atoi(Header(m, DLQAttemptsHeader))
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
+
+// ReplayHeaders are m's headers for sending it back to the topic it was dead-lettered from:
+// its original headers, without the ones the finalizer added, and with the replay count
+// raised by one. A message that fails again is dead-lettered with its count, so it shows how
+// often it has been tried.
+func ReplayHeaders(m kafka.Message) []kafka.Header {
+	var out []kafka.Header
+	for _, h := range m.Headers {
+		switch h.Key {
+		case DLQReasonHeader, DLQErrorHeader, DLQAttemptsHeader, DLQSourceTopicHeader, DLQSourcePartitionHeader, DLQSourceOffsetHeader, ReplayCountHeader:
+			continue
+		}
+		out = append(out, h)
+	}
+	return append(out, kafka.Header{Key: ReplayCountHeader, Value: []byte(strconv.Itoa(ReplayCount(m) + 1))})
+}
+
+// ReadTopic calls fn with every message the topic retains, partition by partition in offset
+// order. It reads without a consumer group, so nothing is committed and the topic is left as
+// it was, and stops at the offsets the topic had ended at when it started.
+func ReadTopic(ctx context.Context, brokers []string, topic string, fn func(kafka.Message) error) error {
+	offsets, err := topicOffsets(ctx, &kafka.Client{Addr: kafka.TCP(brokers...)}, topic)
+	if err != nil {
+		return err
+	}
+	for _, po := range offsets {
+		if po.LastOffset <= po.FirstOffset {
+			continue
+		}
+		if err := readPartition(ctx, brokers, topic, po, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readPartition(ctx context.Context, brokers []string, topic string, po kafka.PartitionOffsets, fn func(kafka.Message) error) error {
+	r := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:   brokers,
+		Topic:     topic,
+		Partition: po.Partition,
+		MinBytes:  1,
+		MaxBytes:  10e6,
+	})
+	defer r.Close()
+	if err := r.SetOffset(po.FirstOffset); err != nil {
+		return err
+	}
+	for {
+		m, err := r.ReadMessage(ctx)
+		if err != nil {
+			return err
+		}
+		if err := fn(m); err != nil {
+			return err
+		}
+		if m.Offset+1 >= po.LastOffset {
+			return nil
+		}
+	}
+}
+
+// topicOffsets returns the first and last offset of each of the topic's partitions.
+func topicOffsets(ctx context.Context, client *kafka.Client, topic string) ([]kafka.PartitionOffsets, error) {
+	meta, err := client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{topic}})
+	if err != nil {
+		return nil, err
+	}
+	var reqs []kafka.OffsetRequest
+	for _, t := range meta.Topics {
+		if t.Error != nil {
+			return nil, t.Error
+		}
+		for _, part := range t.Partitions {
+			reqs = append(reqs, kafka.FirstOffsetOf(part.ID), kafka.LastOffsetOf(part.ID))
+		}
+	}
+	if len(reqs) == 0 {
+		return nil, nil
+	}
+
+	res, err := client.ListOffsets(ctx, &kafka.ListOffsetsRequest{Topics: map[string][]kafka.OffsetRequest{topic: reqs}})
+	if err != nil {
+		return nil, err
+	}
+	for _, po := range res.Topics[topic] {
+		if po.Error != nil {
+			return nil, po.Error
+		}
+	}
+	return res.Topics[topic], nil
+}
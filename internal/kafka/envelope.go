@@ -0,0 +1,150 @@
+package kafkax
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Message types carried on the bookings topic.
+const (
+	TypeFinalizeBooking = "finalize_booking"
+	TypeBookingTimeout  = "booking_timeout"
+)
+
+// Envelope wraps every message published to Kafka so consumers can validate
+// the payload shape before acting on it.
+//
+// Compatibility policy: a schema version may only add optional fields, never
+// remove or retype existing ones. Consumers accept every version from 0 up to
+// the current version of a type and ignore unknown fields. Messages with an
+// unknown type, a version newer than the consumer knows, or a payload that does
+// not match its schema are rejected and routed to the DLQ.
+//
+// Payload schemas are the JSON Schema documents under schemas/, one per type and
+// version; see schema.go.
+//
+// Version 0 is the legacy un-enveloped format where payload fields sit at the
+// top level next to "type"; it is still accepted for in-flight messages.
+type Envelope struct {
+	Type       string          `json:"type"`
+	Version    int             `json:"version"`
+	ProducedAt time.Time       `json:"produced_at"`
+	Producer   string          `json:"producer"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// fieldKind is the JSON type of a payload field in ProtoCodec's encoding.
+type fieldKind string
+
+const (
+	kindString fieldKind = "string"
+	kindArray  fieldKind = "array"
+)
+
+// field is a payload property with its ProtoCodec field number.
+type field struct {
+	Name   string
+	Kind   fieldKind
+	Number int
+}
+
+// payloadSchema is one version of a message type's payload schema.
+type payloadSchema struct {
+	doc    *jsonSchema
+	raw    []byte
+	fields []field
+}
+
+// schema holds every version of a message type's payload schema.
+type schema struct {
+	CurrentVersion int
+	Versions       map[int]*payloadSchema
+}
+
+// schemas are the documents under schemas/, by message type. A broken document stops the
+// process at start rather than fail every message.
+var schemas = func() map[string]schema {
+	s, err := loadSchemas()
+	if err != nil {
+		panic("kafkax: " + err.Error())
+	}
+	return s
+}()
+
+// ValidationError is returned when a message does not match its schema.
+type ValidationError struct {
+	Type    string
+	Version int
+	Details []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("schema validation failed for %s v%d: %s", e.Type, e.Version, strings.Join(e.Details, "; "))
+}
+
+// NewEnvelope wraps payload in an envelope using the current schema version for typ.
+func NewEnvelope(typ, producer string, payload any) (Envelope, error) {
+	s, ok := schemas[typ]
+	if !ok {
+		return Envelope{}, fmt.Errorf("unknown message type: %s", typ)
+	}
+	p, err := json.Marshal(payload)
+	if err != nil {
+		return Envelope{}, err
+	}
+	return Envelope{
+		Type:       typ,
+		Version:    s.CurrentVersion,
+		ProducedAt: time.Now().UTC(),
+		Producer:   producer,
+		Payload:    p,
+	}, nil
+}
+
+// ParseEnvelope decodes a JSON encoded message and validates its payload against the
+// registered schema. Validation failures are returned as *ValidationError.
+func ParseEnvelope(b []byte) (Envelope, error) {
+	e, err := JSONCodec{}.Unmarshal(b)
+	if err != nil {
+		return e, err
+	}
+	return e, Validate(e)
+}
+
+// Validate checks e's payload against the JSON Schema document for its type and version.
+func Validate(e Envelope) error {
+	verr := &ValidationError{Type: e.Type, Version: e.Version}
+	if e.Type == "" {
+		verr.Details = append(verr.Details, "missing type")
+		return verr
+	}
+	s, ok := schemas[e.Type]
+	if !ok {
+		verr.Details = append(verr.Details, "unknown type")
+		return verr
+	}
+	if e.Version > s.CurrentVersion {
+		verr.Details = append(verr.Details, fmt.Sprintf("unsupported version, max %d", s.CurrentVersion))
+		return verr
+	}
+	version, ok := s.Versions[e.Version]
+	if !ok {
+		verr.Details = append(verr.Details, "unknown version")
+		return verr
+	}
+
+	var payload any
+	dec := json.NewDecoder(bytes.NewReader(e.Payload))
+	dec.UseNumber()
+	if err := dec.Decode(&payload); err != nil {
+		verr.Details = append(verr.Details, "payload is not valid JSON")
+		return verr
+	}
+	if verr.Details = version.doc.validate(payload, "", nil); len(verr.Details) > 0 {
+		return verr
+	}
+	return nil
+}
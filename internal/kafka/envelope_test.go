@@ -0,0 +1,113 @@
+package kafkax
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateRejects(t *testing.T) {
+	valid := `{"booking_id":"b1","event_id":"e1","user_id":"u1","seats":["A1"],"idempotency_key":null}`
+	tests := []struct {
+		name    string
+		typ     string
+		version int
+		payload string
+		detail  string
+	}{
+		{"missing type", "", 1, valid, "missing type"},
+		{"unknown type", "refund_booking", 1, valid, "unknown type"},
+		{"newer version", TypeFinalizeBooking, 2, valid, "unsupported version, max 1"},
+		{"not JSON", TypeFinalizeBooking, 1, `{"booking_id":`, "payload is not valid JSON"},
+		{"not an object", TypeFinalizeBooking, 1, `["b1"]`, "payload: expected object"},
+		{"missing booking_id", TypeFinalizeBooking, 1, `{"event_id":"e1","user_id":"u1","seats":[]}`, "booking_id: required"},
+		{"missing seats", TypeBookingTimeout, 1, `{"booking_id":"b1","event_id":"e1","user_id":"u1"}`, "seats: required"},
+		{"null booking_id", TypeFinalizeBooking, 1, `{"booking_id":null,"event_id":"e1","user_id":"u1","seats":[]}`, "booking_id: expected string"},
+		{"empty booking_id", TypeFinalizeBooking, 1, `{"booking_id":"","event_id":"e1","user_id":"u1","seats":[]}`, "booking_id: shorter than 1"},
+		{"numeric event_id", TypeFinalizeBooking, 1, `{"booking_id":"b1","event_id":42,"user_id":"u1","seats":[]}`, "event_id: expected string"},
+		{"seats not an array", TypeFinalizeBooking, 1, `{"booking_id":"b1","event_id":"e1","user_id":"u1","seats":"A1"}`, "seats: expected array"},
+		{"numeric seat", TypeFinalizeBooking, 1, `{"booking_id":"b1","event_id":"e1","user_id":"u1","seats":["A1",2]}`, "seats[1]: expected string"},
+		{"numeric idempotency_key", TypeFinalizeBooking, 1, `{"booking_id":"b1","event_id":"e1","user_id":"u1","seats":[],"idempotency_key":7}`, "idempotency_key: expected string or null"},
+		{"legacy without user_id", TypeBookingTimeout, 0, `{"type":"booking_timeout","booking_id":"b1","event_id":"e1","seats":[]}`, "user_id: required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(Envelope{Type: tt.typ, Version: tt.version, ProducedAt: time.Now(), Payload: json.RawMessage(tt.payload)})
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("Validate = %v, want a ValidationError", err)
+			}
+			if !strings.Contains(strings.Join(verr.Details, "; "), tt.detail) {
+				t.Errorf("Validate details = %q, want %q", verr.Details, tt.detail)
+			}
+		})
+	}
+}
+
+func TestValidateAccepts(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int
+		payload string
+	}{
+		{"current", 1, `{"booking_id":"b1","event_id":"e1","user_id":"u1","seats":["A1","A2"],"idempotency_key":"k"}`},
+		{"without idempotency key", 1, `{"booking_id":"b1","event_id":"e1","user_id":"u1","seats":[],"idempotency_key":null}`},
+		{"unknown fields", 1, `{"booking_id":"b1","event_id":"e1","user_id":"u1","seats":[],"phase_id":"p1"}`},
+		{"legacy", 0, `{"type":"finalize_booking","booking_id":"b1","event_id":"e1","user_id":"u1","seats":["A1"]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := Envelope{Type: TypeFinalizeBooking, Version: tt.version, Payload: json.RawMessage(tt.payload)}
+			if err := Validate(e); err != nil {
+				t.Errorf("Validate = %v", err)
+			}
+		})
+	}
+}
+
+func TestSchemaDocuments(t *testing.T) {
+	want := []field{
+		{Name: "booking_id", Kind: kindString, Number: 1},
+		{Name: "event_id", Kind: kindString, Number: 2},
+		{Name: "user_id", Kind: kindString, Number: 3},
+		{Name: "seats", Kind: kindArray, Number: 4},
+		{Name: "idempotency_key", Kind: kindString, Number: 5},
+	}
+	for _, typ := range []string{TypeFinalizeBooking, TypeBookingTimeout} {
+		for version := 0; version <= 1; version++ {
+			// ProtoCodec numbers fields by x-proto-field, so messages already on the wire
+			// depend on these numbers
+			fields, err := schemaFields(typ, version)
+			if err != nil {
+				t.Fatalf("%s v%d: %v", typ, version, err)
+			}
+			if !reflect.DeepEqual(fields, want) {
+				t.Errorf("%s v%d fields = %+v, want %+v", typ, version, fields, want)
+			}
+			doc, ok := Schema(typ, version)
+			if !ok {
+				t.Fatalf("%s v%d: no document", typ, version)
+			}
+			var parsed map[string]any
+			if err := json.Unmarshal(doc, &parsed); err != nil || parsed["$schema"] != "https://json-schema.org/draft/2020-12/schema" {
+				t.Errorf("%s v%d: document is not a 2020-12 JSON Schema: %v", typ, version, err)
+			}
+		}
+	}
+}
+
+func TestParseSchemaRefusesUnsupportedKeywords(t *testing.T) {
+	for _, doc := range []string{
+		`{"type":"object","additionalProperties":false}`,
+		`{"type":"object","properties":{"seats":{"type":"array","maxItems":4}}}`,
+		`{"type":"object","properties":{"id":{"type":"string","format":"uuid"}}}`,
+		`{"type":"uuid"}`,
+	} {
+		if _, err := parseSchema([]byte(doc)); err == nil {
+			t.Errorf("parseSchema(%s) accepted a keyword the validator doesn't implement", doc)
+		}
+	}
+}
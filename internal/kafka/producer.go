@@ -5,10 +5,15 @@ import (
 	"time"
 
 	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/tracing"
 )
 
 type Producer struct {
 	writer *kafka.Writer
+	codec  Codec
 }
 
 func NewProducer(brokers []string, topic string) *Producer {
@@ -17,16 +22,75 @@ func NewProducer(brokers []string, topic string) *Producer {
 		Topic:        topic,
 		RequiredAcks: kafka.RequireAll,
 		Balancer:     &kafka.Hash{},
-	}}
+	}, codec: JSONCodec{}}
+}
+
+// WithCodec sets the codec used by PublishEnvelope.
+func (p *Producer) WithCodec(c Codec) *Producer {
+	p.codec = c
+	return p
 }
 
-func (p *Producer) Publish(ctx context.Context, key, value []byte) error {
+// Publish writes one message, carrying ctx's trace context in its headers so the consumer's
+// span continues the trace.
+func (p *Producer) Publish(ctx context.Context, key, value []byte, headers ...kafka.Header) error {
+	ctx, span := tracing.StartKind(ctx, trace.SpanKindProducer, "kafka publish "+p.writer.Topic,
+		attribute.String("messaging.system", "kafka"),
+		attribute.String("messaging.destination.name", p.writer.Topic),
+		attribute.String("messaging.kafka.message.key", string(key)),
+	)
 	msg := kafka.Message{
-		Key:   key,
-		Value: value,
-		Time:  time.Now(),
+		Key:     key,
+		Value:   value,
+		Headers: injectTrace(ctx, append([]kafka.Header(nil), headers...)),
+		Time:    time.Now(),
+	}
+	err := p.writer.WriteMessages(ctx, msg)
+	tracing.End(span, err)
+	return err
+}
+
+// PublishEnvelope encodes e with the producer's codec and tags the message with its content type.
+func (p *Producer) PublishEnvelope(ctx context.Context, key []byte, e Envelope) error {
+	value, err := p.codec.Marshal(e)
+	if err != nil {
+		return err
 	}
-	return p.writer.WriteMessages(ctx, msg)
+	return p.Publish(ctx, key, value, kafka.Header{Key: ContentTypeHeader, Value: []byte(p.codec.ContentType())})
 }
 
+// Topic is the topic the producer writes to.
+func (p *Producer) Topic() string { return p.writer.Topic }
+
 func (p *Producer) Close() error { return p.writer.Close() }
+
+// Ping asks the brokers for the producer's topic metadata, failing if no broker answers or
+// the topic doesn't exist.
+func (p *Producer) Ping(ctx context.Context) error {
+	client := &kafka.Client{Addr: p.writer.Addr}
+	meta, err := client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{p.writer.Topic}})
+	if err != nil {
+		return err
+	}
+	for _, t := range meta.Topics {
+		if t.Error != nil {
+			return t.Error
+		}
+	}
+	return nil
+}
+
+// Depth returns how many messages the producer's topic currently retains across its
+// partitions, last offset minus first offset. For a topic nothing consumes, such as the DLQ,
+// that is its backlog.
+func (p *Producer) Depth(ctx context.Context) (int64, error) {
+	offsets, err := topicOffsets(ctx, &kafka.Client{Addr: p.writer.Addr}, p.writer.Topic)
+	if err != nil {
+		return 0, err
+	}
+	var depth int64
+	for _, po := range offsets {
+		depth += po.LastOffset - po.FirstOffset
+	}
+	return depth, nil
+}
@@ -0,0 +1,264 @@
+package kafkax
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Payload schemas are JSON Schema (draft 2020-12) documents, one per message type and
+// version at schemas/<type>/v<version>.json, so producers in other languages can validate
+// against the same files. Each payload property carries an x-proto-field annotation, its
+// field number in ProtoCodec's encoding.
+//
+// The validator here implements the keywords the documents use: type, required,
+// properties, items and minLength, with $schema, $id, $comment, title, description and
+// x-proto-field read as annotations. A document using any other keyword fails to load
+// rather than have it silently ignored.
+//
+//go:embed schemas
+var schemaFS embed.FS
+
+// jsonSchema is a parsed JSON Schema document or subschema.
+type jsonSchema struct {
+	Types      []string
+	Required   []string
+	Properties map[string]*jsonSchema
+	Items      *jsonSchema
+	MinLength  *int
+	ProtoField int
+}
+
+var schemaKeywords = map[string]bool{
+	"$schema": true, "$id": true, "$comment": true, "title": true, "description": true,
+	"type": true, "required": true, "properties": true, "items": true, "minLength": true,
+	"x-proto-field": true,
+}
+
+var jsonTypes = map[string]bool{
+	"object": true, "array": true, "string": true, "number": true, "integer": true, "boolean": true, "null": true,
+}
+
+// parseSchema parses a schema document, refusing keywords the validator doesn't implement.
+func parseSchema(b []byte) (*jsonSchema, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	s := &jsonSchema{}
+	for key, raw := range doc {
+		if !schemaKeywords[key] {
+			return nil, fmt.Errorf("unsupported keyword %q", key)
+		}
+		var err error
+		switch key {
+		case "type":
+			var one string
+			if json.Unmarshal(raw, &one) == nil {
+				s.Types = []string{one}
+			} else {
+				err = json.Unmarshal(raw, &s.Types)
+			}
+			for _, t := range s.Types {
+				if !jsonTypes[t] {
+					return nil, fmt.Errorf("unknown type %q", t)
+				}
+			}
+		case "required":
+			err = json.Unmarshal(raw, &s.Required)
+		case "properties":
+			var props map[string]json.RawMessage
+			if err = json.Unmarshal(raw, &props); err == nil {
+				s.Properties = make(map[string]*jsonSchema, len(props))
+				for name, sub := range props {
+					if s.Properties[name], err = parseSchema(sub); err != nil {
+						return nil, fmt.Errorf("properties.%s: %w", name, err)
+					}
+				}
+			}
+		case "items":
+			if s.Items, err = parseSchema(raw); err != nil {
+				return nil, fmt.Errorf("items: %w", err)
+			}
+		case "minLength":
+			s.MinLength = new(int)
+			err = json.Unmarshal(raw, s.MinLength)
+		case "x-proto-field":
+			err = json.Unmarshal(raw, &s.ProtoField)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	return s, nil
+}
+
+// validate appends to details every way v, decoded with UseNumber, breaks s; at is the
+// path to v, empty for the payload itself.
+func (s *jsonSchema) validate(v any, at string, details []string) []string {
+	if len(s.Types) > 0 && !s.allows(v) {
+		return append(details, fmt.Sprintf("%s: expected %s", label(at), strings.Join(s.Types, " or ")))
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				details = append(details, fmt.Sprintf("%s: required", join(at, name)))
+			}
+		}
+		names := make([]string, 0, len(s.Properties))
+		for name := range s.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if value, ok := v[name]; ok {
+				details = s.Properties[name].validate(value, join(at, name), details)
+			}
+		}
+	case []any:
+		if s.Items != nil {
+			for i, item := range v {
+				details = s.Items.validate(item, at+"["+strconv.Itoa(i)+"]", details)
+			}
+		}
+	case string:
+		if s.MinLength != nil && utf8.RuneCountInString(v) < *s.MinLength {
+			details = append(details, fmt.Sprintf("%s: shorter than %d", label(at), *s.MinLength))
+		}
+	}
+	return details
+}
+
+func (s *jsonSchema) allows(v any) bool {
+	for _, t := range s.Types {
+		switch v := v.(type) {
+		case map[string]any:
+			if t == "object" {
+				return true
+			}
+		case []any:
+			if t == "array" {
+				return true
+			}
+		case string:
+			if t == "string" {
+				return true
+			}
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+		case nil:
+			if t == "null" {
+				return true
+			}
+		case json.Number:
+			if t == "number" {
+				return true
+			}
+			if _, err := v.Int64(); t == "integer" && err == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func join(at, name string) string {
+	if at == "" {
+		return name
+	}
+	return at + "." + name
+}
+
+func label(at string) string {
+	if at == "" {
+		return "payload"
+	}
+	return at
+}
+
+// fields lists a payload schema's properties in x-proto-field order, for ProtoCodec.
+func (s *jsonSchema) fields() ([]field, error) {
+	var out []field
+	for name, p := range s.Properties {
+		f := field{Name: name, Number: p.ProtoField}
+		for _, t := range p.Types {
+			switch t {
+			case "string":
+				f.Kind = kindString
+			case "array":
+				f.Kind = kindArray
+			}
+		}
+		if f.Number < 1 || f.Kind == "" {
+			return nil, fmt.Errorf("%s: needs x-proto-field and a string or array type", name)
+		}
+		out = append(out, f)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Number < out[j].Number })
+	for i, f := range out {
+		if f.Number != i+1 {
+			return nil, fmt.Errorf("%s: x-proto-field %d, want %d", f.Name, f.Number, i+1)
+		}
+	}
+	return out, nil
+}
+
+// loadSchemas reads every schema document under schemas/.
+func loadSchemas() (map[string]schema, error) {
+	out := map[string]schema{}
+	err := fs.WalkDir(schemaFS, "schemas", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		typ := path.Base(path.Dir(p))
+		version, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(path.Base(p), "v"), ".json"))
+		if err != nil {
+			return fmt.Errorf("%s: name must be v<version>.json", p)
+		}
+		b, err := schemaFS.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		doc, err := parseSchema(b)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		fields, err := doc.fields()
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		s := out[typ]
+		if s.Versions == nil {
+			s.Versions = map[int]*payloadSchema{}
+		}
+		s.Versions[version] = &payloadSchema{doc: doc, raw: b, fields: fields}
+		s.CurrentVersion = max(s.CurrentVersion, version)
+		out[typ] = s
+		return nil
+	})
+	return out, err
+}
+
+// Schema returns the JSON Schema document for version of typ's payload, for publishing to
+// producers outside this repository.
+func Schema(typ string, version int) ([]byte, bool) {
+	s, ok := schemas[typ]
+	if !ok {
+		return nil, false
+	}
+	v, ok := s.Versions[version]
+	if !ok {
+		return nil, false
+	}
+	return bytes.Clone(v.raw), true
+}
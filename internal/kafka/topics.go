@@ -0,0 +1,74 @@
+package kafkax
+
+import (
+	"context"
+	"errors"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// TopicSpec is the layout a topic should have.
+type TopicSpec struct {
+	Name              string
+	Partitions        int
+	ReplicationFactor int
+}
+
+// TopicLayout returns the topic's partition count and the replication factor of its first
+// partition, or zeros if the topic doesn't exist.
+func TopicLayout(ctx context.Context, brokers []string, topic string) (partitions, replicas int, err error) {
+	client := &kafka.Client{Addr: kafka.TCP(brokers...)}
+	meta, err := client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{topic}})
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, t := range meta.Topics {
+		if t.Name != topic {
+			continue
+		}
+		if errors.Is(t.Error, kafka.UnknownTopicOrPartition) {
+			return 0, 0, nil
+		}
+		if t.Error != nil {
+			return 0, 0, t.Error
+		}
+		if len(t.Partitions) > 0 {
+			replicas = len(t.Partitions[0].Replicas)
+		}
+		return len(t.Partitions), replicas, nil
+	}
+	return 0, 0, nil
+}
+
+// CreateTopic creates the topic as specified. A topic created in the meantime is not an
+// error.
+func CreateTopic(ctx context.Context, brokers []string, spec TopicSpec) error {
+	client := &kafka.Client{Addr: kafka.TCP(brokers...)}
+	res, err := client.CreateTopics(ctx, &kafka.CreateTopicsRequest{Topics: []kafka.TopicConfig{{
+		Topic:             spec.Name,
+		NumPartitions:     spec.Partitions,
+		ReplicationFactor: spec.ReplicationFactor,
+	}}})
+	if err != nil {
+		return err
+	}
+	if err := res.Errors[spec.Name]; err != nil && !errors.Is(err, kafka.TopicAlreadyExists) {
+		return err
+	}
+	return nil
+}
+
+// AddPartitions grows the topic to count partitions. Kafka can't remove partitions, and
+// adding them moves keys to new partitions, so messages for an event published before and
+// after may be consumed out of order while the old ones drain.
+func AddPartitions(ctx context.Context, brokers []string, topic string, count int) error {
+	client := &kafka.Client{Addr: kafka.TCP(brokers...)}
+	res, err := client.CreatePartitions(ctx, &kafka.CreatePartitionsRequest{Topics: []kafka.TopicPartitionsConfig{{
+		Name:  topic,
+		Count: int32(count),
+	}}})
+	if err != nil {
+		return err
+	}
+	return res.Errors[topic]
+}
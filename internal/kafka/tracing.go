@@ -0,0 +1,50 @@
+package kafkax
+
+import (
+	"context"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+)
+
+// headerCarrier carries trace context in Kafka message headers.
+type headerCarrier struct{ headers *[]kafka.Header }
+
+func (c headerCarrier) Get(key string) string {
+	for _, h := range *c.headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c headerCarrier) Set(key, value string) {
+	for i, h := range *c.headers {
+		if h.Key == key {
+			(*c.headers)[i].Value = []byte(value)
+			return
+		}
+	}
+	*c.headers = append(*c.headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, len(*c.headers))
+	for i, h := range *c.headers {
+		keys[i] = h.Key
+	}
+	return keys
+}
+
+// injectTrace adds ctx's trace context to headers.
+func injectTrace(ctx context.Context, headers []kafka.Header) []kafka.Header {
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier{&headers})
+	return headers
+}
+
+// MessageContext returns ctx continuing the trace m was published in, if any.
+func MessageContext(ctx context.Context, m kafka.Message) context.Context {
+	headers := m.Headers
+	return otel.GetTextMapPropagator().Extract(ctx, headerCarrier{&headers})
+}
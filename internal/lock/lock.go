@@ -0,0 +1,117 @@
+// Package lock serializes critical sections across API and worker processes with Postgres
+// session advisory locks. Postgres rather than Redis, because the sections it guards
+// (cancellations, payment timeouts, token resyncs) must keep working while bookings are
+// admitted through the Postgres fallback. A lock is tied to the pooled connection holding
+// it, so one held by a process that dies is released with its connection.
+package lock
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/metrics"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store"
+)
+
+// eventClass keeps event locks in their own advisory lock key space, apart from the
+// single-key locks taken inside transactions (e.g. waitlist promotion).
+const eventClass = 1
+
+// pollInterval is how often a waiting caller retries a lock someone else holds.
+const pollInterval = 25 * time.Millisecond
+
+// unlockTimeout bounds the unlock; a connection that can't unlock is closed instead.
+const unlockTimeout = 5 * time.Second
+
+// ErrTimeout is returned when a lock isn't acquired within the Locker's wait.
+var ErrTimeout = errors.New("timed out waiting for event lock")
+
+// Locker takes per-event locks. A nil Locker runs critical sections without locking.
+type Locker struct {
+	db   *store.DB
+	wait time.Duration
+	hold time.Duration
+}
+
+// New returns a Locker that waits up to wait for a lock and gives the critical section at
+// most hold to finish; its context is cancelled after that.
+func New(db *store.DB, wait, hold time.Duration) *Locker {
+	return &Locker{db: db, wait: wait, hold: hold}
+}
+
+// Event runs fn while holding eventID's lock. name labels the critical section in metrics
+// (evently_lock_*). It returns ErrTimeout without running fn if the lock stays taken.
+func (l *Locker) Event(ctx context.Context, name, eventID string, fn func(ctx context.Context) error) error {
+	if l == nil {
+		return fn(ctx)
+	}
+	start := time.Now()
+	conn, err := l.acquire(ctx, eventID)
+	metrics.LockWaitDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+	if err != nil {
+		outcome := "error"
+		if errors.Is(err, ErrTimeout) {
+			outcome = "timeout"
+		}
+		metrics.LockAcquisitionsTotal.WithLabelValues(name, outcome).Inc()
+		return err
+	}
+	metrics.LockAcquisitionsTotal.WithLabelValues(name, "acquired").Inc()
+
+	held := time.Now()
+	defer func() {
+		metrics.LockHoldDuration.WithLabelValues(name).Observe(time.Since(held).Seconds())
+		l.release(conn, eventID)
+	}()
+	holdCtx, cancel := context.WithTimeout(ctx, l.hold)
+	defer cancel()
+	return fn(holdCtx)
+}
+
+func (l *Locker) acquire(ctx context.Context, eventID string) (*pgxpool.Conn, error) {
+	waitCtx, cancel := context.WithTimeout(ctx, l.wait)
+	defer cancel()
+	conn, err := l.db.Pool.Acquire(waitCtx)
+	if err != nil {
+		return nil, waitErr(ctx, waitCtx, err)
+	}
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		var ok bool
+		if err := conn.QueryRow(waitCtx, `SELECT pg_try_advisory_lock($1, hashtext($2))`, eventClass, eventID).Scan(&ok); err != nil {
+			conn.Release()
+			return nil, waitErr(ctx, waitCtx, err)
+		}
+		if ok {
+			return conn, nil
+		}
+		select {
+		case <-ticker.C:
+		case <-waitCtx.Done():
+			conn.Release()
+			return nil, waitErr(ctx, waitCtx, waitCtx.Err())
+		}
+	}
+}
+
+// waitErr reports err as ErrTimeout if it came from the wait running out rather than ctx.
+func waitErr(ctx, waitCtx context.Context, err error) error {
+	if ctx.Err() == nil && waitCtx.Err() != nil {
+		return ErrTimeout
+	}
+	return err
+}
+
+func (l *Locker) release(conn *pgxpool.Conn, eventID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), unlockTimeout)
+	defer cancel()
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_unlock($1, hashtext($2))`, eventClass, eventID); err != nil {
+		// Closing the session is the other way to drop its locks
+		_ = conn.Conn().Close(ctx)
+	}
+	conn.Release()
+}
@@ -0,0 +1,113 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// LevelChangesChannel is the Redis pub/sub channel carrying "component=level" changes, so a
+// level set through one API instance applies to every API and worker process.
+const LevelChangesChannel = "log_level_changes"
+
+// DefaultComponent names the default level in SetLevel and Levels.
+const DefaultComponent = "default"
+
+var levels = &levelSet{def: zapcore.InfoLevel, components: map[string]zapcore.Level{}}
+
+// levelSet is the process's log levels: a default, and overrides per component.
+type levelSet struct {
+	mu         sync.RWMutex
+	def        zapcore.Level
+	components map[string]zapcore.Level
+}
+
+func (s *levelSet) reset(def zapcore.Level, components map[string]zapcore.Level) {
+	s.mu.Lock()
+	s.def, s.components = def, components
+	s.mu.Unlock()
+}
+
+func (s *levelSet) enabled(component string, lvl zapcore.Level) bool {
+	s.mu.RLock()
+	min, ok := s.components[component]
+	if !ok {
+		min = s.def
+	}
+	s.mu.RUnlock()
+	return lvl >= min
+}
+
+// SetLevel sets component's level, or the default level for DefaultComponent. An empty
+// level drops the component's override so it follows the default again.
+func SetLevel(component, level string) error {
+	component = strings.TrimSpace(component)
+	if component == "" {
+		return fmt.Errorf("component is required")
+	}
+	levels.mu.Lock()
+	defer levels.mu.Unlock()
+	if level == "" {
+		if component == DefaultComponent {
+			return fmt.Errorf("the default level can't be dropped")
+		}
+		delete(levels.components, component)
+		return nil
+	}
+	var l zapcore.Level
+	if err := l.Set(level); err != nil {
+		return err
+	}
+	if component == DefaultComponent {
+		levels.def = l
+	} else {
+		levels.components[component] = l
+	}
+	return nil
+}
+
+// Levels returns the current levels: DefaultComponent's and every override.
+func Levels() map[string]string {
+	levels.mu.RLock()
+	defer levels.mu.RUnlock()
+	out := map[string]string{DefaultComponent: levels.def.String()}
+	for name, l := range levels.components {
+		out[name] = l.String()
+	}
+	return out
+}
+
+// PublishLevel sets component's level in every process listening on LevelChangesChannel,
+// this one included once its listener receives it.
+func PublishLevel(ctx context.Context, client *redis.Client, component, level string) error {
+	return client.Publish(ctx, LevelChangesChannel, component+"="+level).Err()
+}
+
+// ListenLevels applies level changes as they are published, until ctx is done.
+func ListenLevels(ctx context.Context, log *zap.Logger, client *redis.Client) {
+	sub := client.Subscribe(ctx, LevelChangesChannel)
+	defer sub.Close()
+
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			component, level, _ := strings.Cut(msg.Payload, "=")
+			if err := SetLevel(component, level); err != nil {
+				log.Warn("ignoring log level change", zap.String("change", msg.Payload), zap.Error(err))
+				continue
+			}
+			log.Info("log level changed", zap.String("component", component), zap.String("level", level))
+		}
+	}
+}
@@ -1,15 +1,133 @@
 package logger
 
 import (
+	"fmt"
+	"os"
+	"strings"
+
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
+// Options configures where logs go and at what levels.
+type Options struct {
+	Env string
+	// Level is the level of components without one in Components (default info, debug in
+	// development)
+	Level string
+	// Components overrides the level per component as "component=level" pairs separated by
+	// commas, e.g. "worker=debug,store=warn"
+	Components string
+	// File, if set, receives JSON logs as well as stdout, rotated once it reaches MaxSizeMB
+	// with MaxBackups old files kept
+	File       string
+	MaxSizeMB  int
+	MaxBackups int
+}
+
 // New creates a new zap logger based on environment.
 func New(env string) *zap.Logger {
-	if env == "development" {
-		l, _ := zap.NewDevelopment()
-		return l
+	l, err := NewWithOptions(Options{Env: env})
+	if err != nil {
+		// Only a bad file or level can fail, and neither is set here
+		panic(err)
 	}
-	l, _ := zap.NewProduction()
 	return l
 }
+
+// NewWithOptions builds a logger writing JSON to stdout (human-readable in development) and,
+// if opts.File is set, to a rotated file. Its levels are the process's component levels: the
+// logger itself logs at the default level, and loggers from Component at their component's.
+func NewWithOptions(opts Options) (*zap.Logger, error) {
+	def := zapcore.InfoLevel
+	if opts.Env == "development" {
+		def = zapcore.DebugLevel
+	}
+	if opts.Level != "" {
+		if err := def.Set(opts.Level); err != nil {
+			return nil, fmt.Errorf("LOG_LEVEL: %w", err)
+		}
+	}
+	overrides, err := parseComponentLevels(opts.Components)
+	if err != nil {
+		return nil, fmt.Errorf("LOG_LEVELS: %w", err)
+	}
+	levels.reset(def, overrides)
+
+	// Sinks let every entry through; componentCore decides what is logged
+	all := zap.NewAtomicLevelAt(zapcore.DebugLevel)
+	jsonEncoder := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	stdoutEncoder := jsonEncoder
+	if opts.Env == "development" {
+		stdoutEncoder = zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig())
+	}
+	cores := []zapcore.Core{zapcore.NewCore(stdoutEncoder, zapcore.Lock(os.Stdout), all)}
+	if opts.File != "" {
+		f, err := openRotating(opts.File, opts.MaxSizeMB, opts.MaxBackups)
+		if err != nil {
+			return nil, fmt.Errorf("LOG_FILE: %w", err)
+		}
+		cores = append(cores, zapcore.NewCore(jsonEncoder, zapcore.AddSync(f), all))
+	}
+
+	zapOpts := []zap.Option{zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel)}
+	if opts.Env == "development" {
+		zapOpts = append(zapOpts, zap.Development())
+	}
+	core := &componentCore{Core: zapcore.NewTee(cores...)}
+	return zap.New(core, zapOpts...), nil
+}
+
+// Component returns log named after component and logging at component's level, which
+// LOG_LEVELS or SetLevel may set apart from the default. Processes name their root logger
+// (api, worker) and hand the store layer a "store" component.
+func Component(log *zap.Logger, component string) *zap.Logger {
+	return log.Named(component).WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		if cc, ok := c.(*componentCore); ok {
+			c = cc.Core
+		}
+		return &componentCore{Core: c, component: component}
+	}))
+}
+
+// componentCore logs what its component's current level allows. Levels are looked up on
+// every entry, so a SetLevel applies to loggers already handed out.
+type componentCore struct {
+	zapcore.Core
+	component string
+}
+
+func (c *componentCore) Enabled(lvl zapcore.Level) bool {
+	return levels.enabled(c.component, lvl)
+}
+
+func (c *componentCore) With(fields []zapcore.Field) zapcore.Core {
+	return &componentCore{Core: c.Core.With(fields), component: c.component}
+}
+
+func (c *componentCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.Enabled(ent.Level) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
+
+func parseComponentLevels(s string) (map[string]zapcore.Level, error) {
+	out := make(map[string]zapcore.Level)
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		name, level, ok := strings.Cut(p, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("%q is not component=level", p)
+		}
+		var l zapcore.Level
+		if err := l.Set(strings.TrimSpace(level)); err != nil {
+			return nil, err
+		}
+		out[strings.TrimSpace(name)] = l
+	}
+	return out, nil
+}
@@ -1,15 +1,26 @@
 package mailer
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/smtp"
+	"net/textproto"
 )
 
 type Mail struct {
 	To      string
 	Subject string
 	Body    string
+	// HTML, when set, is sent alongside Body as an alternative for mail clients that show it
+	HTML string
+	// OrganizerID is the organizer whose branding the email carries; nil for the platform's
+	OrganizerID *string
+	// BookingID is the booking the email is about, if any, recorded with it in the mail queue
+	BookingID *string
 }
 
 type Sender interface {
@@ -29,13 +40,16 @@ func (s *SMTPSender) Send(m Mail) error {
 	auth := smtp.PlainAuth("", s.User, s.Pass, s.Host)
 
 	// Build message with proper headers
-	msg := []byte("From: " + s.From + "\r\n" +
+	header := "From: " + s.From + "\r\n" +
 		"To: " + m.To + "\r\n" +
 		"Subject: " + m.Subject + "\r\n" +
-		"MIME-Version: 1.0\r\n" +
-		"Content-Type: text/plain; charset=\"utf-8\"\r\n" +
-		"\r\n" +
-		m.Body)
+		"MIME-Version: 1.0\r\n"
+	var msg []byte
+	if m.HTML == "" {
+		msg = []byte(header + "Content-Type: text/plain; charset=\"utf-8\"\r\n\r\n" + m.Body)
+	} else {
+		msg = []byte(header + alternative(m.Body, m.HTML))
+	}
 
 	err := smtp.SendMail(addr, auth, s.From, []string{m.To}, msg)
 	if err != nil {
@@ -44,3 +58,31 @@ func (s *SMTPSender) Send(m Mail) error {
 	log.Printf("MAIL to=%s subject=%s body=%s", m.To, m.Subject, m.Body)
 	return nil
 }
+
+// alternative is a multipart/alternative entity with the text and HTML versions of a body,
+// HTML last so clients that can show it prefer it.
+func alternative(text, html string) string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	boundary := "evently-" + hex.EncodeToString(b)
+	return "Content-Type: multipart/alternative; boundary=\"" + boundary + "\"\r\n\r\n" +
+		"--" + boundary + "\r\n" +
+		"Content-Type: text/plain; charset=\"utf-8\"\r\n\r\n" + text + "\r\n" +
+		"--" + boundary + "\r\n" +
+		"Content-Type: text/html; charset=\"utf-8\"\r\n\r\n" + html + "\r\n" +
+		"--" + boundary + "--\r\n"
+}
+
+// Throttled reports whether err is the mail server deferring the message with a 4xx reply,
+// which providers use to push back on senders going too fast.
+func Throttled(err error) bool {
+	var reply *textproto.Error
+	return errors.As(err, &reply) && reply.Code >= 400 && reply.Code < 500
+}
+
+// Temporary reports whether a send that failed with err may succeed if retried: the server
+// deferred it or it never got through.
+func Temporary(err error) bool {
+	var netErr net.Error
+	return Throttled(err) || errors.As(err, &netErr)
+}
@@ -11,17 +11,62 @@ var (
 		Help: "Total HTTP requests",
 	}, []string{"method", "route", "status"})
 
+	HTTPBodyRejectedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "evently_http_body_rejected_total",
+		Help: "Request bodies rejected before reaching a handler, by reason (too_large, too_deep)",
+	}, []string{"route", "reason"})
+
 	BookingRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name: "evently_booking_requests_total",
 		Help: "Booking outcomes",
 	}, []string{"outcome"})
 
+	// Buckets are finer around the booking latency budget (150ms by default)
+	BookingCreateDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "evently_booking_create_duration_seconds",
+		Help:    "Time taken to admit a booking request, from event lookup to the pending booking's insert",
+		Buckets: []float64{.005, .01, .025, .05, .075, .1, .125, .15, .2, .3, .5, 1, 2.5},
+	})
+
 	BookingFinalizeDuration = promauto.NewHistogram(prometheus.HistogramOpts{
 		Name:    "evently_booking_finalize_duration_seconds",
 		Help:    "Finalize worker duration",
 		Buckets: prometheus.DefBuckets,
 	})
 
+	WorkerMessagesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "evently_worker_messages_total",
+		Help: "Finalizer messages by envelope type and outcome (consumed, retried, succeeded, failed, dead_lettered, duplicate, taken_over)",
+	}, []string{"type", "outcome"})
+
+	WorkerMessageDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "evently_worker_message_duration_seconds",
+		Help:    "Finalizer message handling duration by envelope type",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"type"})
+
+	DLQDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "evently_kafka_dlq_depth",
+		Help: "Messages retained in a dead-letter topic, sampled periodically",
+	}, []string{"topic"})
+
+	LockWaitDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "evently_lock_wait_duration_seconds",
+		Help:    "Time spent waiting for a per-event lock, by critical section",
+		Buckets: []float64{.001, .005, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"name"})
+
+	LockHoldDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "evently_lock_hold_duration_seconds",
+		Help:    "Time a per-event lock was held, by critical section",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"name"})
+
+	LockAcquisitionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "evently_lock_acquisitions_total",
+		Help: "Per-event lock attempts by critical section and outcome (acquired, timeout, error)",
+	}, []string{"name", "outcome"})
+
 	ReconciliationRunsTotal = promauto.NewCounter(prometheus.CounterOpts{
 		Name: "evently_reconciliation_runs_total",
 		Help: "Total reconciliation runs",
@@ -31,4 +76,177 @@ var (
 		Name: "evently_reconciliation_fixes_total",
 		Help: "Total reconciliation fixes applied",
 	})
+
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "evently_db_query_duration_seconds",
+		Help:    "Postgres query duration by query name",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"query"})
+
+	DBQueryErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "evently_db_query_errors_total",
+		Help: "Postgres query errors by query name and error type",
+	}, []string{"query", "error_type"})
+
+	RedisTokenOpsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "evently_redis_token_ops_total",
+		Help: "Redis token bucket operations by op and outcome (success, insufficient, error)",
+	}, []string{"op", "outcome"})
+
+	RedisTokenOpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "evently_redis_token_op_duration_seconds",
+		Help:    "Redis token bucket operation latency",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"op"})
+
+	EventTokensRemaining = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "evently_event_tokens_remaining",
+		Help: "Tokens left in Redis per event with a token counter, sampled periodically",
+	}, []string{"event_id"})
+
+	AdmissionDegraded = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "evently_admission_degraded",
+		Help: "1 while bookings are admitted through Postgres because Redis is failing",
+	})
+
+	NotificationsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "evently_notifications_total",
+		Help: "Batched notification emails by kind and outcome (sent, failed, retried, throttled)",
+	}, []string{"kind", "outcome"})
+
+	MailQueueTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "evently_mail_queue_total",
+		Help: "Single emails through the mail queue by outcome (queued, sent, retried, dead)",
+	}, []string{"outcome"})
+
+	PaymentsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "evently_payments_total",
+		Help: "Payment provider calls by operation (charge, authorize, capture, void, refund) and outcome (ok, declined, error)",
+	}, []string{"operation", "outcome"})
+
+	DuplicatePaymentCallbacksTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "evently_duplicate_payment_callbacks_total",
+		Help: "Payment callbacks for an already processed transaction ID, answered with the original result",
+	})
+
+	WebhookRejectionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "evently_webhook_rejections_total",
+		Help: "Webhook calls rejected before reaching a handler, by provider and reason",
+	}, []string{"provider", "reason"})
+
+	UserWebhookDeliveriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "evently_user_webhook_deliveries_total",
+		Help: "Personal webhook delivery attempts by outcome (delivered, retried, failed)",
+	}, []string{"outcome"})
+
+	WebhookEndpointDeliveriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "evently_webhook_endpoint_deliveries_total",
+		Help: "Integration webhook delivery attempts by outcome (delivered, retried, failed)",
+	}, []string{"outcome"})
+
+	RateLimiterUnavailableTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "evently_rate_limiter_unavailable_total",
+		Help: "Requests the Redis rate limiter couldn't check, by policy and action (rejected when failing closed, fallback to the in-memory limit otherwise)",
+	}, []string{"policy", "action"})
+
+	PaymentURLUp = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "evently_payment_url_up",
+		Help: "1 while the payment service passes the worker's health probes, 0 while payment links are deferred",
+	})
+
+	PaymentURLProbeDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "evently_payment_url_probe_seconds",
+		Help:    "Latency of payment service health probes",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	PaymentDeferralsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "evently_payment_deferrals_total",
+		Help: "Bookings held without a payment link while the payment service was down, by outcome (deferred, resumed, expired)",
+	}, []string{"outcome"})
+
+	BookingTimeoutsScheduled = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "evently_booking_timeouts_scheduled",
+		Help: "Payment timeouts in the Redis schedule, waiting to fire or be settled",
+	})
+
+	BookingTimeoutsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "evently_booking_timeouts_total",
+		Help: "Due payment timeouts claimed by the worker's poller, by outcome (published, settled, failed)",
+	}, []string{"outcome"})
+
+	OutboxBacklog = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "evently_outbox_backlog",
+		Help: "Outbox messages not yet published to Kafka",
+	})
+
+	OutboxOldestSeconds = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "evently_outbox_oldest_seconds",
+		Help: "Age of the oldest unpublished outbox message, 0 when there is none",
+	})
+
+	OutboxPublishedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "evently_outbox_published_total",
+		Help: "Outbox messages the relay tried to publish, by outcome (published, failed, dead)",
+	}, []string{"outcome"})
+
+	OutboxFailed = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "evently_outbox_failed",
+		Help: "Outbox messages given up on after OUTBOX_MAX_ATTEMPTS failed publishes, waiting for an operator",
+	})
+
+	PendingBookings = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "evently_pending_bookings",
+		Help: "Pending bookings by payment provider (none before a provider reports on them) and age (0-5m, 5-10m, 10m+)",
+	}, []string{"provider", "age"})
+
+	PendingSeats = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "evently_pending_seats",
+		Help: "Seats held by pending bookings, by payment provider and age",
+	}, []string{"provider", "age"})
+
+	PaymentConversionPercent = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "evently_payment_conversion_percent",
+		Help: "Share of the bookings created in the last hour and no longer pending that were paid for, by payment provider",
+	}, []string{"provider"})
+
+	PaymentConversionLow = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "evently_payment_conversion_low",
+		Help: "1 while a payment provider's hourly conversion is below PAYMENT_CONVERSION_ALERT_PERCENT",
+	}, []string{"provider"})
+
+	PaymentConversionAlertsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "evently_payment_conversion_alerts_total",
+		Help: "Times a payment provider's hourly conversion dropped below the alert threshold",
+	}, []string{"provider"})
+
+	CheckInScansTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "evently_checkin_scans_total",
+		Help: "Ticket scans at event gates, by outcome (admitted, duplicate, wrong_event, outside_window, invalid_ticket, error)",
+	}, []string{"outcome"})
+
+	SeatIntegrityFlagsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "evently_seat_integrity_flags_total",
+		Help: "Bookings flagged for untrustworthy seats, by source (payment, check)",
+	}, []string{"source"})
+
+	SeatIntegrityMismatches = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "evently_seat_integrity_mismatches",
+		Help: "Booked bookings whose stored seats don't match the seats table, as of the last integrity check",
+	})
+
+	OnsalePrewarmsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "evently_onsale_prewarms_total",
+		Help: "On-sale warm-ups run by the event status checker, by resulting status (ready, degraded)",
+	}, []string{"status"})
+
+	SalePhaseRolloverSeatsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "evently_sale_phase_rollover_seats_total",
+		Help: "Unsold seats rolled over from ended sale phases into the next phase",
+	})
+
+	DependencyUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "evently_dependency_up",
+		Help: "1 if the dependency (postgres, postgres_batch, redis, kafka) passed its last readiness check, 0 if not",
+	}, []string{"dependency"})
 )
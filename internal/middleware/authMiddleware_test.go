@@ -0,0 +1,182 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/redis/redistest"
+)
+
+// serve runs a request carrying headers through Middleware in front of a handler that
+// answers 200 and reports whether the handler was reached.
+func serve(t *testing.T, requireAdmin bool, headers map[string]string) (int, bool) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	reached := false
+	r := gin.New()
+	r.GET("/", Middleware("secret", requireAdmin), func(c *gin.Context) {
+		reached = true
+		c.Status(http.StatusOK)
+	})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code, reached
+}
+
+func bearer(t *testing.T, userID string, admin bool, roleVersion int) map[string]string {
+	t.Helper()
+	token, err := Issue("secret", userID, admin, roleVersion, time.Hour)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	return map[string]string{"Authorization": "Bearer " + token}
+}
+
+func TestMiddlewareBlacklistFailsOpen(t *testing.T) {
+	// Nothing listens here, so every revocation check fails
+	UseTokenBlacklist(NewTokenBlacklist(redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})))
+	t.Cleanup(func() { UseTokenBlacklist(nil) })
+
+	if code, reached := serve(t, false, bearer(t, "u1", false, 0)); code != http.StatusOK || !reached {
+		t.Errorf("status = %d, reached = %v, want 200 through a Redis outage", code, reached)
+	}
+}
+
+func TestMiddlewareSessions(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: redistest.Addr(t)})
+	t.Cleanup(func() { _ = client.Close() })
+	store := NewSessionStore(client, time.Hour)
+	UseSessionStore(store)
+	t.Cleanup(func() { UseSessionStore(nil) })
+	ctx := context.Background()
+
+	userID := uuid.NewString()
+	first, _, err := store.Create(ctx, userID, false, 0)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	second, _, err := store.Create(ctx, userID, false, 0)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	third, _, err := store.Create(ctx, userID, false, 0)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	check := func(name string, headers map[string]string, want int) {
+		t.Helper()
+		if code, _ := serve(t, false, headers); code != want {
+			t.Errorf("%s: status = %d, want %d", name, code, want)
+		}
+	}
+	check("session as bearer token", map[string]string{"Authorization": "Bearer " + first}, http.StatusOK)
+	check("session cookie", map[string]string{"Cookie": SessionCookie + "=" + first}, http.StatusOK)
+	check("unknown session", map[string]string{"Authorization": "Bearer " + strings.Repeat("0", 64)}, http.StatusUnauthorized)
+	// JWTs issued before the switch to sessions keep working
+	check("JWT", bearer(t, userID, false, 0), http.StatusOK)
+
+	if err := store.Delete(ctx, first); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	check("ended session", map[string]string{"Authorization": "Bearer " + first}, http.StatusUnauthorized)
+	check("the user's other session", map[string]string{"Authorization": "Bearer " + second}, http.StatusOK)
+
+	if n, err := store.DeleteUser(ctx, userID); err != nil || n != 2 {
+		t.Fatalf("DeleteUser = %d, %v, want 2", n, err)
+	}
+	check("session ended with the user's", map[string]string{"Authorization": "Bearer " + second}, http.StatusUnauthorized)
+	check("session ended with the user's", map[string]string{"Cookie": SessionCookie + "=" + third}, http.StatusUnauthorized)
+}
+
+func TestMiddlewareSessionsFailClosed(t *testing.T) {
+	UseSessionStore(NewSessionStore(redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1}), time.Hour))
+	t.Cleanup(func() { UseSessionStore(nil) })
+
+	if code, reached := serve(t, false, map[string]string{"Authorization": "Bearer " + strings.Repeat("0", 64)}); code != http.StatusServiceUnavailable || reached {
+		t.Errorf("status = %d, reached = %v, want 503 while the session store is down", code, reached)
+	}
+}
+
+type userRole struct {
+	role    string
+	version int
+}
+
+// fakeRoles is a RoleLookup over users' current roles.
+type fakeRoles map[string]userRole
+
+func (f fakeRoles) lookup(_ context.Context, userID string) (string, int, error) {
+	r, ok := f[userID]
+	if !ok {
+		return "", 0, errors.New("no such user")
+	}
+	return r.role, r.version, nil
+}
+
+func TestMiddlewareAdminRoleVersion(t *testing.T) {
+	roles := fakeRoles{"admin": {RoleAdmin, 1}, "user": {RoleUser, 0}}
+	cache := NewRoleCache(zap.NewNop(), roles.lookup, nil, time.Hour)
+	UseRoleCache(cache)
+	t.Cleanup(func() { UseRoleCache(nil) })
+
+	admin := bearer(t, "admin", true, 1)
+	check := func(name string, headers map[string]string, want int) {
+		t.Helper()
+		if code, reached := serve(t, true, headers); code != want || reached != (want == http.StatusOK) {
+			t.Errorf("%s: status = %d, reached = %v, want %d", name, code, reached, want)
+		}
+	}
+	check("current admin", admin, http.StatusOK)
+	check("user", bearer(t, "user", false, 0), http.StatusForbidden)
+	// The admin claim alone isn't enough; the user must be an admin now
+	check("user claiming admin", bearer(t, "user", true, 0), http.StatusForbidden)
+	check("unknown user", bearer(t, "gone", true, 0), http.StatusForbidden)
+
+	// Demoting and promoting again bumps the version, so tokens from before are stale
+	roles["admin"] = userRole{RoleAdmin, 2}
+	check("cached until invalidated", admin, http.StatusOK)
+	cache.Invalidate("admin")
+	check("stale role version", admin, http.StatusForbidden)
+	check("token for the new version", bearer(t, "admin", true, 2), http.StatusOK)
+}
+
+func TestMiddlewareAdminAPIKeys(t *testing.T) {
+	t.Cleanup(func() { UseAdminAPIKeys(nil) })
+	key := func(k string) map[string]string { return map[string]string{APIKeyHeader: k} }
+	check := func(name string, requireAdmin bool, headers map[string]string, want int) {
+		t.Helper()
+		if code, reached := serve(t, requireAdmin, headers); code != want || reached != (want == http.StatusOK) {
+			t.Errorf("%s: status = %d, reached = %v, want %d", name, code, reached, want)
+		}
+	}
+
+	UseAdminAPIKeys([]string{"old-key", " new-key "})
+	check("configured key", true, key("new-key"), http.StatusOK)
+	check("key being rotated out", true, key("old-key"), http.StatusOK)
+	check("unknown key", true, key("other-key"), http.StatusUnauthorized)
+	// Keys only stand in for admins; user routes still need a user
+	check("key on a user route", false, key("new-key"), http.StatusUnauthorized)
+
+	UseAdminAPIKeys([]string{"new-key"})
+	check("revoked key", true, key("old-key"), http.StatusUnauthorized)
+	check("remaining key", true, key("new-key"), http.StatusOK)
+
+	// An unset ADMIN_API_KEYS disables key auth
+	UseAdminAPIKeys([]string{""})
+	check("keys disabled", true, key("new-key"), http.StatusUnauthorized)
+}
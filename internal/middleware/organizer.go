@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/api/response"
+)
+
+// Roles a user can hold; see users.role.
+const (
+	RoleUser      = "user"
+	RoleOrganizer = "organizer"
+	RoleAdmin     = "admin"
+)
+
+// Event permissions an owner grants co-organizers; see event_members.permissions. Owners
+// and admins hold all of them.
+const (
+	PermEditEvent     = "edit_event"
+	PermViewAnalytics = "view_analytics"
+	PermManageCheckIn = "manage_check_in"
+	PermRefunds       = "trigger_refunds"
+)
+
+// EventPermissions lists every event permission.
+var EventPermissions = []string{PermEditEvent, PermViewAnalytics, PermManageCheckIn, PermRefunds}
+
+// EventOwnership reports whether an event exists and whether the user owns it.
+type EventOwnership func(ctx context.Context, eventID, userID string) (exists, owns bool, err error)
+
+// EventAccess reports whether an event exists, whether the user owns it, and the
+// permissions they hold on it as a co-organizer.
+type EventAccess func(ctx context.Context, eventID, userID string) (exists, owns bool, perms []string, err error)
+
+// currentRole is the user's role now rather than when their token was issued, so granting or
+// revoking a role takes effect without signing in again.
+func currentRole(ctx context.Context, userID string) (string, error) {
+	if roleCache == nil {
+		return userRoleInDB(ctx, userID)
+	}
+	role, _, err := roleCache.Get(ctx, userID)
+	return role, err
+}
+
+// RequireOrganizer lets organizers and admins through and sets "role". It goes after
+// UserMiddleware, which authenticates the user.
+func RequireOrganizer() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, err := currentRole(c.Request.Context(), c.GetString("uid"))
+		// Like the admin check, a failed lookup fails closed
+		if err != nil || (role != RoleOrganizer && role != RoleAdmin) {
+			response.Abort(c, http.StatusForbidden, gin.H{"error": "organizer required"})
+			return
+		}
+		c.Set("role", role)
+		c.Next()
+	}
+}
+
+// RequireEventOwner only lets the owner of the event in the :id parameter through; admins
+// may act on any event. It goes after RequireOrganizer.
+func RequireEventOwner(ownership EventOwnership) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		eventID := c.Param("id")
+		if _, err := uuid.Parse(eventID); err != nil {
+			response.Abort(c, http.StatusNotFound, gin.H{"error": "Event not found"})
+			return
+		}
+		exists, owns, err := ownership(c.Request.Context(), eventID, c.GetString("uid"))
+		if err != nil {
+			response.Abort(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+		// Event IDs aren't secret, so a missing event is a 404 for owners and others alike
+		if !exists {
+			response.Abort(c, http.StatusNotFound, gin.H{"error": "Event not found"})
+			return
+		}
+		if !owns && c.GetString("role") != RoleAdmin {
+			response.Abort(c, http.StatusForbidden, gin.H{"error": "you don't own this event"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireEventPermission lets through the owner of the event in the :id parameter, its
+// co-organizers holding perm, and admins, and sets "role". It goes after UserMiddleware;
+// co-organizers needn't be organizers themselves, but owners still must be, as for
+// RequireEventOwner.
+func RequireEventPermission(access EventAccess, perm string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		eventID := c.Param("id")
+		if _, err := uuid.Parse(eventID); err != nil {
+			response.Abort(c, http.StatusNotFound, gin.H{"error": "Event not found"})
+			return
+		}
+		role, err := currentRole(c.Request.Context(), c.GetString("uid"))
+		if err != nil {
+			response.Abort(c, http.StatusForbidden, gin.H{"error": "event permission required"})
+			return
+		}
+		exists, owns, perms, err := access(c.Request.Context(), eventID, c.GetString("uid"))
+		if err != nil {
+			response.Abort(c, http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+		if !exists {
+			response.Abort(c, http.StatusNotFound, gin.H{"error": "Event not found"})
+			return
+		}
+		allowed := role == RoleAdmin || (owns && role == RoleOrganizer)
+		for _, p := range perms {
+			allowed = allowed || p == perm
+		}
+		if !allowed {
+			response.Abort(c, http.StatusForbidden, gin.H{"error": "you don't have the " + perm + " permission on this event"})
+			return
+		}
+		c.Set("role", role)
+		c.Next()
+	}
+}
@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+func TestRequireEventOwner(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	roles := fakeRoles{"owner": {RoleOrganizer, 0}, "rival": {RoleOrganizer, 0}, "admin": {RoleAdmin, 0}, "user": {RoleUser, 0}}
+	UseRoleCache(NewRoleCache(zap.NewNop(), roles.lookup, nil, time.Hour))
+	t.Cleanup(func() { UseRoleCache(nil) })
+
+	event, missing, broken := uuid.NewString(), uuid.NewString(), uuid.NewString()
+	ownership := func(_ context.Context, eventID, userID string) (bool, bool, error) {
+		switch eventID {
+		case event:
+			return true, userID == "owner", nil
+		case broken:
+			return false, false, errors.New("connection refused")
+		}
+		return false, false, nil
+	}
+	reached := false
+	r := gin.New()
+	r.PUT("/events/:id", UserMiddleware("secret"), RequireOrganizer(), RequireEventOwner(ownership), func(c *gin.Context) {
+		reached = true
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name    string
+		userID  string
+		eventID string
+		want    int
+	}{
+		{name: "owner", userID: "owner", eventID: event, want: http.StatusOK},
+		{name: "admin", userID: "admin", eventID: event, want: http.StatusOK},
+		{name: "another organizer", userID: "rival", eventID: event, want: http.StatusForbidden},
+		{name: "not an organizer", userID: "user", eventID: event, want: http.StatusForbidden},
+		{name: "role lookup fails", userID: "gone", eventID: event, want: http.StatusForbidden},
+		{name: "missing event", userID: "rival", eventID: missing, want: http.StatusNotFound},
+		{name: "event ID isn't a UUID", userID: "owner", eventID: "not-a-uuid", want: http.StatusNotFound},
+		{name: "ownership lookup fails", userID: "owner", eventID: broken, want: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reached = false
+			req := httptest.NewRequest(http.MethodPut, "/events/"+tt.eventID, nil)
+			for k, v := range bearer(t, tt.userID, false, 0) {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want || reached != (tt.want == http.StatusOK) {
+				t.Errorf("status = %d, reached = %v, want %d: %s", w.Code, reached, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestRequireEventPermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	roles := fakeRoles{"owner": {RoleOrganizer, 0}, "demoted": {RoleUser, 0}, "helper": {RoleUser, 0}, "viewer": {RoleUser, 0},
+		"admin": {RoleAdmin, 0}, "stranger": {RoleOrganizer, 0}}
+	UseRoleCache(NewRoleCache(zap.NewNop(), roles.lookup, nil, time.Hour))
+	t.Cleanup(func() { UseRoleCache(nil) })
+
+	event := uuid.NewString()
+	access := func(_ context.Context, eventID, userID string) (bool, bool, []string, error) {
+		if eventID != event {
+			return false, false, nil, nil
+		}
+		switch userID {
+		case "owner", "demoted":
+			return true, true, nil, nil
+		case "helper":
+			return true, false, []string{PermManageCheckIn, PermRefunds}, nil
+		case "viewer":
+			return true, false, []string{PermViewAnalytics}, nil
+		}
+		return true, false, nil, nil
+	}
+	r := gin.New()
+	r.POST("/events/:id/refunds", UserMiddleware("secret"), RequireEventPermission(access, PermRefunds), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name    string
+		userID  string
+		eventID string
+		want    int
+	}{
+		{name: "owner", userID: "owner", eventID: event, want: http.StatusOK},
+		{name: "admin", userID: "admin", eventID: event, want: http.StatusOK},
+		{name: "co-organizer with the permission", userID: "helper", eventID: event, want: http.StatusOK},
+		{name: "co-organizer without it", userID: "viewer", eventID: event, want: http.StatusForbidden},
+		// Owners must still be organizers, as for RequireEventOwner
+		{name: "owner no longer an organizer", userID: "demoted", eventID: event, want: http.StatusForbidden},
+		{name: "organizer of other events", userID: "stranger", eventID: event, want: http.StatusForbidden},
+		{name: "missing event", userID: "owner", eventID: uuid.NewString(), want: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/events/"+tt.eventID+"/refunds", nil)
+			for k, v := range bearer(t, tt.userID, false, 0) {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/api/response"
 )
 
 func RateLimit(rps int, burst int) gin.HandlerFunc {
@@ -33,7 +35,7 @@ func RateLimit(rps int, burst int) gin.HandlerFunc {
 		b.tokens = min(float64(burst), b.tokens+elapsed*refill)
 		if b.tokens < 1 {
 			mu.Unlock()
-			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "rate limit"})
+			response.Abort(c, http.StatusTooManyRequests, gin.H{"error": "rate limit"})
 			return
 		}
 		b.tokens -= 1
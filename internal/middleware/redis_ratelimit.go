@@ -3,181 +3,183 @@ package middleware
 import (
 	"context"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/api/response"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/metrics"
 )
 
-// RedisRateLimit creates a rate limiter using Redis
-func RedisRateLimit(redisClient *redis.Client, rps int, burst int) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// Get client IP
-		clientIP := c.ClientIP()
-		if clientIP == "" {
-			clientIP = "unknown"
-		}
+// slidingWindowLua admits a request if fewer than ARGV[2] were admitted in the last ARGV[1]
+// milliseconds. ARGV[4] names the request, so requests in the same millisecond count apart.
+const slidingWindowLua = `
+	local key = KEYS[1]
+	local window = tonumber(ARGV[1])
+	local limit = tonumber(ARGV[2])
+	local now = tonumber(ARGV[3])
+
+	-- Remove old entries
+	redis.call('ZREMRANGEBYSCORE', key, 0, now - window)
+
+	-- Count current requests
+	local current = redis.call('ZCARD', key)
+
+	if current < limit then
+		-- Add current request
+		redis.call('ZADD', key, now, ARGV[4])
+		redis.call('PEXPIRE', key, window)
+		return {1, limit - current - 1}
+	else
+		return {0, 0}
+	end
+`
+
+// RateLimitPolicy is a named limit for a group of routes. FailClosed decides what happens
+// when Redis can't be asked: reject with 503, for routes where unlimited guessing is worse
+// than an outage (login, OTPs), or fall back to a per-instance in-memory limit, for public
+// browsing.
+type RateLimitPolicy struct {
+	Name       string
+	RPS        int
+	Burst      int
+	FailClosed bool
+}
+
+// limiterUnavailableRetry is the Retry-After sent when a fail-closed policy can't reach Redis.
+const limiterUnavailableRetry = 5
+
+var requestSeq atomic.Uint64
+
+// slidingWindow records a request against key and reports whether it is admitted, how many
+// more fit in the window, and the window. Burst requests are allowed per burst/rps seconds.
+func slidingWindow(ctx context.Context, client *redis.Client, key string, rps, burst int) (bool, int64, time.Duration, error) {
+	window := time.Duration(burst) * time.Second / time.Duration(rps)
+	now := time.Now()
+	member := strconv.FormatInt(now.UnixNano(), 10) + "-" + strconv.FormatUint(requestSeq.Add(1), 10)
+	result, err := client.Eval(ctx, slidingWindowLua, []string{key}, window.Milliseconds(), burst, now.UnixMilli(), member).Int64Slice()
+	if err != nil {
+		return false, 0, window, err
+	}
+	if len(result) < 2 {
+		return false, 0, window, fmt.Errorf("unexpected rate limit reply %v", result)
+	}
+	return result[0] == 1, result[1], window, nil
+}
+
+// limit applies the sliding window for key, setting the rate limit headers. It reports
+// false, with the request aborted, if the request is over the limit. Redis errors are
+// returned for the caller to fail open or closed.
+func limit(c *gin.Context, client *redis.Client, key string, rps, burst int) (bool, error) {
+	allowed, remaining, window, err := slidingWindow(c.Request.Context(), client, key, rps, burst)
+	if err != nil {
+		return false, err
+	}
+	retry := int(math.Ceil(window.Seconds()))
+
+	// Set rate limit headers
+	c.Header("X-RateLimit-Limit", fmt.Sprintf("%d", burst))
+	c.Header("X-RateLimit-Remaining", fmt.Sprintf("%d", remaining))
+	c.Header("X-RateLimit-Reset", fmt.Sprintf("%d", time.Now().Add(window).Unix()))
+
+	if !allowed {
+		c.Header("Retry-After", fmt.Sprintf("%d", retry))
+		response.Abort(c, http.StatusTooManyRequests, gin.H{
+			"error":       "Rate limit exceeded",
+			"retry_after": retry,
+		})
+		return false, nil
+	}
+	return true, nil
+}
 
-		// Create rate limit key
-		key := fmt.Sprintf("rate_limit:%s", clientIP)
-
-		// Use Redis sliding window counter
-		ctx := context.Background()
-
-		// Lua script for sliding window rate limiting
-		luaScript := `
-			local key = KEYS[1]
-			local window = tonumber(ARGV[1])
-			local limit = tonumber(ARGV[2])
-			local now = tonumber(ARGV[3])
-			
-			-- Remove old entries
-			redis.call('ZREMRANGEBYSCORE', key, 0, now - window)
-			
-			-- Count current requests
-			local current = redis.call('ZCARD', key)
-			
-			if current < limit then
-				-- Add current request
-				redis.call('ZADD', key, now, now)
-				redis.call('EXPIRE', key, window)
-				return {1, limit - current - 1}
-			else
-				return {0, 0}
-			end
-		`
-
-		window := time.Duration(burst) * time.Second / time.Duration(rps)
-		now := time.Now().Unix()
-
-		result, err := redisClient.Eval(ctx, luaScript, []string{key},
-			int(window.Seconds()), burst, now).Result()
+func clientKey(c *gin.Context) string {
+	clientIP := c.ClientIP()
+	if clientIP == "" {
+		clientIP = "unknown"
+	}
+	return clientIP
+}
 
+// PolicyRateLimit limits each client IP under the policy, keyed apart from other policies.
+func PolicyRateLimit(redisClient *redis.Client, p RateLimitPolicy) gin.HandlerFunc {
+	memoryRateLimit := RateLimit(p.RPS, p.Burst)
+	prefix := "rate_limit:"
+	if p.Name != "" {
+		prefix += p.Name + ":"
+	}
+	policy := p.Name
+	if policy == "" {
+		policy = "default"
+	}
+	return func(c *gin.Context) {
+		ok, err := limit(c, redisClient, prefix+clientKey(c), p.RPS, p.Burst)
 		if err != nil {
-			// If Redis is down, allow the request (fail open)
-			c.Next()
+			if p.FailClosed {
+				metrics.RateLimiterUnavailableTotal.WithLabelValues(policy, "rejected").Inc()
+				c.Header("Retry-After", fmt.Sprintf("%d", limiterUnavailableRetry))
+				response.Abort(c, http.StatusServiceUnavailable, gin.H{
+					"error":       "Rate limiter unavailable, retry shortly",
+					"retry_after": limiterUnavailableRetry,
+				})
+				return
+			}
+			metrics.RateLimiterUnavailableTotal.WithLabelValues(policy, "fallback").Inc()
+			memoryRateLimit(c)
 			return
 		}
+		if ok {
+			c.Next()
+		}
+	}
+}
 
-		results, ok := result.([]interface{})
-		if !ok || len(results) < 2 {
-			c.Next() // fail open
+// RedisRateLimit creates a rate limiter using Redis
+func RedisRateLimit(redisClient *redis.Client, rps int, burst int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ok, err := limit(c, redisClient, "rate_limit:"+clientKey(c), rps, burst)
+		if err != nil {
+			// If Redis is down, allow the request (fail open)
+			c.Next()
 			return
 		}
-		allowed := results[0].(int64)
-		remaining := results[1].(int64)
-
-		// Set rate limit headers
-		c.Header("X-RateLimit-Limit", fmt.Sprintf("%d", burst))
-		c.Header("X-RateLimit-Remaining", fmt.Sprintf("%d", remaining))
-		c.Header("X-RateLimit-Reset", fmt.Sprintf("%d", now+int64(window.Seconds())))
-
-		if allowed == 0 {
-			c.Header("Retry-After", fmt.Sprintf("%d", int(window.Seconds())))
-			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
-				"error":       "Rate limit exceeded",
-				"retry_after": int(window.Seconds()),
-			})
-			return
+		if ok {
+			c.Next()
 		}
-
-		c.Next()
 	}
 }
 
 // RedisRateLimitByUser creates a rate limiter using Redis based on user ID
 func RedisRateLimitByUser(redisClient *redis.Client, rps int, burst int) gin.HandlerFunc {
+	byIP := RedisRateLimit(redisClient, rps, burst)
 	return func(c *gin.Context) {
 		// Get user ID from context (set by auth middleware)
 		userID := c.GetString("uid")
 		if userID == "" {
 			// If no user ID, fall back to IP-based limiting
-			RedisRateLimit(redisClient, rps, burst)(c)
+			byIP(c)
 			return
 		}
 
-		// Create rate limit key for user
-		key := fmt.Sprintf("rate_limit_user:%s", userID)
-
-		// Use Redis sliding window counter
-		ctx := context.Background()
-
-		// Lua script for sliding window rate limiting
-		luaScript := `
-			local key = KEYS[1]
-			local window = tonumber(ARGV[1])
-			local limit = tonumber(ARGV[2])
-			local now = tonumber(ARGV[3])
-			
-			-- Remove old entries
-			redis.call('ZREMRANGEBYSCORE', key, 0, now - window)
-			
-			-- Count current requests
-			local current = redis.call('ZCARD', key)
-			
-			if current < limit then
-				-- Add current request
-				redis.call('ZADD', key, now, now)
-				redis.call('EXPIRE', key, window)
-				return {1, limit - current - 1}
-			else
-				return {0, 0}
-			end
-		`
-
-		window := time.Duration(burst) * time.Second / time.Duration(rps)
-		now := time.Now().Unix()
-
-		result, err := redisClient.Eval(ctx, luaScript, []string{key},
-			int(window.Seconds()), burst, now).Result()
-
+		ok, err := limit(c, redisClient, "rate_limit_user:"+userID, rps, burst)
 		if err != nil {
 			// If Redis is down, allow the request (fail open)
 			c.Next()
 			return
 		}
-
-		results := result.([]interface{})
-		allowed := results[0].(int64)
-		remaining := results[1].(int64)
-
-		// Set rate limit headers
-		c.Header("X-RateLimit-Limit", fmt.Sprintf("%d", burst))
-		c.Header("X-RateLimit-Remaining", fmt.Sprintf("%d", remaining))
-		c.Header("X-RateLimit-Reset", fmt.Sprintf("%d", now+int64(window.Seconds())))
-
-		if allowed == 0 {
-			c.Header("Retry-After", fmt.Sprintf("%d", int(window.Seconds())))
-			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
-				"error":       "Rate limit exceeded",
-				"retry_after": int(window.Seconds()),
-			})
-			return
+		if ok {
+			c.Next()
 		}
-
-		c.Next()
 	}
 }
 
-// HybridRateLimit combines Redis and in-memory rate limiting
+// HybridRateLimit combines Redis and in-memory rate limiting: while Redis is unavailable,
+// each instance limits in memory. It is a fail-open PolicyRateLimit.
 func HybridRateLimit(redisClient *redis.Client, rps int, burst int) gin.HandlerFunc {
-	// Fallback to in-memory rate limiting if Redis is unavailable
-	memoryRateLimit := RateLimit(rps, burst)
-
-	return func(c *gin.Context) {
-		// Try Redis first
-		ctx := context.Background()
-
-		// Simple Redis check
-		_, err := redisClient.Ping(ctx).Result()
-		if err != nil {
-			// Redis is down, use in-memory fallback
-			memoryRateLimit(c)
-			return
-		}
-
-		// Use Redis rate limiting
-		RedisRateLimit(redisClient, rps, burst)(c)
-	}
+	return PolicyRateLimit(redisClient, RateLimitPolicy{RPS: rps, Burst: burst})
 }
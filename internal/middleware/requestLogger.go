@@ -3,16 +3,22 @@ package middleware
 import (
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/tracing"
 )
 
 // RequestLogger is a simple zap logger middleware.
 func RequestLogger(log *zap.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Next()
-		log.Info("request",
+		fields := []zap.Field{
 			zap.String("method", c.Request.Method),
 			zap.String("path", c.Request.URL.Path),
 			zap.Int("status", c.Writer.Status()),
-		)
+		}
+		if id := tracing.TraceID(c.Request.Context()); id != "" {
+			fields = append(fields, zap.String("trace_id", id))
+		}
+		log.Info("request", fields...)
 	}
 }
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// RoleChangesChannel is the Redis pub/sub channel carrying IDs of users whose role changed.
+const RoleChangesChannel = "role_changes"
+
+// RoleLookup loads a user's current role and role version from the source of truth.
+type RoleLookup func(ctx context.Context, userID string) (role string, version int, err error)
+
+type roleEntry struct {
+	role    string
+	version int
+	expires time.Time
+}
+
+// RoleCache keeps recently checked user roles in memory so admin requests don't hit
+// Postgres every time. Entries expire after ttl and are dropped immediately when a
+// role change is announced on RoleChangesChannel.
+type RoleCache struct {
+	log     *zap.Logger
+	lookup  RoleLookup
+	redis   *redis.Client
+	ttl     time.Duration
+	mu      sync.RWMutex
+	entries map[string]roleEntry
+}
+
+func NewRoleCache(log *zap.Logger, lookup RoleLookup, redis *redis.Client, ttl time.Duration) *RoleCache {
+	return &RoleCache{
+		log:     log,
+		lookup:  lookup,
+		redis:   redis,
+		ttl:     ttl,
+		entries: make(map[string]roleEntry),
+	}
+}
+
+// Get returns the cached role for userID, loading it on a miss.
+func (c *RoleCache) Get(ctx context.Context, userID string) (string, int, error) {
+	c.mu.RLock()
+	e, ok := c.entries[userID]
+	c.mu.RUnlock()
+	if ok && time.Now().Before(e.expires) {
+		return e.role, e.version, nil
+	}
+
+	role, version, err := c.lookup(ctx, userID)
+	if err != nil {
+		return "", 0, err
+	}
+	c.mu.Lock()
+	c.entries[userID] = roleEntry{role: role, version: version, expires: time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+	return role, version, nil
+}
+
+func (c *RoleCache) Invalidate(userID string) {
+	c.mu.Lock()
+	delete(c.entries, userID)
+	c.mu.Unlock()
+}
+
+// Listen drops cache entries as role changes are published, until ctx is done.
+func (c *RoleCache) Listen(ctx context.Context) {
+	sub := c.redis.Subscribe(ctx, RoleChangesChannel)
+	defer sub.Close()
+
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			c.Invalidate(msg.Payload)
+			c.log.Debug("role cache invalidated", zap.String("user_id", msg.Payload))
+		}
+	}
+}
+
+// PublishRoleChange tells every RoleCache to forget userID.
+func PublishRoleChange(ctx context.Context, client *redis.Client, userID string) error {
+	return client.Publish(ctx, RoleChangesChannel, userID).Err()
+}
@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+)
+
+// SessionCookie carries the session ID for browsers when AUTH_MODE is session.
+const SessionCookie = "evently_session"
+
+// ErrSessionNotFound is returned for a session ID that is unknown, expired or ended.
+var ErrSessionNotFound = errors.New("session not found")
+
+// session is what the store keeps for a session ID: the same facts a JWT carries.
+type session struct {
+	UserID      string    `json:"uid"`
+	Admin       bool      `json:"adm"`
+	RoleVersion int       `json:"rv"`
+	ExpiresAt   time.Time `json:"exp"`
+}
+
+// SessionStore keeps opaque server-side sessions in Redis, for deployments that want
+// sign-outs and revocations to apply at once instead of when a JWT expires. Sessions are
+// keyed by a hash of their ID, so the keyspace doesn't hold usable credentials, and each
+// user's sessions are indexed so they can all be ended together.
+type SessionStore struct {
+	redis *redis.Client
+	ttl   time.Duration
+}
+
+func NewSessionStore(redis *redis.Client, ttl time.Duration) *SessionStore {
+	return &SessionStore{redis: redis, ttl: ttl}
+}
+
+var sessions *SessionStore
+
+// UseSessionStore makes Middleware accept session IDs from s as well as JWTs.
+func UseSessionStore(s *SessionStore) {
+	sessions = s
+}
+
+// TTL is how long a session lasts from sign-in.
+func (s *SessionStore) TTL() time.Duration { return s.ttl }
+
+// Create starts a session for the user and returns its ID and expiry.
+func (s *SessionStore) Create(ctx context.Context, userID string, admin bool, roleVersion int) (string, time.Time, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", time.Time{}, err
+	}
+	id := hex.EncodeToString(b)
+	expires := time.Now().Add(s.ttl)
+	raw, err := json.Marshal(session{UserID: userID, Admin: admin, RoleVersion: roleVersion, ExpiresAt: expires})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	key := sessionKey(id)
+	_, err = s.redis.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Set(ctx, key, raw, s.ttl)
+		p.SAdd(ctx, userSessionsKey(userID), key)
+		// The index lives as long as the user's newest session; DeleteUser skips expired members
+		p.Expire(ctx, userSessionsKey(userID), s.ttl)
+		return nil
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return id, expires, nil
+}
+
+// Get returns the claims of the session id, or ErrSessionNotFound.
+func (s *SessionStore) Get(ctx context.Context, id string) (*Claims, error) {
+	raw, err := s.redis.Get(ctx, sessionKey(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var sess session
+	if err := json.Unmarshal(raw, &sess); err != nil {
+		return nil, err
+	}
+	return &Claims{UserID: sess.UserID, Admin: sess.Admin, RoleVersion: sess.RoleVersion,
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(sess.ExpiresAt)}}, nil
+}
+
+// Delete ends the session id. Ending one that doesn't exist is not an error.
+func (s *SessionStore) Delete(ctx context.Context, id string) error {
+	claims, err := s.Get(ctx, id)
+	if err == ErrSessionNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	key := sessionKey(id)
+	_, err = s.redis.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Del(ctx, key)
+		p.SRem(ctx, userSessionsKey(claims.UserID), key)
+		return nil
+	})
+	return err
+}
+
+// DeleteUser ends every session of the user and returns how many there were.
+func (s *SessionStore) DeleteUser(ctx context.Context, userID string) (int, error) {
+	keys, err := s.redis.SMembers(ctx, userSessionsKey(userID)).Result()
+	if err != nil {
+		return 0, err
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	n, err := s.redis.Del(ctx, append(keys, userSessionsKey(userID))...).Result()
+	if err != nil {
+		return 0, err
+	}
+	// The index itself was one of the deleted keys
+	return int(n) - 1, nil
+}
+
+// IsSessionID reports whether token is a session ID rather than a JWT, which always has
+// three dot-separated parts.
+func IsSessionID(token string) bool {
+	return token != "" && !strings.Contains(token, ".")
+}
+
+func sessionKey(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return "session:" + hex.EncodeToString(sum[:])
+}
+
+func userSessionsKey(userID string) string {
+	return "user_sessions:" + userID
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// TokenBlacklist records tokens revoked before they expire, such as on logout. Each entry is
+// keyed by the token's jti and expires with the token, so the set only holds tokens that
+// would otherwise still be accepted.
+type TokenBlacklist struct {
+	redis *redis.Client
+}
+
+func NewTokenBlacklist(redis *redis.Client) *TokenBlacklist {
+	return &TokenBlacklist{redis: redis}
+}
+
+var blacklist *TokenBlacklist
+
+// UseTokenBlacklist makes Middleware reject tokens revoked in b.
+func UseTokenBlacklist(b *TokenBlacklist) {
+	blacklist = b
+}
+
+// Revoke blacklists the token for the rest of its lifetime. Tokens issued before they
+// carried a jti can't be revoked and are left to expire.
+func (b *TokenBlacklist) Revoke(ctx context.Context, claims *Claims) error {
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return nil
+	}
+	ttl := time.Until(claims.ExpiresAt.Time)
+	if ttl <= 0 {
+		return nil
+	}
+	return b.redis.Set(ctx, revokedTokenKey(claims.ID), 1, ttl).Err()
+}
+
+// Revoked reports whether the token with jti has been revoked.
+func (b *TokenBlacklist) Revoked(ctx context.Context, jti string) (bool, error) {
+	err := b.redis.Get(ctx, revokedTokenKey(jti)).Err()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func revokedTokenKey(jti string) string {
+	return "revoked_token:" + jti
+}
@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestWebhookSignature(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	secrets := ParseWebhookSecrets("acme:new-secret,acme:old-secret,stripe:whsec_test")
+	const body = `{"booking_id":"b1"}`
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-10*time.Minute).Unix(), 10)
+
+	tests := []struct {
+		name     string
+		provider string
+		headers  map[string]string
+		want     int
+	}{
+		{
+			name: "signed with the current secret", provider: "acme",
+			headers: map[string]string{WebhookTimestampHeader: now, WebhookSignatureHeader: "v1=" + SignWebhook("new-secret", now, []byte(body))},
+			want:    http.StatusOK,
+		},
+		{
+			name: "signed with a secret being rotated out", provider: "acme",
+			headers: map[string]string{WebhookTimestampHeader: now, WebhookSignatureHeader: "v1=" + SignWebhook("old-secret", now, []byte(body))},
+			want:    http.StatusOK,
+		},
+		{
+			name: "stripe signature header", provider: "stripe",
+			headers: map[string]string{stripeSignatureHeader: "t=" + now + ",v1=" + SignWebhook("whsec_test", now, []byte(body))},
+			want:    http.StatusOK,
+		},
+		{
+			name: "unknown provider", provider: "other",
+			headers: map[string]string{WebhookTimestampHeader: now, WebhookSignatureHeader: "v1=" + SignWebhook("new-secret", now, []byte(body))},
+			want:    http.StatusNotFound,
+		},
+		{
+			name: "missing signature", provider: "acme",
+			headers: map[string]string{WebhookTimestampHeader: now},
+			want:    http.StatusUnauthorized,
+		},
+		{
+			name: "missing timestamp", provider: "acme",
+			headers: map[string]string{WebhookSignatureHeader: "v1=" + SignWebhook("new-secret", now, []byte(body))},
+			want:    http.StatusUnauthorized,
+		},
+		{
+			name: "signed with another secret", provider: "acme",
+			headers: map[string]string{WebhookTimestampHeader: now, WebhookSignatureHeader: "v1=" + SignWebhook("whsec_test", now, []byte(body))},
+			want:    http.StatusUnauthorized,
+		},
+		{
+			name: "signature of another body", provider: "acme",
+			headers: map[string]string{WebhookTimestampHeader: now, WebhookSignatureHeader: "v1=" + SignWebhook("new-secret", now, []byte(`{}`))},
+			want:    http.StatusUnauthorized,
+		},
+		{
+			name: "signature is not hex", provider: "acme",
+			headers: map[string]string{WebhookTimestampHeader: now, WebhookSignatureHeader: "v1=not-hex"},
+			want:    http.StatusUnauthorized,
+		},
+		{
+			name: "replayed outside the tolerance", provider: "acme",
+			headers: map[string]string{WebhookTimestampHeader: stale, WebhookSignatureHeader: "v1=" + SignWebhook("new-secret", stale, []byte(body))},
+			want:    http.StatusUnauthorized,
+		},
+		{
+			name: "timestamp is not a number", provider: "acme",
+			headers: map[string]string{WebhookTimestampHeader: "yesterday", WebhookSignatureHeader: "v1=" + SignWebhook("new-secret", "yesterday", []byte(body))},
+			want:    http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			reached := false
+			r := gin.New()
+			r.POST("/webhooks/:provider", WebhookSignature(secrets, 5*time.Minute), func(c *gin.Context) {
+				reached = true
+				b, _ := io.ReadAll(c.Request.Body)
+				got = string(b)
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/webhooks/"+tt.provider, strings.NewReader(body))
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if reached != (tt.want == http.StatusOK) {
+				t.Errorf("handler reached = %v", reached)
+			}
+			if reached && got != body {
+				t.Errorf("handler read body %q, want %q", got, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// ErrDeclined is the provider refusing an operation outright; retrying won't help. Any other
+// error may be transient.
+var ErrDeclined = errors.New("payment declined")
+
+// Provider moves money through a payment processor. Charge authorizes and captures in one
+// step; Authorize only reserves the amount on the buyer's card, and the authorization must
+// later be captured, or voided to release it. Each method returns the provider's reference
+// for what it created.
+type Provider interface {
+	Charge(ctx context.Context, paymentID string, amount float64, currency string) (string, error)
+	Authorize(ctx context.Context, paymentID string, amount float64, currency string) (string, error)
+	Capture(ctx context.Context, ref string, amount float64) error
+	Void(ctx context.Context, ref string) error
+	Refund(ctx context.Context, ref string, amount float64) error
+}
+
+// Simulated stands in for a real processor: every call succeeds after Delay.
+type Simulated struct {
+	Log   *zap.Logger
+	Delay time.Duration
+}
+
+func (s *Simulated) Charge(ctx context.Context, paymentID string, amount float64, currency string) (string, error) {
+	s.Log.Info("Processing payment", zap.String("payment_id", paymentID), zap.Float64("amount", amount), zap.String("currency", currency))
+	return "ch_" + uuid.NewString(), s.wait(ctx)
+}
+
+func (s *Simulated) Authorize(ctx context.Context, paymentID string, amount float64, currency string) (string, error) {
+	s.Log.Info("Authorizing payment", zap.String("payment_id", paymentID), zap.Float64("amount", amount), zap.String("currency", currency))
+	return "auth_" + uuid.NewString(), s.wait(ctx)
+}
+
+func (s *Simulated) Capture(ctx context.Context, ref string, amount float64) error {
+	s.Log.Info("Capturing payment", zap.String("ref", ref), zap.Float64("amount", amount))
+	return s.wait(ctx)
+}
+
+func (s *Simulated) Void(ctx context.Context, ref string) error {
+	s.Log.Info("Voiding authorization", zap.String("ref", ref))
+	return s.wait(ctx)
+}
+
+func (s *Simulated) Refund(ctx context.Context, ref string, amount float64) error {
+	s.Log.Info("Processing refund", zap.String("ref", ref), zap.Float64("amount", amount))
+	return s.wait(ctx)
+}
+
+func (s *Simulated) wait(ctx context.Context) error {
+	if s.Delay <= 0 {
+		return nil
+	}
+	t := time.NewTimer(s.Delay)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,306 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// StripeAPI is Stripe's API base URL.
+const StripeAPI = "https://api.stripe.com"
+
+// ErrActionRequired is a payment the buyer still has to complete (e.g. a 3DS challenge); it
+// can't be finished server-side.
+var ErrActionRequired = errors.New("payment requires action from the buyer")
+
+// Intent is a payment started server-side that the buyer completes on the payment page, such
+// as a Stripe PaymentIntent. The page confirms it with ClientSecret; the provider reports the
+// outcome by webhook.
+type Intent struct {
+	Ref          string  `json:"ref"`
+	ClientSecret string  `json:"client_secret"`
+	Status       string  `json:"status"`
+	Amount       float64 `json:"amount"`
+	Currency     string  `json:"currency"`
+}
+
+// IntentProvider is a Provider that can also start payments for the buyer to complete.
+// manual intents only authorize the card, to be captured later.
+type IntentProvider interface {
+	Provider
+	CreateIntent(ctx context.Context, bookingID string, amount float64, currency string, manual bool) (*Intent, error)
+}
+
+// Stripe moves money through Stripe PaymentIntents. paymentID passed to Charge and Authorize
+// is a Stripe PaymentMethod; references are PaymentIntent IDs. Intents for a booking,
+// captures, voids and refunds carry idempotency keys, so retrying them never moves money twice.
+type Stripe struct {
+	Log       *zap.Logger
+	SecretKey string
+	// BaseURL defaults to StripeAPI
+	BaseURL string
+	Client  *http.Client
+}
+
+// StripeIntent is the part of a PaymentIntent the API and its webhooks use. Amounts are in
+// the currency's minor unit.
+type StripeIntent struct {
+	ID               string            `json:"id"`
+	Status           string            `json:"status"`
+	Amount           int64             `json:"amount"`
+	AmountReceived   int64             `json:"amount_received"`
+	AmountCapturable int64             `json:"amount_capturable"`
+	Currency         string            `json:"currency"`
+	CaptureMethod    string            `json:"capture_method"`
+	ClientSecret     string            `json:"client_secret"`
+	Metadata         map[string]string `json:"metadata"`
+}
+
+// StripeEvent is a Stripe webhook event. Data.Object is the PaymentIntent for
+// payment_intent.* events.
+type StripeEvent struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Data struct {
+		Object json.RawMessage `json:"object"`
+	} `json:"data"`
+}
+
+// ParseStripeIntentEvent decodes a webhook body into the event and, for payment_intent.*
+// events, its PaymentIntent; the intent is nil for other event types.
+func ParseStripeIntentEvent(body []byte) (*StripeEvent, *StripeIntent, error) {
+	var e StripeEvent
+	if err := json.Unmarshal(body, &e); err != nil {
+		return nil, nil, err
+	}
+	if e.ID == "" || e.Type == "" {
+		return nil, nil, errors.New("not a stripe event")
+	}
+	if !strings.HasPrefix(e.Type, "payment_intent.") {
+		return &e, nil, nil
+	}
+	var pi StripeIntent
+	if err := json.Unmarshal(e.Data.Object, &pi); err != nil {
+		return nil, nil, err
+	}
+	return &e, &pi, nil
+}
+
+func (s *Stripe) Charge(ctx context.Context, paymentID string, amount float64, currency string) (string, error) {
+	pi, err := s.confirm(ctx, paymentID, amount, currency, "automatic")
+	if err != nil {
+		return "", err
+	}
+	if pi.Status != "succeeded" && pi.Status != "processing" {
+		return pi.ID, intentStatusError(pi.Status)
+	}
+	return pi.ID, nil
+}
+
+func (s *Stripe) Authorize(ctx context.Context, paymentID string, amount float64, currency string) (string, error) {
+	pi, err := s.confirm(ctx, paymentID, amount, currency, "manual")
+	if err != nil {
+		return "", err
+	}
+	if pi.Status != "requires_capture" {
+		return pi.ID, intentStatusError(pi.Status)
+	}
+	return pi.ID, nil
+}
+
+// confirm creates and confirms a PaymentIntent for the PaymentMethod in one call.
+func (s *Stripe) confirm(ctx context.Context, paymentMethod string, amount float64, currency, captureMethod string) (*StripeIntent, error) {
+	form := url.Values{}
+	form.Set("amount", strconv.FormatInt(ToMinor(amount, currency), 10))
+	form.Set("currency", strings.ToLower(currency))
+	form.Set("payment_method", paymentMethod)
+	form.Set("capture_method", captureMethod)
+	form.Set("confirm", "true")
+	// Server-side confirmation has no page to redirect the buyer back to
+	form.Set("automatic_payment_methods[enabled]", "true")
+	form.Set("automatic_payment_methods[allow_redirects]", "never")
+	var pi StripeIntent
+	// No idempotency key: a PaymentMethod may pay for several bookings of the same amount
+	err := s.post(ctx, "/v1/payment_intents", "", form, &pi)
+	return &pi, err
+}
+
+// CreateIntent starts a PaymentIntent tagged with the booking for the payment page to
+// confirm. Asking again for the same booking returns the same intent.
+func (s *Stripe) CreateIntent(ctx context.Context, bookingID string, amount float64, currency string, manual bool) (*Intent, error) {
+	captureMethod := "automatic"
+	if manual {
+		captureMethod = "manual"
+	}
+	form := url.Values{}
+	form.Set("amount", strconv.FormatInt(ToMinor(amount, currency), 10))
+	form.Set("currency", strings.ToLower(currency))
+	form.Set("capture_method", captureMethod)
+	form.Set("automatic_payment_methods[enabled]", "true")
+	form.Set("metadata[booking_id]", bookingID)
+	var pi StripeIntent
+	if err := s.post(ctx, "/v1/payment_intents", "intent:"+bookingID+":"+captureMethod, form, &pi); err != nil {
+		return nil, err
+	}
+	s.Log.Info("Created payment intent", zap.String("booking_id", bookingID), zap.String("ref", pi.ID))
+	return &Intent{Ref: pi.ID, ClientSecret: pi.ClientSecret, Status: pi.Status, Amount: amount, Currency: strings.ToUpper(currency)}, nil
+}
+
+func (s *Stripe) Capture(ctx context.Context, ref string, amount float64) error {
+	// The intent knows its currency; the minor amount is what Stripe captures
+	pi, err := s.intent(ctx, ref)
+	if err != nil {
+		return err
+	}
+	form := url.Values{}
+	form.Set("amount_to_capture", strconv.FormatInt(ToMinor(amount, pi.Currency), 10))
+	return s.post(ctx, "/v1/payment_intents/"+url.PathEscape(ref)+"/capture", "capture:"+ref, form, &pi)
+}
+
+func (s *Stripe) Void(ctx context.Context, ref string) error {
+	var pi StripeIntent
+	return s.post(ctx, "/v1/payment_intents/"+url.PathEscape(ref)+"/cancel", "void:"+ref, url.Values{}, &pi)
+}
+
+// Refund refunds amount of the intent's charge. The idempotency key carries what the charge
+// had refunded before, so a retried refund is made once while a later one of the same
+// amount, such as a second seat cancelled on its own, is still made.
+func (s *Stripe) Refund(ctx context.Context, ref string, amount float64) error {
+	var pi struct {
+		Currency     string `json:"currency"`
+		LatestCharge struct {
+			AmountRefunded int64 `json:"amount_refunded"`
+		} `json:"latest_charge"`
+	}
+	err := s.do(ctx, http.MethodGet, "/v1/payment_intents/"+url.PathEscape(ref)+"?"+url.Values{"expand[]": {"latest_charge"}}.Encode(), "", nil, &pi)
+	if err != nil {
+		return err
+	}
+	minor := ToMinor(amount, pi.Currency)
+	form := url.Values{}
+	form.Set("payment_intent", ref)
+	form.Set("amount", strconv.FormatInt(minor, 10))
+	var refund struct {
+		ID string `json:"id"`
+	}
+	return s.post(ctx, "/v1/refunds", fmt.Sprintf("refund:%s:%d:%d", ref, pi.LatestCharge.AmountRefunded, minor), form, &refund)
+}
+
+func (s *Stripe) intent(ctx context.Context, ref string) (*StripeIntent, error) {
+	var pi StripeIntent
+	err := s.do(ctx, http.MethodGet, "/v1/payment_intents/"+url.PathEscape(ref), "", nil, &pi)
+	return &pi, err
+}
+
+func (s *Stripe) post(ctx context.Context, path, idempotencyKey string, form url.Values, out any) error {
+	return s.do(ctx, http.MethodPost, path, idempotencyKey, form, out)
+}
+
+func (s *Stripe) do(ctx context.Context, method, path, idempotencyKey string, form url.Values, out any) error {
+	base := s.BaseURL
+	if base == "" {
+		base = StripeAPI
+	}
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(base, "/")+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.SecretKey)
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return stripeError(resp.StatusCode, raw)
+	}
+	return json.Unmarshal(raw, out)
+}
+
+// stripeError turns an API error into an error, wrapping ErrDeclined for card errors (402),
+// which retrying won't fix.
+func stripeError(status int, raw []byte) error {
+	var e struct {
+		Error struct {
+			Type    string `json:"type"`
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	_ = json.Unmarshal(raw, &e)
+	msg := e.Error.Message
+	if msg == "" {
+		msg = http.StatusText(status)
+	}
+	if status == http.StatusPaymentRequired || e.Error.Type == "card_error" {
+		return fmt.Errorf("%w: %s", ErrDeclined, msg)
+	}
+	return fmt.Errorf("stripe: %d %s: %s", status, e.Error.Code, msg)
+}
+
+func intentStatusError(status string) error {
+	if status == "requires_action" {
+		return ErrActionRequired
+	}
+	return fmt.Errorf("%w: payment intent is %s", ErrDeclined, status)
+}
+
+// zeroDecimal are the currencies Stripe takes in whole units.
+var zeroDecimal = map[string]bool{
+	"BIF": true, "CLP": true, "DJF": true, "GNF": true, "JPY": true, "KMF": true, "KRW": true, "MGA": true,
+	"PYG": true, "RWF": true, "UGX": true, "VND": true, "VUV": true, "XAF": true, "XOF": true, "XPF": true,
+}
+
+// ToMinor converts amount to the currency's minor unit, as Stripe takes amounts.
+func ToMinor(amount float64, currency string) int64 {
+	if zeroDecimal[strings.ToUpper(currency)] {
+		return int64(math.Round(amount))
+	}
+	return int64(math.Round(amount * 100))
+}
+
+// FromMinor converts a Stripe amount back to the currency's major unit.
+func FromMinor(amount int64, currency string) float64 {
+	if zeroDecimal[strings.ToUpper(currency)] {
+		return float64(amount)
+	}
+	return float64(amount) / 100
+}
+
+// NewProvider returns Stripe when a secret key is configured and the simulated processor
+// otherwise, so local stacks run without an account.
+func NewProvider(log *zap.Logger, stripeKey, stripeURL string) Provider {
+	if stripeKey == "" {
+		return &Simulated{Log: log, Delay: 100 * time.Millisecond}
+	}
+	return &Stripe{Log: log, SecretKey: stripeKey, BaseURL: stripeURL}
+}
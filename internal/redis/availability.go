@@ -0,0 +1,60 @@
+package redisx
+
+import (
+	"context"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+)
+
+// Availability buckets shown as badges on event listings.
+const (
+	AvailabilityAvailable = "available"
+	AvailabilityLimited   = "limited"
+	AvailabilitySoldOut   = "sold_out"
+)
+
+// availabilityKey is a hash of event ID to availability bucket, rewritten as a whole by the
+// availability job so events whose counter went away drop out.
+const availabilityKey = "event_availability"
+
+// SetAvailability replaces every event's availability bucket with buckets in one
+// transaction, so readers never see a half-written set.
+func (t *TokenBucket) SetAvailability(ctx context.Context, buckets map[string]string) error {
+	start := time.Now()
+	_, err := t.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, availabilityKey)
+		if len(buckets) > 0 {
+			pipe.HSet(ctx, availabilityKey, buckets)
+		}
+		return nil
+	})
+	if err != nil {
+		observe("set_availability", start, "error")
+		return err
+	}
+	observe("set_availability", start, "success")
+	return nil
+}
+
+// Availability returns the bucket of each of ids that has one; events the job hasn't
+// classified are left out.
+func (t *TokenBucket) Availability(ctx context.Context, ids []string) (map[string]string, error) {
+	out := make(map[string]string, len(ids))
+	if len(ids) == 0 {
+		return out, nil
+	}
+	start := time.Now()
+	vals, err := t.client.HMGet(ctx, availabilityKey, ids...).Result()
+	if err != nil {
+		observe("availability", start, "error")
+		return nil, err
+	}
+	observe("availability", start, "success")
+	for i, v := range vals {
+		if s, ok := v.(string); ok {
+			out[ids[i]] = s
+		}
+	}
+	return out, nil
+}
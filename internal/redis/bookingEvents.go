@@ -0,0 +1,114 @@
+package redisx
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/domain"
+)
+
+// Booking status transitions pushed to clients watching a booking.
+const (
+	BookingEventPaymentRequested = "payment_requested"
+	BookingEventPaymentReceived  = "payment_received"
+	BookingEventPaymentExtended  = "payment_extended"
+	BookingEventExpired          = "expired"
+	BookingEventCancelled        = "cancelled"
+	BookingEventWaitlistPromoted = "waitlist_promoted"
+	// The seats are held but the payment link waits for the payment service to recover
+	BookingEventPaymentDelayed = "payment_delayed"
+	// Support moved the booking to other seats
+	BookingEventReseated = "reseated"
+)
+
+// BookingEvent is one status transition of a booking.
+type BookingEvent struct {
+	Type      string               `json:"type"`
+	BookingID string               `json:"booking_id"`
+	Status    domain.BookingStatus `json:"status"`
+	At        time.Time            `json:"at"`
+	ExpiresAt *time.Time           `json:"expires_at,omitempty"` // payment deadline of a pending booking
+}
+
+// Listener is handed every event Publish announces, e.g. to forward it somewhere durable.
+// It handles its own errors.
+type Listener func(ctx context.Context, e BookingEvent)
+
+// BookingEvents fans booking status transitions out over Redis pub/sub, one channel per
+// booking, so whichever API instance holds a client's stream receives them.
+type BookingEvents struct {
+	client    *redis.Client
+	listeners []Listener
+}
+
+func NewBookingEvents(addr string) *BookingEvents {
+	c := newClient(addr)
+	return &BookingEvents{client: c}
+}
+
+func bookingEventsChannel(bookingID string) string { return "booking_events:" + bookingID }
+
+// OnPublish registers l to be called by Publish with each event, before it goes to Redis, so
+// it sees the event even while Redis is down.
+func (b *BookingEvents) OnPublish(l Listener) *BookingEvents {
+	b.listeners = append(b.listeners, l)
+	return b
+}
+
+// Publish announces e on its booking's channel. Nobody listening is not an error.
+func (b *BookingEvents) Publish(ctx context.Context, e BookingEvent) error {
+	if e.At.IsZero() {
+		e.At = time.Now()
+	}
+	for _, l := range b.listeners {
+		l(ctx, e)
+	}
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(ctx, bookingEventsChannel(e.BookingID), payload).Err()
+}
+
+// Subscribe streams the booking's events until ctx is done. The returned channel is closed
+// when the subscription ends.
+func (b *BookingEvents) Subscribe(ctx context.Context, bookingID string) (<-chan BookingEvent, error) {
+	sub := b.client.Subscribe(ctx, bookingEventsChannel(bookingID))
+	// Wait for the confirmation so events published right after Subscribe returns aren't missed
+	if _, err := sub.Receive(ctx); err != nil {
+		_ = sub.Close()
+		return nil, err
+	}
+
+	out := make(chan BookingEvent)
+	go func() {
+		defer close(out)
+		defer sub.Close()
+		msgs := sub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case m, ok := <-msgs:
+				if !ok {
+					return
+				}
+				var e BookingEvent
+				if err := json.Unmarshal([]byte(m.Payload), &e); err != nil {
+					continue
+				}
+				select {
+				case out <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (b *BookingEvents) Close() { _ = b.client.Close() }
@@ -2,44 +2,199 @@ package redisx
 
 import (
 	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
 
 	redis "github.com/redis/go-redis/v9"
 )
 
+// Timeout marker values. A pending booking's marker is "processing:<deadline>", or bare
+// "processing" when written before deadlines were recorded, and becomes
+// "extended:<new deadline>" once its payment window has been extended. Deadlines are unix seconds.
+const (
+	timeoutProcessing = "processing"
+	timeoutExtended   = "extended"
+)
+
+var (
+	// ErrNoPaymentWindow means the booking has no running payment timeout to extend: the
+	// worker has not scheduled it yet, or it has already fired.
+	ErrNoPaymentWindow = errors.New("booking has no running payment window")
+	// ErrAlreadyExtended means the payment window was already extended once.
+	ErrAlreadyExtended = errors.New("payment window already extended")
+	// ErrPaymentWindowOver means the deadline passed before the extension arrived.
+	ErrPaymentWindowOver = errors.New("payment window is already over")
+)
+
+// timeoutsKey is the schedule of payment timeouts: a sorted set of marker keys scored by
+// the unix second each booking's payment window closes. Workers poll it with ClaimDue.
+const timeoutsKey = "booking_timeouts"
+
+// extendTimeoutLua moves a pending booking's deadline back by ARGV[1] seconds, once, in both
+// its marker (KEYS[1]) and the schedule (KEYS[2]). ARGV[2] is now, which stands in for the
+// deadline of a marker that has none.
+// Returns {1, new deadline}, {0, deadline} if already extended, {-1, 0} when there is no
+// running timeout, or {-2, deadline} if the deadline has passed.
+const extendTimeoutLua = `
+local v = redis.call('GET', KEYS[1])
+if not v then
+  return {-1, 0}
+end
+local now = tonumber(ARGV[2])
+local deadline
+if v == 'processing' then
+  deadline = now
+elseif string.sub(v, 1, 11) == 'processing:' then
+  deadline = tonumber(string.sub(v, 12))
+elseif string.sub(v, 1, 9) == 'extended:' then
+  return {0, tonumber(string.sub(v, 10))}
+else
+  return {-1, 0}
+end
+if deadline < now then
+  return {-2, deadline}
+end
+local extended = deadline + tonumber(ARGV[1])
+redis.call('SET', KEYS[1], 'extended:' .. extended)
+redis.call('ZADD', KEYS[2], extended, KEYS[1])
+return {1, extended}`
+
+// claimDueTimeoutsLua returns up to ARGV[2] scheduled timeouts due by ARGV[1] and pushes
+// each back by the lease in ARGV[3], so no other worker claims them meanwhile and one whose
+// worker died fires again once the lease runs out.
+const claimDueTimeoutsLua = `
+local now = tonumber(ARGV[1])
+local due = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', now, 'LIMIT', 0, tonumber(ARGV[2]))
+for _, m in ipairs(due) do
+  redis.call('ZADD', KEYS[1], now + tonumber(ARGV[3]), m)
+end
+return due`
+
 type TimeoutBucket struct {
 	client *redis.Client
 }
 
 func NewTimeoutBucket(addr string) *TimeoutBucket {
-	c := redis.NewClient(&redis.Options{Addr: addr})
+	c := newClient(addr)
 	return &TimeoutBucket{client: c}
 }
 
+// timeoutKeyPattern matches every payment-timeout key of an event's bookings.
+func timeoutKeyPattern(eventID string) string { return eventID + ":*" }
+
+// GetClient returns the underlying Redis client for pub/sub listeners
+func (t *TimeoutBucket) GetClient() *redis.Client {
+	return t.client
+}
+
 func (t *TimeoutBucket) NilError() error {
 	return redis.Nil
 }
 
-func (t *TimeoutBucket) AddBooking(ctx context.Context, eventID string, bookingID string) error {
+// AddBooking marks the booking's payment timeout as running until deadline and schedules
+// it to fire then.
+func (t *TimeoutBucket) AddBooking(ctx context.Context, eventID string, bookingID string, deadline time.Time) error {
 	key := eventID + ":" + bookingID
-	return t.client.Set(ctx, key, "processing", 0).Err()
+	_, err := t.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Set(ctx, key, timeoutProcessing+":"+strconv.FormatInt(deadline.Unix(), 10), 0)
+		p.ZAdd(ctx, timeoutsKey, redis.Z{Score: float64(deadline.Unix()), Member: key})
+		return nil
+	})
+	return err
+}
+
+// DueTimeout is a booking whose payment window has closed.
+type DueTimeout struct {
+	EventID   string
+	BookingID string
+}
+
+// ClaimDue returns up to limit bookings whose payment window closed by now, leasing them
+// for lease: they aren't returned again until it runs out, unless DeleteBooking drops them
+// first.
+func (t *TimeoutBucket) ClaimDue(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]DueTimeout, error) {
+	keys, err := t.client.Eval(ctx, claimDueTimeoutsLua, []string{timeoutsKey}, now.Unix(), limit, int64(lease/time.Second)).StringSlice()
+	if err != nil {
+		return nil, err
+	}
+	due := make([]DueTimeout, 0, len(keys))
+	for _, key := range keys {
+		eventID, bookingID, ok := strings.Cut(key, ":")
+		if !ok {
+			// Not a marker key; nothing would ever settle it
+			t.client.ZRem(ctx, timeoutsKey, key)
+			continue
+		}
+		due = append(due, DueTimeout{EventID: eventID, BookingID: bookingID})
+	}
+	return due, nil
+}
+
+// Scheduled returns how many payment timeouts are waiting to fire or be settled.
+func (t *TimeoutBucket) Scheduled(ctx context.Context) (int64, error) {
+	return t.client.ZCard(ctx, timeoutsKey).Result()
 }
 
 func (t *TimeoutBucket) GetBooking(ctx context.Context, eventID string, bookingID string) (string, error) {
 	key := eventID + ":" + bookingID
 	v, err := t.client.Get(ctx, key).Result()
 	if err == t.NilError() {
-		return "processing", nil
+		return timeoutProcessing, nil
 	}
 	return v, err
 }
 
+// Extend pushes the booking's payment deadline back by by, which is only allowed once
+// per booking, and returns the new deadline.
+func (t *TimeoutBucket) Extend(ctx context.Context, eventID string, bookingID string, by time.Duration) (time.Time, error) {
+	key := eventID + ":" + bookingID
+	res, err := t.client.Eval(ctx, extendTimeoutLua, []string{key, timeoutsKey}, int64(by/time.Second), time.Now().Unix()).Int64Slice()
+	if err != nil {
+		return time.Time{}, err
+	}
+	deadline := time.Unix(res[1], 0)
+	switch res[0] {
+	case 1:
+		return deadline, nil
+	case 0:
+		return deadline, ErrAlreadyExtended
+	case -2:
+		return deadline, ErrPaymentWindowOver
+	default:
+		return time.Time{}, ErrNoPaymentWindow
+	}
+}
+
+// ExtendedUntil reports the new deadline held by a marker value read with GetBooking, if
+// the booking's payment window was extended.
+func ExtendedUntil(v string) (time.Time, bool) {
+	rest, ok := strings.CutPrefix(v, timeoutExtended+":")
+	if !ok {
+		return time.Time{}, false
+	}
+	unix, err := strconv.ParseInt(rest, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(unix, 0), true
+}
+
+// DeleteBooking drops the booking's timeout marker and takes it off the schedule, once the
+// timeout has been settled.
 func (t *TimeoutBucket) DeleteBooking(ctx context.Context, eventID string, bookingID string) (int, error) {
 	key := eventID + ":" + bookingID
-	deletedCount, err := t.client.Del(ctx, key).Result()
+	var del *redis.IntCmd
+	_, err := t.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		del = p.Del(ctx, key)
+		p.ZRem(ctx, timeoutsKey, key)
+		return nil
+	})
 	if err != nil {
 		return 1, err
 	}
-	return int(deletedCount), err
+	return int(del.Val()), nil
 }
 
 func (t *TimeoutBucket) Close() { _ = t.client.Close() }
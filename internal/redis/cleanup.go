@@ -0,0 +1,37 @@
+package redisx
+
+import (
+	"context"
+	"strings"
+)
+
+const scanBatch = 500
+
+// ReleaseEventKeys deletes every key owned by an event: its token counter and sale phase
+// buckets, its seat holds and any payment-timeout markers of its bookings. It returns the
+// number of keys removed.
+func (t *TokenBucket) ReleaseEventKeys(ctx context.Context, eventID string) (int, error) {
+	keys := []string{t.key(eventID), t.seatHoldsKey(eventID)}
+	for _, pattern := range []string{timeoutKeyPattern(eventID), t.phaseKeyPattern(eventID)} {
+		iter := t.client.Scan(ctx, 0, pattern, scanBatch).Iterator()
+		for iter.Next(ctx) {
+			keys = append(keys, iter.Val())
+		}
+		if err := iter.Err(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := t.client.Del(ctx, keys...).Result()
+	return int(n), err
+}
+
+// EventIDsWithTokens lists the events that currently have a token counter in Redis.
+func (t *TokenBucket) EventIDsWithTokens(ctx context.Context) ([]string, error) {
+	var ids []string
+	prefix := t.key("")
+	iter := t.client.Scan(ctx, 0, prefix+"*", scanBatch).Iterator()
+	for iter.Next(ctx) {
+		ids = append(ids, strings.TrimPrefix(iter.Val(), prefix))
+	}
+	return ids, iter.Err()
+}
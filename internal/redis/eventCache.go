@@ -0,0 +1,73 @@
+package redisx
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+)
+
+// eventCacheGenKey is bumped whenever an event changes. Cached events and lists are stored
+// under the generation current when they were read from Postgres, so a bump retires all of
+// them at once, including any whose load was still in flight.
+const eventCacheGenKey = "event_cache:gen"
+
+func (t *TokenBucket) eventCacheKey(gen int64, name string) string {
+	return fmt.Sprintf("event_cache:%d:%s", gen, name)
+}
+
+// EventCacheGeneration returns the current generation of the event cache.
+func (t *TokenBucket) EventCacheGeneration(ctx context.Context) (int64, error) {
+	start := time.Now()
+	gen, err := t.client.Get(ctx, eventCacheGenKey).Int64()
+	if err != nil && err != redis.Nil {
+		observe("event_cache_gen", start, "error")
+		return 0, err
+	}
+	observe("event_cache_gen", start, "success")
+	return gen, nil
+}
+
+// CachedEventData returns what SetCachedEventData stored under name in generation gen, or
+// nil if nothing is.
+func (t *TokenBucket) CachedEventData(ctx context.Context, gen int64, name string) ([]byte, error) {
+	start := time.Now()
+	b, err := t.client.Get(ctx, t.eventCacheKey(gen, name)).Bytes()
+	if err == redis.Nil {
+		observe("event_cache_get", start, "miss")
+		return nil, nil
+	}
+	if err != nil {
+		observe("event_cache_get", start, "error")
+		return nil, err
+	}
+	observe("event_cache_get", start, "success")
+	return b, nil
+}
+
+// SetCachedEventData caches an encoded event or list under name in generation gen for ttl.
+func (t *TokenBucket) SetCachedEventData(ctx context.Context, gen int64, name string, data []byte, ttl time.Duration) error {
+	start := time.Now()
+	if err := t.client.Set(ctx, t.eventCacheKey(gen, name), data, ttl).Err(); err != nil {
+		observe("event_cache_set", start, "error")
+		return err
+	}
+	observe("event_cache_set", start, "success")
+	return nil
+}
+
+// InvalidateEvent retires every cached event and list, on every instance, and drops the
+// event's cached stats.
+func (t *TokenBucket) InvalidateEvent(ctx context.Context, eventID string) error {
+	start := time.Now()
+	pipe := t.client.TxPipeline()
+	pipe.Incr(ctx, eventCacheGenKey)
+	pipe.Del(ctx, t.eventStatsKey(eventID))
+	if _, err := pipe.Exec(ctx); err != nil {
+		observe("event_cache_invalidate", start, "error")
+		return err
+	}
+	observe("event_cache_invalidate", start, "success")
+	return nil
+}
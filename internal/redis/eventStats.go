@@ -0,0 +1,41 @@
+package redisx
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+)
+
+func (t *TokenBucket) eventStatsKey(eventID string) string {
+	return fmt.Sprintf("event_stats:%s", eventID)
+}
+
+// CachedEventStats returns the event's cached public stats as encoded by SetEventStats, or
+// nil if none are cached.
+func (t *TokenBucket) CachedEventStats(ctx context.Context, eventID string) ([]byte, error) {
+	start := time.Now()
+	b, err := t.client.Get(ctx, t.eventStatsKey(eventID)).Bytes()
+	if err == redis.Nil {
+		observe("event_stats_get", start, "miss")
+		return nil, nil
+	}
+	if err != nil {
+		observe("event_stats_get", start, "error")
+		return nil, err
+	}
+	observe("event_stats_get", start, "success")
+	return b, nil
+}
+
+// SetEventStats caches the event's encoded public stats for ttl.
+func (t *TokenBucket) SetEventStats(ctx context.Context, eventID string, stats []byte, ttl time.Duration) error {
+	start := time.Now()
+	if err := t.client.Set(ctx, t.eventStatsKey(eventID), stats, ttl).Err(); err != nil {
+		observe("event_stats_set", start, "error")
+		return err
+	}
+	observe("event_stats_set", start, "success")
+	return nil
+}
@@ -0,0 +1,51 @@
+package redisx
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EvictionPolicy returns the server's maxmemory-policy. Servers that disable CONFIG (some
+// managed offerings) return an error.
+func (t *TokenBucket) EvictionPolicy(ctx context.Context) (string, error) {
+	res, err := t.client.ConfigGet(ctx, "maxmemory-policy").Result()
+	if err != nil {
+		return "", err
+	}
+	return res["maxmemory-policy"], nil
+}
+
+// BadTokenCounters lists the token counters that aren't a non-negative integer or that have
+// an expiry set, either of which makes Reserve misbehave. It scans the whole keyspace.
+func (t *TokenBucket) BadTokenCounters(ctx context.Context) ([]string, error) {
+	var bad []string
+	prefix := t.key("")
+	iter := t.client.Scan(ctx, 0, prefix+"*", scanBatch).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		v, err := t.client.Get(ctx, key).Result()
+		if err != nil {
+			// Gone since the scan
+			continue
+		}
+		ttl, err := t.client.TTL(ctx, key).Result()
+		if err != nil {
+			return nil, err
+		}
+		if n, err := strconv.Atoi(v); err != nil || n < 0 || ttl > 0 {
+			bad = append(bad, strings.TrimPrefix(key, prefix))
+		}
+	}
+	return bad, iter.Err()
+}
+
+// Ping returns how long Redis took to answer a PING.
+func (t *TokenBucket) Ping(ctx context.Context) (time.Duration, error) {
+	start := time.Now()
+	if err := t.client.Ping(ctx).Err(); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
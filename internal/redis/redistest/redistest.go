@@ -0,0 +1,18 @@
+// Package redistest connects tests to a Redis server.
+package redistest
+
+import (
+	"os"
+	"testing"
+)
+
+// Addr returns the address of the Redis server at TEST_REDIS_ADDR. The test is skipped when
+// TEST_REDIS_ADDR isn't set. Tests share the server, so each should keep to keys of its own.
+func Addr(t *testing.T) string {
+	t.Helper()
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("TEST_REDIS_ADDR is not set")
+	}
+	return addr
+}
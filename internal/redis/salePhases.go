@@ -0,0 +1,144 @@
+package redisx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+)
+
+// ErrNoPhaseTokens is returned by ReservePhase when the phase has no bucket yet.
+var ErrNoPhaseTokens = errors.New("sale phase has no token bucket")
+
+// reservePhaseLua is reserveLua, except that a missing bucket returns -1 instead of
+// reading as empty, so the caller can create it from Postgres rather than turn buyers away.
+const reservePhaseLua = `
+local current = redis.call('GET', KEYS[1])
+if not current then
+  return -1
+end
+local n = tonumber(ARGV[1])
+if tonumber(current) >= n then
+  redis.call('DECRBY', KEYS[1], n)
+  return 1
+end
+return 0`
+
+// adjustPhaseLua adds ARGV[1] to a bucket that exists. A missing bucket is left missing:
+// it is created from Postgres, which already counts the change.
+const adjustPhaseLua = `
+if redis.call('EXISTS', KEYS[1]) == 1 then
+  return redis.call('INCRBY', KEYS[1], ARGV[1])
+end
+return 0`
+
+// phaseKey is the token bucket of one of an event's sale phases. It is kept apart from the
+// event_tokens: prefix, which EventIDsWithTokens lists.
+func (t *TokenBucket) phaseKey(eventID, phaseID string) string {
+	return fmt.Sprintf("phase_tokens:%s:%s", eventID, phaseID)
+}
+
+// phaseKeyPattern matches every sale phase bucket of an event.
+func (t *TokenBucket) phaseKeyPattern(eventID string) string { return t.phaseKey(eventID, "*") }
+
+// ReservePhase takes n tokens from the sale phase's bucket, on top of the event's own. It
+// fails with ErrNoPhaseTokens if the bucket doesn't exist yet.
+func (t *TokenBucket) ReservePhase(ctx context.Context, eventID, phaseID string, n int) (bool, error) {
+	start := time.Now()
+	v, err := t.client.Eval(ctx, reservePhaseLua, []string{t.phaseKey(eventID, phaseID)}, n).Int()
+	if err != nil {
+		observe("reserve_phase", start, "error")
+		return false, err
+	}
+	switch v {
+	case -1:
+		observe("reserve_phase", start, "missing")
+		return false, ErrNoPhaseTokens
+	case 1:
+		observe("reserve_phase", start, "success")
+		return true, nil
+	}
+	observe("reserve_phase", start, "insufficient")
+	return false, nil
+}
+
+// ReleasePhase returns n tokens to the sale phase's bucket, if it has one.
+func (t *TokenBucket) ReleasePhase(ctx context.Context, eventID, phaseID string, n int) error {
+	start := time.Now()
+	if err := t.client.Eval(ctx, adjustPhaseLua, []string{t.phaseKey(eventID, phaseID)}, n).Err(); err != nil {
+		observe("release_phase", start, "error")
+		return err
+	}
+	observe("release_phase", start, "success")
+	return nil
+}
+
+// MovePhaseTokens moves n tokens from one of the event's sale phase buckets to another, for
+// quantity rolled over between them. Buckets that don't exist are left alone.
+func (t *TokenBucket) MovePhaseTokens(ctx context.Context, eventID, fromID, toID string, n int) error {
+	if err := t.client.Eval(ctx, adjustPhaseLua, []string{t.phaseKey(eventID, fromID)}, -n).Err(); err != nil {
+		return err
+	}
+	return t.client.Eval(ctx, adjustPhaseLua, []string{t.phaseKey(eventID, toID)}, n).Err()
+}
+
+// EnsurePhaseTokens creates the sale phase's bucket with n tokens if it doesn't exist,
+// reporting whether it did.
+func (t *TokenBucket) EnsurePhaseTokens(ctx context.Context, eventID, phaseID string, n int) (bool, error) {
+	return t.client.SetNX(ctx, t.phaseKey(eventID, phaseID), n, 0).Result()
+}
+
+// InitPhaseTokens sets the sale phase's bucket to n tokens.
+func (t *TokenBucket) InitPhaseTokens(ctx context.Context, eventID, phaseID string, n int) error {
+	return t.client.Set(ctx, t.phaseKey(eventID, phaseID), n, 0).Err()
+}
+
+// RemainingPhase returns the tokens left in the sale phase's bucket, 0 if it has none.
+func (t *TokenBucket) RemainingPhase(ctx context.Context, eventID, phaseID string) (int, error) {
+	v, err := t.client.Get(ctx, t.phaseKey(eventID, phaseID)).Int()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return v, err
+}
+
+// DropPhaseTokens deletes the buckets of the event's sale phases other than keep, for phases
+// that were removed. It returns the number of buckets removed.
+func (t *TokenBucket) DropPhaseTokens(ctx context.Context, eventID string, keep []string) (int, error) {
+	kept := make(map[string]bool, len(keep))
+	for _, id := range keep {
+		kept[t.phaseKey(eventID, id)] = true
+	}
+	var keys []string
+	iter := t.client.Scan(ctx, 0, t.phaseKeyPattern(eventID), scanBatch).Iterator()
+	for iter.Next(ctx) {
+		if !kept[iter.Val()] {
+			keys = append(keys, iter.Val())
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return 0, err
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	n, err := t.client.Del(ctx, keys...).Result()
+	return int(n), err
+}
+
+// DropAllPhaseTokens deletes the sale phase buckets of every event, for each to be created
+// again from Postgres when it is next booked. It returns the number of buckets removed.
+func (t *TokenBucket) DropAllPhaseTokens(ctx context.Context) (int, error) {
+	removed := 0
+	iter := t.client.Scan(ctx, 0, t.phaseKey("*", "*"), scanBatch).Iterator()
+	for iter.Next(ctx) {
+		n, err := t.client.Del(ctx, iter.Val()).Result()
+		if err != nil {
+			return removed, err
+		}
+		removed += int(n)
+	}
+	return removed, iter.Err()
+}
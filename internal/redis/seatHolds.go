@@ -0,0 +1,92 @@
+package redisx
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// holdSeatsLua claims seat labels in an event's hash of holds for a holder, all or none.
+// Fields are "<holder>:<expires at, ms>"; a hold past its expiry counts as free and one the
+// holder already has is renewed. Returns the labels held by someone else, empty on success.
+// The hash expires once its longest-lived hold would have.
+const holdSeatsLua = `
+local key = KEYS[1]
+local holder = ARGV[1]
+local now = tonumber(ARGV[2])
+local ttl = tonumber(ARGV[3])
+local taken = {}
+for i = 4, #ARGV do
+  local v = redis.call('HGET', key, ARGV[i])
+  if v then
+    local h, exp = string.match(v, '^(.*):(%d+)$')
+    if h ~= holder and tonumber(exp) > now then
+      table.insert(taken, ARGV[i])
+    end
+  end
+end
+if #taken > 0 then
+  return taken
+end
+for i = 4, #ARGV do
+  redis.call('HSET', key, ARGV[i], holder .. ':' .. (now + ttl))
+end
+if redis.call('PTTL', key) < ttl then
+  redis.call('PEXPIRE', key, ttl)
+end
+return taken`
+
+// releaseSeatsLua drops the holder's holds on the labels, leaving anyone else's alone.
+const releaseSeatsLua = `
+local key = KEYS[1]
+local prefix = ARGV[1] .. ':'
+local n = 0
+for i = 2, #ARGV do
+  local v = redis.call('HGET', key, ARGV[i])
+  if v and string.sub(v, 1, #prefix) == prefix then
+    n = n + redis.call('HDEL', key, ARGV[i])
+  end
+end
+return n`
+
+func (t *TokenBucket) seatHoldsKey(eventID string) string {
+	return fmt.Sprintf("event_seat_holds:%s", eventID)
+}
+
+// HoldSeats atomically holds every one of seats of the event for holder for ttl, or none of
+// them: it returns the labels someone else holds, and holds nothing, when any are taken.
+func (t *TokenBucket) HoldSeats(ctx context.Context, eventID, holder string, seats []string, ttl time.Duration) ([]string, error) {
+	start := time.Now()
+	args := make([]interface{}, 0, len(seats)+3)
+	args = append(args, holder, start.UnixMilli(), ttl.Milliseconds())
+	for _, seat := range seats {
+		args = append(args, seat)
+	}
+	taken, err := t.client.Eval(ctx, holdSeatsLua, []string{t.seatHoldsKey(eventID)}, args...).StringSlice()
+	if err != nil {
+		observe("hold_seats", start, "error")
+		return nil, err
+	}
+	if len(taken) > 0 {
+		observe("hold_seats", start, "taken")
+		return taken, nil
+	}
+	observe("hold_seats", start, "success")
+	return nil, nil
+}
+
+// ReleaseSeats gives up holder's holds on seats before they expire.
+func (t *TokenBucket) ReleaseSeats(ctx context.Context, eventID, holder string, seats []string) error {
+	start := time.Now()
+	args := make([]interface{}, 0, len(seats)+1)
+	args = append(args, holder)
+	for _, seat := range seats {
+		args = append(args, seat)
+	}
+	if err := t.client.Eval(ctx, releaseSeatsLua, []string{t.seatHoldsKey(eventID)}, args...).Err(); err != nil {
+		observe("release_seats", start, "error")
+		return err
+	}
+	observe("release_seats", start, "success")
+	return nil
+}
@@ -3,8 +3,11 @@ package redisx
 import (
 	"context"
 	"fmt"
+	"time"
 
 	redis "github.com/redis/go-redis/v9"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/metrics"
 )
 
 const reserveLua = `
@@ -21,7 +24,7 @@ end`
 type TokenBucket struct{ client *redis.Client }
 
 func NewTokenBucket(addr string) *TokenBucket {
-	c := redis.NewClient(&redis.Options{Addr: addr})
+	c := newClient(addr)
 	return &TokenBucket{client: c}
 }
 
@@ -31,25 +34,121 @@ func (t *TokenBucket) InitTokens(ctx context.Context, eventID string, capacity i
 	return t.client.Set(ctx, t.key(eventID), capacity, 0).Err()
 }
 
+// EnsureTokens creates the event's token counter with n tokens if it doesn't exist. It
+// reports whether it created it; an existing counter is left alone.
+func (t *TokenBucket) EnsureTokens(ctx context.Context, eventID string, n int) (bool, error) {
+	return t.client.SetNX(ctx, t.key(eventID), n, 0).Result()
+}
+
+// observe records the latency and outcome of a token operation started at start.
+func observe(op string, start time.Time, outcome string) {
+	metrics.RedisTokenOpDuration.WithLabelValues(op).Observe(time.Since(start).Seconds())
+	metrics.RedisTokenOpsTotal.WithLabelValues(op, outcome).Inc()
+}
+
 func (t *TokenBucket) Reserve(ctx context.Context, eventID string, n int) (bool, error) {
+	start := time.Now()
 	res := t.client.Eval(ctx, reserveLua, []string{t.key(eventID)}, n)
 	if res.Err() != nil {
+		observe("reserve", start, "error")
 		return false, res.Err()
 	}
 	v, _ := res.Int()
-	return v == 1, nil
+	if v != 1 {
+		observe("reserve", start, "insufficient")
+		return false, nil
+	}
+	observe("reserve", start, "success")
+	return true, nil
 }
 
 func (t *TokenBucket) Release(ctx context.Context, eventID string, n int) error {
-	return t.client.IncrBy(ctx, t.key(eventID), int64(n)).Err()
+	start := time.Now()
+	err := t.client.IncrBy(ctx, t.key(eventID), int64(n)).Err()
+	if err != nil {
+		observe("release", start, "error")
+		return err
+	}
+	observe("release", start, "success")
+	return nil
 }
 
 func (t *TokenBucket) Remaining(ctx context.Context, eventID string) (int, error) {
+	start := time.Now()
 	v, err := t.client.Get(ctx, t.key(eventID)).Int()
 	if err == redis.Nil {
+		observe("remaining", start, "success")
 		return 0, nil
 	}
-	return v, err
+	if err != nil {
+		observe("remaining", start, "error")
+		return v, err
+	}
+	observe("remaining", start, "success")
+	return v, nil
+}
+
+// RunTokenGauge publishes the remaining tokens of every event with a token counter to
+// metrics.EventTokensRemaining every interval until ctx is done. Events whose counter
+// disappeared drop out of the gauge on the next sample.
+func (t *TokenBucket) RunTokenGauge(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = t.sampleTokens(ctx)
+		}
+	}
+}
+
+func (t *TokenBucket) sampleTokens(ctx context.Context) error {
+	remaining, err := t.RemainingAll(ctx)
+	if err != nil {
+		return err
+	}
+	metrics.EventTokensRemaining.Reset()
+	for id, n := range remaining {
+		metrics.EventTokensRemaining.WithLabelValues(id).Set(float64(n))
+	}
+	return nil
+}
+
+// RemainingAll returns the remaining tokens of every event with a token counter, keyed by
+// event ID.
+func (t *TokenBucket) RemainingAll(ctx context.Context) (map[string]int, error) {
+	ids, err := t.EventIDsWithTokens(ctx)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = t.key(id)
+	}
+
+	var vals []interface{}
+	if len(keys) > 0 {
+		vals, err = t.client.MGet(ctx, keys...).Result()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	remaining := make(map[string]int, len(vals))
+	for i, v := range vals {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var n int
+		if _, err := fmt.Sscan(s, &n); err == nil {
+			remaining[ids[i]] = n
+		}
+	}
+	return remaining, nil
 }
 
 func (t *TokenBucket) Close() { _ = t.client.Close() }
@@ -0,0 +1,51 @@
+package redisx
+
+import (
+	"context"
+	"net"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/tracing"
+)
+
+// tracingHook records Redis commands and pipelines as spans of the request or message being
+// traced; commands outside a trace are not recorded.
+type tracingHook struct{}
+
+func newClient(addr string) *redis.Client {
+	c := redis.NewClient(&redis.Options{Addr: addr})
+	c.AddHook(tracingHook{})
+	return c
+}
+
+func (tracingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (tracingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		ctx, span := tracing.Child(ctx, "redis "+cmd.Name(),
+			attribute.String("db.system", "redis"),
+			attribute.String("db.operation", cmd.Name()),
+		)
+		err := next(ctx, cmd)
+		tracing.End(span, err, redis.Nil)
+		return err
+	}
+}
+
+func (tracingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		ctx, span := tracing.Child(ctx, "redis pipeline",
+			attribute.String("db.system", "redis"),
+			attribute.Int("db.redis.commands", len(cmds)),
+		)
+		err := next(ctx, cmds)
+		tracing.End(span, err, redis.Nil)
+		return err
+	}
+}
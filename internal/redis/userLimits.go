@@ -0,0 +1,53 @@
+package redisx
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// reserveUserTicketsLua keeps a sorted set of a user's reservations scored by time.
+// Members are "<id>:<tickets>". Entries older than the window are dropped before
+// summing; the reservation is only added if the total stays within the limit.
+// Returns {1, used} on success or {0, used} when the limit would be exceeded.
+const reserveUserTicketsLua = `
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+local n = tonumber(ARGV[4])
+local member = ARGV[5]
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+local used = 0
+for _, m in ipairs(redis.call('ZRANGE', key, 0, -1)) do
+  used = used + tonumber(string.match(m, ':(%d+)$'))
+end
+if used + n > limit then
+  return {0, used}
+end
+redis.call('ZADD', key, now, member)
+redis.call('PEXPIRE', key, window)
+return {1, used + n}`
+
+func (t *TokenBucket) userTicketsKey(scope, userID string) string {
+	return fmt.Sprintf("user_tickets:%s:%s", scope, userID)
+}
+
+func userTicketsMember(id string, n int) string { return fmt.Sprintf("%s:%d", id, n) }
+
+// ReserveUserTickets counts n tickets against userID's rolling limit in scope, identified by id.
+// It returns false without recording anything when the user already holds too many tickets
+// in the trailing window; used is the user's total in the window either way.
+func (t *TokenBucket) ReserveUserTickets(ctx context.Context, scope, userID, id string, n, limit int, window time.Duration) (bool, int, error) {
+	res, err := t.client.Eval(ctx, reserveUserTicketsLua, []string{t.userTicketsKey(scope, userID)},
+		time.Now().UnixMilli(), window.Milliseconds(), limit, n, userTicketsMember(id, n)).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	return res[0] == 1, int(res[1]), nil
+}
+
+// ReleaseUserTickets gives back a reservation made by ReserveUserTickets.
+func (t *TokenBucket) ReleaseUserTickets(ctx context.Context, scope, userID, id string, n int) error {
+	return t.client.ZRem(ctx, t.userTicketsKey(scope, userID), userTicketsMember(id, n)).Err()
+}
@@ -0,0 +1,220 @@
+// Package refunds works out what a booking's refund pays back, seat by seat.
+//
+// Money is handled in the minor unit of the booking's currency of record, the one its
+// payment was taken in (cents, or whole yen for zero-decimal currencies), so no amount is
+// ever a fraction of what the processor can move. What the booking paid is split across its
+// seats in proportion to their list prices, so a booking that changed to dearer or cheaper
+// seats spreads the difference over them, and the booking's cancellation fee is split evenly
+// across its seats. Both splits use the largest remainder method: each seat gets its share
+// rounded down, and the units left over go one each to the seats with the largest
+// remainders, earlier seats first on a tie, so seat shares always add up to the exact total.
+// A seat's fee is at most what it paid, so no seat refunds less than nothing; what a seat
+// can't take is split evenly across the seats that paid more than their share, so the
+// booking keeps its whole fee unless it paid less than that. Taxes included
+// in what the booking paid are split the same way, in proportion to what each seat paid, so
+// every seat line shows how much of its payment was which tax.
+package refunds
+
+import (
+	"errors"
+	"math/big"
+	"slices"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/payments"
+)
+
+// RoundingLargestRemainder names the rounding policy, recorded with every breakdown.
+const RoundingLargestRemainder = "minor_units_largest_remainder"
+
+var (
+	ErrInvalidAmount  = errors.New("amounts can't be negative")
+	ErrUnknownSeat    = errors.New("seat is not part of the booking")
+	ErrSeatPrices     = errors.New("every seat needs a price")
+	ErrTaxExceedsPaid = errors.New("taxes can't be more than the amount paid")
+)
+
+// Tax is a named tax and its amount, included in what a booking or seat paid.
+type Tax struct {
+	Name   string  `json:"name"`
+	Amount float64 `json:"amount"`
+}
+
+// Input is a booking as far as its refund is concerned. Seats are its seats with their list
+// prices; a booking without seat labels counts as a single unlabeled seat.
+type Input struct {
+	Currency   string
+	AmountPaid float64
+	Seats      []string
+	Prices     []float64
+	// CancellationFee is charged once per booking; zero refunds in full
+	CancellationFee float64
+	// Refund names the seats refunded, nil for all of them
+	Refund []string
+	// Taxes are the taxes included in AmountPaid
+	Taxes []Tax
+}
+
+// SeatLine is one refunded seat's part of a refund.
+type SeatLine struct {
+	Label  string  `json:"label,omitempty"`
+	Price  float64 `json:"price"`
+	Paid   float64 `json:"paid"`
+	Fee    float64 `json:"fee"`
+	Refund float64 `json:"refund"`
+	// Taxes are the seat's share of each of the booking's taxes, part of what it paid
+	Taxes []Tax `json:"taxes,omitempty"`
+}
+
+// Breakdown is how a refund was worked out: the refunded seats' share of what the booking
+// paid, less their share of the cancellation fee. Taxes totals the refunded seats' tax lines.
+type Breakdown struct {
+	Currency        string      `json:"currency"`
+	Rounding        string      `json:"rounding"`
+	AmountPaid      float64     `json:"amount_paid"`
+	Paid            float64     `json:"paid"`
+	CancellationFee float64     `json:"cancellation_fee"`
+	Refund          float64     `json:"refund"`
+	Seats           []*SeatLine `json:"seats"`
+	Taxes           []Tax       `json:"taxes,omitempty"`
+}
+
+// Calculate works out the refund of in's seats.
+func Calculate(in Input) (*Breakdown, error) {
+	if in.AmountPaid < 0 || in.CancellationFee < 0 {
+		return nil, ErrInvalidAmount
+	}
+	labels, prices := in.Seats, in.Prices
+	if len(labels) == 0 {
+		labels, prices = []string{""}, []float64{in.AmountPaid}
+	}
+	if len(prices) != len(labels) {
+		return nil, ErrSeatPrices
+	}
+	for _, label := range in.Refund {
+		if !slices.Contains(labels, label) {
+			return nil, ErrUnknownSeat
+		}
+	}
+
+	weights := make([]int64, len(prices))
+	for i, p := range prices {
+		if p < 0 {
+			return nil, ErrInvalidAmount
+		}
+		weights[i] = payments.ToMinor(p, in.Currency)
+	}
+	amountPaid := payments.ToMinor(in.AmountPaid, in.Currency)
+	paid := allocate(amountPaid, weights)
+	fees := capFees(allocate(payments.ToMinor(in.CancellationFee, in.Currency), make([]int64, len(labels))), paid)
+	taxes := make([][]int64, len(in.Taxes))
+	var totalTax int64
+	for k, tax := range in.Taxes {
+		if tax.Amount < 0 {
+			return nil, ErrInvalidAmount
+		}
+		amount := payments.ToMinor(tax.Amount, in.Currency)
+		totalTax += amount
+		taxes[k] = allocate(amount, paid)
+	}
+	if totalTax > amountPaid {
+		return nil, ErrTaxExceedsPaid
+	}
+
+	b := &Breakdown{
+		Currency:   in.Currency,
+		Rounding:   RoundingLargestRemainder,
+		AmountPaid: payments.FromMinor(amountPaid, in.Currency),
+		Seats:      []*SeatLine{},
+	}
+	var totalPaid, totalFee int64
+	taxTotals := make([]int64, len(in.Taxes))
+	for i, label := range labels {
+		if in.Refund != nil && !slices.Contains(in.Refund, label) {
+			continue
+		}
+		fee := fees[i]
+		totalPaid += paid[i]
+		totalFee += fee
+		line := &SeatLine{
+			Label:  label,
+			Price:  payments.FromMinor(weights[i], in.Currency),
+			Paid:   payments.FromMinor(paid[i], in.Currency),
+			Fee:    payments.FromMinor(fee, in.Currency),
+			Refund: payments.FromMinor(paid[i]-fee, in.Currency),
+		}
+		for k, tax := range in.Taxes {
+			taxTotals[k] += taxes[k][i]
+			line.Taxes = append(line.Taxes, Tax{Name: tax.Name, Amount: payments.FromMinor(taxes[k][i], in.Currency)})
+		}
+		b.Seats = append(b.Seats, line)
+	}
+	for k, tax := range in.Taxes {
+		b.Taxes = append(b.Taxes, Tax{Name: tax.Name, Amount: payments.FromMinor(taxTotals[k], in.Currency)})
+	}
+	b.Paid = payments.FromMinor(totalPaid, in.Currency)
+	b.CancellationFee = payments.FromMinor(totalFee, in.Currency)
+	b.Refund = payments.FromMinor(totalPaid-totalFee, in.Currency)
+	return b, nil
+}
+
+// capFees caps each seat's fee at what it paid and splits the excess evenly across the seats
+// with room left, until it is all placed or every seat's fee is what it paid.
+func capFees(fees, paid []int64) []int64 {
+	fees = slices.Clone(fees)
+	for {
+		var excess int64
+		var room []int
+		for i := range fees {
+			if fees[i] > paid[i] {
+				excess += fees[i] - paid[i]
+				fees[i] = paid[i]
+			}
+			if fees[i] < paid[i] {
+				room = append(room, i)
+			}
+		}
+		if excess == 0 || len(room) == 0 {
+			return fees
+		}
+		for j, extra := range allocate(excess, make([]int64, len(room))) {
+			fees[room[j]] += extra
+		}
+	}
+}
+
+// allocate splits total across weights by the largest remainder method. Weights that are all
+// zero split it evenly.
+func allocate(total int64, weights []int64) []int64 {
+	var sum int64
+	for _, w := range weights {
+		sum += w
+	}
+	if sum == 0 {
+		weights = make([]int64, len(weights))
+		for i := range weights {
+			weights[i] = 1
+		}
+		sum = int64(len(weights))
+	}
+
+	shares := make([]int64, len(weights))
+	rems := make([]*big.Int, len(weights))
+	left := total
+	t, d := big.NewInt(total), big.NewInt(sum)
+	for i, w := range weights {
+		// total*w can overflow int64 for large amounts
+		q, r := new(big.Int).QuoRem(new(big.Int).Mul(t, big.NewInt(w)), d, new(big.Int))
+		shares[i], rems[i] = q.Int64(), r
+		left -= shares[i]
+	}
+
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int { return rems[b].Cmp(rems[a]) })
+	for _, i := range order[:left] {
+		shares[i]++
+	}
+	return shares
+}
@@ -0,0 +1,185 @@
+package refunds
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCalculate(t *testing.T) {
+	tests := []struct {
+		name string
+		in   Input
+		want *Breakdown
+	}{
+		{
+			name: "largest remainder ties go to earlier seats",
+			in: Input{Currency: "USD", AmountPaid: 100, Seats: []string{"A1", "A2", "A3"}, Prices: []float64{10, 10, 10},
+				CancellationFee: 10},
+			want: &Breakdown{Currency: "USD", Rounding: RoundingLargestRemainder, AmountPaid: 100, Paid: 100,
+				CancellationFee: 10, Refund: 90, Seats: []*SeatLine{
+					{Label: "A1", Price: 10, Paid: 33.34, Fee: 3.34, Refund: 30},
+					{Label: "A2", Price: 10, Paid: 33.33, Fee: 3.33, Refund: 30},
+					{Label: "A3", Price: 10, Paid: 33.33, Fee: 3.33, Refund: 30},
+				}},
+		},
+		{
+			name: "paid split by list price",
+			in:   Input{Currency: "USD", AmountPaid: 90, Seats: []string{"A1", "B1"}, Prices: []float64{20, 10}},
+			want: &Breakdown{Currency: "USD", Rounding: RoundingLargestRemainder, AmountPaid: 90, Paid: 90,
+				Refund: 90, Seats: []*SeatLine{
+					{Label: "A1", Price: 20, Paid: 60, Refund: 60},
+					{Label: "B1", Price: 10, Paid: 30, Refund: 30},
+				}},
+		},
+		{
+			name: "zero decimal currency splits whole yen",
+			in: Input{Currency: "JPY", AmountPaid: 1000, Seats: []string{"A1", "A2", "A3"}, Prices: []float64{500, 500, 500},
+				CancellationFee: 100},
+			want: &Breakdown{Currency: "JPY", Rounding: RoundingLargestRemainder, AmountPaid: 1000, Paid: 1000,
+				CancellationFee: 100, Refund: 900, Seats: []*SeatLine{
+					{Label: "A1", Price: 500, Paid: 334, Fee: 34, Refund: 300},
+					{Label: "A2", Price: 500, Paid: 333, Fee: 33, Refund: 300},
+					{Label: "A3", Price: 500, Paid: 333, Fee: 33, Refund: 300},
+				}},
+		},
+		{
+			name: "fee equal to the amount paid refunds nothing",
+			in:   Input{Currency: "USD", AmountPaid: 20, Seats: []string{"A1", "A2"}, Prices: []float64{10, 10}, CancellationFee: 20},
+			want: &Breakdown{Currency: "USD", Rounding: RoundingLargestRemainder, AmountPaid: 20, Paid: 20,
+				CancellationFee: 20, Refund: 0, Seats: []*SeatLine{
+					{Label: "A1", Price: 10, Paid: 10, Fee: 10, Refund: 0},
+					{Label: "A2", Price: 10, Paid: 10, Fee: 10, Refund: 0},
+				}},
+		},
+		{
+			name: "fee above the amount paid is capped per seat",
+			in:   Input{Currency: "USD", AmountPaid: 20, Seats: []string{"A1", "A2"}, Prices: []float64{15, 5}, CancellationFee: 50},
+			want: &Breakdown{Currency: "USD", Rounding: RoundingLargestRemainder, AmountPaid: 20, Paid: 20,
+				CancellationFee: 20, Refund: 0, Seats: []*SeatLine{
+					{Label: "A1", Price: 15, Paid: 15, Fee: 15, Refund: 0},
+					{Label: "A2", Price: 5, Paid: 5, Fee: 5, Refund: 0},
+				}},
+		},
+		{
+			name: "fee a seat can't take moves to seats with room",
+			in:   Input{Currency: "USD", AmountPaid: 20, Seats: []string{"A1", "A2"}, Prices: []float64{15, 5}, CancellationFee: 12},
+			want: &Breakdown{Currency: "USD", Rounding: RoundingLargestRemainder, AmountPaid: 20, Paid: 20,
+				CancellationFee: 12, Refund: 8, Seats: []*SeatLine{
+					{Label: "A1", Price: 15, Paid: 15, Fee: 7, Refund: 8},
+					{Label: "A2", Price: 5, Paid: 5, Fee: 5, Refund: 0},
+				}},
+		},
+		{
+			name: "comped seat's fee share is kept on the paid seats",
+			in: Input{Currency: "USD", AmountPaid: 20, Seats: []string{"A1", "A2", "A3"}, Prices: []float64{0, 10, 10},
+				CancellationFee: 9, Refund: []string{"A1", "A2"}},
+			want: &Breakdown{Currency: "USD", Rounding: RoundingLargestRemainder, AmountPaid: 20, Paid: 10,
+				CancellationFee: 4.5, Refund: 5.5, Seats: []*SeatLine{
+					{Label: "A1", Price: 0, Paid: 0, Fee: 0, Refund: 0},
+					{Label: "A2", Price: 10, Paid: 10, Fee: 4.5, Refund: 5.5},
+				}},
+		},
+		{
+			name: "single unlabeled seat",
+			in:   Input{Currency: "USD", AmountPaid: 42.5, CancellationFee: 2.5},
+			want: &Breakdown{Currency: "USD", Rounding: RoundingLargestRemainder, AmountPaid: 42.5, Paid: 42.5,
+				CancellationFee: 2.5, Refund: 40, Seats: []*SeatLine{
+					{Price: 42.5, Paid: 42.5, Fee: 2.5, Refund: 40},
+				}},
+		},
+		{
+			name: "some seats refunded",
+			in: Input{Currency: "USD", AmountPaid: 100, Seats: []string{"A1", "B1", "C1"}, Prices: []float64{50, 30, 20},
+				CancellationFee: 9, Refund: []string{"B1"}},
+			want: &Breakdown{Currency: "USD", Rounding: RoundingLargestRemainder, AmountPaid: 100, Paid: 30,
+				CancellationFee: 3, Refund: 27, Seats: []*SeatLine{
+					{Label: "B1", Price: 30, Paid: 30, Fee: 3, Refund: 27},
+				}},
+		},
+		{
+			name: "taxes split by what each seat paid",
+			in: Input{Currency: "USD", AmountPaid: 100, Seats: []string{"A1", "B1"}, Prices: []float64{60, 40},
+				Taxes: []Tax{{Name: "VAT", Amount: 10}, {Name: "City", Amount: 0.05}}},
+			want: &Breakdown{Currency: "USD", Rounding: RoundingLargestRemainder, AmountPaid: 100, Paid: 100,
+				Refund: 100, Seats: []*SeatLine{
+					{Label: "A1", Price: 60, Paid: 60, Refund: 60, Taxes: []Tax{{Name: "VAT", Amount: 6}, {Name: "City", Amount: 0.03}}},
+					{Label: "B1", Price: 40, Paid: 40, Refund: 40, Taxes: []Tax{{Name: "VAT", Amount: 4}, {Name: "City", Amount: 0.02}}},
+				}, Taxes: []Tax{{Name: "VAT", Amount: 10}, {Name: "City", Amount: 0.05}}},
+		},
+		{
+			name: "taxes of the refunded seats only",
+			in: Input{Currency: "USD", AmountPaid: 30, Seats: []string{"A1", "A2", "A3"}, Prices: []float64{10, 10, 10},
+				Taxes: []Tax{{Name: "VAT", Amount: 0.05}}, Refund: []string{"A1", "A3"}},
+			want: &Breakdown{Currency: "USD", Rounding: RoundingLargestRemainder, AmountPaid: 30, Paid: 20,
+				Refund: 20, Seats: []*SeatLine{
+					{Label: "A1", Price: 10, Paid: 10, Refund: 10, Taxes: []Tax{{Name: "VAT", Amount: 0.02}}},
+					{Label: "A3", Price: 10, Paid: 10, Refund: 10, Taxes: []Tax{{Name: "VAT", Amount: 0.01}}},
+				}, Taxes: []Tax{{Name: "VAT", Amount: 0.03}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Calculate(tt.in)
+			if err != nil {
+				t.Fatalf("Calculate: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Calculate() = %s, want %s", describe(got), describe(tt.want))
+			}
+		})
+	}
+}
+
+func TestCalculateErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   Input
+		want error
+	}{
+		{"negative amount paid", Input{Currency: "USD", AmountPaid: -1}, ErrInvalidAmount},
+		{"negative fee", Input{Currency: "USD", AmountPaid: 10, CancellationFee: -1}, ErrInvalidAmount},
+		{"negative price", Input{Currency: "USD", AmountPaid: 10, Seats: []string{"A1"}, Prices: []float64{-10}}, ErrInvalidAmount},
+		{"missing prices", Input{Currency: "USD", AmountPaid: 10, Seats: []string{"A1", "A2"}, Prices: []float64{10}}, ErrSeatPrices},
+		{"unknown seat", Input{Currency: "USD", AmountPaid: 10, Seats: []string{"A1"}, Prices: []float64{10}, Refund: []string{"B1"}}, ErrUnknownSeat},
+		{"negative tax", Input{Currency: "USD", AmountPaid: 10, Taxes: []Tax{{Name: "VAT", Amount: -1}}}, ErrInvalidAmount},
+		{"taxes above the amount paid", Input{Currency: "USD", AmountPaid: 10, Taxes: []Tax{{Name: "VAT", Amount: 6}, {Name: "City", Amount: 5}}}, ErrTaxExceedsPaid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Calculate(tt.in); !errors.Is(err, tt.want) {
+				t.Errorf("Calculate() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		name    string
+		total   int64
+		weights []int64
+		want    []int64
+	}{
+		{"exact", 100, []int64{1, 3}, []int64{25, 75}},
+		{"tie goes to the earlier share", 10, []int64{1, 1, 1}, []int64{4, 3, 3}},
+		{"largest remainder first", 1, []int64{1, 2}, []int64{0, 1}},
+		{"zero weights split evenly", 5, []int64{0, 0}, []int64{3, 2}},
+		{"nothing to split", 0, []int64{2, 1}, []int64{0, 0}},
+		{"large amounts don't overflow", 1 << 62, []int64{1 << 40, 1 << 40}, []int64{1 << 61, 1 << 61}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := allocate(tt.total, tt.weights); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("allocate(%d, %v) = %v, want %v", tt.total, tt.weights, got, tt.want)
+			}
+		})
+	}
+}
+
+// describe prints a breakdown with its seat lines, which %v would show as pointers.
+func describe(b *Breakdown) string {
+	out, _ := json.Marshal(b)
+	return string(out)
+}
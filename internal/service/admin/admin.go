@@ -4,52 +4,227 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"time"
 
 	"go.uber.org/zap"
 
+	"github.com/samirwankhede/lewly-pgpyewj/internal/domain"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/lock"
+	jwtMiddleware "github.com/samirwankhede/lewly-pgpyewj/internal/middleware"
 	redisx "github.com/samirwankhede/lewly-pgpyewj/internal/redis"
+	eventsService "github.com/samirwankhede/lewly-pgpyewj/internal/service/events"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/service/fx"
+	jobService "github.com/samirwankhede/lewly-pgpyewj/internal/service/jobs"
 	mailer "github.com/samirwankhede/lewly-pgpyewj/internal/service/mailer"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/service/organizers"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/service/simulation"
+	waitlistService "github.com/samirwankhede/lewly-pgpyewj/internal/service/waitlist"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/store/admin"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/store/bookings"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/store/events"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/invitations"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/jobs"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/members"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/notifications"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/prewarms"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/store/seats"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/snapshots"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/store/users"
 )
 
+var (
+	ErrEventNotFound         = errors.New("event not found")
+	ErrDuplicateEvent        = errors.New("an event with the same name, venue and start time already exists")
+	ErrInvalidVisibility     = errors.New("visibility must be public, unlisted or private")
+	ErrInvalidPaymentCapture = errors.New("payment_capture must be immediate or manual")
+	ErrSandboxPublic         = errors.New("sandbox events can't be public")
+)
+
 type AdminService struct {
-	log      *zap.Logger
-	events   *events.EventsRepository
-	users    *users.UsersRepository
-	bookings *bookings.BookingsRepository
-	admin    *admin.AdminRepository
-	seats    *seats.SeatsRepository
-	tokens   *redisx.TokenBucket
-	mailer   *mailer.MailerService
+	log        *zap.Logger
+	events     *events.EventsRepository
+	users      *users.UsersRepository
+	bookings   *bookings.BookingsRepository
+	admin      *admin.AdminRepository
+	seats      *seats.SeatsRepository
+	tokens     *redisx.TokenBucket
+	mailer     *mailer.MailerService
+	organizers *organizers.OrganizersService
+	snapshots  *snapshots.SnapshotsRepository
+	jobs       *jobService.Runner
+	compare    compareCache
+	// invitations is nil unless private event invitations are enabled
+	invitations   *invitations.InvitationsRepository
+	inviteBaseURL string
+	// notifications is nil unless broadcasts go through the dispatcher
+	notifications *notifications.NotificationsRepository
+	// duplicateCheck rejects events matching an existing name, venue and start time
+	duplicateCheck bool
+	publishHooks   []PublishHook
+	cancelHooks    []CancelHook
+	locks          *lock.Locker
+	// promoter is nil unless capacity increases offer new seats to the waitlist
+	promoter *waitlistService.Promoter
+	prewarms *prewarms.PrewarmsRepository
+	phases   *eventsService.SalePhases
+	brands   *mailer.Brands
+	// members is nil unless owners can invite co-organizers
+	members       *members.MembersRepository
+	memberBaseURL string
+}
+
+// PublishHook is told about every newly created event, e.g. to match it against users'
+// subscriptions. Hooks run in the background after the admin request returns and handle
+// their own errors.
+type PublishHook func(ctx context.Context, e *events.Event)
+
+// OnPublish registers hook to run for every event created from now on.
+func (a *AdminService) OnPublish(hook PublishHook) *AdminService {
+	a.publishHooks = append(a.publishHooks, hook)
+	return a
 }
 
-func NewAdminService(log *zap.Logger, events *events.EventsRepository, users *users.UsersRepository, bookings *bookings.BookingsRepository, admin *admin.AdminRepository, seats *seats.SeatsRepository, tokens *redisx.TokenBucket, mailer *mailer.MailerService) *AdminService {
-	return &AdminService{log: log, events: events, users: users, bookings: bookings, admin: admin, seats: seats, tokens: tokens, mailer: mailer}
+// CancelHook is told about every event an admin cancels, once its bookings are cancelled
+// too. Like publish hooks, cancel hooks run in the background and handle their own errors.
+type CancelHook func(ctx context.Context, e *events.Event)
+
+// OnCancel registers hook to run for every event cancelled from now on.
+func (a *AdminService) OnCancel(hook CancelHook) *AdminService {
+	a.cancelHooks = append(a.cancelHooks, hook)
+	return a
+}
+
+func NewAdminService(log *zap.Logger, events *events.EventsRepository, users *users.UsersRepository, bookings *bookings.BookingsRepository, admin *admin.AdminRepository, seats *seats.SeatsRepository, tokens *redisx.TokenBucket, mailer *mailer.MailerService, organizers *organizers.OrganizersService, snapshots *snapshots.SnapshotsRepository, jobs *jobService.Runner, duplicateCheck bool) *AdminService {
+	return &AdminService{log: log, events: events, users: users, bookings: bookings, admin: admin, seats: seats, tokens: tokens, mailer: mailer, organizers: organizers, snapshots: snapshots, jobs: jobs, duplicateCheck: duplicateCheck, phases: eventsService.NewSalePhases(log, events, tokens)}
 }
 
 type AdminEvent struct {
-	Name                     string          `json:"name" binding:"required"`
-	Venue                    string          `json:"venue" binding:"required"`
-	Category                 string          `json:"category"`
-	StartTime                time.Time       `json:"start_time" binding:"required"`
-	EndTime                  time.Time       `json:"end_time" binding:"required"`
-	Capacity                 int             `json:"capacity" binding:"required"`
-	Metadata                 json.RawMessage `json:"metadata"`
-	TicketPrice              float64         `json:"ticket_price"`
-	CancellationFee          float64         `json:"cancellation_fee"`
-	MaximumTicketsPerBooking int             `json:"maximum_tickets_per_booking"`
-	Seats                    []string        `json:"seats" binding:"required"`
+	Name            string          `json:"name" binding:"required"`
+	Venue           string          `json:"venue" binding:"required"`
+	Category        string          `json:"category"`
+	StartTime       time.Time       `json:"start_time" binding:"required"`
+	EndTime         time.Time       `json:"end_time" binding:"required"`
+	Capacity        int             `json:"capacity" binding:"omitempty,gt=0"`
+	Metadata        json.RawMessage `json:"metadata"`
+	TicketPrice     float64         `json:"ticket_price"`
+	CancellationFee float64         `json:"cancellation_fee"`
+	// Currency is the ISO 4217 code prices are charged in, USD when omitted
+	Currency                 string   `json:"currency"`
+	MaximumTicketsPerBooking int      `json:"maximum_tickets_per_booking"`
+	Seats                    []string `json:"seats"`
+	// SeatLayout generates Seats server-side, and Sections a map of named blocks each sold at
+	// its own price tier; send one of Seats, SeatLayout or Sections
+	SeatLayout *SeatLayout   `json:"seat_layout"`
+	Sections   []SeatSection `json:"sections"`
+	// PriceTiers are the prices Sections sell at instead of TicketPrice
+	PriceTiers            []events.PriceTier `json:"price_tiers"`
+	OrganizerID           *string            `json:"organizer_id"`
+	MaxTicketsPerUser     *int               `json:"max_tickets_per_user" binding:"omitempty,gt=0"`
+	UserTicketWindowHours *int               `json:"user_ticket_window_hours" binding:"omitempty,gt=0"`
+	Latitude              *float64           `json:"latitude" binding:"omitempty,min=-90,max=90"`
+	Longitude             *float64           `json:"longitude" binding:"omitempty,min=-180,max=180"`
+	// Feature toggles default to on when omitted
+	WaitlistEnabled      *bool `json:"waitlist_enabled"`
+	SeatSelectionEnabled *bool `json:"seat_selection_enabled"`
+	LikesEnabled         *bool `json:"likes_enabled"`
+	// NoSingleSeat (off by default) stops bookings from leaving a lone empty seat in a row
+	NoSingleSeat bool `json:"no_single_seat"`
+	// PaymentCapture is immediate (the default) or manual: cards are only authorized at booking
+	// and charged when the organizer confirms, or automatically at CaptureAt (the start time
+	// when unset)
+	PaymentCapture string     `json:"payment_capture"`
+	CaptureAt      *time.Time `json:"capture_at"`
+	// Visibility is public (the default), unlisted or private; only invitees can book private events
+	Visibility string `json:"visibility"`
+	// Sandbox makes a rehearsal event: unlisted unless private, left out of analytics, and
+	// only bookable by admins and its testers
+	Sandbox bool `json:"sandbox"`
+	// AllowDuplicate skips the name + venue + start time duplicate check
+	AllowDuplicate bool `json:"allow_duplicate"`
+	// OwnerID is set for events organizers create through CreateOwnedEvent
+	OwnerID *string `json:"-"`
+}
+
+// countSet counts how many of the alternatives were given.
+func countSet(given ...bool) int {
+	n := 0
+	for _, g := range given {
+		if g {
+			n++
+		}
+	}
+	return n
 }
 
+// enabled resolves an optional feature toggle, which is on unless explicitly turned off.
+func enabled(toggle *bool) bool { return toggle == nil || *toggle }
+
+// CreateEvent creates the event, its seat map and its token bucket. Unless duplicate checking
+// is off or in.AllowDuplicate is set, an event matching an existing one's name, venue and
+// start time is not created and the existing event is returned with ErrDuplicateEvent.
 func (a *AdminService) CreateEvent(ctx context.Context, in AdminEvent) (*events.Event, error) {
-	// Validate seats array size matches capacity
-	if len(in.Seats) != in.Capacity {
-		return nil, errors.New("seats array size must match event capacity")
+	currency := "USD"
+	if in.Currency != "" {
+		code, err := fx.Normalize(in.Currency)
+		if err != nil {
+			return nil, err
+		}
+		currency = code
+	}
+	visibility := events.VisibilityPublic
+	if in.Sandbox {
+		visibility = events.VisibilityUnlisted
+	}
+	if in.Visibility != "" {
+		if !events.ValidVisibility(in.Visibility) {
+			return nil, ErrInvalidVisibility
+		}
+		visibility = in.Visibility
+	}
+	if in.Sandbox && visibility == events.VisibilityPublic {
+		return nil, ErrSandboxPublic
+	}
+	capture := events.CaptureImmediate
+	if in.PaymentCapture != "" {
+		if !events.ValidCapture(in.PaymentCapture) {
+			return nil, ErrInvalidPaymentCapture
+		}
+		capture = in.PaymentCapture
+	}
+	if err := validatePriceTiers(in.PriceTiers); err != nil {
+		return nil, err
+	}
+	var seatMap []seats.NewSeat
+	switch {
+	case countSet(len(in.Seats) > 0, in.SeatLayout != nil, len(in.Sections) > 0) > 1:
+		return nil, fmt.Errorf("%w: send one of seats, seat_layout or sections", ErrInvalidSeats)
+	case len(in.Sections) > 0:
+		s, err := sectionSeats(in.Sections, in.PriceTiers)
+		if err != nil {
+			return nil, err
+		}
+		seatMap = s
+	case in.SeatLayout != nil:
+		s, err := in.SeatLayout.seats("", "")
+		if err != nil {
+			return nil, err
+		}
+		seatMap = s
+	default:
+		for _, label := range in.Seats {
+			seatMap = append(seatMap, seats.NewSeat{Label: label})
+		}
+	}
+	if err := validateSeatLabels(seatLabels(seatMap)); err != nil {
+		return nil, err
+	}
+	// Capacity defaults to the size of the seat map
+	if in.Capacity == 0 {
+		in.Capacity = len(seatMap)
+	}
+	if len(seatMap) != in.Capacity {
+		return nil, fmt.Errorf("%w: %d seats for a capacity of %d", ErrInvalidSeats, len(seatMap), in.Capacity)
 	}
 
 	e := &events.Event{
@@ -60,18 +235,46 @@ func (a *AdminService) CreateEvent(ctx context.Context, in AdminEvent) (*events.
 		EndTime:                  in.EndTime,
 		Capacity:                 in.Capacity,
 		Metadata:                 in.Metadata,
-		Status:                   "upcoming",
+		Status:                   domain.EventUpcoming,
 		TicketPrice:              in.TicketPrice,
 		CancellationFee:          in.CancellationFee,
 		MaximumTicketsPerBooking: in.MaximumTicketsPerBooking,
+		OrganizerID:              in.OrganizerID,
+		MaxTicketsPerUser:        in.MaxTicketsPerUser,
+		UserTicketWindowHours:    in.UserTicketWindowHours,
+		Latitude:                 in.Latitude,
+		Longitude:                in.Longitude,
+		WaitlistEnabled:          enabled(in.WaitlistEnabled),
+		SeatSelectionEnabled:     enabled(in.SeatSelectionEnabled),
+		LikesEnabled:             enabled(in.LikesEnabled),
+		NoSingleSeat:             in.NoSingleSeat,
+		PaymentCapture:           capture,
+		CaptureAt:                in.CaptureAt,
+		Currency:                 currency,
+		Visibility:               visibility,
+		Sandbox:                  in.Sandbox,
+		OwnerID:                  in.OwnerID,
 	}
-	e, err := a.events.Create(ctx, e)
-	if err != nil {
+	// The duplicate check and the insert are one transaction, so concurrent double submits
+	// can't both pass it
+	if a.duplicateCheck && !in.AllowDuplicate {
+		dupID, err := a.events.CreateUnlessDuplicate(ctx, e)
+		if err != nil {
+			return nil, err
+		}
+		if dupID != "" {
+			dup, err := a.events.Get(ctx, dupID)
+			if err != nil {
+				return nil, err
+			}
+			return dup, ErrDuplicateEvent
+		}
+	} else if _, err := a.events.Create(ctx, e); err != nil {
 		return nil, err
 	}
 
 	// Create seats in the seats table
-	err = a.seats.CreateSeats(ctx, e.ID, in.Seats)
+	err := a.seats.CreateSeats(ctx, e.ID, seatMap, in.PriceTiers)
 	if err != nil {
 		a.log.Error("Failed to create seats", zap.Error(err), zap.String("event_id", e.ID))
 		// Note: We don't return error here as the event is already created
@@ -79,6 +282,59 @@ func (a *AdminService) CreateEvent(ctx context.Context, in AdminEvent) (*events.
 	}
 
 	_ = a.tokens.InitTokens(ctx, e.ID, e.Capacity)
+	a.invalidateEvent(ctx, e.ID)
+
+	// Nobody outside the rehearsal hears about a sandbox event
+	if e.Sandbox {
+		a.log.Info("Sandbox event created", zap.String("event_id", e.ID))
+		return e, nil
+	}
+	// Tell the organizer's followers without holding up the admin request
+	if e.OrganizerID != nil {
+		go a.organizers.NotifyFollowers(context.Background(), e)
+	}
+	for _, hook := range a.publishHooks {
+		go hook(context.Background(), e)
+	}
+	return e, nil
+}
+
+// CloneEventRequest copies an event template to another organizer, e.g. to seed demo events.
+type CloneEventRequest struct {
+	OrganizerID string     `json:"organizer_id" binding:"required"`
+	Name        string     `json:"name"`
+	StartTime   *time.Time `json:"start_time"`
+	EndTime     *time.Time `json:"end_time"`
+}
+
+// CloneEvent copies the source event's configuration and seat map under the target organizer
+// and initializes its tokens. Nothing user-generated is carried over.
+func (a *AdminService) CloneEvent(ctx context.Context, sourceID string, in CloneEventRequest) (*events.Event, error) {
+	if _, err := a.organizers.Get(ctx, in.OrganizerID); err != nil {
+		return nil, err
+	}
+
+	newID, err := a.admin.CloneEvent(ctx, sourceID, admin.CloneRequest{
+		OrganizerID: in.OrganizerID,
+		Name:        in.Name,
+		StartTime:   in.StartTime,
+		EndTime:     in.EndTime,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if newID == "" {
+		return nil, ErrEventNotFound
+	}
+
+	e, err := a.events.Get(ctx, newID)
+	if err != nil {
+		return nil, err
+	}
+	_ = a.tokens.InitTokens(ctx, e.ID, e.Capacity)
+	a.invalidateEvent(ctx, e.ID)
+
+	a.log.Info("Event cloned", zap.String("source_event_id", sourceID), zap.String("event_id", e.ID), zap.String("organizer_id", in.OrganizerID))
 	return e, nil
 }
 
@@ -86,49 +342,277 @@ func (a *AdminService) GetSummary(ctx context.Context, from, to time.Time) (*adm
 	return a.admin.GetSummary(ctx, from, to)
 }
 
-func (a *AdminService) CancelEvent(ctx context.Context, eventID string) error {
-	// Get event details for email notifications
+// ExportBookings streams every booking of the event to fn, oldest first.
+func (a *AdminService) ExportBookings(ctx context.Context, eventID string, fn func(*admin.ExportedBooking) error) error {
 	event, err := a.events.Get(ctx, eventID)
 	if err != nil {
 		return err
 	}
 	if event == nil {
-		return errors.New("event not found")
+		return ErrEventNotFound
 	}
+	return a.admin.ExportBookings(ctx, eventID, fn)
+}
+
+// ListInventorySnapshots returns the recorded inventory history of an event between from and to.
+func (a *AdminService) ListInventorySnapshots(ctx context.Context, eventID string, from, to time.Time) ([]*snapshots.Snapshot, error) {
+	return a.snapshots.ListByEvent(ctx, eventID, from, to)
+}
 
-	// Cancel the event
-	err = a.admin.CancelEvent(ctx, eventID)
+// SimulateOnSale runs a what-if on-sale against a copy of the event's configuration.
+// Capacity and per-booking limit come from the event unless params override them.
+func (a *AdminService) SimulateOnSale(ctx context.Context, eventID string, params simulation.Params) (*simulation.Result, error) {
+	event, err := a.events.Get(ctx, eventID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if event == nil {
+		return nil, ErrEventNotFound
+	}
+	if params.Capacity == 0 {
+		params.Capacity = event.Capacity
+	}
+	if params.MaximumTicketsPerBooking == 0 {
+		params.MaximumTicketsPerBooking = event.MaximumTicketsPerBooking
 	}
+	return simulation.Run(params)
+}
+
+// TokenResync reports a token bucket before and after it was reset from Postgres, and the
+// buckets of the event's sale phases.
+type TokenResync struct {
+	EventID string                       `json:"event_id"`
+	Before  int                          `json:"before"`
+	After   int                          `json:"after"`
+	Phases  []*eventsService.PhaseResync `json:"phases,omitempty"`
+}
 
-	bookings, err := a.bookings.ListByEvent(ctx, eventID, 1000, 0) // Get all bookings
+// ResyncTokens resets the event's Redis token bucket, and those of its sale phases, to what
+// its bookings say they should hold, for when a bucket drifted (e.g. a crash between reserve
+// and release).
+func (a *AdminService) ResyncTokens(ctx context.Context, eventID string) (*TokenResync, error) {
+	event, err := a.events.Get(ctx, eventID)
 	if err != nil {
+		return nil, err
+	}
+	if event == nil {
+		return nil, ErrEventNotFound
+	}
+	// A cancellation between reading Postgres and writing the bucket would be counted twice
+	var before, after int
+	var phases []*eventsService.PhaseResync
+	err = a.locks.Event(ctx, "resync", eventID, func(ctx context.Context) error {
+		var err error
+		if before, err = a.tokens.Remaining(ctx, eventID); err != nil {
+			return err
+		}
+		if after, err = a.events.ExpectedTokens(ctx, eventID); err != nil {
+			return err
+		}
+		if err := a.tokens.InitTokens(ctx, eventID, after); err != nil {
+			return err
+		}
+		phases, err = a.phases.Resync(ctx, eventID)
 		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	a.log.Info("Resynced event tokens", zap.String("event_id", eventID), zap.Int("before", before), zap.Int("after", after), zap.Int("phases", len(phases)))
+	return &TokenResync{EventID: eventID, Before: before, After: after, Phases: phases}, nil
+}
+
+// broadcastPollInterval is how often a cancellation job checks on its email broadcast.
+const broadcastPollInterval = 2 * time.Second
+
+// CancellationResult is the outcome of an event cancellation job.
+type CancellationResult struct {
+	Notified            int     `json:"notified"`
+	NotificationBatchID *string `json:"notification_batch_id,omitempty"`
+}
+
+// CancelEvent cancels the event in a background job: the event and its bookings are
+// cancelled, its Redis keys released and every paid attendee emailed. The job's total is
+// the attendees and it completes once their emails are sent, counting each sent or failed.
+func (a *AdminService) CancelEvent(ctx context.Context, eventID string) (*jobs.Job, error) {
+	// Get event details for email notifications
+	event, err := a.events.Get(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	if event == nil {
+		return nil, ErrEventNotFound
+	}
+	return a.jobs.Start(ctx, "event_cancellation", &eventID, func(ctx context.Context, p *jobService.Progress) (any, error) {
+		return a.cancelEvent(ctx, event, p)
+	})
+}
+
+func (a *AdminService) cancelEvent(ctx context.Context, event *events.Event, p *jobService.Progress) (*CancellationResult, error) {
+	if err := a.admin.CancelEvent(ctx, event.ID); err != nil {
+		return nil, err
+	}
+	a.invalidateEvent(ctx, event.ID)
+	for _, hook := range a.cancelHooks {
+		go hook(context.Background(), event)
+	}
+
+	// No further bookings are possible, so drop the event's tokens and timeout markers
+	if _, err := a.tokens.ReleaseEventKeys(ctx, event.ID); err != nil {
+		a.log.Error("Failed to release Redis keys for cancelled event", zap.Error(err), zap.String("event_id", event.ID))
+	}
+
+	emails, err := a.admin.PaidAttendeeEmails(ctx, event.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.SetTotal(ctx, len(emails)); err != nil {
+		return nil, err
+	}
+	batch, err := a.mailer.For(event.OrganizerID).BroadcastEventCancellation(ctx, event.ID, event.Name, event.TicketPrice, emails)
+	if err != nil {
+		return nil, err
+	}
+	a.log.Info("Event cancelled", zap.String("event_id", event.ID), zap.String("event_name", event.Name), zap.Int("notified", len(emails)))
+
+	res := &CancellationResult{Notified: len(emails)}
+	if batch == nil {
+		// Sent inline, or nobody to send to
+		p.Add(ctx, len(emails), 0)
+		return res, nil
+	}
+	res.NotificationBatchID = &batch.ID
+	if a.notifications == nil {
+		return res, nil
+	}
+	return res, a.awaitBroadcast(ctx, batch.ID, p)
+}
+
+// awaitBroadcast mirrors a broadcast's sent and failed counts into the job until it's done.
+func (a *AdminService) awaitBroadcast(ctx context.Context, batchID string, p *jobService.Progress) error {
+	var sent, failed int
+	ticker := time.NewTicker(broadcastPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		b, err := a.notifications.Get(ctx, batchID)
+		if err != nil {
+			a.log.Warn("Failed to check notification batch", zap.Error(err), zap.String("batch_id", batchID))
+			continue
+		}
+		if b == nil {
+			return fmt.Errorf("notification batch %s disappeared", batchID)
+		}
+		p.Add(ctx, b.Sent-sent, b.Failed-failed)
+		sent, failed = b.Sent, b.Failed
+		if b.Status == notifications.StatusDone {
+			return nil
+		}
 	}
-	for _, booking := range bookings {
-		if booking.PaymentStatus == "paid" {
-			user, err := a.users.GetByID(ctx, booking.UserID)
+}
+
+// Jobs lists background admin jobs newest first, only those of kind and in state when set.
+func (a *AdminService) Jobs(ctx context.Context, kind, state string, limit, offset int) ([]*jobs.Job, error) {
+	return a.jobs.List(ctx, kind, state, limit, offset)
+}
+
+// Job returns a background job's state and progress, or nil if there is none.
+func (a *AdminService) Job(ctx context.Context, id string) (*jobs.Job, error) {
+	return a.jobs.Get(ctx, id)
+}
+
+// WithNotifications lets admins follow broadcast progress.
+func (a *AdminService) WithNotifications(repo *notifications.NotificationsRepository) *AdminService {
+	a.notifications = repo
+	return a
+}
+
+// WithEventLocks keeps token resyncs from racing cancellations and payment timeouts of the
+// same event.
+func (a *AdminService) WithEventLocks(locks *lock.Locker) *AdminService {
+	a.locks = locks
+	return a
+}
+
+// NotificationBatches lists broadcasts, newest first.
+func (a *AdminService) NotificationBatches(ctx context.Context, limit, offset int) ([]*notifications.Batch, error) {
+	if a.notifications == nil {
+		return []*notifications.Batch{}, nil
+	}
+	return a.notifications.List(ctx, limit, offset)
+}
+
+// NotificationBatch returns one broadcast's progress, or nil if there is none.
+func (a *AdminService) NotificationBatch(ctx context.Context, id string) (*notifications.Batch, error) {
+	if a.notifications == nil {
+		return nil, nil
+	}
+	return a.notifications.Get(ctx, id)
+}
+
+func (a *AdminService) UpdateEvent(ctx context.Context, eventID string, updates map[string]interface{}) error {
+	if v, ok := updates["visibility"]; ok {
+		s, _ := v.(string)
+		if !events.ValidVisibility(s) {
+			return ErrInvalidVisibility
+		}
+		if s == events.VisibilityPublic {
+			e, err := a.events.Get(ctx, eventID)
 			if err != nil {
-				a.log.Error("User not found", zap.String("user_id", booking.UserID))
+				return err
 			}
-			a.mailer.SendEventCancellationEmail(user.Email, event.Name, event.TicketPrice)
+			if e != nil && e.Sandbox {
+				return ErrSandboxPublic
+			}
+		}
+	}
+	if v, ok := updates["payment_capture"]; ok {
+		if s, _ := v.(string); !events.ValidCapture(s) {
+			return ErrInvalidPaymentCapture
 		}
 	}
-	a.log.Info("Event cancelled", zap.String("event_id", eventID), zap.String("event_name", event.Name))
+	if err := a.admin.UpdateEvent(ctx, eventID, updates); err != nil {
+		return err
+	}
+	a.invalidateEvent(ctx, eventID)
 	return nil
 }
 
-func (a *AdminService) UpdateEvent(ctx context.Context, eventID string, updates map[string]interface{}) error {
-	return a.admin.UpdateEvent(ctx, eventID, updates)
+// invalidateEvent retires the cached copies of an event that was just changed, along with
+// the cached listings. It is logged rather than returned since the change itself went
+// through; readers see it once the cache expires.
+func (a *AdminService) invalidateEvent(ctx context.Context, eventID string) {
+	if err := a.tokens.InvalidateEvent(ctx, eventID); err != nil {
+		a.log.Error("Failed to invalidate event cache", zap.Error(err), zap.String("event_id", eventID))
+	}
 }
 
 func (a *AdminService) CreateAdminFromUser(ctx context.Context, userID string) error {
-	return a.admin.CreateAdminFromUser(ctx, userID)
+	if err := a.admin.CreateAdminFromUser(ctx, userID); err != nil {
+		return err
+	}
+	a.announceRoleChange(ctx, userID)
+	return nil
 }
 
 func (a *AdminService) RemoveAdmin(ctx context.Context, userID string) error {
-	return a.admin.RemoveAdmin(ctx, userID)
+	if err := a.admin.RemoveAdmin(ctx, userID); err != nil {
+		return err
+	}
+	a.announceRoleChange(ctx, userID)
+	return nil
+}
+
+// announceRoleChange evicts the user from every instance's role cache. A failed publish is
+// only logged: the bumped role_version already invalidates old tokens once the cache TTL lapses.
+func (a *AdminService) announceRoleChange(ctx context.Context, userID string) {
+	if err := jwtMiddleware.PublishRoleChange(ctx, a.tokens.GetClient(), userID); err != nil {
+		a.log.Error("Failed to publish role change", zap.Error(err), zap.String("user_id", userID))
+	}
 }
 
 func (a *AdminService) RemoveUser(ctx context.Context, userID string) error {
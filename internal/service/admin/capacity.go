@@ -0,0 +1,152 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	waitlistService "github.com/samirwankhede/lewly-pgpyewj/internal/service/waitlist"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/admin"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/seats"
+)
+
+var (
+	ErrCapacityEventClosed = errors.New("capacity can only be added to upcoming or ongoing events")
+	ErrSeatsExist          = errors.New("some of the seats are already on the event's seat map")
+)
+
+// CapacityRequest adds seats to an event: Seats labels them one by one, SeatLayout generates
+// them; send one. Section and Tier apply to every new seat, and Tier must be one of the
+// event's price tiers.
+type CapacityRequest struct {
+	Seats      []string    `json:"seats"`
+	SeatLayout *SeatLayout `json:"seat_layout"`
+	Section    string      `json:"section"`
+	Tier       string      `json:"tier"`
+}
+
+// WithPromoter lets capacity increases offer their new seats to the waitlist.
+func (a *AdminService) WithPromoter(promoter *waitlistService.Promoter) *AdminService {
+	a.promoter = promoter
+	return a
+}
+
+// IncreaseCapacity adds seats to an event that is on sale. Under the event lock, so no
+// cancellation, payment timeout or token resync of the event interleaves, one transaction
+// adds the seats and raises the capacity, the new seats are offered to the waitlist one per
+// user, and whatever nobody was waiting for is added to the Redis token bucket. Buyers
+// either see the old capacity or the new one. With ErrSeatsExist nothing is added and the
+// increase lists the labels already on the seat map.
+func (a *AdminService) IncreaseCapacity(ctx context.Context, eventID string, in CapacityRequest, adminID string) (*admin.CapacityIncrease, error) {
+	event, err := a.events.Get(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	if event == nil {
+		return nil, ErrEventNotFound
+	}
+	if event.Status.Over() {
+		return nil, ErrCapacityEventClosed
+	}
+
+	var seatMap []seats.NewSeat
+	switch {
+	case countSet(len(in.Seats) > 0, in.SeatLayout != nil) != 1:
+		return nil, fmt.Errorf("%w: send one of seats or seat_layout", ErrInvalidSeats)
+	case in.SeatLayout != nil:
+		s, err := in.SeatLayout.seats(in.Section, in.Tier)
+		if err != nil {
+			return nil, err
+		}
+		seatMap = s
+	default:
+		for _, label := range in.Seats {
+			seatMap = append(seatMap, seats.NewSeat{Label: label, Section: in.Section, Tier: in.Tier})
+		}
+	}
+	if err := validateSeatLabels(seatLabels(seatMap)); err != nil {
+		return nil, err
+	}
+	if event.Capacity+len(seatMap) > MaxSeatsPerEvent {
+		return nil, fmt.Errorf("%w: %d seats, at most %d allowed", ErrInvalidSeats, event.Capacity+len(seatMap), MaxSeatsPerEvent)
+	}
+	if in.Tier != "" {
+		tiers, err := a.events.PriceTiers(ctx, eventID)
+		if err != nil {
+			return nil, err
+		}
+		known := false
+		for _, t := range tiers {
+			known = known || t.Name == in.Tier
+		}
+		if !known {
+			return nil, fmt.Errorf("%w: tier %q is not one of the event's price tiers", ErrInvalidSeats, in.Tier)
+		}
+	}
+	var by *string
+	if adminID != "" {
+		by = &adminID
+	}
+
+	var inc *admin.CapacityIncrease
+	err = a.locks.Event(ctx, "capacity", eventID, func(ctx context.Context) error {
+		var err error
+		inc, err = a.admin.IncreaseCapacity(ctx, eventID, seatMap, by)
+		if err != nil {
+			return err
+		}
+		if inc == nil {
+			return ErrEventNotFound
+		}
+		if len(inc.ExistingSeats) > 0 {
+			return ErrSeatsExist
+		}
+		a.invalidateEvent(ctx, eventID)
+		a.releaseCapacity(ctx, inc)
+		return nil
+	})
+	if err != nil {
+		if err == ErrSeatsExist {
+			return inc, err
+		}
+		return nil, err
+	}
+	a.log.Info("Increased event capacity",
+		zap.String("event_id", eventID),
+		zap.Int("previous_capacity", inc.PreviousCapacity),
+		zap.Int("new_capacity", inc.NewCapacity),
+		zap.Int("waitlist_offers", inc.WaitlistOffers),
+		zap.Int("tokens_released", inc.TokensReleased))
+	return inc, nil
+}
+
+// releaseCapacity offers the increase's seats to the waitlist and puts the rest on sale.
+// The seats are already committed, so failures are logged rather than returned: a bucket
+// left short is fixed by a token resync.
+func (a *AdminService) releaseCapacity(ctx context.Context, inc *admin.CapacityIncrease) {
+	rest := inc.Seats
+	if a.promoter != nil {
+		promos, left, err := a.promoter.OfferCapacity(ctx, inc.EventID, inc.ID, inc.Seats)
+		if err != nil {
+			a.log.Error("Failed to offer new seats to waitlist", zap.Error(err), zap.String("event_id", inc.EventID))
+		}
+		inc.WaitlistOffers, rest = len(promos), left
+	}
+	if len(rest) > 0 {
+		if err := a.tokens.Release(ctx, inc.EventID, len(rest)); err != nil {
+			a.log.Error("Failed to add tokens for new seats; resync the event's tokens", zap.Error(err), zap.String("event_id", inc.EventID))
+		} else {
+			inc.TokensReleased = len(rest)
+		}
+	}
+	if err := a.admin.RecordCapacityRelease(ctx, inc.ID, inc.WaitlistOffers, inc.TokensReleased); err != nil {
+		a.log.Error("Failed to record capacity release", zap.Error(err), zap.String("increase_id", inc.ID))
+	}
+}
+
+// CapacityIncreases lists the event's capacity increases, newest first.
+func (a *AdminService) CapacityIncreases(ctx context.Context, eventID string) ([]*admin.CapacityIncrease, error) {
+	return a.admin.ListCapacityIncreases(ctx, eventID)
+}
@@ -0,0 +1,87 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/admin"
+)
+
+const (
+	// MaxCompareEvents caps how many events one comparison covers.
+	MaxCompareEvents = 20
+	// compareCacheTTL is how long a comparison is served from memory. The metrics move
+	// slowly once an event is on sale, and the queries scan every booking of each event.
+	compareCacheTTL = time.Minute
+)
+
+var ErrInvalidComparison = errors.New("compare needs between 2 and 20 distinct event ids")
+
+type compareEntry struct {
+	result  []*admin.EventComparison
+	expires time.Time
+}
+
+// compareCache memoizes comparisons by their sorted set of event IDs.
+type compareCache struct {
+	mu      sync.Mutex
+	entries map[string]compareEntry
+}
+
+func (c *compareCache) get(key string, now time.Time) ([]*admin.EventComparison, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || now.After(e.expires) {
+		return nil, false
+	}
+	return e.result, true
+}
+
+func (c *compareCache) put(key string, result []*admin.EventComparison, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]compareEntry)
+	}
+	// Drop expired entries so ad hoc id combinations don't accumulate
+	for k, e := range c.entries {
+		if now.After(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = compareEntry{result: result, expires: now.Add(compareCacheTTL)}
+}
+
+// CompareEvents returns side-by-side sales metrics for the given events, cached for a minute.
+// Unknown IDs are left out of the result.
+func (a *AdminService) CompareEvents(ctx context.Context, eventIDs []string) ([]*admin.EventComparison, error) {
+	seen := make(map[string]bool, len(eventIDs))
+	ids := make([]string, 0, len(eventIDs))
+	for _, id := range eventIDs {
+		if id = strings.TrimSpace(id); id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) < 2 || len(ids) > MaxCompareEvents {
+		return nil, ErrInvalidComparison
+	}
+	sort.Strings(ids)
+	key := strings.Join(ids, ",")
+
+	now := time.Now()
+	if res, ok := a.compare.get(key, now); ok {
+		return res, nil
+	}
+	res, err := a.admin.CompareEvents(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	a.compare.put(key, res, now)
+	return res, nil
+}
@@ -0,0 +1,319 @@
+package admin
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"net/url"
+	"strings"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+
+	jobService "github.com/samirwankhede/lewly-pgpyewj/internal/service/jobs"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/events"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/invitations"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/jobs"
+)
+
+const (
+	// MaxInvitees caps the rows of one invitee import.
+	MaxInvitees = 5000
+
+	inviteCodeLength   = 8
+	inviteCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	// inviteCodeAttempts bounds retries on code collisions.
+	inviteCodeAttempts = 5
+)
+
+var (
+	ErrEventNotPrivate = errors.New("invitations are only for private events")
+	ErrInvalidInvitees = errors.New("invalid invitee CSV")
+)
+
+// WithInvitations enables importing invitees for private events. baseURL is the public API
+// address invite links in invitation emails point to.
+func (a *AdminService) WithInvitations(repo *invitations.InvitationsRepository, baseURL string) *AdminService {
+	a.invitations = repo
+	a.inviteBaseURL = strings.TrimRight(baseURL, "/")
+	return a
+}
+
+// inviteLink opens the private event with the invitee's code filled in.
+func (a *AdminService) inviteLink(eventID, code string) string {
+	return a.inviteBaseURL + "/v1/events/" + url.PathEscape(eventID) + "?code=" + url.QueryEscape(code)
+}
+
+// ImportedInvitee is one invitee of an import and the invitation they hold.
+type ImportedInvitee struct {
+	Email  string `json:"email"`
+	UserID string `json:"user_id"`
+	Code   string `json:"code"`
+	// NewUser is set when the import created the user's account
+	NewUser bool `json:"new_user"`
+	// AlreadyInvited is set when the user held an invitation before this import
+	AlreadyInvited bool `json:"already_invited"`
+	// EmailError is why the invitation email couldn't be sent, if it couldn't
+	EmailError string `json:"email_error,omitempty"`
+}
+
+// RejectedInvitee is a CSV row that was not imported.
+type RejectedInvitee struct {
+	Line   int    `json:"line"`
+	Email  string `json:"email"`
+	Reason string `json:"reason"`
+}
+
+// InviteeImport summarizes an invitee import, as the result of its job. Created and Matched
+// count new and existing accounts; Invited counts invitations issued by this import.
+type InviteeImport struct {
+	EventID  string             `json:"event_id"`
+	Created  int                `json:"created"`
+	Matched  int                `json:"matched"`
+	Invited  int                `json:"invited"`
+	Invitees []*ImportedInvitee `json:"invitees"`
+	Rejected []*RejectedInvitee `json:"rejected"`
+}
+
+// InviteeList is a private event's invitees with their redemption funnel.
+type InviteeList struct {
+	EventID  string                 `json:"event_id"`
+	Issued   int                    `json:"issued"`
+	Redeemed int                    `json:"redeemed"`
+	Booked   int                    `json:"booked"`
+	Invitees []*invitations.Invitee `json:"invitees"`
+}
+
+type inviteeRow struct {
+	line  int
+	email string
+	name  string
+}
+
+// ImportInvitees reads a CSV of invitees and invites each one to the private event in a
+// background job whose result is the InviteeImport. The CSV has an email column and an
+// optional name column, either first and second or named in a header row. Emails matching
+// an account invite that user; any other email gets a new account with a random password,
+// which the invitee replaces through the password reset flow. Users already invited keep
+// their code and aren't emailed again. The file is parsed before the job starts, so an
+// unreadable one is an error here; rows with a bad or repeated email count as failed items
+// and are reported in Rejected. The rest are imported in one transaction: if any of them
+// can't be, none is and the job fails.
+func (a *AdminService) ImportInvitees(ctx context.Context, eventID string, r io.Reader) (*jobs.Job, error) {
+	event, err := a.privateEvent(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, rejected, err := parseInvitees(r)
+	if err != nil {
+		return nil, err
+	}
+	return a.jobs.Start(ctx, "invitee_import", &eventID, func(ctx context.Context, p *jobService.Progress) (any, error) {
+		return a.importInvitees(ctx, event, rows, rejected, p)
+	})
+}
+
+func (a *AdminService) importInvitees(ctx context.Context, event *events.Event, rows []inviteeRow, rejected []*RejectedInvitee, p *jobService.Progress) (*InviteeImport, error) {
+	if err := p.SetTotal(ctx, len(rows)+len(rejected)); err != nil {
+		return nil, err
+	}
+	for _, r := range rejected {
+		p.Fail(ctx, fmt.Sprintf("line %d %s: %s", r.Line, r.Email, r.Reason))
+	}
+
+	// New accounts get a password nobody knows; one hash serves the whole import, since
+	// hashing each row would hold the import's transaction open for minutes
+	hash, err := unknownPasswordHash()
+	if err != nil {
+		return nil, err
+	}
+	invitees := make([]invitations.NewInvitee, len(rows))
+	for i, row := range rows {
+		name := row.name
+		if name == "" {
+			name = row.email[:strings.Index(row.email, "@")]
+		}
+		invitees[i] = invitations.NewInvitee{Email: row.email, Name: name, PasswordHash: hash}
+	}
+	// Every account and invitation is written in one transaction, so a failing row leaves
+	// nothing imported and the job fails instead
+	imported, err := a.invitations.Import(ctx, event.ID, invitees, newInviteCode, inviteCodeAttempts)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &InviteeImport{EventID: event.ID, Invitees: []*ImportedInvitee{}, Rejected: rejected}
+	for i, imp := range imported {
+		row, inv := rows[i], imp.Invitation
+		if imp.NewUser {
+			res.Created++
+		} else {
+			res.Matched++
+		}
+		if imp.Issued {
+			res.Invited++
+		}
+		invitee := &ImportedInvitee{
+			Email:          imp.Email,
+			UserID:         inv.UserID,
+			Code:           inv.Code,
+			NewUser:        imp.NewUser,
+			AlreadyInvited: !imp.Issued,
+		}
+		res.Invitees = append(res.Invitees, invitee)
+
+		if imp.Issued {
+			err := a.mailer.For(event.OrganizerID).SendEventInvitationEmail(imp.Email, event.Name, event.StartTime, inv.Code, a.inviteLink(event.ID, inv.Code), imp.NewUser)
+			if err != nil {
+				// The invitation stands; the admin can pass the code on another way
+				invitee.EmailError = err.Error()
+				p.Fail(ctx, fmt.Sprintf("line %d %s: invitation email failed: %v", row.line, row.email, err))
+				continue
+			}
+		}
+		p.Succeed(ctx)
+	}
+
+	a.log.Info("Invitees imported", zap.String("event_id", event.ID), zap.Int("created", res.Created),
+		zap.Int("matched", res.Matched), zap.Int("invited", res.Invited), zap.Int("rejected", len(res.Rejected)))
+	return res, nil
+}
+
+// ListInvitees returns the private event's invitees and how many redeemed and booked.
+func (a *AdminService) ListInvitees(ctx context.Context, eventID string) (*InviteeList, error) {
+	if _, err := a.privateEvent(ctx, eventID); err != nil {
+		return nil, err
+	}
+	invitees, err := a.invitations.ListByEvent(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	out := &InviteeList{EventID: eventID, Issued: len(invitees), Invitees: invitees}
+	for _, i := range invitees {
+		if i.RedeemedAt != nil {
+			out.Redeemed++
+		}
+		if i.Booked {
+			out.Booked++
+		}
+	}
+	return out, nil
+}
+
+func (a *AdminService) privateEvent(ctx context.Context, eventID string) (*events.Event, error) {
+	event, err := a.events.Get(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	if event == nil {
+		return nil, ErrEventNotFound
+	}
+	if event.Visibility != events.VisibilityPrivate || a.invitations == nil {
+		return nil, ErrEventNotPrivate
+	}
+	return event, nil
+}
+
+// unknownPasswordHash hashes a random secret that is thrown away. A bcrypt hash (rather than
+// none) keeps invitees' new accounts eligible for the OTP password reset they sign in with.
+func unknownPasswordHash() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(hex.EncodeToString(secret)), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// parseInvitees reads invitee rows from CSV. Invalid and duplicate emails are returned as
+// rejected rather than failing the import; an unreadable or oversized file is an error.
+func parseInvitees(r io.Reader) ([]inviteeRow, []*RejectedInvitee, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	emailCol, nameCol := 0, 1
+	first := true
+	rows := []inviteeRow{}
+	rejected := []*RejectedInvitee{}
+	seen := make(map[string]bool)
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrInvalidInvitees, err)
+		}
+		line, _ := cr.FieldPos(0)
+
+		// A header row names the columns; without one email comes first, then name
+		if first {
+			first = false
+			if col := columnIndex(rec, "email"); col >= 0 {
+				emailCol, nameCol = col, columnIndex(rec, "name")
+				continue
+			}
+		}
+		if len(rows)+len(rejected) >= MaxInvitees {
+			return nil, nil, fmt.Errorf("%w: more than %d rows", ErrInvalidInvitees, MaxInvitees)
+		}
+
+		var email, name string
+		if emailCol < len(rec) {
+			email = strings.TrimSpace(rec[emailCol])
+		}
+		if nameCol >= 0 && nameCol < len(rec) {
+			name = strings.TrimSpace(rec[nameCol])
+		}
+		if email == "" && name == "" {
+			continue
+		}
+		addr, err := mail.ParseAddress(email)
+		if err != nil || addr.Address != email {
+			rejected = append(rejected, &RejectedInvitee{Line: line, Email: email, Reason: "invalid email"})
+			continue
+		}
+		key := strings.ToLower(email)
+		if seen[key] {
+			rejected = append(rejected, &RejectedInvitee{Line: line, Email: email, Reason: "duplicate email"})
+			continue
+		}
+		seen[key] = true
+		rows = append(rows, inviteeRow{line: line, email: email, name: name})
+	}
+	if len(rows) == 0 && len(rejected) == 0 {
+		return nil, nil, fmt.Errorf("%w: no invitees", ErrInvalidInvitees)
+	}
+	return rows, rejected, nil
+}
+
+// columnIndex returns the index of the header named name, or -1.
+func columnIndex(header []string, name string) int {
+	for i, h := range header {
+		if strings.EqualFold(strings.TrimSpace(h), name) {
+			return i
+		}
+	}
+	return -1
+}
+
+func newInviteCode() (string, error) {
+	b := make([]byte, inviteCodeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = inviteCodeAlphabet[int(b[i])%len(inviteCodeAlphabet)]
+	}
+	return string(b), nil
+}
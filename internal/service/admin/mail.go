@@ -0,0 +1,82 @@
+package admin
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/zap"
+
+	mailer "github.com/samirwankhede/lewly-pgpyewj/internal/service/mailer"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/notifications"
+)
+
+// ErrTestMailRecipient is returned for a test send without a recipient by a caller that has
+// no user account to default to, i.e. one authenticated with an API key.
+var ErrTestMailRecipient = errors.New("to is required when not signed in as a user")
+
+// MailTemplates returns every email template rendered with sample data.
+func (a *AdminService) MailTemplates() []mailer.Template {
+	return a.mailer.Templates()
+}
+
+// MailTemplate returns one template rendered with sample data.
+func (a *AdminService) MailTemplate(name string) (*mailer.Template, error) {
+	t, ok := a.mailer.Template(name)
+	if !ok {
+		return nil, mailer.ErrUnknownTemplate
+	}
+	return t, nil
+}
+
+// SendTestMail sends the named template with sample data to to, or to the requesting
+// admin's own address when to is empty, and returns the address it went to.
+func (a *AdminService) SendTestMail(ctx context.Context, adminID, name, to string) (string, error) {
+	if _, ok := a.mailer.Template(name); !ok {
+		return "", mailer.ErrUnknownTemplate
+	}
+	if to == "" {
+		if adminID == "" {
+			return "", ErrTestMailRecipient
+		}
+		user, err := a.users.GetByID(ctx, adminID)
+		if err != nil {
+			return "", err
+		}
+		if user == nil {
+			return "", ErrTestMailRecipient
+		}
+		to = user.Email
+	}
+	if err := a.mailer.SendTestEmail(to, name); err != nil {
+		return "", err
+	}
+	a.log.Info("Test email requested", zap.String("admin_id", adminID), zap.String("template", name), zap.String("to", to))
+	return to, nil
+}
+
+// ErrNoMailQueue is returned for mail queue requests when emails are sent inline.
+var ErrNoMailQueue = errors.New("mail queue is not enabled")
+
+// QueuedMail lists queued emails in status (all if empty), newest first, with how many are
+// in each status. Dead emails are the ones that ran out of attempts or were rejected.
+func (a *AdminService) QueuedMail(ctx context.Context, status string, limit, offset int) ([]*notifications.QueuedMail, map[string]int, error) {
+	q := a.mailer.Queue()
+	if q == nil {
+		return nil, nil, ErrNoMailQueue
+	}
+	return q.Mail(ctx, status, limit, offset)
+}
+
+// RequeueMail gives a dead email a fresh set of attempts.
+func (a *AdminService) RequeueMail(ctx context.Context, adminID, id string) (*notifications.QueuedMail, error) {
+	q := a.mailer.Queue()
+	if q == nil {
+		return nil, ErrNoMailQueue
+	}
+	m, err := q.Requeue(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	a.log.Info("Dead email requeued by admin", zap.String("admin_id", adminID), zap.String("mail_id", id))
+	return m, nil
+}
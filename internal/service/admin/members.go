@@ -0,0 +1,264 @@
+package admin
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	jwtMiddleware "github.com/samirwankhede/lewly-pgpyewj/internal/middleware"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/members"
+)
+
+const (
+	// memberTokenPrefix starts every co-organizer invitation token.
+	memberTokenPrefix = "em_"
+	memberTokenBytes  = 32
+	// memberInviteTTL is how long an invitation's link works.
+	memberInviteTTL = 7 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidMember      = errors.New("invalid member")
+	ErrMemberNotFound     = errors.New("member not found")
+	ErrMemberIsOwner      = errors.New("the event's owner can't be invited to it")
+	ErrMemberInviteClosed = errors.New("invitation was accepted or has expired")
+	ErrMemberRecipient    = errors.New("invitation was sent to another email address")
+)
+
+// MemberInvitePreview is what an invitee sees of an invitation before accepting it.
+type MemberInvitePreview struct {
+	Member    *members.Member `json:"member"`
+	EventName string          `json:"event_name"`
+	StartTime time.Time       `json:"start_time"`
+	FromName  string          `json:"from_name"`
+}
+
+// WithMembers lets event owners invite co-organizers. baseURL is the public API address the
+// links in invitation emails point to.
+func (a *AdminService) WithMembers(repo *members.MembersRepository, baseURL string) *AdminService {
+	a.members = repo
+	a.memberBaseURL = strings.TrimRight(baseURL, "/")
+	return a
+}
+
+// EventAccess reports whether the event exists, whether the user owns it and what they may
+// do on it as a co-organizer, for middleware.RequireEventPermission.
+func (a *AdminService) EventAccess(ctx context.Context, eventID, userID string) (bool, bool, []string, error) {
+	exists, owns, err := a.events.Ownership(ctx, eventID, userID)
+	if err != nil || !exists || owns || a.members == nil {
+		return exists, owns, nil, err
+	}
+	perms, err := a.members.Permissions(ctx, eventID, userID)
+	if err != nil {
+		return false, false, nil, err
+	}
+	return true, false, perms, nil
+}
+
+// InviteMember emails email an invitation to co-organize the event with permissions. A
+// pending invitation of the same address is replaced and its old link stops working.
+func (a *AdminService) InviteMember(ctx context.Context, eventID, inviterID, email string, permissions []string) (*members.Member, error) {
+	email = strings.TrimSpace(email)
+	if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+		return nil, fmt.Errorf("%w: email must be a plain email address", ErrInvalidMember)
+	}
+	permissions, err := validatePermissions(permissions)
+	if err != nil {
+		return nil, err
+	}
+	event, err := a.events.Get(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	if event == nil {
+		return nil, ErrEventNotFound
+	}
+	if event.OwnerID != nil {
+		owner, err := a.users.GetByID(ctx, *event.OwnerID)
+		if err != nil {
+			return nil, err
+		}
+		if owner != nil && strings.EqualFold(owner.Email, email) {
+			return nil, ErrMemberIsOwner
+		}
+	}
+
+	token, err := newMemberToken()
+	if err != nil {
+		return nil, err
+	}
+	m := &members.Member{EventID: eventID, Email: email, Permissions: permissions, InvitedBy: &inviterID, ExpiresAt: time.Now().Add(memberInviteTTL)}
+	m, err = a.members.Invite(ctx, m, hashMemberToken(token))
+	if err != nil {
+		return nil, err
+	}
+
+	fromName := ""
+	if inviter, err := a.users.GetByID(ctx, inviterID); err == nil && inviter != nil {
+		fromName = inviter.Name
+	}
+	link := a.memberBaseURL + "/v1/organizer/member-invites/" + url.PathEscape(token)
+	if err := a.mailer.For(event.OrganizerID).SendMemberInviteEmail(email, fromName, event.Name, event.StartTime, permissions, link, m.ExpiresAt); err != nil {
+		// Without the email nobody can accept it
+		if _, rerr := a.members.Remove(ctx, eventID, m.ID); rerr != nil {
+			a.log.Error("Failed to remove unsent member invitation", zap.Error(rerr), zap.String("member_id", m.ID))
+		}
+		return nil, err
+	}
+	a.log.Info("Co-organizer invited", zap.String("event_id", eventID), zap.String("member_id", m.ID), zap.Strings("permissions", permissions))
+	return m, nil
+}
+
+// EventMembers lists the event's co-organizers and pending invitations.
+func (a *AdminService) EventMembers(ctx context.Context, eventID string) ([]*members.Member, error) {
+	return a.members.List(ctx, eventID)
+}
+
+// UpdateMember replaces what the event's co-organizer, or invitee, may do.
+func (a *AdminService) UpdateMember(ctx context.Context, eventID, memberID string, permissions []string) (*members.Member, error) {
+	if _, err := uuid.Parse(memberID); err != nil {
+		return nil, ErrMemberNotFound
+	}
+	permissions, err := validatePermissions(permissions)
+	if err != nil {
+		return nil, err
+	}
+	m, err := a.members.SetPermissions(ctx, eventID, memberID, permissions)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, ErrMemberNotFound
+	}
+	return m, nil
+}
+
+// RemoveMember takes the co-organizer off the event, or withdraws their invitation.
+func (a *AdminService) RemoveMember(ctx context.Context, eventID, memberID string) error {
+	if _, err := uuid.Parse(memberID); err != nil {
+		return ErrMemberNotFound
+	}
+	ok, err := a.members.Remove(ctx, eventID, memberID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrMemberNotFound
+	}
+	return nil
+}
+
+// Memberships lists the events the user co-organizes.
+func (a *AdminService) Memberships(ctx context.Context, userID string) ([]*members.Membership, error) {
+	return a.members.ListByUser(ctx, userID)
+}
+
+// PreviewMemberInvite shows the invitee, signed in as userID, what the token offers.
+func (a *AdminService) PreviewMemberInvite(ctx context.Context, token, userID string) (*MemberInvitePreview, error) {
+	m, err := a.openMemberInvite(ctx, token, userID)
+	if err != nil {
+		return nil, err
+	}
+	event, err := a.events.Get(ctx, m.EventID)
+	if err != nil {
+		return nil, err
+	}
+	if event == nil {
+		return nil, ErrMemberInviteClosed
+	}
+	p := &MemberInvitePreview{Member: m, EventName: event.Name, StartTime: event.StartTime}
+	if m.InvitedBy != nil {
+		if inviter, err := a.users.GetByID(ctx, *m.InvitedBy); err == nil && inviter != nil {
+			p.FromName = inviter.Name
+		}
+	}
+	return p, nil
+}
+
+// AcceptMemberInvite makes userID, who must be signed in with the email the invitation was
+// sent to, a co-organizer of its event.
+func (a *AdminService) AcceptMemberInvite(ctx context.Context, token, userID string) (*members.Member, error) {
+	m, err := a.openMemberInvite(ctx, token, userID)
+	if err != nil {
+		return nil, err
+	}
+	m, err = a.members.Accept(ctx, m.ID, userID)
+	if err != nil {
+		if errors.Is(err, members.ErrInviteClosed) {
+			return nil, ErrMemberInviteClosed
+		}
+		return nil, err
+	}
+	a.log.Info("Co-organizer invitation accepted", zap.String("event_id", m.EventID), zap.String("member_id", m.ID), zap.String("user_id", userID))
+	return m, nil
+}
+
+// openMemberInvite returns the pending invitation token claims, if it was sent to userID's
+// email.
+func (a *AdminService) openMemberInvite(ctx context.Context, token, userID string) (*members.Member, error) {
+	if !strings.HasPrefix(token, memberTokenPrefix) {
+		return nil, ErrMemberNotFound
+	}
+	m, err := a.members.GetByTokenHash(ctx, hashMemberToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, ErrMemberNotFound
+	}
+	if m.Status != members.StatusInvited || !time.Now().Before(m.ExpiresAt) {
+		return nil, ErrMemberInviteClosed
+	}
+	user, err := a.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !strings.EqualFold(user.Email, m.Email) {
+		return nil, ErrMemberRecipient
+	}
+	return m, nil
+}
+
+// validatePermissions checks permissions are known event permissions, and returns them
+// without repeats in a fixed order.
+func validatePermissions(permissions []string) ([]string, error) {
+	if len(permissions) == 0 {
+		return nil, fmt.Errorf("%w: permissions must name at least one of %s", ErrInvalidMember, strings.Join(jwtMiddleware.EventPermissions, ", "))
+	}
+	for _, p := range permissions {
+		if !slices.Contains(jwtMiddleware.EventPermissions, p) {
+			return nil, fmt.Errorf("%w: unknown permission %q; permissions are %s", ErrInvalidMember, p, strings.Join(jwtMiddleware.EventPermissions, ", "))
+		}
+	}
+	out := []string{}
+	for _, p := range jwtMiddleware.EventPermissions {
+		if slices.Contains(permissions, p) {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+func newMemberToken() (string, error) {
+	b := make([]byte, memberTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return memberTokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashMemberToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
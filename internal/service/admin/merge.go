@@ -0,0 +1,68 @@
+package admin
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/admin"
+)
+
+var (
+	ErrMergeSameEvent    = errors.New("cannot merge an event into itself")
+	ErrMergeEventClosed  = errors.New("both events must be upcoming or ongoing")
+	ErrMergePending      = errors.New("source event has pending bookings; retry once their payment windows close")
+	ErrMergeSeatConflict = errors.New("seats booked on the source event are not available on the target")
+)
+
+// MergeEvents folds sourceID, an accidental duplicate, into targetID. Nothing is moved when
+// it returns ErrMergePending or ErrMergeSeatConflict; the result says which bookings or seats
+// are in the way. Afterwards the source's Redis keys are dropped and the target's tokens are
+// reset from Postgres, since they now cover both events' bookings.
+func (a *AdminService) MergeEvents(ctx context.Context, sourceID, targetID string) (*admin.MergeResult, error) {
+	if sourceID == targetID {
+		return nil, ErrMergeSameEvent
+	}
+	for _, id := range []string{sourceID, targetID} {
+		e, err := a.events.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if e == nil {
+			return nil, ErrEventNotFound
+		}
+		if e.Status.Over() {
+			return nil, ErrMergeEventClosed
+		}
+	}
+
+	res, err := a.admin.MergeEvents(ctx, sourceID, targetID)
+	if err != nil {
+		return nil, err
+	}
+	if res == nil {
+		return nil, ErrEventNotFound
+	}
+	if res.PendingBookings > 0 {
+		return res, ErrMergePending
+	}
+	if len(res.ConflictingSeats) > 0 {
+		return res, ErrMergeSeatConflict
+	}
+
+	a.invalidateEvent(ctx, sourceID)
+	a.invalidateEvent(ctx, targetID)
+	if _, err := a.tokens.ReleaseEventKeys(ctx, sourceID); err != nil {
+		a.log.Error("Failed to release Redis keys for merged event", zap.Error(err), zap.String("event_id", sourceID))
+	}
+	if _, err := a.ResyncTokens(ctx, targetID); err != nil {
+		a.log.Error("Failed to resync tokens after merge", zap.Error(err), zap.String("event_id", targetID))
+	}
+	a.log.Info("Merged duplicate event",
+		zap.String("source_id", sourceID),
+		zap.String("target_id", targetID),
+		zap.Int("bookings_moved", res.BookingsMoved),
+		zap.Int("waitlist_moved", res.WaitlistMoved))
+	return res, nil
+}
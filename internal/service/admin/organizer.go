@@ -0,0 +1,108 @@
+package admin
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/admin"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/events"
+)
+
+var (
+	ErrCannotGrantOrganizer = errors.New("user not found or already an admin")
+	ErrNotOrganizer         = errors.New("user is not an organizer")
+	ErrOrganizerField       = errors.New("organizer_id is set by an admin")
+)
+
+// GrantOrganizer makes the user an organizer, publishing their events under the organizer
+// profile if one is given. Admins can't be made organizers; they already manage every event.
+func (a *AdminService) GrantOrganizer(ctx context.Context, userID string, organizerID *string) error {
+	if organizerID != nil {
+		if _, err := a.organizers.Get(ctx, *organizerID); err != nil {
+			return err
+		}
+	}
+	if err := a.admin.GrantOrganizer(ctx, userID, organizerID); err != nil {
+		if err == pgx.ErrNoRows {
+			return ErrCannotGrantOrganizer
+		}
+		return err
+	}
+	a.announceRoleChange(ctx, userID)
+	return nil
+}
+
+// RevokeOrganizer makes the organizer a plain user. Their events stay as they are.
+func (a *AdminService) RevokeOrganizer(ctx context.Context, userID string) error {
+	if err := a.admin.RevokeOrganizer(ctx, userID); err != nil {
+		if err == pgx.ErrNoRows {
+			return ErrNotOrganizer
+		}
+		return err
+	}
+	a.announceRoleChange(ctx, userID)
+	return nil
+}
+
+// CreateOwnedEvent creates an event owned by ownerID, published under their organizer
+// profile whatever in.OrganizerID says.
+func (a *AdminService) CreateOwnedEvent(ctx context.Context, ownerID string, in AdminEvent) (*events.Event, error) {
+	organizerID, err := a.admin.OrganizerProfile(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	in.OrganizerID = organizerID
+	in.OwnerID = &ownerID
+	return a.CreateEvent(ctx, in)
+}
+
+// UpdateOwnedEvent is UpdateEvent for an event's owner, who may change everything an admin
+// can except the organizer profile it is published under.
+func (a *AdminService) UpdateOwnedEvent(ctx context.Context, eventID string, updates map[string]interface{}) error {
+	if _, ok := updates["organizer_id"]; ok {
+		return ErrOrganizerField
+	}
+	return a.UpdateEvent(ctx, eventID, updates)
+}
+
+// OwnedEvents pages through the events the user owns, soonest first.
+func (a *AdminService) OwnedEvents(ctx context.Context, ownerID string, limit, offset int) ([]*events.Event, error) {
+	return a.events.ListByOwner(ctx, ownerID, limit, offset)
+}
+
+// OwnedAnalytics computes the comparison metrics of a page of the user's events, as
+// OwnedEvents pages them.
+func (a *AdminService) OwnedAnalytics(ctx context.Context, ownerID string, limit, offset int) ([]*admin.EventComparison, error) {
+	owned, err := a.events.ListByOwner(ctx, ownerID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	if len(owned) == 0 {
+		return []*admin.EventComparison{}, nil
+	}
+	ids := make([]string, len(owned))
+	for i, e := range owned {
+		ids[i] = e.ID
+	}
+	return a.admin.CompareEvents(ctx, ids)
+}
+
+// EventAnalytics computes the comparison metrics of one event.
+func (a *AdminService) EventAnalytics(ctx context.Context, eventID string) (*admin.EventComparison, error) {
+	res, err := a.admin.CompareEvents(ctx, []string{eventID})
+	if err != nil {
+		return nil, err
+	}
+	if len(res) == 0 {
+		return nil, ErrEventNotFound
+	}
+	return res[0], nil
+}
+
+// EventOwnership reports whether the event exists and whether the user owns it, for
+// middleware.RequireEventOwner.
+func (a *AdminService) EventOwnership(ctx context.Context, eventID, userID string) (bool, bool, error) {
+	return a.events.Ownership(ctx, eventID, userID)
+}
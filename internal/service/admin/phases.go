@@ -0,0 +1,126 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/events"
+)
+
+// maxSalePhases caps how many phases an event is sold in.
+const maxSalePhases = 10
+
+var (
+	ErrInvalidSalePhases = errors.New("invalid sale phases")
+	ErrSalePhaseInUse    = errors.New("a sale phase that has bookings can't be removed")
+)
+
+// SalePhaseInput is one phase of SetSalePhases.
+type SalePhaseInput struct {
+	Name     string    `json:"name" binding:"required"`
+	Price    float64   `json:"price"`
+	Quantity int       `json:"quantity"`
+	StartsAt time.Time `json:"starts_at" binding:"required"`
+	EndsAt   time.Time `json:"ends_at" binding:"required"`
+}
+
+// SetSalePhases replaces the event's sale phases with in, in the order they are sold. Their
+// windows must follow one another without overlapping and end by the event's end, and their
+// quantities fit in its capacity. Phases are matched to the current ones by name, so
+// renaming a phase that has bookings fails with ErrSalePhaseInUse. The new quantities are
+// rolled over again for phases that have already ended, and the phase buckets reset to
+// match. An empty in sells the event without phases again.
+func (a *AdminService) SetSalePhases(ctx context.Context, eventID string, in []SalePhaseInput) ([]*events.SalePhase, error) {
+	event, err := a.events.Get(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	if event == nil {
+		return nil, ErrEventNotFound
+	}
+	if event.Status.Over() {
+		return nil, ErrEventOver
+	}
+	if err := validateSalePhases(in, event); err != nil {
+		return nil, err
+	}
+
+	phases := make([]*events.SalePhase, len(in))
+	for i, p := range in {
+		phases[i] = &events.SalePhase{Name: p.Name, Price: p.Price, Quantity: p.Quantity, StartsAt: p.StartsAt, EndsAt: p.EndsAt}
+	}
+	if _, err := a.events.SetSalePhases(ctx, eventID, phases); err != nil {
+		if err == events.ErrSalePhaseInUse {
+			return nil, ErrSalePhaseInUse
+		}
+		return nil, err
+	}
+	// Rolling over moves tokens between buckets the resync then sets outright, so both run
+	// under the lock cancellations take
+	err = a.locks.Event(ctx, "resync", eventID, func(ctx context.Context) error {
+		if _, err := a.phases.RollOver(ctx, eventID); err != nil {
+			return err
+		}
+		_, err := a.phases.Resync(ctx, eventID)
+		return err
+	})
+	if err != nil {
+		// The phases are saved; a token resync finishes the job
+		a.log.Error("Failed to reset sale phase tokens", zap.Error(err), zap.String("event_id", eventID))
+	}
+	a.log.Info("Sale phases set", zap.String("event_id", eventID), zap.Int("phases", len(in)))
+	return a.events.SalePhases(ctx, eventID)
+}
+
+// SalePhases returns the event's sale phases in order, with what each has sold and rolled over.
+func (a *AdminService) SalePhases(ctx context.Context, eventID string) ([]*events.SalePhase, error) {
+	event, err := a.events.Get(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	if event == nil {
+		return nil, ErrEventNotFound
+	}
+	return a.events.SalePhases(ctx, eventID)
+}
+
+func validateSalePhases(in []SalePhaseInput, event *events.Event) error {
+	if len(in) > maxSalePhases {
+		return fmt.Errorf("%w: an event can have at most %d sale phases", ErrInvalidSalePhases, maxSalePhases)
+	}
+	seen := make(map[string]bool, len(in))
+	total := 0
+	for i, p := range in {
+		if p.Name == "" || len(p.Name) > maxTierNameLen {
+			return fmt.Errorf("%w: sale phase names must be 1-%d characters", ErrInvalidSalePhases, maxTierNameLen)
+		}
+		if seen[p.Name] {
+			return fmt.Errorf("%w: sale phase %q appears more than once", ErrInvalidSalePhases, p.Name)
+		}
+		seen[p.Name] = true
+		if p.Price < 0 {
+			return fmt.Errorf("%w: sale phase %q has a negative price", ErrInvalidSalePhases, p.Name)
+		}
+		if p.Quantity < 0 {
+			return fmt.Errorf("%w: sale phase %q has a negative quantity", ErrInvalidSalePhases, p.Name)
+		}
+		if !p.StartsAt.Before(p.EndsAt) {
+			return fmt.Errorf("%w: sale phase %q must start before it ends", ErrInvalidSalePhases, p.Name)
+		}
+		if i > 0 && p.StartsAt.Before(in[i-1].EndsAt) {
+			return fmt.Errorf("%w: sale phase %q starts before %q ends", ErrInvalidSalePhases, p.Name, in[i-1].Name)
+		}
+		if p.EndsAt.After(event.EndTime) {
+			return fmt.Errorf("%w: sale phase %q ends after the event", ErrInvalidSalePhases, p.Name)
+		}
+		total += p.Quantity
+	}
+	if total > event.Capacity {
+		return fmt.Errorf("%w: sale phases sell %d seats but the event has %d", ErrInvalidSalePhases, total, event.Capacity)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/prewarms"
+)
+
+var (
+	ErrPrewarmNotFound    = errors.New("no on-sale is scheduled for this event")
+	ErrSaleStartPassed    = errors.New("sale_start_time must be in the future")
+	ErrSaleStartAfterShow = errors.New("sale_start_time must be before the event starts")
+	ErrEventOver          = errors.New("event is cancelled or expired")
+)
+
+// WithPrewarms lets admins schedule on-sales for the event status checker to warm up.
+func (a *AdminService) WithPrewarms(repo *prewarms.PrewarmsRepository) *AdminService {
+	a.prewarms = repo
+	return a
+}
+
+// SchedulePrewarm schedules the event's on-sale for saleStart, replacing any earlier
+// schedule and its readiness report.
+func (a *AdminService) SchedulePrewarm(ctx context.Context, eventID string, saleStart time.Time) (*prewarms.Prewarm, error) {
+	event, err := a.events.Get(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	if event == nil {
+		return nil, ErrEventNotFound
+	}
+	if event.Status.Over() {
+		return nil, ErrEventOver
+	}
+	if !saleStart.After(time.Now()) {
+		return nil, ErrSaleStartPassed
+	}
+	if !saleStart.Before(event.StartTime) {
+		return nil, ErrSaleStartAfterShow
+	}
+	p, err := a.prewarms.Schedule(ctx, eventID, saleStart)
+	if err != nil {
+		return nil, err
+	}
+	a.log.Info("On-sale scheduled", zap.String("event_id", eventID), zap.Time("sale_start_time", saleStart))
+	return p, nil
+}
+
+// Prewarm returns the event's scheduled on-sale and its readiness report, if it has been
+// warmed.
+func (a *AdminService) Prewarm(ctx context.Context, eventID string) (*prewarms.Prewarm, error) {
+	p, err := a.prewarms.Get(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, ErrPrewarmNotFound
+	}
+	return p, nil
+}
+
+// CancelPrewarm unschedules the event's on-sale.
+func (a *AdminService) CancelPrewarm(ctx context.Context, eventID string) error {
+	removed, err := a.prewarms.Delete(ctx, eventID)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return ErrPrewarmNotFound
+	}
+	return nil
+}
+
+// Prewarms lists the on-sales starting at or after since, soonest first.
+func (a *AdminService) Prewarms(ctx context.Context, since time.Time, limit, offset int) ([]*prewarms.Prewarm, error) {
+	return a.prewarms.List(ctx, since, limit, offset)
+}
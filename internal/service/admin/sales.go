@@ -0,0 +1,109 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/admin"
+)
+
+var ErrInvalidSalesCurve = errors.New("invalid sales curve request")
+
+// maxSalesCurvePoints caps a curve at about three months of hours; longer sales are asked
+// for by day.
+const maxSalesCurvePoints = 24 * 92
+
+// SalesPoint is one hour or day of an event's sale.
+type SalesPoint struct {
+	Start             time.Time `json:"start"`
+	Bookings          int       `json:"bookings"`
+	Tickets           int       `json:"tickets"`
+	CumulativeTickets int       `json:"cumulative_tickets"`
+	// SellThroughPercent is CumulativeTickets against the event's current capacity
+	SellThroughPercent float64 `json:"sell_through_percent"`
+}
+
+// SalesCurve is how an event's sale paced: tickets sold per hour or day from the event's
+// creation to now (or its end), with the running total. Empty buckets are included so the
+// curve can be plotted as is.
+type SalesCurve struct {
+	EventID     string       `json:"event_id"`
+	Interval    string       `json:"interval"`
+	Timezone    string       `json:"timezone"`
+	Capacity    int          `json:"capacity"`
+	TicketsSold int          `json:"tickets_sold"`
+	Points      []SalesPoint `json:"points"`
+}
+
+// SalesCurve buckets the event's booked bookings by when they were finalized, per interval
+// ("hour" or "day") in the IANA time zone timezone. Bookings cancelled since don't count.
+func (a *AdminService) SalesCurve(ctx context.Context, eventID, interval, timezone string) (*SalesCurve, error) {
+	if interval != "hour" && interval != "day" {
+		return nil, fmt.Errorf("%w: interval must be hour or day", ErrInvalidSalesCurve)
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unknown timezone %q", ErrInvalidSalesCurve, timezone)
+	}
+	event, err := a.events.Get(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	if event == nil {
+		return nil, ErrEventNotFound
+	}
+
+	step := func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }
+	trunc := func(t time.Time) time.Time {
+		y, m, d := t.In(loc).Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, loc)
+	}
+	if interval == "hour" {
+		step = func(t time.Time) time.Time { return t.Add(time.Hour) }
+		// Hours start on the zone's hour, which isn't UTC's in half-hour zones
+		trunc = func(t time.Time) time.Time {
+			y, m, d := t.In(loc).Date()
+			return time.Date(y, m, d, t.In(loc).Hour(), 0, 0, 0, loc)
+		}
+	}
+	from := trunc(event.CreatedAt)
+	to := time.Now()
+	if !event.EndTime.IsZero() && event.EndTime.Before(to) {
+		to = event.EndTime
+	}
+
+	buckets, err := a.admin.SalesByBucket(ctx, eventID, interval, loc.String())
+	if err != nil {
+		return nil, err
+	}
+	sold := make(map[int64]admin.SalesBucket, len(buckets))
+	for _, b := range buckets {
+		sold[b.Start.Unix()] = b
+		// Bookings moved in by a merge can predate the event, and late finalizations can
+		// follow its end; both still belong on the curve
+		if b.Start.Before(from) {
+			from = b.Start.In(loc)
+		}
+		if b.Start.After(to) {
+			to = b.Start
+		}
+	}
+	if interval == "hour" && to.Sub(from) > maxSalesCurvePoints*time.Hour {
+		return nil, fmt.Errorf("%w: the sale is too long to show by hour, use interval=day", ErrInvalidSalesCurve)
+	}
+
+	curve := &SalesCurve{EventID: eventID, Interval: interval, Timezone: loc.String(), Capacity: event.Capacity, Points: []SalesPoint{}}
+	for t := from; !t.After(to); t = step(t) {
+		b := sold[t.Unix()]
+		curve.TicketsSold += b.Tickets
+		p := SalesPoint{Start: t, Bookings: b.Bookings, Tickets: b.Tickets, CumulativeTickets: curve.TicketsSold}
+		if event.Capacity > 0 {
+			p.SellThroughPercent = math.Round(1000*float64(p.CumulativeTickets)/float64(event.Capacity)) / 10
+		}
+		curve.Points = append(curve.Points, p)
+	}
+	return curve, nil
+}
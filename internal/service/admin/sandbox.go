@@ -0,0 +1,74 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/events"
+)
+
+var (
+	ErrEventNotSandbox = errors.New("event is not a sandbox event")
+	ErrUserNotFound    = errors.New("user not found")
+)
+
+// SandboxTesters lists who besides admins may book the sandbox event.
+func (a *AdminService) SandboxTesters(ctx context.Context, eventID string) ([]*events.SandboxTester, error) {
+	if _, err := a.sandboxEvent(ctx, eventID); err != nil {
+		return nil, err
+	}
+	return a.events.SandboxTesters(ctx, eventID)
+}
+
+// AddSandboxTester lets the user with email book the sandbox event, e.g. a member of the
+// organizer's staff rehearsing the on-sale. They need an account already.
+func (a *AdminService) AddSandboxTester(ctx context.Context, eventID, email string) (*events.SandboxTester, error) {
+	if _, err := a.sandboxEvent(ctx, eventID); err != nil {
+		return nil, err
+	}
+	u, err := a.users.GetByEmail(ctx, strings.TrimSpace(email))
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, ErrUserNotFound
+	}
+	if err := a.events.AddSandboxTester(ctx, eventID, u.ID); err != nil {
+		return nil, err
+	}
+	a.log.Info("Sandbox tester added", zap.String("event_id", eventID), zap.String("user_id", u.ID))
+	return &events.SandboxTester{UserID: u.ID, Email: u.Email, Name: u.Name}, nil
+}
+
+// RemoveSandboxTester stops the user booking the sandbox event. Bookings they already made
+// are kept.
+func (a *AdminService) RemoveSandboxTester(ctx context.Context, eventID, userID string) error {
+	if _, err := a.sandboxEvent(ctx, eventID); err != nil {
+		return err
+	}
+	removed, err := a.events.RemoveSandboxTester(ctx, eventID, userID)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func (a *AdminService) sandboxEvent(ctx context.Context, eventID string) (*events.Event, error) {
+	event, err := a.events.Get(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	if event == nil {
+		return nil, ErrEventNotFound
+	}
+	if !event.Sandbox {
+		return nil, ErrEventNotSandbox
+	}
+	return event, nil
+}
@@ -0,0 +1,171 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/events"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/seats"
+)
+
+const (
+	// MaxSeatsPerEvent bounds a seat map, whether listed or generated.
+	MaxSeatsPerEvent = 100000
+	maxSeatLabelLen  = 32
+	maxTierNameLen   = 64
+)
+
+var (
+	ErrInvalidSeats = errors.New("invalid seats")
+	seatLabelFormat = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9 ._-]*$`)
+)
+
+// SeatLayout generates a seat map of rows × seats per row, labelled prefix + row + number:
+// {"rows": ["A", "B"], "seats_per_row": 40} gives A1..A40, B1..B40. RowCount instead of
+// Rows names rows A..Z, AA, AB, and so on.
+type SeatLayout struct {
+	Rows        []string `json:"rows"`
+	RowCount    int      `json:"row_count" binding:"omitempty,gt=0"`
+	SeatsPerRow int      `json:"seats_per_row" binding:"required,gt=0"`
+	Prefix      string   `json:"prefix"`
+	// FirstNumber is the number of the first seat in each row (default 1)
+	FirstNumber *int `json:"first_number" binding:"omitempty,gte=0"`
+}
+
+// SeatSection is a named block of the seat map, laid out like SeatLayout and sold at one
+// price tier: {"name": "Balcony", "tier": "premium", "rows": ["A"], "seats_per_row": 12,
+// "prefix": "BAL-"}. Labels must be unique across sections, so sections reusing row names
+// need a prefix.
+type SeatSection struct {
+	Name string `json:"name"`
+	// Tier names one of the event's price tiers; without one the seats sell at the ticket price
+	Tier string `json:"tier"`
+	SeatLayout
+}
+
+// seats expands the layout into seats of section sold at tier, row by row.
+func (l *SeatLayout) seats(section, tier string) ([]seats.NewSeat, error) {
+	rows := l.Rows
+	if len(rows) > 0 && l.RowCount > 0 {
+		return nil, fmt.Errorf("%w: seat_layout takes rows or row_count, not both", ErrInvalidSeats)
+	}
+	if len(rows) == 0 {
+		if l.RowCount <= 0 {
+			return nil, fmt.Errorf("%w: seat_layout needs rows or row_count", ErrInvalidSeats)
+		}
+		rows = make([]string, l.RowCount)
+		for i := range rows {
+			rows[i] = rowName(i)
+		}
+	}
+	if l.SeatsPerRow <= 0 {
+		return nil, fmt.Errorf("%w: seats_per_row must be positive", ErrInvalidSeats)
+	}
+	if len(rows)*l.SeatsPerRow > MaxSeatsPerEvent {
+		return nil, fmt.Errorf("%w: layout has %d seats, at most %d allowed", ErrInvalidSeats, len(rows)*l.SeatsPerRow, MaxSeatsPerEvent)
+	}
+	first := 1
+	if l.FirstNumber != nil {
+		first = *l.FirstNumber
+	}
+
+	out := make([]seats.NewSeat, 0, len(rows)*l.SeatsPerRow)
+	for _, row := range rows {
+		for n := first; n < first+l.SeatsPerRow; n++ {
+			out = append(out, seats.NewSeat{Label: l.Prefix + row + strconv.Itoa(n), Section: section, Row: row, Tier: tier})
+		}
+	}
+	return out, nil
+}
+
+// sectionSeats expands sections in order. Every section needs a distinct name and a tier,
+// if it has one, from tiers.
+func sectionSeats(sections []SeatSection, tiers []events.PriceTier) ([]seats.NewSeat, error) {
+	known := make(map[string]bool, len(tiers))
+	for _, t := range tiers {
+		known[t.Name] = true
+	}
+	names := make(map[string]bool, len(sections))
+	var out []seats.NewSeat
+	for i := range sections {
+		sec := &sections[i]
+		if sec.Name == "" {
+			return nil, fmt.Errorf("%w: section %d needs a name", ErrInvalidSeats, i+1)
+		}
+		if names[sec.Name] {
+			return nil, fmt.Errorf("%w: section %q appears more than once", ErrInvalidSeats, sec.Name)
+		}
+		names[sec.Name] = true
+		if sec.Tier != "" && !known[sec.Tier] {
+			return nil, fmt.Errorf("%w: section %q uses unknown price tier %q", ErrInvalidSeats, sec.Name, sec.Tier)
+		}
+		s, err := sec.seats(sec.Name, sec.Tier)
+		if err != nil {
+			return nil, fmt.Errorf("section %q: %w", sec.Name, err)
+		}
+		if len(out)+len(s) > MaxSeatsPerEvent {
+			return nil, fmt.Errorf("%w: sections have more than %d seats", ErrInvalidSeats, MaxSeatsPerEvent)
+		}
+		out = append(out, s...)
+	}
+	return out, nil
+}
+
+// validatePriceTiers checks that tiers have distinct names and non-negative prices.
+func validatePriceTiers(tiers []events.PriceTier) error {
+	seen := make(map[string]bool, len(tiers))
+	for _, t := range tiers {
+		if t.Name == "" || len(t.Name) > maxTierNameLen {
+			return fmt.Errorf("%w: price tier names must be 1-%d characters", ErrInvalidSeats, maxTierNameLen)
+		}
+		if seen[t.Name] {
+			return fmt.Errorf("%w: price tier %q appears more than once", ErrInvalidSeats, t.Name)
+		}
+		if t.Price < 0 {
+			return fmt.Errorf("%w: price tier %q has a negative price", ErrInvalidSeats, t.Name)
+		}
+		seen[t.Name] = true
+	}
+	return nil
+}
+
+func seatLabels(s []seats.NewSeat) []string {
+	labels := make([]string, len(s))
+	for i := range s {
+		labels[i] = s[i].Label
+	}
+	return labels
+}
+
+// rowName returns spreadsheet-style row names: 0 -> A, 25 -> Z, 26 -> AA.
+func rowName(i int) string {
+	name := ""
+	for i >= 0 {
+		name = string(rune('A'+i%26)) + name
+		i = i/26 - 1
+	}
+	return name
+}
+
+// validateSeatLabels checks that every label is well-formed and appears once.
+func validateSeatLabels(labels []string) error {
+	if len(labels) == 0 {
+		return fmt.Errorf("%w: an event needs at least one seat", ErrInvalidSeats)
+	}
+	if len(labels) > MaxSeatsPerEvent {
+		return fmt.Errorf("%w: %d seats, at most %d allowed", ErrInvalidSeats, len(labels), MaxSeatsPerEvent)
+	}
+	seen := make(map[string]bool, len(labels))
+	for _, label := range labels {
+		if len(label) > maxSeatLabelLen || !seatLabelFormat.MatchString(label) {
+			return fmt.Errorf("%w: label %q must be 1-%d letters, digits, spaces, '.', '_' or '-', starting with a letter or digit", ErrInvalidSeats, label, maxSeatLabelLen)
+		}
+		if seen[label] {
+			return fmt.Errorf("%w: label %q appears more than once", ErrInvalidSeats, label)
+		}
+		seen[label] = true
+	}
+	return nil
+}
@@ -12,7 +12,9 @@ import (
 	"golang.org/x/crypto/bcrypt"
 
 	jwtMiddleware "github.com/samirwankhede/lewly-pgpyewj/internal/middleware"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/oauth"
 	redisx "github.com/samirwankhede/lewly-pgpyewj/internal/redis"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/service/fx"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/service/mailer"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/store/users"
 )
@@ -23,6 +25,11 @@ type AuthService struct {
 	redis  *redisx.TokenBucket
 	secret string
 	mailer *mailer.MailerService
+	google *oauth.Google
+	// blacklist revokes tokens on logout; without one logout only ends the session client-side
+	blacklist *jwtMiddleware.TokenBlacklist
+	// sessions, when set, issues server-side session IDs instead of JWTs
+	sessions *jwtMiddleware.SessionStore
 }
 
 type SignupRequest struct {
@@ -44,11 +51,12 @@ type LoginResponse struct {
 }
 
 type UserInfo struct {
-	ID    string `json:"id"`
-	Name  string `json:"name"`
-	Email string `json:"email"`
-	Phone string `json:"phone"`
-	Role  string `json:"role"`
+	ID                string `json:"id"`
+	Name              string `json:"name"`
+	Email             string `json:"email"`
+	Phone             string `json:"phone"`
+	Role              string `json:"role"`
+	PreferredCurrency string `json:"preferred_currency,omitempty"`
 }
 
 type PasswordChangeRequest struct {
@@ -72,6 +80,7 @@ var (
 	ErrUserNotFound       = errors.New("user not found")
 	ErrInvalidOTP         = errors.New("invalid or expired OTP")
 	ErrOAuthUser          = errors.New("password change not allowed for OAuth users")
+	ErrInvalidToken       = errors.New("invalid token")
 )
 
 func NewAuthService(log *zap.Logger, users *users.UsersRepository, redis *redisx.TokenBucket, secret string, mailer *mailer.MailerService) *AuthService {
@@ -84,6 +93,19 @@ func NewAuthService(log *zap.Logger, users *users.UsersRepository, redis *redisx
 	}
 }
 
+// WithTokenBlacklist makes Logout revoke the token it is given.
+func (s *AuthService) WithTokenBlacklist(blacklist *jwtMiddleware.TokenBlacklist) *AuthService {
+	s.blacklist = blacklist
+	return s
+}
+
+// WithSessions has sign-ins issue session IDs kept in store instead of JWTs. Logout ends
+// the session, and changing or resetting a password ends all of the user's sessions.
+func (s *AuthService) WithSessions(store *jwtMiddleware.SessionStore) *AuthService {
+	s.sessions = store
+	return s
+}
+
 func (s *AuthService) Signup(ctx context.Context, req SignupRequest) (*LoginResponse, error) {
 	// Check if user already exists
 	existing, err := s.users.GetByEmail(ctx, req.Email)
@@ -114,7 +136,7 @@ func (s *AuthService) Signup(ctx context.Context, req SignupRequest) (*LoginResp
 	}
 
 	// Generate token
-	token, expires, err := s.generateToken(user.ID, user.Role == "admin")
+	token, expires, err := s.generateToken(ctx, user.ID, user.Role == "admin", user.RoleVersion)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate token: %w", err)
 	}
@@ -148,7 +170,7 @@ func (s *AuthService) Login(ctx context.Context, req LoginRequest) (*LoginRespon
 	}
 
 	// Generate token
-	token, expires, err := s.generateToken(user.ID, user.Role == "admin")
+	token, expires, err := s.generateToken(ctx, user.ID, user.Role == "admin", user.RoleVersion)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate token: %w", err)
 	}
@@ -160,6 +182,27 @@ func (s *AuthService) Login(ctx context.Context, req LoginRequest) (*LoginRespon
 	}, nil
 }
 
+// Logout revokes token until it would have expired, or ends it if it is a session ID.
+func (s *AuthService) Logout(ctx context.Context, token string) error {
+	if s.sessions != nil && jwtMiddleware.IsSessionID(token) {
+		if err := s.sessions.Delete(ctx, token); err != nil {
+			return fmt.Errorf("failed to end session: %w", err)
+		}
+		return nil
+	}
+	claims, err := jwtMiddleware.ParseToken(s.secret, token)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	if s.blacklist == nil {
+		return nil
+	}
+	if err := s.blacklist.Revoke(ctx, claims); err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	return nil
+}
+
 func (s *AuthService) ChangePassword(ctx context.Context, userID string, req PasswordChangeRequest) error {
 	user, err := s.users.GetByID(ctx, userID)
 	if err != nil {
@@ -184,7 +227,11 @@ func (s *AuthService) ChangePassword(ctx context.Context, userID string, req Pas
 	}
 
 	// Update password
-	return s.users.UpdatePassword(ctx, userID, string(hashedPassword))
+	if err := s.users.UpdatePassword(ctx, userID, string(hashedPassword)); err != nil {
+		return err
+	}
+	s.endSessions(ctx, userID)
+	return nil
 }
 
 func (s *AuthService) RequestPasswordChangeOTP(ctx context.Context, req OTPRequest) error {
@@ -257,6 +304,7 @@ func (s *AuthService) VerifyPasswordChangeOTP(ctx context.Context, req OTPVerify
 
 	// Delete OTP
 	s.redis.GetClient().Del(ctx, key)
+	s.endSessions(ctx, user.ID)
 
 	return nil
 }
@@ -272,15 +320,18 @@ func (s *AuthService) GetProfile(ctx context.Context, userID string) (*UserInfo,
 	}
 
 	return &UserInfo{
-		ID:    user.ID,
-		Name:  user.Name,
-		Email: user.Email,
-		Phone: user.Phone,
-		Role:  user.Role,
+		ID:                user.ID,
+		Name:              user.Name,
+		Email:             user.Email,
+		Phone:             user.Phone,
+		Role:              user.Role,
+		PreferredCurrency: user.PreferredCurrency,
 	}, nil
 }
 
-func (s *AuthService) UpdateProfile(ctx context.Context, userID string, name, phone string) error {
+// UpdateProfile sets the user's name and phone. preferredCurrency is left unchanged when
+// nil and cleared when empty.
+func (s *AuthService) UpdateProfile(ctx context.Context, userID string, name, phone string, preferredCurrency *string) error {
 	user, err := s.users.GetByID(ctx, userID)
 	if err != nil {
 		return ErrUserNotFound
@@ -289,18 +340,45 @@ func (s *AuthService) UpdateProfile(ctx context.Context, userID string, name, ph
 	if user == nil {
 		return ErrUserNotFound
 	}
-	return s.users.UpdateProfile(ctx, userID, name, phone)
+
+	currency := user.PreferredCurrency
+	if preferredCurrency != nil {
+		currency = *preferredCurrency
+		if currency != "" {
+			if currency, err = fx.Normalize(currency); err != nil {
+				return err
+			}
+		}
+	}
+	return s.users.UpdateProfile(ctx, userID, name, phone, currency)
 }
 
-func (s *AuthService) generateToken(userID string, isAdmin bool) (string, time.Time, error) {
+func (s *AuthService) generateToken(ctx context.Context, userID string, isAdmin bool, roleVersion int) (string, time.Time, error) {
+	if s.sessions != nil {
+		return s.sessions.Create(ctx, userID, isAdmin, roleVersion)
+	}
 	expires := time.Now().Add(24 * time.Hour)
-	token, err := jwtMiddleware.Issue(s.secret, userID, isAdmin, 24*time.Hour)
+	token, err := jwtMiddleware.Issue(s.secret, userID, isAdmin, roleVersion, 24*time.Hour)
 	if err != nil {
 		return "", time.Time{}, err
 	}
 	return token, expires, nil
 }
 
+// endSessions ends every session of the user, when sessions are in use. The password is
+// already changed, so a failure is only logged; the sessions still expire on their own.
+func (s *AuthService) endSessions(ctx context.Context, userID string) {
+	if s.sessions == nil {
+		return
+	}
+	n, err := s.sessions.DeleteUser(ctx, userID)
+	if err != nil {
+		s.log.Error("Failed to end sessions", zap.Error(err), zap.String("user_id", userID))
+		return
+	}
+	s.log.Info("Ended sessions", zap.String("user_id", userID), zap.Int("sessions", n))
+}
+
 func (s *AuthService) generateOTP() string {
 	bytes := make([]byte, 3)
 	rand.Read(bytes)
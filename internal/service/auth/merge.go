@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/users"
+)
+
+// mergeCodeTTL is how long both accounts have to confirm a merge.
+const mergeCodeTTL = 15 * time.Minute
+
+var (
+	ErrMergeSameUser    = errors.New("cannot merge an account into itself")
+	ErrInvalidMergeCode = errors.New("invalid or expired merge codes")
+)
+
+type MergeRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type MergeConfirmRequest struct {
+	// Code is the one sent to the signed-in account, MergedCode the one sent to the other
+	Code       string `json:"code" binding:"required"`
+	MergedCode string `json:"merged_code" binding:"required"`
+}
+
+// pendingMerge is a merge waiting for both codes, stored under the kept user.
+type pendingMerge struct {
+	MergedID   string `json:"merged_id"`
+	Code       string `json:"code"`
+	MergedCode string `json:"merged_code"`
+}
+
+// RequestAccountMerge starts folding the account registered with req.Email into userID by
+// emailing each account its own code. Like the password OTP it reveals nothing about
+// whether the other account exists: unknown emails and admin accounts, which would lose
+// their role, get no codes. A new request replaces the previous one.
+func (s *AuthService) RequestAccountMerge(ctx context.Context, userID string, req MergeRequest) error {
+	kept, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if kept == nil {
+		return ErrUserNotFound
+	}
+	merged, err := s.users.GetByEmail(ctx, req.Email)
+	if err != nil {
+		return err
+	}
+	// Merging an organizer away would orphan the events they own
+	if merged == nil || merged.Role == "admin" || merged.Role == "organizer" {
+		return nil
+	}
+	if merged.ID == kept.ID {
+		return ErrMergeSameUser
+	}
+
+	p := pendingMerge{MergedID: merged.ID, Code: s.generateOTP(), MergedCode: s.generateOTP()}
+	raw, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	if err := s.redis.GetClient().Set(ctx, mergeKey(kept.ID), raw, mergeCodeTTL).Err(); err != nil {
+		return fmt.Errorf("failed to store merge request: %w", err)
+	}
+
+	if err := s.mailer.SendAccountMergeCodeEmail(kept.Email, p.Code, kept.Email, merged.Email); err != nil {
+		s.log.Error("Failed to send merge code", zap.Error(err))
+	}
+	if err := s.mailer.SendAccountMergeCodeEmail(merged.Email, p.MergedCode, kept.Email, merged.Email); err != nil {
+		s.log.Error("Failed to send merge code", zap.Error(err))
+	}
+	return nil
+}
+
+// ConfirmAccountMerge checks the codes sent to both accounts and merges the other account
+// into userID. The request is used up by the first attempt, right or wrong.
+func (s *AuthService) ConfirmAccountMerge(ctx context.Context, userID string, req MergeConfirmRequest) (*users.MergeResult, error) {
+	raw, err := s.redis.GetClient().GetDel(ctx, mergeKey(userID)).Bytes()
+	if err != nil {
+		return nil, ErrInvalidMergeCode
+	}
+	var p pendingMerge
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return nil, ErrInvalidMergeCode
+	}
+	if subtle.ConstantTimeCompare([]byte(p.Code), []byte(req.Code)) != 1 ||
+		subtle.ConstantTimeCompare([]byte(p.MergedCode), []byte(req.MergedCode)) != 1 {
+		return nil, ErrInvalidMergeCode
+	}
+
+	res, err := s.users.MergeInto(ctx, p.MergedID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if res == nil {
+		return nil, ErrUserNotFound
+	}
+	// The merged account is deleted; its sessions go with it
+	s.endSessions(ctx, p.MergedID)
+	s.log.Info("Merged accounts", zap.String("user_id", userID), zap.String("merged_user_id", p.MergedID),
+		zap.Int64("bookings", res.Bookings), zap.Int64("waitlist_entries", res.WaitlistEntries), zap.Int64("likes", res.Likes))
+	return res, nil
+}
+
+func mergeKey(userID string) string {
+	return "account_merge:" + userID
+}
@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/oauth"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/users"
+)
+
+// oauthStateTTL is how long a user has to finish Google's consent page.
+const oauthStateTTL = 10 * time.Minute
+
+const providerGoogle = "google"
+
+var (
+	ErrOAuthDisabled        = errors.New("oauth login is not configured")
+	ErrInvalidOAuthState    = errors.New("invalid or expired oauth state")
+	ErrOAuthEmailUnverified = errors.New("google account email is not verified")
+	ErrOAuthAccountLinked   = errors.New("account is already linked to another google identity")
+)
+
+// WithGoogle enables sign-in with Google.
+func (s *AuthService) WithGoogle(google *oauth.Google) *AuthService {
+	s.google = google
+	return s
+}
+
+// StartGoogleLogin returns the Google consent URL and the state it carries. The state is
+// single use and must come back on the callback within oauthStateTTL.
+func (s *AuthService) StartGoogleLogin(ctx context.Context) (string, string, error) {
+	if !s.google.Enabled() {
+		return "", "", ErrOAuthDisabled
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	state := hex.EncodeToString(b)
+	if err := s.redis.GetClient().Set(ctx, oauthStateKey(state), providerGoogle, oauthStateTTL).Err(); err != nil {
+		return "", "", fmt.Errorf("failed to store oauth state: %w", err)
+	}
+	return s.google.AuthCodeURL(state), state, nil
+}
+
+// FinishGoogleLogin exchanges the callback's code and signs the Google account in. A
+// returning account is found by its Google subject; otherwise it is linked to the user
+// with the same email, if Google has verified that email, or a new user without a
+// password is created.
+func (s *AuthService) FinishGoogleLogin(ctx context.Context, state, code string) (*LoginResponse, error) {
+	if !s.google.Enabled() {
+		return nil, ErrOAuthDisabled
+	}
+	if state == "" || code == "" {
+		return nil, ErrInvalidOAuthState
+	}
+	if err := s.redis.GetClient().GetDel(ctx, oauthStateKey(state)).Err(); err != nil {
+		return nil, ErrInvalidOAuthState
+	}
+
+	id, err := s.google.Exchange(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.users.GetByOAuth(ctx, providerGoogle, id.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		if user, err = s.linkOrCreate(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+
+	token, expires, err := s.generateToken(ctx, user.ID, user.Role == "admin", user.RoleVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	return &LoginResponse{
+		Token:   token,
+		User:    s.userToInfo(user),
+		Expires: expires,
+	}, nil
+}
+
+func (s *AuthService) linkOrCreate(ctx context.Context, id *oauth.Identity) (*users.User, error) {
+	if !id.EmailVerified {
+		return nil, ErrOAuthEmailUnverified
+	}
+	existing, err := s.users.GetByEmail(ctx, id.Email)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		if existing.OAuthSub != "" {
+			return nil, ErrOAuthAccountLinked
+		}
+		if err := s.users.LinkOAuth(ctx, existing.ID, providerGoogle, id.Subject); err != nil {
+			if err == pgx.ErrNoRows {
+				return nil, ErrOAuthAccountLinked
+			}
+			return nil, err
+		}
+		s.log.Info("Linked google account", zap.String("user_id", existing.ID))
+		existing.OAuthProvider, existing.OAuthSub = providerGoogle, id.Subject
+		return existing, nil
+	}
+
+	name := id.Name
+	if name == "" {
+		name = id.Email
+	}
+	user, err := s.users.Create(ctx, &users.User{
+		Name:          name,
+		Email:         id.Email,
+		OAuthProvider: providerGoogle,
+		OAuthSub:      id.Subject,
+		Role:          "user",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+	s.log.Info("Created user from google account", zap.String("user_id", user.ID))
+	return user, nil
+}
+
+func oauthStateKey(state string) string {
+	return "oauth_state:" + state
+}
@@ -0,0 +1,215 @@
+package bookings
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/metrics"
+	redisx "github.com/samirwankhede/lewly-pgpyewj/internal/redis"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/events"
+)
+
+// restoreTimeout bounds a token rebuild, which holds off fallback bookings on every instance.
+const restoreTimeout = 10 * time.Second
+
+var (
+	ErrAdmissionThrottled  = errors.New("booking is rate limited while running without Redis, retry shortly")
+	ErrAdmissionRecovering = errors.New("booking admission is switching back to Redis, retry shortly")
+)
+
+// Admission decides whether bookings are admitted through Redis tokens or, while Redis is
+// failing, through a locked capacity check in Postgres. It trips into the fallback on the
+// first Redis error and switches back once Redis answers again and its token buckets have
+// been rebuilt from Postgres. A nil or disabled Admission never falls back.
+//
+// The mode is shared by every API instance through Postgres: a trip on one instance is
+// followed by the others within a probe interval, and whichever instance first sees Redis
+// answer rebuilds the tokens under a lock that waits out fallback bookings in flight on
+// every instance and refuses new ones, so none is admitted without a token after the
+// rebuild counted what was left. Until an instance follows a trip it keeps taking tokens,
+// which the rebuild resets anyway.
+type Admission struct {
+	log     *zap.Logger
+	db      *store.DB
+	tokens  *redisx.TokenBucket
+	events  *events.EventsRepository
+	enabled bool
+
+	degraded atomic.Bool
+	// Serializes trips so only one request records the mode and the rest wait for it
+	tripMu sync.Mutex
+
+	// Global token bucket for fallback admissions, so Postgres sees a trickle rather than the on-sale
+	limitMu sync.Mutex
+	rps     float64
+	burst   float64
+	allowed float64
+	last    time.Time
+}
+
+func NewAdmission(log *zap.Logger, db *store.DB, tokens *redisx.TokenBucket, events *events.EventsRepository, enabled bool, rps int) *Admission {
+	if rps < 1 {
+		rps = 1
+	}
+	return &Admission{log: log, db: db, tokens: tokens, events: events, enabled: enabled, rps: float64(rps), burst: float64(rps), allowed: float64(rps)}
+}
+
+// Degraded reports whether bookings are currently admitted through Postgres.
+func (a *Admission) Degraded() bool {
+	return a != nil && a.degraded.Load()
+}
+
+// Trip switches every instance to the Postgres fallback after a Redis error and reports
+// whether the caller should retry through it. It is a no-op when the fallback is disabled,
+// and reports false if the switch can't be recorded in Postgres either.
+func (a *Admission) Trip(err error) bool {
+	if a == nil || !a.enabled {
+		return false
+	}
+	// A request cancelled or out of budget says nothing about Redis
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if a.degraded.Load() {
+		return true
+	}
+	a.tripMu.Lock()
+	defer a.tripMu.Unlock()
+	if a.degraded.Load() {
+		return true
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if serr := a.db.SetAdmissionDegraded(ctx); serr != nil {
+		a.log.Error("Redis unavailable and Postgres admission could not be switched on", zap.Error(err), zap.NamedError("store_error", serr))
+		return false
+	}
+	a.degrade()
+	a.log.Warn("Redis unavailable, admitting bookings through Postgres", zap.Error(err))
+	return true
+}
+
+func (a *Admission) degrade() {
+	a.degraded.Store(true)
+	metrics.AdmissionDegraded.Set(1)
+}
+
+func (a *Admission) restore() {
+	if a.degraded.CompareAndSwap(true, false) {
+		metrics.AdmissionDegraded.Set(0)
+	}
+}
+
+// Fallback runs fn, which admits the booking through Postgres. It returns
+// ErrAdmissionRecovering if Redis admission was restored in the meantime, on this instance
+// or, as fn finds under the admission lock, on another, and ErrAdmissionThrottled if the
+// fallback rate limit is exhausted.
+func (a *Admission) Fallback(fn func() error) error {
+	if !a.degraded.Load() {
+		return ErrAdmissionRecovering
+	}
+	if !a.allow() {
+		return ErrAdmissionThrottled
+	}
+	err := fn()
+	if errors.Is(err, store.ErrAdmissionRestored) {
+		a.restore()
+		return ErrAdmissionRecovering
+	}
+	return err
+}
+
+func (a *Admission) allow() bool {
+	a.limitMu.Lock()
+	defer a.limitMu.Unlock()
+	now := time.Now()
+	a.allowed += now.Sub(a.last).Seconds() * a.rps
+	if a.allowed > a.burst {
+		a.allowed = a.burst
+	}
+	a.last = now
+	if a.allowed < 1 {
+		return false
+	}
+	a.allowed--
+	return true
+}
+
+// Run follows the shared admission mode every interval and, while degraded, probes Redis.
+// Once it answers, every live event's token bucket is reset to what Postgres says is left,
+// since fallback bookings never took tokens, sale phase buckets are dropped to be rebuilt
+// the same way when next booked, and admission switches back to Redis on every instance.
+func (a *Admission) Run(ctx context.Context, interval time.Duration) {
+	if a == nil || !a.enabled {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.sync(ctx)
+		}
+	}
+}
+
+func (a *Admission) sync(ctx context.Context) {
+	degraded, err := a.db.AdmissionDegraded(ctx)
+	if err != nil {
+		a.log.Warn("Failed to read the shared admission mode", zap.Error(err))
+		return
+	}
+	if !degraded {
+		if a.degraded.Load() {
+			a.restore()
+			a.log.Info("Redis admission restored by another instance")
+		}
+		return
+	}
+	if !a.degraded.Load() {
+		a.degrade()
+		a.log.Warn("Another instance found Redis unavailable, admitting bookings through Postgres")
+	}
+	if err := a.tokens.GetClient().Ping(ctx).Err(); err != nil {
+		return
+	}
+	// Fallback bookings waiting on the lock hold pool connections the rebuild needs, so a
+	// rebuild that can't get one gives up and lets them through rather than wait forever
+	ctx, cancel := context.WithTimeout(ctx, restoreTimeout)
+	defer cancel()
+	resynced := 0
+	restored, err := a.db.RestoreAdmission(ctx, func(ctx context.Context) error {
+		expected, err := a.events.ExpectedTokensLive(ctx)
+		if err != nil {
+			return err
+		}
+		for eventID, n := range expected {
+			if err := a.tokens.InitTokens(ctx, eventID, n); err != nil {
+				return err
+			}
+		}
+		if _, err := a.tokens.DropAllPhaseTokens(ctx); err != nil {
+			return err
+		}
+		resynced = len(expected)
+		return nil
+	})
+	if err != nil {
+		a.log.Error("Redis is back but token rebuild failed, staying on Postgres admission", zap.Error(err))
+		return
+	}
+	a.restore()
+	if restored {
+		a.log.Info("Redis recovered, admitting bookings through tokens again", zap.Int("events_resynced", resynced))
+	} else {
+		a.log.Info("Redis admission restored by another instance")
+	}
+}
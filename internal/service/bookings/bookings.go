@@ -2,34 +2,90 @@ package bookings
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 
+	"github.com/samirwankhede/lewly-pgpyewj/internal/clock"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/domain"
 	kafkax "github.com/samirwankhede/lewly-pgpyewj/internal/kafka"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/lock"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/metrics"
 	redisx "github.com/samirwankhede/lewly-pgpyewj/internal/redis"
+	eventsService "github.com/samirwankhede/lewly-pgpyewj/internal/service/events"
 	mailer "github.com/samirwankhede/lewly-pgpyewj/internal/service/mailer"
+	paymentService "github.com/samirwankhede/lewly-pgpyewj/internal/service/payment"
+	waitlistService "github.com/samirwankhede/lewly-pgpyewj/internal/service/waitlist"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/store/bookings"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/store/events"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/invitations"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/store/users"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/store/waitlist"
 )
 
+// producerName identifies this service in Kafka message envelopes.
+const producerName = "evently-api"
+
+// defaultSeatHold is how long chosen seats stay held in Redis while a request claims them,
+// unless WithSeatHolds says otherwise.
+const defaultSeatHold = 30 * time.Second
+
+var (
+	ErrIdempotencyKeyReused = errors.New("idempotency key was already used for a different booking")
+	ErrBookingNotFound      = errors.New("booking not found")
+	ErrBookingNotPending    = errors.New("booking is not pending")
+	ErrSoldOut              = errors.New("event is sold out")
+	// Seat selection events take seat labels or a quantity, general admission events a quantity
+	ErrSeatsRequired         = errors.New("seats or a quantity are required for this event")
+	ErrQuantityRequired      = errors.New("quantity is required for this event")
+	ErrSeatsAndQuantity      = errors.New("send seats or a quantity, not both")
+	ErrSeatSelectionDisabled = errors.New("seat selection is disabled for this event")
+	ErrSeatsTaken            = errors.New("seats are no longer available")
+	// ErrSeatContention is returned when concurrent bookings kept taking the seats picked for a quantity booking
+	ErrSeatContention = errors.New("seats are being taken by other bookings; retry with the same Idempotency-Key")
+	// Private events can only be booked with the user's own invitation
+	ErrInvitationRequired    = errors.New("this event is invitation only")
+	ErrSandboxTesterOnly     = errors.New("this is a sandbox event; only its testers can book it")
+	ErrInvalidInvitationCode = errors.New("invalid invitation code")
+	// ErrLatencyBudget is returned when a booking request runs past its latency budget
+	ErrLatencyBudget = errors.New("booking took longer than its latency budget; retry with the same Idempotency-Key")
+)
+
 type BookingsService struct {
 	log        *zap.Logger
-	repo       *bookings.BookingsRepository
-	events     *events.EventsRepository
+	repo       bookingStore
+	events     eventStore
 	users      *users.UsersRepository
-	tokens     *redisx.TokenBucket
+	tokens     tokenStore
 	prod       *kafkax.Producer
-	wait       *waitlist.WaitlistRepository
+	wait       waitlistStore
 	mailer     *mailer.MailerService
 	paymentURL string
+	notify     *redisx.BookingEvents
+	promoter   *waitlistService.Promoter
+	admission  *Admission
+	phases     *eventsService.SalePhases
+	clock      clock.Clock
+	invites    *invitations.InvitationsRepository
+	payments   *paymentService.PaymentService
+	seatHold   time.Duration
+	cache      *eventCache
+	budget     time.Duration
+	locks      *lock.Locker
+	// transferTTL is how long a transfer's claim link works
+	transferTTL time.Duration
+	createHooks []CreateHook
 }
 
+// CreateHook is told about every booking a user makes, e.g. to notify integrations. Hooks run
+// in the background after the booking request returns and handle their own errors.
+type CreateHook func(ctx context.Context, bookingID string)
+
 type BookingRequest struct {
 	UserID         string   `json:"user_id"`
 	Seats          []string `json:"seats"`
@@ -37,18 +93,141 @@ type BookingRequest struct {
 }
 
 type BookingResponse struct {
-	BookingID string `json:"booking_id"`
-	Status    string `json:"status"`
-	Position  int    `json:"position,omitempty"`
+	BookingID string               `json:"booking_id"`
+	Status    domain.BookingStatus `json:"status"`
+	Position  int                  `json:"position,omitempty"`
+}
+
+func NewBookingsService(log *zap.Logger, repo *bookings.BookingsRepository, events *events.EventsRepository, users *users.UsersRepository, tokens *redisx.TokenBucket, prod *kafkax.Producer, wait *waitlist.WaitlistRepository, mailer *mailer.MailerService, paymentURL string, notify *redisx.BookingEvents, promoter *waitlistService.Promoter, admission *Admission) *BookingsService {
+	return &BookingsService{log: log, repo: repo, events: events, users: users, tokens: tokens, prod: prod, wait: wait, mailer: mailer, paymentURL: paymentURL, notify: notify, promoter: promoter, admission: admission, phases: eventsService.NewSalePhases(log, events, tokens), clock: clock.Real{}, seatHold: defaultSeatHold, cache: newEventCache(events, 0), transferTTL: defaultTransferTTL}
+}
+
+// OnCreate registers hook to run for every booking made from now on.
+func (s *BookingsService) OnCreate(hook CreateHook) *BookingsService {
+	s.createHooks = append(s.createHooks, hook)
+	return s
+}
+
+// created runs the create hooks for the new booking.
+func (s *BookingsService) created(bookingID string) {
+	for _, hook := range s.createHooks {
+		go hook(context.Background(), bookingID)
+	}
+}
+
+// WithClock replaces the wall clock used to reject bookings for events that have ended.
+func (s *BookingsService) WithClock(c clock.Clock) *BookingsService {
+	s.clock = c
+	return s
+}
+
+// WithInvitations lets invitees book private events. Without it private events can't be booked.
+func (s *BookingsService) WithInvitations(repo *invitations.InvitationsRepository) *BookingsService {
+	s.invites = repo
+	return s
+}
+
+// WithPayments voids a cancelled booking's uncaptured card authorization.
+func (s *BookingsService) WithPayments(payments *paymentService.PaymentService) *BookingsService {
+	s.payments = payments
+	return s
+}
+
+// WithSeatHolds sets how long chosen seats are held in Redis while a booking request claims
+// them; a hold a crashed request never released frees itself after ttl.
+func (s *BookingsService) WithSeatHolds(ttl time.Duration) *BookingsService {
+	if ttl > 0 {
+		s.seatHold = ttl
+	}
+	return s
+}
+
+// WithEventCache keeps the event and ticket limit a booking is validated against in memory
+// for ttl instead of reading them from Postgres on every request.
+func (s *BookingsService) WithEventCache(ttl time.Duration) *BookingsService {
+	s.cache = newEventCache(s.events, ttl)
+	return s
+}
+
+// WithLatencyBudget bounds how long Create may spend before the booking is inserted; a
+// request still short of the insert when budget runs out fails with ErrLatencyBudget.
+// 0 disables the budget.
+func (s *BookingsService) WithLatencyBudget(budget time.Duration) *BookingsService {
+	s.budget = budget
+	return s
+}
+
+// WithEventLocks serializes cancellations with the other processes changing an event's
+// capacity (payment timeouts, token resyncs, reconciliation).
+func (s *BookingsService) WithEventLocks(locks *lock.Locker) *BookingsService {
+	s.locks = locks
+	return s
+}
+
+// checkInvitation admits the user to a private event if they redeemed an invitation before
+// or pass the code of their own invitation, which is redeemed on the way. It returns the
+// HTTP status to fail with.
+func (s *BookingsService) checkInvitation(ctx context.Context, eventID, userID, code string) (int, error) {
+	if s.invites == nil {
+		return 403, ErrInvitationRequired
+	}
+	if code = strings.ToUpper(strings.TrimSpace(code)); code != "" {
+		inv, err := s.invites.GetByCode(ctx, eventID, code)
+		if err != nil {
+			return 500, err
+		}
+		if inv == nil || inv.UserID != userID {
+			return 403, ErrInvalidInvitationCode
+		}
+		if inv.RedeemedAt == nil {
+			if _, err := s.invites.Redeem(ctx, inv.ID); err != nil {
+				return 500, err
+			}
+		}
+		return 0, nil
+	}
+	invited, err := s.invites.HasRedeemed(ctx, eventID, userID)
+	if err != nil {
+		return 500, err
+	}
+	if !invited {
+		return 403, ErrInvitationRequired
+	}
+	return 0, nil
 }
 
-func NewBookingsService(log *zap.Logger, repo *bookings.BookingsRepository, events *events.EventsRepository, users *users.UsersRepository, tokens *redisx.TokenBucket, prod *kafkax.Producer, wait *waitlist.WaitlistRepository, mailer *mailer.MailerService, paymentURL string) *BookingsService {
-	return &BookingsService{log: log, repo: repo, events: events, users: users, tokens: tokens, prod: prod, wait: wait, mailer: mailer, paymentURL: paymentURL}
+// Create books seats for the user. Events with seat selection take the chosen seat labels;
+// general admission events take a quantity and are assigned seats once tokens are reserved.
+// source is the channel the request came through and is recorded on the booking. Private
+// events also need invitationCode unless the user already redeemed their invitation.
+func (s *BookingsService) Create(ctx context.Context, eventID string, userID string, source string, IdempotencyKey *string, seats []string, quantity int, invitationCode string) (*BookingResponse, int, error) {
+	start := time.Now()
+	defer func() { metrics.BookingCreateDuration.Observe(time.Since(start).Seconds()) }()
+	if s.budget <= 0 {
+		return s.create(ctx, eventID, userID, source, IdempotencyKey, seats, quantity, invitationCode)
+	}
+
+	budgetCtx, cancel := context.WithTimeout(ctx, s.budget)
+	defer cancel()
+	resp, code, err := s.create(budgetCtx, eventID, userID, source, IdempotencyKey, seats, quantity, invitationCode)
+	if err != nil && errors.Is(budgetCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		metrics.BookingRequestsTotal.WithLabelValues("over_budget").Inc()
+		s.log.Warn("Booking request exceeded its latency budget", zap.Error(err), zap.String("event_id", eventID), zap.Duration("budget", s.budget))
+		return nil, 503, ErrLatencyBudget
+	}
+	return resp, code, err
 }
 
-func (s *BookingsService) Create(ctx context.Context, eventID string, userID string, IdempotencyKey *string, seats []string) (*BookingResponse, int, error) {
+// create is Create under the latency budget. Only the Redis reservations and the insert of
+// the pending booking are meant to run here; the event and its ticket limit come from the
+// event cache, and the finalize message goes to Kafka through the outbox.
+func (s *BookingsService) create(ctx context.Context, eventID string, userID string, source string, IdempotencyKey *string, seats []string, quantity int, invitationCode string) (*BookingResponse, int, error) {
+	// Releases and the insert run to completion even once the budget is spent, so a
+	// reservation is never leaked and a committed booking is never reported as failed
+	detached := context.WithoutCancel(ctx)
+
 	// Check if event exists and is not expired
-	event, err := s.events.Get(ctx, eventID)
+	event, limit, phases, err := s.cache.get(ctx, eventID)
 	if err != nil {
 		return nil, 500, err
 	}
@@ -56,85 +235,398 @@ func (s *BookingsService) Create(ctx context.Context, eventID string, userID str
 		return nil, 404, errors.New("event not found")
 	}
 
-	// Check if event is expired
-	if event.EndTime.Before(time.Now()) {
-		// Update event status to expired
-		s.events.UpdateStatus(ctx, eventID, "expired")
-		return nil, 400, errors.New("event is expired")
+	if err := eventsService.RequireOpen(event, s.clock.Now()); err != nil {
+		return nil, 409, err
+	}
+
+	if event.Sandbox {
+		ok, err := s.events.CanRehearse(ctx, eventID, userID)
+		if err != nil {
+			return nil, 500, err
+		}
+		if !ok {
+			return nil, 403, ErrSandboxTesterOnly
+		}
+	}
+	if event.Visibility == events.VisibilityPrivate {
+		if code, err := s.checkInvitation(ctx, eventID, userID, invitationCode); err != nil {
+			return nil, code, err
+		}
+	}
+
+	// Without seat labels the booking is for quantity seats, which are picked for it
+	count := len(seats)
+	switch {
+	case count > 0 && !event.SeatSelectionEnabled:
+		return nil, 400, ErrSeatSelectionDisabled
+	case count > 0 && quantity > 0:
+		return nil, 400, ErrSeatsAndQuantity
+	case count == 0 && quantity <= 0 && event.SeatSelectionEnabled:
+		return nil, 400, ErrSeatsRequired
+	case count == 0 && quantity <= 0:
+		return nil, 400, ErrQuantityRequired
+	case count == 0:
+		count = quantity
 	}
 
 	// Check if user is trying to book more than maximum allowed
-	if len(seats) > event.MaximumTicketsPerBooking {
+	if count > event.MaximumTicketsPerBooking {
 		return nil, 400, fmt.Errorf("cannot book more than %d tickets", event.MaximumTicketsPerBooking)
 	}
 
 	// Idempotency check
 	if IdempotencyKey != nil && *IdempotencyKey != "" {
 		if b, err := s.repo.GetByIdempotency(ctx, *IdempotencyKey); err == nil && b != nil {
+			// Keys come from clients, so never replay someone else's booking
+			if b.UserID != userID || b.EventID != eventID {
+				return nil, 409, ErrIdempotencyKeyReused
+			}
 			return &BookingResponse{BookingID: b.ID, Status: b.Status}, 200, nil
 		}
 	}
 
+	if err := s.checkStranded(ctx, event, seats); err != nil {
+		if errors.Is(err, ErrStrandedSeat) {
+			metrics.BookingRequestsTotal.WithLabelValues("stranded_seat").Inc()
+			return nil, 409, err
+		}
+		return nil, 500, err
+	}
+
+	// Events sold in phases only sell while a phase is open, and from its quantity
+	phase, err := eventsService.CurrentPhase(phases, s.clock.Now())
+	if err != nil {
+		metrics.BookingRequestsTotal.WithLabelValues("not_on_sale").Inc()
+		return nil, 409, err
+	}
+	var phaseID *string
+	if phase != nil {
+		phaseID = &phase.ID
+	}
+
+	// Postgres admission locks the event row, so it isn't held to the budget
+	if s.admission.Degraded() {
+		return s.createDegraded(detached, event, userID, source, IdempotencyKey, seats, count, phaseID)
+	}
+
+	// Chosen seats are held until the pending booking claiming them is in Postgres, so two
+	// requests for the same seat can't both pass the token check and insert a booking
+	release, err := s.holdSeats(ctx, eventID, seats)
+	if err != nil {
+		if errors.Is(err, ErrSeatsTaken) {
+			metrics.BookingRequestsTotal.WithLabelValues("seat_taken").Inc()
+			return nil, 409, err
+		}
+		if s.admission.Trip(err) {
+			return s.createDegraded(detached, event, userID, source, IdempotencyKey, seats, count, phaseID)
+		}
+		return nil, 500, err
+	}
+	defer release()
+	if err := s.checkTaken(ctx, eventID, seats); err != nil {
+		if errors.Is(err, ErrSeatsTaken) {
+			metrics.BookingRequestsTotal.WithLabelValues("seat_taken").Inc()
+			return nil, 409, err
+		}
+		return nil, 500, err
+	}
+
+	// Rolling per-user limit across bookings, so a buyer can't get around the per-booking cap
+	limitID := uuid.NewString()
+	if limit != nil {
+		ok, used, err := s.tokens.ReserveUserTickets(ctx, limit.Scope, userID, limitID, count, limit.MaxTickets, limit.Window)
+		if err != nil {
+			if s.admission.Trip(err) {
+				return s.createDegraded(detached, event, userID, source, IdempotencyKey, seats, count, phaseID)
+			}
+			return nil, 500, err
+		}
+		if !ok {
+			metrics.BookingRequestsTotal.WithLabelValues("user_limit").Inc()
+			return nil, 429, fmt.Errorf("ticket limit reached: %d of %d tickets already booked in the last %s", used, limit.MaxTickets, limit.Window)
+		}
+	}
+	releaseLimit := func() {
+		if limit == nil {
+			return
+		}
+		if err := s.tokens.ReleaseUserTickets(detached, limit.Scope, userID, limitID, count); err != nil {
+			s.log.Error("Failed to release user ticket limit", zap.Error(err), zap.String("user_id", userID))
+		}
+	}
+
+	// The phase's tokens come first: a phase that is sold out turns buyers away while the
+	// event still has seats for later phases
+	releasePhase := func() {}
+	if phase != nil {
+		ok, err := s.phases.Reserve(ctx, phase, count)
+		if err != nil {
+			releaseLimit()
+			if s.admission.Trip(err) {
+				return s.createDegraded(detached, event, userID, source, IdempotencyKey, seats, count, phaseID)
+			}
+			return nil, 500, err
+		}
+		if !ok {
+			releaseLimit()
+			metrics.BookingRequestsTotal.WithLabelValues("phase_sold_out").Inc()
+			return nil, 409, eventsService.ErrPhaseSoldOut
+		}
+		releasePhase = func() {
+			if err := s.phases.Release(detached, eventID, phase.ID, count); err != nil {
+				s.log.Error("Failed to release sale phase tokens", zap.Error(err), zap.String("event_id", eventID), zap.String("phase_id", phase.ID))
+			}
+		}
+	}
+
 	// Reserve tokens for the number of seats requested
-	ok, err := s.tokens.Reserve(ctx, eventID, len(seats))
+	ok, err := s.tokens.Reserve(ctx, eventID, count)
 	if err != nil {
+		releasePhase()
+		releaseLimit()
+		if s.admission.Trip(err) {
+			return s.createDegraded(detached, event, userID, source, IdempotencyKey, seats, count, phaseID)
+		}
 		return nil, 500, err
 	}
 
 	if ok {
-		// Store seats in booking
-		seatsJSON, _ := json.Marshal(seats)
-		b, err := s.repo.CreatePending(ctx, userID, eventID, IdempotencyKey, seatsJSON)
+		var b *bookings.Booking
+		var created bool
+		if len(seats) == 0 {
+			b, created, err = s.repo.CreatePendingAssigned(detached, userID, eventID, IdempotencyKey, source, phaseID, count, seatPicker(event), s.finalizeMessage(IdempotencyKey))
+		} else {
+			b, created, err = s.repo.CreatePending(detached, userID, eventID, IdempotencyKey, seats, source, phaseID, s.finalizeMessage(IdempotencyKey))
+		}
+		if err != nil || !created {
+			_ = s.tokens.Release(detached, eventID, count)
+			releasePhase()
+			releaseLimit()
+		}
 		if err != nil {
-			return nil, 500, err
+			return nil, seatErrorCode(err), seatError(err)
+		}
+		if b == nil {
+			// Tokens and the seat map disagree; redis_rebuild or a token resync fixes the bucket
+			s.log.Warn("Tokens reserved but not enough seats to assign", zap.String("event_id", eventID), zap.Int("wanted", count))
+			return nil, 409, ErrSoldOut
+		}
+		if !created {
+			// A concurrent request with the same key won the insert; this one booked nothing
+			metrics.BookingRequestsTotal.WithLabelValues("idempotent_replay").Inc()
+			if b.UserID != userID {
+				return nil, 409, ErrIdempotencyKeyReused
+			}
+			return &BookingResponse{BookingID: b.ID, Status: b.Status}, 200, nil
+		}
+
+		s.created(b.ID)
+		return &BookingResponse{BookingID: b.ID, Status: domain.BookingPending}, 202, nil
+	}
+
+	// Fallback: Auto waitlist. Waitlisted requests don't count against the user's limit
+	releasePhase()
+	releaseLimit()
+	if !event.WaitlistEnabled {
+		metrics.BookingRequestsTotal.WithLabelValues("sold_out").Inc()
+		return nil, 409, ErrSoldOut
+	}
+	position, err := s.wait.Add(ctx, eventID, userID)
+	if err == waitlist.ErrClosed {
+		// The event has started: there's nothing left to wait for
+		metrics.BookingRequestsTotal.WithLabelValues("sold_out").Inc()
+		return nil, 409, ErrSoldOut
+	}
+	if err != nil {
+		return nil, 500, err
+	}
+
+	return &BookingResponse{Status: domain.BookingWaitlisted, Position: position}, 200, nil
+}
+
+// createDegraded admits a booking while Redis is failing: the capacity check and insert
+// happen under a row lock in Postgres, and the Redis-backed per-user ticket limit is not
+// enforced. Tokens are rebuilt from Postgres when Redis comes back.
+func (s *BookingsService) createDegraded(ctx context.Context, event *events.Event, userID string, source string, IdempotencyKey *string, seats []string, count int, phaseID *string) (*BookingResponse, int, error) {
+	var resp *BookingResponse
+	code := 202
+	err := s.admission.Fallback(func() error {
+		// Without seats, CreatePendingIfAvailable picks them once it has admitted the booking
+		b, created, err := s.repo.CreatePendingIfAvailable(ctx, userID, event.ID, IdempotencyKey, seats, source, phaseID, count, seatPicker(event), s.finalizeMessage(IdempotencyKey))
+		if errors.Is(err, bookings.ErrSalePhaseSoldOut) {
+			metrics.BookingRequestsTotal.WithLabelValues("phase_sold_out").Inc()
+			code = 409
+			return eventsService.ErrPhaseSoldOut
+		}
+		if err != nil {
+			code = seatErrorCode(err)
+			return seatError(err)
+		}
+		switch {
+		case b != nil && !created:
+			metrics.BookingRequestsTotal.WithLabelValues("idempotent_replay").Inc()
+			if b.UserID != userID {
+				code = 409
+				return ErrIdempotencyKeyReused
+			}
+			code = 200
+			resp = &BookingResponse{BookingID: b.ID, Status: b.Status}
+		case b != nil:
+			metrics.BookingRequestsTotal.WithLabelValues("fallback_admitted").Inc()
+			s.created(b.ID)
+			resp = &BookingResponse{BookingID: b.ID, Status: domain.BookingPending}
+		case !event.WaitlistEnabled:
+			metrics.BookingRequestsTotal.WithLabelValues("sold_out").Inc()
+			code = 409
+			return ErrSoldOut
+		default:
+			position, err := s.wait.Add(ctx, event.ID, userID)
+			if err == waitlist.ErrClosed {
+				metrics.BookingRequestsTotal.WithLabelValues("sold_out").Inc()
+				code = 409
+				return ErrSoldOut
+			}
+			if err != nil {
+				code = 500
+				return err
+			}
+			code = 200
+			resp = &BookingResponse{Status: domain.BookingWaitlisted, Position: position}
 		}
+		return nil
+	})
+	switch err {
+	case nil:
+		return resp, code, nil
+	case ErrAdmissionThrottled:
+		metrics.BookingRequestsTotal.WithLabelValues("fallback_throttled").Inc()
+		return nil, 429, err
+	case ErrAdmissionRecovering:
+		return nil, 503, err
+	}
+	return nil, code, err
+}
 
+// finalizeMessage announces a new pending booking to the worker. The message goes through
+// the outbox, written with the booking, so a booking is never left without one when Kafka
+// is unreachable; the worker's relay publishes it.
+func (s *BookingsService) finalizeMessage(IdempotencyKey *string) bookings.Announce {
+	return func(b *bookings.Booking) (*store.OutboxMessage, error) {
 		payload := map[string]any{
-			"type":            "finalize_booking",
 			"booking_id":      b.ID,
-			"event_id":        eventID,
-			"user_id":         userID,
-			"seats":           seats,
+			"event_id":        b.EventID,
+			"user_id":         b.UserID,
+			"seats":           b.Seats,
 			"idempotency_key": IdempotencyKey,
 		}
-		by, _ := json.Marshal(payload)
-		if err := s.prod.Publish(ctx, []byte(eventID), by); err != nil {
-			s.log.Error("kafka publish error", zap.Error(err))
+		env, err := kafkax.NewEnvelope(kafkax.TypeFinalizeBooking, producerName, payload)
+		if err != nil {
+			return nil, err
+		}
+		value, err := kafkax.JSONCodec{}.Marshal(env)
+		if err != nil {
+			return nil, err
 		}
-		return &BookingResponse{BookingID: b.ID, Status: "pending"}, 202, nil
+		return &store.OutboxMessage{Topic: s.prod.Topic(), Key: b.EventID, Envelope: value}, nil
 	}
+}
 
-	// Fallback: Auto waitlist
-	position, err := s.wait.Add(ctx, eventID, userID)
+var ErrValidation = errors.New("validation error")
+
+// Watch streams status transitions of the user's booking until ctx is done. It returns the
+// booking's current state first so the caller can tell whether anything is left to wait for.
+func (s *BookingsService) Watch(ctx context.Context, bookingID, userID string) (*bookings.Booking, <-chan redisx.BookingEvent, error) {
+	b, err := s.repo.GetByID(ctx, bookingID)
 	if err != nil {
-		return nil, 500, err
+		return nil, nil, err
+	}
+	if b == nil || b.UserID != userID {
+		return nil, nil, ErrBookingNotFound
+	}
+	if s.notify == nil {
+		return b, nil, nil
 	}
+	events, err := s.notify.Subscribe(ctx, bookingID)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Re-read after subscribing so a transition between the two reads isn't lost
+	if b, err = s.repo.GetByID(ctx, bookingID); err != nil || b == nil {
+		return nil, nil, ErrBookingNotFound
+	}
+	return b, events, nil
+}
 
-	return &BookingResponse{Status: "waitlisted", Position: position}, 200, nil
+// GetBooking returns any booking by ID, for operators; nil if it doesn't exist.
+func (s *BookingsService) GetBooking(ctx context.Context, bookingID string) (*bookings.Booking, error) {
+	return s.repo.GetByID(ctx, bookingID)
 }
 
-var ErrValidation = errors.New("validation error")
+// RequeueFinalize writes the finalize message of a pending booking to the outbox again, for
+// bookings stuck pending because the original message was lost or failed; the relay
+// publishes it like any other.
+func (s *BookingsService) RequeueFinalize(ctx context.Context, bookingID string) (*bookings.Booking, error) {
+	b, err := s.repo.Requeue(ctx, bookingID, func(b *bookings.Booking) (*store.OutboxMessage, error) {
+		var key *string
+		if b.IdempotencyKey != "" {
+			key = &b.IdempotencyKey
+		}
+		return s.finalizeMessage(key)(b)
+	})
+	if errors.Is(err, bookings.ErrNotPending) {
+		return nil, ErrBookingNotPending
+	}
+	if err != nil {
+		return nil, err
+	}
+	if b == nil {
+		return nil, ErrBookingNotFound
+	}
+	s.log.Info("Requeued booking finalization", zap.String("booking_id", b.ID))
+	return b, nil
+}
 
 func (s *BookingsService) Cancel(ctx context.Context, bookingID string) (map[string]any, int, error) {
-	b, wasBooked, err := s.repo.CancelBookingTx(ctx, bookingID)
+	current, err := s.repo.GetByID(ctx, bookingID)
 	if err != nil {
-		return nil, 409, err
+		return nil, 500, err
+	}
+	if current == nil {
+		return nil, 404, ErrBookingNotFound
 	}
 
-	// release tokens when a booked reservation is cancelled
-	if wasBooked {
-		// Get the number of seats from the booking
-		var seats []string
-		if len(b.Seats) > 0 {
-			json.Unmarshal(b.Seats, &seats)
+	// The cancellation and the seats it frees are one step for anyone else adjusting the
+	// event's capacity
+	var b *bookings.Booking
+	var wasBooked bool
+	err = s.locks.Event(ctx, "cancel", current.EventID, func(ctx context.Context) error {
+		var err error
+		b, wasBooked, err = s.repo.CancelBookingTx(ctx, bookingID)
+		if err != nil || !wasBooked {
+			return err
 		}
-		seatCount := len(seats)
-		if seatCount == 0 {
-			seatCount = 1 // fallback
+		s.freeSeats(ctx, b)
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, lock.ErrTimeout) {
+			return nil, 503, err
 		}
+		return nil, 409, err
+	}
+	// The card was only authorized, so releasing the hold is the whole refund
+	if b.PaymentStatus == "authorized" && s.payments != nil {
+		if _, err := s.payments.VoidBooking(ctx, bookingID); err != nil {
+			s.log.Error("Failed to void authorization", zap.Error(err), zap.String("booking_id", bookingID))
+		}
+	}
+	if s.notify != nil {
+		if err := s.notify.Publish(ctx, redisx.BookingEvent{Type: redisx.BookingEventCancelled, BookingID: bookingID, Status: domain.BookingCancelled}); err != nil {
+			s.log.Error("Failed to publish booking event", zap.Error(err), zap.String("booking_id", bookingID))
+		}
+	}
 
-		_ = s.tokens.Release(ctx, b.EventID, seatCount)
-
+	if wasBooked {
 		event, err := s.events.Get(ctx, b.EventID)
 		if err != nil {
 			return nil, 409, err
@@ -147,59 +639,82 @@ func (s *BookingsService) Cancel(ctx context.Context, bookingID string) (map[str
 				return nil, 409, err
 			}
 			paymentLink := fmt.Sprintf("%s/v1/payment/refund?booking_id=%s", s.paymentURL, bookingID)
-			s.mailer.SendCancellationEmail(user.Email, event.CancellationFee, paymentLink)
-		}
-
-		// Promote next person from waitlist
-		if s.wait != nil {
-			if id, userID, _, err := s.wait.NextActive(ctx, b.EventID); err == nil && userID != "" {
-				// Get seats from the cancelled booking
-				var seats []string
-				if len(b.Seats) > 0 {
-					json.Unmarshal(b.Seats, &seats)
-				}
-				seatsJSON, _ := json.Marshal(seats)
-				if pb, cerr := s.repo.CreatePending(ctx, userID, b.EventID, nil, seatsJSON); cerr == nil {
-					payload := map[string]any{
-						"type":            "finalize_booking",
-						"booking_id":      pb.ID,
-						"event_id":        b.EventID,
-						"user_id":         userID,
-						"seats":           seats,
-						"idempotency_key": pb.IdempotencyKey,
-					}
-					by, _ := json.Marshal(payload)
-					_ = s.prod.Publish(ctx, []byte(b.EventID), by)
-					_ = s.wait.Remove(ctx, id)
-
-					// Send waitlist promotion email
-					if s.mailer != nil {
-						user, err := s.users.GetByID(ctx, userID)
-						if err != nil {
-							return nil, 409, err
-						}
-						s.mailer.SendWaitlistPromotionEmail(user.Email, event.Name)
-					}
-				}
-			}
+			s.mailer.For(event.OrganizerID).About(b.ID).SendCancellationEmail(user.Email, event.CancellationFee, paymentLink)
 		}
 	}
 	return map[string]any{"booking_id": b.ID, "status": b.Status}, 200, nil
 }
 
+// freeSeats gives a cancelled booking's seats to the head of the waitlist, or back to the
+// pool, and its sale phase's, if nobody is waiting. A promoted booking is sold in the
+// cancelled booking's phase.
+func (s *BookingsService) freeSeats(ctx context.Context, b *bookings.Booking) {
+	s.release(ctx, b, b.Seats, false)
+}
+
+// release frees seats of b as freeSeats does; partial seats are given up by a booking that
+// keeps the rest, and are promoted apart from the booking's own promotion.
+func (s *BookingsService) release(ctx context.Context, b *bookings.Booking, seats domain.Seats, partial bool) {
+	promoted := false
+	if s.promoter != nil {
+		promote := s.promoter.Promote
+		if partial {
+			promote = s.promoter.PromoteSeats
+		}
+		promo, err := promote(ctx, b.EventID, b.ID, seats)
+		if err != nil {
+			s.log.Error("Failed to promote waitlist user", zap.Error(err), zap.String("booking_id", b.ID))
+		}
+		promoted = promo != nil
+	}
+	if !promoted {
+		seatCount := len(seats)
+		if seatCount == 0 {
+			seatCount = 1 // fallback
+		}
+		_ = s.tokens.Release(ctx, b.EventID, seatCount)
+		if b.SalePhaseID != nil {
+			if err := s.phases.Release(ctx, b.EventID, *b.SalePhaseID, seatCount); err != nil {
+				s.log.Error("Failed to release sale phase tokens", zap.Error(err), zap.String("booking_id", b.ID))
+			}
+		}
+	}
+}
+
 func (s *BookingsService) GetBookingStatus(ctx context.Context, bookingID string) (string, error) {
 	return s.repo.GetBookingStatus(ctx, bookingID)
 }
 
+// MaxBatchStatusIDs caps how many bookings one GetBookingStatuses call may ask about.
+const MaxBatchStatusIDs = 100
+
+// GetBookingStatuses looks up several of the user's bookings in one query. IDs that don't
+// exist or belong to someone else are returned in notFound.
+func (s *BookingsService) GetBookingStatuses(ctx context.Context, userID string, ids []string) (map[string]string, []string, error) {
+	if len(ids) == 0 || len(ids) > MaxBatchStatusIDs {
+		return nil, nil, ErrValidation
+	}
+	statuses, err := s.repo.GetStatuses(ctx, userID, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	notFound := []string{}
+	for _, id := range ids {
+		if _, ok := statuses[id]; !ok {
+			notFound = append(notFound, id)
+		}
+	}
+	return statuses, notFound, nil
+}
+
 func (s *BookingsService) GetAvailableSeats(ctx context.Context, eventID string) ([]string, error) {
 	return s.events.GetAvailableSeats(ctx, eventID)
 }
 
-func (s *BookingsService) ListUserBookings(ctx context.Context, userID string, limit, offset int) ([]*bookings.Booking, error) {
-	return s.repo.ListByUser(ctx, userID, limit, offset)
+func (s *BookingsService) ListUserBookings(ctx context.Context, userID string, limit, offset int, after *bookings.UserPosition) ([]*bookings.Booking, error) {
+	return s.repo.ListByUser(ctx, userID, limit, offset, after)
 }
 
 func (s *BookingsService) FinalizeBooking(ctx context.Context, bookingID string, seats []string, amountPaid float64) error {
-	seatsJSON, _ := json.Marshal(seats)
-	return s.repo.FinalizeBooking(ctx, bookingID, seatsJSON, amountPaid)
+	return s.repo.FinalizeBooking(ctx, bookingID, seats, amountPaid, "paid")
 }
@@ -0,0 +1,189 @@
+package bookings
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/clock"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/domain"
+	kafkax "github.com/samirwankhede/lewly-pgpyewj/internal/kafka"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/bookings"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/events"
+)
+
+// Round trips the fakes wait out per call, roughly a same-zone Postgres query and Redis
+// command, so the benchmark shows what each saved call is worth.
+const (
+	pgRoundTrip    = 300 * time.Microsecond
+	redisRoundTrip = 100 * time.Microsecond
+)
+
+// roundTrips counts the fakes' calls and waits out their round trips.
+type roundTrips struct {
+	pg, redis atomic.Int64
+}
+
+func (r *roundTrips) postgres() {
+	r.pg.Add(1)
+	wait(pgRoundTrip)
+}
+
+func (r *roundTrips) redisCall() {
+	r.redis.Add(1)
+	wait(redisRoundTrip)
+}
+
+// wait spins for d; time.Sleep rounds sub-millisecond waits up to the timer's granularity.
+func wait(d time.Duration) {
+	for start := time.Now(); time.Since(start) < d; {
+	}
+}
+
+// fakeEvents serves one seat selection event with a per-user ticket limit. Methods the
+// booking path doesn't call are left to the embedded nil interface.
+type fakeEvents struct {
+	eventStore
+	rt    *roundTrips
+	event *events.Event
+	limit *events.UserTicketLimit
+}
+
+func (f *fakeEvents) Get(ctx context.Context, id string) (*events.Event, error) {
+	f.rt.postgres()
+	return f.event, nil
+}
+
+func (f *fakeEvents) GetUserTicketLimit(ctx context.Context, eventID string) (*events.UserTicketLimit, error) {
+	f.rt.postgres()
+	return f.limit, nil
+}
+
+func (f *fakeEvents) SalePhases(ctx context.Context, eventID string) ([]*events.SalePhase, error) {
+	f.rt.postgres()
+	return nil, nil
+}
+
+func (f *fakeEvents) TakenSeats(ctx context.Context, eventID string, labels []string) ([]string, error) {
+	f.rt.postgres()
+	return nil, nil
+}
+
+// fakeBookings inserts every pending booking, building its outbox message as the real
+// insert does.
+type fakeBookings struct {
+	bookingStore
+	rt *roundTrips
+}
+
+func (f *fakeBookings) GetByIdempotency(ctx context.Context, key string) (*bookings.Booking, error) {
+	f.rt.postgres()
+	return nil, nil
+}
+
+func (f *fakeBookings) CreatePending(ctx context.Context, userID string, eventID string, idempotencyKey *string, seats domain.Seats, source string, phaseID *string, announce bookings.Announce) (*bookings.Booking, bool, error) {
+	f.rt.postgres()
+	b := &bookings.Booking{ID: uuid.NewString(), UserID: userID, EventID: eventID, Status: domain.BookingPending, Seats: seats, Source: source}
+	if _, err := announce(b); err != nil {
+		return nil, false, err
+	}
+	return b, true, nil
+}
+
+// fakeTokens always has tokens and seats to give.
+type fakeTokens struct {
+	tokenStore
+	rt *roundTrips
+}
+
+func (f *fakeTokens) Reserve(ctx context.Context, eventID string, n int) (bool, error) {
+	f.rt.redisCall()
+	return true, nil
+}
+
+func (f *fakeTokens) Release(ctx context.Context, eventID string, n int) error {
+	f.rt.redisCall()
+	return nil
+}
+
+func (f *fakeTokens) ReserveUserTickets(ctx context.Context, scope, userID, id string, n, limit int, window time.Duration) (bool, int, error) {
+	f.rt.redisCall()
+	return true, n, nil
+}
+
+func (f *fakeTokens) ReleaseUserTickets(ctx context.Context, scope, userID, id string, n int) error {
+	f.rt.redisCall()
+	return nil
+}
+
+func (f *fakeTokens) HoldSeats(ctx context.Context, eventID, holder string, seats []string, ttl time.Duration) ([]string, error) {
+	f.rt.redisCall()
+	return nil, nil
+}
+
+func (f *fakeTokens) ReleaseSeats(ctx context.Context, eventID, holder string, seats []string) error {
+	f.rt.redisCall()
+	return nil
+}
+
+// BenchmarkBookingsCreate books two chosen seats through Create against in-memory fakes of
+// Postgres and Redis, with and without the event cache. Besides ns/op it reports the p50 and
+// p99 request latency and the Postgres and Redis round trips each booking took.
+func BenchmarkBookingsCreate(b *testing.B) {
+	for _, ttl := range []time.Duration{0, 30 * time.Second} {
+		b.Run(fmt.Sprintf("event_cache=%s", ttl), func(b *testing.B) {
+			rt := &roundTrips{}
+			event := &events.Event{
+				ID:                       uuid.NewString(),
+				Name:                     "Benchmark",
+				Capacity:                 1_000_000,
+				MaximumTicketsPerBooking: 4,
+				SeatSelectionEnabled:     true,
+				WaitlistEnabled:          true,
+				Status:                   domain.EventUpcoming,
+				StartTime:                time.Now().Add(24 * time.Hour),
+				EndTime:                  time.Now().Add(27 * time.Hour),
+			}
+			evs := &fakeEvents{rt: rt, event: event, limit: &events.UserTicketLimit{Scope: event.ID, MaxTickets: 8, Window: time.Hour}}
+			s := &BookingsService{
+				log:      zap.NewNop(),
+				repo:     &fakeBookings{rt: rt},
+				events:   evs,
+				tokens:   &fakeTokens{rt: rt},
+				prod:     kafkax.NewProducer([]string{"localhost:9092"}, "bookings"),
+				clock:    clock.Real{},
+				seatHold: defaultSeatHold,
+				cache:    newEventCache(evs, ttl),
+			}
+			ctx := context.Background()
+			userID := uuid.NewString()
+			durations := make([]time.Duration, 0, b.N)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				key := uuid.NewString()
+				seats := []string{fmt.Sprintf("A%d", 2*i), fmt.Sprintf("A%d", 2*i+1)}
+				start := time.Now()
+				_, code, err := s.Create(ctx, event.ID, userID, "web", &key, seats, 0, "")
+				durations = append(durations, time.Since(start))
+				if err != nil || code != 202 {
+					b.Fatalf("Create = %d, %v", code, err)
+				}
+			}
+			b.StopTimer()
+
+			sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+			b.ReportMetric(float64(durations[len(durations)/2].Microseconds()), "p50-µs")
+			b.ReportMetric(float64(durations[len(durations)*99/100].Microseconds()), "p99-µs")
+			b.ReportMetric(float64(rt.pg.Load())/float64(b.N), "pg-round-trips/op")
+			b.ReportMetric(float64(rt.redis.Load())/float64(b.N), "redis-round-trips/op")
+		})
+	}
+}
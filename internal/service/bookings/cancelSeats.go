@@ -0,0 +1,125 @@
+package bookings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/domain"
+	redisx "github.com/samirwankhede/lewly-pgpyewj/internal/redis"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/refunds"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/bookings"
+)
+
+// ErrAllSeatsCancelled refuses a seat cancellation that names every seat of the booking;
+// the booking itself is cancelled instead.
+var ErrAllSeatsCancelled = errors.New("cancel the booking to give up all of its seats")
+
+// SeatCancellation is a booking after its owner cancelled some of its seats, with the refund
+// of those seats when it was paid.
+type SeatCancellation struct {
+	Booking *bookings.Booking  `json:"booking"`
+	Refund  *refunds.Breakdown `json:"refund,omitempty"`
+}
+
+// CancelSeats cancels some of the user's own booked booking's seats and keeps the rest. The
+// booking is repriced at the seats it keeps, and the seats it gives up go to the waitlist or
+// back on sale, under the same event lock as a cancellation. A paid booking has them
+// refunded through the payment service, each seat what it paid less its share of the
+// cancellation fee; a booking yet to pay just owes less.
+func (s *BookingsService) CancelSeats(ctx context.Context, bookingID, userID string, seats []string) (*SeatCancellation, error) {
+	if len(seats) == 0 {
+		return nil, ErrValidation
+	}
+	seen := make(map[string]bool, len(seats))
+	for _, label := range seats {
+		if label == "" || seen[label] {
+			return nil, ErrValidation
+		}
+		seen[label] = true
+	}
+
+	b, err := s.repo.GetByID(ctx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil || b.UserID != userID {
+		return nil, ErrBookingNotFound
+	}
+	if b.Status != domain.BookingBooked {
+		return nil, ErrBookingNotActive
+	}
+	var unknown, keep []string
+	for _, label := range seats {
+		if !slices.Contains(b.Seats, label) {
+			unknown = append(unknown, label)
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownSeats, strings.Join(unknown, ", "))
+	}
+	for _, label := range b.Seats {
+		if !seen[label] {
+			keep = append(keep, label)
+		}
+	}
+	if len(keep) == 0 {
+		return nil, ErrAllSeatsCancelled
+	}
+	if b.PaymentStatus == "authorized" {
+		return nil, ErrAuthorizedPriceChange
+	}
+
+	event, err := s.events.Get(ctx, b.EventID)
+	if err != nil {
+		return nil, err
+	}
+	if event == nil {
+		return nil, errors.New("event not found")
+	}
+	amount, err := s.events.BookingAmount(ctx, event, b.ID, keep)
+	if err != nil {
+		return nil, err
+	}
+
+	drop := func(ctx context.Context, amountPaid *float64) error {
+		return s.locks.Event(ctx, "cancel", b.EventID, func(ctx context.Context) error {
+			res, err := s.repo.ChangeSeats(ctx, bookingID, keep, bookings.SeatChange{AmountDue: amount, AmountPaid: amountPaid})
+			if err := seatMoveError(res, err); err != nil {
+				return err
+			}
+			s.release(ctx, b, seats, true)
+			return nil
+		})
+	}
+	result := &SeatCancellation{}
+	if b.PaymentStatus == "paid" && s.payments != nil {
+		result.Refund, err = s.payments.RefundSeats(ctx, b, seats, func(ctx context.Context, amountPaid float64) error {
+			return drop(ctx, &amountPaid)
+		})
+	} else {
+		err = drop(ctx, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	refunded := 0.0
+	if result.Refund != nil {
+		refunded = result.Refund.Refund
+	}
+	s.log.Info("Booking seats cancelled", zap.String("booking_id", bookingID), zap.Strings("seats", seats), zap.Float64("refunded", refunded))
+
+	if s.notify != nil {
+		if err := s.notify.Publish(ctx, redisx.BookingEvent{Type: redisx.BookingEventReseated, BookingID: bookingID, Status: b.Status}); err != nil {
+			s.log.Error("Failed to publish booking event", zap.Error(err), zap.String("booking_id", bookingID))
+		}
+	}
+	if result.Booking, err = s.repo.GetByID(ctx, bookingID); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
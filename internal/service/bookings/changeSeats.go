@@ -0,0 +1,131 @@
+package bookings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+
+	redisx "github.com/samirwankhede/lewly-pgpyewj/internal/redis"
+	eventsService "github.com/samirwankhede/lewly-pgpyewj/internal/service/events"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/bookings"
+)
+
+// ErrAuthorizedPriceChange refuses a seat change that would change what a manual-capture
+// booking's card is authorized for; the authorization can't be resized.
+var ErrAuthorizedPriceChange = errors.New("booking's card is authorized for its current price; only seats at the same price can be chosen before it is charged")
+
+// SeatChangeResult is a booking after its owner changed seats, with any money moved.
+type SeatChangeResult struct {
+	Booking  *bookings.Booking `json:"booking"`
+	Charged  float64           `json:"charged"`
+	Refunded float64           `json:"refunded"`
+}
+
+// ChangeSeats moves the user's own pending or booked booking to other seats of a seat
+// selection event, as many as it has. The new seats are held in Redis while one transaction
+// checks them, frees the old ones and takes the new ones, so the swap is all or nothing.
+// The booking is repriced at the new seats' tiers: a pending booking is simply charged the
+// new amount at payment, while a paid one is charged the difference to paymentID or refunded
+// it through the payment service.
+func (s *BookingsService) ChangeSeats(ctx context.Context, bookingID, userID string, seats []string, paymentID string) (*SeatChangeResult, error) {
+	if len(seats) == 0 {
+		return nil, ErrValidation
+	}
+	seen := make(map[string]bool, len(seats))
+	for _, label := range seats {
+		if label == "" || seen[label] {
+			return nil, ErrValidation
+		}
+		seen[label] = true
+	}
+
+	b, err := s.repo.GetByID(ctx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil || b.UserID != userID {
+		return nil, ErrBookingNotFound
+	}
+	if !b.Status.Active() {
+		return nil, ErrBookingNotActive
+	}
+	if len(b.Seats) != len(seats) {
+		return nil, ErrSeatCountChanged
+	}
+
+	event, err := s.events.Get(ctx, b.EventID)
+	if err != nil {
+		return nil, err
+	}
+	if event == nil {
+		return nil, errors.New("event not found")
+	}
+	if err := eventsService.RequireOpen(event, s.clock.Now()); err != nil {
+		return nil, err
+	}
+	if !event.SeatSelectionEnabled {
+		return nil, ErrSeatSelectionDisabled
+	}
+	amount, err := s.events.BookingAmount(ctx, event, b.ID, seats)
+	if err != nil {
+		return nil, err
+	}
+	if b.PaymentStatus == "authorized" && amount != b.AmountDue {
+		return nil, ErrAuthorizedPriceChange
+	}
+
+	release, err := s.holdSeats(ctx, b.EventID, seats)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	result := &SeatChangeResult{}
+	move := func(ctx context.Context, amountPaid *float64) error {
+		res, err := s.repo.ChangeSeats(ctx, bookingID, seats, bookings.SeatChange{AmountDue: amount, AmountPaid: amountPaid})
+		return seatMoveError(res, err)
+	}
+	if b.PaymentStatus == "paid" && s.payments != nil {
+		change, err := s.payments.ChangeAmount(ctx, b, amount, paymentID, func(ctx context.Context, amountPaid float64) error {
+			return move(ctx, &amountPaid)
+		})
+		if err != nil {
+			return nil, err
+		}
+		result.Charged, result.Refunded = change.Charged, change.Refunded
+	} else if err := move(ctx, nil); err != nil {
+		return nil, err
+	}
+	s.log.Info("Booking seats changed", zap.String("booking_id", bookingID), zap.Strings("from", b.Seats), zap.Strings("to", seats),
+		zap.Float64("charged", result.Charged), zap.Float64("refunded", result.Refunded))
+
+	if s.notify != nil {
+		if err := s.notify.Publish(ctx, redisx.BookingEvent{Type: redisx.BookingEventReseated, BookingID: bookingID, Status: b.Status}); err != nil {
+			s.log.Error("Failed to publish booking event", zap.Error(err), zap.String("booking_id", bookingID))
+		}
+	}
+	if result.Booking, err = s.repo.GetByID(ctx, bookingID); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// seatMoveError turns the outcome of moving a booking's seats into the service's errors.
+func seatMoveError(res *bookings.Reseat, err error) error {
+	switch {
+	case errors.Is(err, bookings.ErrNotActive):
+		return ErrBookingNotActive
+	case err != nil:
+		return err
+	case res == nil:
+		return ErrBookingNotFound
+	case len(res.Unknown) > 0:
+		return fmt.Errorf("%w: %s", ErrUnknownSeats, strings.Join(res.Unknown, ", "))
+	case len(res.Taken) > 0:
+		return fmt.Errorf("%w: %s", ErrSeatsTaken, strings.Join(res.Taken, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package bookings
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/events"
+)
+
+// eventCacheMaxEntries is how many events the cache holds before expired entries are swept.
+const eventCacheMaxEntries = 10000
+
+type cachedEvent struct {
+	event   *events.Event
+	limit   *events.UserTicketLimit
+	phases  []*events.SalePhase
+	expires time.Time
+}
+
+// eventCache keeps the event row, per-user ticket limit and sale phases that every booking request
+// validates against in memory for ttl, so the booking path doesn't read them from Postgres
+// on each request. Changes to an event reach bookings within ttl; a ttl of 0 reads through.
+// Unknown events aren't cached, so an event is bookable as soon as it is created.
+type eventCache struct {
+	events  eventStore
+	ttl     time.Duration
+	mu      sync.RWMutex
+	entries map[string]cachedEvent
+}
+
+func newEventCache(repo eventStore, ttl time.Duration) *eventCache {
+	return &eventCache{events: repo, ttl: ttl, entries: make(map[string]cachedEvent)}
+}
+
+// get returns the event, its user ticket limit and its sale phases, or a nil event if it
+// doesn't exist. Callers must not modify them.
+func (c *eventCache) get(ctx context.Context, eventID string) (*events.Event, *events.UserTicketLimit, []*events.SalePhase, error) {
+	now := time.Now()
+	c.mu.RLock()
+	e, ok := c.entries[eventID]
+	c.mu.RUnlock()
+	if ok && now.Before(e.expires) {
+		return e.event, e.limit, e.phases, nil
+	}
+
+	event, err := c.events.Get(ctx, eventID)
+	if err != nil || event == nil {
+		return nil, nil, nil, err
+	}
+	limit, err := c.events.GetUserTicketLimit(ctx, eventID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	phases, err := c.events.SalePhases(ctx, eventID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if c.ttl <= 0 {
+		return event, limit, phases, nil
+	}
+
+	c.mu.Lock()
+	if len(c.entries) >= eventCacheMaxEntries {
+		for id, old := range c.entries {
+			if !now.Before(old.expires) {
+				delete(c.entries, id)
+			}
+		}
+	}
+	c.entries[eventID] = cachedEvent{event: event, limit: limit, phases: phases, expires: now.Add(c.ttl)}
+	c.mu.Unlock()
+	return event, limit, phases, nil
+}
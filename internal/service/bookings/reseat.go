@@ -0,0 +1,91 @@
+package bookings
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"go.uber.org/zap"
+
+	redisx "github.com/samirwankhede/lewly-pgpyewj/internal/redis"
+	eventsService "github.com/samirwankhede/lewly-pgpyewj/internal/service/events"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/bookings"
+)
+
+var (
+	ErrBookingNotActive = errors.New("booking is not pending or booked")
+	// A reseat never goes through payment, so it can't change what the booking paid for
+	ErrSeatCountChanged = errors.New("new seats must be as many as the booking has")
+	ErrUnknownSeats     = errors.New("seats are not on the event's seat map")
+)
+
+// Reseat moves a pending or booked booking to other seats for support, e.g. off a broken
+// seat, without a new payment. The new seats are held in Redis while Postgres swaps them,
+// so a concurrent booking can't claim them; the move is written to the audit log with the
+// admin's ID and reason, and the customer is emailed their new seats.
+func (s *BookingsService) Reseat(ctx context.Context, bookingID string, seats []string, actorID, reason string) (*bookings.Booking, error) {
+	reason = strings.TrimSpace(reason)
+	if len(seats) == 0 || reason == "" {
+		return nil, ErrValidation
+	}
+	seen := make(map[string]bool, len(seats))
+	for _, label := range seats {
+		if label == "" || seen[label] {
+			return nil, ErrValidation
+		}
+		seen[label] = true
+	}
+
+	b, err := s.repo.GetByID(ctx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil {
+		return nil, ErrBookingNotFound
+	}
+	if !b.Status.Active() {
+		return nil, ErrBookingNotActive
+	}
+	if len(b.Seats) != len(seats) {
+		return nil, ErrSeatCountChanged
+	}
+
+	event, err := s.events.Get(ctx, b.EventID)
+	if err != nil {
+		return nil, err
+	}
+	if event == nil {
+		return nil, errors.New("event not found")
+	}
+	if err := eventsService.RequireOpen(event, s.clock.Now()); err != nil {
+		return nil, err
+	}
+
+	release, err := s.holdSeats(ctx, b.EventID, seats)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	res, err := s.repo.Reseat(ctx, bookingID, seats, actorID, reason)
+	if err := seatMoveError(res, err); err != nil {
+		return nil, err
+	}
+	s.log.Info("Booking reseated", zap.String("booking_id", bookingID), zap.Strings("from", res.From),
+		zap.Strings("to", seats), zap.String("actor_id", actorID), zap.String("reason", reason))
+
+	if s.notify != nil {
+		if err := s.notify.Publish(ctx, redisx.BookingEvent{Type: redisx.BookingEventReseated, BookingID: bookingID, Status: res.Status}); err != nil {
+			s.log.Error("Failed to publish booking event", zap.Error(err), zap.String("booking_id", bookingID))
+		}
+	}
+	if s.mailer != nil {
+		user, err := s.users.GetByID(ctx, res.UserID)
+		if err != nil {
+			s.log.Error("Failed to load user for reseat email", zap.Error(err), zap.String("booking_id", bookingID))
+		} else if user != nil {
+			s.mailer.For(event.OrganizerID).About(bookingID).SendReseatEmail(user.Email, event.Name, res.From, seats, reason)
+		}
+	}
+	return s.repo.GetByID(ctx, bookingID)
+}
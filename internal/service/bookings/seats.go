@@ -0,0 +1,262 @@
+package bookings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/metrics"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/bookings"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/events"
+)
+
+// ErrStrandedSeat rejects a seat selection that would leave a lone empty seat in a row on an
+// event with the no-single-seat rule on.
+var ErrStrandedSeat = errors.New("selection would leave a single empty seat")
+
+// checkStranded enforces the event's no-single-seat rule on a chosen selection. The check
+// reads the seat map without a lock, so two bookings racing for neighbouring seats can still
+// leave a gap between them; it stops the common case, not every one.
+func (s *BookingsService) checkStranded(ctx context.Context, event *events.Event, seats []string) error {
+	if !event.NoSingleSeat || len(seats) == 0 {
+		return nil
+	}
+	open, err := s.events.OpenSeats(ctx, event.ID)
+	if err != nil {
+		return err
+	}
+	if stranded := strandedSeats(open, seats); len(stranded) > 0 {
+		return fmt.Errorf("%w: %s", ErrStrandedSeat, strings.Join(stranded, ", "))
+	}
+	return nil
+}
+
+// holdSeats atomically holds the chosen seats in Redis for this request. It fails with
+// ErrSeatsTaken, naming the seats, when another request holds any of them; other errors are
+// Redis failing. The returned func releases the holds once the request is done with them.
+func (s *BookingsService) holdSeats(ctx context.Context, eventID string, seats []string) (func(), error) {
+	if len(seats) == 0 {
+		return func() {}, nil
+	}
+	holder := uuid.NewString()
+	held, err := s.tokens.HoldSeats(ctx, eventID, holder, seats, s.seatHold)
+	if err != nil {
+		return nil, err
+	}
+	if len(held) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrSeatsTaken, strings.Join(held, ", "))
+	}
+	return func() {
+		// Released even if the request was cancelled; otherwise the seats wait out the hold
+		if err := s.tokens.ReleaseSeats(context.Background(), eventID, holder, seats); err != nil {
+			s.log.Warn("Failed to release seat holds", zap.Error(err), zap.String("event_id", eventID))
+		}
+	}, nil
+}
+
+// checkTaken rejects a seat selection with ErrSeatsTaken when a booking already has any of
+// the seats. Run under the request's holds, nothing can claim them between the check and
+// the pending booking's insert.
+func (s *BookingsService) checkTaken(ctx context.Context, eventID string, seats []string) error {
+	if len(seats) == 0 {
+		return nil
+	}
+	taken, err := s.events.TakenSeats(ctx, eventID, seats)
+	if err != nil {
+		return err
+	}
+	if len(taken) > 0 {
+		return fmt.Errorf("%w: %s", ErrSeatsTaken, strings.Join(taken, ", "))
+	}
+	return nil
+}
+
+// seatPicker picks the seats of the event's quantity bookings: the first block of
+// neighbouring seats in one row, in layout order, so parties sit together as near the front
+// as they can. On events with the no-single-seat rule the block must leave no lone seat in
+// its row, and a gap it fills exactly is preferred. When no row has a block the first open
+// seats in layout order are picked, since holding the last seats back would only leave them
+// unsold.
+func seatPicker(event *events.Event) bookings.SeatPicker {
+	noSingleSeat := event.NoSingleSeat
+	return func(open []bookings.OpenSeat, n int) []string {
+		if block := pickBlock(open, n, noSingleSeat); block != nil {
+			return block
+		}
+		if len(open) > n {
+			open = open[:n]
+		}
+		labels := make([]string, len(open))
+		for i := range open {
+			labels[i] = open[i].Label
+		}
+		return labels
+	}
+}
+
+// seatError turns the store's seat errors into the service's.
+func seatError(err error) error {
+	var claimed *bookings.SeatsClaimedError
+	switch {
+	case errors.As(err, &claimed):
+		metrics.BookingRequestsTotal.WithLabelValues("seat_taken").Inc()
+		return fmt.Errorf("%w: %s", ErrSeatsTaken, strings.Join(claimed.Seats, ", "))
+	case errors.Is(err, bookings.ErrSeatContention):
+		return ErrSeatContention
+	}
+	return err
+}
+
+func seatErrorCode(err error) int {
+	var claimed *bookings.SeatsClaimedError
+	switch {
+	case errors.As(err, &claimed):
+		return 409
+	case errors.Is(err, bookings.ErrSeatContention):
+		return 503
+	}
+	return 500
+}
+
+// splitSeat splits a label into its row and its number within the row: "B12" is seat 12 of
+// row "B". Labels that don't end in a number have no neighbours.
+func splitSeat(label string) (row string, number int, ok bool) {
+	i := len(label)
+	for i > 0 && label[i-1] >= '0' && label[i-1] <= '9' {
+		i--
+	}
+	if i == len(label) {
+		return "", 0, false
+	}
+	n, err := strconv.Atoi(label[i:])
+	if err != nil {
+		return "", 0, false
+	}
+	return label[:i], n, true
+}
+
+// seatRows indexes open seat labels by row and number.
+func seatRows(labels []string) map[string]map[int]string {
+	rows := make(map[string]map[int]string)
+	for _, label := range labels {
+		row, n, ok := splitSeat(label)
+		if !ok {
+			continue
+		}
+		if rows[row] == nil {
+			rows[row] = make(map[int]string)
+		}
+		rows[row][n] = label
+	}
+	return rows
+}
+
+// strandedSeats returns the open seats that taking selection would leave with no open
+// neighbour on either side, in label order.
+func strandedSeats(open, selection []string) []string {
+	taken := make(map[string]bool, len(selection))
+	for _, label := range selection {
+		taken[label] = true
+	}
+	remaining := make([]string, 0, len(open))
+	for _, label := range open {
+		if !taken[label] {
+			remaining = append(remaining, label)
+		}
+	}
+	rows := seatRows(remaining)
+
+	seen := make(map[string]bool)
+	var stranded []string
+	for _, label := range selection {
+		row, n, ok := splitSeat(label)
+		if !ok {
+			continue
+		}
+		seats := rows[row]
+		for _, neighbour := range []int{n - 1, n + 1} {
+			l, isOpen := seats[neighbour]
+			if !isOpen || seen[l] {
+				continue
+			}
+			_, left := seats[neighbour-1]
+			_, right := seats[neighbour+1]
+			if !left && !right {
+				seen[l] = true
+				stranded = append(stranded, l)
+			}
+		}
+	}
+	sort.Strings(stranded)
+	return stranded
+}
+
+// seatPlace is where a seat sits for adjacency: seats of a laid-out row are neighbours when
+// their layout positions are, listed seats when their labels' numbers are (see splitSeat).
+type seatPlace struct {
+	section, row string
+	laidOut      bool
+}
+
+type placedSeat struct {
+	label  string
+	number int
+}
+
+// pickBlock finds count adjacent open seats in one row, trying rows in layout order and
+// taking a block from the start of the first run of neighbouring open seats long enough.
+// With noSingleSeat the block must leave no lone seat beside it: a run exactly count long
+// is preferred, filling a gap, otherwise the first run at least two longer is used. It
+// returns nil if no row has a block.
+func pickBlock(open []bookings.OpenSeat, count int, noSingleSeat bool) []string {
+	rows := make(map[seatPlace][]placedSeat)
+	var order []seatPlace
+	for _, s := range open {
+		place := seatPlace{section: s.Section, row: s.Row, laidOut: true}
+		number := s.Position
+		if s.Row == "" {
+			row, n, ok := splitSeat(s.Label)
+			if !ok {
+				continue
+			}
+			place = seatPlace{section: s.Section, row: row}
+			number = n
+		}
+		if rows[place] == nil {
+			order = append(order, place)
+		}
+		rows[place] = append(rows[place], placedSeat{label: s.Label, number: number})
+	}
+
+	var fallback []string
+	for _, place := range order {
+		seats := rows[place]
+		sort.Slice(seats, func(i, j int) bool { return seats[i].number < seats[j].number })
+		for start := 0; start < len(seats); {
+			end := start + 1
+			for end < len(seats) && seats[end].number == seats[end-1].number+1 {
+				end++
+			}
+			run := end - start
+			take := run == count || (!noSingleSeat && run > count)
+			if take || (run >= count+2 && fallback == nil) {
+				block := make([]string, count)
+				for i := range block {
+					block[i] = seats[start+i].label
+				}
+				if take {
+					return block
+				}
+				fallback = block
+			}
+			start = end
+		}
+	}
+	return fallback
+}
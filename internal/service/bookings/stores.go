@@ -0,0 +1,68 @@
+package bookings
+
+import (
+	"context"
+	"time"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/domain"
+	redisx "github.com/samirwankhede/lewly-pgpyewj/internal/redis"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/bookings"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/events"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/waitlist"
+)
+
+// The service reaches Postgres and Redis through these, which NewBookingsService fills with
+// the real repositories and token bucket, so the booking path can run against in-memory
+// fakes in benchmarks.
+
+type bookingStore interface {
+	GetByID(ctx context.Context, id string) (*bookings.Booking, error)
+	GetByIdempotency(ctx context.Context, key string) (*bookings.Booking, error)
+	GetBookingStatus(ctx context.Context, bookingID string) (string, error)
+	GetStatuses(ctx context.Context, userID string, ids []string) (map[string]string, error)
+	ListByUser(ctx context.Context, userID string, limit, offset int, after *bookings.UserPosition) ([]*bookings.Booking, error)
+	CreatePending(ctx context.Context, userID string, eventID string, idempotencyKey *string, seats domain.Seats, source string, phaseID *string, announce bookings.Announce) (*bookings.Booking, bool, error)
+	CreatePendingAssigned(ctx context.Context, userID string, eventID string, idempotencyKey *string, source string, phaseID *string, n int, pick bookings.SeatPicker, announce bookings.Announce) (*bookings.Booking, bool, error)
+	CreatePendingIfAvailable(ctx context.Context, userID string, eventID string, idempotencyKey *string, seats domain.Seats, source string, phaseID *string, n int, pick bookings.SeatPicker, announce bookings.Announce) (*bookings.Booking, bool, error)
+	FinalizeBooking(ctx context.Context, bookingID string, seats domain.Seats, amountPaid float64, paymentStatus string) error
+	Requeue(ctx context.Context, id string, announce bookings.Announce) (*bookings.Booking, error)
+	CancelBookingTx(ctx context.Context, bookingID string) (*bookings.Booking, bool, error)
+	Reseat(ctx context.Context, id string, seats []string, actorID, reason string) (*bookings.Reseat, error)
+	ChangeSeats(ctx context.Context, id string, seats []string, change bookings.SeatChange) (*bookings.Reseat, error)
+	CreateTransfer(ctx context.Context, t *bookings.Transfer, tokenHash []byte) error
+	GetTransferByHash(ctx context.Context, tokenHash []byte) (*bookings.Transfer, error)
+	AcceptTransfer(ctx context.Context, transferID, toUserID string) (*bookings.Transfer, error)
+	CancelTransfer(ctx context.Context, bookingID, fromUserID string) (bool, error)
+	Trace(ctx context.Context, bookingID string) ([]*bookings.TraceEntry, error)
+}
+
+type eventStore interface {
+	Get(ctx context.Context, id string) (*events.Event, error)
+	GetUserTicketLimit(ctx context.Context, eventID string) (*events.UserTicketLimit, error)
+	SalePhases(ctx context.Context, eventID string) ([]*events.SalePhase, error)
+	CanRehearse(ctx context.Context, eventID, userID string) (bool, error)
+	BookingAmount(ctx context.Context, event *events.Event, bookingID string, seats []string) (float64, error)
+	GetAvailableSeats(ctx context.Context, eventID string) ([]string, error)
+	OpenSeats(ctx context.Context, eventID string) ([]string, error)
+	TakenSeats(ctx context.Context, eventID string, labels []string) ([]string, error)
+}
+
+type tokenStore interface {
+	Reserve(ctx context.Context, eventID string, n int) (bool, error)
+	Release(ctx context.Context, eventID string, n int) error
+	ReserveUserTickets(ctx context.Context, scope, userID, id string, n, limit int, window time.Duration) (bool, int, error)
+	ReleaseUserTickets(ctx context.Context, scope, userID, id string, n int) error
+	HoldSeats(ctx context.Context, eventID, holder string, seats []string, ttl time.Duration) ([]string, error)
+	ReleaseSeats(ctx context.Context, eventID, holder string, seats []string) error
+}
+
+type waitlistStore interface {
+	Add(ctx context.Context, eventID, userID string) (int, error)
+}
+
+var (
+	_ bookingStore  = (*bookings.BookingsRepository)(nil)
+	_ eventStore    = (*events.EventsRepository)(nil)
+	_ tokenStore    = (*redisx.TokenBucket)(nil)
+	_ waitlistStore = (*waitlist.WaitlistRepository)(nil)
+)
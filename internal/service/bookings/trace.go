@@ -0,0 +1,48 @@
+package bookings
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	redisx "github.com/samirwankhede/lewly-pgpyewj/internal/redis"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/bookings"
+)
+
+// BookingTrace is everything recorded about a booking: the booking as it is now, and what
+// happened to it, oldest first.
+type BookingTrace struct {
+	Booking *bookings.Booking      `json:"booking"`
+	Entries []*bookings.TraceEntry `json:"entries"`
+}
+
+// Trace puts together the booking's history from its events, audit rows, outbox and Kafka
+// handling, payments, emails and webhook deliveries, for support investigating it.
+func (s *BookingsService) Trace(ctx context.Context, bookingID string) (*BookingTrace, error) {
+	if _, err := uuid.Parse(bookingID); err != nil {
+		return nil, ErrBookingNotFound
+	}
+	b, err := s.repo.GetByID(ctx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil {
+		return nil, ErrBookingNotFound
+	}
+	entries, err := s.repo.Trace(ctx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	return &BookingTrace{Booking: b, Entries: entries}, nil
+}
+
+// EventRecorder returns a listener that stores every booking transition announced, so it
+// shows in the booking's trace after the pub/sub message is gone.
+func EventRecorder(log *zap.Logger, repo *bookings.BookingsRepository) redisx.Listener {
+	return func(ctx context.Context, e redisx.BookingEvent) {
+		if err := repo.RecordEvent(ctx, e.BookingID, e.Type, string(e.Status), e.At, e.ExpiresAt); err != nil {
+			log.Error("Failed to record booking event", zap.Error(err), zap.String("booking_id", e.BookingID), zap.String("type", e.Type))
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package bookings
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/domain"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/bookings"
+)
+
+const (
+	// transferTokenPrefix starts every transfer claim token.
+	transferTokenPrefix = "tr_"
+	transferTokenBytes  = 32
+	// defaultTransferTTL is how long a claim link works, unless WithTransferTTL says
+	// otherwise; links never outlive the event's start.
+	defaultTransferTTL = 72 * time.Hour
+)
+
+var (
+	ErrTransferToSelf    = errors.New("a booking can't be transferred to its own owner")
+	ErrNotTransferable   = errors.New("only booked bookings of upcoming events that haven't been checked in can be transferred")
+	ErrTransferNotFound  = errors.New("transfer not found")
+	ErrTransferClosed    = errors.New("transfer was accepted, cancelled or has expired")
+	ErrTransferRecipient = errors.New("transfer was sent to another email address")
+)
+
+// TransferPreview is what a recipient sees of a transfer before accepting it.
+type TransferPreview struct {
+	Transfer  *bookings.Transfer `json:"transfer"`
+	EventName string             `json:"event_name"`
+	StartTime time.Time          `json:"start_time"`
+	Venue     string             `json:"venue"`
+	Seats     domain.Seats       `json:"seats"`
+	FromName  string             `json:"from_name"`
+}
+
+// WithTransferTTL sets how long a transfer's claim link works.
+func (s *BookingsService) WithTransferTTL(ttl time.Duration) *BookingsService {
+	if ttl > 0 {
+		s.transferTTL = ttl
+	}
+	return s
+}
+
+// Transfer offers the user's booked booking to whoever holds email: they are sent a claim
+// link, and the booking moves to their account once they accept it signed in with that
+// email. A new transfer of the same booking replaces a pending one. The booking stays the
+// sender's, and usable, until then.
+func (s *BookingsService) Transfer(ctx context.Context, bookingID, userID, email string) (*bookings.Transfer, error) {
+	email = strings.TrimSpace(email)
+	b, err := s.repo.GetByID(ctx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil || b.UserID != userID {
+		return nil, ErrBookingNotFound
+	}
+	if b.Status != domain.BookingBooked {
+		return nil, ErrNotTransferable
+	}
+	sender, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if sender == nil {
+		return nil, ErrBookingNotFound
+	}
+	if strings.EqualFold(sender.Email, email) {
+		return nil, ErrTransferToSelf
+	}
+	event, err := s.events.Get(ctx, b.EventID)
+	if err != nil {
+		return nil, err
+	}
+	now := s.clock.Now()
+	if event == nil || event.Status.Over() || !now.Before(event.StartTime) {
+		return nil, ErrNotTransferable
+	}
+
+	expires := now.Add(s.transferTTL)
+	if event.StartTime.Before(expires) {
+		expires = event.StartTime
+	}
+	token, err := newTransferToken()
+	if err != nil {
+		return nil, err
+	}
+	t := &bookings.Transfer{BookingID: b.ID, EventID: b.EventID, FromUserID: userID, ToEmail: email, ExpiresAt: expires}
+	if err := s.repo.CreateTransfer(ctx, t, hashTransferToken(token)); err != nil {
+		return nil, err
+	}
+
+	link := strings.TrimRight(s.paymentURL, "/") + "/v1/transfers/" + url.PathEscape(token)
+	if err := s.mailer.For(event.OrganizerID).About(b.ID).SendTransferClaimEmail(email, sender.Name, event.Name, event.StartTime, b.Seats, link, expires); err != nil {
+		// Without the email nobody can claim it
+		if _, cerr := s.repo.CancelTransfer(ctx, b.ID, userID); cerr != nil {
+			s.log.Error("Failed to cancel unsent transfer", zap.Error(cerr), zap.String("transfer_id", t.ID))
+		}
+		return nil, err
+	}
+	s.log.Info("Booking transfer offered", zap.String("booking_id", b.ID), zap.String("transfer_id", t.ID), zap.Time("expires_at", expires))
+	return t, nil
+}
+
+// CancelTransfer withdraws the user's pending transfer of the booking.
+func (s *BookingsService) CancelTransfer(ctx context.Context, bookingID, userID string) error {
+	ok, err := s.repo.CancelTransfer(ctx, bookingID, userID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrTransferNotFound
+	}
+	return nil
+}
+
+// PreviewTransfer shows the recipient, signed in as userID, what the claim token offers.
+func (s *BookingsService) PreviewTransfer(ctx context.Context, token, userID string) (*TransferPreview, error) {
+	t, err := s.openTransfer(ctx, token, userID)
+	if err != nil {
+		return nil, err
+	}
+	b, err := s.repo.GetByID(ctx, t.BookingID)
+	if err != nil {
+		return nil, err
+	}
+	event, err := s.events.Get(ctx, t.EventID)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil || event == nil {
+		return nil, ErrTransferClosed
+	}
+	p := &TransferPreview{Transfer: t, EventName: event.Name, StartTime: event.StartTime, Venue: event.Venue, Seats: b.Seats}
+	if sender, err := s.users.GetByID(ctx, t.FromUserID); err == nil && sender != nil {
+		p.FromName = sender.Name
+	}
+	return p, nil
+}
+
+// AcceptTransfer moves the transferred booking to userID, who must be signed in with the
+// email it was sent to. The booking keeps its ID, seats and payment, so the recipient's
+// ticket is the same ticket; the sender no longer sees it or can cancel it.
+func (s *BookingsService) AcceptTransfer(ctx context.Context, token, userID string) (*bookings.Booking, error) {
+	t, err := s.openTransfer(ctx, token, userID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.repo.AcceptTransfer(ctx, t.ID, userID); err != nil {
+		switch {
+		case errors.Is(err, bookings.ErrTransferClosed):
+			return nil, ErrTransferClosed
+		case errors.Is(err, bookings.ErrNotTransferable):
+			return nil, ErrNotTransferable
+		}
+		return nil, err
+	}
+	s.log.Info("Booking transferred", zap.String("booking_id", t.BookingID), zap.String("transfer_id", t.ID),
+		zap.String("from_user_id", t.FromUserID), zap.String("to_user_id", userID))
+	return s.repo.GetByID(ctx, t.BookingID)
+}
+
+// openTransfer returns the pending transfer token claims, if it was sent to userID's email.
+func (s *BookingsService) openTransfer(ctx context.Context, token, userID string) (*bookings.Transfer, error) {
+	if !strings.HasPrefix(token, transferTokenPrefix) {
+		return nil, ErrTransferNotFound
+	}
+	t, err := s.repo.GetTransferByHash(ctx, hashTransferToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, ErrTransferNotFound
+	}
+	if t.Status != bookings.TransferPending || !s.clock.Now().Before(t.ExpiresAt) {
+		return nil, ErrTransferClosed
+	}
+	if t.FromUserID == userID {
+		return nil, ErrTransferToSelf
+	}
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !strings.EqualFold(user.Email, t.ToEmail) {
+		return nil, ErrTransferRecipient
+	}
+	return t, nil
+}
+
+func newTransferToken() (string, error) {
+	b := make([]byte, transferTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return transferTokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashTransferToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
@@ -0,0 +1,243 @@
+package checkin
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/domain"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/metrics"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/bookings"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/checkin"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/events"
+)
+
+// TokenPrefix starts every gate token, so one pasted in the wrong place is recognisable.
+const TokenPrefix = "gt_"
+
+const (
+	tokenBytes      = 32
+	defaultTokenTTL = 12 * time.Hour
+	maxTokenNameLen = 64
+)
+
+var (
+	ErrEventNotFound    = errors.New("event not found")
+	ErrEventOver        = errors.New("event is cancelled or has ended")
+	ErrInvalidTokenTTL  = errors.New("invalid gate token lifetime")
+	ErrInvalidTokenName = errors.New("gate token names must be at most 64 characters")
+	ErrTokenNotFound    = errors.New("gate token not found")
+	ErrInvalidToken     = errors.New("invalid gate token")
+	ErrTokenRevoked     = errors.New("gate token revoked")
+	ErrTokenNotValid    = errors.New("gate token is not valid at this time")
+	ErrBookingNotFound  = errors.New("booking not found")
+	ErrWrongEvent       = errors.New("booking is for another event")
+	ErrOutsideWindow    = errors.New("check-in is not open for this event")
+	ErrNotBooked        = errors.New("booking is not confirmed")
+	ErrAlreadyCheckedIn = errors.New("booking already checked in")
+)
+
+// Barcode is what a ticket's QR code encodes: the booking ID, which gate devices read and
+// send to Scan. Everything that renders a ticket, such as wallet passes, encodes this.
+func Barcode(bookingID string) string {
+	return bookingID
+}
+
+// IssueTokenRequest is an admin's request for a gate token. Without ValidFrom the token is
+// valid at once; TTLMinutes defaults to 12 hours.
+type IssueTokenRequest struct {
+	Name       string     `json:"name"`
+	ValidFrom  *time.Time `json:"valid_from"`
+	TTLMinutes int        `json:"ttl_minutes" binding:"omitempty,gt=0"`
+}
+
+// ScanResult is what a gate device shows for a scanned ticket.
+type ScanResult struct {
+	BookingID   string       `json:"booking_id"`
+	EventID     string       `json:"event_id"`
+	Seats       domain.Seats `json:"seats"`
+	CheckedInAt time.Time    `json:"checked_in_at"`
+}
+
+// CheckInService runs entry at the gates. Gate staff don't sign in as users: an admin
+// issues each device a short-lived token scoped to one event, and scans made with it are
+// refused for other events' tickets and outside the event's check-in window.
+type CheckInService struct {
+	log      *zap.Logger
+	repo     *checkin.CheckInRepository
+	events   *events.EventsRepository
+	bookings *bookings.BookingsRepository
+	// maxTTL caps the lifetime of a token; opensBefore is how long before an event starts
+	// its gates open
+	maxTTL      time.Duration
+	opensBefore time.Duration
+}
+
+func NewCheckInService(log *zap.Logger, repo *checkin.CheckInRepository, events *events.EventsRepository, bookings *bookings.BookingsRepository, maxTTL, opensBefore time.Duration) *CheckInService {
+	return &CheckInService{log: log, repo: repo, events: events, bookings: bookings, maxTTL: maxTTL, opensBefore: opensBefore}
+}
+
+// IssueToken creates a gate token for the event. The returned token carries the secret,
+// which is not stored and can't be shown again.
+func (s *CheckInService) IssueToken(ctx context.Context, eventID, issuedBy string, req IssueTokenRequest) (*checkin.GateToken, error) {
+	name := strings.TrimSpace(req.Name)
+	if len(name) > maxTokenNameLen {
+		return nil, ErrInvalidTokenName
+	}
+	ttl := defaultTokenTTL
+	if req.TTLMinutes > 0 {
+		ttl = time.Duration(req.TTLMinutes) * time.Minute
+	}
+	if ttl > s.maxTTL {
+		return nil, ErrInvalidTokenTTL
+	}
+	e, err := s.events.Get(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, ErrEventNotFound
+	}
+	if e.Status.Over() {
+		return nil, ErrEventOver
+	}
+
+	validFrom := time.Now()
+	if req.ValidFrom != nil && req.ValidFrom.After(validFrom) {
+		validFrom = *req.ValidFrom
+	}
+	secret, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	t := &checkin.GateToken{EventID: eventID, Name: name, ValidFrom: validFrom, ExpiresAt: validFrom.Add(ttl)}
+	// API-key requests have no user behind them
+	if issuedBy != "" {
+		t.IssuedBy = &issuedBy
+	}
+	if err := s.repo.CreateToken(ctx, t, hashToken(secret)); err != nil {
+		return nil, err
+	}
+	t.Token = secret
+	s.log.Info("gate token issued", zap.String("event_id", eventID), zap.String("token_id", t.ID), zap.Time("expires_at", t.ExpiresAt))
+	return t, nil
+}
+
+func (s *CheckInService) ListTokens(ctx context.Context, eventID string) ([]*checkin.GateToken, error) {
+	return s.repo.ListTokens(ctx, eventID)
+}
+
+func (s *CheckInService) RevokeToken(ctx context.Context, eventID, tokenID string) error {
+	ok, err := s.repo.RevokeToken(ctx, eventID, tokenID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+// Authenticate returns the gate token secret belongs to, if it is valid now.
+func (s *CheckInService) Authenticate(ctx context.Context, secret string) (*checkin.GateToken, error) {
+	if !strings.HasPrefix(secret, TokenPrefix) {
+		return nil, ErrInvalidToken
+	}
+	t, err := s.repo.GetTokenByHash(ctx, hashToken(secret))
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, ErrInvalidToken
+	}
+	if t.RevokedAt != nil {
+		return nil, ErrTokenRevoked
+	}
+	now := time.Now()
+	if now.Before(t.ValidFrom) || !now.Before(t.ExpiresAt) {
+		return nil, ErrTokenNotValid
+	}
+	return t, nil
+}
+
+// Scan admits the booking through gate. The booking must be a confirmed booking of the
+// gate's event, scanned between opensBefore ahead of the event's start and its end; a
+// ticket is admitted once, and a second scan returns ErrAlreadyCheckedIn with the first.
+func (s *CheckInService) Scan(ctx context.Context, gate *checkin.GateToken, bookingID string) (*ScanResult, error) {
+	res, err := s.scan(ctx, gate, bookingID)
+	metrics.CheckInScansTotal.WithLabelValues(scanOutcome(err)).Inc()
+	return res, err
+}
+
+func (s *CheckInService) scan(ctx context.Context, gate *checkin.GateToken, bookingID string) (*ScanResult, error) {
+	b, err := s.bookings.GetByID(ctx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil {
+		return nil, ErrBookingNotFound
+	}
+	if b.EventID != gate.EventID {
+		s.log.Warn("gate scan for another event", zap.String("token_id", gate.ID), zap.String("booking_id", bookingID), zap.String("event_id", gate.EventID))
+		return nil, ErrWrongEvent
+	}
+	e, err := s.events.Get(ctx, gate.EventID)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, ErrEventNotFound
+	}
+	now := time.Now()
+	if e.Status == domain.EventCancelled || now.Before(e.StartTime.Add(-s.opensBefore)) || now.After(e.EndTime) {
+		return nil, ErrOutsideWindow
+	}
+	if b.Status != domain.BookingBooked {
+		return nil, ErrNotBooked
+	}
+
+	ci, first, err := s.repo.CheckIn(ctx, b.ID, b.EventID, gate.ID)
+	if err != nil {
+		return nil, err
+	}
+	res := &ScanResult{BookingID: b.ID, EventID: b.EventID, Seats: b.Seats, CheckedInAt: ci.CheckedInAt}
+	if !first {
+		return res, ErrAlreadyCheckedIn
+	}
+	return res, nil
+}
+
+func scanOutcome(err error) string {
+	switch err {
+	case nil:
+		return "admitted"
+	case ErrAlreadyCheckedIn:
+		return "duplicate"
+	case ErrWrongEvent:
+		return "wrong_event"
+	case ErrOutsideWindow:
+		return "outside_window"
+	case ErrBookingNotFound, ErrNotBooked:
+		return "invalid_ticket"
+	}
+	return "error"
+}
+
+func newToken() (string, error) {
+	b := make([]byte, tokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return TokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(secret string) []byte {
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:]
+}
@@ -0,0 +1,38 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/domain"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/events"
+)
+
+// ErrEventArchived rejects changes to an event that has ended. Its page, attendance and
+// waitlist stay readable.
+var ErrEventArchived = errors.New("event has ended and is archived; it can no longer be booked, liked or waitlisted")
+
+// Archived reports whether the event has ended: its end time has passed, or the status
+// checker already marked it expired.
+func Archived(e *events.Event, now time.Time) bool {
+	return e.Status == domain.EventExpired || !e.EndTime.After(now)
+}
+
+// RequireOpen is the check every service runs before changing anything about an event on a
+// user's behalf (bookings, likes, waitlist joins). It returns ErrEventArchived once the event
+// has ended.
+func RequireOpen(e *events.Event, now time.Time) error {
+	if Archived(e, now) {
+		return ErrEventArchived
+	}
+	return nil
+}
+
+// Attendance returns how an archived event went, or nil for an event that hasn't ended.
+func (s *EventsService) Attendance(ctx context.Context, e *events.Event) (*events.Attendance, error) {
+	if !Archived(e, s.clock.Now()) {
+		return nil, nil
+	}
+	return s.repo.Attendance(ctx, e.ID)
+}
@@ -0,0 +1,101 @@
+package events
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	redisx "github.com/samirwankhede/lewly-pgpyewj/internal/redis"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/events"
+)
+
+// defaultLimitedPercent is the share of capacity left at or below which an event is
+// limited, unless WithAvailability says otherwise.
+const defaultLimitedPercent = 10
+
+// WithAvailability sets the share of capacity, in percent, left at or below which an event's
+// listing badge says limited.
+func (s *EventsService) WithAvailability(limitedPercent int) *EventsService {
+	s.limitedPercent = limitedPercent
+	return s
+}
+
+// Classify buckets an event by its remaining tokens: sold_out with none left, limited with
+// at most limitedPercent of capacity left, available otherwise.
+func Classify(remaining, capacity, limitedPercent int) string {
+	switch {
+	case remaining <= 0:
+		return redisx.AvailabilitySoldOut
+	case remaining*100 <= capacity*limitedPercent:
+		return redisx.AvailabilityLimited
+	default:
+		return redisx.AvailabilityAvailable
+	}
+}
+
+// RunAvailability classifies every event on sale now and every interval after until ctx is
+// done, storing the buckets in Redis for listings to read. Listings never count seats
+// themselves, so a badge can be up to interval out of date. Every API instance runs it;
+// they all write the same buckets.
+func (s *EventsService) RunAvailability(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := s.classifyAll(ctx); err != nil {
+			s.log.Error("Failed to classify event availability", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *EventsService) classifyAll(ctx context.Context) error {
+	remaining, err := s.tokens.RemainingAll(ctx)
+	if err != nil {
+		return err
+	}
+	ids := make([]string, 0, len(remaining))
+	for id := range remaining {
+		// Stray keys under the token prefix aren't events
+		if _, err := uuid.Parse(id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	capacities, err := s.repo.Capacities(ctx, ids)
+	if err != nil {
+		return err
+	}
+	buckets := make(map[string]string, len(capacities))
+	for id, capacity := range capacities {
+		buckets[id] = Classify(remaining[id], capacity, s.limitedPercent)
+	}
+	return s.tokens.SetAvailability(ctx, buckets)
+}
+
+// Annotate sets Availability on each event from the buckets in Redis. Badges are
+// best-effort: a Redis error is logged and leaves them unset rather than failing a listing.
+func Annotate(ctx context.Context, log *zap.Logger, tokens *redisx.TokenBucket, evs ...*events.Event) {
+	if tokens == nil || len(evs) == 0 {
+		return
+	}
+	ids := make([]string, len(evs))
+	for i, e := range evs {
+		ids[i] = e.ID
+	}
+	buckets, err := tokens.Availability(ctx, ids)
+	if err != nil {
+		log.Warn("Failed to read event availability", zap.Error(err))
+		return
+	}
+	for _, e := range evs {
+		e.Availability = buckets[e.ID]
+	}
+}
@@ -0,0 +1,160 @@
+package events
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/events"
+)
+
+// WithCache caches event details and the public listings in Redis for ttl. Zero, the
+// default, reads everything from Postgres. Admin changes retire the cache through
+// TokenBucket.InvalidateEvent; likes and reserved counts may lag by up to ttl.
+func (s *EventsService) WithCache(ttl time.Duration) *EventsService {
+	s.cacheTTL = ttl
+	return s
+}
+
+// getEvent is repo.Get through the cache. Only events that exist are cached.
+func (s *EventsService) getEvent(ctx context.Context, id string) (*events.Event, error) {
+	if s.cacheTTL <= 0 {
+		return s.repo.Get(ctx, id)
+	}
+	data, err := s.cached(ctx, "event:"+id, func(ctx context.Context) (any, error) {
+		e, err := s.repo.Get(ctx, id)
+		if err != nil || e == nil {
+			return nil, err
+		}
+		return e, nil
+	})
+	if err != nil || data == nil {
+		return nil, err
+	}
+	e := &events.Event{}
+	if err := json.Unmarshal(data, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// listEvents is a listing query through the cache; name must identify the query and its
+// parameters.
+func (s *EventsService) listEvents(ctx context.Context, name string, load func(ctx context.Context) ([]*events.Event, error)) ([]*events.Event, error) {
+	if s.cacheTTL <= 0 {
+		return load(ctx)
+	}
+	data, err := s.cached(ctx, "list:"+name, func(ctx context.Context) (any, error) {
+		evs, err := load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		// Cache empty listings too, or a quiet catalog would miss every time
+		if evs == nil {
+			evs = []*events.Event{}
+		}
+		return evs, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var evs []*events.Event
+	if err := json.Unmarshal(data, &evs); err != nil {
+		return nil, err
+	}
+	return evs, nil
+}
+
+// cached returns the encoding cached under name, or nil if load finds nothing to cache. On a
+// miss it calls load and caches its result. Concurrent misses for the same name share one
+// load, so a hot event going on sale costs one query per instance rather than one per
+// request. They share the encoding rather than the value because callers annotate and
+// localize what they decode.
+func (s *EventsService) cached(ctx context.Context, name string, load func(ctx context.Context) (any, error)) ([]byte, error) {
+	// The generation is read before loading, so an invalidation that lands while the load
+	// is running retires what it caches
+	gen, err := s.tokens.EventCacheGeneration(ctx)
+	if err != nil {
+		s.log.Warn("Failed to read event cache generation", zap.Error(err))
+		loaded, err := load(ctx)
+		if err != nil || loaded == nil {
+			return nil, err
+		}
+		return json.Marshal(loaded)
+	}
+	data, err := s.tokens.CachedEventData(ctx, gen, name)
+	if err != nil {
+		s.log.Warn("Failed to read event cache", zap.String("key", name), zap.Error(err))
+	}
+	if data != nil {
+		return data, nil
+	}
+
+	shared, err, _ := s.flight.Do(fmt.Sprintf("%d:%s", gen, name), func() (any, error) {
+		// Detached from the request that happened to start the load, so it being cancelled
+		// doesn't fail the others waiting on it
+		ctx := context.WithoutCancel(ctx)
+		loaded, err := load(ctx)
+		if err != nil || loaded == nil {
+			return []byte(nil), err
+		}
+		data, err := json.Marshal(loaded)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.tokens.SetCachedEventData(ctx, gen, name, data, s.cacheTTL); err != nil {
+			s.log.Warn("Failed to write event cache", zap.String("key", name), zap.Error(err))
+		}
+		return data, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return shared.([]byte), nil
+}
+
+// listKey names a listing query in the cache. Positions and search parameters are hashed
+// to keep keys short.
+func listKey(kind string, limit, offset int, search ...string) string {
+	key := fmt.Sprintf("%s:%d:%d", kind, limit, offset)
+	if len(search) > 0 {
+		sum := sha256.Sum256([]byte(strings.Join(search, "\x00")))
+		key += ":" + hex.EncodeToString(sum[:8])
+	}
+	return key
+}
+
+// positionKey formats an optional listing position for listKey.
+func positionKey(after *events.ListPosition) string {
+	if after == nil {
+		return ""
+	}
+	return timeKey(&after.StartTime) + "/" + after.ID
+}
+
+// timeKey formats an optional time bound for listKey.
+func timeKey(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// warm loads the event and its stats into the cache the way a page view would, whatever
+// the event's visibility. It returns nil if the event doesn't exist.
+func (s *EventsService) warm(ctx context.Context, id string) (*events.Event, error) {
+	e, err := s.getEvent(ctx, id)
+	if err != nil || e == nil {
+		return nil, err
+	}
+	if _, err := s.computeStats(ctx, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
@@ -2,54 +2,213 @@ package events
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
 
+	"github.com/samirwankhede/lewly-pgpyewj/internal/clock"
 	redisx "github.com/samirwankhede/lewly-pgpyewj/internal/redis"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/service/fx"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/store/events"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/invitations"
+)
+
+var (
+	ErrEventNotFound         = errors.New("event not found")
+	ErrLikesDisabled         = errors.New("likes are disabled for this event")
+	ErrSeatSelectionDisabled = errors.New("seat selection is disabled for this event")
+	ErrInvitationNotFound    = errors.New("invitation not found")
+	// Invitation codes are personal; redeeming someone else's is refused
+	ErrInvitationNotYours = errors.New("invitation was issued to another user")
 )
 
 type EventsService struct {
-	log    *zap.Logger
-	repo   *events.EventsRepository
-	tokens *redisx.TokenBucket
+	log     *zap.Logger
+	repo    *events.EventsRepository
+	tokens  *redisx.TokenBucket
+	rates   *fx.Rates
+	invites *invitations.InvitationsRepository
+	clock   clock.Clock
+	// limitedPercent is where the availability badge turns limited
+	limitedPercent int
+	// statsTTL is how long public stats stay cached
+	statsTTL time.Duration
+	// cacheTTL is how long events and listings stay cached; zero disables the cache
+	cacheTTL time.Duration
+	flight   singleflight.Group
 }
 
 func NewEventsService(log *zap.Logger, repo *events.EventsRepository, tokens *redisx.TokenBucket) *EventsService {
-	return &EventsService{log: log, repo: repo, tokens: tokens}
+	return &EventsService{log: log, repo: repo, tokens: tokens, clock: clock.Real{}, limitedPercent: defaultLimitedPercent, statsTTL: defaultStatsTTL}
 }
 
-func (s *EventsService) List(ctx context.Context, limit, offset int, q string, from, to *time.Time) ([]*events.Event, error) {
-	return s.repo.List(ctx, limit, offset, q, from, to)
+// WithClock replaces the wall clock used to tell archived events apart.
+func (s *EventsService) WithClock(c clock.Clock) *EventsService {
+	s.clock = c
+	return s
 }
 
-func (s *EventsService) ListAll(ctx context.Context, limit, offset int) ([]*events.Event, error) {
-	return s.repo.ListAll(ctx, limit, offset)
+// WithRates enables display prices in other currencies.
+func (s *EventsService) WithRates(rates *fx.Rates) *EventsService {
+	s.rates = rates
+	return s
 }
 
-func (s *EventsService) ListUpcoming(ctx context.Context, limit, offset int) ([]*events.Event, error) {
-	return s.repo.ListUpcoming(ctx, limit, offset)
+// WithInvitations enables redeeming invitations to private events.
+func (s *EventsService) WithInvitations(repo *invitations.InvitationsRepository) *EventsService {
+	s.invites = repo
+	return s
 }
 
-func (s *EventsService) ListPopular(ctx context.Context, limit, offset int) ([]*events.Event, error) {
-	return s.repo.ListPopular(ctx, limit, offset)
+// Localize sets DisplayPrice on each event to its prices converted into currency. Events
+// already priced in currency are left alone. It returns fx.ErrUnsupportedCurrency when
+// there is no rate for the conversion, including when display prices are not enabled.
+func (s *EventsService) Localize(ctx context.Context, currency string, evs ...*events.Event) error {
+	currency, err := fx.Normalize(currency)
+	if err != nil {
+		return err
+	}
+	for _, e := range evs {
+		if e == nil || e.Currency == currency {
+			continue
+		}
+		if s.rates == nil {
+			return fx.ErrUnsupportedCurrency
+		}
+		rate, asOf, err := s.rates.Rate(ctx, e.Currency, currency)
+		if err != nil {
+			return err
+		}
+		e.DisplayPrice = &events.DisplayPrice{
+			Currency:        currency,
+			TicketPrice:     fx.Round(e.TicketPrice * rate),
+			CancellationFee: fx.Round(e.CancellationFee * rate),
+			Rate:            rate,
+			AsOf:            asOf,
+		}
+	}
+	return nil
+}
+
+func (s *EventsService) List(ctx context.Context, limit, offset int, after *events.ListPosition, q string, from, to *time.Time) ([]*events.Event, error) {
+	key := listKey("search", limit, offset, positionKey(after), q, timeKey(from), timeKey(to))
+	evs, err := s.listEvents(ctx, key, func(ctx context.Context) ([]*events.Event, error) {
+		return s.repo.List(ctx, limit, offset, after, q, from, to)
+	})
+	if err != nil {
+		return nil, err
+	}
+	Annotate(ctx, s.log, s.tokens, evs...)
+	return evs, nil
+}
+
+func (s *EventsService) ListNearby(ctx context.Context, f events.NearbyFilter, limit, offset int) ([]*events.NearbyEvent, error) {
+	items, err := s.repo.ListNearby(ctx, f, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	evs := make([]*events.Event, len(items))
+	for i, item := range items {
+		evs[i] = item.Event
+	}
+	Annotate(ctx, s.log, s.tokens, evs...)
+	return items, nil
+}
+
+// Search runs a full-text search over the public catalog; see EventsRepository.Search.
+func (s *EventsService) Search(ctx context.Context, f events.SearchFilter, limit, offset int) ([]*events.SearchResult, error) {
+	items, err := s.repo.Search(ctx, f, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	evs := make([]*events.Event, len(items))
+	for i, item := range items {
+		evs[i] = item.Event
+	}
+	Annotate(ctx, s.log, s.tokens, evs...)
+	return items, nil
 }
 
-func (s *EventsService) Get(ctx context.Context, id string) (*events.Event, int, error) {
-	e, err := s.repo.Get(ctx, id)
+func (s *EventsService) ListAll(ctx context.Context, limit, offset int, after *events.ListPosition) ([]*events.Event, error) {
+	evs, err := s.listEvents(ctx, listKey("all", limit, offset, positionKey(after)), func(ctx context.Context) ([]*events.Event, error) {
+		return s.repo.ListAll(ctx, limit, offset, after)
+	})
 	if err != nil {
+		return nil, err
+	}
+	Annotate(ctx, s.log, s.tokens, evs...)
+	return evs, nil
+}
+
+func (s *EventsService) ListUpcoming(ctx context.Context, limit, offset int, after *events.ListPosition) ([]*events.Event, error) {
+	evs, err := s.listEvents(ctx, listKey("upcoming", limit, offset, positionKey(after)), func(ctx context.Context) ([]*events.Event, error) {
+		return s.repo.ListUpcoming(ctx, limit, offset, after)
+	})
+	if err != nil {
+		return nil, err
+	}
+	Annotate(ctx, s.log, s.tokens, evs...)
+	return evs, nil
+}
+
+func (s *EventsService) ListPopular(ctx context.Context, limit, offset int) ([]*events.Event, error) {
+	evs, err := s.listEvents(ctx, listKey("popular", limit, offset), func(ctx context.Context) ([]*events.Event, error) {
+		return s.repo.ListPopular(ctx, limit, offset)
+	})
+	if err != nil {
+		return nil, err
+	}
+	Annotate(ctx, s.log, s.tokens, evs...)
+	return evs, nil
+}
+
+// Get returns the event and its remaining tokens, or a nil event if there is none. Private
+// events are only returned with a valid invitation code for them, so without one they look
+// like they don't exist.
+func (s *EventsService) Get(ctx context.Context, id string, code string) (*events.Event, int, error) {
+	e, err := s.visibleEvent(ctx, id, code)
+	if err != nil || e == nil {
 		return nil, 0, err
 	}
 	rem, _ := s.tokens.Remaining(ctx, id)
 	return e, rem, nil
 }
 
+// requireFeature loads the event and returns errDisabled if the toggle picked by on is off,
+// or ErrEventArchived if the event has ended.
+func (s *EventsService) requireFeature(ctx context.Context, eventID string, on func(*events.Event) bool, errDisabled error) error {
+	e, err := s.repo.Get(ctx, eventID)
+	if err != nil {
+		return err
+	}
+	if e == nil {
+		return ErrEventNotFound
+	}
+	if err := RequireOpen(e, s.clock.Now()); err != nil {
+		return err
+	}
+	if !on(e) {
+		return errDisabled
+	}
+	return nil
+}
+
+func likesEnabled(e *events.Event) bool { return e.LikesEnabled }
+
 func (s *EventsService) LikeEvent(ctx context.Context, eventID, userID string) error {
+	if err := s.requireFeature(ctx, eventID, likesEnabled, ErrLikesDisabled); err != nil {
+		return err
+	}
 	return s.repo.LikeEvent(ctx, eventID, userID)
 }
 
 func (s *EventsService) UnlikeEvent(ctx context.Context, eventID, userID string) error {
+	if err := s.requireFeature(ctx, eventID, likesEnabled, ErrLikesDisabled); err != nil {
+		return err
+	}
 	return s.repo.UnlikeEvent(ctx, eventID, userID)
 }
 
@@ -57,6 +216,130 @@ func (s *EventsService) IsLiked(ctx context.Context, eventID, userID string) (bo
 	return s.repo.IsLiked(ctx, eventID, userID)
 }
 
-func (s *EventsService) GetAvailableSeats(ctx context.Context, eventID string) ([]string, error) {
-	return s.repo.GetAvailableSeats(ctx, eventID)
+// SeatMap is an event's seat map grouped by section, then row, in layout order. Seats lists
+// the labels that can still be chosen.
+type SeatMap struct {
+	Seats    []string           `json:"seats"`
+	Tiers    []events.PriceTier `json:"tiers"`
+	Sections []*SeatMapSection  `json:"sections"`
+}
+
+// SeatMapSection is a section of the seat map; seats laid out without sections are in one
+// section with an empty name.
+type SeatMapSection struct {
+	Name string        `json:"name"`
+	Rows []*SeatMapRow `json:"rows"`
+}
+
+// SeatMapRow is a row of a section; seats listed without rows are in one row with an empty name.
+type SeatMapRow struct {
+	Row   string            `json:"row"`
+	Seats []*events.MapSeat `json:"seats"`
+}
+
+// GetSeatMap returns the event's seat map with each seat's price and availability. Events
+// without seat selection don't expose their seat map; seats are assigned at booking time
+// instead. Like Get, private events need an invitation code.
+func (s *EventsService) GetSeatMap(ctx context.Context, eventID string, code string) (*SeatMap, error) {
+	e, err := s.visibleEvent(ctx, eventID, code)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, ErrEventNotFound
+	}
+	if !e.SeatSelectionEnabled {
+		return nil, ErrSeatSelectionDisabled
+	}
+	tiers, err := s.repo.PriceTiers(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	seats, err := s.repo.SeatMap(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &SeatMap{Seats: []string{}, Tiers: tiers, Sections: []*SeatMapSection{}}
+	sections := map[string]*SeatMapSection{}
+	rows := map[[2]string]*SeatMapRow{}
+	for _, seat := range seats {
+		if seat.Available {
+			m.Seats = append(m.Seats, seat.Label)
+		}
+		sec, ok := sections[seat.Section]
+		if !ok {
+			sec = &SeatMapSection{Name: seat.Section}
+			sections[seat.Section] = sec
+			m.Sections = append(m.Sections, sec)
+		}
+		key := [2]string{seat.Section, seat.Row}
+		row, ok := rows[key]
+		if !ok {
+			row = &SeatMapRow{Row: seat.Row}
+			rows[key] = row
+			sec.Rows = append(sec.Rows, row)
+		}
+		row.Seats = append(row.Seats, seat)
+	}
+	return m, nil
+}
+
+// RedeemInvitation redeems the event invitation with code for the user, giving them access
+// to book the event if it is private. Redeeming an already redeemed invitation succeeds.
+func (s *EventsService) RedeemInvitation(ctx context.Context, eventID, userID, code string) (*invitations.Invitation, error) {
+	if s.invites == nil {
+		return nil, ErrInvitationNotFound
+	}
+	inv, err := s.invites.GetByCode(ctx, eventID, strings.ToUpper(strings.TrimSpace(code)))
+	if err != nil {
+		return nil, err
+	}
+	if inv == nil {
+		return nil, ErrInvitationNotFound
+	}
+	if inv.UserID != userID {
+		return nil, ErrInvitationNotYours
+	}
+	if inv.RedeemedAt != nil {
+		return inv, nil
+	}
+	inv, err = s.invites.Redeem(ctx, inv.ID)
+	if err != nil {
+		return nil, err
+	}
+	if inv == nil {
+		return nil, ErrInvitationNotFound
+	}
+	s.log.Info("Invitation redeemed", zap.String("event_id", eventID), zap.String("user_id", userID))
+	return inv, nil
+}
+
+// visibleEvent loads the event through the cache, returning nil if it doesn't exist or is
+// private and code is not one of its invitation codes.
+func (s *EventsService) visibleEvent(ctx context.Context, id string, code string) (*events.Event, error) {
+	e, err := s.getEvent(ctx, id)
+	if err != nil || e == nil {
+		return nil, err
+	}
+	if e.Visibility == events.VisibilityPrivate {
+		ok, err := s.validCode(ctx, id, code)
+		if err != nil || !ok {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// validCode reports whether code is an invitation code for the event.
+func (s *EventsService) validCode(ctx context.Context, eventID, code string) (bool, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if s.invites == nil || code == "" {
+		return false, nil
+	}
+	inv, err := s.invites.GetByCode(ctx, eventID, code)
+	if err != nil {
+		return false, err
+	}
+	return inv != nil, nil
 }
@@ -0,0 +1,87 @@
+package events
+
+import (
+	"context"
+	"time"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/domain"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/events"
+)
+
+const (
+	DefaultFeedLimit = 100
+	MaxFeedLimit     = 500
+	// feedOverlap is how far before the newest updated_at a delta sync should restart. An
+	// event's updated_at is when its updating transaction began, so one committing late can
+	// land behind events a previous sync already saw; restarting a little earlier catches it.
+	feedOverlap = time.Minute
+)
+
+// FeedEvent is an event as syndicated to aggregators: what a listing needs to show and link
+// to it, without booking internals.
+type FeedEvent struct {
+	ID          string             `json:"id"`
+	Name        string             `json:"name"`
+	Venue       string             `json:"venue"`
+	Category    string             `json:"category"`
+	StartTime   time.Time          `json:"start_time"`
+	EndTime     time.Time          `json:"end_time"`
+	Status      domain.EventStatus `json:"status"`
+	TicketPrice float64            `json:"ticket_price"`
+	Currency    string             `json:"currency"`
+	Capacity    int                `json:"capacity"`
+	Latitude    *float64           `json:"latitude,omitempty"`
+	Longitude   *float64           `json:"longitude,omitempty"`
+	OrganizerID *string            `json:"organizer_id,omitempty"`
+	CreatedAt   time.Time          `json:"created_at"`
+	UpdatedAt   time.Time          `json:"updated_at"`
+}
+
+// FeedPage is one page of the feed. Next is set when more events follow; SyncFrom is set on
+// the last page and is the updated_since to start the next delta sync from.
+type FeedPage struct {
+	Events   []*FeedEvent
+	Next     *events.FeedPosition
+	SyncFrom *time.Time
+}
+
+// Feed returns a page of public events in the order they were last updated, oldest first,
+// so a sync that pages through to the end has seen every change. since limits the page to
+// events updated after it, after continues from a previous page.
+func (s *EventsService) Feed(ctx context.Context, since *time.Time, after *events.FeedPosition, limit int) (*FeedPage, error) {
+	if limit <= 0 || limit > MaxFeedLimit {
+		limit = DefaultFeedLimit
+	}
+	evs, err := s.repo.ListFeed(ctx, since, after, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	page := &FeedPage{Events: []*FeedEvent{}}
+	if len(evs) > limit {
+		evs = evs[:limit]
+		last := evs[len(evs)-1]
+		page.Next = &events.FeedPosition{UpdatedAt: last.UpdatedAt, ID: last.ID}
+	}
+	for _, e := range evs {
+		page.Events = append(page.Events, &FeedEvent{
+			ID: e.ID, Name: e.Name, Venue: e.Venue, Category: e.Category,
+			StartTime: e.StartTime, EndTime: e.EndTime, Status: e.Status,
+			TicketPrice: e.TicketPrice, Currency: e.Currency, Capacity: e.Capacity,
+			Latitude: e.Latitude, Longitude: e.Longitude, OrganizerID: e.OrganizerID,
+			CreatedAt: e.CreatedAt, UpdatedAt: e.UpdatedAt,
+		})
+	}
+	if page.Next == nil {
+		switch {
+		case len(evs) > 0:
+			from := evs[len(evs)-1].UpdatedAt.Add(-feedOverlap)
+			page.SyncFrom = &from
+		case after != nil:
+			from := after.UpdatedAt.Add(-feedOverlap)
+			page.SyncFrom = &from
+		case since != nil:
+			page.SyncFrom = since
+		}
+	}
+	return page, nil
+}
@@ -0,0 +1,222 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/clock"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/metrics"
+	redisx "github.com/samirwankhede/lewly-pgpyewj/internal/redis"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/events"
+)
+
+var (
+	ErrNotOnSale    = errors.New("tickets for this event aren't on sale right now")
+	ErrPhaseSoldOut = errors.New("the current sale phase is sold out")
+)
+
+// rolloverSettle is how long after a phase ends its unsold quantity is rolled over, so
+// bookings admitted in its last moments are in Postgres by then and counted as sold.
+const rolloverSettle = 30 * time.Second
+
+// rolloverBatchSize caps how many events one rollover run looks at.
+const rolloverBatchSize = 100
+
+// CurrentPhase returns the phase of phases open at now. It returns nil for an event sold
+// without phases, and ErrNotOnSale when none of them is open.
+func CurrentPhase(phases []*events.SalePhase, now time.Time) (*events.SalePhase, error) {
+	if len(phases) == 0 {
+		return nil, nil
+	}
+	for _, p := range phases {
+		if p.OpenAt(now) {
+			return p, nil
+		}
+	}
+	return nil, ErrNotOnSale
+}
+
+// SalePhases enforces and maintains the quantity of events sold in phases. Each phase has a
+// token bucket in Redis alongside the event's, created from Postgres the first time it is
+// booked, and Run rolls the unsold quantity of ended phases into the next.
+type SalePhases struct {
+	log    *zap.Logger
+	events *events.EventsRepository
+	tokens *redisx.TokenBucket
+	clock  clock.Clock
+}
+
+func NewSalePhases(log *zap.Logger, events *events.EventsRepository, tokens *redisx.TokenBucket) *SalePhases {
+	return &SalePhases{log: log, events: events, tokens: tokens, clock: clock.Real{}}
+}
+
+// Reserve takes n tokens from phase's bucket, creating the bucket first if it doesn't exist.
+func (p *SalePhases) Reserve(ctx context.Context, phase *events.SalePhase, n int) (bool, error) {
+	ok, err := p.tokens.ReservePhase(ctx, phase.EventID, phase.ID, n)
+	if err != redisx.ErrNoPhaseTokens {
+		return ok, err
+	}
+	expected, err := p.events.ExpectedPhaseTokens(ctx, phase.ID)
+	if err != nil {
+		return false, err
+	}
+	// A concurrent request may have created it first; either way it exists now
+	if _, err := p.tokens.EnsurePhaseTokens(ctx, phase.EventID, phase.ID, expected); err != nil {
+		return false, err
+	}
+	return p.tokens.ReservePhase(ctx, phase.EventID, phase.ID, n)
+}
+
+// Release returns n tokens to the bucket of the event's phase phaseID.
+func (p *SalePhases) Release(ctx context.Context, eventID, phaseID string, n int) error {
+	return p.tokens.ReleasePhase(ctx, eventID, phaseID, n)
+}
+
+// RollOver moves the unsold quantity of the event's ended phases into the phases after them,
+// in Postgres and then in their buckets. It returns the moves made.
+func (p *SalePhases) RollOver(ctx context.Context, eventID string) ([]events.Rollover, error) {
+	moves, err := p.events.RollOverSalePhases(ctx, eventID, p.clock.Now().Add(-rolloverSettle))
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range moves {
+		metrics.SalePhaseRolloverSeatsTotal.Add(float64(m.Seats))
+		if err := p.tokens.MovePhaseTokens(ctx, eventID, m.From, m.To, m.Seats); err != nil {
+			// Postgres has the rollover; a token resync brings the buckets in line
+			p.log.Error("Failed to move sale phase tokens", zap.Error(err), zap.String("event_id", eventID), zap.String("from", m.From), zap.String("to", m.To))
+		}
+		p.log.Info("Rolled over unsold sale phase quantity", zap.String("event_id", eventID), zap.String("from", m.From), zap.String("to", m.To), zap.Int("seats", m.Seats))
+	}
+	return moves, nil
+}
+
+// Run rolls over the ended phases of every event still on sale and returns how many seats
+// it moved.
+func (p *SalePhases) Run(ctx context.Context) (int, error) {
+	ids, err := p.events.EventsWithEndedSalePhases(ctx, p.clock.Now().Add(-rolloverSettle), rolloverBatchSize)
+	if err != nil {
+		p.log.Error("Failed to list events with ended sale phases", zap.Error(err))
+		return 0, err
+	}
+	moved := 0
+	for _, id := range ids {
+		moves, err := p.RollOver(ctx, id)
+		if err != nil {
+			p.log.Error("Failed to roll over sale phases", zap.Error(err), zap.String("event_id", id))
+			continue
+		}
+		for _, m := range moves {
+			moved += m.Seats
+		}
+	}
+	return moved, nil
+}
+
+// RunPeriodic rolls over ended phases every interval until ctx is done.
+func (p *SalePhases) RunPeriodic(ctx context.Context, interval time.Duration) {
+	ticker := p.clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	p.log.Info("Starting sale phase rollover", zap.Duration("interval", interval))
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.log.Info("Stopping sale phase rollover")
+			return
+		case <-ticker.C():
+			_, _ = p.Run(ctx)
+		}
+	}
+}
+
+// PhaseResync reports a sale phase bucket before and after it was reset from Postgres.
+type PhaseResync struct {
+	PhaseID string `json:"phase_id"`
+	Name    string `json:"name"`
+	Before  int    `json:"before"`
+	After   int    `json:"after"`
+}
+
+// Resync resets the buckets of the event's phases to their pools minus what they sold, and
+// drops the buckets of phases that no longer exist. Callers hold the event's lock, as for
+// the event's own bucket.
+func (p *SalePhases) Resync(ctx context.Context, eventID string) ([]*PhaseResync, error) {
+	phases, err := p.events.SalePhases(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	out := []*PhaseResync{}
+	ids := make([]string, len(phases))
+	for i, phase := range phases {
+		ids[i] = phase.ID
+		before, err := p.tokens.RemainingPhase(ctx, eventID, phase.ID)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.tokens.InitPhaseTokens(ctx, eventID, phase.ID, phase.Remaining()); err != nil {
+			return nil, err
+		}
+		out = append(out, &PhaseResync{PhaseID: phase.ID, Name: phase.Name, Before: before, After: phase.Remaining()})
+	}
+	if _, err := p.tokens.DropPhaseTokens(ctx, eventID, ids); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Sale phase statuses, as buyers see them.
+const (
+	PhaseUpcoming = "upcoming"
+	PhaseOnSale   = "on_sale"
+	PhaseSoldOut  = "sold_out"
+	PhaseEnded    = "ended"
+)
+
+// SalePhaseView is a sale phase as buyers see it.
+type SalePhaseView struct {
+	Name      string    `json:"name"`
+	Price     float64   `json:"price"`
+	StartsAt  time.Time `json:"starts_at"`
+	EndsAt    time.Time `json:"ends_at"`
+	Status    string    `json:"status"`
+	Remaining int       `json:"remaining"`
+}
+
+// SalePhases returns the phases the event is sold in, in order, with the status of each and
+// how many seats it has left. Events sold without phases have none. Private events need the
+// invitation code as for the event itself.
+func (s *EventsService) SalePhases(ctx context.Context, eventID, code string) ([]*SalePhaseView, error) {
+	e, err := s.visibleEvent(ctx, eventID, code)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, ErrEventNotFound
+	}
+	phases, err := s.repo.SalePhases(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	now := s.clock.Now()
+	out := make([]*SalePhaseView, len(phases))
+	for i, p := range phases {
+		v := &SalePhaseView{Name: p.Name, Price: p.Price, StartsAt: p.StartsAt, EndsAt: p.EndsAt, Remaining: p.Remaining()}
+		switch {
+		case now.Before(p.StartsAt):
+			v.Status = PhaseUpcoming
+		case !now.Before(p.EndsAt):
+			v.Status = PhaseEnded
+			v.Remaining = 0
+		case v.Remaining == 0:
+			v.Status = PhaseSoldOut
+		default:
+			v.Status = PhaseOnSale
+		}
+		out[i] = v
+	}
+	return out, nil
+}
@@ -0,0 +1,181 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/clock"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/metrics"
+	redisx "github.com/samirwankhede/lewly-pgpyewj/internal/redis"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/prewarms"
+)
+
+// Prewarmer gets scheduled on-sales ready before they open. Once an on-sale is within lead
+// of its start it runs the checks once and stores them as the on-sale's readiness report;
+// until the start it then keeps the event's cache entries loaded, since they live for less
+// than the lead.
+type Prewarmer struct {
+	log    *zap.Logger
+	repo   *prewarms.PrewarmsRepository
+	events *EventsService
+	tokens *redisx.TokenBucket
+	lead   time.Duration
+	clock  clock.Clock
+}
+
+func NewPrewarmer(log *zap.Logger, repo *prewarms.PrewarmsRepository, events *EventsService, tokens *redisx.TokenBucket, lead time.Duration) *Prewarmer {
+	return &Prewarmer{log: log, repo: repo, events: events, tokens: tokens, lead: lead, clock: clock.Real{}}
+}
+
+// Run warms every on-sale due within the lead and returns how many it reported on.
+func (p *Prewarmer) Run(ctx context.Context) (int, error) {
+	now := p.clock.Now()
+	due, err := p.repo.Due(ctx, now, now.Add(p.lead))
+	if err != nil {
+		p.log.Error("Failed to list due on-sales", zap.Error(err))
+		return 0, err
+	}
+
+	reported := 0
+	for _, pw := range due {
+		if pw.Status != prewarms.StatusScheduled {
+			if _, err := p.events.warm(ctx, pw.EventID); err != nil {
+				p.log.Warn("Failed to refresh on-sale caches", zap.Error(err), zap.String("event_id", pw.EventID))
+			}
+			continue
+		}
+
+		checks := p.Warm(ctx, pw.EventID)
+		status := prewarms.StatusReady
+		var failed []string
+		for _, c := range checks {
+			if !c.OK {
+				status = prewarms.StatusDegraded
+				failed = append(failed, c.Name)
+			}
+		}
+		saved, err := p.repo.SaveReport(ctx, pw.EventID, pw.SaleStartTime, status, checks, p.clock.Now())
+		if err != nil {
+			p.log.Error("Failed to save on-sale readiness report", zap.Error(err), zap.String("event_id", pw.EventID))
+			continue
+		}
+		if !saved {
+			// Rescheduled while it was being warmed; the next run warms the new schedule
+			continue
+		}
+		reported++
+		metrics.OnsalePrewarmsTotal.WithLabelValues(status).Inc()
+		if status == prewarms.StatusDegraded {
+			p.log.Warn("On-sale is not ready", zap.String("event_id", pw.EventID),
+				zap.Time("sale_start_time", pw.SaleStartTime), zap.Strings("failed", failed))
+		} else {
+			p.log.Info("On-sale ready", zap.String("event_id", pw.EventID), zap.Time("sale_start_time", pw.SaleStartTime))
+		}
+	}
+	return reported, nil
+}
+
+// Warm runs the warm-up checks for the event: its page and stats loaded into the cache, its
+// seats read and counted against capacity, its token bucket checked against its bookings
+// (and created if missing), and Redis answering with a safe eviction policy.
+func (p *Prewarmer) Warm(ctx context.Context, eventID string) []prewarms.Check {
+	var checks []prewarms.Check
+	run := func(name string, fn func() (bool, string)) {
+		start := time.Now()
+		ok, detail := fn()
+		checks = append(checks, prewarms.Check{Name: name, OK: ok, Detail: detail, DurationMs: time.Since(start).Milliseconds()})
+	}
+
+	capacity := 0
+	run("event_cache", func() (bool, string) {
+		e, err := p.events.warm(ctx, eventID)
+		if err != nil {
+			return false, err.Error()
+		}
+		if e == nil {
+			return false, "event not found"
+		}
+		capacity = e.Capacity
+		if p.events.cacheTTL <= 0 {
+			return true, "stats cached; the event cache is off (EVENT_CACHE_SECONDS=0)"
+		}
+		return true, "event and stats cached"
+	})
+
+	run("seat_map", func() (bool, string) {
+		seats, err := p.events.repo.SeatMap(ctx, eventID)
+		if err != nil {
+			return false, err.Error()
+		}
+		available := 0
+		for _, s := range seats {
+			if s.Available {
+				available++
+			}
+		}
+		detail := fmt.Sprintf("%d seats for capacity %d, %d available", len(seats), capacity, available)
+		return len(seats) == capacity, detail
+	})
+
+	run("tokens", func() (bool, string) {
+		expected, err := p.events.repo.ExpectedTokens(ctx, eventID)
+		if err != nil {
+			return false, err.Error()
+		}
+		created, err := p.tokens.EnsureTokens(ctx, eventID, expected)
+		if err != nil {
+			return false, err.Error()
+		}
+		if created {
+			return true, fmt.Sprintf("bucket was missing; created with %d tokens", expected)
+		}
+		remaining, err := p.tokens.Remaining(ctx, eventID)
+		if err != nil {
+			return false, err.Error()
+		}
+		if remaining != expected {
+			return false, fmt.Sprintf("bucket holds %d tokens but bookings leave %d; run evctl tokens resync", remaining, expected)
+		}
+		return true, fmt.Sprintf("%d tokens", remaining)
+	})
+
+	run("redis", func() (bool, string) {
+		rtt, err := p.tokens.Ping(ctx)
+		if err != nil {
+			return false, err.Error()
+		}
+		policy, err := p.tokens.EvictionPolicy(ctx)
+		if err != nil {
+			// Managed Redis often disables CONFIG; the ping is all that can be checked
+			return true, fmt.Sprintf("ping %s; eviction policy unknown", rtt)
+		}
+		if strings.HasPrefix(policy, "allkeys-") {
+			return false, fmt.Sprintf("ping %s; maxmemory-policy %s can evict token counters and rate-limit windows", rtt, policy)
+		}
+		return true, fmt.Sprintf("ping %s; maxmemory-policy %s", rtt, policy)
+	})
+
+	return checks
+}
+
+// RunPeriodic warms due on-sales every interval until ctx is done.
+func (p *Prewarmer) RunPeriodic(ctx context.Context, interval time.Duration) {
+	ticker := p.clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	p.log.Info("Starting on-sale prewarmer", zap.Duration("interval", interval), zap.Duration("lead", p.lead))
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.log.Info("Stopping on-sale prewarmer")
+			return
+		case <-ticker.C():
+			_, _ = p.Run(ctx)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package events
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	redisx "github.com/samirwankhede/lewly-pgpyewj/internal/redis"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/snapshots"
+)
+
+// InventorySnapshotter periodically records each live event's inventory so oversell
+// investigations can see what Postgres and Redis believed at a given time.
+type InventorySnapshotter struct {
+	log       *zap.Logger
+	snapshots *snapshots.SnapshotsRepository
+	tokens    *redisx.TokenBucket
+}
+
+func NewInventorySnapshotter(log *zap.Logger, snapshots *snapshots.SnapshotsRepository, tokens *redisx.TokenBucket) *InventorySnapshotter {
+	return &InventorySnapshotter{log: log, snapshots: snapshots, tokens: tokens}
+}
+
+// TakeSnapshots records one snapshot per live event and returns how many were written.
+func (s *InventorySnapshotter) TakeSnapshots(ctx context.Context) (int, error) {
+	snaps, err := s.snapshots.CollectLive(ctx)
+	if err != nil {
+		s.log.Error("Failed to collect inventory", zap.Error(err))
+		return 0, err
+	}
+	if len(snaps) == 0 {
+		return 0, nil
+	}
+
+	for _, snap := range snaps {
+		rem, err := s.tokens.Remaining(ctx, snap.EventID)
+		if err != nil {
+			// Record -1 so a Redis outage is visible in the snapshot instead of looking like a sell-out
+			s.log.Error("Failed to read tokens for snapshot", zap.Error(err), zap.String("event_id", snap.EventID))
+			rem = -1
+		}
+		snap.TokensRemaining = rem
+	}
+
+	if err := s.snapshots.Insert(ctx, snaps, time.Now()); err != nil {
+		s.log.Error("Failed to store inventory snapshots", zap.Error(err))
+		return 0, err
+	}
+
+	s.log.Info("Took inventory snapshots", zap.Int("count", len(snaps)))
+	return len(snaps), nil
+}
+
+// RunPeriodic takes snapshots every interval until ctx is done
+func (s *InventorySnapshotter) RunPeriodic(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	s.log.Info("Starting inventory snapshotter", zap.Duration("interval", interval))
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.log.Info("Stopping inventory snapshotter")
+			return
+		case <-ticker.C:
+			_, _ = s.TakeSnapshots(ctx)
+		}
+	}
+}
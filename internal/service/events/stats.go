@@ -0,0 +1,114 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/events"
+)
+
+// defaultStatsTTL is how long public stats are cached, unless WithStatsCache says otherwise.
+const defaultStatsTTL = time.Minute
+
+// PublicStats are an event's figures safe to show anyone, for "85% sold" style social
+// proof: shares and totals only, nothing about who booked.
+type PublicStats struct {
+	EventID     string `json:"event_id"`
+	PercentSold int    `json:"percent_sold"`
+	SoldOut     bool   `json:"sold_out"`
+	Likes       int    `json:"likes"`
+	Waitlist    int    `json:"waitlist"`
+	// LastEdition is how the previous event of the series sold, if there was one
+	LastEdition *EditionStats `json:"last_edition,omitempty"`
+	AsOf        time.Time     `json:"as_of"`
+}
+
+// EditionStats is how an earlier event of a series sold.
+type EditionStats struct {
+	EventID     string    `json:"event_id"`
+	StartTime   time.Time `json:"start_time"`
+	PercentSold int       `json:"percent_sold"`
+}
+
+// WithStatsCache sets how long public stats are cached in Redis.
+func (s *EventsService) WithStatsCache(ttl time.Duration) *EventsService {
+	s.statsTTL = ttl
+	return s
+}
+
+// Stats returns the event's public stats, cached in Redis for statsTTL so marketing pages
+// polling them don't run the aggregates each time. Like Get, private events need an
+// invitation code; a nil result means there is no such event.
+func (s *EventsService) Stats(ctx context.Context, id string, code string) (*PublicStats, error) {
+	e, err := s.visibleEvent(ctx, id, code)
+	if err != nil || e == nil {
+		return nil, err
+	}
+	if cached, err := s.tokens.CachedEventStats(ctx, id); err != nil {
+		s.log.Warn("Failed to read cached event stats", zap.Error(err), zap.String("event_id", id))
+	} else if cached != nil {
+		var st PublicStats
+		if err := json.Unmarshal(cached, &st); err == nil {
+			return &st, nil
+		}
+	}
+	return s.computeStats(ctx, e)
+}
+
+// computeStats computes the event's public stats from Postgres and caches them.
+func (s *EventsService) computeStats(ctx context.Context, e *events.Event) (*PublicStats, error) {
+	id := e.ID
+	a, err := s.repo.Attendance(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if a == nil {
+		return nil, nil
+	}
+	st := &PublicStats{
+		EventID:     id,
+		PercentSold: percentSold(a.TicketsSold, a.Capacity),
+		SoldOut:     a.Capacity > 0 && a.TicketsSold >= a.Capacity,
+		Likes:       e.Likes,
+		Waitlist:    a.Waitlisted,
+		AsOf:        s.clock.Now().UTC(),
+	}
+	if !e.LikesEnabled {
+		st.Likes = 0
+	}
+	prevID, prevStart, err := s.repo.PreviousEdition(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if prevID != "" {
+		prev, err := s.repo.Attendance(ctx, prevID)
+		if err != nil {
+			return nil, err
+		}
+		if prev != nil {
+			st.LastEdition = &EditionStats{EventID: prevID, StartTime: prevStart, PercentSold: percentSold(prev.TicketsSold, prev.Capacity)}
+		}
+	}
+
+	if b, err := json.Marshal(st); err == nil {
+		if err := s.tokens.SetEventStats(ctx, id, b, s.statsTTL); err != nil {
+			s.log.Warn("Failed to cache event stats", zap.Error(err), zap.String("event_id", id))
+		}
+	}
+	return st, nil
+}
+
+// percentSold is sold as a whole percentage of capacity, rounded down so an event isn't
+// shown as sold out before it is.
+func percentSold(sold, capacity int) int {
+	if capacity <= 0 {
+		return 0
+	}
+	if sold >= capacity {
+		return 100
+	}
+	return sold * 100 / capacity
+}
@@ -6,39 +6,194 @@ import (
 
 	"go.uber.org/zap"
 
+	"github.com/samirwankhede/lewly-pgpyewj/internal/clock"
+	redisx "github.com/samirwankhede/lewly-pgpyewj/internal/redis"
+	mailer "github.com/samirwankhede/lewly-pgpyewj/internal/service/mailer"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/store/events"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/seats"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/users"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/waitlist"
 )
 
+// archiveBatchSize caps how many events are archived per check so a backlog is drained gradually.
+const archiveBatchSize = 50
+
 type EventStatusChecker struct {
-	log    *zap.Logger
-	events *events.EventsRepository
+	log          *zap.Logger
+	events       *events.EventsRepository
+	seats        *seats.SeatsRepository
+	tokens       *redisx.TokenBucket
+	archiveAfter time.Duration
+	clock        clock.Clock
+	// Waitlist expiry, off unless WithWaitlistExpiry is called
+	waitlist  *waitlist.WaitlistRepository
+	users     *users.UsersRepository
+	mailer    *mailer.MailerService
+	closeLead time.Duration
 }
 
-func NewEventStatusChecker(log *zap.Logger, events *events.EventsRepository) *EventStatusChecker {
+func NewEventStatusChecker(log *zap.Logger, events *events.EventsRepository, seats *seats.SeatsRepository, tokens *redisx.TokenBucket, archiveAfter time.Duration) *EventStatusChecker {
 	return &EventStatusChecker{
-		log:    log,
-		events: events,
+		log:          log,
+		events:       events,
+		seats:        seats,
+		tokens:       tokens,
+		archiveAfter: archiveAfter,
+		clock:        clock.Real{},
 	}
 }
 
+// WithClock replaces the wall clock that decides which events have ended.
+func (s *EventStatusChecker) WithClock(c clock.Clock) *EventStatusChecker {
+	s.clock = c
+	return s
+}
+
+// WithWaitlistExpiry has the checker close each event's waitlist lead before it starts,
+// expiring the entries still waiting and emailing their users.
+func (s *EventStatusChecker) WithWaitlistExpiry(repo *waitlist.WaitlistRepository, users *users.UsersRepository, mailer *mailer.MailerService, lead time.Duration) *EventStatusChecker {
+	s.waitlist = repo
+	s.users = users
+	s.mailer = mailer
+	s.closeLead = lead
+	return s
+}
+
 // CheckAndUpdateExpiredEvents checks for events that have passed their end_time and updates their status to 'expired'
 func (s *EventStatusChecker) CheckAndUpdateExpiredEvents(ctx context.Context) (int, error) {
-	updatedCount, err := s.events.UpdateExpiredEvents(ctx)
+	expiredIDs, err := s.events.UpdateExpiredEvents(ctx, s.clock.Now())
 	if err != nil {
 		s.log.Error("Failed to update expired events", zap.Error(err))
 		return 0, err
 	}
 
-	if updatedCount > 0 {
-		s.log.Info("Updated expired events", zap.Int("count", updatedCount))
+	if len(expiredIDs) > 0 {
+		s.log.Info("Updated expired events", zap.Int("count", len(expiredIDs)))
+	}
+
+	// Expired events can no longer be booked, so their Redis state is dead weight
+	for _, id := range expiredIDs {
+		if _, err := s.tokens.ReleaseEventKeys(ctx, id); err != nil {
+			s.log.Error("Failed to release Redis keys for expired event", zap.Error(err), zap.String("event_id", id))
+		}
+		if err := s.tokens.InvalidateEvent(ctx, id); err != nil {
+			s.log.Error("Failed to invalidate event cache for expired event", zap.Error(err), zap.String("event_id", id))
+		}
+	}
+
+	return len(expiredIDs), nil
+}
+
+// SweepOrphanKeys removes Redis token keys of events that are terminal or no longer exist,
+// catching anything the expiry and cancel hooks missed
+func (s *EventStatusChecker) SweepOrphanKeys(ctx context.Context) (int, error) {
+	ids, err := s.tokens.EventIDsWithTokens(ctx)
+	if err != nil {
+		s.log.Error("Failed to scan Redis token keys", zap.Error(err))
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	live, err := s.events.FilterLiveEventIDs(ctx, ids)
+	if err != nil {
+		s.log.Error("Failed to look up live events", zap.Error(err))
+		return 0, err
+	}
+
+	removed := 0
+	for _, id := range ids {
+		if live[id] {
+			continue
+		}
+		n, err := s.tokens.ReleaseEventKeys(ctx, id)
+		if err != nil {
+			s.log.Error("Failed to release orphan Redis keys", zap.Error(err), zap.String("event_id", id))
+			continue
+		}
+		removed += n
+	}
+
+	if removed > 0 {
+		s.log.Info("Swept orphan Redis keys", zap.Int("count", removed))
 	}
 
-	return updatedCount, nil
+	return removed, nil
+}
+
+// ArchiveFinishedEventSeats moves seats of events that finished more than archiveAfter ago
+// out of the hot seats table
+func (s *EventStatusChecker) ArchiveFinishedEventSeats(ctx context.Context) (int, error) {
+	archived, err := s.seats.ArchiveFinishedEvents(ctx, s.clock.Now().Add(-s.archiveAfter), archiveBatchSize)
+	if err != nil {
+		s.log.Error("Failed to archive seats", zap.Error(err))
+		return archived, err
+	}
+
+	if archived > 0 {
+		s.log.Info("Archived seats of finished events", zap.Int("count", archived))
+	}
+
+	return archived, nil
+}
+
+// CloseWaitlists closes the waitlists of events starting within the close lead and tells
+// everyone still waiting on them. Closure can lag the lead by up to the check interval;
+// joins and promotions refuse started events themselves, so it never lags the start.
+func (s *EventStatusChecker) CloseWaitlists(ctx context.Context) (int, error) {
+	if s.waitlist == nil {
+		return 0, nil
+	}
+	cutoff := s.clock.Now().Add(s.closeLead)
+	closed := 0
+	for {
+		batch, err := s.waitlist.CloseDue(ctx, cutoff, archiveBatchSize)
+		if err != nil {
+			s.log.Error("Failed to close waitlists", zap.Error(err))
+			return closed, err
+		}
+		for _, c := range batch {
+			s.notifyWaitlistClosed(ctx, c)
+		}
+		closed += len(batch)
+		if len(batch) < archiveBatchSize {
+			break
+		}
+	}
+
+	if closed > 0 {
+		s.log.Info("Closed waitlists of starting events", zap.Int("count", closed))
+	}
+
+	return closed, nil
+}
+
+// notifyWaitlistClosed emails each user whose entry expired when c closed. The closure is
+// already committed, so failures are logged rather than retried.
+func (s *EventStatusChecker) notifyWaitlistClosed(ctx context.Context, c *waitlist.ClosedWaitlist) {
+	if len(c.Expired) == 0 {
+		return
+	}
+	event, err := s.events.Get(ctx, c.EventID)
+	if err != nil || event == nil {
+		s.log.Error("Failed to load event for waitlist closure", zap.Error(err), zap.String("event_id", c.EventID))
+		return
+	}
+	for _, entry := range c.Expired {
+		user, err := s.users.GetByID(ctx, entry.UserID)
+		if err != nil || user == nil {
+			s.log.Error("Failed to load waitlisted user", zap.Error(err), zap.String("user_id", entry.UserID))
+			continue
+		}
+		_ = s.mailer.For(event.OrganizerID).SendWaitlistClosedEmail(user.Email, event.Name)
+	}
+	s.log.Info("Expired waitlist entries", zap.String("event_id", c.EventID), zap.Int("count", len(c.Expired)))
 }
 
 // RunPeriodicCheck runs the expired events check periodically
 func (s *EventStatusChecker) RunPeriodicCheck(ctx context.Context, interval time.Duration) {
-	ticker := time.NewTicker(interval)
+	ticker := s.clock.NewTicker(interval)
 	defer ticker.Stop()
 
 	s.log.Info("Starting periodic event status checker", zap.Duration("interval", interval))
@@ -48,11 +203,14 @@ func (s *EventStatusChecker) RunPeriodicCheck(ctx context.Context, interval time
 		case <-ctx.Done():
 			s.log.Info("Stopping periodic event status checker")
 			return
-		case <-ticker.C:
+		case <-ticker.C():
 			_, err := s.CheckAndUpdateExpiredEvents(ctx)
 			if err != nil {
 				s.log.Error("Periodic check failed", zap.Error(err))
 			}
+			_, _ = s.CloseWaitlists(ctx)
+			_, _ = s.ArchiveFinishedEventSeats(ctx)
+			_, _ = s.SweepOrphanKeys(ctx)
 		}
 	}
 }
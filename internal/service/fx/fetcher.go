@@ -0,0 +1,130 @@
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/clock"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/fx"
+)
+
+// maxResponseBytes caps how much of a rates response is read.
+const maxResponseBytes = 1 << 20
+
+// Fetcher pulls the day's exchange rates from an HTTP provider and stores them as a
+// snapshot. Providers are expected to answer with {"base": "USD", "date": "2006-01-02",
+// "rates": {"EUR": 0.92, ...}}; "base_code" is accepted for "base", and a missing date
+// means today.
+type Fetcher struct {
+	log    *zap.Logger
+	repo   *fx.FXRepository
+	url    string
+	client *http.Client
+	clock  clock.Clock
+}
+
+func NewFetcher(log *zap.Logger, repo *fx.FXRepository, url string) *Fetcher {
+	return &Fetcher{
+		log:    log,
+		repo:   repo,
+		url:    url,
+		client: &http.Client{Timeout: 30 * time.Second},
+		clock:  clock.Real{},
+	}
+}
+
+// WithClock replaces the wall clock used to stamp snapshots.
+func (f *Fetcher) WithClock(c clock.Clock) *Fetcher {
+	f.clock = c
+	return f
+}
+
+type ratesResponse struct {
+	Base     string             `json:"base"`
+	BaseCode string             `json:"base_code"`
+	Date     string             `json:"date"`
+	Rates    map[string]float64 `json:"rates"`
+}
+
+// Fetch downloads and stores one snapshot.
+func (f *Fetcher) Fetch(ctx context.Context) (*fx.Snapshot, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fx provider returned %s", resp.Status)
+	}
+
+	var body ratesResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decode fx rates: %w", err)
+	}
+	if body.Base == "" {
+		body.Base = body.BaseCode
+	}
+	base, err := Normalize(body.Base)
+	if err != nil {
+		return nil, fmt.Errorf("fx provider base: %w", err)
+	}
+
+	now := f.clock.Now().UTC()
+	snap := &fx.Snapshot{
+		AsOf:      time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC),
+		Base:      base,
+		Rates:     make(map[string]float64, len(body.Rates)),
+		FetchedAt: now,
+	}
+	if body.Date != "" {
+		if d, err := time.Parse("2006-01-02", body.Date); err == nil {
+			snap.AsOf = d
+		}
+	}
+	for code, rate := range body.Rates {
+		code, err := Normalize(code)
+		if err != nil || rate <= 0 || code == base {
+			continue
+		}
+		snap.Rates[code] = rate
+	}
+	if len(snap.Rates) == 0 {
+		return nil, fmt.Errorf("fx provider returned no usable rates")
+	}
+
+	if err := f.repo.Save(ctx, snap); err != nil {
+		return nil, err
+	}
+	f.log.Info("Stored FX snapshot", zap.Time("as_of", snap.AsOf), zap.String("base", base), zap.Int("currencies", len(snap.Rates)))
+	return snap, nil
+}
+
+// RunPeriodic fetches a snapshot every interval until ctx is done
+func (f *Fetcher) RunPeriodic(ctx context.Context, interval time.Duration) {
+	ticker := f.clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	f.log.Info("Starting FX rates fetcher", zap.Duration("interval", interval))
+
+	for {
+		select {
+		case <-ctx.Done():
+			f.log.Info("Stopping FX rates fetcher")
+			return
+		case <-ticker.C():
+			if _, err := f.Fetch(ctx); err != nil {
+				f.log.Error("Failed to fetch FX rates", zap.Error(err))
+			}
+		}
+	}
+}
@@ -0,0 +1,147 @@
+// Package fx converts event prices into a buyer's preferred currency for display, using
+// the daily rate snapshots stored by the Fetcher. Charges are always made in the event's
+// own currency; converted amounts are informational.
+package fx
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/clock"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/fx"
+)
+
+// cacheTTL bounds how long a process keeps a snapshot before checking for a newer one.
+const cacheTTL = 10 * time.Minute
+
+var (
+	ErrInvalidCurrency     = errors.New("currency must be a 3-letter ISO 4217 code")
+	ErrUnsupportedCurrency = errors.New("no exchange rate for currency")
+)
+
+// Normalize upper-cases code and checks it looks like an ISO 4217 code.
+func Normalize(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 3 {
+		return "", ErrInvalidCurrency
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return "", ErrInvalidCurrency
+		}
+	}
+	return code, nil
+}
+
+// Quote is an amount converted into Currency with the rate and snapshot date used.
+type Quote struct {
+	Currency string    `json:"currency"`
+	Amount   float64   `json:"amount"`
+	Rate     float64   `json:"rate"`
+	AsOf     time.Time `json:"as_of"`
+}
+
+// Rates serves conversions from the latest stored snapshot, cached in memory. It is safe
+// for concurrent use.
+type Rates struct {
+	log   *zap.Logger
+	repo  *fx.FXRepository
+	clock clock.Clock
+
+	mu       sync.Mutex
+	snap     *fx.Snapshot
+	loadedAt time.Time
+}
+
+func NewRates(log *zap.Logger, repo *fx.FXRepository) *Rates {
+	return &Rates{log: log, repo: repo, clock: clock.Real{}}
+}
+
+// WithClock replaces the wall clock behind the snapshot cache.
+func (r *Rates) WithClock(c clock.Clock) *Rates {
+	r.clock = c
+	return r
+}
+
+func (r *Rates) latest(ctx context.Context) (*fx.Snapshot, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.snap != nil && r.clock.Now().Sub(r.loadedAt) < cacheTTL {
+		return r.snap, nil
+	}
+	snap, err := r.repo.Latest(ctx)
+	if err != nil {
+		// Serve the stale snapshot rather than failing every price display
+		if r.snap != nil {
+			r.log.Warn("Failed to refresh FX rates, using cached snapshot", zap.Error(err))
+			return r.snap, nil
+		}
+		return nil, err
+	}
+	r.snap, r.loadedAt = snap, r.clock.Now()
+	return snap, nil
+}
+
+// Rate returns units of to per one unit of from, and the date of the snapshot it came from.
+func (r *Rates) Rate(ctx context.Context, from, to string) (float64, time.Time, error) {
+	from, err := Normalize(from)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	if to, err = Normalize(to); err != nil {
+		return 0, time.Time{}, err
+	}
+	snap, err := r.latest(ctx)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	if from == to {
+		var asOf time.Time
+		if snap != nil {
+			asOf = snap.AsOf
+		}
+		return 1, asOf, nil
+	}
+	if snap == nil {
+		return 0, time.Time{}, ErrUnsupportedCurrency
+	}
+	fromRate, ok := rateOf(snap, from)
+	if !ok {
+		return 0, time.Time{}, ErrUnsupportedCurrency
+	}
+	toRate, ok := rateOf(snap, to)
+	if !ok {
+		return 0, time.Time{}, ErrUnsupportedCurrency
+	}
+	return toRate / fromRate, snap.AsOf, nil
+}
+
+// Convert converts amount from one currency to another, rounded to cents.
+func (r *Rates) Convert(ctx context.Context, amount float64, from, to string) (*Quote, error) {
+	rate, asOf, err := r.Rate(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	code, _ := Normalize(to)
+	return &Quote{Currency: code, Amount: Round(amount * rate), Rate: rate, AsOf: asOf}, nil
+}
+
+// Round rounds a money amount to two decimal places.
+func Round(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// rateOf returns units of code per unit of the snapshot's base; the base itself is 1.
+func rateOf(snap *fx.Snapshot, code string) (float64, bool) {
+	if code == snap.Base {
+		return 1, true
+	}
+	rate, ok := snap.Rates[code]
+	return rate, ok && rate > 0
+}
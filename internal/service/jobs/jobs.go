@@ -0,0 +1,233 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/jobs"
+)
+
+const (
+	// MaxErrorSamples caps the item errors kept on a job.
+	MaxErrorSamples = 20
+
+	// flushEvery and flushInterval bound how much progress is held before it's written.
+	flushEvery    = 100
+	flushInterval = time.Second
+
+	heartbeatInterval = 30 * time.Second
+	// staleAfter is how long a job may go without a heartbeat before it counts as abandoned.
+	staleAfter = 3 * heartbeatInterval
+)
+
+var errPanic = errors.New("job panicked")
+
+// Func does a job's work, reporting progress through p. Its result is stored on the job as
+// JSON; an error fails the job.
+type Func func(ctx context.Context, p *Progress) (any, error)
+
+// Runner runs admin jobs in the background, at most a fixed number at once; the rest wait
+// in the queued state. While a job runs the runner heartbeats it, and jobs whose server
+// stopped are failed after a few missed heartbeats.
+type Runner struct {
+	log   *zap.Logger
+	repo  *jobs.JobsRepository
+	slots chan struct{}
+
+	mu     sync.Mutex
+	active map[string]bool
+}
+
+func NewRunner(log *zap.Logger, repo *jobs.JobsRepository, concurrency int) *Runner {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &Runner{log: log, repo: repo, slots: make(chan struct{}, concurrency), active: make(map[string]bool)}
+}
+
+// Start queues a job of kind and runs fn for it in the background. The returned job is
+// queued; poll Get for its progress.
+func (r *Runner) Start(ctx context.Context, kind string, eventID *string, fn Func) (*jobs.Job, error) {
+	j, err := r.repo.Create(ctx, kind, eventID)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	r.active[j.ID] = true
+	r.mu.Unlock()
+	go r.run(j, fn)
+	return j, nil
+}
+
+func (r *Runner) Get(ctx context.Context, id string) (*jobs.Job, error) {
+	return r.repo.Get(ctx, id)
+}
+
+// List returns jobs newest first, filtered by kind and state when they're set.
+func (r *Runner) List(ctx context.Context, kind, state string, limit, offset int) ([]*jobs.Job, error) {
+	return r.repo.List(ctx, kind, state, limit, offset)
+}
+
+func (r *Runner) run(j *jobs.Job, fn Func) {
+	ctx := context.Background()
+	defer func() {
+		r.mu.Lock()
+		delete(r.active, j.ID)
+		r.mu.Unlock()
+	}()
+
+	r.slots <- struct{}{}
+	defer func() { <-r.slots }()
+
+	log := r.log.With(zap.String("job_id", j.ID), zap.String("kind", j.Kind))
+	if err := r.repo.Start(ctx, j.ID); err != nil {
+		log.Error("Failed to start job", zap.Error(err))
+	}
+	log.Info("Job started")
+
+	p := &Progress{repo: r.repo, id: j.ID, last: time.Now()}
+	result, err := r.call(ctx, fn, p)
+	p.flush(ctx)
+
+	var errMsg *string
+	var raw json.RawMessage
+	if err != nil {
+		msg := err.Error()
+		errMsg = &msg
+	} else if result != nil {
+		if raw, err = json.Marshal(result); err != nil {
+			msg := "encode result: " + err.Error()
+			errMsg = &msg
+		}
+	}
+	if err := r.repo.Finish(ctx, j.ID, raw, errMsg); err != nil {
+		log.Error("Failed to record job outcome", zap.Error(err))
+	}
+	if errMsg != nil {
+		log.Error("Job failed", zap.String("error", *errMsg))
+		return
+	}
+	log.Info("Job completed")
+}
+
+// call runs fn, turning a panic into an error so the job is failed rather than left running.
+func (r *Runner) call(ctx context.Context, fn Func, p *Progress) (result any, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			r.log.Error("Job panicked", zap.Any("panic", v), zap.String("job_id", p.id))
+			err = errPanic
+		}
+	}()
+	return fn(ctx, p)
+}
+
+// Run heartbeats this runner's jobs and fails abandoned ones until ctx is done.
+func (r *Runner) Run(ctx context.Context) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		r.mu.Lock()
+		ids := make([]string, 0, len(r.active))
+		for id := range r.active {
+			ids = append(ids, id)
+		}
+		r.mu.Unlock()
+		if len(ids) > 0 {
+			if err := r.repo.Touch(ctx, ids); err != nil {
+				r.log.Error("Failed to heartbeat jobs", zap.Error(err))
+			}
+		}
+		if n, err := r.repo.FailStale(ctx, staleAfter); err != nil {
+			r.log.Error("Failed to fail abandoned jobs", zap.Error(err))
+		} else if n > 0 {
+			r.log.Warn("Failed abandoned jobs", zap.Int64("count", n))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Progress collects a running job's counters and writes them out in batches.
+type Progress struct {
+	repo *jobs.JobsRepository
+	id   string
+
+	mu        sync.Mutex
+	succeeded int
+	failed    int
+	samples   []string
+	sampled   int
+	last      time.Time
+}
+
+// SetTotal records how many items the job will process.
+func (p *Progress) SetTotal(ctx context.Context, total int) error {
+	return p.repo.SetTotal(ctx, p.id, total)
+}
+
+// Succeed counts an item done.
+func (p *Progress) Succeed(ctx context.Context) {
+	p.mu.Lock()
+	p.succeeded++
+	p.mu.Unlock()
+	p.maybeFlush(ctx)
+}
+
+// Fail counts an item that failed, keeping sample among the job's error samples while
+// there is room.
+func (p *Progress) Fail(ctx context.Context, sample string) {
+	p.mu.Lock()
+	p.failed++
+	if p.sampled < MaxErrorSamples {
+		p.samples = append(p.samples, sample)
+		p.sampled++
+	}
+	p.mu.Unlock()
+	p.maybeFlush(ctx)
+}
+
+// Add counts several items at once, for work tracked in bulk elsewhere.
+func (p *Progress) Add(ctx context.Context, succeeded, failed int) {
+	p.mu.Lock()
+	p.succeeded += succeeded
+	p.failed += failed
+	p.mu.Unlock()
+	p.maybeFlush(ctx)
+}
+
+func (p *Progress) maybeFlush(ctx context.Context) {
+	p.mu.Lock()
+	due := p.succeeded+p.failed >= flushEvery || time.Since(p.last) >= flushInterval
+	p.mu.Unlock()
+	if due {
+		p.flush(ctx)
+	}
+}
+
+func (p *Progress) flush(ctx context.Context) {
+	p.mu.Lock()
+	succeeded, failed, samples := p.succeeded, p.failed, p.samples
+	p.succeeded, p.failed, p.samples = 0, 0, nil
+	p.last = time.Now()
+	p.mu.Unlock()
+	if succeeded == 0 && failed == 0 {
+		return
+	}
+	if err := p.repo.AddProgress(ctx, p.id, succeeded, failed, samples); err != nil {
+		// Put the counts back so the next flush writes them
+		p.mu.Lock()
+		p.succeeded += succeeded
+		p.failed += failed
+		p.samples = append(samples, p.samples...)
+		p.mu.Unlock()
+	}
+}
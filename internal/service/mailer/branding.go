@@ -0,0 +1,232 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"net/mail"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/mailer"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/branding"
+)
+
+// defaultBrandName signs emails when no branding sets a name.
+const defaultBrandName = "Evently"
+
+const (
+	// brandingTTL is how long loaded branding is used before it is read again, which bounds
+	// how long a change takes to reach emails sent by other processes.
+	brandingTTL = time.Minute
+	// brandingTimeout bounds the lookup behind Send, which callers make without a context.
+	brandingTimeout = 5 * time.Second
+
+	maxBrandNameLen = 100
+	maxFooterLen    = 2000
+	maxLegalTextLen = 4000
+)
+
+var ErrInvalidBranding = errors.New("invalid branding")
+
+// Brands resolves the branding emails are signed with: an organizer's over the platform's
+// over the defaults, field by field. Lookups are cached for brandingTTL, and one that fails
+// falls back to what could be loaded, so a database hiccup never holds up an email.
+type Brands struct {
+	log  *zap.Logger
+	repo *branding.BrandingRepository
+
+	mu    sync.Mutex
+	cache map[string]cachedBrand
+}
+
+type cachedBrand struct {
+	b       *branding.Branding
+	expires time.Time
+}
+
+func NewBrands(log *zap.Logger, repo *branding.BrandingRepository) *Brands {
+	return &Brands{log: log, repo: repo, cache: make(map[string]cachedBrand)}
+}
+
+// Get returns the stored branding of the organizer, or the platform's if organizerID is nil,
+// without resolving it; nil if none was set.
+func (b *Brands) Get(ctx context.Context, organizerID *string) (*branding.Branding, error) {
+	return b.repo.Get(ctx, organizerID)
+}
+
+// Set validates and stores br, which replaces the branding of br.OrganizerID or the
+// platform's, and drops this process's cache so it applies at once here.
+func (b *Brands) Set(ctx context.Context, br *branding.Branding, adminID string) (*branding.Branding, error) {
+	if err := validateBranding(br); err != nil {
+		return nil, err
+	}
+	out, err := b.repo.Set(ctx, br, adminID)
+	if err != nil {
+		return nil, err
+	}
+	b.invalidate()
+	return out, nil
+}
+
+// Delete removes the organizer's branding, reporting whether there was any.
+func (b *Brands) Delete(ctx context.Context, organizerID string) (bool, error) {
+	ok, err := b.repo.Delete(ctx, organizerID)
+	if err != nil {
+		return false, err
+	}
+	b.invalidate()
+	return ok, nil
+}
+
+// Resolve returns the branding emails for the organizer are sent with, or the platform's
+// if organizerID is nil.
+func (b *Brands) Resolve(ctx context.Context, organizerID *string) *branding.Branding {
+	out := &branding.Branding{Name: defaultBrandName}
+	merge(out, b.load(ctx, nil))
+	if organizerID != nil {
+		merge(out, b.load(ctx, organizerID))
+		out.OrganizerID = organizerID
+	}
+	return out
+}
+
+// load returns the stored branding of organizerID through the cache, nil if there is none
+// or it couldn't be read.
+func (b *Brands) load(ctx context.Context, organizerID *string) *branding.Branding {
+	key := ""
+	if organizerID != nil {
+		key = *organizerID
+	}
+	b.mu.Lock()
+	c, ok := b.cache[key]
+	b.mu.Unlock()
+	if ok && time.Now().Before(c.expires) {
+		return c.b
+	}
+
+	br, err := b.repo.Get(ctx, organizerID)
+	if err != nil {
+		b.log.Error("Failed to load email branding", zap.Error(err), zap.Stringp("organizer_id", organizerID))
+		// Keep what was cached, if anything, rather than sign with less
+		return c.b
+	}
+	b.mu.Lock()
+	b.cache[key] = cachedBrand{b: br, expires: time.Now().Add(brandingTTL)}
+	b.mu.Unlock()
+	return br
+}
+
+func (b *Brands) invalidate() {
+	b.mu.Lock()
+	b.cache = make(map[string]cachedBrand)
+	b.mu.Unlock()
+}
+
+// merge overwrites dst's fields with src's non-empty ones.
+func merge(dst, src *branding.Branding) {
+	if src == nil {
+		return
+	}
+	for _, f := range []struct{ dst, src *string }{
+		{&dst.Name, &src.Name},
+		{&dst.Footer, &src.Footer},
+		{&dst.SupportEmail, &src.SupportEmail},
+		{&dst.LogoURL, &src.LogoURL},
+		{&dst.LegalText, &src.LegalText},
+	} {
+		if *f.src != "" {
+			*f.dst = *f.src
+		}
+	}
+}
+
+func validateBranding(b *branding.Branding) error {
+	b.Name = strings.TrimSpace(b.Name)
+	b.SupportEmail = strings.TrimSpace(b.SupportEmail)
+	b.LogoURL = strings.TrimSpace(b.LogoURL)
+	b.Footer = strings.TrimSpace(b.Footer)
+	b.LegalText = strings.TrimSpace(b.LegalText)
+	switch {
+	case len(b.Name) > maxBrandNameLen || strings.ContainsAny(b.Name, "\r\n"):
+		return fmt.Errorf("%w: name must be a single line of at most %d characters", ErrInvalidBranding, maxBrandNameLen)
+	case len(b.Footer) > maxFooterLen:
+		return fmt.Errorf("%w: footer must be at most %d characters", ErrInvalidBranding, maxFooterLen)
+	case len(b.LegalText) > maxLegalTextLen:
+		return fmt.Errorf("%w: legal_text must be at most %d characters", ErrInvalidBranding, maxLegalTextLen)
+	}
+	if b.SupportEmail != "" {
+		if a, err := mail.ParseAddress(b.SupportEmail); err != nil || a.Address != b.SupportEmail {
+			return fmt.Errorf("%w: support_email must be a plain email address", ErrInvalidBranding)
+		}
+	}
+	if b.LogoURL != "" {
+		u, err := url.Parse(b.LogoURL)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return fmt.Errorf("%w: logo_url must be an https URL", ErrInvalidBranding)
+		}
+	}
+	return nil
+}
+
+// BrandedSender signs every email with the branding of its organizer, or the platform's,
+// before handing it to the sender behind it. Branding is applied at send time, so emails
+// waiting in the queue or a broadcast pick up changes made since they were rendered.
+type BrandedSender struct {
+	sender mailer.Sender
+	brands *Brands
+}
+
+func NewBrandedSender(sender mailer.Sender, brands *Brands) *BrandedSender {
+	return &BrandedSender{sender: sender, brands: brands}
+}
+
+func (s *BrandedSender) Send(m mailer.Mail) error {
+	ctx, cancel := context.WithTimeout(context.Background(), brandingTimeout)
+	defer cancel()
+	return s.sender.Send(applyBranding(m, s.brands.Resolve(ctx, m.OrganizerID)))
+}
+
+// applyBranding signs m's body with b, and adds an HTML version with b's logo.
+func applyBranding(m mailer.Mail, b *branding.Branding) mailer.Mail {
+	var text strings.Builder
+	text.WriteString(m.Body)
+	fmt.Fprintf(&text, "\nBest regards,\n%s Team\n", b.Name)
+	if b.SupportEmail != "" {
+		fmt.Fprintf(&text, "\nQuestions? Contact us at %s\n", b.SupportEmail)
+	}
+	if b.Footer != "" {
+		fmt.Fprintf(&text, "\n%s\n", b.Footer)
+	}
+	if b.LegalText != "" {
+		fmt.Fprintf(&text, "\n--\n%s\n", b.LegalText)
+	}
+
+	var h strings.Builder
+	h.WriteString(`<!DOCTYPE html><html><body style="font-family:Arial,sans-serif;color:#222;max-width:600px;margin:0 auto">`)
+	if b.LogoURL != "" {
+		fmt.Fprintf(&h, `<p><img src="%s" alt="%s" style="max-height:60px"></p>`, html.EscapeString(b.LogoURL), html.EscapeString(b.Name))
+	}
+	fmt.Fprintf(&h, `<div style="white-space:pre-wrap">%s</div>`, html.EscapeString(strings.TrimSpace(m.Body)))
+	fmt.Fprintf(&h, `<p>Best regards,<br>%s Team</p>`, html.EscapeString(b.Name))
+	if b.SupportEmail != "" {
+		e := html.EscapeString(b.SupportEmail)
+		fmt.Fprintf(&h, `<p>Questions? Contact us at <a href="mailto:%s">%s</a></p>`, e, e)
+	}
+	if b.Footer != "" {
+		fmt.Fprintf(&h, `<p style="white-space:pre-wrap;color:#666;font-size:13px">%s</p>`, html.EscapeString(b.Footer))
+	}
+	if b.LegalText != "" {
+		fmt.Fprintf(&h, `<hr><p style="white-space:pre-wrap;color:#999;font-size:11px">%s</p>`, html.EscapeString(b.LegalText))
+	}
+	h.WriteString(`</body></html>`)
+
+	m.Body = text.String()
+	m.HTML = h.String()
+	return m
+}
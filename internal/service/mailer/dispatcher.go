@@ -0,0 +1,229 @@
+package mailer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/mailer"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/metrics"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/notifications"
+)
+
+const (
+	// dispatchChunk is how many recipients are claimed and recorded at a time.
+	dispatchChunk = 200
+	// dispatchLease is how long a claim is held before another sender may take it over.
+	dispatchLease = 5 * time.Minute
+	// dispatchMaxAttempts bounds retries of one recipient before they're marked failed.
+	dispatchMaxAttempts = 5
+
+	minThrottleBackoff = 2 * time.Second
+	maxThrottleBackoff = 2 * time.Minute
+)
+
+// Dispatcher sends mass notifications as persistent batches. Recipients are claimed in
+// chunks and sent by parallel workers under a shared rate limit, and every chunk's results
+// are recorded before the next is claimed, so a restarted server resumes a batch where it
+// stopped. A recipient whose send was in flight when the process died may get the email
+// twice. When the mail server pushes back with a 4xx reply, every worker pauses, backing off
+// further while it keeps doing so.
+type Dispatcher struct {
+	log     *zap.Logger
+	repo    *notifications.NotificationsRepository
+	sender  mailer.Sender
+	workers int
+	// pace ticks once per allowed send; nil when sends aren't rate limited
+	pace <-chan time.Time
+
+	mu       sync.Mutex
+	running  map[string]bool
+	backoff  time.Duration
+	resumeAt time.Time
+}
+
+// NewDispatcher sends with workers goroutines per batch, at most ratePerSecond emails a second
+// across all batches; zero or less means no limit.
+func NewDispatcher(log *zap.Logger, repo *notifications.NotificationsRepository, sender mailer.Sender, workers, ratePerSecond int) *Dispatcher {
+	if workers < 1 {
+		workers = 1
+	}
+	d := &Dispatcher{log: log, repo: repo, sender: sender, workers: workers, running: make(map[string]bool)}
+	if ratePerSecond > 0 {
+		d.pace = time.NewTicker(time.Second / time.Duration(ratePerSecond)).C
+	}
+	return d
+}
+
+// Enqueue queues one email to every address in emails and starts sending it in the background.
+// Repeated addresses get a single email, signed with the branding of organizerID or the
+// platform's if it is nil. It returns nil if there is nobody to send to.
+func (d *Dispatcher) Enqueue(ctx context.Context, kind string, eventID, organizerID *string, subject, body string, emails []string) (*notifications.Batch, error) {
+	seen := make(map[string]bool, len(emails))
+	unique := make([]string, 0, len(emails))
+	for _, e := range emails {
+		if e != "" && !seen[e] {
+			seen[e] = true
+			unique = append(unique, e)
+		}
+	}
+	if len(unique) == 0 {
+		return nil, nil
+	}
+
+	b, err := d.repo.Create(ctx, &notifications.Batch{Kind: kind, EventID: eventID, OrganizerID: organizerID, Subject: subject, Body: body}, unique)
+	if err != nil {
+		return nil, err
+	}
+	d.log.Info("Notification batch queued", zap.String("batch_id", b.ID), zap.String("kind", kind), zap.Int("total", b.Total))
+	go d.run(context.Background(), b)
+	return b, nil
+}
+
+// Run resumes unfinished batches now and every interval after, picking up batches left by a
+// crash or restart and claims a dead sender never finished.
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		d.resume(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *Dispatcher) resume(ctx context.Context) {
+	batches, err := d.repo.ListUnfinished(ctx)
+	if err != nil {
+		d.log.Error("Failed to list unfinished notification batches", zap.Error(err))
+		return
+	}
+	for _, b := range batches {
+		go d.run(ctx, b)
+	}
+}
+
+// run sends the batch chunk by chunk until nothing is left to claim. Only one run per batch
+// is active in a process; runs in other processes claim disjoint recipients.
+func (d *Dispatcher) run(ctx context.Context, b *notifications.Batch) {
+	d.mu.Lock()
+	if d.running[b.ID] {
+		d.mu.Unlock()
+		return
+	}
+	d.running[b.ID] = true
+	d.mu.Unlock()
+	defer func() {
+		d.mu.Lock()
+		delete(d.running, b.ID)
+		d.mu.Unlock()
+	}()
+
+	for ctx.Err() == nil {
+		emails, err := d.repo.Claim(ctx, b.ID, dispatchChunk, dispatchLease)
+		if err != nil {
+			d.log.Error("Failed to claim notification recipients", zap.Error(err), zap.String("batch_id", b.ID))
+			return
+		}
+		if len(emails) == 0 {
+			done, err := d.repo.Finish(ctx, b.ID)
+			if err != nil {
+				d.log.Error("Failed to finish notification batch", zap.Error(err), zap.String("batch_id", b.ID))
+			} else if done {
+				d.log.Info("Notification batch sent", zap.String("batch_id", b.ID), zap.String("kind", b.Kind))
+			}
+			return
+		}
+
+		results := d.sendChunk(ctx, b, emails)
+		if err := d.repo.Record(ctx, b.ID, results, dispatchMaxAttempts); err != nil {
+			// The claims expire and the chunk is sent again by a later run
+			d.log.Error("Failed to record notification results", zap.Error(err), zap.String("batch_id", b.ID))
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) sendChunk(ctx context.Context, b *notifications.Batch, emails []string) []notifications.Result {
+	results := make([]notifications.Result, len(emails))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < d.workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = d.send(ctx, b, emails[i])
+			}
+		}()
+	}
+	for i := range emails {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+func (d *Dispatcher) send(ctx context.Context, b *notifications.Batch, email string) notifications.Result {
+	if err := d.wait(ctx); err != nil {
+		return notifications.Result{Email: email, Error: err.Error(), Retry: true}
+	}
+	err := d.sender.Send(mailer.Mail{To: email, Subject: b.Subject, Body: b.Body, OrganizerID: b.OrganizerID})
+	throttled := err != nil && mailer.Throttled(err)
+	d.mu.Lock()
+	if throttled {
+		d.backoff = min(max(2*d.backoff, minThrottleBackoff), maxThrottleBackoff)
+		d.resumeAt = time.Now().Add(d.backoff)
+	} else if err == nil {
+		d.backoff = 0
+	}
+	d.mu.Unlock()
+
+	switch {
+	case err == nil:
+		metrics.NotificationsTotal.WithLabelValues(b.Kind, "sent").Inc()
+		return notifications.Result{Email: email}
+	case throttled:
+		metrics.NotificationsTotal.WithLabelValues(b.Kind, "throttled").Inc()
+		return notifications.Result{Email: email, Error: err.Error(), Retry: true}
+	case mailer.Temporary(err):
+		metrics.NotificationsTotal.WithLabelValues(b.Kind, "retried").Inc()
+		return notifications.Result{Email: email, Error: err.Error(), Retry: true}
+	default:
+		metrics.NotificationsTotal.WithLabelValues(b.Kind, "failed").Inc()
+		d.log.Warn("Notification email rejected", zap.Error(err), zap.String("batch_id", b.ID), zap.String("email", email))
+		return notifications.Result{Email: email, Error: err.Error()}
+	}
+}
+
+// wait blocks until the mail server is no longer backing us off and the rate limit allows
+// another send.
+func (d *Dispatcher) wait(ctx context.Context) error {
+	d.mu.Lock()
+	pause := time.Until(d.resumeAt)
+	d.mu.Unlock()
+	if pause > 0 {
+		t := time.NewTimer(pause)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+	if d.pace == nil {
+		return ctx.Err()
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-d.pace:
+		return nil
+	}
+}
@@ -1,16 +1,30 @@
 package mailer
 
 import (
-	"fmt"
+	"context"
+	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/samirwankhede/lewly-pgpyewj/internal/mailer"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/service/fx"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/notifications"
 )
 
 type MailerService struct {
 	log    *zap.Logger
 	sender mailer.Sender
+	// dispatcher sends broadcasts; without one they're sent inline, one by one
+	dispatcher *Dispatcher
+	// queue takes single emails to send in the background; without one they're sent inline
+	queue *MailQueue
+	// brands renders template previews with the platform's branding; sends are branded by
+	// the sender
+	brands *Brands
+	// organizerID is whose branding emails are signed with; nil for the platform's
+	organizerID *string
+	// bookingID is the booking emails are about, for the booking's trace; nil if none
+	bookingID *string
 }
 
 func NewMailerService(log *zap.Logger, sender mailer.Sender) *MailerService {
@@ -20,29 +34,123 @@ func NewMailerService(log *zap.Logger, sender mailer.Sender) *MailerService {
 	}
 }
 
-func (m *MailerService) SendPaymentRequestEmail(userEmail string, eventName string, amount float64, paymentLink string) error {
-	subject := fmt.Sprintf("Payment Required for %s", eventName)
-	body := fmt.Sprintf(`
-Dear User,
+// WithDispatcher sends broadcasts to many recipients through d in the background.
+func (m *MailerService) WithDispatcher(d *Dispatcher) *MailerService {
+	m.dispatcher = d
+	return m
+}
+
+// WithQueue sends single emails through q, which retries them, instead of inline.
+func (m *MailerService) WithQueue(q *MailQueue) *MailerService {
+	m.queue = q
+	return m
+}
+
+// WithBrands shows template previews with the platform's branding from b.
+func (m *MailerService) WithBrands(b *Brands) *MailerService {
+	m.brands = b
+	return m
+}
+
+// For returns a MailerService that signs its emails with the organizer's branding, for
+// emails about their events. A nil organizerID keeps the platform's.
+func (m *MailerService) For(organizerID *string) *MailerService {
+	scoped := *m
+	scoped.organizerID = organizerID
+	return &scoped
+}
+
+// About returns a MailerService whose emails are recorded as being about the booking, so
+// they show in its trace.
+func (m *MailerService) About(bookingID string) *MailerService {
+	scoped := *m
+	scoped.bookingID = &bookingID
+	return &scoped
+}
+
+// Queue returns the mail queue single emails go through, or nil if they're sent inline.
+func (m *MailerService) Queue() *MailQueue {
+	return m.queue
+}
+
+// send queues mail if there is a queue, or sends it now.
+func (m *MailerService) send(mail mailer.Mail) error {
+	if m.queue != nil {
+		return m.queue.Send(mail)
+	}
+	return m.sender.Send(mail)
+}
+
+// broadcast sends one email to every address in emails. With a dispatcher it returns the
+// queued batch to follow progress with; otherwise it sends before returning and the batch is nil.
+func (m *MailerService) broadcast(ctx context.Context, kind string, eventID string, subject, body string, emails []string) (*notifications.Batch, error) {
+	if m.dispatcher != nil {
+		return m.dispatcher.Enqueue(ctx, kind, &eventID, m.organizerID, subject, body, emails)
+	}
+	for _, email := range emails {
+		if err := m.sender.Send(mailer.Mail{To: email, Subject: subject, Body: body, OrganizerID: m.organizerID}); err != nil {
+			m.log.Error("Failed to send broadcast email", zap.Error(err), zap.String("kind", kind), zap.String("email", email))
+		}
+	}
+	return nil, nil
+}
+
+// BroadcastEventCancellation tells every paid attendee in emails that the event was cancelled.
+func (m *MailerService) BroadcastEventCancellation(ctx context.Context, eventID string, eventName string, refundAmount float64, emails []string) (*notifications.Batch, error) {
+	subject, body := renderEventCancellation(eventName, refundAmount)
+	return m.broadcast(ctx, "event_cancellation", eventID, subject, body, emails)
+}
 
-Your booking for "%s" is ready for payment.
+// BroadcastNewEvent announces a newly published event to the organizer's followers in emails.
+func (m *MailerService) BroadcastNewEvent(ctx context.Context, eventID string, organizerName string, eventName string, startTime time.Time, emails []string) (*notifications.Batch, error) {
+	subject, body := renderNewEvent(organizerName, eventName, startTime)
+	return m.broadcast(ctx, "new_event", eventID, subject, body, emails)
+}
+
+// BroadcastSubscriptionAlert announces a newly published event to the users in emails whose
+// category or tag subscriptions it matched.
+func (m *MailerService) BroadcastSubscriptionAlert(ctx context.Context, eventID string, eventName string, venue string, startTime time.Time, link string, emails []string) (*notifications.Batch, error) {
+	subject, body := renderSubscriptionAlert(eventName, venue, startTime, link)
+	return m.broadcast(ctx, "subscription_alert", eventID, subject, body, emails)
+}
+
+// SendSubscriptionDigestEmail lists the new events that matched the user's digest
+// subscriptions since their last digest.
+func (m *MailerService) SendSubscriptionDigestEmail(email string, events []DigestEvent) error {
+	subject, body := renderSubscriptionDigest(events)
+
+	mail := mailer.Mail{
+		To:          email,
+		Subject:     subject,
+		Body:        body,
+		OrganizerID: m.organizerID,
+		BookingID:   m.bookingID,
+	}
 
-Amount: $%.2f
-Payment Link: %s
+	err := m.send(mail)
+	if err != nil {
+		m.log.Error("Failed to send subscription digest email", zap.Error(err), zap.String("email", email))
+		return err
+	}
 
-Please complete your payment within 15 minutes to secure your booking.
+	m.log.Info("Subscription digest email sent", zap.String("email", email), zap.Int("events", len(events)))
+	return nil
+}
 
-Best regards,
-Evently Team
-`, eventName, amount, paymentLink)
+// SendPaymentRequestEmail asks for amount in currency. display, when set, is the amount
+// converted into the user's preferred currency and is shown alongside for reference.
+func (m *MailerService) SendPaymentRequestEmail(userEmail string, eventName string, amount float64, currency string, display *fx.Quote, paymentLink string) error {
+	subject, body := renderPaymentRequest(eventName, amount, currency, display, paymentLink)
 
 	mail := mailer.Mail{
-		To:      userEmail,
-		Subject: subject,
-		Body:    body,
+		To:          userEmail,
+		Subject:     subject,
+		Body:        body,
+		OrganizerID: m.organizerID,
+		BookingID:   m.bookingID,
 	}
 
-	err := m.sender.Send(mail)
+	err := m.send(mail)
 	if err != nil {
 		m.log.Error("Failed to send payment request email", zap.Error(err), zap.String("email", userEmail))
 		return err
@@ -52,26 +160,64 @@ Evently Team
 	return nil
 }
 
-func (m *MailerService) SendWaitlistPromotionEmail(userEmail string, eventName string) error {
-	subject := fmt.Sprintf("Great News! You're off the waitlist for %s", eventName)
-	body := fmt.Sprintf(`
-Dear User,
+// SendPaymentDelayedEmail tells the user their seats are held until holdUntil while the
+// payment link waits for the payment service.
+func (m *MailerService) SendPaymentDelayedEmail(userEmail string, eventName string, holdUntil time.Time) error {
+	subject, body := renderPaymentDelayed(eventName, holdUntil)
+
+	mail := mailer.Mail{
+		To:          userEmail,
+		Subject:     subject,
+		Body:        body,
+		OrganizerID: m.organizerID,
+		BookingID:   m.bookingID,
+	}
+
+	err := m.send(mail)
+	if err != nil {
+		m.log.Error("Failed to send payment delayed email", zap.Error(err), zap.String("email", userEmail))
+		return err
+	}
 
-Great news! A spot has opened up for "%s" and you're next in line!
+	m.log.Info("Payment delayed email sent", zap.String("email", userEmail), zap.String("event", eventName))
+	return nil
+}
+
+// SendBookingConfirmationEmail tells the buyer their booking is confirmed, with a link per
+// wallet format adding the tickets to their phone.
+func (m *MailerService) SendBookingConfirmationEmail(userEmail string, eventName string, venue string, startTime time.Time, seats []string, bookingID string, walletLinks map[string]string) error {
+	subject, body := renderBookingConfirmation(eventName, venue, startTime, seats, bookingID, walletLinks)
+
+	mail := mailer.Mail{
+		To:          userEmail,
+		Subject:     subject,
+		Body:        body,
+		OrganizerID: m.organizerID,
+		BookingID:   m.bookingID,
+	}
+
+	err := m.send(mail)
+	if err != nil {
+		m.log.Error("Failed to send booking confirmation email", zap.Error(err), zap.String("email", userEmail), zap.String("booking_id", bookingID))
+		return err
+	}
 
-You will receive a payment link soon.
+	m.log.Info("Booking confirmation email sent", zap.String("email", userEmail), zap.String("booking_id", bookingID))
+	return nil
+}
 
-Best regards,
-Evently Team
-`, eventName)
+func (m *MailerService) SendWaitlistPromotionEmail(userEmail string, eventName string) error {
+	subject, body := renderWaitlistPromotion(eventName)
 
 	mail := mailer.Mail{
-		To:      userEmail,
-		Subject: subject,
-		Body:    body,
+		To:          userEmail,
+		Subject:     subject,
+		Body:        body,
+		OrganizerID: m.organizerID,
+		BookingID:   m.bookingID,
 	}
 
-	err := m.sender.Send(mail)
+	err := m.send(mail)
 	if err != nil {
 		m.log.Error("Failed to send waitlist promotion email", zap.Error(err), zap.String("email", userEmail))
 		return err
@@ -81,29 +227,39 @@ Evently Team
 	return nil
 }
 
-func (m *MailerService) SendCancellationEmail(userEmail string, cancellationFee float64, paymentLink string) error {
-	subject := "Booking Cancellation - Refund Information"
-	body := fmt.Sprintf(`
-Dear User,
+func (m *MailerService) SendWaitlistClosedEmail(userEmail string, eventName string) error {
+	subject, body := renderWaitlistClosed(eventName)
 
-Your booking has been cancelled.
+	mail := mailer.Mail{
+		To:          userEmail,
+		Subject:     subject,
+		Body:        body,
+		OrganizerID: m.organizerID,
+		BookingID:   m.bookingID,
+	}
 
-Cancellation Fee: $%.2f
-Refund Link: %s
+	err := m.send(mail)
+	if err != nil {
+		m.log.Error("Failed to send waitlist closed email", zap.Error(err), zap.String("email", userEmail))
+		return err
+	}
 
-Please use the refund link to process your refund.
+	m.log.Info("Waitlist closed email sent", zap.String("email", userEmail), zap.String("event", eventName))
+	return nil
+}
 
-Best regards,
-Evently Team
-`, cancellationFee, paymentLink)
+func (m *MailerService) SendCancellationEmail(userEmail string, cancellationFee float64, paymentLink string) error {
+	subject, body := renderCancellation(cancellationFee, paymentLink)
 
 	mail := mailer.Mail{
-		To:      userEmail,
-		Subject: subject,
-		Body:    body,
+		To:          userEmail,
+		Subject:     subject,
+		Body:        body,
+		OrganizerID: m.organizerID,
+		BookingID:   m.bookingID,
 	}
 
-	err := m.sender.Send(mail)
+	err := m.send(mail)
 	if err != nil {
 		m.log.Error("Failed to send cancellation email", zap.Error(err), zap.String("email", userEmail))
 		return err
@@ -113,30 +269,40 @@ Evently Team
 	return nil
 }
 
-func (m *MailerService) SendEventCancellationEmail(userEmail string, eventName string, refundAmount float64) error {
-	subject := fmt.Sprintf("Event Cancelled: %s", eventName)
-	body := fmt.Sprintf(`
-Dear User,
+// SendReseatEmail tells a customer support moved their booking from one set of seats to another.
+func (m *MailerService) SendReseatEmail(userEmail string, eventName string, from []string, to []string, reason string) error {
+	subject, body := renderReseat(eventName, from, to, reason)
 
-We regret to inform you that the event "%s" has been cancelled.
-
-Refund Amount: $%.2f
+	mail := mailer.Mail{
+		To:          userEmail,
+		Subject:     subject,
+		Body:        body,
+		OrganizerID: m.organizerID,
+		BookingID:   m.bookingID,
+	}
 
-Your refund amount arrive shortly.
+	err := m.send(mail)
+	if err != nil {
+		m.log.Error("Failed to send reseat email", zap.Error(err), zap.String("email", userEmail))
+		return err
+	}
 
-We apologize for any inconvenience.
+	m.log.Info("Reseat email sent", zap.String("email", userEmail), zap.String("event", eventName))
+	return nil
+}
 
-Best regards,
-Evently Team
-`, eventName, refundAmount)
+func (m *MailerService) SendEventCancellationEmail(userEmail string, eventName string, refundAmount float64) error {
+	subject, body := renderEventCancellation(eventName, refundAmount)
 
 	mail := mailer.Mail{
-		To:      userEmail,
-		Subject: subject,
-		Body:    body,
+		To:          userEmail,
+		Subject:     subject,
+		Body:        body,
+		OrganizerID: m.organizerID,
+		BookingID:   m.bookingID,
 	}
 
-	err := m.sender.Send(mail)
+	err := m.send(mail)
 	if err != nil {
 		m.log.Error("Failed to send event cancellation email", zap.Error(err), zap.String("email", userEmail))
 		return err
@@ -147,34 +313,200 @@ Evently Team
 }
 
 func (m *MailerService) SendPasswordChangeOTPEmail(userEmail string, otp string) error {
-	subject := "Password Change OTP"
-	body := fmt.Sprintf(`
-Dear User,
+	subject, body := renderPasswordChangeOTP(otp)
 
-You have requested to change your password.
+	mail := mailer.Mail{
+		To:          userEmail,
+		Subject:     subject,
+		Body:        body,
+		OrganizerID: m.organizerID,
+		BookingID:   m.bookingID,
+	}
 
-Your OTP is: %s
+	err := m.send(mail)
+	if err != nil {
+		m.log.Error("Failed to send password change OTP email", zap.Error(err), zap.String("email", userEmail))
+		return err
+	}
 
-This OTP will expire in 15 minutes.
+	m.log.Info("Password change OTP email sent", zap.String("email", userEmail))
+	return nil
+}
+
+// SendAccountMergeCodeEmail sends one side of an account merge its confirmation code.
+func (m *MailerService) SendAccountMergeCodeEmail(userEmail string, code string, keptEmail string, mergedEmail string) error {
+	subject, body := renderAccountMergeCode(code, keptEmail, mergedEmail, userEmail == keptEmail)
+
+	mail := mailer.Mail{
+		To:          userEmail,
+		Subject:     subject,
+		Body:        body,
+		OrganizerID: m.organizerID,
+		BookingID:   m.bookingID,
+	}
 
-If you did not request this change, please ignore this email.
+	err := m.send(mail)
+	if err != nil {
+		m.log.Error("Failed to send account merge email", zap.Error(err), zap.String("email", userEmail))
+		return err
+	}
+
+	m.log.Info("Account merge email sent", zap.String("email", userEmail))
+	return nil
+}
 
-Best regards,
-Evently Team
-`, otp)
+func (m *MailerService) SendNewEventEmail(userEmail string, organizerName string, eventName string, startTime time.Time) error {
+	subject, body := renderNewEvent(organizerName, eventName, startTime)
 
 	mail := mailer.Mail{
-		To:      userEmail,
-		Subject: subject,
-		Body:    body,
+		To:          userEmail,
+		Subject:     subject,
+		Body:        body,
+		OrganizerID: m.organizerID,
+		BookingID:   m.bookingID,
 	}
 
-	err := m.sender.Send(mail)
+	err := m.send(mail)
 	if err != nil {
-		m.log.Error("Failed to send password change OTP email", zap.Error(err), zap.String("email", userEmail))
+		m.log.Error("Failed to send new event email", zap.Error(err), zap.String("email", userEmail))
 		return err
 	}
 
-	m.log.Info("Password change OTP email sent", zap.String("email", userEmail))
+	m.log.Info("New event email sent", zap.String("email", userEmail), zap.String("event", eventName))
+	return nil
+}
+
+func (m *MailerService) SendSalesMilestoneEmail(email string, eventName string, percent int, sold int, capacity int) error {
+	subject, body := renderSalesMilestone(eventName, percent, sold, capacity)
+
+	mail := mailer.Mail{
+		To:          email,
+		Subject:     subject,
+		Body:        body,
+		OrganizerID: m.organizerID,
+		BookingID:   m.bookingID,
+	}
+
+	err := m.send(mail)
+	if err != nil {
+		m.log.Error("Failed to send sales milestone email", zap.Error(err), zap.String("email", email))
+		return err
+	}
+
+	m.log.Info("Sales milestone email sent", zap.String("email", email), zap.String("event", eventName), zap.Int("percent", percent))
+	return nil
+}
+
+// SendConversionAlertEmail tells an admin that provider's hourly payment conversion fell
+// below threshold percent.
+func (m *MailerService) SendConversionAlertEmail(email string, provider string, percent float64, converted int, resolved int, threshold float64) error {
+	subject, body := renderConversionAlert(provider, percent, converted, resolved, threshold)
+
+	mail := mailer.Mail{
+		To:          email,
+		Subject:     subject,
+		Body:        body,
+		OrganizerID: m.organizerID,
+		BookingID:   m.bookingID,
+	}
+
+	err := m.send(mail)
+	if err != nil {
+		m.log.Error("Failed to send conversion alert email", zap.Error(err), zap.String("email", email))
+		return err
+	}
+
+	m.log.Info("Conversion alert email sent", zap.String("email", email), zap.String("provider", provider), zap.Float64("percent", percent))
+	return nil
+}
+
+// SendTransferClaimEmail sends the recipient of a booking transfer the link to accept it.
+func (m *MailerService) SendTransferClaimEmail(email string, fromName string, eventName string, startTime time.Time, seats []string, link string, expiresAt time.Time) error {
+	subject, body := renderTransferClaim(fromName, eventName, startTime, seats, link, expiresAt)
+
+	mail := mailer.Mail{
+		To:          email,
+		Subject:     subject,
+		Body:        body,
+		OrganizerID: m.organizerID,
+		BookingID:   m.bookingID,
+	}
+
+	err := m.send(mail)
+	if err != nil {
+		m.log.Error("Failed to send transfer claim email", zap.Error(err), zap.String("email", email))
+		return err
+	}
+
+	m.log.Info("Transfer claim email sent", zap.String("email", email), zap.String("event", eventName))
+	return nil
+}
+
+// SendMemberInviteEmail sends someone invited to co-organize an event the permissions
+// offered and the link to accept.
+func (m *MailerService) SendMemberInviteEmail(email string, fromName string, eventName string, startTime time.Time, permissions []string, link string, expiresAt time.Time) error {
+	subject, body := renderMemberInvite(fromName, eventName, startTime, permissions, link, expiresAt)
+
+	mail := mailer.Mail{
+		To:          email,
+		Subject:     subject,
+		Body:        body,
+		OrganizerID: m.organizerID,
+		BookingID:   m.bookingID,
+	}
+
+	err := m.send(mail)
+	if err != nil {
+		m.log.Error("Failed to send member invite email", zap.Error(err), zap.String("email", email))
+		return err
+	}
+
+	m.log.Info("Member invite email sent", zap.String("email", email), zap.String("event", eventName))
+	return nil
+}
+
+// SendSeatIntegrityAlertEmail tells an admin that count bookings were newly flagged for
+// untrustworthy seats, listing lines, one per booking, for the first of them.
+func (m *MailerService) SendSeatIntegrityAlertEmail(email string, count int, lines []string) error {
+	subject, body := renderSeatIntegrityAlert(count, lines)
+
+	mail := mailer.Mail{
+		To:          email,
+		Subject:     subject,
+		Body:        body,
+		OrganizerID: m.organizerID,
+		BookingID:   m.bookingID,
+	}
+
+	err := m.send(mail)
+	if err != nil {
+		m.log.Error("Failed to send seat integrity alert email", zap.Error(err), zap.String("email", email))
+		return err
+	}
+
+	m.log.Info("Seat integrity alert email sent", zap.String("email", email), zap.Int("bookings", count))
+	return nil
+}
+
+// SendEventInvitationEmail sends an invitee their code and invite link for a private event.
+// newAccount notes that an account was created for them, which they claim with a password reset.
+func (m *MailerService) SendEventInvitationEmail(email string, eventName string, startTime time.Time, code string, link string, newAccount bool) error {
+	subject, body := renderEventInvitation(eventName, startTime, code, link, newAccount)
+
+	mail := mailer.Mail{
+		To:          email,
+		Subject:     subject,
+		Body:        body,
+		OrganizerID: m.organizerID,
+		BookingID:   m.bookingID,
+	}
+
+	err := m.send(mail)
+	if err != nil {
+		m.log.Error("Failed to send event invitation email", zap.Error(err), zap.String("email", email))
+		return err
+	}
+
+	m.log.Info("Event invitation email sent", zap.String("email", email), zap.String("event", eventName))
 	return nil
 }
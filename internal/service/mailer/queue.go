@@ -0,0 +1,157 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/mailer"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/metrics"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/notifications"
+)
+
+const (
+	// queueChunk is how many queued emails are claimed at a time.
+	queueChunk = 50
+	// queueLease is how long a claim is held before another worker may take it over.
+	queueLease = 5 * time.Minute
+	// enqueueTimeout bounds the insert behind Send, which callers make without a context.
+	enqueueTimeout = 5 * time.Second
+)
+
+var (
+	ErrMailNotFound = errors.New("queued email not found")
+	ErrMailNotDead  = errors.New("only dead emails can be requeued")
+)
+
+// MailQueue persists single emails in Postgres instead of sending them inline, so a mail
+// server that is down or pushing back doesn't lose them. It is a mailer.Sender: Send only
+// queues, and Run, in the worker, sends what is due. A send that fails temporarily is
+// retried after a backoff doubling from baseBackoff up to maxBackoff; one rejected outright,
+// or still failing after maxAttempts, is left dead for admins to look at and requeue.
+type MailQueue struct {
+	log         *zap.Logger
+	repo        *notifications.NotificationsRepository
+	sender      mailer.Sender
+	maxAttempts int
+	baseBackoff time.Duration
+	maxBackoff  time.Duration
+}
+
+func NewMailQueue(log *zap.Logger, repo *notifications.NotificationsRepository, sender mailer.Sender, maxAttempts int, baseBackoff, maxBackoff time.Duration) *MailQueue {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	return &MailQueue{log: log, repo: repo, sender: sender, maxAttempts: maxAttempts, baseBackoff: baseBackoff, maxBackoff: maxBackoff}
+}
+
+// Send queues m. It fails only if the email couldn't be stored.
+func (q *MailQueue) Send(m mailer.Mail) error {
+	ctx, cancel := context.WithTimeout(context.Background(), enqueueTimeout)
+	defer cancel()
+	queued, err := q.repo.EnqueueMail(ctx, m.To, m.Subject, m.Body, m.OrganizerID, m.BookingID)
+	if err != nil {
+		return err
+	}
+	metrics.MailQueueTotal.WithLabelValues("queued").Inc()
+	q.log.Debug("Email queued", zap.String("mail_id", queued.ID), zap.String("email", m.To))
+	return nil
+}
+
+// Run sends due emails now and every interval after until ctx is done. Workers in other
+// processes claim disjoint emails.
+func (q *MailQueue) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		q.drain(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// drain sends chunk after chunk until nothing is due.
+func (q *MailQueue) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		due, err := q.repo.ClaimMail(ctx, queueChunk, queueLease)
+		if err != nil {
+			q.log.Error("Failed to claim queued emails", zap.Error(err))
+			return
+		}
+		if len(due) == 0 {
+			return
+		}
+		for _, m := range due {
+			q.send(ctx, m)
+		}
+	}
+}
+
+func (q *MailQueue) send(ctx context.Context, m *notifications.QueuedMail) {
+	err := q.sender.Send(mailer.Mail{To: m.To, Subject: m.Subject, Body: m.Body, OrganizerID: m.OrganizerID})
+	switch {
+	case err == nil:
+		err = q.repo.MarkMailSent(ctx, m.ID)
+		metrics.MailQueueTotal.WithLabelValues("sent").Inc()
+	case mailer.Temporary(err) && m.Attempts < q.maxAttempts:
+		next := time.Now().Add(q.backoff(m.Attempts))
+		q.log.Warn("Queued email failed, retrying", zap.Error(err), zap.String("mail_id", m.ID), zap.Int("attempts", m.Attempts), zap.Time("next_attempt_at", next))
+		err = q.repo.RetryMail(ctx, m.ID, err.Error(), next)
+		metrics.MailQueueTotal.WithLabelValues("retried").Inc()
+	default:
+		q.log.Error("Queued email dead", zap.Error(err), zap.String("mail_id", m.ID), zap.String("email", m.To), zap.Int("attempts", m.Attempts))
+		err = q.repo.KillMail(ctx, m.ID, err.Error())
+		metrics.MailQueueTotal.WithLabelValues("dead").Inc()
+	}
+	if err != nil {
+		// The claim expires and the email is sent again
+		q.log.Error("Failed to record queued email send", zap.Error(err), zap.String("mail_id", m.ID))
+	}
+}
+
+// backoff is how long to wait before the attempt after the given one: baseBackoff doubled
+// for every attempt after the first, at most maxBackoff.
+func (q *MailQueue) backoff(attempts int) time.Duration {
+	d := q.baseBackoff
+	for i := 1; i < attempts && d < q.maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, q.maxBackoff)
+}
+
+// Mail lists queued emails in status (all if empty), newest first, with counts per status.
+func (q *MailQueue) Mail(ctx context.Context, status string, limit, offset int) ([]*notifications.QueuedMail, map[string]int, error) {
+	list, err := q.repo.ListMail(ctx, status, limit, offset)
+	if err != nil {
+		return nil, nil, err
+	}
+	counts, err := q.repo.CountMail(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return list, counts, nil
+}
+
+// Requeue sends a dead email again with a fresh set of attempts.
+func (q *MailQueue) Requeue(ctx context.Context, id string) (*notifications.QueuedMail, error) {
+	m, err := q.repo.GetMail(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, ErrMailNotFound
+	}
+	ok, err := q.repo.RequeueMail(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrMailNotDead
+	}
+	return q.repo.GetMail(ctx, id)
+}
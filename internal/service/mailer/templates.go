@@ -0,0 +1,553 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/mailer"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/service/fx"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/branding"
+)
+
+var ErrUnknownTemplate = errors.New("unknown email template")
+
+// Template is an email the platform sends, with sample data for previews and test sends.
+type Template struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Subject, Body and HTML are the template rendered with its sample data and signed with
+	// the platform's branding
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+	HTML    string `json:"html"`
+}
+
+type templateDef struct {
+	description string
+	sample      func() (subject, body string)
+}
+
+// sampleTime is fixed so previews don't change between calls.
+var sampleTime = time.Date(2030, time.June, 14, 19, 30, 0, 0, time.UTC)
+
+// templates lists every email for previews; each sample goes through the same render
+// function the real send uses, so a preview can't drift from what attendees receive.
+var templates = map[string]templateDef{
+	"payment_request": {
+		description: "Sent by the worker once a booking is pending, with the payment link",
+		sample: func() (string, string) {
+			display := &fx.Quote{Currency: "EUR", Amount: 110.52, Rate: 0.921, AsOf: sampleTime}
+			return renderPaymentRequest("Sample Concert", 120, "USD", display, "https://evently.example/p/AbC123")
+		},
+	},
+	"booking_confirmation": {
+		description: "Sent when a booking is paid for and confirmed, with links adding the tickets to Apple or Google Wallet when those are configured",
+		sample: func() (string, string) {
+			return renderBookingConfirmation("Sample Concert", "Sample Arena", sampleTime, []string{"B12", "B13"}, "7d0c2f4e-0000-0000-0000-000000000000",
+				map[string]string{"apple": "https://evently.example/v1/bookings/7d0c2f4e-0000-0000-0000-000000000000/wallet?format=apple&expires=1907704800&sig=sample",
+					"google": "https://evently.example/v1/bookings/7d0c2f4e-0000-0000-0000-000000000000/wallet?format=google&expires=1907704800&sig=sample"})
+		},
+	},
+	"payment_delayed": {
+		description: "Sent by the worker instead of the payment request while the payment service is down",
+		sample:      func() (string, string) { return renderPaymentDelayed("Sample Concert", sampleTime) },
+	},
+	"waitlist_promotion": {
+		description: "Sent when a waitlisted user is handed freed seats",
+		sample:      func() (string, string) { return renderWaitlistPromotion("Sample Concert") },
+	},
+	"waitlist_closed": {
+		description: "Sent by the event status checker to users still waiting when an event's waitlist closes at its start",
+		sample:      func() (string, string) { return renderWaitlistClosed("Sample Concert") },
+	},
+	"booking_cancellation": {
+		description: "Sent when a user cancels a booking, with the cancellation fee and refund link",
+		sample: func() (string, string) {
+			return renderCancellation(12, "https://evently.example/v1/payment/refund?booking_id=sample")
+		},
+	},
+	"booking_reseat": {
+		description: "Sent when support moves a booking to other seats, with the old and new seats and the reason",
+		sample: func() (string, string) {
+			return renderReseat("Sample Concert", []string{"B12", "B13"}, []string{"C7", "C8"}, "Seat B12 is broken")
+		},
+	},
+	"event_cancellation": {
+		description: "Sent to every paid attendee when an admin cancels an event",
+		sample:      func() (string, string) { return renderEventCancellation("Sample Concert", 120) },
+	},
+	"password_otp": {
+		description: "Sent when a user requests a password change",
+		sample:      func() (string, string) { return renderPasswordChangeOTP("123456") },
+	},
+	"account_merge": {
+		description: "Sent to both accounts of a requested account merge, each with its own confirmation code",
+		sample: func() (string, string) {
+			return renderAccountMergeCode("4f9a1c", "kept@example.com", "merged@example.com", false)
+		},
+	},
+	"new_event": {
+		description: "Sent to an organizer's followers when they publish an event",
+		sample:      func() (string, string) { return renderNewEvent("Sample Promotions", "Sample Concert", sampleTime) },
+	},
+	"subscription_alert": {
+		description: "Sent right away to users with an instant category or tag subscription matching a new event",
+		sample: func() (string, string) {
+			return renderSubscriptionAlert("Sample Jazz Night", "Palace Grounds, Bangalore", sampleTime, "https://evently.example/v1/events/sample")
+		},
+	},
+	"subscription_digest": {
+		description: "Sent periodically to users with digest subscriptions, listing the new events that matched",
+		sample: func() (string, string) {
+			return renderSubscriptionDigest([]DigestEvent{
+				{Name: "Sample Jazz Night", Venue: "Palace Grounds, Bangalore", StartTime: sampleTime, Link: "https://evently.example/v1/events/sample"},
+				{Name: "Sample Quartet", Venue: "Town Hall, Bangalore", StartTime: sampleTime.AddDate(0, 0, 7), Link: "https://evently.example/v1/events/sample-2"},
+			})
+		},
+	},
+	"sales_milestone": {
+		description: "Sent to an event's milestone subscribers when sales cross a threshold",
+		sample:      func() (string, string) { return renderSalesMilestone("Sample Concert", 75, 750, 1000) },
+	},
+	"payment_conversion_alert": {
+		description: "Sent by the worker to ADMIN_EMAIL when a payment provider's hourly conversion drops below the alert threshold",
+		sample:      func() (string, string) { return renderConversionAlert("stripe", 38.5, 77, 200, 50) },
+	},
+	"booking_transfer": {
+		description: "Sent to the recipient of a booking transfer, with the link to accept it",
+		sample: func() (string, string) {
+			return renderTransferClaim("Sam Sample", "Sample Concert", sampleTime, []string{"B12", "B13"}, "https://evently.example/v1/transfers/tr_sample", sampleTime.AddDate(0, 0, -3))
+		},
+	},
+	"seat_integrity_alert": {
+		description: "Sent to ADMIN_EMAIL when bookings are flagged for seats that are missing or don't match the seats table",
+		sample: func() (string, string) {
+			return renderSeatIntegrityAlert(1, []string{"booking 7d0c2f4e-0000-0000-0000-000000000000 (event 1b9e5a70-0000-0000-0000-000000000000): booking has no seats; stored [], assigned []"})
+		},
+	},
+	"event_invitation": {
+		description: "Sent to each invitee imported for a private event, with their invitation code",
+		sample: func() (string, string) {
+			return renderEventInvitation("Sample Offsite", sampleTime, "K7QX2M9D", "https://evently.example/v1/events/sample?code=K7QX2M9D", true)
+		},
+	},
+	"event_member_invite": {
+		description: "Sent when an event's owner invites a co-organizer, with the permissions offered and the link to accept",
+		sample: func() (string, string) {
+			return renderMemberInvite("Sam Sample", "Sample Concert", sampleTime, []string{"edit_event", "manage_check_in"}, "https://evently.example/v1/organizer/member-invites/em_sample", sampleTime.AddDate(0, 0, -7))
+		},
+	},
+}
+
+// Templates returns every template rendered with sample data, sorted by name.
+func (m *MailerService) Templates() []Template {
+	out := make([]Template, 0, len(templates))
+	for name := range templates {
+		t, _ := m.Template(name)
+		out = append(out, *t)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Template renders the named template with sample data, reporting false if there is none.
+func (m *MailerService) Template(name string) (*Template, bool) {
+	def, ok := templates[name]
+	if !ok {
+		return nil, false
+	}
+	subject, body := def.sample()
+	b := &branding.Branding{Name: defaultBrandName}
+	if m.brands != nil {
+		b = m.brands.Resolve(context.Background(), nil)
+	}
+	signed := applyBranding(mailer.Mail{Subject: subject, Body: body}, b)
+	return &Template{Name: name, Description: def.description, Subject: subject, Body: signed.Body, HTML: signed.HTML}, true
+}
+
+// SendTestEmail sends the named template, rendered with sample data and its subject marked
+// as a test, to the given address. It skips the mail queue, so the admin sees the mail
+// server's answer.
+func (m *MailerService) SendTestEmail(to string, name string) error {
+	def, ok := templates[name]
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownTemplate, name)
+	}
+	// The sender signs it, as it does real sends
+	subject, body := def.sample()
+	mail := mailer.Mail{
+		To:      to,
+		Subject: "[TEST] " + subject,
+		Body:    body,
+	}
+
+	err := m.sender.Send(mail)
+	if err != nil {
+		m.log.Error("Failed to send test email", zap.Error(err), zap.String("email", to), zap.String("template", name))
+		return err
+	}
+
+	m.log.Info("Test email sent", zap.String("email", to), zap.String("template", name))
+	return nil
+}
+
+func renderPaymentRequest(eventName string, amount float64, currency string, display *fx.Quote, paymentLink string) (string, string) {
+	subject := fmt.Sprintf("Payment Required for %s", eventName)
+	approx := ""
+	if display != nil {
+		approx = fmt.Sprintf("\nApprox.: %.2f %s (rate of %s; you will be charged in %s)", display.Amount, display.Currency, display.AsOf.Format("2006-01-02"), currency)
+	}
+	body := fmt.Sprintf(`
+Dear User,
+
+Your booking for "%s" is ready for payment.
+
+Amount: %.2f %s%s
+Payment Link: %s
+
+Please complete your payment within 15 minutes to secure your booking.
+`, eventName, amount, currency, approx, paymentLink)
+	return subject, body
+}
+
+func renderPaymentDelayed(eventName string, holdUntil time.Time) (string, string) {
+	subject := fmt.Sprintf("Your seats for %s are held", eventName)
+	body := fmt.Sprintf(`
+Dear User,
+
+Your seats for "%s" are reserved, but our payment service is temporarily unavailable.
+
+We will email your payment link as soon as it is back, and you will then have the usual
+15 minutes to pay. If it is still unavailable at %s, your hold will be released.
+`, eventName, holdUntil.Format(time.RFC1123))
+	return subject, body
+}
+
+func renderWaitlistPromotion(eventName string) (string, string) {
+	subject := fmt.Sprintf("Great News! You're off the waitlist for %s", eventName)
+	body := fmt.Sprintf(`
+Dear User,
+
+Great news! A spot has opened up for "%s" and you're next in line!
+
+You will receive a payment link soon.
+`, eventName)
+	return subject, body
+}
+
+func renderWaitlistClosed(eventName string) (string, string) {
+	subject := fmt.Sprintf("The waitlist for %s has closed", eventName)
+	body := fmt.Sprintf(`
+Dear User,
+
+"%s" is about to start, so its waitlist has closed. No seats opened up for you this
+time, and you have not been charged.
+`, eventName)
+	return subject, body
+}
+
+func renderCancellation(cancellationFee float64, paymentLink string) (string, string) {
+	subject := "Booking Cancellation - Refund Information"
+	body := fmt.Sprintf(`
+Dear User,
+
+Your booking has been cancelled.
+
+Cancellation Fee: $%.2f
+Refund Link: %s
+
+Please use the refund link to process your refund.
+`, cancellationFee, paymentLink)
+	return subject, body
+}
+
+func renderReseat(eventName string, from []string, to []string, reason string) (string, string) {
+	subject := fmt.Sprintf("Your seats for %s have changed", eventName)
+	body := fmt.Sprintf(`
+Dear User,
+
+Our support team has moved your booking for "%s" to different seats.
+
+Previous Seats: %s
+New Seats: %s
+Reason: %s
+
+Nothing changes about your payment. Please use your new seats at the event.
+`, eventName, strings.Join(from, ", "), strings.Join(to, ", "), reason)
+	return subject, body
+}
+
+func renderEventCancellation(eventName string, refundAmount float64) (string, string) {
+	subject := fmt.Sprintf("Event Cancelled: %s", eventName)
+	body := fmt.Sprintf(`
+Dear User,
+
+We regret to inform you that the event "%s" has been cancelled.
+
+Refund Amount: $%.2f
+
+Your refund amount arrive shortly.
+
+We apologize for any inconvenience.
+`, eventName, refundAmount)
+	return subject, body
+}
+
+func renderPasswordChangeOTP(otp string) (string, string) {
+	subject := "Password Change OTP"
+	body := fmt.Sprintf(`
+Dear User,
+
+You have requested to change your password.
+
+Your OTP is: %s
+
+This OTP will expire in 15 minutes.
+
+If you did not request this change, please ignore this email.
+`, otp)
+	return subject, body
+}
+
+// renderAccountMergeCode is the code for one side of a merge; kept says whether the
+// recipient is the account that stays.
+func renderAccountMergeCode(code, keptEmail, mergedEmail string, kept bool) (string, string) {
+	subject := "Confirm merging your Evently accounts"
+	fate := fmt.Sprintf("The account for %s will be closed and its bookings, waitlist spots and likes moved to %s.", mergedEmail, keptEmail)
+	if kept {
+		fate = fmt.Sprintf("Bookings, waitlist spots and likes of %s will be moved to this account, and that account will be closed.", mergedEmail)
+	}
+	body := fmt.Sprintf(`
+Dear User,
+
+A request was made to merge the Evently accounts %s and %s.
+%s
+
+Your confirmation code is: %s
+
+Both accounts' codes are needed, and they expire in 15 minutes.
+
+If you did not request this, please ignore this email.
+`, keptEmail, mergedEmail, fate, code)
+	return subject, body
+}
+
+func renderNewEvent(organizerName string, eventName string, startTime time.Time) (string, string) {
+	subject := fmt.Sprintf("%s just announced %s", organizerName, eventName)
+	body := fmt.Sprintf(`
+Dear User,
+
+%s, an organizer you follow, has published a new event: "%s".
+
+Starts: %s
+
+Book early to secure your seats.
+`, organizerName, eventName, startTime.Format(time.RFC1123))
+	return subject, body
+}
+
+func renderSubscriptionAlert(eventName string, venue string, startTime time.Time, link string) (string, string) {
+	subject := fmt.Sprintf("New event: %s", eventName)
+	body := fmt.Sprintf(`
+Dear User,
+
+A new event matching your subscriptions has been published: "%s".
+
+Venue: %s
+Starts: %s
+Event Link: %s
+
+Book early to secure your seats.
+`, eventName, venue, startTime.Format(time.RFC1123), link)
+	return subject, body
+}
+
+// DigestEvent is one event listed in a subscription digest.
+type DigestEvent struct {
+	Name      string
+	Venue     string
+	StartTime time.Time
+	Link      string
+}
+
+func renderSubscriptionDigest(events []DigestEvent) (string, string) {
+	subject := fmt.Sprintf("%d new events matching your subscriptions", len(events))
+	if len(events) == 1 {
+		subject = fmt.Sprintf("New event matching your subscriptions: %s", events[0].Name)
+	}
+	var list strings.Builder
+	for _, e := range events {
+		fmt.Fprintf(&list, "\n- %s\n  %s, %s\n  %s\n", e.Name, e.Venue, e.StartTime.Format(time.RFC1123), e.Link)
+	}
+	body := fmt.Sprintf(`
+Dear User,
+
+These events matching your subscriptions were published recently:
+%s
+Book early to secure your seats.
+`, list.String())
+	return subject, body
+}
+
+func renderSalesMilestone(eventName string, percent int, sold int, capacity int) (string, string) {
+	subject := fmt.Sprintf("%s reached %d%% sold", eventName, percent)
+	if percent >= 100 {
+		subject = fmt.Sprintf("%s is sold out", eventName)
+	}
+	body := fmt.Sprintf(`
+Hello,
+
+"%s" has crossed its %d%% sales milestone.
+
+Tickets sold: %d of %d
+`, eventName, percent, sold, capacity)
+	return subject, body
+}
+
+func renderConversionAlert(provider string, percent float64, converted int, resolved int, threshold float64) (string, string) {
+	subject := fmt.Sprintf("Payment conversion for %s dropped to %.1f%%", provider, percent)
+	body := fmt.Sprintf(`
+Hello,
+
+Only %.1f%% of the bookings %s handled in the last hour were paid for (%d of %d), below the %.0f%% alert threshold.
+
+Bookings stuck at payment hold their seats until they expire. Check the provider's status and the pending booking age buckets on the payments dashboard.
+`, percent, provider, converted, resolved, threshold)
+	return subject, body
+}
+
+func renderTransferClaim(fromName string, eventName string, startTime time.Time, seats []string, link string, expiresAt time.Time) (string, string) {
+	if fromName == "" {
+		fromName = "Someone"
+	}
+	subject := fmt.Sprintf("%s sent you tickets for %s", fromName, eventName)
+	body := fmt.Sprintf(`
+Hello,
+
+%s wants to transfer their booking for %s on %s to you.
+
+Seats: %s
+
+Sign in to Evently with this email address (or sign up with it) and accept the tickets here:
+%s
+
+The link works until %s. Once you accept, the booking and its tickets are yours.
+`, fromName, eventName, startTime.Format("Monday, January 2, 2006 at 3:04 PM MST"), strings.Join(seats, ", "), link, expiresAt.Format("January 2, 2006 at 3:04 PM MST"))
+	return subject, body
+}
+
+// memberPermissionNames describes each event permission in a co-organizer invitation.
+var memberPermissionNames = map[string]string{
+	"edit_event":      "edit the event's details",
+	"view_analytics":  "view its analytics and sales",
+	"manage_check_in": "manage check-in and gate devices",
+	"trigger_refunds": "refund its paid bookings",
+}
+
+func renderMemberInvite(fromName string, eventName string, startTime time.Time, permissions []string, link string, expiresAt time.Time) (string, string) {
+	if fromName == "" {
+		fromName = "The organizer"
+	}
+	var perms strings.Builder
+	for _, p := range permissions {
+		name, ok := memberPermissionNames[p]
+		if !ok {
+			name = p
+		}
+		fmt.Fprintf(&perms, "- %s\n", name)
+	}
+	subject := fmt.Sprintf("%s invited you to help organize %s", fromName, eventName)
+	body := fmt.Sprintf(`
+Hello,
+
+%s invited you to help organize %s on %s. As a co-organizer you can:
+%s
+Sign in to Evently with this email address (or sign up with it) and accept the invitation here:
+%s
+
+The link works until %s.
+`, fromName, eventName, startTime.Format("Monday, January 2, 2006 at 3:04 PM MST"), perms.String(), link, expiresAt.Format("January 2, 2006 at 3:04 PM MST"))
+	return subject, body
+}
+
+func renderSeatIntegrityAlert(count int, lines []string) (string, string) {
+	subject := fmt.Sprintf("%d booking(s) flagged for seat integrity", count)
+	more := ""
+	if count > len(lines) {
+		more = fmt.Sprintf("\n...and %d more.\n", count-len(lines))
+	}
+	body := fmt.Sprintf(`
+Hello,
+
+These bookings have seats that are missing, not on their event's seat map, or don't match
+the seats table. Payments for bookings without valid seats are refused until they're fixed:
+
+%s
+%s
+Open issues are listed at GET /admin/seat-integrity.
+`, strings.Join(lines, "\n"), more)
+	return subject, body
+}
+
+func renderEventInvitation(eventName string, startTime time.Time, code string, link string, newAccount bool) (string, string) {
+	subject := fmt.Sprintf("You're invited to %s", eventName)
+	account := ""
+	if newAccount {
+		account = "\nAn Evently account has been created for this email address. Use \"Forgot password\"\nto set your password before signing in.\n"
+	}
+	body := fmt.Sprintf(`
+Dear User,
+
+You have been invited to the private event "%s".
+
+Starts: %s
+Invitation Code: %s
+Event Link: %s
+%s
+Sign in and book through the link, or enter your code when booking. The code is
+personal and only works with your account.
+`, eventName, startTime.Format(time.RFC1123), code, link, account)
+	return subject, body
+}
+
+// walletNames labels wallet pass links by format.
+var walletNames = map[string]string{"apple": "Apple Wallet", "google": "Google Wallet"}
+
+func renderBookingConfirmation(eventName string, venue string, startTime time.Time, seats []string, bookingID string, walletLinks map[string]string) (string, string) {
+	subject := fmt.Sprintf("Your tickets for %s", eventName)
+	seatList := "General admission"
+	if len(seats) > 0 {
+		seatList = strings.Join(seats, ", ")
+	}
+	wallet := ""
+	if len(walletLinks) > 0 {
+		formats := make([]string, 0, len(walletLinks))
+		for format := range walletLinks {
+			formats = append(formats, format)
+		}
+		sort.Strings(formats)
+		wallet = "\nAdd your tickets to your phone:\n"
+		for _, format := range formats {
+			wallet += fmt.Sprintf("%s: %s\n", walletNames[format], walletLinks[format])
+		}
+	}
+	body := fmt.Sprintf(`
+Dear User,
+
+Your booking for "%s" is confirmed.
+
+Starts: %s
+Venue: %s
+Seats: %s
+Booking: %s
+%s
+Show your wallet pass or booking at the gate.
+`, eventName, startTime.Format(time.RFC1123), venue, seatList, bookingID, wallet)
+	return subject, body
+}
@@ -0,0 +1,164 @@
+package milestones
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	jwtMiddleware "github.com/samirwankhede/lewly-pgpyewj/internal/middleware"
+	mailer "github.com/samirwankhede/lewly-pgpyewj/internal/service/mailer"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/events"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/milestones"
+)
+
+const webhookTimeout = 10 * time.Second
+
+var (
+	ErrEventNotFound    = errors.New("event not found")
+	ErrInvalidMilestone = errors.New("invalid milestone")
+)
+
+// MilestonesService stores organizers' sales milestones and notifies them when payments
+// push an event's sales past one.
+type MilestonesService struct {
+	log           *zap.Logger
+	repo          *milestones.MilestonesRepository
+	events        *events.EventsRepository
+	mailer        *mailer.MailerService
+	http          *http.Client
+	webhookSecret string
+}
+
+// MilestoneInput configures one milestone; at least one of NotifyEmail and WebhookURL should be set.
+type MilestoneInput struct {
+	Percent     int     `json:"percent" binding:"required,min=1,max=100"`
+	NotifyEmail *string `json:"notify_email" binding:"omitempty,email"`
+	WebhookURL  *string `json:"webhook_url"`
+}
+
+// MilestoneEvent is the body POSTed to a milestone's webhook_url, signed like incoming
+// webhooks: Webhook-Timestamp plus Webhook-Signature "v1=<hex HMAC-SHA256 of ts.body>".
+type MilestoneEvent struct {
+	Type      string    `json:"type"`
+	EventID   string    `json:"event_id"`
+	EventName string    `json:"event_name"`
+	Percent   int       `json:"percent"`
+	Sold      int       `json:"sold"`
+	Capacity  int       `json:"capacity"`
+	CrossedAt time.Time `json:"crossed_at"`
+}
+
+func NewMilestonesService(log *zap.Logger, repo *milestones.MilestonesRepository, events *events.EventsRepository, mailer *mailer.MailerService, webhookSecret string) *MilestonesService {
+	return &MilestonesService{log: log, repo: repo, events: events, mailer: mailer, http: &http.Client{Timeout: webhookTimeout}, webhookSecret: webhookSecret}
+}
+
+// Set replaces the event's milestones with in.
+func (s *MilestonesService) Set(ctx context.Context, eventID string, in []MilestoneInput) ([]*milestones.Milestone, error) {
+	e, err := s.events.Get(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, ErrEventNotFound
+	}
+
+	seen := map[int]bool{}
+	ms := make([]*milestones.Milestone, 0, len(in))
+	for _, m := range in {
+		if m.Percent < 1 || m.Percent > 100 || seen[m.Percent] {
+			return nil, ErrInvalidMilestone
+		}
+		seen[m.Percent] = true
+		if m.WebhookURL != nil {
+			u, err := url.Parse(*m.WebhookURL)
+			if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+				return nil, ErrInvalidMilestone
+			}
+		}
+		ms = append(ms, &milestones.Milestone{EventID: eventID, Percent: m.Percent, NotifyEmail: m.NotifyEmail, WebhookURL: m.WebhookURL})
+	}
+
+	if err := s.repo.Replace(ctx, eventID, ms); err != nil {
+		return nil, err
+	}
+	// Milestones added below current sales are crossed right away
+	s.Check(ctx, eventID)
+	return s.repo.ListByEvent(ctx, eventID)
+}
+
+func (s *MilestonesService) List(ctx context.Context, eventID string) ([]*milestones.Milestone, error) {
+	return s.repo.ListByEvent(ctx, eventID)
+}
+
+// Check claims every milestone the event's finalized sales have reached and notifies the
+// organizer of each. It is called after each successful payment; errors are only logged.
+func (s *MilestonesService) Check(ctx context.Context, eventID string) {
+	sold, capacity, err := s.repo.SoldTickets(ctx, eventID)
+	if err != nil {
+		s.log.Error("Failed to count sold tickets", zap.Error(err), zap.String("event_id", eventID))
+		return
+	}
+	if capacity <= 0 {
+		return
+	}
+	crossed, err := s.repo.MarkCrossed(ctx, eventID, sold*100/capacity)
+	if err != nil {
+		s.log.Error("Failed to mark milestones", zap.Error(err), zap.String("event_id", eventID))
+		return
+	}
+	if len(crossed) == 0 {
+		return
+	}
+
+	e, err := s.events.Get(ctx, eventID)
+	if err != nil || e == nil {
+		s.log.Error("Failed to load event for milestones", zap.Error(err), zap.String("event_id", eventID))
+		return
+	}
+	for _, m := range crossed {
+		s.log.Info("Sales milestone crossed", zap.String("event_id", eventID), zap.Int("percent", m.Percent), zap.Int("sold", sold))
+		if m.NotifyEmail != nil && s.mailer != nil {
+			_ = s.mailer.For(e.OrganizerID).SendSalesMilestoneEmail(*m.NotifyEmail, e.Name, m.Percent, sold, capacity)
+		}
+		if m.WebhookURL != nil {
+			ev := MilestoneEvent{Type: "sales.milestone", EventID: eventID, EventName: e.Name, Percent: m.Percent, Sold: sold, Capacity: capacity, CrossedAt: *m.CrossedAt}
+			if err := s.deliver(ctx, *m.WebhookURL, ev); err != nil {
+				s.log.Error("Failed to deliver milestone webhook", zap.Error(err), zap.String("event_id", eventID), zap.Int("percent", m.Percent))
+			}
+		}
+	}
+}
+
+func (s *MilestonesService) deliver(ctx context.Context, target string, ev MilestoneEvent) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.webhookSecret != "" {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(jwtMiddleware.WebhookTimestampHeader, ts)
+		req.Header.Set(jwtMiddleware.WebhookSignatureHeader, "v1="+jwtMiddleware.SignWebhook(s.webhookSecret, ts, body))
+	}
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package organizers
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/zap"
+
+	redisx "github.com/samirwankhede/lewly-pgpyewj/internal/redis"
+	eventsService "github.com/samirwankhede/lewly-pgpyewj/internal/service/events"
+	mailer "github.com/samirwankhede/lewly-pgpyewj/internal/service/mailer"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/events"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/organizers"
+)
+
+// profileEventsLimit caps the upcoming events embedded in a public profile.
+const profileEventsLimit = 50
+
+var ErrOrganizerNotFound = errors.New("organizer not found")
+
+type OrganizersService struct {
+	log    *zap.Logger
+	repo   *organizers.OrganizersRepository
+	events *events.EventsRepository
+	mailer *mailer.MailerService
+	tokens *redisx.TokenBucket
+}
+
+type CreateOrganizerRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	Website     string `json:"website"`
+	LogoURL     string `json:"logo_url"`
+	// Rolling per-user ticket limit shared across the organizer's events
+	MaxTicketsPerUser     *int `json:"max_tickets_per_user" binding:"omitempty,gt=0"`
+	UserTicketWindowHours *int `json:"user_ticket_window_hours" binding:"omitempty,gt=0"`
+}
+
+type Profile struct {
+	Organizer      *organizers.Organizer `json:"organizer"`
+	UpcomingEvents []*events.Event       `json:"upcoming_events"`
+}
+
+func NewOrganizersService(log *zap.Logger, repo *organizers.OrganizersRepository, events *events.EventsRepository, mailer *mailer.MailerService) *OrganizersService {
+	return &OrganizersService{log: log, repo: repo, events: events, mailer: mailer}
+}
+
+// WithAvailability adds availability badges to the upcoming events on profiles.
+func (s *OrganizersService) WithAvailability(tokens *redisx.TokenBucket) *OrganizersService {
+	s.tokens = tokens
+	return s
+}
+
+func (s *OrganizersService) Create(ctx context.Context, req CreateOrganizerRequest) (*organizers.Organizer, error) {
+	return s.repo.Create(ctx, &organizers.Organizer{
+		Name:                  req.Name,
+		Description:           req.Description,
+		Website:               req.Website,
+		LogoURL:               req.LogoURL,
+		MaxTicketsPerUser:     req.MaxTicketsPerUser,
+		UserTicketWindowHours: req.UserTicketWindowHours,
+	})
+}
+
+func (s *OrganizersService) Get(ctx context.Context, id string) (*organizers.Organizer, error) {
+	o, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if o == nil {
+		return nil, ErrOrganizerNotFound
+	}
+	return o, nil
+}
+
+func (s *OrganizersService) GetProfile(ctx context.Context, id string) (*Profile, error) {
+	o, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if o == nil {
+		return nil, ErrOrganizerNotFound
+	}
+
+	upcoming, err := s.events.ListUpcomingByOrganizer(ctx, id, profileEventsLimit, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	eventsService.Annotate(ctx, s.log, s.tokens, upcoming...)
+	return &Profile{Organizer: o, UpcomingEvents: upcoming}, nil
+}
+
+func (s *OrganizersService) Follow(ctx context.Context, organizerID, userID string) error {
+	o, err := s.repo.Get(ctx, organizerID)
+	if err != nil {
+		return err
+	}
+	if o == nil {
+		return ErrOrganizerNotFound
+	}
+	return s.repo.Follow(ctx, organizerID, userID)
+}
+
+func (s *OrganizersService) Unfollow(ctx context.Context, organizerID, userID string) error {
+	return s.repo.Unfollow(ctx, organizerID, userID)
+}
+
+func (s *OrganizersService) IsFollowing(ctx context.Context, organizerID, userID string) (bool, error) {
+	return s.repo.IsFollowing(ctx, organizerID, userID)
+}
+
+// NotifyFollowers emails every follower of the event's organizer about a newly published event
+// as one broadcast. Failures for individual followers are logged and skipped.
+func (s *OrganizersService) NotifyFollowers(ctx context.Context, e *events.Event) {
+	if e.OrganizerID == nil {
+		return
+	}
+	o, err := s.repo.Get(ctx, *e.OrganizerID)
+	if err != nil || o == nil {
+		s.log.Error("Failed to load organizer for notifications", zap.Error(err), zap.String("event_id", e.ID))
+		return
+	}
+	emails, err := s.repo.FollowerEmails(ctx, o.ID)
+	if err != nil {
+		s.log.Error("Failed to load organizer followers", zap.Error(err), zap.String("organizer_id", o.ID))
+		return
+	}
+	if _, err := s.mailer.For(&o.ID).BroadcastNewEvent(ctx, e.ID, o.Name, e.Name, e.StartTime, emails); err != nil {
+		s.log.Error("Failed to notify organizer followers", zap.Error(err), zap.String("organizer_id", o.ID), zap.String("event_id", e.ID))
+		return
+	}
+	s.log.Info("Notified organizer followers", zap.String("organizer_id", o.ID), zap.String("event_id", e.ID), zap.Int("count", len(emails)))
+}
@@ -0,0 +1,132 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/refunds"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/bookings"
+	storePayments "github.com/samirwankhede/lewly-pgpyewj/internal/store/payments"
+)
+
+var (
+	ErrNotPaid          = errors.New("booking has no captured payment")
+	ErrPaymentIDMissing = errors.New("payment_id is required to charge the difference")
+)
+
+// AmountChange is what changing a paid booking's amount moved: Charged extra, or Refunded
+// part of what it paid.
+type AmountChange struct {
+	AmountPaid float64 `json:"amount_paid"`
+	Charged    float64 `json:"charged,omitempty"`
+	Refunded   float64 `json:"refunded,omitempty"`
+}
+
+// ChangeAmount makes a paid booking's payment amount instead of what it paid, around apply,
+// which changes the booking itself given the new amount paid. A dearer booking is charged
+// the difference to paymentID before apply runs, and refunded it if apply fails; a cheaper
+// one has apply run first and is then refunded the difference from its payment, so a failed
+// change never costs the customer. A refund failing after apply is returned as an error
+// with the change kept.
+func (s *PaymentService) ChangeAmount(ctx context.Context, booking *bookings.Booking, amount float64, paymentID string, apply func(ctx context.Context, amountPaid float64) error) (*AmountChange, error) {
+	if booking.PaymentStatus != "paid" {
+		return nil, ErrNotPaid
+	}
+	amount = math.Round(amount*100) / 100
+	diff := math.Round((amount-booking.AmountPaid)*100) / 100
+	change := &AmountChange{AmountPaid: amount}
+
+	switch {
+	case diff > 0:
+		if paymentID == "" {
+			return nil, ErrPaymentIDMissing
+		}
+		ref, err := s.provider.Charge(ctx, paymentID, diff, booking.Currency)
+		observe("charge", err)
+		if err != nil {
+			s.log.Error("Charging seat change failed", zap.Error(err), zap.String("booking_id", booking.ID))
+			return nil, ErrPaymentFailed
+		}
+		if err := apply(ctx, amount); err != nil {
+			s.reverse(ctx, false, ref, diff)
+			return nil, err
+		}
+		change.Charged = diff
+		s.recordAdjustment(ctx, &storePayments.Adjustment{BookingID: booking.ID, Kind: storePayments.AdjustmentCharge,
+			Amount: diff, Currency: booking.Currency, PaymentID: &paymentID, ProviderRef: &ref})
+
+	case diff < 0:
+		if err := apply(ctx, amount); err != nil {
+			return nil, err
+		}
+		live, err := s.payments.GetLive(ctx, booking.ID)
+		if err != nil {
+			return nil, err
+		}
+		ref := booking.ID
+		if live != nil && live.ProviderRef != nil {
+			ref = *live.ProviderRef
+		}
+		err = s.provider.Refund(ctx, ref, -diff)
+		observe("refund", err)
+		if err != nil {
+			s.log.Error("Refunding seat change failed", zap.Error(err), zap.String("booking_id", booking.ID), zap.Float64("amount", -diff))
+			return nil, err
+		}
+		change.Refunded = -diff
+		s.recordAdjustment(ctx, &storePayments.Adjustment{BookingID: booking.ID, Kind: storePayments.AdjustmentRefund,
+			Amount: -diff, Currency: booking.Currency, ProviderRef: &ref})
+
+	default:
+		if err := apply(ctx, amount); err != nil {
+			return nil, err
+		}
+	}
+	return change, nil
+}
+
+// RefundSeats refunds seats of a paid booking whose owner is cancelling them and keeping the
+// rest, around apply, which drops the seats from the booking given its new amount paid. Like a
+// cheaper seat change, apply runs first and the seats are then refunded what they paid less
+// their share of the cancellation fee still to be charged, so a failed cancellation never
+// costs the customer. A refund failing after apply is returned as an error with the seats
+// dropped.
+func (s *PaymentService) RefundSeats(ctx context.Context, booking *bookings.Booking, seats []string, apply func(ctx context.Context, amountPaid float64) error) (*refunds.Breakdown, error) {
+	if booking.PaymentStatus != "paid" {
+		return nil, ErrNotPaid
+	}
+	event, err := s.events.Get(ctx, booking.EventID)
+	if err != nil {
+		return nil, err
+	}
+	if event == nil {
+		return nil, ErrEventNotFound
+	}
+	fee, err := s.feeLeft(ctx, booking, event)
+	if err != nil {
+		return nil, err
+	}
+	breakdown, err := s.refundBreakdown(ctx, booking, event, fee, seats)
+	if err != nil {
+		return nil, err
+	}
+	if err := apply(ctx, math.Round((breakdown.AmountPaid-breakdown.Paid)*100)/100); err != nil {
+		return nil, err
+	}
+	if err := s.refund(ctx, booking, storePayments.RefundSeatsCancelled, breakdown); err != nil {
+		s.log.Error("Refunding cancelled seats failed", zap.Error(err), zap.String("booking_id", booking.ID), zap.Float64("amount", breakdown.Refund))
+		return nil, err
+	}
+	return breakdown, nil
+}
+
+// recordAdjustment keeps a record of money already moved; failing to write it is logged
+// rather than undoing the payment.
+func (s *PaymentService) recordAdjustment(ctx context.Context, a *storePayments.Adjustment) {
+	if err := s.payments.CreateAdjustment(ctx, a); err != nil {
+		s.log.Error("Failed to record payment adjustment", zap.Error(err), zap.String("booking_id", a.BookingID), zap.String("kind", a.Kind))
+	}
+}
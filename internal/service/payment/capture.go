@@ -0,0 +1,207 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/domain"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/payments"
+	redisx "github.com/samirwankhede/lewly-pgpyewj/internal/redis"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/service/jobs"
+	storeJobs "github.com/samirwankhede/lewly-pgpyewj/internal/store/jobs"
+	storePayments "github.com/samirwankhede/lewly-pgpyewj/internal/store/payments"
+)
+
+// CaptureResult is what capturing an event's authorizations charged.
+type CaptureResult struct {
+	Captured int     `json:"captured"`
+	Failed   int     `json:"failed"`
+	Amount   float64 `json:"amount"`
+}
+
+// CapturePayment charges the booking's authorized payment, for an organizer confirming the
+// booking ahead of the event's capture time.
+func (s *PaymentService) CapturePayment(ctx context.Context, bookingID string) (*storePayments.Payment, error) {
+	booking, err := s.bookings.GetByID(ctx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	if booking == nil {
+		return nil, ErrBookingNotFound
+	}
+	p, err := s.payments.ClaimBooking(ctx, bookingID, captureLease)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		if booking.PaymentStatus == "paid" {
+			return nil, ErrAlreadyPaid
+		}
+		return nil, ErrNotAuthorized
+	}
+	if err := s.capture(ctx, p); err != nil {
+		return nil, err
+	}
+	return s.payments.GetLive(ctx, bookingID)
+}
+
+// CaptureEventPayments charges every authorization of the event in a background job, for an
+// organizer confirming the whole event; its result is a CaptureResult.
+func (s *PaymentService) CaptureEventPayments(ctx context.Context, eventID string) (*storeJobs.Job, error) {
+	event, err := s.events.Get(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	if event == nil {
+		return nil, ErrEventNotFound
+	}
+	return s.jobs.Start(ctx, "payment_capture", &eventID, func(ctx context.Context, p *jobs.Progress) (any, error) {
+		total, err := s.payments.CountCapturable(ctx, eventID)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.SetTotal(ctx, total); err != nil {
+			return nil, err
+		}
+		return s.captureEvent(ctx, eventID, p)
+	})
+}
+
+// RunCaptures captures due authorizations now and every interval after until ctx is done:
+// those of manual-capture events whose capture time has passed, and any a crashed capture
+// left claimed.
+func (s *PaymentService) RunCaptures(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.captureDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *PaymentService) captureDue(ctx context.Context) {
+	eventIDs, err := s.payments.DueForCapture(ctx, captureLease)
+	if err != nil {
+		s.log.Error("Failed to list events due for capture", zap.Error(err))
+		return
+	}
+	for _, id := range eventIDs {
+		res, err := s.captureEvent(ctx, id, nil)
+		if err != nil {
+			s.log.Error("Failed to capture event payments", zap.Error(err), zap.String("event_id", id))
+		}
+		if res.Captured+res.Failed == 0 {
+			continue
+		}
+		s.log.Info("Captured event payments", zap.String("event_id", id), zap.Int("captured", res.Captured), zap.Int("failed", res.Failed))
+	}
+}
+
+// captureEvent captures the event's authorizations chunk by chunk, counting each on p when
+// it's set. A payment whose capture fails is only tried again on a later call.
+func (s *PaymentService) captureEvent(ctx context.Context, eventID string, p *jobs.Progress) (*CaptureResult, error) {
+	res := &CaptureResult{}
+	started := time.Now()
+	for {
+		claimed, err := s.payments.ClaimEvent(ctx, eventID, started, captureChunk, captureLease)
+		if err != nil {
+			return res, err
+		}
+		if len(claimed) == 0 {
+			return res, nil
+		}
+		for _, payment := range claimed {
+			if err := s.capture(ctx, payment); err != nil {
+				res.Failed++
+				if p != nil {
+					p.Fail(ctx, fmt.Sprintf("booking %s: %v", payment.BookingID, err))
+				}
+				continue
+			}
+			res.Captured++
+			res.Amount += payment.Amount
+			if p != nil {
+				p.Succeed(ctx)
+			}
+		}
+	}
+}
+
+// capture charges a claimed authorization. A declined capture fails the payment for good;
+// any other error hands the claim back so a later capture retries it.
+func (s *PaymentService) capture(ctx context.Context, p *storePayments.Payment) error {
+	err := s.provider.Capture(ctx, providerRef(p), p.Amount)
+	observe("capture", err)
+	switch {
+	case errors.Is(err, payments.ErrDeclined):
+		s.log.Warn("Capture declined", zap.Error(err), zap.String("booking_id", p.BookingID))
+		if ferr := s.payments.Failed(ctx, p, err.Error()); ferr != nil {
+			s.log.Error("Failed to record declined capture", zap.Error(ferr), zap.String("payment_id", p.ID))
+		}
+		return fmt.Errorf("%w: %v", ErrPaymentFailed, err)
+	case err != nil:
+		if rerr := s.payments.Release(ctx, p.ID, err.Error()); rerr != nil {
+			s.log.Error("Failed to release capture claim", zap.Error(rerr), zap.String("payment_id", p.ID))
+		}
+		return err
+	}
+
+	// The money moved; if this isn't recorded the claim lapses and the capture is retried,
+	// which providers reject for an authorization already captured
+	if err := s.payments.Captured(ctx, p); err != nil {
+		s.log.Error("Captured payment not recorded", zap.Error(err), zap.String("payment_id", p.ID), zap.String("booking_id", p.BookingID))
+		return err
+	}
+	if s.notify != nil {
+		e := redisx.BookingEvent{Type: redisx.BookingEventPaymentReceived, BookingID: p.BookingID, Status: domain.BookingBooked}
+		if err := s.notify.Publish(ctx, e); err != nil {
+			s.log.Error("Failed to publish booking event", zap.Error(err), zap.String("booking_id", p.BookingID))
+		}
+	}
+	return nil
+}
+
+// VoidBooking releases the booking's uncaptured authorization so the buyer's card is no
+// longer held, reporting whether there was one to void.
+func (s *PaymentService) VoidBooking(ctx context.Context, bookingID string) (bool, error) {
+	p, err := s.payments.GetLive(ctx, bookingID)
+	if err != nil {
+		return false, err
+	}
+	if p == nil || p.State != storePayments.StateAuthorized {
+		return false, nil
+	}
+	return s.void(ctx, p)
+}
+
+func (s *PaymentService) void(ctx context.Context, p *storePayments.Payment) (bool, error) {
+	err := s.provider.Void(ctx, providerRef(p))
+	observe("void", err)
+	if err != nil {
+		return false, err
+	}
+	voided, err := s.payments.Voided(ctx, p)
+	if err != nil {
+		return false, err
+	}
+	if !voided {
+		// A capture claimed it in between; the provider will decline that capture
+		s.log.Warn("Voided an authorization being captured", zap.String("payment_id", p.ID), zap.String("booking_id", p.BookingID))
+	}
+	return voided, nil
+}
+
+func providerRef(p *storePayments.Payment) string {
+	if p.ProviderRef == nil {
+		return ""
+	}
+	return *p.ProviderRef
+}
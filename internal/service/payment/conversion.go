@@ -0,0 +1,133 @@
+package payment
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/metrics"
+	mailer "github.com/samirwankhede/lewly-pgpyewj/internal/service/mailer"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/bookings"
+)
+
+// conversionWindow is how far back bookings count towards the conversion percentage.
+const conversionWindow = time.Hour
+
+var pendingAgeBuckets = []string{"0-5m", "5-10m", "10m+"}
+
+// ConversionMonitor samples pending bookings and recent payment conversion into metrics,
+// grouped by the payment provider handling each booking, and alerts ADMIN_EMAIL once when a
+// provider's conversion drops below threshold percent, again only after it has recovered.
+// Providers with fewer than minResolved resolved bookings in the window aren't judged.
+type ConversionMonitor struct {
+	log         *zap.Logger
+	bookings    *bookings.BookingsRepository
+	mailer      *mailer.MailerService
+	adminEmail  string
+	threshold   float64
+	minResolved int
+
+	// Providers currently below threshold, only touched by Run
+	low map[string]bool
+}
+
+// NewConversionMonitor returns the monitor. A non-positive threshold disables alerts; the
+// metrics are still sampled.
+func NewConversionMonitor(log *zap.Logger, bookings *bookings.BookingsRepository, mailer *mailer.MailerService, adminEmail string, threshold float64, minResolved int) *ConversionMonitor {
+	return &ConversionMonitor{log: log, bookings: bookings, mailer: mailer, adminEmail: adminEmail, threshold: threshold, minResolved: minResolved, low: map[string]bool{}}
+}
+
+// Run samples now and every interval after until ctx is done. A non-positive interval
+// disables it.
+func (m *ConversionMonitor) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		m.samplePending(ctx)
+		m.sampleConversion(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// providerLabel names bookings no provider has reported on yet "none".
+func providerLabel(provider string) string {
+	if provider == "" {
+		return "none"
+	}
+	return provider
+}
+
+func (m *ConversionMonitor) samplePending(ctx context.Context) {
+	ages, err := m.bookings.PendingAges(ctx)
+	if err != nil {
+		m.log.Warn("Failed to read pending booking ages", zap.Error(err))
+		return
+	}
+	metrics.PendingBookings.Reset()
+	metrics.PendingSeats.Reset()
+	// Every provider seen gets all buckets, so an emptied bucket reads 0 rather than vanishing
+	zero := func(provider string) {
+		for _, age := range pendingAgeBuckets {
+			metrics.PendingBookings.WithLabelValues(provider, age).Set(0)
+			metrics.PendingSeats.WithLabelValues(provider, age).Set(0)
+		}
+	}
+	zero("none")
+	seen := map[string]bool{"none": true}
+	for _, a := range ages {
+		provider := providerLabel(a.Provider)
+		if !seen[provider] {
+			seen[provider] = true
+			zero(provider)
+		}
+		metrics.PendingBookings.WithLabelValues(provider, a.Age).Set(float64(a.Bookings))
+		metrics.PendingSeats.WithLabelValues(provider, a.Age).Set(float64(a.Seats))
+	}
+}
+
+func (m *ConversionMonitor) sampleConversion(ctx context.Context) {
+	conversions, err := m.bookings.ConversionSince(ctx, time.Now().Add(-conversionWindow))
+	if err != nil {
+		m.log.Warn("Failed to read payment conversion", zap.Error(err))
+		return
+	}
+	metrics.PaymentConversionPercent.Reset()
+	for _, c := range conversions {
+		provider := providerLabel(c.Provider)
+		percent := 100 * float64(c.Converted) / float64(c.Resolved)
+		metrics.PaymentConversionPercent.WithLabelValues(provider).Set(percent)
+		// Bookings no provider saw were abandoned before paying; that isn't a provider failing
+		if c.Provider == "" || m.threshold <= 0 || c.Resolved < m.minResolved {
+			continue
+		}
+		m.judge(c, percent)
+	}
+}
+
+// judge alerts when c's provider first drops below the threshold and notes its recovery.
+func (m *ConversionMonitor) judge(c *bookings.Conversion, percent float64) {
+	below := percent < m.threshold
+	switch {
+	case below && !m.low[c.Provider]:
+		m.low[c.Provider] = true
+		metrics.PaymentConversionLow.WithLabelValues(c.Provider).Set(1)
+		metrics.PaymentConversionAlertsTotal.WithLabelValues(c.Provider).Inc()
+		m.log.Warn("Payment conversion below threshold", zap.String("provider", c.Provider), zap.Float64("percent", percent),
+			zap.Int("converted", c.Converted), zap.Int("resolved", c.Resolved), zap.Float64("threshold", m.threshold))
+		if m.mailer != nil && m.adminEmail != "" {
+			_ = m.mailer.SendConversionAlertEmail(m.adminEmail, c.Provider, percent, c.Converted, c.Resolved, m.threshold)
+		}
+	case !below && m.low[c.Provider]:
+		delete(m.low, c.Provider)
+		metrics.PaymentConversionLow.WithLabelValues(c.Provider).Set(0)
+		m.log.Info("Payment conversion recovered", zap.String("provider", c.Provider), zap.Float64("percent", percent))
+	}
+}
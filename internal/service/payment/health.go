@@ -0,0 +1,108 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/metrics"
+)
+
+const (
+	// The payment service is marked down after failDown failed probes in a row and up again
+	// after passUp passing ones, so a single slow response doesn't flap payment links.
+	failDown     = 3
+	passUp       = 2
+	probeTimeout = 5 * time.Second
+)
+
+// Health tracks whether the payment service behind PAYMENT_URL can take payments, from
+// periodic probes of its health URL. A probe fails on a network error, a non-2xx reply or a
+// reply slower than maxLatency. A nil Health, or one without a URL, is always up.
+type Health struct {
+	log        *zap.Logger
+	url        string
+	maxLatency time.Duration
+	http       *http.Client
+
+	down atomic.Bool
+	// Consecutive probe outcomes, only touched by Run
+	fails, passes int
+}
+
+func NewHealth(log *zap.Logger, url string, maxLatency time.Duration) *Health {
+	metrics.PaymentURLUp.Set(1)
+	return &Health{log: log, url: url, maxLatency: maxLatency, http: &http.Client{Timeout: probeTimeout}}
+}
+
+// Up reports whether payment links can be sent.
+func (h *Health) Up() bool {
+	return h == nil || h.url == "" || !h.down.Load()
+}
+
+// Run probes the health URL now and every interval after until ctx is done. A non-positive
+// interval disables probing.
+func (h *Health) Run(ctx context.Context, interval time.Duration) {
+	if h == nil || h.url == "" || interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		h.check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *Health) check(ctx context.Context) {
+	latency, err := h.probe(ctx)
+	if ctx.Err() != nil {
+		return
+	}
+	metrics.PaymentURLProbeDuration.Observe(latency.Seconds())
+	if err == nil && latency > h.maxLatency {
+		err = fmt.Errorf("slow response: %s", latency.Round(time.Millisecond))
+	}
+
+	if err != nil {
+		h.fails, h.passes = h.fails+1, 0
+		if h.fails >= failDown && h.down.CompareAndSwap(false, true) {
+			metrics.PaymentURLUp.Set(0)
+			h.log.Warn("Payment service is down, deferring payment links", zap.Error(err), zap.String("url", h.url))
+		}
+		return
+	}
+	h.fails, h.passes = 0, h.passes+1
+	if h.passes >= passUp && h.down.CompareAndSwap(true, false) {
+		metrics.PaymentURLUp.Set(1)
+		h.log.Info("Payment service recovered, sending deferred payment links", zap.Duration("latency", latency))
+	}
+}
+
+func (h *Health) probe(ctx context.Context) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.url, nil)
+	if err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	resp, err := h.http.Do(req)
+	if err != nil {
+		return time.Since(start), err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+	latency := time.Since(start)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return latency, fmt.Errorf("health check returned %d", resp.StatusCode)
+	}
+	return latency, nil
+}
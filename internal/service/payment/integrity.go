@@ -0,0 +1,135 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/metrics"
+	mailer "github.com/samirwankhede/lewly-pgpyewj/internal/service/mailer"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/bookings"
+)
+
+// alertSample is how many issues an integrity alert lists.
+const alertSample = 20
+
+var ErrIssueNotFound = errors.New("no open seat integrity issue for booking")
+
+// SeatIntegrity keeps track of bookings whose seats can't be trusted. Payments for a booking
+// without valid seats are refused and the booking flagged; Run periodically flags booked
+// bookings whose stored seats don't match what the seats table assigns them, and resolves
+// its flags once they match again. Newly flagged bookings are emailed to ADMIN_EMAIL.
+type SeatIntegrity struct {
+	log        *zap.Logger
+	bookings   *bookings.BookingsRepository
+	mailer     *mailer.MailerService
+	adminEmail string
+}
+
+func NewSeatIntegrity(log *zap.Logger, bookings *bookings.BookingsRepository, mailer *mailer.MailerService, adminEmail string) *SeatIntegrity {
+	return &SeatIntegrity{log: log, bookings: bookings, mailer: mailer, adminEmail: adminEmail}
+}
+
+// Flag records issue and alerts the admin if it is new.
+func (s *SeatIntegrity) Flag(ctx context.Context, issue *bookings.SeatIssue) {
+	metrics.SeatIntegrityFlagsTotal.WithLabelValues(issue.Source).Inc()
+	s.log.Error("Seat integrity issue", zap.String("booking_id", issue.BookingID), zap.String("event_id", issue.EventID),
+		zap.String("source", issue.Source), zap.String("reason", issue.Reason))
+	opened, err := s.bookings.FlagSeatIssue(ctx, issue)
+	if err != nil {
+		s.log.Error("Failed to record seat integrity issue", zap.Error(err), zap.String("booking_id", issue.BookingID))
+		return
+	}
+	if opened {
+		s.alert([]*bookings.SeatIssue{issue})
+	}
+}
+
+// Run checks now and every interval after until ctx is done. A non-positive interval
+// disables it.
+func (s *SeatIntegrity) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *SeatIntegrity) check(ctx context.Context) {
+	found, err := s.bookings.SeatMismatches(ctx)
+	if err != nil {
+		s.log.Error("Failed to check booking seats", zap.Error(err))
+		return
+	}
+	metrics.SeatIntegrityMismatches.Set(float64(len(found)))
+
+	still := make([]string, 0, len(found))
+	opened := []*bookings.SeatIssue{}
+	for _, issue := range found {
+		issue.Reason = "stored seats don't match the seats table"
+		still = append(still, issue.BookingID)
+		isNew, err := s.bookings.FlagSeatIssue(ctx, issue)
+		if err != nil {
+			s.log.Error("Failed to record seat integrity issue", zap.Error(err), zap.String("booking_id", issue.BookingID))
+			continue
+		}
+		if isNew {
+			metrics.SeatIntegrityFlagsTotal.WithLabelValues(issue.Source).Inc()
+			opened = append(opened, issue)
+		}
+	}
+	resolved, err := s.bookings.ResolveCheckedIssues(ctx, still)
+	if err != nil {
+		s.log.Error("Failed to resolve seat integrity issues", zap.Error(err))
+	}
+	if len(opened) > 0 || resolved > 0 {
+		s.log.Warn("Seat integrity check", zap.Int("mismatched", len(found)), zap.Int("new", len(opened)), zap.Int64("resolved", resolved))
+	}
+	if len(opened) > 0 {
+		s.alert(opened)
+	}
+}
+
+func (s *SeatIntegrity) alert(issues []*bookings.SeatIssue) {
+	if s.mailer == nil || s.adminEmail == "" {
+		return
+	}
+	lines := make([]string, 0, min(len(issues), alertSample))
+	for _, i := range issues[:min(len(issues), alertSample)] {
+		lines = append(lines, fmt.Sprintf("booking %s (event %s): %s; stored [%s], assigned [%s]",
+			i.BookingID, i.EventID, i.Reason, strings.Join(i.Stored, ", "), strings.Join(i.Assigned, ", ")))
+	}
+	if err := s.mailer.SendSeatIntegrityAlertEmail(s.adminEmail, len(issues), lines); err != nil {
+		s.log.Error("Failed to send seat integrity alert", zap.Error(err))
+	}
+}
+
+// Issues lists open issues, or every issue with all, newest first.
+func (s *SeatIntegrity) Issues(ctx context.Context, all bool, limit, offset int) ([]*bookings.SeatIssue, error) {
+	return s.bookings.ListSeatIssues(ctx, all, limit, offset)
+}
+
+// Resolve closes the booking's open issue once an admin has dealt with it. Issues the check
+// still finds are opened again on its next run.
+func (s *SeatIntegrity) Resolve(ctx context.Context, bookingID string) error {
+	ok, err := s.bookings.ResolveSeatIssue(ctx, bookingID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrIssueNotFound
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/domain"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/payments"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/events"
+	storePayments "github.com/samirwankhede/lewly-pgpyewj/internal/store/payments"
+)
+
+const (
+	// Provider events are claimed eventChunk at a time and leased for eventLease; one still
+	// failing after eventMaxAttempts is left for an operator
+	eventChunk       = 50
+	eventLease       = 2 * time.Minute
+	eventMaxAttempts = 10
+)
+
+// Provider event types that carry money for a booking: captured, or only authorized on
+// manual-capture events.
+const (
+	EventPaymentSucceeded  = "payment_intent.succeeded"
+	EventPaymentAuthorized = "payment_intent.amount_capturable_updated"
+)
+
+var ErrIntentsUnsupported = errors.New("payment provider does not support payment intents")
+
+// CreateIntent starts a payment for the user's pending booking that the payment page
+// completes with the provider; the provider's webhook books it once the money is taken.
+// Manual-capture events only authorize the card.
+func (s *PaymentService) CreateIntent(ctx context.Context, bookingID, userID string) (*payments.Intent, error) {
+	ip, ok := s.provider.(payments.IntentProvider)
+	if !ok {
+		return nil, ErrIntentsUnsupported
+	}
+	booking, err := s.bookings.GetByID(ctx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	// Someone else's booking looks the same as a missing one
+	if booking == nil || booking.UserID != userID {
+		return nil, ErrBookingNotFound
+	}
+	if booking.Status != domain.BookingPending {
+		if booking.Status == domain.BookingBooked {
+			return nil, ErrAlreadyPaid
+		}
+		return nil, ErrNotPending
+	}
+	event, err := s.events.Get(ctx, booking.EventID)
+	if err != nil {
+		return nil, err
+	}
+	if event == nil {
+		return nil, ErrEventNotFound
+	}
+
+	seats, err := s.checkSeats(ctx, booking)
+	if err != nil {
+		return nil, err
+	}
+	amount, err := s.events.BookingAmount(ctx, event, booking.ID, seats)
+	if err != nil {
+		return nil, err
+	}
+	intent, err := ip.CreateIntent(ctx, booking.ID, amount, event.Currency, event.PaymentCapture == events.CaptureManual)
+	observe("intent", err)
+	if err != nil {
+		return nil, err
+	}
+	return intent, nil
+}
+
+// RecordProviderEvent stores a verified webhook event for RunProviderEvents to apply, so the
+// webhook can be acknowledged at once. It reports false for an event already recorded.
+func (s *PaymentService) RecordProviderEvent(ctx context.Context, e *storePayments.ProviderEvent) (bool, error) {
+	return s.payments.RecordEvent(ctx, e)
+}
+
+// RunProviderEvents applies recorded provider events now and every interval after until ctx
+// is done. Any number of instances can run it; each event is claimed by one of them.
+func (s *PaymentService) RunProviderEvents(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.applyEvents(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *PaymentService) applyEvents(ctx context.Context) {
+	for ctx.Err() == nil {
+		claimed, err := s.payments.ClaimEvents(ctx, eventChunk, eventMaxAttempts, eventLease)
+		if err != nil {
+			s.log.Error("Failed to claim provider events", zap.Error(err))
+			return
+		}
+		for _, e := range claimed {
+			if err := s.applyEvent(ctx, e); err != nil {
+				s.log.Error("Failed to apply provider event", zap.Error(err), zap.String("event_id", e.ID), zap.Int("attempts", e.Attempts))
+				if err := s.payments.ReleaseEvent(ctx, e.ID, err.Error()); err != nil {
+					s.log.Error("Failed to release provider event", zap.Error(err), zap.String("event_id", e.ID))
+				}
+				continue
+			}
+			if err := s.payments.EventProcessed(ctx, e.ID); err != nil {
+				s.log.Error("Failed to mark provider event processed", zap.Error(err), zap.String("event_id", e.ID))
+			}
+		}
+		if len(claimed) < eventChunk {
+			return
+		}
+	}
+}
+
+// applyEvent books the event's booking with the payment it reports. Money that arrives for a
+// booking that can't take it (no longer pending, or paid short or in another currency) is
+// given back. Errors leave the event to be retried.
+func (s *PaymentService) applyEvent(ctx context.Context, e *storePayments.ProviderEvent) error {
+	authorized := e.Type == EventPaymentAuthorized
+	if e.BookingID == nil {
+		s.log.Warn("Provider event without a booking, ignoring it", zap.String("event_id", e.ID), zap.String("ref", e.ProviderRef))
+		return nil
+	}
+	booking, err := s.bookings.GetByID(ctx, *e.BookingID)
+	if err != nil {
+		return err
+	}
+	if booking == nil {
+		s.log.Warn("Provider event for an unknown booking, ignoring it", zap.String("event_id", e.ID), zap.String("booking_id", *e.BookingID))
+		return nil
+	}
+
+	if booking.Status != domain.BookingPending {
+		live, err := s.payments.GetLive(ctx, booking.ID)
+		if err != nil {
+			return err
+		}
+		// Already applied: the provider reports an authorization and then its capture
+		if live != nil && live.ProviderRef != nil && *live.ProviderRef == e.ProviderRef {
+			return nil
+		}
+		s.log.Warn("Payment for a booking that is no longer pending, reversing it", zap.String("booking_id", booking.ID), zap.String("status", string(booking.Status)))
+		s.reverse(ctx, authorized, e.ProviderRef, e.Amount)
+		return nil
+	}
+
+	event, err := s.events.Get(ctx, booking.EventID)
+	if err != nil {
+		return err
+	}
+	if event == nil {
+		return ErrEventNotFound
+	}
+	seats, err := s.checkSeats(ctx, booking)
+	if errors.Is(err, ErrInvalidSeats) {
+		s.log.Warn("Payment for a booking without valid seats, reversing it", zap.String("booking_id", booking.ID))
+		s.reverse(ctx, authorized, e.ProviderRef, e.Amount)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	expected, err := s.events.BookingAmount(ctx, event, booking.ID, seats)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(e.Currency, event.Currency) || e.Amount < expected {
+		s.log.Warn("Payment does not cover the booking, reversing it", zap.String("booking_id", booking.ID),
+			zap.Float64("amount", e.Amount), zap.String("currency", e.Currency), zap.Float64("expected", expected))
+		s.reverse(ctx, authorized, e.ProviderRef, e.Amount)
+		return nil
+	}
+
+	_, err = s.settle(ctx, booking, seats, e.ID, e.ProviderRef, e.Amount, event.Currency, authorized)
+	if errors.Is(err, ErrAlreadyPaid) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("settle booking %s: %w", booking.ID, err)
+	}
+	s.log.Info("Booking paid through provider webhook", zap.String("booking_id", booking.ID), zap.String("event_id", e.ID), zap.Bool("authorized", authorized))
+	return nil
+}
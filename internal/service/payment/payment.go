@@ -5,18 +5,54 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
 
+	"github.com/samirwankhede/lewly-pgpyewj/internal/domain"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/metrics"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/payments"
+	redisx "github.com/samirwankhede/lewly-pgpyewj/internal/redis"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/refunds"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/service/jobs"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/service/mailer"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/service/milestones"
+	walletService "github.com/samirwankhede/lewly-pgpyewj/internal/service/wallet"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/store/bookings"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/store/events"
+	storeJobs "github.com/samirwankhede/lewly-pgpyewj/internal/store/jobs"
+	storePayments "github.com/samirwankhede/lewly-pgpyewj/internal/store/payments"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/users"
+)
+
+const (
+	// captureChunk is how many authorizations an event capture claims at a time.
+	captureChunk = 100
+	// captureLease is how long a capture holds its claim before another may take it over.
+	captureLease = 5 * time.Minute
+	// transactionLease is how long a payment callback holds its transaction ID before a
+	// retry may take it over.
+	transactionLease = 2 * time.Minute
 )
 
 type PaymentService struct {
-	log      *zap.Logger
-	bookings *bookings.BookingsRepository
-	events   *events.EventsRepository
+	log          *zap.Logger
+	bookings     *bookings.BookingsRepository
+	events       *events.EventsRepository
+	milestones   *milestones.MilestonesService
+	notify       *redisx.BookingEvents
+	jobs         *jobs.Runner
+	payments     *storePayments.PaymentsRepository
+	provider     payments.Provider
+	timeouts     *redisx.TimeoutBucket
+	maxExtension time.Duration
+	// integrity is told about bookings refused for invalid seats; nil only logs them
+	integrity *SeatIntegrity
+	// users, mailer and wallet send confirmation emails; no emails are sent while mailer is nil
+	users  *users.UsersRepository
+	mailer *mailer.MailerService
+	wallet *walletService.WalletService
 }
 
 type PaymentRequest struct {
@@ -29,25 +65,207 @@ type PaymentResponse struct {
 	Success   bool   `json:"success"`
 	Message   string `json:"message"`
 	BookingID string `json:"booking_id,omitempty"`
+	// Refund is how a cancellation refund was worked out
+	Refund *refunds.Breakdown `json:"refund,omitempty"`
 }
 
 var (
-	ErrBookingNotFound = errors.New("booking not found")
-	ErrInvalidAmount   = errors.New("invalid amount")
-	ErrPaymentFailed   = errors.New("payment failed")
-	ErrBookingExpired  = errors.New("booking expired")
-	ErrAlreadyPaid     = errors.New("booking already paid")
+	ErrBookingNotFound   = errors.New("booking not found")
+	ErrInvalidAmount     = errors.New("invalid amount")
+	ErrPaymentFailed     = errors.New("payment failed")
+	ErrBookingExpired    = errors.New("booking expired")
+	ErrAlreadyPaid       = errors.New("booking already paid")
+	ErrNotPending        = errors.New("booking is not awaiting payment")
+	ErrInvalidExtension  = errors.New("invalid extension")
+	ErrEventNotFound     = errors.New("event not found")
+	ErrNotAuthorized     = errors.New("booking has no authorized payment to capture")
+	ErrInvalidSeats      = errors.New("booking has no valid seats; support has been alerted")
+	ErrTransactionReused = errors.New("payment ID was already used for another booking")
+	ErrPaymentInProgress = errors.New("payment is already being processed")
 )
 
-func NewPaymentService(log *zap.Logger, bookings *bookings.BookingsRepository, events *events.EventsRepository) *PaymentService {
-	return &PaymentService{
-		log:      log,
-		bookings: bookings,
-		events:   events,
+// HoldExtension is the outcome of extending a booking's payment window.
+type HoldExtension struct {
+	BookingID string    `json:"booking_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func NewPaymentService(log *zap.Logger, bookings *bookings.BookingsRepository, events *events.EventsRepository, milestones *milestones.MilestonesService, notify *redisx.BookingEvents, jobs *jobs.Runner, payments *storePayments.PaymentsRepository, provider payments.Provider) *PaymentService {
+	return &PaymentService{log: log, bookings: bookings, events: events, milestones: milestones, notify: notify, jobs: jobs, payments: payments, provider: provider}
+}
+
+// WithSeatIntegrity flags bookings refused for invalid seats with integrity, which alerts
+// the admin.
+func (s *PaymentService) WithSeatIntegrity(integrity *SeatIntegrity) *PaymentService {
+	s.integrity = integrity
+	return s
+}
+
+// WithConfirmations emails buyers when their booking is confirmed, linking wallet passes
+// from wallet if it is non-nil.
+func (s *PaymentService) WithConfirmations(users *users.UsersRepository, mailer *mailer.MailerService, wallet *walletService.WalletService) *PaymentService {
+	s.users = users
+	s.mailer = mailer
+	s.wallet = wallet
+	return s
+}
+
+// checkSeats returns the booking's seats if they are a non-empty list of distinct labels on
+// its event's seat map. Otherwise the booking is flagged and ErrInvalidSeats returned, so it
+// is never charged for, or finalized with, seats that don't exist.
+func (s *PaymentService) checkSeats(ctx context.Context, booking *bookings.Booking) ([]string, error) {
+	seats := []string(booking.Seats)
+	reason := ""
+	seen := make(map[string]bool, len(seats))
+	for _, label := range seats {
+		if strings.TrimSpace(label) == "" || seen[label] {
+			reason = fmt.Sprintf("blank or repeated seat label %q", label)
+			break
+		}
+		seen[label] = true
+	}
+	if len(seats) == 0 {
+		reason = "booking has no seats"
+	}
+	if reason == "" {
+		unmapped, err := s.events.UnmappedSeats(ctx, booking.EventID, seats)
+		if err != nil {
+			return nil, err
+		}
+		if len(unmapped) > 0 {
+			reason = "seats not on the event's seat map: " + strings.Join(unmapped, ", ")
+		}
+	}
+	if reason == "" {
+		return seats, nil
+	}
+
+	issue := &bookings.SeatIssue{BookingID: booking.ID, EventID: booking.EventID, Source: bookings.IssueSourcePayment, Reason: reason, Stored: booking.Seats}
+	if s.integrity != nil {
+		s.integrity.Flag(ctx, issue)
+	} else {
+		s.log.Error("Seat integrity issue", zap.String("booking_id", booking.ID), zap.String("reason", reason))
+	}
+	return nil, ErrInvalidSeats
+}
+
+// WithHoldExtension lets payment windows be extended once, by at most max, while a payment
+// is in progress.
+func (s *PaymentService) WithHoldExtension(timeouts *redisx.TimeoutBucket, max time.Duration) *PaymentService {
+	s.timeouts = timeouts
+	s.maxExtension = max
+	return s
+}
+
+// ExtendHold moves a pending booking's payment deadline back by by (the maximum when 0),
+// so a timeout can't cancel it while the provider is still confirming the payment. A
+// booking's window is extended at most once. userID, when set, must own the booking.
+func (s *PaymentService) ExtendHold(ctx context.Context, bookingID, userID string, by time.Duration) (*HoldExtension, error) {
+	if s.timeouts == nil || s.maxExtension <= 0 {
+		return nil, fmt.Errorf("%w: payment window extensions are disabled", ErrInvalidExtension)
+	}
+	if by == 0 {
+		by = s.maxExtension
+	}
+	if by < time.Second || by > s.maxExtension {
+		return nil, fmt.Errorf("%w: extension must be between 1 and %d seconds", ErrInvalidExtension, int(s.maxExtension/time.Second))
+	}
+
+	booking, err := s.bookings.GetByID(ctx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	// Someone else's booking looks the same as a missing one
+	if booking == nil || (userID != "" && booking.UserID != userID) {
+		return nil, ErrBookingNotFound
+	}
+	if booking.Status != domain.BookingPending {
+		if booking.Status == domain.BookingBooked {
+			return nil, ErrAlreadyPaid
+		}
+		return nil, ErrNotPending
+	}
+
+	deadline, err := s.timeouts.Extend(ctx, booking.EventID, booking.ID, by)
+	if err != nil {
+		return nil, err
+	}
+	s.log.Info("Extended payment window", zap.String("booking_id", booking.ID), zap.Duration("by", by), zap.Time("expires_at", deadline))
+
+	if s.notify != nil {
+		e := redisx.BookingEvent{Type: redisx.BookingEventPaymentExtended, BookingID: booking.ID, Status: domain.BookingPending, ExpiresAt: &deadline}
+		if err := s.notify.Publish(ctx, e); err != nil {
+			s.log.Error("Failed to publish booking event", zap.Error(err), zap.String("booking_id", booking.ID))
+		}
+	}
+
+	return &HoldExtension{BookingID: booking.ID, ExpiresAt: deadline}, nil
+}
+
+// NoteProvider records provider as handling a pending booking's payment. Failures are only
+// logged; the booking is processed either way.
+func (s *PaymentService) NoteProvider(ctx context.Context, bookingID, provider string) {
+	if provider == "" {
+		return
+	}
+	if err := s.bookings.SetPaymentProvider(ctx, bookingID, provider); err != nil {
+		s.log.Warn("Failed to record payment provider", zap.Error(err), zap.String("booking_id", bookingID), zap.String("provider", provider))
 	}
 }
 
+// ProcessBookingPayment charges (or, on manual-capture events, authorizes) req.PaymentID
+// for a pending booking and books it. Callbacks are idempotent on req.PaymentID: a
+// duplicate gets the first one's response back without charging or finalizing again, and
+// ErrPaymentInProgress while the first is still being processed.
 func (s *PaymentService) ProcessBookingPayment(ctx context.Context, req PaymentRequest) (*PaymentResponse, error) {
+	if req.PaymentID == "" {
+		return s.processBookingPayment(ctx, req)
+	}
+	held, err := s.payments.ClaimTransaction(ctx, &storePayments.Transaction{PaymentID: req.PaymentID, BookingID: req.BookingID, Amount: req.Amount}, transactionLease)
+	if err != nil {
+		return nil, err
+	}
+	if held != nil {
+		return s.replay(held, req)
+	}
+
+	resp, err := s.processBookingPayment(ctx, req)
+	if err != nil {
+		// Nothing was settled under this transaction; let the provider's retry run it again
+		if relErr := s.payments.ReleaseTransaction(ctx, req.PaymentID); relErr != nil {
+			s.log.Error("Failed to release payment transaction", zap.Error(relErr), zap.String("payment_id", req.PaymentID))
+		}
+		return nil, err
+	}
+	result, err := json.Marshal(resp)
+	if err == nil {
+		err = s.payments.CompleteTransaction(ctx, req.PaymentID, result)
+	}
+	if err != nil {
+		// The payment went through; a duplicate now waits out the lease and gets ErrAlreadyPaid
+		s.log.Error("Failed to record payment transaction result", zap.Error(err), zap.String("payment_id", req.PaymentID))
+	}
+	return resp, nil
+}
+
+// replay answers a callback for a transaction ID that was already claimed.
+func (s *PaymentService) replay(held *storePayments.Transaction, req PaymentRequest) (*PaymentResponse, error) {
+	if held.BookingID != req.BookingID {
+		return nil, ErrTransactionReused
+	}
+	if held.CompletedAt == nil {
+		return nil, ErrPaymentInProgress
+	}
+	resp := &PaymentResponse{}
+	if err := json.Unmarshal(held.Result, resp); err != nil {
+		return nil, err
+	}
+	metrics.DuplicatePaymentCallbacksTotal.Inc()
+	s.log.Info("Duplicate payment callback, returning the original result", zap.String("payment_id", req.PaymentID), zap.String("booking_id", req.BookingID))
+	return resp, nil
+}
+
+func (s *PaymentService) processBookingPayment(ctx context.Context, req PaymentRequest) (*PaymentResponse, error) {
 	// Get booking
 	booking, err := s.bookings.GetByID(ctx, req.BookingID)
 	if err != nil {
@@ -58,8 +276,8 @@ func (s *PaymentService) ProcessBookingPayment(ctx context.Context, req PaymentR
 	}
 
 	// Check if booking is still pending
-	if booking.Status != "pending" {
-		if booking.Status == "booked" {
+	if booking.Status != domain.BookingPending {
+		if booking.Status == domain.BookingBooked {
 			return nil, ErrAlreadyPaid
 		}
 		return nil, fmt.Errorf("booking is in %s status", booking.Status)
@@ -74,55 +292,140 @@ func (s *PaymentService) ProcessBookingPayment(ctx context.Context, req PaymentR
 		return nil, errors.New("event not found")
 	}
 
-	// Parse seats from JSON to get actual count
-	var seats []string
-	if len(booking.Seats) > 0 {
-		if err := json.Unmarshal(booking.Seats, &seats); err != nil {
-			s.log.Error("Failed to parse seats JSON", zap.Error(err))
-			seats = []string{"seat1"} // fallback
-		}
-	}
-	if len(seats) == 0 {
-		seats = []string{"seat1"} // fallback
+	seats, err := s.checkSeats(ctx, booking)
+	if err != nil {
+		return nil, err
 	}
 
-	// Validate amount based on actual seat count
-	expectedAmount := event.TicketPrice * float64(len(seats))
+	// Validate amount based on the seats and their price tiers
+	expectedAmount, err := s.events.BookingAmount(ctx, event, booking.ID, seats)
+	if err != nil {
+		return nil, err
+	}
 	if req.Amount < expectedAmount {
 		return nil, ErrInvalidAmount
 	}
 
-	// Simulate payment processing (in real implementation, integrate with Stripe/PayPal)
-	success := s.simulatePaymentProcessing(req.PaymentID, req.Amount)
-	if !success {
+	// Manual-capture events only authorize the card now; it is charged once captured
+	manual := event.PaymentCapture == events.CaptureManual
+	operation, charge := "charge", s.provider.Charge
+	if manual {
+		operation, charge = "authorize", s.provider.Authorize
+	}
+	ref, err := charge(ctx, req.PaymentID, req.Amount, event.Currency)
+	observe(operation, err)
+	if err != nil {
+		s.log.Error("Payment processing failed", zap.Error(err), zap.String("booking_id", req.BookingID), zap.String("operation", operation))
+		msg := err.Error()
+		failed := &storePayments.Payment{BookingID: booking.ID, EventID: booking.EventID, PaymentID: req.PaymentID,
+			Amount: req.Amount, Currency: event.Currency, State: storePayments.StateFailed, Error: &msg}
+		if _, err := s.payments.Create(ctx, failed); err != nil {
+			s.log.Error("Failed to record failed payment", zap.Error(err), zap.String("booking_id", req.BookingID))
+		}
 		return &PaymentResponse{
 			Success: false,
 			Message: "Payment processing failed",
 		}, nil
 	}
 
-	// Update booking status to paid
-	err = s.bookings.UpdatePaymentStatus(ctx, req.BookingID, "paid", req.Amount)
+	return s.settle(ctx, booking, seats, req.PaymentID, ref, req.Amount, event.Currency, manual)
+}
+
+// settle records a payment the provider took (or, when authorized, only authorized) for a
+// pending booking and books it. A booking that already has a live payment gets this one
+// reversed and ErrAlreadyPaid.
+func (s *PaymentService) settle(ctx context.Context, booking *bookings.Booking, seats []string, paymentID, ref string, amount float64, currency string, authorized bool) (*PaymentResponse, error) {
+	state, paymentStatus, amountPaid := storePayments.StateCaptured, "paid", amount
+	if authorized {
+		state, paymentStatus, amountPaid = storePayments.StateAuthorized, "authorized", 0
+	}
+	_, err := s.payments.Create(ctx, &storePayments.Payment{BookingID: booking.ID, EventID: booking.EventID, PaymentID: paymentID,
+		ProviderRef: &ref, Amount: amount, Currency: currency, State: state})
 	if err != nil {
-		s.log.Error("Failed to update payment status", zap.Error(err))
+		if errors.Is(err, storePayments.ErrLivePayment) {
+			// A concurrent request paid for the booking first; give this payment back
+			s.log.Warn("Duplicate payment for booking, reversing it", zap.String("booking_id", booking.ID))
+			s.reverse(ctx, authorized, ref, amount)
+			return nil, ErrAlreadyPaid
+		}
+		s.log.Error("Failed to record payment", zap.Error(err), zap.String("booking_id", booking.ID))
 		return nil, err
 	}
 
 	// Finalize booking (mark as booked and update event reserved count)
-	seatsBytes, _ := json.Marshal(seats)
-	err = s.bookings.FinalizeBooking(ctx, req.BookingID, seatsBytes, req.Amount)
+	err = s.bookings.FinalizeBooking(ctx, booking.ID, seats, amountPaid, paymentStatus)
 	if err != nil {
 		s.log.Error("Failed to finalize booking", zap.Error(err))
 		return nil, err
 	}
 
+	if s.notify != nil {
+		e := redisx.BookingEvent{Type: redisx.BookingEventPaymentReceived, BookingID: booking.ID, Status: domain.BookingBooked}
+		if err := s.notify.Publish(ctx, e); err != nil {
+			s.log.Error("Failed to publish booking event", zap.Error(err), zap.String("booking_id", booking.ID))
+		}
+	}
+
+	// Sales milestones are computed from finalized bookings, off the payment's critical path
+	if s.milestones != nil {
+		go s.milestones.Check(context.Background(), booking.EventID)
+	}
+	if s.mailer != nil {
+		go s.sendConfirmation(context.Background(), booking.ID)
+	}
+
+	message := "Payment processed successfully"
+	if authorized {
+		message = "Payment authorized; the card is charged when the booking is confirmed"
+	}
 	return &PaymentResponse{
 		Success:   true,
-		Message:   "Payment processed successfully",
-		BookingID: req.BookingID,
+		Message:   message,
+		BookingID: booking.ID,
 	}, nil
 }
 
+// sendConfirmation emails the booking's holder that it is confirmed, with links to its
+// wallet passes.
+func (s *PaymentService) sendConfirmation(ctx context.Context, bookingID string) {
+	b, err := s.bookings.GetByID(ctx, bookingID)
+	if err != nil || b == nil {
+		s.log.Error("Failed to load booking for confirmation email", zap.Error(err), zap.String("booking_id", bookingID))
+		return
+	}
+	e, err := s.events.Get(ctx, b.EventID)
+	if err != nil || e == nil {
+		s.log.Error("Failed to load event for confirmation email", zap.Error(err), zap.String("booking_id", bookingID))
+		return
+	}
+	u, err := s.users.GetByID(ctx, b.UserID)
+	if err != nil || u == nil {
+		s.log.Error("Failed to load user for confirmation email", zap.Error(err), zap.String("booking_id", bookingID))
+		return
+	}
+	var links map[string]string
+	if s.wallet != nil {
+		links = s.wallet.Links(b, e)
+	}
+	// The mailer logs failures itself
+	_ = s.mailer.For(e.OrganizerID).About(b.ID).SendBookingConfirmationEmail(u.Email, e.Name, e.Venue, e.StartTime, b.Seats, b.ID, links)
+}
+
+// reverse gives back a payment taken twice for the same booking.
+func (s *PaymentService) reverse(ctx context.Context, authorized bool, ref string, amount float64) {
+	var err error
+	if authorized {
+		err = s.provider.Void(ctx, ref)
+		observe("void", err)
+	} else {
+		err = s.provider.Refund(ctx, ref, amount)
+		observe("refund", err)
+	}
+	if err != nil {
+		s.log.Error("Failed to reverse duplicate payment", zap.Error(err), zap.String("ref", ref))
+	}
+}
+
 func (s *PaymentService) ProcessCancellationRefund(ctx context.Context, BookingID string) (*PaymentResponse, error) {
 	// Get booking
 	booking, err := s.bookings.GetByID(ctx, BookingID)
@@ -133,6 +436,36 @@ func (s *PaymentService) ProcessCancellationRefund(ctx context.Context, BookingI
 		return nil, ErrBookingNotFound
 	}
 
+	if booking.PaymentStatus == "voided" {
+		return &PaymentResponse{
+			Success:   true,
+			Message:   "Authorization already voided; nothing was charged",
+			BookingID: BookingID,
+		}, nil
+	}
+	// Nothing was charged for an authorized booking; releasing the hold on the card is enough
+	if booking.PaymentStatus == "authorized" {
+		voided, err := s.VoidBooking(ctx, booking.ID)
+		if err != nil {
+			s.log.Error("Failed to void authorization", zap.Error(err), zap.String("booking_id", booking.ID))
+			return &PaymentResponse{
+				Success: false,
+				Message: "Voiding the authorization failed",
+			}, nil
+		}
+		if voided {
+			return &PaymentResponse{
+				Success:   true,
+				Message:   "Authorization voided; nothing was charged",
+				BookingID: BookingID,
+			}, nil
+		}
+		// A capture got there first; refund what it took
+		if booking, err = s.bookings.GetByID(ctx, BookingID); err != nil {
+			return nil, err
+		}
+	}
+
 	// Check if booking was actually paid
 	if booking.PaymentStatus != "paid" {
 		return nil, errors.New("booking was not paid")
@@ -147,16 +480,20 @@ func (s *PaymentService) ProcessCancellationRefund(ctx context.Context, BookingI
 		return nil, errors.New("event not found")
 	}
 
-	// Calculate refund amount (subtract cancellation fee)
-	cancellationFee := event.CancellationFee
-	refundAmount := booking.AmountPaid - cancellationFee
-	if refundAmount < 0 {
-		refundAmount = 0
+	// Every seat is refunded what it paid less its share of the cancellation fee left after
+	// any seats cancelled earlier
+	fee, err := s.feeLeft(ctx, booking, event)
+	if err != nil {
+		return nil, err
+	}
+	breakdown, err := s.refundBreakdown(ctx, booking, event, fee, nil)
+	if err != nil {
+		return nil, err
 	}
+	refundAmount := breakdown.Refund
 
-	// Simulate refund processing
-	success := s.simulateRefundProcessing(booking.ID, refundAmount)
-	if !success {
+	if err := s.refund(ctx, booking, storePayments.RefundBookingCancelled, breakdown); err != nil {
+		s.log.Error("Refund processing failed", zap.Error(err), zap.String("booking_id", booking.ID))
 		return &PaymentResponse{
 			Success: false,
 			Message: "Refund processing failed",
@@ -172,66 +509,161 @@ func (s *PaymentService) ProcessCancellationRefund(ctx context.Context, BookingI
 
 	return &PaymentResponse{
 		Success:   true,
-		Message:   fmt.Sprintf("Refund processed successfully. Amount: %.2f, Cancellation fee: %.2f", refundAmount, cancellationFee),
+		Message:   fmt.Sprintf("Refund processed successfully. Amount: %.2f, Cancellation fee: %.2f", refundAmount, breakdown.CancellationFee),
 		BookingID: BookingID,
+		Refund:    breakdown,
 	}, nil
 }
 
-func (s *PaymentService) ProcessEventCancellationRefund(ctx context.Context, eventID string) error {
-	// Get all paid bookings for the event
-	bookings, err := s.bookings.ListByEvent(ctx, eventID, 1000, 0) // Get all bookings
-	if err != nil {
-		return err
-	}
+// EventRefundResult is what an event cancellation refund job paid back.
+type EventRefundResult struct {
+	Refunded int     `json:"refunded"`
+	Amount   float64 `json:"amount"`
+	// Voided counts authorizations released without a charge
+	Voided int `json:"voided"`
+}
 
-	// Get event details
+// ProcessEventCancellationRefund refunds every paid booking of the event in full and voids
+// its uncaptured authorizations, in a background job that counts each booking as it is
+// refunded, voided or fails.
+func (s *PaymentService) ProcessEventCancellationRefund(ctx context.Context, eventID string) (*storeJobs.Job, error) {
 	event, err := s.events.Get(ctx, eventID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if event == nil {
-		return errors.New("event not found")
-	}
-
-	// Process refunds for all paid bookings
-	for _, booking := range bookings {
-		if booking.PaymentStatus == "paid" {
-			// Full refund for event cancellation
-			success := s.simulateRefundProcessing(booking.ID, booking.AmountPaid)
-			if success {
-				err = s.bookings.UpdatePaymentStatus(ctx, booking.ID, "refunded", booking.AmountPaid)
-				if err != nil {
-					s.log.Error("Failed to update refund status", zap.Error(err), zap.String("booking_id", booking.ID))
-				}
-			} else {
-				s.log.Error("Refund processing failed", zap.String("booking_id", booking.ID))
-			}
-		}
+		return nil, ErrEventNotFound
 	}
-
-	return nil
+	return s.jobs.Start(ctx, "event_refund", &eventID, func(ctx context.Context, p *jobs.Progress) (any, error) {
+		return s.refundEvent(ctx, event, p)
+	})
 }
 
-// Simulate payment processing (replace with real payment provider integration)
-func (s *PaymentService) simulatePaymentProcessing(paymentID string, amount float64) bool {
-	// In real implementation, this would call Stripe/PayPal API
-	s.log.Info("Processing payment", zap.String("payment_id", paymentID), zap.Float64("amount", amount))
+func (s *PaymentService) refundEvent(ctx context.Context, event *events.Event, p *jobs.Progress) (*EventRefundResult, error) {
+	paid, err := s.bookings.ListPaidByEvent(ctx, event.ID)
+	if err != nil {
+		return nil, err
+	}
+	authorized, err := s.payments.ListByEvent(ctx, event.ID, storePayments.StateAuthorized)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.SetTotal(ctx, len(paid)+len(authorized)); err != nil {
+		return nil, err
+	}
 
-	// Simulate some processing time
-	time.Sleep(100 * time.Millisecond)
+	res := &EventRefundResult{}
+	for _, booking := range paid {
+		// Full refund for event cancellation
+		breakdown, err := s.refundBreakdown(ctx, booking, event, 0, nil)
+		if err != nil {
+			s.log.Error("Failed to work out refund", zap.Error(err), zap.String("booking_id", booking.ID))
+			p.Fail(ctx, fmt.Sprintf("booking %s: refund not worked out: %v", booking.ID, err))
+			continue
+		}
+		if err := s.refund(ctx, booking, storePayments.RefundEventCancelled, breakdown); err != nil {
+			s.log.Error("Refund processing failed", zap.Error(err), zap.String("booking_id", booking.ID))
+			p.Fail(ctx, fmt.Sprintf("booking %s: refund failed: %v", booking.ID, err))
+			continue
+		}
+		if err := s.bookings.UpdatePaymentStatus(ctx, booking.ID, "refunded", breakdown.Refund); err != nil {
+			s.log.Error("Failed to update refund status", zap.Error(err), zap.String("booking_id", booking.ID))
+			p.Fail(ctx, fmt.Sprintf("booking %s: refunded but not recorded: %v", booking.ID, err))
+			continue
+		}
+		res.Refunded++
+		res.Amount += breakdown.Refund
+		p.Succeed(ctx)
+	}
+	for _, auth := range authorized {
+		if _, err := s.void(ctx, auth); err != nil {
+			s.log.Error("Failed to void authorization", zap.Error(err), zap.String("booking_id", auth.BookingID))
+			p.Fail(ctx, fmt.Sprintf("booking %s: void failed: %v", auth.BookingID, err))
+			continue
+		}
+		res.Voided++
+		p.Succeed(ctx)
+	}
+	return res, nil
+}
 
-	// Simulate 95% success rate
-	return true // Always succeed for demo
+// feeLeft is the event's cancellation fee less what the booking's earlier refunds kept, so a
+// booking that gave up seats one at a time is never charged more than one fee.
+func (s *PaymentService) feeLeft(ctx context.Context, booking *bookings.Booking, event *events.Event) (float64, error) {
+	charged, err := s.payments.FeesCharged(ctx, booking.ID)
+	if err != nil {
+		return 0, err
+	}
+	return max(0, event.CancellationFee-charged), nil
 }
 
-// Simulate refund processing (replace with real payment provider integration)
-func (s *PaymentService) simulateRefundProcessing(bookingID string, amount float64) bool {
-	// In real implementation, this would call Stripe/PayPal API
-	s.log.Info("Processing refund", zap.String("booking_id", bookingID), zap.Float64("amount", amount))
+// refundBreakdown works out the refund of the booking's seats, all of them when seats is nil,
+// less their share of fee, in the currency it paid in, with what it paid split across its
+// seats by their list prices.
+func (s *PaymentService) refundBreakdown(ctx context.Context, booking *bookings.Booking, event *events.Event, fee float64, seats []string) (*refunds.Breakdown, error) {
+	prices, err := s.events.SeatPrices(ctx, event, booking.ID, booking.Seats)
+	if err != nil {
+		return nil, err
+	}
+	currency := booking.Currency
+	if currency == "" {
+		currency = event.Currency
+	}
+	return refunds.Calculate(refunds.Input{
+		Currency:        currency,
+		AmountPaid:      booking.AmountPaid,
+		Seats:           booking.Seats,
+		Prices:          prices,
+		CancellationFee: fee,
+		Refund:          seats,
+	})
+}
 
-	// Simulate some processing time
-	time.Sleep(100 * time.Millisecond)
+// refund pays the breakdown's refund of the booking's captured payment back, and records it
+// with the breakdown. Bookings paid before payments were recorded are refunded by booking ID.
+// The payment is only marked refunded once every seat it paid for is.
+func (s *PaymentService) refund(ctx context.Context, booking *bookings.Booking, reason string, breakdown *refunds.Breakdown) error {
+	live, err := s.payments.GetLive(ctx, booking.ID)
+	if err != nil {
+		return err
+	}
+	ref := booking.ID
+	if live != nil && live.ProviderRef != nil {
+		ref = *live.ProviderRef
+	}
+	err = s.provider.Refund(ctx, ref, breakdown.Refund)
+	observe("refund", err)
+	if err != nil {
+		return err
+	}
+	record := &storePayments.Refund{BookingID: booking.ID, Reason: reason, Currency: breakdown.Currency,
+		Amount: breakdown.Refund, CancellationFee: breakdown.CancellationFee, ProviderRef: &ref}
+	if live != nil {
+		record.PaymentID = &live.ID
+		if breakdown.Paid == breakdown.AmountPaid {
+			if err := s.payments.Refunded(ctx, live.ID); err != nil {
+				s.log.Error("Failed to record refund", zap.Error(err), zap.String("payment_id", live.ID))
+			}
+		}
+	}
+	// The money has moved; a lost record is logged rather than failing the refund
+	if record.Breakdown, err = json.Marshal(breakdown); err == nil {
+		err = s.payments.CreateRefund(ctx, record)
+	}
+	if err != nil {
+		s.log.Error("Failed to record refund breakdown", zap.Error(err), zap.String("booking_id", booking.ID))
+	}
+	return nil
+}
 
-	// Simulate 95% success rate
-	return true // Always succeed for demo
+// observe counts a provider call by its outcome.
+func observe(operation string, err error) {
+	outcome := "ok"
+	switch {
+	case errors.Is(err, payments.ErrDeclined):
+		outcome = "declined"
+	case err != nil:
+		outcome = "error"
+	}
+	metrics.PaymentsTotal.WithLabelValues(operation, outcome).Inc()
 }
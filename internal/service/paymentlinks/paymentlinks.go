@@ -0,0 +1,88 @@
+package paymentlinks
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/paymentlinks"
+)
+
+const (
+	codeLength   = 8
+	codeAlphabet = "abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	// createAttempts bounds retries on code collisions.
+	createAttempts = 5
+)
+
+var (
+	ErrLinkNotFound = errors.New("payment link not found")
+	ErrLinkExpired  = errors.New("payment link expired")
+)
+
+type PaymentLinksService struct {
+	log     *zap.Logger
+	repo    *paymentlinks.PaymentLinksRepository
+	baseURL string
+}
+
+func NewPaymentLinksService(log *zap.Logger, repo *paymentlinks.PaymentLinksRepository, baseURL string) *PaymentLinksService {
+	return &PaymentLinksService{log: log, repo: repo, baseURL: baseURL}
+}
+
+// Shorten stores target under a new code that expires at expiresAt and returns the short URL.
+func (s *PaymentLinksService) Shorten(ctx context.Context, bookingID, target string, expiresAt time.Time) (string, error) {
+	for i := 0; i < createAttempts; i++ {
+		code, err := newCode()
+		if err != nil {
+			return "", err
+		}
+		ok, err := s.repo.Create(ctx, &paymentlinks.Link{Code: code, BookingID: bookingID, TargetURL: target, ExpiresAt: expiresAt})
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return s.baseURL + "/p/" + code, nil
+		}
+	}
+	return "", errors.New("could not allocate a unique payment link code")
+}
+
+// Resolve returns the target of code and records the click. Clicks on expired links are
+// recorded too, since they're the interesting ones for support.
+func (s *PaymentLinksService) Resolve(ctx context.Context, code, userAgent string) (string, error) {
+	l, err := s.repo.Get(ctx, code)
+	if err != nil {
+		return "", err
+	}
+	if l == nil {
+		return "", ErrLinkNotFound
+	}
+
+	expired := time.Now().After(l.ExpiresAt)
+	if err := s.repo.RecordClick(ctx, l, expired, userAgent); err != nil {
+		s.log.Error("Failed to record payment link click", zap.Error(err), zap.String("booking_id", l.BookingID))
+	}
+	if expired {
+		return "", ErrLinkExpired
+	}
+	return l.TargetURL, nil
+}
+
+func (s *PaymentLinksService) ListClicks(ctx context.Context, bookingID string) ([]*paymentlinks.Click, error) {
+	return s.repo.ListClicksByBooking(ctx, bookingID)
+}
+
+func newCode() (string, error) {
+	b := make([]byte, codeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = codeAlphabet[int(b[i])%len(codeAlphabet)]
+	}
+	return string(b), nil
+}
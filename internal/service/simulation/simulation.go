@@ -0,0 +1,242 @@
+package simulation
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// DefaultPaymentWindow matches the payment timeout the finalize worker schedules for pending bookings.
+const DefaultPaymentWindow = 15 * time.Minute
+
+const (
+	// defaultHorizon bounds a simulation when the caller does not set one.
+	defaultHorizon = 2 * time.Hour
+	// maxHorizon keeps a single request from simulating days of seconds.
+	maxHorizon = 48 * time.Hour
+)
+
+var ErrInvalidParams = errors.New("invalid simulation parameters")
+
+// Params describe an on-sale: the event's inventory configuration plus demand assumptions.
+type Params struct {
+	Capacity                 int     `json:"capacity"`
+	MaximumTicketsPerBooking int     `json:"maximum_tickets_per_booking"`
+	ArrivalRate              float64 `json:"arrival_rate"`        // booking attempts per second
+	TicketsPerBooking        float64 `json:"tickets_per_booking"` // mean tickets requested, capped at MaximumTicketsPerBooking
+	PaymentConversion        float64 `json:"payment_conversion"`  // share of pending bookings that pay within the window
+	PaymentWindowSeconds     int     `json:"payment_window_seconds"`
+	HorizonSeconds           int     `json:"horizon_seconds"`
+	Seed                     int64   `json:"seed"`
+}
+
+// Sample is the state at the end of one simulated minute.
+type Sample struct {
+	Minute          int `json:"minute"`
+	TokensRemaining int `json:"tokens_remaining"`
+	Pending         int `json:"pending"`
+	Sold            int `json:"sold"`
+	Waitlist        int `json:"waitlist"`
+	Attempts        int `json:"attempts"`
+	Rejected        int `json:"rejected"`
+}
+
+// Result summarizes a simulated on-sale.
+type Result struct {
+	Params Params `json:"params"`
+	// TokensExhaustedAfterSeconds is when the Redis bucket first ran too low for the smallest
+	// request; later arrivals are waitlisted even though expiring holds may still free seats.
+	TokensExhaustedAfterSeconds *int `json:"tokens_exhausted_after_seconds"`
+	// SoldOutAfterSeconds is when no seats were left to reserve and no holds were pending.
+	SoldOutAfterSeconds *int `json:"sold_out_after_seconds"`
+	TicketsSold         int  `json:"tickets_sold"`
+	Attempts            int  `json:"attempts"`
+	Waitlisted          int  `json:"waitlisted"`
+	Promoted            int  `json:"promoted"`
+	ExpiredHolds        int  `json:"expired_holds"`
+	PeakPending         int  `json:"peak_pending"`
+	PeakWaitlist        int  `json:"peak_waitlist"`
+	// PeakAttemptsPerSecond is the busiest second of reserve calls against the event's single token key.
+	PeakAttemptsPerSecond int `json:"peak_attempts_per_second"`
+	// RejectionRate is the share of reserve calls that found too few tokens.
+	RejectionRate float64  `json:"rejection_rate"`
+	Timeline      []Sample `json:"timeline"`
+}
+
+type hold struct {
+	tickets int
+	pays    bool
+	settle  int // second at which the hold is paid or expires
+}
+
+// Run simulates the on-sale second by second. Arrivals are Poisson with ArrivalRate;
+// a successful reserve becomes a pending hold that either pays at a random point in the
+// window or expires at its end. Like the finalize worker, an expired hold's seats go to
+// the head of the waitlist before they are returned to the bucket.
+func Run(p Params) (*Result, error) {
+	if err := normalize(&p); err != nil {
+		return nil, err
+	}
+	rng := rand.New(rand.NewSource(p.Seed))
+
+	res := &Result{Params: p}
+	tokens := p.Capacity
+	window := p.PaymentWindowSeconds
+	horizon := p.HorizonSeconds
+	settling := make(map[int][]hold)
+	pending := 0
+	waitlist := []int{} // tickets each waitlisted user asked for
+	rejected := 0
+	smallest := ticketsRequestedMin(p)
+
+	var minute Sample
+	newHold := func(now, tickets int) {
+		h := hold{tickets: tickets, pays: rng.Float64() < p.PaymentConversion, settle: now + window}
+		if h.pays {
+			h.settle = now + 1 + rng.Intn(window)
+		}
+		settling[h.settle] = append(settling[h.settle], h)
+		pending++
+	}
+
+	for now := 0; now < horizon; now++ {
+		for _, h := range settling[now] {
+			pending--
+			if h.pays {
+				res.TicketsSold += h.tickets
+				continue
+			}
+			res.ExpiredHolds++
+			if len(waitlist) > 0 {
+				waitlist = waitlist[1:]
+				res.Promoted++
+				newHold(now, h.tickets)
+				continue
+			}
+			tokens += h.tickets
+		}
+		delete(settling, now)
+
+		arrivals := poisson(rng, p.ArrivalRate)
+		if arrivals > res.PeakAttemptsPerSecond {
+			res.PeakAttemptsPerSecond = arrivals
+		}
+		for i := 0; i < arrivals; i++ {
+			res.Attempts++
+			minute.Attempts++
+			n := ticketsRequested(rng, p)
+			if tokens >= n {
+				tokens -= n
+				newHold(now, n)
+				continue
+			}
+			rejected++
+			minute.Rejected++
+			waitlist = append(waitlist, n)
+			res.Waitlisted++
+		}
+
+		elapsed := now + 1
+		if tokens < smallest && res.TokensExhaustedAfterSeconds == nil {
+			res.TokensExhaustedAfterSeconds = &elapsed
+		}
+		soldOut := tokens < smallest && pending == 0
+		if soldOut {
+			res.SoldOutAfterSeconds = &elapsed
+		}
+		if pending > res.PeakPending {
+			res.PeakPending = pending
+		}
+		if len(waitlist) > res.PeakWaitlist {
+			res.PeakWaitlist = len(waitlist)
+		}
+
+		if elapsed%60 == 0 || elapsed == horizon || soldOut {
+			minute.Minute = (elapsed + 59) / 60
+			minute.TokensRemaining = tokens
+			minute.Pending = pending
+			minute.Sold = res.TicketsSold
+			minute.Waitlist = len(waitlist)
+			res.Timeline = append(res.Timeline, minute)
+			minute = Sample{}
+		}
+
+		if soldOut {
+			break
+		}
+	}
+
+	if res.Attempts > 0 {
+		res.RejectionRate = float64(rejected) / float64(res.Attempts)
+	}
+	return res, nil
+}
+
+func normalize(p *Params) error {
+	if p.Capacity <= 0 || p.ArrivalRate <= 0 {
+		return ErrInvalidParams
+	}
+	if p.PaymentConversion < 0 || p.PaymentConversion > 1 {
+		return ErrInvalidParams
+	}
+	if p.MaximumTicketsPerBooking <= 0 {
+		p.MaximumTicketsPerBooking = 1
+	}
+	if p.TicketsPerBooking <= 0 {
+		p.TicketsPerBooking = 1
+	}
+	if p.TicketsPerBooking > float64(p.MaximumTicketsPerBooking) {
+		p.TicketsPerBooking = float64(p.MaximumTicketsPerBooking)
+	}
+	if p.PaymentWindowSeconds <= 0 {
+		p.PaymentWindowSeconds = int(DefaultPaymentWindow / time.Second)
+	}
+	if p.HorizonSeconds <= 0 {
+		p.HorizonSeconds = int(defaultHorizon / time.Second)
+	}
+	if p.HorizonSeconds > int(maxHorizon/time.Second) {
+		p.HorizonSeconds = int(maxHorizon / time.Second)
+	}
+	return nil
+}
+
+// ticketsRequested draws a ticket count in [1, MaximumTicketsPerBooking] with mean close to TicketsPerBooking.
+func ticketsRequested(rng *rand.Rand, p Params) int {
+	base := int(math.Floor(p.TicketsPerBooking))
+	n := base
+	if rng.Float64() < p.TicketsPerBooking-float64(base) {
+		n++
+	}
+	if n < 1 {
+		n = 1
+	}
+	if n > p.MaximumTicketsPerBooking {
+		n = p.MaximumTicketsPerBooking
+	}
+	return n
+}
+
+func ticketsRequestedMin(p Params) int {
+	if n := int(math.Floor(p.TicketsPerBooking)); n > 1 {
+		return n
+	}
+	return 1
+}
+
+// poisson draws from a Poisson distribution, using a normal approximation for large means.
+func poisson(rng *rand.Rand, mean float64) int {
+	if mean > 30 {
+		n := int(math.Round(mean + math.Sqrt(mean)*rng.NormFloat64()))
+		if n < 0 {
+			return 0
+		}
+		return n
+	}
+	l := math.Exp(-mean)
+	k := 0
+	for prod := rng.Float64(); prod > l; prod *= rng.Float64() {
+		k++
+	}
+	return k
+}
@@ -0,0 +1,228 @@
+package subscriptions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+
+	mailer "github.com/samirwankhede/lewly-pgpyewj/internal/service/mailer"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/events"
+	storeSubscriptions "github.com/samirwankhede/lewly-pgpyewj/internal/store/subscriptions"
+)
+
+const (
+	maxSubscriptionsPerUser = 50
+	maxTermLength           = 64
+	// Digests are looked for every digestCheck, claiming digestChunk users at a time
+	digestCheck = 5 * time.Minute
+	digestChunk = 100
+	digestLease = 5 * time.Minute
+)
+
+var (
+	ErrInvalidSubscription  = errors.New("kind must be category or tag, delivery instant or digest, and term 1-64 characters")
+	ErrTooManySubscriptions = errors.New("too many subscriptions")
+	ErrSubscriptionNotFound = errors.New("subscription not found")
+)
+
+// SubscriptionInput subscribes to a category or tag. Delivery defaults to digest.
+type SubscriptionInput struct {
+	Kind     string `json:"kind" binding:"required"`
+	Term     string `json:"term" binding:"required"`
+	Delivery string `json:"delivery"`
+}
+
+// SubscriptionsService lets users subscribe to event categories and tags and tells them about
+// newly published public events that match: instant subscribers through an email broadcast
+// as the event is published, the rest in a digest every digestEvery.
+type SubscriptionsService struct {
+	log         *zap.Logger
+	repo        *storeSubscriptions.SubscriptionsRepository
+	mailer      *mailer.MailerService
+	baseURL     string
+	digestEvery time.Duration
+}
+
+// NewSubscriptionsService returns the service. baseURL is the public API address event links
+// in emails point at.
+func NewSubscriptionsService(log *zap.Logger, repo *storeSubscriptions.SubscriptionsRepository, mailer *mailer.MailerService, baseURL string, digestEvery time.Duration) *SubscriptionsService {
+	return &SubscriptionsService{log: log, repo: repo, mailer: mailer, baseURL: strings.TrimRight(baseURL, "/"), digestEvery: digestEvery}
+}
+
+// Subscribe adds a subscription for the user, or changes the delivery of the one they have
+// for the same term.
+func (s *SubscriptionsService) Subscribe(ctx context.Context, userID string, in SubscriptionInput) (*storeSubscriptions.Subscription, error) {
+	term := strings.ToLower(strings.TrimSpace(in.Term))
+	delivery := in.Delivery
+	if delivery == "" {
+		delivery = storeSubscriptions.DeliveryDigest
+	}
+	if (in.Kind != storeSubscriptions.KindCategory && in.Kind != storeSubscriptions.KindTag) ||
+		(delivery != storeSubscriptions.DeliveryInstant && delivery != storeSubscriptions.DeliveryDigest) ||
+		term == "" || utf8.RuneCountInString(term) > maxTermLength {
+		return nil, ErrInvalidSubscription
+	}
+
+	n, err := s.repo.Count(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if n >= maxSubscriptionsPerUser {
+		// Changing the delivery of an existing subscription is still allowed
+		existing, err := s.repo.ListByUser(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for _, sub := range existing {
+			if sub.Kind == in.Kind && sub.Term == term {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, ErrTooManySubscriptions
+		}
+	}
+	return s.repo.Upsert(ctx, &storeSubscriptions.Subscription{UserID: userID, Kind: in.Kind, Term: term, Delivery: delivery})
+}
+
+func (s *SubscriptionsService) List(ctx context.Context, userID string) ([]*storeSubscriptions.Subscription, error) {
+	return s.repo.ListByUser(ctx, userID)
+}
+
+func (s *SubscriptionsService) Unsubscribe(ctx context.Context, userID, id string) error {
+	deleted, err := s.repo.Delete(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrSubscriptionNotFound
+	}
+	return nil
+}
+
+// MatchEvent is the publish hook: it matches a newly published public event against every
+// subscription, broadcasts the alert to instant subscribers and queues the event for the
+// others' digests. Errors are only logged.
+func (s *SubscriptionsService) MatchEvent(ctx context.Context, e *events.Event) {
+	if e.Visibility != events.VisibilityPublic {
+		return
+	}
+	matches, err := s.repo.MatchEvent(ctx, e.ID, e.Category, eventTags(e), e.Venue)
+	if err != nil {
+		s.log.Error("Failed to match event subscriptions", zap.Error(err), zap.String("event_id", e.ID))
+		return
+	}
+	var instant []string
+	for _, m := range matches {
+		if m.Delivery == storeSubscriptions.DeliveryInstant {
+			instant = append(instant, m.Email)
+		}
+	}
+	if len(instant) > 0 {
+		if _, err := s.mailer.BroadcastSubscriptionAlert(ctx, e.ID, e.Name, e.Venue, e.StartTime, s.eventLink(e.ID), instant); err != nil {
+			s.log.Error("Failed to send subscription alerts", zap.Error(err), zap.String("event_id", e.ID))
+		}
+	}
+	if len(matches) > 0 {
+		s.log.Info("Matched event subscriptions", zap.String("event_id", e.ID), zap.Int("instant", len(instant)), zap.Int("digest", len(matches)-len(instant)))
+	}
+}
+
+// eventTags returns the lower-cased "tags" of the event's metadata, if it has any.
+func eventTags(e *events.Event) []string {
+	var meta struct {
+		Tags []string `json:"tags"`
+	}
+	if len(e.Metadata) > 0 {
+		_ = json.Unmarshal(e.Metadata, &meta)
+	}
+	tags := make([]string, 0, len(meta.Tags))
+	for _, t := range meta.Tags {
+		if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+func (s *SubscriptionsService) eventLink(eventID string) string {
+	return s.baseURL + "/v1/events/" + url.PathEscape(eventID)
+}
+
+// RunDigests sends the digests that are due now and every few minutes after until ctx is
+// done. A user's digest is due once their oldest unsent match is digestEvery old, so nobody
+// gets more than about one digest per period. Every API instance runs it; claims keep them
+// from sending the same digest twice.
+func (s *SubscriptionsService) RunDigests(ctx context.Context) {
+	if s.digestEvery <= 0 {
+		return
+	}
+	ticker := time.NewTicker(digestCheck)
+	defer ticker.Stop()
+	for {
+		s.sendDigests(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *SubscriptionsService) sendDigests(ctx context.Context) {
+	for ctx.Err() == nil {
+		items, err := s.repo.ClaimDigests(ctx, time.Now().Add(-s.digestEvery), digestChunk, digestLease)
+		if err != nil {
+			s.log.Error("Failed to claim subscription digests", zap.Error(err))
+			return
+		}
+		if len(items) == 0 {
+			return
+		}
+
+		byUser := map[string][]*storeSubscriptions.DigestItem{}
+		var order []string
+		for _, it := range items {
+			if _, ok := byUser[it.UserID]; !ok {
+				order = append(order, it.UserID)
+			}
+			byUser[it.UserID] = append(byUser[it.UserID], it)
+		}
+		for _, userID := range order {
+			s.sendDigest(ctx, userID, byUser[userID])
+		}
+	}
+}
+
+// sendDigest emails one user's digest. Events cancelled or already started since they
+// matched are left out, and a digest left empty is only marked sent.
+func (s *SubscriptionsService) sendDigest(ctx context.Context, userID string, items []*storeSubscriptions.DigestItem) {
+	ids := make([]string, 0, len(items))
+	var list []mailer.DigestEvent
+	now := time.Now()
+	for _, it := range items {
+		ids = append(ids, it.EventID)
+		if it.EventStatus == "cancelled" || it.StartTime.Before(now) {
+			continue
+		}
+		list = append(list, mailer.DigestEvent{Name: it.EventName, Venue: it.Venue, StartTime: it.StartTime, Link: s.eventLink(it.EventID)})
+	}
+
+	if len(list) > 0 {
+		// On failure the claim is kept, so the digest is retried once its lease runs out
+		if err := s.mailer.SendSubscriptionDigestEmail(items[0].Email, list); err != nil {
+			return
+		}
+	}
+	if err := s.repo.DigestSent(ctx, userID, ids); err != nil {
+		s.log.Error("Failed to record subscription digest", zap.Error(err), zap.String("user_id", userID))
+	}
+}
@@ -0,0 +1,164 @@
+package waitlist
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/domain"
+	kafkax "github.com/samirwankhede/lewly-pgpyewj/internal/kafka"
+	redisx "github.com/samirwankhede/lewly-pgpyewj/internal/redis"
+	mailer "github.com/samirwankhede/lewly-pgpyewj/internal/service/mailer"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/events"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/users"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/waitlist"
+)
+
+// producerName identifies promotions in Kafka message envelopes.
+const producerName = "evently-waitlist"
+
+// Promoter is the single place waitlist users are promoted into freed seats, whether the
+// seats were freed by a cancellation or by a payment timeout.
+type Promoter struct {
+	log    *zap.Logger
+	repo   *waitlist.WaitlistRepository
+	events *events.EventsRepository
+	users  *users.UsersRepository
+	prod   *kafkax.Producer
+	mailer *mailer.MailerService
+	notify *redisx.BookingEvents
+}
+
+func NewPromoter(log *zap.Logger, repo *waitlist.WaitlistRepository, events *events.EventsRepository, users *users.UsersRepository, prod *kafkax.Producer, mailer *mailer.MailerService, notify *redisx.BookingEvents) *Promoter {
+	return &Promoter{log: log, repo: repo, events: events, users: users, prod: prod, mailer: mailer, notify: notify}
+}
+
+// Promote gives seats freed by sourceBookingID to the head of the event's waitlist. The new
+// pending booking goes through the normal finalize flow, so the worker sends the payment link
+// and schedules its timeout. Calling Promote again for the same source is a no-op that returns
+// the earlier promotion. It returns nil if nobody is waiting.
+func (p *Promoter) Promote(ctx context.Context, eventID, sourceBookingID string, seats domain.Seats) (*waitlist.Promotion, error) {
+	return p.promote(ctx, eventID, sourceBookingID, seats, p.repo.ClaimNext)
+}
+
+// PromoteSeats is Promote for some of sourceBookingID's seats, given up while it keeps the
+// rest; calling it again for the same seats returns the earlier promotion.
+func (p *Promoter) PromoteSeats(ctx context.Context, eventID, sourceBookingID string, seats domain.Seats) (*waitlist.Promotion, error) {
+	return p.promote(ctx, eventID, sourceBookingID, seats, p.repo.ClaimSeats)
+}
+
+func (p *Promoter) promote(ctx context.Context, eventID, sourceBookingID string, seats domain.Seats, claim func(ctx context.Context, eventID, sourceBookingID string, seats domain.Seats) (*waitlist.Promotion, error)) (*waitlist.Promotion, error) {
+	// Entries left from before the waitlist was turned off stay put; the seats go back on sale
+	event, err := p.events.Get(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	if event != nil && !event.WaitlistEnabled {
+		p.log.Info("Waitlist disabled, not promoting", zap.String("event_id", eventID))
+		return nil, nil
+	}
+	if event != nil && waitlistClosed(event) {
+		p.log.Info("Waitlist closed, not promoting", zap.String("event_id", eventID))
+		return nil, nil
+	}
+
+	promo, err := claim(ctx, eventID, sourceBookingID, seats)
+	if err != nil {
+		p.log.Error("Failed to claim waitlist entry", zap.Error(err), zap.String("event_id", eventID))
+		return nil, err
+	}
+	if promo == nil {
+		p.log.Info("No users in waitlist to promote", zap.String("event_id", eventID))
+		return nil, nil
+	}
+	if !promo.Claimed {
+		p.log.Info("Seats already promoted", zap.String("source_booking_id", sourceBookingID), zap.String("booking_id", promo.BookingID))
+		return promo, nil
+	}
+	if err := p.announce(ctx, event, eventID, promo, seats); err != nil {
+		return nil, err
+	}
+	p.log.Info("Promoted waitlist user",
+		zap.String("source_booking_id", sourceBookingID),
+		zap.String("booking_id", promo.BookingID),
+		zap.String("user_id", promo.UserID),
+		zap.Int("position", promo.Position))
+	return promo, nil
+}
+
+// OfferCapacity offers seats, added to the event by capacity increase increaseID, to its
+// waitlist: one seat each, in waitlist order, as pending bookings that go through the normal
+// finalize flow. Seats already offered by an earlier call for the same increase count as
+// offered again. It returns the promotions and the seats nobody was waiting for, which are
+// all of them when the waitlist is off.
+func (p *Promoter) OfferCapacity(ctx context.Context, eventID, increaseID string, seats domain.Seats) ([]*waitlist.Promotion, domain.Seats, error) {
+	event, err := p.events.Get(ctx, eventID)
+	if err != nil {
+		return nil, seats, err
+	}
+	if event != nil && (!event.WaitlistEnabled || waitlistClosed(event)) {
+		return nil, seats, nil
+	}
+
+	promos := []*waitlist.Promotion{}
+	for i, seat := range seats {
+		promo, err := p.repo.ClaimCapacityOffer(ctx, eventID, increaseID, seat)
+		if err != nil {
+			p.log.Error("Failed to claim waitlist entry", zap.Error(err), zap.String("event_id", eventID))
+			return promos, seats[i:], err
+		}
+		if promo == nil {
+			return promos, seats[i:], nil
+		}
+		promos = append(promos, promo)
+		if promo.Claimed {
+			if err := p.announce(ctx, event, eventID, promo, domain.Seats{seat}); err != nil {
+				p.log.Error("Failed to announce waitlist offer", zap.Error(err), zap.String("booking_id", promo.BookingID))
+			}
+		}
+	}
+	if len(promos) > 0 {
+		p.log.Info("Offered new seats to waitlist", zap.String("event_id", eventID), zap.String("increase_id", increaseID), zap.Int("offers", len(promos)))
+	}
+	return promos, nil, nil
+}
+
+// waitlistClosed reports whether the event has started or is over, so its waitlist takes
+// no more promotions. The claim checks again in Postgres, along with the checker's
+// closure, so this only saves the round trip.
+func waitlistClosed(event *events.Event) bool {
+	return event.Status.Over() || !event.StartTime.After(time.Now())
+}
+
+// announce sends a claimed promotion's booking to the finalizer and tells the user.
+func (p *Promoter) announce(ctx context.Context, event *events.Event, eventID string, promo *waitlist.Promotion, seats domain.Seats) error {
+	payload := map[string]any{
+		"booking_id": promo.BookingID,
+		"event_id":   eventID,
+		"user_id":    promo.UserID,
+		"seats":      seats,
+	}
+	env, err := kafkax.NewEnvelope(kafkax.TypeFinalizeBooking, producerName, payload)
+	if err != nil {
+		return err
+	}
+	if err := p.prod.PublishEnvelope(ctx, []byte(eventID), env); err != nil {
+		// The booking exists; `evctl bookings finalize` can requeue it
+		p.log.Error("Failed to publish promoted booking", zap.Error(err), zap.String("booking_id", promo.BookingID))
+	}
+
+	if p.notify != nil {
+		e := redisx.BookingEvent{Type: redisx.BookingEventWaitlistPromoted, BookingID: promo.BookingID, Status: domain.BookingPending}
+		if err := p.notify.Publish(ctx, e); err != nil {
+			p.log.Error("Failed to publish booking event", zap.Error(err), zap.String("booking_id", promo.BookingID))
+		}
+	}
+
+	if p.mailer != nil && event != nil {
+		if user, err := p.users.GetByID(ctx, promo.UserID); err == nil && user != nil {
+			_ = p.mailer.For(event.OrganizerID).About(promo.BookingID).SendWaitlistPromotionEmail(user.Email, event.Name)
+		}
+	}
+	return nil
+}
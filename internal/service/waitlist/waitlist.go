@@ -0,0 +1,75 @@
+package waitlist
+
+import (
+	"context"
+	"errors"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/clock"
+	eventsService "github.com/samirwankhede/lewly-pgpyewj/internal/service/events"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/events"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/invitations"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/waitlist"
+)
+
+var (
+	ErrEventNotFound      = errors.New("event not found")
+	ErrWaitlistDisabled   = errors.New("waitlist is disabled for this event")
+	ErrInvitationRequired = errors.New("this event is invitation only")
+	ErrSandboxTesterOnly  = errors.New("this is a sandbox event; only its testers can join its waitlist")
+	ErrWaitlistClosed     = errors.New("waitlist closed: the event has started")
+)
+
+// WaitlistService lets users join an event's waitlist by hand. Bookings that find an event
+// sold out join it themselves through BookingsService.
+type WaitlistService struct {
+	repo    *waitlist.WaitlistRepository
+	events  *events.EventsRepository
+	invites *invitations.InvitationsRepository
+	clock   clock.Clock
+}
+
+func NewWaitlistService(repo *waitlist.WaitlistRepository, events *events.EventsRepository, invites *invitations.InvitationsRepository) *WaitlistService {
+	return &WaitlistService{repo: repo, events: events, invites: invites, clock: clock.Real{}}
+}
+
+// Join adds the user to the event's waitlist and returns their position. Events that have
+// started or ended, or have their waitlist off, can't be joined; private events need a
+// redeemed invitation.
+func (s *WaitlistService) Join(ctx context.Context, eventID, userID string) (int, error) {
+	event, err := s.events.Get(ctx, eventID)
+	if err != nil {
+		return 0, err
+	}
+	if event == nil {
+		return 0, ErrEventNotFound
+	}
+	if err := eventsService.RequireOpen(event, s.clock.Now()); err != nil {
+		return 0, err
+	}
+	if !event.WaitlistEnabled {
+		return 0, ErrWaitlistDisabled
+	}
+	if event.Sandbox {
+		ok, err := s.events.CanRehearse(ctx, eventID, userID)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			return 0, ErrSandboxTesterOnly
+		}
+	}
+	if event.Visibility == events.VisibilityPrivate {
+		invited, err := s.invites.HasRedeemed(ctx, eventID, userID)
+		if err != nil {
+			return 0, err
+		}
+		if !invited {
+			return 0, ErrInvitationRequired
+		}
+	}
+	pos, err := s.repo.Add(ctx, eventID, userID)
+	if err == waitlist.ErrClosed {
+		return 0, ErrWaitlistClosed
+	}
+	return pos, err
+}
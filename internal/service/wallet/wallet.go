@@ -0,0 +1,208 @@
+package wallet
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/domain"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/service/checkin"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/bookings"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/events"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/users"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/wallet"
+)
+
+// Pass formats.
+const (
+	FormatApple  = "apple"
+	FormatGoogle = "google"
+)
+
+// linkGrace keeps emailed links working for a day after the event ends, for stragglers
+// adding their pass on the way home.
+const linkGrace = 24 * time.Hour
+
+var (
+	ErrUnknownFormat   = errors.New("format must be apple or google")
+	ErrFormatDisabled  = errors.New("passes in this format aren't configured")
+	ErrBookingNotFound = errors.New("booking not found")
+	ErrNotFinalized    = errors.New("booking is not confirmed")
+	ErrInvalidLink     = errors.New("invalid or expired wallet link")
+)
+
+// WalletService turns confirmed bookings into wallet passes. Passes are fetched by the
+// booking's holder, or by anyone with a signed link from their confirmation email.
+type WalletService struct {
+	log      *zap.Logger
+	bookings *bookings.BookingsRepository
+	events   *events.EventsRepository
+	users    *users.UsersRepository
+	baseURL  string
+	linkKey  []byte
+	// apple and google are nil unless that format is configured
+	apple  *wallet.ApplePasses
+	google *wallet.GooglePasses
+}
+
+func NewWalletService(log *zap.Logger, bookings *bookings.BookingsRepository, events *events.EventsRepository, users *users.UsersRepository, baseURL, linkSecret string) *WalletService {
+	return &WalletService{log: log, bookings: bookings, events: events, users: users, baseURL: strings.TrimRight(baseURL, "/"), linkKey: []byte(linkSecret)}
+}
+
+// WithApple offers Apple Wallet passes.
+func (s *WalletService) WithApple(p *wallet.ApplePasses) *WalletService {
+	s.apple = p
+	return s
+}
+
+// WithGoogle offers Google Wallet passes.
+func (s *WalletService) WithGoogle(p *wallet.GooglePasses) *WalletService {
+	s.google = p
+	return s
+}
+
+// Formats lists the configured pass formats.
+func (s *WalletService) Formats() []string {
+	var out []string
+	if s.apple != nil {
+		out = append(out, FormatApple)
+	}
+	if s.google != nil {
+		out = append(out, FormatGoogle)
+	}
+	return out
+}
+
+func (s *WalletService) enabled(format string) error {
+	switch format {
+	case FormatApple:
+		if s.apple == nil {
+			return ErrFormatDisabled
+		}
+	case FormatGoogle:
+		if s.google == nil {
+			return ErrFormatDisabled
+		}
+	default:
+		return ErrUnknownFormat
+	}
+	return nil
+}
+
+// Link is the signature of a link made by Links, which stands in for signing in.
+type Link struct {
+	Expires string
+	Sig     string
+}
+
+// ticket loads what the booking's pass in format shows. The request must come from the
+// booking's holder, userID, or carry a link signed for them.
+func (s *WalletService) ticket(ctx context.Context, bookingID, format, userID string, link *Link) (*wallet.Ticket, error) {
+	b, err := s.bookings.GetByID(ctx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	if link != nil {
+		// A link is signed for the holder it was sent to, so it stops working once the
+		// booking is transferred
+		if b == nil || !s.validLink(b, format, link) {
+			return nil, ErrInvalidLink
+		}
+	} else if b == nil || b.UserID != userID {
+		return nil, ErrBookingNotFound
+	}
+	if b.Status != domain.BookingBooked {
+		return nil, ErrNotFinalized
+	}
+	e, err := s.events.Get(ctx, b.EventID)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, ErrBookingNotFound
+	}
+	holder, err := s.users.GetByID(ctx, b.UserID)
+	if err != nil {
+		return nil, err
+	}
+	t := &wallet.Ticket{
+		Serial:    b.ID,
+		Barcode:   checkin.Barcode(b.ID),
+		EventID:   e.ID,
+		EventName: e.Name,
+		Venue:     e.Venue,
+		StartTime: e.StartTime,
+		EndTime:   e.EndTime,
+		Seats:     b.Seats,
+		Latitude:  e.Latitude,
+		Longitude: e.Longitude,
+	}
+	if holder != nil {
+		t.HolderName = holder.Name
+	}
+	return t, nil
+}
+
+// ApplePass returns the booking's .pkpass bundle for its holder, userID, or whoever has
+// link.
+func (s *WalletService) ApplePass(ctx context.Context, bookingID, userID string, link *Link) ([]byte, error) {
+	if err := s.enabled(FormatApple); err != nil {
+		return nil, err
+	}
+	t, err := s.ticket(ctx, bookingID, FormatApple, userID, link)
+	if err != nil {
+		return nil, err
+	}
+	return s.apple.Pass(t)
+}
+
+// GoogleSaveURL returns the link adding the booking's pass to Google Wallet, for its
+// holder, userID, or whoever has link.
+func (s *WalletService) GoogleSaveURL(ctx context.Context, bookingID, userID string, link *Link) (string, error) {
+	if err := s.enabled(FormatGoogle); err != nil {
+		return "", err
+	}
+	t, err := s.ticket(ctx, bookingID, FormatGoogle, userID, link)
+	if err != nil {
+		return "", err
+	}
+	return s.google.SaveURL(t)
+}
+
+// Links returns a signed download link for each configured format, valid for the booking's
+// current holder until a day after the event ends. They are meant for the booking's
+// confirmation email.
+func (s *WalletService) Links(b *bookings.Booking, e *events.Event) map[string]string {
+	expires := strconv.FormatInt(e.EndTime.Add(linkGrace).Unix(), 10)
+	links := make(map[string]string)
+	for _, format := range s.Formats() {
+		q := url.Values{}
+		q.Set("format", format)
+		q.Set("expires", expires)
+		q.Set("sig", s.sign(b, format, expires))
+		links[format] = s.baseURL + "/v1/bookings/" + url.PathEscape(b.ID) + "/wallet?" + q.Encode()
+	}
+	return links
+}
+
+func (s *WalletService) validLink(b *bookings.Booking, format string, link *Link) bool {
+	exp, err := strconv.ParseInt(link.Expires, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return false
+	}
+	return hmac.Equal([]byte(link.Sig), []byte(s.sign(b, format, link.Expires)))
+}
+
+func (s *WalletService) sign(b *bookings.Booking, format, expires string) string {
+	mac := hmac.New(sha256.New, s.linkKey)
+	mac.Write([]byte("wallet\n" + b.ID + "\n" + b.UserID + "\n" + format + "\n" + expires))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,56 @@
+package webhooks
+
+import "testing"
+
+func TestRefuseLocal(t *testing.T) {
+	tests := []struct {
+		address string
+		refused bool
+	}{
+		{"93.184.216.34:443", false},
+		{"8.8.8.8:443", false},
+		{"[2606:4700::1111]:443", false},
+		{"[64:ff9b::808:808]:443", false}, // NAT64 of 8.8.8.8
+
+		{"127.0.0.1:443", true},
+		{"10.1.2.3:443", true},
+		{"172.16.0.1:443", true},
+		{"192.168.1.1:443", true},
+		{"169.254.169.254:80", true},
+		{"100.64.0.1:443", true},
+		{"100.127.255.254:443", true},
+		{"192.0.0.8:443", true},
+		{"198.18.0.1:443", true},
+		{"198.19.255.255:443", true},
+		{"240.0.0.1:443", true},
+		{"255.255.255.255:443", true},
+		{"0.0.0.0:443", true},
+		{"0.1.2.3:443", true},
+		{"224.0.0.1:443", true},
+		{"192.0.2.1:443", true},
+
+		{"[::1]:443", true},
+		{"[::]:443", true},
+		{"[::ffff:127.0.0.1]:443", true},
+		{"[::ffff:10.0.0.1]:443", true},
+		{"[::ffff:100.64.0.1]:443", true},
+		{"[64:ff9b::a00:1]:443", true},     // NAT64 of 10.0.0.1
+		{"[64:ff9b::a9fe:a9fe]:443", true}, // NAT64 of 169.254.169.254
+		{"[64:ff9b:1::1]:443", true},
+		{"[2002:a00:1::1]:443", true}, // 6to4 of 10.0.0.1
+		{"[fd00::1]:443", true},
+		{"[fe80::1%eth0]:443", true},
+		{"[ff02::1]:443", true},
+		{"[2001:db8::1]:443", true},
+
+		{"not-an-ip:443", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			err := refuseLocal("tcp", tt.address, nil)
+			if refused := err != nil; refused != tt.refused {
+				t.Errorf("refuseLocal(%s) = %v, want refused %v", tt.address, err, tt.refused)
+			}
+		})
+	}
+}
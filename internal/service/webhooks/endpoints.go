@@ -0,0 +1,220 @@
+package webhooks
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/metrics"
+	redisx "github.com/samirwankhede/lewly-pgpyewj/internal/redis"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/events"
+	storeWebhooks "github.com/samirwankhede/lewly-pgpyewj/internal/store/webhooks"
+)
+
+// Event types integration endpoints can subscribe to.
+const (
+	EventBookingCreated   = "booking.created"
+	EventBookingFinalized = "booking.finalized"
+	EventBookingCancelled = "booking.cancelled"
+	EventEventCancelled   = "event.cancelled"
+)
+
+// EndpointEventTypes are the changes an integration endpoint can subscribe to.
+var EndpointEventTypes = []string{EventBookingCreated, EventBookingFinalized, EventBookingCancelled, EventEventCancelled}
+
+// endpointBookingTypes maps the booking transitions announced on the publisher to the
+// endpoint event types they are delivered as. Bookings made directly are announced by
+// BookingCreated instead, when they are made; the publisher only hears of them later, once
+// the worker has asked for payment.
+var endpointBookingTypes = map[string]string{
+	redisx.BookingEventWaitlistPromoted: EventBookingCreated,
+	redisx.BookingEventPaymentReceived:  EventBookingFinalized,
+	redisx.BookingEventCancelled:        EventBookingCancelled,
+}
+
+// EndpointInput registers an integration endpoint. An empty EventTypes subscribes to every
+// type.
+type EndpointInput struct {
+	URL         string   `json:"url" binding:"required"`
+	Description string   `json:"description"`
+	EventTypes  []string `json:"event_types"`
+}
+
+// CreatedEndpoint is a new endpoint with its signing secret, which is never shown again.
+type CreatedEndpoint struct {
+	*storeWebhooks.Endpoint
+	Secret string `json:"secret"`
+}
+
+// RegisterEndpoint adds an integration endpoint on behalf of the admin adminID, empty for
+// API keys.
+func (s *WebhooksService) RegisterEndpoint(ctx context.Context, adminID string, in EndpointInput) (*CreatedEndpoint, error) {
+	u, err := url.Parse(in.URL)
+	if err != nil || u.Host == "" || u.User != nil || (u.Scheme != "https" && !(s.allowLocal && u.Scheme == "http")) {
+		return nil, ErrInvalidWebhook
+	}
+	types := []string{}
+	seen := map[string]bool{}
+	for _, t := range in.EventTypes {
+		if !validEndpointEventType(t) {
+			return nil, ErrInvalidWebhook
+		}
+		if !seen[t] {
+			seen[t] = true
+			types = append(types, t)
+		}
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+	endpoint := &storeWebhooks.Endpoint{URL: u.String(), Secret: secret, Description: strings.TrimSpace(in.Description), EventTypes: types}
+	if adminID != "" {
+		endpoint.CreatedBy = &adminID
+	}
+	e, err := s.repo.CreateEndpoint(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return &CreatedEndpoint{Endpoint: e, Secret: secret}, nil
+}
+
+func validEndpointEventType(t string) bool {
+	for _, known := range EndpointEventTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *WebhooksService) ListEndpoints(ctx context.Context) ([]*storeWebhooks.Endpoint, error) {
+	return s.repo.ListEndpoints(ctx)
+}
+
+func (s *WebhooksService) DeleteEndpoint(ctx context.Context, id string) error {
+	deleted, err := s.repo.DeleteEndpoint(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// EnableEndpoint switches an endpoint that was disabled after repeated failures back on.
+func (s *WebhooksService) EnableEndpoint(ctx context.Context, id string) (*storeWebhooks.Endpoint, error) {
+	e, err := s.repo.EnableEndpoint(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, ErrWebhookNotFound
+	}
+	return e, nil
+}
+
+// EndpointDeliveries returns the endpoint's delivery log, newest first, up to 100.
+func (s *WebhooksService) EndpointDeliveries(ctx context.Context, id string, limit int) ([]*storeWebhooks.EndpointDelivery, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+	e, err := s.repo.GetEndpoint(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, ErrWebhookNotFound
+	}
+	return s.repo.ListEndpointDeliveries(ctx, id, limit)
+}
+
+// BookingCreated queues booking.created for a booking a user has just made. It is registered
+// as a hook on the bookings service; errors are only logged.
+func (s *WebhooksService) BookingCreated(ctx context.Context, bookingID string) {
+	s.enqueueBooking(ctx, bookingID, EventBookingCreated, time.Now())
+}
+
+// EventCancelled queues event.cancelled. It is registered as a hook on the admin service;
+// errors are only logged. The event's bookings are cancelled with it without a
+// booking.cancelled each.
+func (s *WebhooksService) EventCancelled(ctx context.Context, e *events.Event) {
+	n, err := s.repo.EnqueueEventForEndpoints(ctx, e.ID, EventEventCancelled, time.Now())
+	if err != nil {
+		s.log.Error("Failed to queue integration webhooks", zap.Error(err), zap.String("event_id", e.ID), zap.String("type", EventEventCancelled))
+		return
+	}
+	if n > 0 {
+		s.log.Debug("Queued integration webhooks", zap.String("event_id", e.ID), zap.String("type", EventEventCancelled), zap.Int("deliveries", n))
+	}
+}
+
+func (s *WebhooksService) enqueueBooking(ctx context.Context, bookingID, eventType string, at time.Time) {
+	n, err := s.repo.EnqueueBookingForEndpoints(ctx, bookingID, eventType, at)
+	if err != nil {
+		s.log.Error("Failed to queue integration webhooks", zap.Error(err), zap.String("booking_id", bookingID), zap.String("type", eventType))
+		return
+	}
+	if n > 0 {
+		s.log.Debug("Queued integration webhooks", zap.String("booking_id", bookingID), zap.String("type", eventType), zap.Int("deliveries", n))
+	}
+}
+
+// dispatchEndpoints sends claimed chunks of endpoint deliveries until nothing is due.
+func (s *WebhooksService) dispatchEndpoints(ctx context.Context) {
+	for ctx.Err() == nil {
+		claimed, err := s.repo.ClaimDueEndpoints(ctx, deliveryChunk, deliveryLease)
+		if err != nil {
+			s.log.Error("Failed to claim integration webhook deliveries", zap.Error(err))
+			return
+		}
+		if len(claimed) == 0 {
+			return
+		}
+		var wg sync.WaitGroup
+		for _, d := range claimed {
+			wg.Add(1)
+			go func(d *storeWebhooks.EndpointDelivery) {
+				defer wg.Done()
+				s.deliverEndpoint(ctx, d)
+			}(d)
+		}
+		wg.Wait()
+	}
+}
+
+// deliverEndpoint is deliver for endpoint deliveries, with the same retries and backoff.
+func (s *WebhooksService) deliverEndpoint(ctx context.Context, d *storeWebhooks.EndpointDelivery) {
+	status, err := s.post(ctx, d.ID, d.URL, d.Secret, d.Payload)
+	if err == nil {
+		metrics.WebhookEndpointDeliveriesTotal.WithLabelValues("delivered").Inc()
+		if err := s.repo.EndpointDelivered(ctx, d, *status); err != nil {
+			s.log.Error("Failed to record integration webhook delivery", zap.Error(err), zap.String("delivery_id", d.ID))
+		}
+		return
+	}
+
+	if d.Attempts < maxAttempts {
+		metrics.WebhookEndpointDeliveriesTotal.WithLabelValues("retried").Inc()
+		if rerr := s.repo.RetryEndpoint(ctx, d.ID, status, err.Error(), time.Now().Add(backoff(d.Attempts))); rerr != nil {
+			s.log.Error("Failed to reschedule integration webhook delivery", zap.Error(rerr), zap.String("delivery_id", d.ID))
+		}
+		return
+	}
+	metrics.WebhookEndpointDeliveriesTotal.WithLabelValues("failed").Inc()
+	disabled, ferr := s.repo.EndpointFailed(ctx, d, status, err.Error(), disableAfter)
+	if ferr != nil {
+		s.log.Error("Failed to record failed integration webhook delivery", zap.Error(ferr), zap.String("delivery_id", d.ID))
+		return
+	}
+	s.log.Warn("Integration webhook delivery failed", zap.Error(err), zap.String("delivery_id", d.ID), zap.String("endpoint_id", d.EndpointID))
+	if disabled {
+		s.log.Warn("Integration webhook endpoint disabled after repeated failures", zap.String("endpoint_id", d.EndpointID))
+	}
+}
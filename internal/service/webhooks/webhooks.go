@@ -0,0 +1,397 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/metrics"
+	jwtMiddleware "github.com/samirwankhede/lewly-pgpyewj/internal/middleware"
+	redisx "github.com/samirwankhede/lewly-pgpyewj/internal/redis"
+	storeWebhooks "github.com/samirwankhede/lewly-pgpyewj/internal/store/webhooks"
+)
+
+const (
+	maxWebhooksPerUser = 10
+	deliveryChunk      = 50
+	deliveryLease      = 2 * time.Minute
+	deliveryTimeout    = 10 * time.Second
+	// A delivery is tried maxAttempts times, backing off from retryBase up to retryMax
+	maxAttempts = 8
+	retryBase   = 30 * time.Second
+	retryMax    = 6 * time.Hour
+	// disableAfter deliveries failing for good in a row switch the webhook off
+	disableAfter = 5
+)
+
+// DeliveryIDHeader carries the delivery's ID, the same on every retry, so receivers can
+// drop duplicates.
+const DeliveryIDHeader = "Webhook-Id"
+
+var (
+	ErrInvalidWebhook   = errors.New("invalid webhook")
+	ErrTooManyWebhooks  = errors.New("too many webhooks")
+	ErrWebhookNotFound  = errors.New("webhook not found")
+	errForbiddenAddress = errors.New("webhook address is not public")
+)
+
+// EventTypes are the booking status changes a webhook can subscribe to, named
+// "booking.<transition>".
+var EventTypes = []string{
+	"booking." + redisx.BookingEventPaymentRequested,
+	"booking." + redisx.BookingEventPaymentReceived,
+	"booking." + redisx.BookingEventPaymentExtended,
+	"booking." + redisx.BookingEventExpired,
+	"booking." + redisx.BookingEventCancelled,
+	"booking." + redisx.BookingEventWaitlistPromoted,
+	"booking." + redisx.BookingEventPaymentDelayed,
+}
+
+// WebhookInput registers a webhook. An empty EventTypes subscribes to every type.
+type WebhookInput struct {
+	URL        string   `json:"url" binding:"required"`
+	EventTypes []string `json:"event_types"`
+}
+
+// CreatedWebhook is a new webhook with its signing secret, which is never shown again.
+type CreatedWebhook struct {
+	*storeWebhooks.Webhook
+	Secret string `json:"secret"`
+}
+
+// WebhooksService lets users register personal webhooks for their own bookings' status
+// changes and delivers them. Changes are queued in Postgres as they are announced and sent
+// by Run, signed like milestone webhooks but with each webhook's own secret.
+type WebhooksService struct {
+	log        *zap.Logger
+	repo       *storeWebhooks.WebhooksRepository
+	http       *http.Client
+	allowLocal bool
+}
+
+// NewWebhooksService returns the service. Unless allowLocal is set, webhook URLs must be
+// https and deliveries refuse to connect to any address that isn't globally reachable, such
+// as loopback, private, link-local and carrier-grade NAT ones, since any user can register
+// one.
+func NewWebhooksService(log *zap.Logger, repo *storeWebhooks.WebhooksRepository, allowLocal bool) *WebhooksService {
+	dialer := &net.Dialer{Timeout: deliveryTimeout}
+	if !allowLocal {
+		dialer.Control = refuseLocal
+	}
+	client := &http.Client{
+		Timeout:   deliveryTimeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext, Proxy: nil},
+		// A redirect could point anywhere; receivers have to answer at the registered URL
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	return &WebhooksService{log: log, repo: repo, http: client, allowLocal: allowLocal}
+}
+
+// deniedPrefixes are the ranges deliveries may not connect to: every range the IANA
+// special-purpose address registries don't mark globally reachable, and multicast. Many of
+// them, such as carrier-grade NAT, route to internal services on cloud hosts.
+var deniedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // this network
+	netip.MustParsePrefix("10.0.0.0/8"),      // private
+	netip.MustParsePrefix("100.64.0.0/10"),   // carrier-grade NAT
+	netip.MustParsePrefix("127.0.0.0/8"),     // loopback
+	netip.MustParsePrefix("169.254.0.0/16"),  // link-local, including cloud metadata
+	netip.MustParsePrefix("172.16.0.0/12"),   // private
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // documentation
+	netip.MustParsePrefix("192.88.99.0/24"),  // 6to4 relay anycast
+	netip.MustParsePrefix("192.168.0.0/16"),  // private
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // documentation
+	netip.MustParsePrefix("224.0.0.0/4"),     // multicast
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved, and broadcast
+	netip.MustParsePrefix("::/96"),           // unspecified, loopback and IPv4-compatible
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // local-use NAT64
+	netip.MustParsePrefix("100::/64"),        // discard
+	netip.MustParsePrefix("2001::/23"),       // IETF protocol assignments, including Teredo
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+	netip.MustParsePrefix("2002::/16"),       // 6to4, which embeds any IPv4 address
+	netip.MustParsePrefix("fc00::/7"),        // unique local
+	netip.MustParsePrefix("fe80::/10"),       // link-local
+	netip.MustParsePrefix("fec0::/10"),       // site-local
+	netip.MustParsePrefix("ff00::/8"),        // multicast
+}
+
+// nat64 is the well-known NAT64 prefix; its addresses reach the IPv4 address in their last
+// four bytes.
+var nat64 = netip.MustParsePrefix("64:ff9b::/96")
+
+// deniedAddress reports whether deliveries may not connect to addr. IPv4 addresses mapped
+// into IPv6 or behind NAT64 are judged by the IPv4 address they reach.
+func deniedAddress(addr netip.Addr) bool {
+	addr = addr.WithZone("").Unmap()
+	if nat64.Contains(addr) {
+		b := addr.As16()
+		addr = netip.AddrFrom4([4]byte(b[12:]))
+	}
+	for _, p := range deniedPrefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// refuseLocal runs after DNS resolution, so a public name resolving to a denied address is
+// refused too.
+func refuseLocal(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || deniedAddress(addr) {
+		return errForbiddenAddress
+	}
+	return nil
+}
+
+// Register adds a webhook for the user.
+func (s *WebhooksService) Register(ctx context.Context, userID string, in WebhookInput) (*CreatedWebhook, error) {
+	u, err := url.Parse(in.URL)
+	if err != nil || u.Host == "" || u.User != nil || (u.Scheme != "https" && !(s.allowLocal && u.Scheme == "http")) {
+		return nil, ErrInvalidWebhook
+	}
+	types := []string{}
+	seen := map[string]bool{}
+	for _, t := range in.EventTypes {
+		if !validEventType(t) {
+			return nil, ErrInvalidWebhook
+		}
+		if !seen[t] {
+			seen[t] = true
+			types = append(types, t)
+		}
+	}
+
+	existing, err := s.repo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= maxWebhooksPerUser {
+		return nil, ErrTooManyWebhooks
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+	w, err := s.repo.Create(ctx, &storeWebhooks.Webhook{UserID: userID, URL: u.String(), Secret: secret, EventTypes: types})
+	if err != nil {
+		return nil, err
+	}
+	return &CreatedWebhook{Webhook: w, Secret: secret}, nil
+}
+
+func validEventType(t string) bool {
+	for _, known := range EventTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+func (s *WebhooksService) List(ctx context.Context, userID string) ([]*storeWebhooks.Webhook, error) {
+	return s.repo.ListByUser(ctx, userID)
+}
+
+func (s *WebhooksService) Delete(ctx context.Context, userID, id string) error {
+	deleted, err := s.repo.Delete(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// Enable switches a webhook that was disabled after repeated failures back on.
+func (s *WebhooksService) Enable(ctx context.Context, userID, id string) (*storeWebhooks.Webhook, error) {
+	w, err := s.repo.Enable(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if w == nil {
+		return nil, ErrWebhookNotFound
+	}
+	return w, nil
+}
+
+// Deliveries returns the webhook's most recent deliveries, newest first, up to 100.
+func (s *WebhooksService) Deliveries(ctx context.Context, userID, id string, limit int) ([]*storeWebhooks.Delivery, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+	w, err := s.repo.Get(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if w == nil {
+		return nil, ErrWebhookNotFound
+	}
+	return s.repo.ListDeliveries(ctx, id, limit)
+}
+
+// Enqueue queues e for the booking owner's webhooks and, for the transitions integrations
+// hear about, for the integration endpoints. It is registered as a listener on the booking
+// event publisher, so every process that announces transitions queues them; errors are only
+// logged.
+func (s *WebhooksService) Enqueue(ctx context.Context, e redisx.BookingEvent) {
+	if t, ok := endpointBookingTypes[e.Type]; ok {
+		s.enqueueBooking(ctx, e.BookingID, t, e.At)
+	}
+	n, err := s.repo.Enqueue(ctx, e.BookingID, "booking."+e.Type, string(e.Status), e.At, e.ExpiresAt)
+	if err != nil {
+		s.log.Error("Failed to queue booking webhooks", zap.Error(err), zap.String("booking_id", e.BookingID), zap.String("type", e.Type))
+		return
+	}
+	if n > 0 {
+		s.log.Debug("Queued booking webhooks", zap.String("booking_id", e.BookingID), zap.String("type", e.Type), zap.Int("deliveries", n))
+	}
+}
+
+// Run sends due deliveries, personal and integration, now and every interval after until ctx
+// is done. Every API instance runs it; claims keep them from sending the same delivery twice.
+func (s *WebhooksService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.dispatch(ctx)
+		s.dispatchEndpoints(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatch sends claimed chunks until nothing is due.
+func (s *WebhooksService) dispatch(ctx context.Context) {
+	for ctx.Err() == nil {
+		claimed, err := s.repo.ClaimDue(ctx, deliveryChunk, deliveryLease)
+		if err != nil {
+			s.log.Error("Failed to claim webhook deliveries", zap.Error(err))
+			return
+		}
+		if len(claimed) == 0 {
+			return
+		}
+		var wg sync.WaitGroup
+		for _, d := range claimed {
+			wg.Add(1)
+			go func(d *storeWebhooks.Delivery) {
+				defer wg.Done()
+				s.deliver(ctx, d)
+			}(d)
+		}
+		wg.Wait()
+	}
+}
+
+// deliver sends one claimed delivery and records the outcome: any 2xx is delivered, anything
+// else is retried with backoff until the attempts run out.
+func (s *WebhooksService) deliver(ctx context.Context, d *storeWebhooks.Delivery) {
+	status, err := s.post(ctx, d.ID, d.URL, d.Secret, d.Payload)
+	if err == nil {
+		metrics.UserWebhookDeliveriesTotal.WithLabelValues("delivered").Inc()
+		if err := s.repo.Delivered(ctx, d, *status); err != nil {
+			s.log.Error("Failed to record webhook delivery", zap.Error(err), zap.String("delivery_id", d.ID))
+		}
+		return
+	}
+
+	if d.Attempts < maxAttempts {
+		metrics.UserWebhookDeliveriesTotal.WithLabelValues("retried").Inc()
+		if rerr := s.repo.Retry(ctx, d.ID, status, err.Error(), time.Now().Add(backoff(d.Attempts))); rerr != nil {
+			s.log.Error("Failed to reschedule webhook delivery", zap.Error(rerr), zap.String("delivery_id", d.ID))
+		}
+		return
+	}
+	metrics.UserWebhookDeliveriesTotal.WithLabelValues("failed").Inc()
+	disabled, ferr := s.repo.Failed(ctx, d, status, err.Error(), disableAfter)
+	if ferr != nil {
+		s.log.Error("Failed to record failed webhook delivery", zap.Error(ferr), zap.String("delivery_id", d.ID))
+		return
+	}
+	s.log.Warn("Webhook delivery failed", zap.Error(err), zap.String("delivery_id", d.ID), zap.String("webhook_id", d.WebhookID))
+	if disabled {
+		s.log.Warn("Webhook disabled after repeated failures", zap.String("webhook_id", d.WebhookID))
+	}
+}
+
+// backoff is the wait after the given failed attempt: retryBase doubled per attempt, capped
+// at retryMax.
+func backoff(attempt int) time.Duration {
+	wait := retryBase
+	for i := 1; i < attempt && wait < retryMax; i++ {
+		wait *= 2
+	}
+	return min(wait, retryMax)
+}
+
+// post sends a delivery's payload with its ID to target, signed with secret, returning the
+// response status if there was one and an error unless it was 2xx.
+func (s *WebhooksService) post(ctx context.Context, id, target, secret string, data []byte) (*int, error) {
+	var payload map[string]any
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+	payload["id"] = id
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(DeliveryIDHeader, id)
+	req.Header.Set(jwtMiddleware.WebhookTimestampHeader, ts)
+	req.Header.Set(jwtMiddleware.WebhookSignatureHeader, "v1="+jwtMiddleware.SignWebhook(secret, ts, body))
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+	status := resp.StatusCode
+	if status < 200 || status >= 300 {
+		return &status, fmt.Errorf("webhook returned %d", status)
+	}
+	return &status, nil
+}
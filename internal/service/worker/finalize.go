@@ -2,18 +2,33 @@ package worker
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"time"
 
 	"go.uber.org/zap"
 
+	"github.com/samirwankhede/lewly-pgpyewj/internal/clock"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/domain"
+	kafkax "github.com/samirwankhede/lewly-pgpyewj/internal/kafka"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/lock"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/metrics"
 	redisx "github.com/samirwankhede/lewly-pgpyewj/internal/redis"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/service/fx"
 	mailerService "github.com/samirwankhede/lewly-pgpyewj/internal/service/mailer"
+	paymentService "github.com/samirwankhede/lewly-pgpyewj/internal/service/payment"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/service/paymentlinks"
+	waitlistService "github.com/samirwankhede/lewly-pgpyewj/internal/service/waitlist"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/store/bookings"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/store/events"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/store/users"
-	"github.com/samirwankhede/lewly-pgpyewj/internal/store/waitlist"
+)
+
+// PaymentWindow is how long a pending booking waits for payment before it times out.
+const PaymentWindow = 15 * time.Minute
+
+const (
+	deferredChunk = 50
+	deferredLease = 2 * time.Minute
 )
 
 type FinalizeService struct {
@@ -21,31 +36,103 @@ type FinalizeService struct {
 	bookings      *bookings.BookingsRepository
 	events        *events.EventsRepository
 	users         *users.UsersRepository
-	waitlist      *waitlist.WaitlistRepository
+	promoter      *waitlistService.Promoter
 	paymentURL    string
 	mailer        *mailerService.MailerService
 	timeoutBucket *redisx.TimeoutBucket
+	links         *paymentlinks.PaymentLinksService
+	bookingEvents *redisx.BookingEvents
+	clock         clock.Clock
+	rates         *fx.Rates
+	payments      *paymentService.PaymentService
+	health        *paymentService.Health
+	deferMax      time.Duration
+	timeoutProd   *kafkax.Producer
+	locks         *lock.Locker
 }
 
 type FinalizePayload struct {
-	Type           string   `json:"type"`
-	BookingID      string   `json:"booking_id"`
-	EventID        string   `json:"event_id"`
-	UserID         string   `json:"user_id"`
-	Seats          []string `json:"seats"`
-	IdempotencyKey *string  `json:"idempotency_key"`
+	Type           string       `json:"type"`
+	BookingID      string       `json:"booking_id"`
+	EventID        string       `json:"event_id"`
+	UserID         string       `json:"user_id"`
+	Seats          domain.Seats `json:"seats"`
+	IdempotencyKey *string      `json:"idempotency_key"`
 }
 
-func NewFinalizeService(log *zap.Logger, bookings *bookings.BookingsRepository, events *events.EventsRepository, users *users.UsersRepository, waitlist *waitlist.WaitlistRepository, paymentURL string, mailer *mailerService.MailerService, timeoutBucket *redisx.TimeoutBucket) *FinalizeService {
+func NewFinalizeService(log *zap.Logger, bookings *bookings.BookingsRepository, events *events.EventsRepository, users *users.UsersRepository, promoter *waitlistService.Promoter, paymentURL string, mailer *mailerService.MailerService, timeoutBucket *redisx.TimeoutBucket, links *paymentlinks.PaymentLinksService, bookingEvents *redisx.BookingEvents) *FinalizeService {
 	return &FinalizeService{
 		log:           log,
 		bookings:      bookings,
 		events:        events,
 		users:         users,
-		waitlist:      waitlist,
+		promoter:      promoter,
 		paymentURL:    paymentURL,
 		mailer:        mailer,
 		timeoutBucket: timeoutBucket,
+		links:         links,
+		bookingEvents: bookingEvents,
+		clock:         clock.Real{},
+	}
+}
+
+// WithClock replaces the wall clock behind payment deadlines and timeouts.
+func (s *FinalizeService) WithClock(c clock.Clock) *FinalizeService {
+	s.clock = c
+	return s
+}
+
+// WithRates records, on each booking, its amount in the buyer's preferred currency.
+func (s *FinalizeService) WithRates(rates *fx.Rates) *FinalizeService {
+	s.rates = rates
+	return s
+}
+
+// WithPayments voids card authorizations left on bookings that time out.
+func (s *FinalizeService) WithPayments(payments *paymentService.PaymentService) *FinalizeService {
+	s.payments = payments
+	return s
+}
+
+// WithHealth defers payment links while health reports the payment service down: bookings
+// keep their seats, the user is told of the delay, and RunDeferred sends the link once the
+// service recovers or releases the seats after deferMax.
+func (s *FinalizeService) WithHealth(health *paymentService.Health, deferMax time.Duration) *FinalizeService {
+	s.health = health
+	s.deferMax = deferMax
+	return s
+}
+
+// WithEventLocks serializes payment timeouts with the other processes changing an event's
+// capacity (cancellations, token resyncs, reconciliation).
+func (s *FinalizeService) WithEventLocks(locks *lock.Locker) *FinalizeService {
+	s.locks = locks
+	return s
+}
+
+// displayQuote converts amount into the user's preferred currency, or returns nil when
+// they have none, it is the event's currency, or no rate is available.
+func (s *FinalizeService) displayQuote(ctx context.Context, user *users.User, amount float64, currency string) *fx.Quote {
+	if s.rates == nil || user.PreferredCurrency == "" || user.PreferredCurrency == currency {
+		return nil
+	}
+	q, err := s.rates.Convert(ctx, amount, currency, user.PreferredCurrency)
+	if err != nil {
+		// The charge doesn't depend on it, so carry on without a display amount
+		s.log.Warn("No display amount for booking", zap.Error(err), zap.String("currency", user.PreferredCurrency))
+		return nil
+	}
+	return q
+}
+
+// announce pushes a booking status transition to clients streaming the booking.
+func (s *FinalizeService) announce(ctx context.Context, typ, bookingID string, status domain.BookingStatus, expiresAt *time.Time) {
+	if s.bookingEvents == nil {
+		return
+	}
+	e := redisx.BookingEvent{Type: typ, BookingID: bookingID, Status: status, ExpiresAt: expiresAt}
+	if err := s.bookingEvents.Publish(ctx, e); err != nil {
+		s.log.Error("Failed to publish booking event", zap.Error(err), zap.String("booking_id", bookingID), zap.String("type", typ))
 	}
 }
 
@@ -60,6 +147,11 @@ func (s *FinalizeService) HandleBookingFinalization(ctx context.Context, payload
 		s.log.Error("Booking not found", zap.String("booking_id", payload.BookingID))
 		return fmt.Errorf("booking not found: %s", payload.BookingID)
 	}
+	// The outbox relay delivers at least once; a repeat for a settled booking has nothing to do
+	if booking.Status != domain.BookingPending {
+		s.log.Info("Booking no longer pending, skipping finalization", zap.String("booking_id", payload.BookingID), zap.String("status", string(booking.Status)))
+		return nil
+	}
 
 	// Get event details
 	event, err := s.events.Get(ctx, payload.EventID)
@@ -72,33 +164,184 @@ func (s *FinalizeService) HandleBookingFinalization(ctx context.Context, payload
 		return fmt.Errorf("event not found: %s", payload.EventID)
 	}
 
-	// Calculate amount based on seats
-	amount := event.TicketPrice * float64(len(payload.Seats))
-
-	// Generate payment link
-	paymentLink := fmt.Sprintf("%s/v1/payment/booking?booking_id=%s&amount=%.2f&payment_id=%s", s.paymentURL, payload.BookingID, amount, payload.BookingID)
+	// Calculate amount based on seats and their price tiers
+	amount, err := s.events.BookingAmount(ctx, event, booking.ID, payload.Seats)
+	if err != nil {
+		s.log.Error("Failed to price booking", zap.Error(err), zap.String("booking_id", payload.BookingID))
+		return err
+	}
 
 	// Hello Evaluator I've pondered over using redis, but over a network with not 'hot' objects like session tokens and decent partitions I haven't implemented cached mappings of event+userid -> email though in production I believe such will be needed
 	// Currently I believe the complexity will increase without much effectiveness so this user email fetching is more focused on HLD and functionality
 	user, err := s.users.GetByID(ctx, payload.UserID)
-	if err != nil {
+	if err != nil || user == nil {
 		s.log.Error("User not found", zap.String("user_id", payload.UserID))
 		return fmt.Errorf("user not found: %s", payload.UserID)
 	}
-	userEmail := user.Email
+
+	// Charge in the event's currency; record what the buyer sees in theirs alongside
+	pricing := &bookings.Booking{Currency: event.Currency, AmountDue: amount}
+	display := s.displayQuote(ctx, user, amount, event.Currency)
+	if display != nil {
+		pricing.DisplayCurrency, pricing.DisplayAmount = &display.Currency, &display.Amount
+		pricing.FXRate, pricing.FXAsOf = &display.Rate, &display.AsOf
+	}
+	if err := s.bookings.SetPricing(ctx, payload.BookingID, pricing); err != nil {
+		s.log.Error("Failed to record booking pricing", zap.Error(err), zap.String("booking_id", payload.BookingID))
+		return err
+	}
+
+	// A link to a payment service that is down would only fail; hold the seats instead
+	if !s.health.Up() {
+		return s.deferPayment(ctx, payload.BookingID, event, user.Email)
+	}
+
+	return s.requestPayment(ctx, payload, event, user.Email, amount, event.Currency, display)
+}
+
+// requestPayment emails the payment link and starts the payment window.
+func (s *FinalizeService) requestPayment(ctx context.Context, payload FinalizePayload, event *events.Event, email string, amount float64, currency string, display *fx.Quote) error {
+	// The timeout is scheduled before the link goes out, so no booking is left without one;
+	// if scheduling fails the message is retried
+	deadline := s.clock.Now().Add(PaymentWindow)
+	if err := s.timeoutBucket.AddBooking(ctx, payload.EventID, payload.BookingID, deadline); err != nil {
+		s.log.Error("Failed to schedule payment timeout", zap.Error(err), zap.String("booking_id", payload.BookingID))
+		return err
+	}
+
+	// Generate payment link
+	paymentLink := s.paymentLink(ctx, payload.BookingID, amount)
+
 	// Send payment request email
-	err = s.mailer.SendPaymentRequestEmail(userEmail, event.Name, amount, paymentLink)
+	err := s.mailer.For(event.OrganizerID).About(payload.BookingID).SendPaymentRequestEmail(email, event.Name, amount, currency, display, paymentLink)
 	if err != nil {
 		s.log.Error("Failed to send payment request email", zap.Error(err))
 		return fmt.Errorf("failed to send payment request email")
 	}
 
-	// Schedule timeout for new booking
-	s.scheduleBookingTimeout(ctx, payload.BookingID, payload.EventID, payload.UserID, payload.Seats)
+	s.announce(ctx, redisx.BookingEventPaymentRequested, payload.BookingID, domain.BookingPending, &deadline)
 
 	return nil
 }
 
+// deferPayment holds a pending booking's seats without a payment link and tells the user.
+// Streams get a payment_delayed event whose expires_at is when the hold is released.
+func (s *FinalizeService) deferPayment(ctx context.Context, bookingID string, event *events.Event, email string) error {
+	held, err := s.bookings.DeferPayment(ctx, bookingID)
+	if err != nil {
+		s.log.Error("Failed to defer payment", zap.Error(err), zap.String("booking_id", bookingID))
+		return err
+	}
+	if !held {
+		s.log.Info("Booking is no longer pending, not deferring payment", zap.String("booking_id", bookingID))
+		return nil
+	}
+	metrics.PaymentDeferralsTotal.WithLabelValues("deferred").Inc()
+	s.log.Info("Payment service down, deferring payment link", zap.String("booking_id", bookingID))
+
+	holdUntil := s.clock.Now().Add(s.deferMax)
+	// The booking is held either way, so a lost notice isn't worth redelivering the message
+	if err := s.mailer.For(event.OrganizerID).About(bookingID).SendPaymentDelayedEmail(email, event.Name, holdUntil); err != nil {
+		s.log.Warn("Failed to send payment delayed email", zap.Error(err), zap.String("booking_id", bookingID))
+	}
+	s.announce(ctx, redisx.BookingEventPaymentDelayed, bookingID, domain.BookingPending, &holdUntil)
+	return nil
+}
+
+// RunDeferred works through deferred bookings every interval until ctx is done: while the
+// payment service is up their links go out with a full payment window, and those held longer
+// than deferMax are expired, releasing the seats to the waitlist. Claims keep two workers
+// from handling the same booking.
+func (s *FinalizeService) RunDeferred(ctx context.Context, interval time.Duration) {
+	if s.health == nil || interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.resumeDeferred(ctx)
+		}
+	}
+}
+
+func (s *FinalizeService) resumeDeferred(ctx context.Context) {
+	up := s.health.Up()
+	expireBefore := s.clock.Now().Add(-s.deferMax)
+	before := expireBefore
+	if up {
+		before = s.clock.Now()
+	}
+	for ctx.Err() == nil {
+		claimed, err := s.bookings.ClaimDeferred(ctx, before, deferredChunk, deferredLease)
+		if err != nil {
+			s.log.Error("Failed to claim deferred bookings", zap.Error(err))
+			return
+		}
+		if len(claimed) == 0 {
+			return
+		}
+		for _, b := range claimed {
+			switch {
+			case b.DeferredAt.Before(expireBefore):
+				s.expireDeferred(ctx, b)
+			case s.health.Up():
+				s.resumePayment(ctx, b)
+			default:
+				// Down again mid-pass; leave the rest for the next recovery
+				if err := s.bookings.ReleaseDeferred(ctx, b.ID); err != nil {
+					s.log.Error("Failed to release deferred booking", zap.Error(err), zap.String("booking_id", b.ID))
+				}
+			}
+		}
+		if s.health.Up() != up {
+			return
+		}
+	}
+}
+
+// resumePayment sends a deferred booking's payment link. On failure the claim is kept, so
+// the booking is retried once its lease runs out.
+func (s *FinalizeService) resumePayment(ctx context.Context, b *bookings.DeferredBooking) {
+	event, err := s.events.Get(ctx, b.EventID)
+	if err != nil || event == nil {
+		s.log.Error("Failed to get event for deferred booking", zap.Error(err), zap.String("booking_id", b.ID))
+		return
+	}
+	user, err := s.users.GetByID(ctx, b.UserID)
+	if err != nil || user == nil {
+		s.log.Error("Failed to get user for deferred booking", zap.Error(err), zap.String("booking_id", b.ID))
+		return
+	}
+	var display *fx.Quote
+	if b.DisplayCurrency != nil && b.DisplayAmount != nil && b.FXRate != nil && b.FXAsOf != nil {
+		display = &fx.Quote{Currency: *b.DisplayCurrency, Amount: *b.DisplayAmount, Rate: *b.FXRate, AsOf: *b.FXAsOf}
+	}
+
+	payload := FinalizePayload{BookingID: b.ID, EventID: b.EventID, UserID: b.UserID, Seats: b.Seats}
+	if err := s.requestPayment(ctx, payload, event, user.Email, b.AmountDue, b.Currency, display); err != nil {
+		return
+	}
+	if err := s.bookings.ResumePayment(ctx, b.ID); err != nil {
+		s.log.Error("Failed to clear payment deferral", zap.Error(err), zap.String("booking_id", b.ID))
+	}
+	metrics.PaymentDeferralsTotal.WithLabelValues("resumed").Inc()
+	s.log.Info("Sent deferred payment link", zap.String("booking_id", b.ID), zap.Duration("deferred_for", s.clock.Now().Sub(b.DeferredAt)))
+}
+
+// expireDeferred times out a booking held past deferMax without its payment link.
+func (s *FinalizeService) expireDeferred(ctx context.Context, b *bookings.DeferredBooking) {
+	payload := FinalizePayload{Type: "booking_timeout", BookingID: b.ID, EventID: b.EventID, UserID: b.UserID, Seats: b.Seats}
+	if err := s.HandleBookingTimeout(ctx, payload); err != nil {
+		s.log.Error("Failed to expire deferred booking", zap.Error(err), zap.String("booking_id", b.ID))
+		return
+	}
+	metrics.PaymentDeferralsTotal.WithLabelValues("expired").Inc()
+}
+
 func (s *FinalizeService) HandleBookingTimeout(ctx context.Context, payload FinalizePayload) error {
 	// Get booking details
 	booking, err := s.bookings.GetByID(ctx, payload.BookingID)
@@ -112,117 +355,73 @@ func (s *FinalizeService) HandleBookingTimeout(ctx context.Context, payload Fina
 	}
 
 	// Check if booking is still pending
-	if booking.Status != "pending" {
+	if booking.Status != domain.BookingPending {
 		s.log.Info("Booking is no longer pending, skipping timeout",
 			zap.String("booking_id", payload.BookingID),
-			zap.String("status", booking.Status))
+			zap.String("status", string(booking.Status)))
+		s.settleTimeout(ctx, payload)
 		return nil
 	}
 
-	// Cancel the booking
-	_, _, err = s.bookings.CancelBookingTx(ctx, payload.BookingID)
-	if err != nil {
-		s.log.Error("Failed to cancel booking", zap.Error(err), zap.String("booking_id", payload.BookingID))
-		return err
-	}
-
-	// Get event details
-	event, err := s.events.Get(ctx, payload.EventID)
+	// A payment in progress (e.g. a 3DS challenge) may have moved the deadline back since this
+	// message was published; the schedule fires it again then
+	v, err := s.timeoutBucket.GetBooking(ctx, payload.EventID, payload.BookingID)
 	if err != nil {
-		s.log.Error("Failed to get event", zap.Error(err), zap.String("event_id", payload.EventID))
 		return err
 	}
-	if event == nil {
-		s.log.Error("Event not found", zap.String("event_id", payload.EventID))
-		return fmt.Errorf("event not found: %s", payload.EventID)
-	}
-
-	// Promote next person from waitlist
-	userID, _, position, err := s.waitlist.NextActive(ctx, payload.EventID)
-	if err != nil {
-		s.log.Error("Failed to get next waitlist user", zap.Error(err), zap.String("event_id", payload.EventID))
-		return err
+	if until, ok := redisx.ExtendedUntil(v); ok && until.After(s.clock.Now()) {
+		s.log.Info("Payment window extended, not timing out yet", zap.String("booking_id", payload.BookingID), zap.Time("until", until))
+		return nil
 	}
 
-	if userID != "" {
-		// Create new pending booking for waitlist user
-		seatsJSON, _ := json.Marshal(payload.Seats)
-		newBooking, err := s.bookings.CreatePending(ctx, userID, payload.EventID, nil, seatsJSON)
-		if err != nil {
-			s.log.Error("Failed to create booking for waitlist user", zap.Error(err))
+	// Expiring the booking and handing its seats on happen under the event's lock, like a
+	// cancellation
+	err = s.locks.Event(ctx, "timeout", payload.EventID, func(ctx context.Context) error {
+		// Cancel the booking
+		if _, _, err := s.bookings.CancelBookingTx(ctx, payload.BookingID); err != nil {
+			s.log.Error("Failed to cancel booking", zap.Error(err), zap.String("booking_id", payload.BookingID))
 			return err
 		}
+		s.announce(ctx, redisx.BookingEventExpired, payload.BookingID, domain.BookingExpired, nil)
 
-		// Calculate amount for new booking
-		amount := event.TicketPrice * float64(len(payload.Seats))
-		paymentLink := fmt.Sprintf("%s/v1/payment/booking?booking_id=%s&amount=%.2f&payment_id=%s", s.paymentURL, newBooking.ID, amount, newBooking.ID)
-
-		// Send waitlist promotion email
-		user, err := s.users.GetByID(ctx, payload.UserID)
-		if err != nil {
-			s.log.Error("User not found", zap.String("user_id", payload.UserID))
-			return fmt.Errorf("user not found: %s", payload.UserID)
+		// Hand the seats to the next person on the waitlist
+		if _, err := s.promoter.Promote(ctx, payload.EventID, payload.BookingID, payload.Seats); err != nil {
+			s.log.Error("Failed to promote waitlist user", zap.Error(err), zap.String("event_id", payload.EventID))
+			return err
 		}
-		userEmail := user.Email
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
-		err = s.mailer.SendWaitlistPromotionEmail(userEmail, event.Name)
-		if err != nil {
-			s.log.Error("Failed to send waitlist promotion email", zap.Error(err))
-			// Don't return error, continue processing
-		}
-		err = s.mailer.SendPaymentRequestEmail(userEmail, event.Name, amount, paymentLink)
-		if err != nil {
-			s.log.Error("Failed to send payment request email", zap.Error(err))
-			return fmt.Errorf("failed to send payment request email")
+	// An authorization that came through too late to book the seats mustn't keep holding the card
+	if s.payments != nil {
+		if _, err := s.payments.VoidBooking(ctx, payload.BookingID); err != nil {
+			s.log.Error("Failed to void authorization", zap.Error(err), zap.String("booking_id", payload.BookingID))
 		}
-
-		// Schedule timeout for new booking
-		s.scheduleBookingTimeout(ctx, newBooking.ID, payload.EventID, userID, payload.Seats)
-
-		s.log.Info("Promoted waitlist user",
-			zap.String("old_booking_id", payload.BookingID),
-			zap.String("new_booking_id", newBooking.ID),
-			zap.String("user_id", userID),
-			zap.Int("position", position))
-	} else {
-		s.log.Info("No users in waitlist to promote", zap.String("event_id", payload.EventID))
 	}
 
+	s.settleTimeout(ctx, payload)
 	return nil
 }
 
-func (s *FinalizeService) scheduleBookingTimeout(ctx context.Context, bookingID, eventID, userID string, seats []string) {
-	go func() {
-		err := s.timeoutBucket.AddBooking(ctx, eventID, bookingID)
-		if err != nil {
-			s.log.Error("Failed to set payment timeout", zap.Error(err))
-		}
-
-		time.Sleep(15 * time.Minute)
-
-		timeoutPayload := FinalizePayload{
-			Type:      "booking_timeout",
-			BookingID: bookingID,
-			EventID:   eventID,
-			UserID:    userID,
-			Seats:     seats,
-		}
-
-		v, err := s.timeoutBucket.GetBooking(ctx, eventID, bookingID)
-		if err != nil {
-			s.log.Error("Failed to get payment timeout", zap.Error(err))
-		}
-		if v != "processed" {
-			// Process the timeout
-			err = s.HandleBookingTimeout(ctx, timeoutPayload)
-			if err != nil {
-				s.log.Error("Failed to process booking timeout", zap.Error(err), zap.String("booking_id", bookingID))
-			}
-		}
-		_, err = s.timeoutBucket.DeleteBooking(ctx, eventID, bookingID)
-		if err != nil {
-			s.log.Error("Failed to Delete timeout bucket", zap.Error(err))
-		}
+// settleTimeout takes a booking's payment timeout off the schedule once it needs nothing
+// more. A failure only means the poller fires it again and it's found settled then.
+func (s *FinalizeService) settleTimeout(ctx context.Context, payload FinalizePayload) {
+	if _, err := s.timeoutBucket.DeleteBooking(ctx, payload.EventID, payload.BookingID); err != nil {
+		s.log.Error("Failed to delete payment timeout", zap.Error(err), zap.String("booking_id", payload.BookingID))
+	}
+}
 
-	}()
+// paymentLink returns a short link to the booking's payment URL that expires with the
+// payment window, falling back to the full URL if the short link can't be stored.
+func (s *FinalizeService) paymentLink(ctx context.Context, bookingID string, amount float64) string {
+	target := fmt.Sprintf("%s/v1/payment/booking?booking_id=%s&amount=%.2f&payment_id=%s", s.paymentURL, bookingID, amount, bookingID)
+	short, err := s.links.Shorten(ctx, bookingID, target, s.clock.Now().Add(PaymentWindow))
+	if err != nil {
+		s.log.Error("Failed to shorten payment link", zap.Error(err), zap.String("booking_id", bookingID))
+		return target
+	}
+	return short
 }
@@ -0,0 +1,163 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	kafkax "github.com/samirwankhede/lewly-pgpyewj/internal/kafka"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/metrics"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/outbox"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/tracing"
+)
+
+const (
+	// Messages are claimed outboxChunk at a time and leased for outboxLease; one still
+	// unpublished after its lease, because its relay died, is published again
+	outboxChunk = 100
+	outboxLease = time.Minute
+	// Published messages are kept this long for inspection, then deleted
+	outboxRetention = 24 * time.Hour
+)
+
+// OutboxRelay publishes the messages the API writes to the outbox to Kafka. A message is
+// marked published only after Kafka acknowledged it, so delivery is at-least-once: a relay
+// dying in between publishes it again. The finalizer skips bookings that are no longer
+// pending; a repeat while the payment is still open sends the payment link again.
+type OutboxRelay struct {
+	log       *zap.Logger
+	repo      *outbox.OutboxRepository
+	producers map[string]*kafkax.Producer
+	// maxAttempts is how many publishes of a message fail before it is given up on
+	maxAttempts int
+}
+
+// defaultOutboxMaxAttempts is how many publishes of a message fail before it is given up
+// on, unless WithMaxAttempts says otherwise.
+const defaultOutboxMaxAttempts = 10
+
+// NewOutboxRelay relays messages to the topics of producers. Messages for any other topic
+// stay in the outbox, logged as failed.
+func NewOutboxRelay(log *zap.Logger, repo *outbox.OutboxRepository, producers ...*kafkax.Producer) *OutboxRelay {
+	byTopic := make(map[string]*kafkax.Producer, len(producers))
+	for _, p := range producers {
+		byTopic[p.Topic()] = p
+	}
+	return &OutboxRelay{log: log, repo: repo, producers: byTopic, maxAttempts: defaultOutboxMaxAttempts}
+}
+
+// WithMaxAttempts gives up on a message once maxAttempts publishes of it have failed,
+// setting it aside as failed instead of retrying it on every pass.
+func (r *OutboxRelay) WithMaxAttempts(maxAttempts int) *OutboxRelay {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	r.maxAttempts = maxAttempts
+	return r
+}
+
+// Run drains the outbox now and every interval after until ctx is done. Any number of
+// workers can run it; each message is claimed by one of them.
+func (r *OutboxRelay) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	lastPurge := time.Time{}
+	for {
+		r.drain(ctx)
+		r.sample(ctx)
+		if time.Since(lastPurge) >= time.Hour {
+			lastPurge = time.Now()
+			if n, err := r.repo.PurgePublished(ctx, lastPurge.Add(-outboxRetention)); err != nil {
+				r.log.Error("Failed to purge published outbox messages", zap.Error(err))
+			} else if n > 0 {
+				r.log.Info("Purged published outbox messages", zap.Int64("count", n))
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *OutboxRelay) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		msgs, err := r.repo.Claim(ctx, outboxChunk, outboxLease)
+		if err != nil {
+			r.log.Error("Failed to claim outbox messages", zap.Error(err))
+			return
+		}
+		for _, m := range msgs {
+			r.publish(ctx, m)
+		}
+		if len(msgs) < outboxChunk {
+			return
+		}
+	}
+}
+
+// publish sends one claimed message, re-encoded with the producer's codec, and marks it
+// published. On failure the claim is released for a later pass, or, once the message has
+// had its attempts, it is marked failed.
+func (r *OutboxRelay) publish(ctx context.Context, m *outbox.Message) {
+	err := r.send(ctx, m)
+	if err != nil && m.Attempts >= r.maxAttempts {
+		metrics.OutboxPublishedTotal.WithLabelValues("dead").Inc()
+		r.log.Error("Giving up on outbox message", zap.Error(err), zap.Int64("id", m.ID), zap.String("topic", m.Topic), zap.Int("attempts", m.Attempts))
+		if err := r.repo.MarkFailed(ctx, m.ID, err.Error()); err != nil {
+			r.log.Error("Failed to mark outbox message failed", zap.Error(err), zap.Int64("id", m.ID))
+		}
+		return
+	}
+	if err != nil {
+		metrics.OutboxPublishedTotal.WithLabelValues("failed").Inc()
+		r.log.Error("Failed to publish outbox message", zap.Error(err), zap.Int64("id", m.ID), zap.String("topic", m.Topic), zap.Int("attempts", m.Attempts))
+		if err := r.repo.Release(ctx, m.ID, err.Error()); err != nil {
+			r.log.Error("Failed to release outbox message", zap.Error(err), zap.Int64("id", m.ID))
+		}
+		return
+	}
+	metrics.OutboxPublishedTotal.WithLabelValues("published").Inc()
+	// If this fails the message is published again once the lease runs out
+	if err := r.repo.MarkPublished(ctx, m.ID); err != nil {
+		r.log.Error("Failed to mark outbox message published", zap.Error(err), zap.Int64("id", m.ID))
+	}
+}
+
+func (r *OutboxRelay) send(ctx context.Context, m *outbox.Message) error {
+	prod, ok := r.producers[m.Topic]
+	if !ok {
+		return fmt.Errorf("no producer for topic %s", m.Topic)
+	}
+	env, err := kafkax.JSONCodec{}.Unmarshal(m.Envelope)
+	if err != nil {
+		return err
+	}
+	return prod.PublishEnvelope(tracing.Extract(ctx, m.TraceContext), []byte(m.Key), env)
+}
+
+func (r *OutboxRelay) sample(ctx context.Context) {
+	n, oldest, err := r.repo.Backlog(ctx)
+	if err != nil {
+		r.log.Error("Failed to sample outbox backlog", zap.Error(err))
+		return
+	}
+	metrics.OutboxBacklog.Set(float64(n))
+	age := 0.0
+	if !oldest.IsZero() {
+		age = time.Since(oldest).Seconds()
+	}
+	metrics.OutboxOldestSeconds.Set(age)
+	failed, err := r.repo.Failed(ctx)
+	if err != nil {
+		r.log.Error("Failed to count failed outbox messages", zap.Error(err))
+		return
+	}
+	metrics.OutboxFailed.Set(float64(failed))
+}
@@ -0,0 +1,108 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/domain"
+	kafkax "github.com/samirwankhede/lewly-pgpyewj/internal/kafka"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/metrics"
+)
+
+// producerName identifies messages the worker publishes itself in Kafka envelopes.
+const producerName = "evently-worker"
+
+const (
+	// Due timeouts are claimed timeoutChunk at a time and leased for timeoutLease; one
+	// still scheduled after its lease, because its message was lost, fires again
+	timeoutChunk = 100
+	timeoutLease = 2 * time.Minute
+)
+
+// WithTimeouts lets RunTimeouts publish booking_timeout messages through prod, onto the
+// topic the finalizer consumes.
+func (s *FinalizeService) WithTimeouts(prod *kafkax.Producer) *FinalizeService {
+	s.timeoutProd = prod
+	return s
+}
+
+// RunTimeouts fires payment timeouts as they come due, now and every interval after until
+// ctx is done. The schedule lives in Redis, so timeouts survive worker restarts, and any
+// number of workers can poll it: each due timeout is claimed by one of them, published as a
+// booking_timeout message and settled by whichever worker consumes it.
+func (s *FinalizeService) RunTimeouts(ctx context.Context, interval time.Duration) {
+	if s.timeoutProd == nil || interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.fireTimeouts(ctx)
+		if n, err := s.timeoutBucket.Scheduled(ctx); err == nil {
+			metrics.BookingTimeoutsScheduled.Set(float64(n))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *FinalizeService) fireTimeouts(ctx context.Context) {
+	for ctx.Err() == nil {
+		due, err := s.timeoutBucket.ClaimDue(ctx, s.clock.Now(), timeoutChunk, timeoutLease)
+		if err != nil {
+			s.log.Error("Failed to claim due payment timeouts", zap.Error(err))
+			return
+		}
+		for _, d := range due {
+			s.fireTimeout(ctx, d.EventID, d.BookingID)
+		}
+		if len(due) < timeoutChunk {
+			return
+		}
+	}
+}
+
+// fireTimeout publishes the booking's timeout, or just takes it off the schedule if the
+// booking was paid or cancelled in the meantime. Failures are left to the lease.
+func (s *FinalizeService) fireTimeout(ctx context.Context, eventID, bookingID string) {
+	booking, err := s.bookings.GetByID(ctx, bookingID)
+	if err != nil {
+		metrics.BookingTimeoutsTotal.WithLabelValues("failed").Inc()
+		s.log.Error("Failed to get booking for timeout", zap.Error(err), zap.String("booking_id", bookingID))
+		return
+	}
+	if booking == nil || booking.Status != domain.BookingPending {
+		metrics.BookingTimeoutsTotal.WithLabelValues("settled").Inc()
+		if _, err := s.timeoutBucket.DeleteBooking(ctx, eventID, bookingID); err != nil {
+			s.log.Error("Failed to delete payment timeout", zap.Error(err), zap.String("booking_id", bookingID))
+		}
+		return
+	}
+
+	seats := booking.Seats
+	if seats == nil {
+		seats = []string{}
+	}
+	payload := FinalizePayload{
+		BookingID:      booking.ID,
+		EventID:        booking.EventID,
+		UserID:         booking.UserID,
+		Seats:          seats,
+		IdempotencyKey: &booking.IdempotencyKey,
+	}
+	env, err := kafkax.NewEnvelope(kafkax.TypeBookingTimeout, producerName, payload)
+	if err == nil {
+		err = s.timeoutProd.PublishEnvelope(ctx, []byte(booking.EventID), env)
+	}
+	if err != nil {
+		metrics.BookingTimeoutsTotal.WithLabelValues("failed").Inc()
+		s.log.Error("Failed to publish booking timeout", zap.Error(err), zap.String("booking_id", bookingID))
+		return
+	}
+	metrics.BookingTimeoutsTotal.WithLabelValues("published").Inc()
+}
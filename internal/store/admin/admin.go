@@ -2,7 +2,6 @@ package admin
 
 import (
 	"context"
-	"fmt"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -26,6 +25,15 @@ type AnalyticsSummary struct {
 	TotalUsers          int            `json:"total_users"`
 	CapacityUtilization float64        `json:"capacity_utilization"`
 	MostPopularEvents   []PopularEvent `json:"most_popular_events"`
+	SalesByChannel      []ChannelSales `json:"sales_by_channel"`
+}
+
+// ChannelSales is what one booking source sold: paid bookings, their seats and revenue.
+type ChannelSales struct {
+	Source   string  `json:"source"`
+	Bookings int     `json:"bookings"`
+	Seats    int     `json:"seats"`
+	Revenue  float64 `json:"revenue"`
 }
 
 type PopularEvent struct {
@@ -35,6 +43,8 @@ type PopularEvent struct {
 	Likes    int    `json:"likes"`
 }
 
+// GetSummary totals bookings, events and users created between from and to. Sandbox events
+// and their bookings are rehearsals and don't count.
 func (r *AdminRepository) GetSummary(ctx context.Context, from, to time.Time) (*AnalyticsSummary, error) {
 	summary := &AnalyticsSummary{}
 
@@ -43,6 +53,7 @@ func (r *AdminRepository) GetSummary(ctx context.Context, from, to time.Time) (*
 		SELECT COUNT(*) 
 		FROM bookings 
 		WHERE created_at BETWEEN $1 AND $2 AND status = 'booked'
+		  AND event_id NOT IN (SELECT id FROM events WHERE sandbox)
 	`, from, to).Scan(&summary.TotalBookings)
 	if err != nil {
 		return nil, err
@@ -52,7 +63,7 @@ func (r *AdminRepository) GetSummary(ctx context.Context, from, to time.Time) (*
 	err = r.db.Pool.QueryRow(ctx, `
 		SELECT COUNT(*) 
 		FROM events 
-		WHERE created_at BETWEEN $1 AND $2
+		WHERE created_at BETWEEN $1 AND $2 AND NOT sandbox
 	`, from, to).Scan(&summary.TotalEvents)
 	if err != nil {
 		return nil, err
@@ -77,7 +88,7 @@ func (r *AdminRepository) GetSummary(ctx context.Context, from, to time.Time) (*
 				ELSE 0 
 			END
 		FROM events 
-		WHERE created_at BETWEEN $1 AND $2
+		WHERE created_at BETWEEN $1 AND $2 AND NOT sandbox
 	`, from, to).Scan(&summary.CapacityUtilization)
 	if err != nil {
 		return nil, err
@@ -88,7 +99,7 @@ func (r *AdminRepository) GetSummary(ctx context.Context, from, to time.Time) (*
 		SELECT e.id, e.name, COUNT(b.id) as bookings, e.likes
 		FROM events e
 		LEFT JOIN bookings b ON e.id = b.event_id AND b.status = 'booked' AND b.created_at BETWEEN $1 AND $2
-		WHERE e.created_at BETWEEN $1 AND $2
+		WHERE e.created_at BETWEEN $1 AND $2 AND NOT e.sandbox
 		GROUP BY e.id, e.name, e.likes
 		ORDER BY bookings DESC, e.likes DESC
 		LIMIT 10
@@ -107,9 +118,444 @@ func (r *AdminRepository) GetSummary(ctx context.Context, from, to time.Time) (*
 		summary.MostPopularEvents = append(summary.MostPopularEvents, event)
 	}
 
+	sales, err := r.channelSales(ctx, `
+		SELECT source, '', COUNT(*), COALESCE(SUM(jsonb_array_length(COALESCE(seats, '[]'::jsonb))), 0), COALESCE(SUM(amount_paid), 0)
+		FROM bookings
+		WHERE created_at BETWEEN $1 AND $2 AND status = 'booked'
+		  AND event_id NOT IN (SELECT id FROM events WHERE sandbox)
+		GROUP BY source
+		ORDER BY 5 DESC, 1
+	`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	summary.SalesByChannel = sales[""]
+	if summary.SalesByChannel == nil {
+		summary.SalesByChannel = []ChannelSales{}
+	}
+
 	return summary, nil
 }
 
+// channelSales scans rows of (source, event_id, bookings, seats, revenue), grouping them by
+// event_id; queries that aren't per event select ” for it.
+func (r *AdminRepository) channelSales(ctx context.Context, query string, args ...any) (map[string][]ChannelSales, error) {
+	rows, err := r.db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[string][]ChannelSales{}
+	for rows.Next() {
+		var eventID string
+		var cs ChannelSales
+		if err := rows.Scan(&cs.Source, &eventID, &cs.Bookings, &cs.Seats, &cs.Revenue); err != nil {
+			return nil, err
+		}
+		out[eventID] = append(out[eventID], cs)
+	}
+	return out, rows.Err()
+}
+
+// EventComparison is one event's sales metrics for side-by-side comparison. Percentages are
+// 0-100; TimeToSellOutSeconds is nil until booked seats reach capacity.
+type EventComparison struct {
+	EventID               string         `json:"event_id"`
+	Name                  string         `json:"name"`
+	StartTime             time.Time      `json:"start_time"`
+	Capacity              int            `json:"capacity"`
+	SeatsSold             int            `json:"seats_sold"`
+	SellThroughPct        float64        `json:"sell_through_pct"`
+	Revenue               float64        `json:"revenue"`
+	TimeToSellOutSeconds  *float64       `json:"time_to_sell_out_seconds"`
+	WaitlistPromotions    int            `json:"waitlist_promotions"`
+	WaitlistConversionPct float64        `json:"waitlist_conversion_pct"`
+	CancellationRatePct   float64        `json:"cancellation_rate_pct"`
+	SalesByChannel        []ChannelSales `json:"sales_by_channel"`
+	// Invitations is only set for private events
+	Invitations *InvitationStats `json:"invitations,omitempty"`
+}
+
+// InvitationStats tracks a private event's invitations: how many were issued, redeemed,
+// and redeemed by invitees who went on to book. Percentages are over issued invitations.
+type InvitationStats struct {
+	Issued        int     `json:"issued"`
+	Redeemed      int     `json:"redeemed"`
+	Booked        int     `json:"booked"`
+	RedemptionPct float64 `json:"redemption_pct"`
+	BookedPct     float64 `json:"booked_pct"`
+}
+
+// CompareEvents computes EventComparison for each of the given events that exists, in
+// start time order. Sell-out time runs from the first booking to the booking whose seats
+// filled the event; waitlist conversion is promoted bookings that were paid over everyone
+// who reached the waitlist (promoted plus still waiting); cancellation rate is paid bookings
+// later cancelled over all paid bookings. SalesByChannel splits paid bookings by source.
+// Private events also get their invitation funnel.
+//
+// It reads bookings rather than analytics_aggregates: nothing populates that rollup, and
+// its daily booking and cancellation counts carry neither seats, revenue, channels nor
+// booking times, so sell-out time and waitlist conversion can't be derived from it. Every
+// subquery is bounded to the compared events by idx_bookings_event_status.
+func (r *AdminRepository) CompareEvents(ctx context.Context, eventIDs []string) ([]*EventComparison, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		WITH b AS (
+			SELECT event_id, user_id, status, payment_status, amount_paid, created_at,
+			       jsonb_array_length(COALESCE(seats, '[]'::jsonb)) AS n,
+			       idempotency_key LIKE 'waitlist-promotion:%' AS promoted
+			FROM bookings
+			WHERE event_id = ANY($1::uuid[])
+		),
+		sold AS (
+			SELECT event_id, created_at,
+			       SUM(n) OVER (PARTITION BY event_id ORDER BY created_at) AS running,
+			       MIN(created_at) OVER (PARTITION BY event_id) AS first_at
+			FROM b
+			WHERE status = 'booked'
+		)
+		SELECT e.id, e.name, e.start_time, e.capacity,
+		       COALESCE((SELECT SUM(n) FROM b WHERE b.event_id = e.id AND b.status = 'booked'), 0),
+		       COALESCE((SELECT SUM(amount_paid) FROM b WHERE b.event_id = e.id AND b.status = 'booked'), 0),
+		       (SELECT EXTRACT(EPOCH FROM MIN(s.created_at) - MIN(s.first_at))::float8
+		        FROM sold s WHERE s.event_id = e.id AND s.running >= e.capacity),
+		       (SELECT COUNT(*) FROM b WHERE b.event_id = e.id AND b.promoted),
+		       (SELECT COUNT(*) FROM b WHERE b.event_id = e.id AND b.promoted AND b.status = 'booked'),
+		       (SELECT COUNT(*) FROM waitlist w WHERE w.event_id = e.id AND w.opted_out = false),
+		       (SELECT COUNT(*) FROM b WHERE b.event_id = e.id AND b.status = 'cancelled' AND b.payment_status IN ('paid', 'refunded')),
+		       (SELECT COUNT(*) FROM b WHERE b.event_id = e.id AND b.status = 'booked'),
+		       e.visibility = 'private',
+		       (SELECT COUNT(*) FROM event_invitations i WHERE i.event_id = e.id),
+		       (SELECT COUNT(*) FROM event_invitations i WHERE i.event_id = e.id AND i.redeemed_at IS NOT NULL),
+		       (SELECT COUNT(*) FROM event_invitations i WHERE i.event_id = e.id AND i.redeemed_at IS NOT NULL
+		          AND EXISTS (SELECT 1 FROM b WHERE b.event_id = e.id AND b.user_id = i.user_id AND b.status = 'booked'))
+		FROM events e
+		WHERE e.id = ANY($1::uuid[])
+		ORDER BY e.start_time, e.id
+	`, eventIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []*EventComparison{}
+	for rows.Next() {
+		c := &EventComparison{}
+		var promotedPaid, waiting, cancelledPaid, booked int
+		var private bool
+		inv := &InvitationStats{}
+		err := rows.Scan(&c.EventID, &c.Name, &c.StartTime, &c.Capacity, &c.SeatsSold, &c.Revenue,
+			&c.TimeToSellOutSeconds, &c.WaitlistPromotions, &promotedPaid, &waiting, &cancelledPaid, &booked,
+			&private, &inv.Issued, &inv.Redeemed, &inv.Booked)
+		if err != nil {
+			return nil, err
+		}
+		if private {
+			inv.RedemptionPct = percent(inv.Redeemed, inv.Issued)
+			inv.BookedPct = percent(inv.Booked, inv.Issued)
+			c.Invitations = inv
+		}
+		c.SellThroughPct = percent(c.SeatsSold, c.Capacity)
+		c.WaitlistConversionPct = percent(promotedPaid, c.WaitlistPromotions+waiting)
+		c.CancellationRatePct = percent(cancelledPaid, cancelledPaid+booked)
+		out = append(out, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sales, err := r.channelSales(ctx, `
+		SELECT source, event_id::text, COUNT(*), COALESCE(SUM(jsonb_array_length(COALESCE(seats, '[]'::jsonb))), 0), COALESCE(SUM(amount_paid), 0)
+		FROM bookings
+		WHERE event_id = ANY($1::uuid[]) AND status = 'booked'
+		GROUP BY event_id, source
+		ORDER BY 5 DESC, 1
+	`, eventIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range out {
+		c.SalesByChannel = sales[c.EventID]
+		if c.SalesByChannel == nil {
+			c.SalesByChannel = []ChannelSales{}
+		}
+	}
+	return out, nil
+}
+
+func percent(part, whole int) float64 {
+	if whole <= 0 {
+		return 0
+	}
+	return float64(part) / float64(whole) * 100
+}
+
+// ExportedBooking is one row of an event's booking export.
+type ExportedBooking struct {
+	ID         string
+	UserID     string
+	UserEmail  string
+	Status     string
+	Seats      []string
+	AmountPaid float64
+	Currency   string
+	// DisplayCurrency and DisplayAmount are empty when the buyer saw the event's own currency
+	DisplayCurrency string
+	DisplayAmount   *float64
+	PaymentStatus   string
+	Source          string
+	CreatedAt       time.Time
+}
+
+// ExportBookings calls fn for every booking of the event, oldest first, streaming rows
+// instead of loading the event's bookings into memory.
+func (r *AdminRepository) ExportBookings(ctx context.Context, eventID string, fn func(*ExportedBooking) error) error {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT b.id, b.user_id, COALESCE(u.email, ''), b.status,
+		       ARRAY(SELECT jsonb_array_elements_text(COALESCE(b.seats, '[]'::jsonb))),
+		       b.amount_paid, b.currency, COALESCE(b.display_currency, ''), b.display_amount,
+		       b.payment_status, b.source, b.created_at
+		FROM bookings b
+		LEFT JOIN users u ON u.id = b.user_id
+		WHERE b.event_id = $1
+		ORDER BY b.created_at, b.id
+	`, eventID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		b := &ExportedBooking{}
+		err := rows.Scan(&b.ID, &b.UserID, &b.UserEmail, &b.Status, &b.Seats,
+			&b.AmountPaid, &b.Currency, &b.DisplayCurrency, &b.DisplayAmount, &b.PaymentStatus, &b.Source, &b.CreatedAt)
+		if err != nil {
+			return err
+		}
+		if err := fn(b); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// CloneRequest overrides fields of a cloned event; nil or empty fields keep the source's value.
+type CloneRequest struct {
+	OrganizerID string
+	Name        string
+	StartTime   *time.Time
+	EndTime     *time.Time
+}
+
+// CloneEvent copies an event's configuration and seat map into a new upcoming event owned
+// by req.OrganizerID. Only the template is copied: reserved and likes start at zero, every
+// seat is available, and no bookings, waitlist, likes or audit rows come along.
+// It returns the new event's ID, or "" if the source does not exist.
+func (r *AdminRepository) CloneEvent(ctx context.Context, sourceID string, req CloneRequest) (string, error) {
+	var newID string
+	err := r.db.WithTx(ctx, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, `
+			INSERT INTO events (name, venue, start_time, end_time, category, capacity, metadata, status,
+			                    ticket_price, cancellation_fee, maximum_tickets_per_booking, organizer_id,
+			                    max_tickets_per_user, user_ticket_window_hours, latitude, longitude,
+			                    waitlist_enabled, seat_selection_enabled, likes_enabled, no_single_seat, payment_capture, capture_at, currency, visibility)
+			SELECT COALESCE(NULLIF($2, ''), name), venue, COALESCE($3, start_time), COALESCE($4, end_time),
+			       category, capacity, metadata, 'upcoming',
+			       ticket_price, cancellation_fee, maximum_tickets_per_booking, $5,
+			       max_tickets_per_user, user_ticket_window_hours, latitude, longitude,
+			       waitlist_enabled, seat_selection_enabled, likes_enabled, no_single_seat, payment_capture, capture_at, currency, visibility
+			FROM events
+			WHERE id = $1
+			RETURNING id
+		`, sourceID, req.Name, req.StartTime, req.EndTime, req.OrganizerID).Scan(&newID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, `
+			INSERT INTO event_price_tiers (event_id, name, price)
+			SELECT $2, name, price FROM event_price_tiers WHERE event_id = $1
+		`, sourceID, newID)
+		if err != nil {
+			return err
+		}
+
+		// Seats of finished events may already have been archived
+		_, err = tx.Exec(ctx, `
+			INSERT INTO seats (event_id, seat_label, section, row_label, tier, position, status)
+			SELECT $2, seat_label, section, row_label, tier, position, 'available'
+			FROM (
+				SELECT seat_label, section, row_label, tier, position FROM seats WHERE event_id = $1
+				UNION ALL
+				SELECT seat_label, section, row_label, tier, position FROM seats_archive WHERE event_id = $1
+			) s
+		`, sourceID, newID)
+		return err
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return newID, nil
+}
+
+// MergeResult reports what MergeEvents moved, or why it moved nothing: the source still has
+// pending bookings, or seats booked on the source are missing from, taken on or claimed by a
+// pending booking on the target.
+type MergeResult struct {
+	SourceID         string   `json:"source_id"`
+	TargetID         string   `json:"target_id"`
+	Merged           bool     `json:"merged"`
+	BookingsMoved    int      `json:"bookings_moved"`
+	WaitlistMoved    int      `json:"waitlist_moved"`
+	LikesMoved       int      `json:"likes_moved"`
+	PendingBookings  int      `json:"pending_bookings,omitempty"`
+	ConflictingSeats []string `json:"conflicting_seats,omitempty"`
+}
+
+// MergeEvents folds an accidental duplicate event into the target in one transaction:
+// bookings move with their seats marked booked on the target's seat map, waitlist entries are
+// appended after the target's (users already waiting there keep their place), likes are
+// combined, and the source is cancelled. It returns nil if either event does not exist.
+func (r *AdminRepository) MergeEvents(ctx context.Context, sourceID, targetID string) (*MergeResult, error) {
+	var res *MergeResult
+	err := r.db.WithTx(ctx, func(tx pgx.Tx) error {
+		// Lock both events in a fixed order so concurrent merges can't deadlock
+		rows, err := tx.Query(ctx, `SELECT id FROM events WHERE id = ANY($1::uuid[]) ORDER BY id FOR UPDATE`, []string{sourceID, targetID})
+		if err != nil {
+			return err
+		}
+		locked := 0
+		for rows.Next() {
+			locked++
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if locked < 2 {
+			return nil
+		}
+		res = &MergeResult{SourceID: sourceID, TargetID: targetID}
+
+		// Pending bookings have payment timeouts scheduled against the source event
+		err = tx.QueryRow(ctx, `SELECT COUNT(*) FROM bookings WHERE event_id = $1 AND status = 'pending'`, sourceID).Scan(&res.PendingBookings)
+		if err != nil {
+			return err
+		}
+		if res.PendingBookings > 0 {
+			return nil
+		}
+
+		rows, err = tx.Query(ctx, `
+			SELECT DISTINCT l.label
+			FROM bookings b, jsonb_array_elements_text(COALESCE(b.seats, '[]'::jsonb)) AS l(label)
+			WHERE b.event_id = $1 AND b.status = 'booked'
+			  AND (
+			      NOT EXISTS (SELECT 1 FROM seats s WHERE s.event_id = $2 AND s.seat_label = l.label AND s.status = 'available')
+			      OR EXISTS (SELECT 1 FROM bookings p WHERE p.event_id = $2 AND p.status = 'pending' AND p.seats ? l.label)
+			  )
+			ORDER BY l.label
+		`, sourceID, targetID)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var label string
+			if err := rows.Scan(&label); err != nil {
+				rows.Close()
+				return err
+			}
+			res.ConflictingSeats = append(res.ConflictingSeats, label)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if len(res.ConflictingSeats) > 0 {
+			return nil
+		}
+
+		_, err = tx.Exec(ctx, `
+			UPDATE seats s
+			SET status = 'booked', held_by_booking = x.booking_id, held_until = NULL, updated_at = now()
+			FROM (
+				SELECT b.id AS booking_id, l.label
+				FROM bookings b, jsonb_array_elements_text(COALESCE(b.seats, '[]'::jsonb)) AS l(label)
+				WHERE b.event_id = $1 AND b.status = 'booked'
+			) x
+			WHERE s.event_id = $2 AND s.seat_label = x.label
+		`, sourceID, targetID)
+		if err != nil {
+			return err
+		}
+
+		// Changing the partition key moves the rows into the target's partition
+		tag, err := tx.Exec(ctx, `UPDATE bookings SET event_id = $2, updated_at = now() WHERE event_id = $1`, sourceID, targetID)
+		if err != nil {
+			return err
+		}
+		res.BookingsMoved = int(tag.RowsAffected())
+
+		tag, err = tx.Exec(ctx, `
+			INSERT INTO waitlist (event_id, user_id, position, opted_out, notified_at, expired_at, created_at)
+			SELECT $2, w.user_id,
+			       (SELECT COALESCE(MAX(position), 0) FROM waitlist WHERE event_id = $2) + ROW_NUMBER() OVER (ORDER BY w.position),
+			       w.opted_out, w.notified_at, w.expired_at, w.created_at
+			FROM waitlist w
+			WHERE w.event_id = $1
+			  AND NOT EXISTS (SELECT 1 FROM waitlist t WHERE t.event_id = $2 AND t.user_id = w.user_id)
+		`, sourceID, targetID)
+		if err != nil {
+			return err
+		}
+		res.WaitlistMoved = int(tag.RowsAffected())
+		if _, err := tx.Exec(ctx, `DELETE FROM waitlist WHERE event_id = $1`, sourceID); err != nil {
+			return err
+		}
+
+		tag, err = tx.Exec(ctx, `
+			INSERT INTO event_likes (user_id, event_id, created_at)
+			SELECT user_id, $2, created_at FROM event_likes WHERE event_id = $1
+			ON CONFLICT DO NOTHING
+		`, sourceID, targetID)
+		if err != nil {
+			return err
+		}
+		res.LikesMoved = int(tag.RowsAffected())
+		if _, err := tx.Exec(ctx, `DELETE FROM event_likes WHERE event_id = $1`, sourceID); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, `
+			UPDATE events e
+			SET reserved = e.reserved + s.reserved,
+			    likes = (SELECT COUNT(*) FROM event_likes WHERE event_id = e.id),
+			    updated_at = now()
+			FROM events s
+			WHERE e.id = $2 AND s.id = $1
+		`, sourceID, targetID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			UPDATE events SET status = 'cancelled', reserved = 0, likes = 0, updated_at = now() WHERE id = $1
+		`, sourceID)
+		if err != nil {
+			return err
+		}
+		res.Merged = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
 func (r *AdminRepository) CancelEvent(ctx context.Context, eventID string) error {
 	return r.db.WithTx(ctx, func(tx pgx.Tx) error {
 		// Update event status
@@ -145,25 +591,40 @@ func (r *AdminRepository) CancelEvent(ctx context.Context, eventID string) error
 	})
 }
 
-func (r *AdminRepository) UpdateEvent(ctx context.Context, eventID string, updates map[string]interface{}) error {
-	// Build dynamic update query
-	query := "UPDATE events SET "
-	args := []interface{}{}
-	argIndex := 1
+// PaidAttendeeEmails returns the email of every user with a paid booking for the event.
+func (r *AdminRepository) PaidAttendeeEmails(ctx context.Context, eventID string) ([]string, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT DISTINCT u.email
+		FROM bookings b
+		JOIN users u ON u.id = b.user_id
+		WHERE b.event_id = $1 AND b.payment_status = 'paid'
+	`, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	for field, value := range updates {
-		if argIndex > 1 {
-			query += ", "
+	emails := []string{}
+	for rows.Next() {
+		var email string
+		if err := rows.Scan(&email); err != nil {
+			return nil, err
 		}
-		query += field + " = $" + fmt.Sprintf("%d", argIndex)
-		args = append(args, value)
-		argIndex++
+		emails = append(emails, email)
 	}
+	return emails, rows.Err()
+}
 
-	query += ", updated_at = now() WHERE id = $" + fmt.Sprintf("%d", argIndex)
-	args = append(args, eventID)
+// UpdateEvent sets the given columns of an event. Only editableEventColumns can be set; any
+// other name, or a value of the wrong type, fails with a *store.ColumnError before anything
+// is written.
+func (r *AdminRepository) UpdateEvent(ctx context.Context, eventID string, updates map[string]interface{}) error {
+	update, err := store.BuildUpdate(updateEventName, "events", editableEventColumns, updates, "id", eventID)
+	if err != nil {
+		return err
+	}
 
-	result, err := r.db.Pool.Exec(ctx, query, args...)
+	result, err := r.db.Pool.Exec(ctx, update.SQL, update.Args...)
 	if err != nil {
 		return err
 	}
@@ -176,9 +637,7 @@ func (r *AdminRepository) UpdateEvent(ctx context.Context, eventID string, updat
 }
 
 func (r *AdminRepository) CreateAdminFromUser(ctx context.Context, userID string) error {
-	query := `UPDATE users SET role = 'admin', updated_at = now() WHERE id = $1`
-
-	result, err := r.db.Pool.Exec(ctx, query, userID)
+	result, err := r.db.Pool.Exec(ctx, grantAdminSQL, userID)
 	if err != nil {
 		return err
 	}
@@ -191,9 +650,22 @@ func (r *AdminRepository) CreateAdminFromUser(ctx context.Context, userID string
 }
 
 func (r *AdminRepository) RemoveAdmin(ctx context.Context, userID string) error {
-	query := `UPDATE users SET role = 'user', updated_at = now() WHERE id = $1 AND role = 'admin'`
+	result, err := r.db.Pool.Exec(ctx, revokeAdminSQL, userID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
 
-	result, err := r.db.Pool.Exec(ctx, query, userID)
+// GrantOrganizer makes the user an organizer publishing under the organizer profile, if
+// given. It returns pgx.ErrNoRows if there is no such user or they are an admin.
+func (r *AdminRepository) GrantOrganizer(ctx context.Context, userID string, organizerID *string) error {
+	result, err := r.db.Pool.Exec(ctx, grantOrganizerSQL, userID, organizerID)
 	if err != nil {
 		return err
 	}
@@ -205,10 +677,35 @@ func (r *AdminRepository) RemoveAdmin(ctx context.Context, userID string) error
 	return nil
 }
 
-func (r *AdminRepository) RemoveUser(ctx context.Context, userID string) error {
-	query := `DELETE FROM users WHERE id = $1 AND role != 'admin'`
+// RevokeOrganizer makes the organizer a plain user again. Their events keep them as owner,
+// so granting the role back restores access. It returns pgx.ErrNoRows if they weren't an
+// organizer.
+func (r *AdminRepository) RevokeOrganizer(ctx context.Context, userID string) error {
+	result, err := r.db.Pool.Exec(ctx, revokeOrganizerSQL, userID)
+	if err != nil {
+		return err
+	}
 
-	result, err := r.db.Pool.Exec(ctx, query, userID)
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// OrganizerProfile returns the organizer profile the user publishes under, or nil if they
+// have none.
+func (r *AdminRepository) OrganizerProfile(ctx context.Context, userID string) (*string, error) {
+	var organizerID *string
+	err := r.db.Pool.QueryRow(ctx, `SELECT organizer_id FROM users WHERE id = $1`, userID).Scan(&organizerID)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	return organizerID, err
+}
+
+func (r *AdminRepository) RemoveUser(ctx context.Context, userID string) error {
+	result, err := r.db.Pool.Exec(ctx, deleteUserSQL, userID)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,150 @@
+package admin
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/domain"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/seats"
+)
+
+// CapacityIncrease is a batch of seats added to an event, and where they went: offered to
+// the waitlist or put on sale as Redis tokens.
+type CapacityIncrease struct {
+	ID               string       `json:"id"`
+	EventID          string       `json:"event_id"`
+	Seats            domain.Seats `json:"seats"`
+	PreviousCapacity int          `json:"previous_capacity"`
+	NewCapacity      int          `json:"new_capacity"`
+	WaitlistOffers   int          `json:"waitlist_offers"`
+	TokensReleased   int          `json:"tokens_released"`
+	AdminID          *string      `json:"admin_id,omitempty"`
+	CreatedAt        time.Time    `json:"created_at"`
+	// ExistingSeats are requested labels the event already has; nothing is added if any are
+	ExistingSeats []string `json:"existing_seats,omitempty"`
+}
+
+// IncreaseCapacity adds newSeats to the end of the event's seat map and raises its capacity
+// in events and event_capacity by as many, in one transaction that holds the event's row
+// lock, so Postgres admission never sees the seats without the capacity or the reverse. It
+// adds nothing and returns the increase with ExistingSeats set if any label is already on
+// the seat map, and returns nil if the event does not exist.
+func (r *AdminRepository) IncreaseCapacity(ctx context.Context, eventID string, newSeats []seats.NewSeat, adminID *string) (*CapacityIncrease, error) {
+	labels := make([]string, len(newSeats))
+	sections := make([]string, len(newSeats))
+	rows := make([]string, len(newSeats))
+	tiers := make([]string, len(newSeats))
+	for i, s := range newSeats {
+		labels[i], sections[i], rows[i], tiers[i] = s.Label, s.Section, s.Row, s.Tier
+	}
+
+	var inc *CapacityIncrease
+	err := r.db.WithTx(ctx, func(tx pgx.Tx) error {
+		c := &CapacityIncrease{EventID: eventID, Seats: labels, AdminID: adminID}
+		err := tx.QueryRow(ctx, `SELECT capacity FROM events WHERE id = $1 FOR UPDATE`, eventID).Scan(&c.PreviousCapacity)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				return nil
+			}
+			return err
+		}
+
+		existing, err := tx.Query(ctx, `
+			SELECT seat_label FROM seats WHERE event_id = $1 AND seat_label = ANY($2::text[])
+			ORDER BY seat_label
+		`, eventID, labels)
+		if err != nil {
+			return err
+		}
+		defer existing.Close()
+		for existing.Next() {
+			var label string
+			if err := existing.Scan(&label); err != nil {
+				return err
+			}
+			c.ExistingSeats = append(c.ExistingSeats, label)
+		}
+		if err := existing.Err(); err != nil {
+			return err
+		}
+		if len(c.ExistingSeats) > 0 {
+			inc = c
+			return nil
+		}
+
+		_, err = tx.Exec(ctx, `
+			INSERT INTO seats (event_id, seat_label, section, row_label, tier, position, status)
+			SELECT $1, label, section, row_label, tier, base.n + s.n, 'available'
+			FROM unnest($2::text[], $3::text[], $4::text[], $5::text[]) WITH ORDINALITY AS s(label, section, row_label, tier, n),
+			     (SELECT COALESCE(MAX(position), 0) AS n FROM seats WHERE event_id = $1) base
+		`, eventID, labels, sections, rows, tiers)
+		if err != nil {
+			return err
+		}
+
+		err = tx.QueryRow(ctx, `
+			UPDATE events SET capacity = capacity + $2 WHERE id = $1 RETURNING capacity
+		`, eventID, len(labels)).Scan(&c.NewCapacity)
+		if err != nil {
+			return err
+		}
+		// Postgres admission locks this row; its capacity must move with the event's
+		_, err = tx.Exec(ctx, `
+			INSERT INTO event_capacity (event_id, capacity) VALUES ($1, $2)
+			ON CONFLICT (event_id) DO UPDATE SET capacity = EXCLUDED.capacity
+		`, eventID, c.NewCapacity)
+		if err != nil {
+			return err
+		}
+
+		err = tx.QueryRow(ctx, `
+			INSERT INTO capacity_increases (event_id, seats, previous_capacity, new_capacity, admin_id)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id, created_at
+		`, eventID, c.Seats, c.PreviousCapacity, c.NewCapacity, adminID).Scan(&c.ID, &c.CreatedAt)
+		if err != nil {
+			return err
+		}
+		inc = c
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return inc, nil
+}
+
+// RecordCapacityRelease stores where the increase's seats went once they were handed out.
+func (r *AdminRepository) RecordCapacityRelease(ctx context.Context, increaseID string, waitlistOffers, tokensReleased int) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		UPDATE capacity_increases SET waitlist_offers = $2, tokens_released = $3 WHERE id = $1
+	`, increaseID, waitlistOffers, tokensReleased)
+	return err
+}
+
+// ListCapacityIncreases returns the event's capacity increases, newest first.
+func (r *AdminRepository) ListCapacityIncreases(ctx context.Context, eventID string) ([]*CapacityIncrease, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT id, event_id, seats, previous_capacity, new_capacity, waitlist_offers, tokens_released, admin_id, created_at
+		FROM capacity_increases
+		WHERE event_id = $1
+		ORDER BY created_at DESC
+	`, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []*CapacityIncrease{}
+	for rows.Next() {
+		c := &CapacityIncrease{}
+		err := rows.Scan(&c.ID, &c.EventID, &c.Seats, &c.PreviousCapacity, &c.NewCapacity, &c.WaitlistOffers, &c.TokensReleased, &c.AdminID, &c.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
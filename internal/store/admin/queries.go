@@ -0,0 +1,50 @@
+package admin
+
+import "github.com/samirwankhede/lewly-pgpyewj/internal/store"
+
+// Statements that change roles or delete accounts, kept together so they can be audited at a
+// glance. Each carries a query name for the tracer.
+const (
+	grantAdminSQL = `-- name: grant_admin
+		UPDATE users SET role = 'admin', role_version = role_version + 1, updated_at = now() WHERE id = $1`
+	revokeAdminSQL = `-- name: revoke_admin
+		UPDATE users SET role = 'user', role_version = role_version + 1, updated_at = now() WHERE id = $1 AND role = 'admin'`
+	// Admins keep their role: an admin already manages every event
+	grantOrganizerSQL = `-- name: grant_organizer
+		UPDATE users SET role = 'organizer', organizer_id = $2, role_version = role_version + 1, updated_at = now() WHERE id = $1 AND role != 'admin'`
+	revokeOrganizerSQL = `-- name: revoke_organizer
+		UPDATE users SET role = 'user', organizer_id = NULL, role_version = role_version + 1, updated_at = now() WHERE id = $1 AND role = 'organizer'`
+	deleteUserSQL = `-- name: delete_user
+		DELETE FROM users WHERE id = $1 AND role != 'admin'`
+)
+
+// updateEventName labels the statements built by UpdateEvent.
+const updateEventName = "admin_update_event"
+
+// editableEventColumns are the event columns UpdateEvent may set. Capacity, status, seats and
+// the counters are left out: they have to change together with Redis tokens, seat rows or
+// bookings, which their own endpoints do.
+var editableEventColumns = store.Columns{
+	"name":                        {Kind: store.KindText},
+	"venue":                       {Kind: store.KindText},
+	"start_time":                  {Kind: store.KindTime, Nullable: true},
+	"end_time":                    {Kind: store.KindTime, Nullable: true},
+	"category":                    {Kind: store.KindText, Nullable: true},
+	"metadata":                    {Kind: store.KindJSON},
+	"ticket_price":                {Kind: store.KindNumber, Nullable: true},
+	"cancellation_fee":            {Kind: store.KindNumber, Nullable: true},
+	"maximum_tickets_per_booking": {Kind: store.KindInt},
+	"organizer_id":                {Kind: store.KindText, Nullable: true},
+	"max_tickets_per_user":        {Kind: store.KindInt, Nullable: true},
+	"user_ticket_window_hours":    {Kind: store.KindInt, Nullable: true},
+	"latitude":                    {Kind: store.KindNumber, Nullable: true},
+	"longitude":                   {Kind: store.KindNumber, Nullable: true},
+	"waitlist_enabled":            {Kind: store.KindBool},
+	"seat_selection_enabled":      {Kind: store.KindBool},
+	"likes_enabled":               {Kind: store.KindBool},
+	"no_single_seat":              {Kind: store.KindBool},
+	"payment_capture":             {Kind: store.KindText},
+	"capture_at":                  {Kind: store.KindTime, Nullable: true},
+	"currency":                    {Kind: store.KindText},
+	"visibility":                  {Kind: store.KindText},
+}
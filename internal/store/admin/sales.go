@@ -0,0 +1,42 @@
+package admin
+
+import (
+	"context"
+	"time"
+)
+
+// SalesBucket is what an event sold in one hour or day: booked bookings whose finalization
+// (their last update) falls in it, and their seats.
+type SalesBucket struct {
+	Start    time.Time
+	Bookings int
+	Tickets  int
+}
+
+// SalesByBucket groups the event's booked bookings by their updated_at truncated to unit
+// ("hour" or "day") in the named time zone, oldest first. Empty buckets are left out.
+func (r *AdminRepository) SalesByBucket(ctx context.Context, eventID, unit, timezone string) ([]SalesBucket, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT date_trunc($2, updated_at, $3) AS bucket,
+		       COUNT(*),
+		       COALESCE(SUM(jsonb_array_length(COALESCE(seats, '[]'::jsonb))), 0)
+		FROM bookings
+		WHERE event_id = $1 AND status = 'booked'
+		GROUP BY bucket
+		ORDER BY bucket
+	`, eventID, unit, timezone)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []SalesBucket
+	for rows.Next() {
+		var b SalesBucket
+		if err := rows.Scan(&b.Start, &b.Bookings, &b.Tickets); err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
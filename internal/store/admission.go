@@ -0,0 +1,85 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrAdmissionRestored is returned by HoldFallbackAdmission once bookings are admitted
+// through Redis again, so the fallback must not admit one.
+var ErrAdmissionRestored = errors.New("booking admission is back on Redis")
+
+// admissionKey is the kv_store row, shared by every API instance, that is true while
+// bookings are admitted through Postgres. Fallback admissions hold the admission lock
+// shared and the token rebuild holds it exclusively, so a rebuild never runs alongside a
+// fallback booking on any instance.
+const admissionKey = "admission_degraded"
+
+const (
+	admissionLockShared = `SELECT pg_advisory_xact_lock_shared(hashtext('admission'))`
+	admissionLock       = `SELECT pg_advisory_xact_lock(hashtext('admission'))`
+)
+
+const admissionDegraded = `SELECT EXISTS (SELECT 1 FROM kv_store WHERE key = $1 AND value = 'true'::jsonb)`
+
+// SetAdmissionDegraded records that bookings are admitted through Postgres, for every
+// instance to follow.
+func (d *DB) SetAdmissionDegraded(ctx context.Context) error {
+	_, err := d.Pool.Exec(ctx, `
+		INSERT INTO kv_store (key, value) VALUES ($1, 'true'::jsonb)
+		ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value
+	`, admissionKey)
+	return err
+}
+
+// AdmissionDegraded reports whether bookings are admitted through Postgres.
+func (d *DB) AdmissionDegraded(ctx context.Context) (bool, error) {
+	var degraded bool
+	err := d.Pool.QueryRow(ctx, admissionDegraded, admissionKey).Scan(&degraded)
+	return degraded, err
+}
+
+// HoldFallbackAdmission takes the admission lock shared until tx ends and returns
+// ErrAdmissionRestored unless bookings are still admitted through Postgres.
+func HoldFallbackAdmission(ctx context.Context, tx pgx.Tx) error {
+	if _, err := tx.Exec(ctx, admissionLockShared); err != nil {
+		return err
+	}
+	var degraded bool
+	if err := tx.QueryRow(ctx, admissionDegraded, admissionKey).Scan(&degraded); err != nil {
+		return err
+	}
+	if !degraded {
+		return ErrAdmissionRestored
+	}
+	return nil
+}
+
+// RestoreAdmission runs rebuild once every fallback admission in flight has committed and
+// then records that bookings are admitted through Redis again, holding the admission lock
+// so no instance admits a fallback booking in between. It reports false without running
+// rebuild when another instance restored admission first.
+func (d *DB) RestoreAdmission(ctx context.Context, rebuild func(ctx context.Context) error) (bool, error) {
+	restored := false
+	err := d.WithTx(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, admissionLock); err != nil {
+			return err
+		}
+		var degraded bool
+		if err := tx.QueryRow(ctx, admissionDegraded, admissionKey).Scan(&degraded); err != nil {
+			return err
+		}
+		if !degraded {
+			return nil
+		}
+		if err := rebuild(ctx); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, `UPDATE kv_store SET value = 'false'::jsonb WHERE key = $1`, admissionKey)
+		restored = err == nil
+		return err
+	})
+	return restored, err
+}
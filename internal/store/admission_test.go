@@ -0,0 +1,85 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/storetest"
+)
+
+func TestRestoreAdmissionWaitsForFallbackBookings(t *testing.T) {
+	db := storetest.DB(t)
+	ctx := context.Background()
+	t.Cleanup(func() { _, _ = db.Pool.Exec(ctx, `DELETE FROM kv_store WHERE key = $1`, "admission_degraded") })
+
+	if err := db.SetAdmissionDegraded(ctx); err != nil {
+		t.Fatalf("SetAdmissionDegraded: %v", err)
+	}
+
+	// A fallback booking on one instance holds the lock while another instance rebuilds
+	held := make(chan struct{})
+	commit := make(chan struct{})
+	booked := make(chan error, 1)
+	go func() {
+		booked <- db.WithTx(ctx, func(tx pgx.Tx) error {
+			if err := store.HoldFallbackAdmission(ctx, tx); err != nil {
+				close(held)
+				return err
+			}
+			close(held)
+			<-commit
+			return nil
+		})
+	}()
+	<-held
+
+	rebuilt := make(chan time.Time, 1)
+	restored := make(chan bool, 1)
+	go func() {
+		ok, err := db.RestoreAdmission(ctx, func(ctx context.Context) error {
+			rebuilt <- time.Now()
+			return nil
+		})
+		if err != nil {
+			t.Errorf("RestoreAdmission: %v", err)
+		}
+		restored <- ok
+	}()
+
+	select {
+	case <-rebuilt:
+		t.Fatal("rebuild ran while a fallback booking was in flight")
+	case <-time.After(200 * time.Millisecond):
+	}
+	committed := time.Now()
+	close(commit)
+	if err := <-booked; err != nil {
+		t.Fatalf("fallback booking: %v", err)
+	}
+	if at := <-rebuilt; at.Before(committed) {
+		t.Error("rebuild ran before the fallback booking committed")
+	}
+	if !<-restored {
+		t.Error("RestoreAdmission = false, want true")
+	}
+
+	err := db.WithTx(ctx, func(tx pgx.Tx) error { return store.HoldFallbackAdmission(ctx, tx) })
+	if !errors.Is(err, store.ErrAdmissionRestored) {
+		t.Errorf("fallback after restore = %v, want ErrAdmissionRestored", err)
+	}
+	if degraded, err := db.AdmissionDegraded(ctx); err != nil || degraded {
+		t.Errorf("AdmissionDegraded = %v, %v, want false", degraded, err)
+	}
+	ok, err := db.RestoreAdmission(ctx, func(ctx context.Context) error {
+		t.Error("second rebuild ran")
+		return nil
+	})
+	if err != nil || ok {
+		t.Errorf("second RestoreAdmission = %v, %v, want false", ok, err)
+	}
+}
@@ -3,27 +3,18 @@ package bookings
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"time"
 
 	"github.com/jackc/pgx/v5"
 	"go.uber.org/zap"
 
+	"github.com/samirwankhede/lewly-pgpyewj/internal/domain"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/store"
 )
 
-type Booking struct {
-	ID             string    `json:"id"`
-	UserID         string    `json:"user_id"`
-	EventID        string    `json:"event_id"`
-	Status         string    `json:"status"`
-	Seats          []byte    `json:"seats"` // JSON array of seat labels
-	IdempotencyKey string    `json:"idempotency_key,omitempty"`
-	AmountPaid     float64   `json:"amount_paid"`
-	PaymentStatus  string    `json:"payment_status"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
-	Version        int       `json:"version"`
-}
+// Booking is the canonical booking type; see package domain.
+type Booking = domain.Booking
 
 type BookingsRepository struct {
 	db  *store.DB
@@ -34,29 +25,230 @@ func NewBookingsRepository(db *store.DB, log *zap.Logger) *BookingsRepository {
 	return &BookingsRepository{db: db, log: log}
 }
 
-func (r *BookingsRepository) CreatePending(ctx context.Context, userID string, eventID string, idempotencyKey *string, seats []byte) (*Booking, error) {
-	query := `
-		INSERT INTO bookings (user_id, event_id, status, idempotency_key, payment_status, seats)
-		VALUES ($1, $2, 'pending', $3, 'pending', $4)
-		RETURNING id, created_at, updated_at, version`
+// ErrSalePhaseSoldOut is returned by CreatePendingIfAvailable when the booking's sale phase
+// has too few seats left.
+var ErrSalePhaseSoldOut = errors.New("sale phase is sold out")
+
+// CreatePending inserts a pending booking made through the source channel, sold in the sale
+// phase phaseID if the event is sold in phases. When another request already created a booking
+// for the event with the same idempotency key, the unique (event_id, idempotency_key)
+// constraint rejects the insert and that booking is returned instead with created false.
+// The rows of the chosen seats are locked first and the insert fails with ErrSeatsClaimed
+// with a SeatsClaimedError if a booking has any of them by then, such as one assigned by
+// CreatePendingAssigned.
+// announce's message is written to the outbox in the same transaction.
+func (r *BookingsRepository) CreatePending(ctx context.Context, userID string, eventID string, idempotencyKey *string, seats domain.Seats, source string, phaseID *string, announce Announce) (*Booking, bool, error) {
+	var booking *Booking
+	err := r.db.WithTx(ctx, func(tx pgx.Tx) error {
+		if err := claimSeats(ctx, tx, eventID, seats); err != nil {
+			return err
+		}
+		var err error
+		booking, err = insertPending(ctx, tx, userID, eventID, idempotencyKey, seats, source, phaseID, announce)
+		return err
+	})
+	if err != nil {
+		return r.existingOnConflict(ctx, err, eventID, idempotencyKey)
+	}
+
+	return booking, true, nil
+}
 
-	booking := &Booking{
+// insertPending inserts a pending booking and writes announce's message to the outbox.
+func insertPending(ctx context.Context, tx pgx.Tx, userID string, eventID string, idempotencyKey *string, seats domain.Seats, source string, phaseID *string, announce Announce) (*Booking, error) {
+	b := &Booking{
 		UserID:        userID,
 		EventID:       eventID,
-		Status:        "pending",
+		Status:        domain.BookingPending,
 		PaymentStatus: "pending",
 		Seats:         seats,
+		Source:        source,
+		SalePhaseID:   phaseID,
 	}
-
 	if idempotencyKey != nil {
-		booking.IdempotencyKey = *idempotencyKey
+		b.IdempotencyKey = *idempotencyKey
+	}
+	err := tx.QueryRow(ctx, `
+		INSERT INTO bookings (user_id, event_id, status, idempotency_key, payment_status, seats, source, sale_phase_id)
+		VALUES ($1, $2, 'pending', $3, 'pending', $4, $5, $6)
+		RETURNING id, created_at, updated_at, version
+	`, userID, eventID, idempotencyKey, seats, source, phaseID).Scan(&b.ID, &b.CreatedAt, &b.UpdatedAt, &b.Version)
+	if err != nil {
+		return nil, err
+	}
+	if err := announce.enqueue(ctx, tx, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// existingOnConflict turns a failed insert into the booking that already holds its
+// idempotency key, with created false, when the key's unique constraint is what failed.
+func (r *BookingsRepository) existingOnConflict(ctx context.Context, err error, eventID string, idempotencyKey *string) (*Booking, bool, error) {
+	if idempotencyKey != nil && store.IsUniqueViolation(err) {
+		existing, gerr := r.getByEventIdempotency(ctx, eventID, *idempotencyKey)
+		if gerr != nil {
+			return nil, false, gerr
+		}
+		if existing != nil {
+			return existing, false, nil
+		}
 	}
+	return nil, false, err
+}
 
-	err := r.db.Pool.QueryRow(ctx, query, userID, eventID, idempotencyKey, seats).
-		Scan(&booking.ID, &booking.CreatedAt, &booking.UpdatedAt, &booking.Version)
+// Announce builds the message announcing a new booking, which is written to the outbox in
+// the booking's transaction. A nil Announce writes none.
+type Announce func(b *Booking) (*store.OutboxMessage, error)
+
+func (a Announce) enqueue(ctx context.Context, tx pgx.Tx, b *Booking) error {
+	if a == nil {
+		return nil
+	}
+	m, err := a(b)
+	if err != nil {
+		return err
+	}
+	return store.EnqueueOutbox(ctx, tx, m)
+}
+
+// ErrNotPending is returned by Requeue for a booking that is no longer pending.
+var ErrNotPending = errors.New("booking is not pending")
+
+// Requeue writes announce's message for a pending booking to the outbox again, with the
+// booking's row locked so it isn't finalized or cancelled meanwhile. It returns nil if the
+// booking doesn't exist and ErrNotPending if it is no longer pending.
+func (r *BookingsRepository) Requeue(ctx context.Context, id string, announce Announce) (*Booking, error) {
+	var booking *Booking
+	err := r.db.WithTx(ctx, func(tx pgx.Tx) error {
+		var status domain.BookingStatus
+		err := tx.QueryRow(ctx, `SELECT status FROM bookings WHERE id = $1 FOR UPDATE`, id).Scan(&status)
+		if err == pgx.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if status != domain.BookingPending {
+			return ErrNotPending
+		}
+		// The row lock holds off writers, not this read
+		if booking, err = r.GetByID(ctx, id); err != nil || booking == nil {
+			return err
+		}
+		return announce.enqueue(ctx, tx, booking)
+	})
 	if err != nil {
 		return nil, err
 	}
+	return booking, nil
+}
+
+// CreatePendingIfAvailable is CreatePending with admission checked in Postgres instead of
+// Redis tokens: the event's event_capacity row is locked FOR UPDATE, so concurrent callers
+// admit one at a time, and the booking is only inserted if capacity minus the seats of
+// pending and booked bookings covers n. Without seats, n seats are assigned with pick as in
+// CreatePendingAssigned. It returns a nil booking when there is no room, and like
+// CreatePending returns the existing booking with created false on a key conflict and
+// writes announce's message to the outbox with the booking. A booking sold in sale phase
+// phaseID must also fit in what the phase has left, or it fails with ErrSalePhaseSoldOut.
+// It fails with store.ErrAdmissionRestored once admission is back on Redis, and holds off
+// the token rebuild until the booking commits.
+func (r *BookingsRepository) CreatePendingIfAvailable(ctx context.Context, userID string, eventID string, idempotencyKey *string, seats domain.Seats, source string, phaseID *string, n int, pick SeatPicker, announce Announce) (*Booking, bool, error) {
+	var booking *Booking
+	err := r.db.WithTx(ctx, func(tx pgx.Tx) error {
+		if err := store.HoldFallbackAdmission(ctx, tx); err != nil {
+			return err
+		}
+		// reconcile creates these rows too; an event booked only through Redis may not have one yet
+		_, err := tx.Exec(ctx, `
+			INSERT INTO event_capacity (event_id, capacity)
+			SELECT id, capacity FROM events WHERE id = $1
+			ON CONFLICT (event_id) DO NOTHING
+		`, eventID)
+		if err != nil {
+			return err
+		}
+
+		var locked string
+		err = tx.QueryRow(ctx, `SELECT event_id FROM event_capacity WHERE event_id = $1 FOR UPDATE`, eventID).Scan(&locked)
+		if err != nil {
+			return err
+		}
+
+		var available int
+		err = tx.QueryRow(ctx, `
+			SELECT e.capacity - COALESCE((
+				SELECT SUM(jsonb_array_length(COALESCE(b.seats, '[]'::jsonb)))
+				FROM bookings b
+				WHERE b.event_id = e.id AND b.status IN ('pending', 'booked')
+			), 0)
+			FROM events e
+			WHERE e.id = $1
+		`, eventID).Scan(&available)
+		if err != nil {
+			return err
+		}
+		if available < n {
+			return nil
+		}
+		if phaseID != nil {
+			// Other admissions wait on the event_capacity row, so the phase can't change under this
+			err = tx.QueryRow(ctx, `
+				SELECT p.quantity + p.rolled_in - p.rolled_out - COALESCE((
+					SELECT SUM(jsonb_array_length(COALESCE(b.seats, '[]'::jsonb)))
+					FROM bookings b
+					WHERE b.event_id = p.event_id AND b.sale_phase_id = p.id AND b.status IN ('pending', 'booked')
+				), 0)
+				FROM event_sale_phases p
+				WHERE p.id = $1
+			`, *phaseID).Scan(&available)
+			if err != nil {
+				return err
+			}
+			if available < n {
+				return ErrSalePhaseSoldOut
+			}
+		}
+
+		if seats == nil {
+			if seats, err = assignSeats(ctx, tx, eventID, n, pick); err != nil || seats == nil {
+				return err
+			}
+		} else if err := claimSeats(ctx, tx, eventID, seats); err != nil {
+			return err
+		}
+		booking, err = insertPending(ctx, tx, userID, eventID, idempotencyKey, seats, source, phaseID, announce)
+		return err
+	})
+	if err != nil {
+		return r.existingOnConflict(ctx, err, eventID, idempotencyKey)
+	}
+	return booking, booking != nil, nil
+}
+
+// getByEventIdempotency looks a key up within one event, which the unique constraint's
+// index (and partition pruning) serve directly.
+func (r *BookingsRepository) getByEventIdempotency(ctx context.Context, eventID, key string) (*Booking, error) {
+	query := `
+		SELECT id, user_id, event_id, status, seats, idempotency_key, amount_paid, 
+		       payment_status, source, currency, amount_due, display_currency, display_amount, fx_rate, fx_as_of, sale_phase_id, created_at, updated_at, version
+		FROM bookings
+		WHERE event_id = $1 AND idempotency_key = $2`
+
+	booking := &Booking{}
+	err := r.db.Pool.QueryRow(ctx, query, eventID, key).Scan(
+		&booking.ID, &booking.UserID, &booking.EventID, &booking.Status,
+		&booking.Seats, &booking.IdempotencyKey, &booking.AmountPaid,
+		&booking.PaymentStatus, &booking.Source, &booking.Currency, &booking.AmountDue,
+		&booking.DisplayCurrency, &booking.DisplayAmount, &booking.FXRate, &booking.FXAsOf, &booking.SalePhaseID, &booking.CreatedAt, &booking.UpdatedAt, &booking.Version,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
 
 	return booking, nil
 }
@@ -64,7 +256,7 @@ func (r *BookingsRepository) CreatePending(ctx context.Context, userID string, e
 func (r *BookingsRepository) GetByID(ctx context.Context, id string) (*Booking, error) {
 	query := `
 		SELECT id, user_id, event_id, status, seats, idempotency_key, amount_paid, 
-		       payment_status, created_at, updated_at, version
+		       payment_status, source, currency, amount_due, display_currency, display_amount, fx_rate, fx_as_of, sale_phase_id, created_at, updated_at, version
 		FROM bookings
 		WHERE id = $1`
 
@@ -72,7 +264,8 @@ func (r *BookingsRepository) GetByID(ctx context.Context, id string) (*Booking,
 	err := r.db.Pool.QueryRow(ctx, query, id).Scan(
 		&booking.ID, &booking.UserID, &booking.EventID, &booking.Status,
 		&booking.Seats, &booking.IdempotencyKey, &booking.AmountPaid,
-		&booking.PaymentStatus, &booking.CreatedAt, &booking.UpdatedAt, &booking.Version,
+		&booking.PaymentStatus, &booking.Source, &booking.Currency, &booking.AmountDue,
+		&booking.DisplayCurrency, &booking.DisplayAmount, &booking.FXRate, &booking.FXAsOf, &booking.SalePhaseID, &booking.CreatedAt, &booking.UpdatedAt, &booking.Version,
 	)
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -87,7 +280,7 @@ func (r *BookingsRepository) GetByID(ctx context.Context, id string) (*Booking,
 func (r *BookingsRepository) GetByIdempotency(ctx context.Context, key string) (*Booking, error) {
 	query := `
 		SELECT id, user_id, event_id, status, seats, idempotency_key, amount_paid, 
-		       payment_status, created_at, updated_at, version
+		       payment_status, source, currency, amount_due, display_currency, display_amount, fx_rate, fx_as_of, sale_phase_id, created_at, updated_at, version
 		FROM bookings
 		WHERE idempotency_key = $1`
 
@@ -95,7 +288,8 @@ func (r *BookingsRepository) GetByIdempotency(ctx context.Context, key string) (
 	err := r.db.Pool.QueryRow(ctx, query, key).Scan(
 		&booking.ID, &booking.UserID, &booking.EventID, &booking.Status,
 		&booking.Seats, &booking.IdempotencyKey, &booking.AmountPaid,
-		&booking.PaymentStatus, &booking.CreatedAt, &booking.UpdatedAt, &booking.Version,
+		&booking.PaymentStatus, &booking.Source, &booking.Currency, &booking.AmountDue,
+		&booking.DisplayCurrency, &booking.DisplayAmount, &booking.FXRate, &booking.FXAsOf, &booking.SalePhaseID, &booking.CreatedAt, &booking.UpdatedAt, &booking.Version,
 	)
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -107,16 +301,36 @@ func (r *BookingsRepository) GetByIdempotency(ctx context.Context, key string) (
 	return booking, nil
 }
 
-func (r *BookingsRepository) ListByUser(ctx context.Context, userID string, limit, offset int) ([]*Booking, error) {
+// UserPosition is where a page of a user's bookings ends: the created_at and ID of its
+// last booking.
+type UserPosition struct {
+	CreatedAt time.Time `json:"c"`
+	ID        string    `json:"id"`
+}
+
+// Position returns the position just after b in its user's bookings.
+func Position(b *Booking) *UserPosition {
+	return &UserPosition{CreatedAt: b.CreatedAt, ID: b.ID}
+}
+
+// ListByUser pages through the user's bookings, newest first. after, if not nil, starts
+// the page past a previous one.
+func (r *BookingsRepository) ListByUser(ctx context.Context, userID string, limit, offset int, after *UserPosition) ([]*Booking, error) {
+	var afterAt *time.Time
+	var afterID *string
+	if after != nil {
+		afterAt, afterID = &after.CreatedAt, &after.ID
+	}
 	query := `
 		SELECT id, user_id, event_id, status, seats, idempotency_key, amount_paid, 
-		       payment_status, created_at, updated_at, version
+		       payment_status, source, currency, amount_due, display_currency, display_amount, fx_rate, fx_as_of, sale_phase_id, created_at, updated_at, version
 		FROM bookings
 		WHERE user_id = $1
-		ORDER BY created_at DESC
+		  AND ($4::timestamptz IS NULL OR (created_at, id) < ($4, $5::uuid))
+		ORDER BY created_at DESC, id DESC
 		LIMIT $2 OFFSET $3`
 
-	rows, err := r.db.Pool.Query(ctx, query, userID, limit, offset)
+	rows, err := r.db.Pool.Query(ctx, query, userID, limit, offset, afterAt, afterID)
 	if err != nil {
 		return nil, err
 	}
@@ -128,7 +342,8 @@ func (r *BookingsRepository) ListByUser(ctx context.Context, userID string, limi
 		err := rows.Scan(
 			&booking.ID, &booking.UserID, &booking.EventID, &booking.Status,
 			&booking.Seats, &booking.IdempotencyKey, &booking.AmountPaid,
-			&booking.PaymentStatus, &booking.CreatedAt, &booking.UpdatedAt, &booking.Version,
+			&booking.PaymentStatus, &booking.Source, &booking.Currency, &booking.AmountDue,
+			&booking.DisplayCurrency, &booking.DisplayAmount, &booking.FXRate, &booking.FXAsOf, &booking.SalePhaseID, &booking.CreatedAt, &booking.UpdatedAt, &booking.Version,
 		)
 		if err != nil {
 			return nil, err
@@ -142,7 +357,7 @@ func (r *BookingsRepository) ListByUser(ctx context.Context, userID string, limi
 func (r *BookingsRepository) ListByEvent(ctx context.Context, eventID string, limit, offset int) ([]*Booking, error) {
 	query := `
 		SELECT id, user_id, event_id, status, seats, idempotency_key, amount_paid, 
-		       payment_status, created_at, updated_at, version
+		       payment_status, source, currency, amount_due, display_currency, display_amount, fx_rate, fx_as_of, sale_phase_id, created_at, updated_at, version
 		FROM bookings
 		WHERE event_id = $1
 		ORDER BY created_at DESC
@@ -160,7 +375,8 @@ func (r *BookingsRepository) ListByEvent(ctx context.Context, eventID string, li
 		err := rows.Scan(
 			&booking.ID, &booking.UserID, &booking.EventID, &booking.Status,
 			&booking.Seats, &booking.IdempotencyKey, &booking.AmountPaid,
-			&booking.PaymentStatus, &booking.CreatedAt, &booking.UpdatedAt, &booking.Version,
+			&booking.PaymentStatus, &booking.Source, &booking.Currency, &booking.AmountDue,
+			&booking.DisplayCurrency, &booking.DisplayAmount, &booking.FXRate, &booking.FXAsOf, &booking.SalePhaseID, &booking.CreatedAt, &booking.UpdatedAt, &booking.Version,
 		)
 		if err != nil {
 			return nil, err
@@ -171,6 +387,73 @@ func (r *BookingsRepository) ListByEvent(ctx context.Context, eventID string, li
 	return bookings, nil
 }
 
+// ListPaidByEvent returns every paid booking of the event, oldest first.
+func (r *BookingsRepository) ListPaidByEvent(ctx context.Context, eventID string) ([]*Booking, error) {
+	query := `
+		SELECT id, user_id, event_id, status, seats, COALESCE(idempotency_key, ''), amount_paid,
+		       payment_status, source, currency, amount_due, display_currency, display_amount, fx_rate, fx_as_of, sale_phase_id, created_at, updated_at, version
+		FROM bookings
+		WHERE event_id = $1 AND payment_status = 'paid'
+		ORDER BY created_at`
+
+	rows, err := r.db.Pool.Query(ctx, query, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bookings []*Booking
+	for rows.Next() {
+		booking := &Booking{}
+		err := rows.Scan(
+			&booking.ID, &booking.UserID, &booking.EventID, &booking.Status,
+			&booking.Seats, &booking.IdempotencyKey, &booking.AmountPaid,
+			&booking.PaymentStatus, &booking.Source, &booking.Currency, &booking.AmountDue,
+			&booking.DisplayCurrency, &booking.DisplayAmount, &booking.FXRate, &booking.FXAsOf, &booking.SalePhaseID, &booking.CreatedAt, &booking.UpdatedAt, &booking.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+		bookings = append(bookings, booking)
+	}
+
+	return bookings, rows.Err()
+}
+
+// ListPending returns every booking still waiting for payment, oldest first. Bookings whose
+// payment link was deferred have no payment window running yet and are left out.
+func (r *BookingsRepository) ListPending(ctx context.Context) ([]*Booking, error) {
+	query := `
+		SELECT id, user_id, event_id, status, seats, COALESCE(idempotency_key, ''), amount_paid,
+		       payment_status, source, currency, amount_due, display_currency, display_amount, fx_rate, fx_as_of, sale_phase_id, created_at, updated_at, version
+		FROM bookings
+		WHERE status = 'pending' AND payment_deferred_at IS NULL
+		ORDER BY created_at`
+
+	rows, err := r.db.Pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bookings []*Booking
+	for rows.Next() {
+		booking := &Booking{}
+		err := rows.Scan(
+			&booking.ID, &booking.UserID, &booking.EventID, &booking.Status,
+			&booking.Seats, &booking.IdempotencyKey, &booking.AmountPaid,
+			&booking.PaymentStatus, &booking.Source, &booking.Currency, &booking.AmountDue,
+			&booking.DisplayCurrency, &booking.DisplayAmount, &booking.FXRate, &booking.FXAsOf, &booking.SalePhaseID, &booking.CreatedAt, &booking.UpdatedAt, &booking.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+		bookings = append(bookings, booking)
+	}
+
+	return bookings, rows.Err()
+}
+
 func (r *BookingsRepository) UpdateStatus(ctx context.Context, id, status string) error {
 	query := `UPDATE bookings SET status = $1, updated_at = now() WHERE id = $2`
 
@@ -204,7 +487,197 @@ func (r *BookingsRepository) UpdatePaymentStatus(ctx context.Context, id, paymen
 	return nil
 }
 
-func (r *BookingsRepository) UpdateSeats(ctx context.Context, id string, seats []byte) error {
+// SetPricing records what the booking will be charged and, when the buyer prefers another
+// currency, the converted amount they were shown.
+func (r *BookingsRepository) SetPricing(ctx context.Context, id string, b *Booking) error {
+	query := `
+		UPDATE bookings
+		SET currency = $1, amount_due = $2, display_currency = $3, display_amount = $4, fx_rate = $5, fx_as_of = $6, updated_at = now()
+		WHERE id = $7`
+
+	result, err := r.db.Pool.Exec(ctx, query, b.Currency, b.AmountDue, b.DisplayCurrency, b.DisplayAmount, b.FXRate, b.FXAsOf, id)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// DeferPayment marks a pending booking as held without a payment link, keeping the time it
+// was first deferred. It reports false if the booking is no longer pending.
+func (r *BookingsRepository) DeferPayment(ctx context.Context, id string) (bool, error) {
+	query := `
+		UPDATE bookings
+		SET payment_deferred_at = COALESCE(payment_deferred_at, now()), payment_resume_claimed_at = NULL, updated_at = now()
+		WHERE id = $1 AND status = 'pending'`
+
+	result, err := r.db.Pool.Exec(ctx, query, id)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// DeferredBooking is a pending booking whose payment link is waiting for the payment
+// service to recover.
+type DeferredBooking struct {
+	Booking
+	DeferredAt time.Time `json:"deferred_at"`
+}
+
+// ClaimDeferred leases up to limit pending bookings deferred before deferredBefore, oldest
+// first, so only one worker sends each payment link. A lease left by a crashed worker runs
+// out after lease.
+func (r *BookingsRepository) ClaimDeferred(ctx context.Context, deferredBefore time.Time, limit int, lease time.Duration) ([]*DeferredBooking, error) {
+	query := `
+		-- name: bookings_claim_deferred
+		UPDATE bookings b
+		SET payment_resume_claimed_at = now()
+		FROM (
+			SELECT id FROM bookings
+			WHERE status = 'pending' AND payment_deferred_at IS NOT NULL AND payment_deferred_at < $1
+			  AND (payment_resume_claimed_at IS NULL OR payment_resume_claimed_at < now() - make_interval(secs => $3))
+			ORDER BY payment_deferred_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		) due
+		WHERE b.id = due.id
+		RETURNING b.id, b.user_id, b.event_id, b.status, b.seats, COALESCE(b.idempotency_key, ''), b.amount_paid,
+		          b.payment_status, b.source, b.currency, b.amount_due, b.display_currency, b.display_amount, b.fx_rate, b.fx_as_of, b.sale_phase_id,
+		          b.created_at, b.updated_at, b.version, b.payment_deferred_at`
+
+	rows, err := r.db.Pool.Query(ctx, query, deferredBefore, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deferred []*DeferredBooking
+	for rows.Next() {
+		d := &DeferredBooking{}
+		err := rows.Scan(
+			&d.ID, &d.UserID, &d.EventID, &d.Status,
+			&d.Seats, &d.IdempotencyKey, &d.AmountPaid,
+			&d.PaymentStatus, &d.Source, &d.Currency, &d.AmountDue,
+			&d.DisplayCurrency, &d.DisplayAmount, &d.FXRate, &d.FXAsOf, &d.SalePhaseID, &d.CreatedAt, &d.UpdatedAt, &d.Version, &d.DeferredAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		deferred = append(deferred, d)
+	}
+
+	return deferred, rows.Err()
+}
+
+// ResumePayment clears a claimed booking's deferral once its payment link has gone out. The
+// payment window starts now, which updated_at records for redis_rebuild.
+func (r *BookingsRepository) ResumePayment(ctx context.Context, id string) error {
+	query := `
+		UPDATE bookings
+		SET payment_deferred_at = NULL, payment_resume_claimed_at = NULL, updated_at = now()
+		WHERE id = $1`
+
+	_, err := r.db.Pool.Exec(ctx, query, id)
+	return err
+}
+
+// ReleaseDeferred gives up a claim so the booking is picked up again on a later pass.
+func (r *BookingsRepository) ReleaseDeferred(ctx context.Context, id string) error {
+	_, err := r.db.Pool.Exec(ctx, `UPDATE bookings SET payment_resume_claimed_at = NULL WHERE id = $1`, id)
+	return err
+}
+
+// SetPaymentProvider records the provider reporting on a pending booking's payment. It is
+// a no-op once the booking has left pending.
+func (r *BookingsRepository) SetPaymentProvider(ctx context.Context, id, provider string) error {
+	_, err := r.db.Pool.Exec(ctx, `UPDATE bookings SET payment_provider = $2 WHERE id = $1 AND status = 'pending'`, id, provider)
+	return err
+}
+
+// PendingAge is how many pending bookings, and seats they hold, are in an age bucket for
+// a payment provider. Provider is empty for bookings no provider has reported on yet.
+type PendingAge struct {
+	Provider string
+	Age      string
+	Bookings int
+	Seats    int
+}
+
+// PendingAges buckets pending bookings by payment provider and age: under 5 minutes
+// ("0-5m"), 5 to 10 ("5-10m") and older ("10m+").
+func (r *BookingsRepository) PendingAges(ctx context.Context) ([]*PendingAge, error) {
+	query := `
+		-- name: bookings_pending_ages
+		SELECT COALESCE(payment_provider, ''),
+		       CASE WHEN created_at > now() - interval '5 minutes' THEN '0-5m'
+		            WHEN created_at > now() - interval '10 minutes' THEN '5-10m'
+		            ELSE '10m+' END AS age,
+		       count(*), COALESCE(sum(CASE WHEN jsonb_typeof(seats) = 'array' THEN jsonb_array_length(seats) END), 0)
+		FROM bookings
+		WHERE status = 'pending'
+		GROUP BY 1, 2`
+
+	rows, err := r.db.Pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ages []*PendingAge
+	for rows.Next() {
+		a := &PendingAge{}
+		if err := rows.Scan(&a.Provider, &a.Age, &a.Bookings, &a.Seats); err != nil {
+			return nil, err
+		}
+		ages = append(ages, a)
+	}
+	return ages, rows.Err()
+}
+
+// Conversion is how many of the bookings created in a window for a payment provider have
+// left pending (Resolved) and how many of those were paid for (Converted).
+type Conversion struct {
+	Provider  string
+	Resolved  int
+	Converted int
+}
+
+// ConversionSince counts the bookings created since since that have been paid for, cancelled
+// or expired, by payment provider. Waitlisted and still pending bookings aren't counted.
+func (r *BookingsRepository) ConversionSince(ctx context.Context, since time.Time) ([]*Conversion, error) {
+	query := `
+		-- name: bookings_conversion_since
+		SELECT COALESCE(payment_provider, ''),
+		       count(*),
+		       count(*) FILTER (WHERE status = 'booked')
+		FROM bookings
+		WHERE created_at >= $1 AND status IN ('booked', 'cancelled', 'expired')
+		  AND event_id NOT IN (SELECT id FROM events WHERE sandbox)
+		GROUP BY 1`
+
+	rows, err := r.db.Pool.Query(ctx, query, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*Conversion
+	for rows.Next() {
+		c := &Conversion{}
+		if err := rows.Scan(&c.Provider, &c.Resolved, &c.Converted); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+func (r *BookingsRepository) UpdateSeats(ctx context.Context, id string, seats domain.Seats) error {
 	query := `UPDATE bookings SET seats = $1, updated_at = now() WHERE id = $2`
 
 	result, err := r.db.Pool.Exec(ctx, query, seats, id)
@@ -219,6 +692,193 @@ func (r *BookingsRepository) UpdateSeats(ctx context.Context, id string, seats [
 	return nil
 }
 
+// ErrNotActive is returned by Reseat for a booking that is no longer pending or booked.
+var ErrNotActive = errors.New("booking is not pending or booked")
+
+// Reseat is the outcome of moving a booking to other seats. When Taken or Unknown name any
+// of the new seats, nothing was changed.
+type Reseat struct {
+	EventID string
+	UserID  string
+	Status  domain.BookingStatus
+	From    domain.Seats
+	Taken   []string // held by the seat map or another pending or booked booking
+	Unknown []string // not on the event's seat map
+}
+
+// Reseat moves a pending or booked booking to seats, locking the new seats' rows so no
+// booking can take them meanwhile, and records the move with actorID and reason as a
+// 'reseated' row in booking_audit. A booked booking's old seats go back to available and
+// its new ones are booked; a pending booking only has its seat list changed, which
+// finalization books once it's paid for. It returns nil if the booking doesn't exist.
+func (r *BookingsRepository) Reseat(ctx context.Context, id string, seats []string, actorID, reason string) (*Reseat, error) {
+	var res *Reseat
+	err := r.db.WithTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		res, err = moveSeats(ctx, tx, id, seats)
+		if err != nil || res == nil || len(res.Taken) > 0 || len(res.Unknown) > 0 {
+			return err
+		}
+		payload, err := json.Marshal(map[string]any{"from": res.From, "to": seats, "reason": reason, "actor_id": actorID})
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO booking_audit (booking_id, event_id, user_id, action, payload)
+			VALUES ($1, $2, $3, 'reseated', $4)`, id, res.EventID, res.UserID, payload)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// SeatChange is what a booking is charged once its owner changes seats: AmountDue is the
+// price of the new seats and AmountPaid, for a paid booking, what it has paid after any
+// extra charge or partial refund.
+type SeatChange struct {
+	AmountDue  float64
+	AmountPaid *float64
+}
+
+// ChangeSeats is Reseat for the booking's owner changing seats: the move is priced by
+// change and recorded as a 'seats_changed' row in booking_audit with the old and new
+// amounts. It returns nil if the booking doesn't exist.
+func (r *BookingsRepository) ChangeSeats(ctx context.Context, id string, seats []string, change SeatChange) (*Reseat, error) {
+	var res *Reseat
+	err := r.db.WithTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		res, err = moveSeats(ctx, tx, id, seats)
+		if err != nil || res == nil || len(res.Taken) > 0 || len(res.Unknown) > 0 {
+			return err
+		}
+		var fromDue, fromPaid float64
+		err = tx.QueryRow(ctx, `
+			UPDATE bookings b
+			SET amount_due = $2,
+			    amount_paid = COALESCE($3, b.amount_paid),
+			    display_amount = CASE WHEN b.fx_rate IS NULL THEN b.display_amount ELSE round(($2 * b.fx_rate)::numeric, 2) END
+			FROM bookings old
+			WHERE b.id = $1 AND old.id = b.id
+			RETURNING old.amount_due, old.amount_paid`, id, change.AmountDue, change.AmountPaid).Scan(&fromDue, &fromPaid)
+		if err != nil {
+			return err
+		}
+		audit := map[string]any{"from": res.From, "to": seats, "amount_due": map[string]float64{"from": fromDue, "to": change.AmountDue}}
+		if change.AmountPaid != nil {
+			audit["amount_paid"] = map[string]float64{"from": fromPaid, "to": *change.AmountPaid}
+		}
+		payload, err := json.Marshal(audit)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO booking_audit (booking_id, event_id, user_id, action, payload)
+			VALUES ($1, $2, $3, 'seats_changed', $4)`, id, res.EventID, res.UserID, payload)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// moveSeats moves the booking to seats within tx, as described on Reseat. When Taken or
+// Unknown name any of the seats, nothing was changed.
+func moveSeats(ctx context.Context, tx pgx.Tx, id string, seats []string) (*Reseat, error) {
+	out := Reseat{}
+	err := tx.QueryRow(ctx, `SELECT event_id, user_id, status, seats FROM bookings WHERE id = $1 FOR UPDATE`, id).
+		Scan(&out.EventID, &out.UserID, &out.Status, &out.From)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !out.Status.Active() {
+		return nil, ErrNotActive
+	}
+	rows, err := tx.Query(ctx, `
+		SELECT seat_label, status <> 'available' AND held_by_booking IS DISTINCT FROM $3::uuid
+		FROM seats
+		WHERE event_id = $1 AND seat_label = ANY($2::text[])
+		FOR UPDATE`, out.EventID, seats, id)
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[string]bool, len(seats))
+	for rows.Next() {
+		var label string
+		var taken bool
+		if err := rows.Scan(&label, &taken); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		found[label] = true
+		if taken {
+			out.Taken = append(out.Taken, label)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, label := range seats {
+		if !found[label] {
+			out.Unknown = append(out.Unknown, label)
+		}
+	}
+
+	// Pending bookings claim seats before the seat map shows them
+	rows, err = tx.Query(ctx, `
+		SELECT DISTINCT l
+		FROM unnest($2::text[]) AS l
+		JOIN bookings b ON b.event_id = $1 AND b.status IN ('pending', 'booked') AND b.seats ? l
+		WHERE b.id <> $3
+		ORDER BY l`, out.EventID, seats, id)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var label string
+		if err := rows.Scan(&label); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		out.Taken = append(out.Taken, label)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(out.Taken) > 0 || len(out.Unknown) > 0 {
+		return &out, nil
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE bookings SET seats = $2, updated_at = now() WHERE id = $1`, id, domain.Seats(seats)); err != nil {
+		return nil, err
+	}
+	if out.Status == domain.BookingBooked {
+		_, err = tx.Exec(ctx, `
+			UPDATE seats
+			SET status = 'available', held_by_booking = NULL, held_until = NULL, updated_at = now()
+			WHERE event_id = $1 AND held_by_booking = $2 AND NOT (seat_label = ANY($3::text[]))`, out.EventID, id, seats)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.Exec(ctx, `
+			UPDATE seats
+			SET status = 'booked', held_by_booking = $2, held_until = NULL, updated_at = now()
+			WHERE event_id = $1 AND seat_label = ANY($3::text[])`, out.EventID, id, seats)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &out, nil
+}
+
 func (r *BookingsRepository) CancelBookingTx(ctx context.Context, bookingID string) (*Booking, bool, error) {
 	tx, err := r.db.Pool.Begin(ctx)
 	if err != nil {
@@ -230,20 +890,21 @@ func (r *BookingsRepository) CancelBookingTx(ctx context.Context, bookingID stri
 	var booking Booking
 	err = tx.QueryRow(ctx, `
 		SELECT id, user_id, event_id, status, seats, idempotency_key, amount_paid, 
-		       payment_status, created_at, updated_at, version
+		       payment_status, source, currency, amount_due, display_currency, display_amount, fx_rate, fx_as_of, sale_phase_id, created_at, updated_at, version
 		FROM bookings
 		WHERE id = $1
 	`, bookingID).Scan(
 		&booking.ID, &booking.UserID, &booking.EventID, &booking.Status,
 		&booking.Seats, &booking.IdempotencyKey, &booking.AmountPaid,
-		&booking.PaymentStatus, &booking.CreatedAt, &booking.UpdatedAt, &booking.Version,
+		&booking.PaymentStatus, &booking.Source, &booking.Currency, &booking.AmountDue,
+		&booking.DisplayCurrency, &booking.DisplayAmount, &booking.FXRate, &booking.FXAsOf, &booking.SalePhaseID, &booking.CreatedAt, &booking.UpdatedAt, &booking.Version,
 	)
 	if err != nil {
 		return nil, false, err
 	}
 
 	// Check if booking was actually booked (not just pending)
-	wasBooked := booking.Status == "booked"
+	wasBooked := booking.Status == domain.BookingBooked
 
 	// Update booking status
 	_, err = tx.Exec(ctx, `
@@ -267,23 +928,15 @@ func (r *BookingsRepository) CancelBookingTx(ctx context.Context, bookingID stri
 		}
 
 		// Release seats - mark them as available again
-		var seatLabels []string
-		if len(booking.Seats) > 0 {
-			err = json.Unmarshal(booking.Seats, &seatLabels)
+		for _, seatLabel := range booking.Seats {
+			_, err = tx.Exec(ctx, `
+			UPDATE seats 
+			SET status = 'available', held_by_booking = NULL, held_until = NULL, updated_at = now()
+			WHERE event_id = $1 AND seat_label = $2
+		`, booking.EventID, seatLabel)
 			if err != nil {
 				return nil, false, err
 			}
-
-			for _, seatLabel := range seatLabels {
-				_, err = tx.Exec(ctx, `
-				UPDATE seats 
-				SET status = 'available', held_by_booking = NULL, held_until = NULL, updated_at = now()
-				WHERE event_id = $1 AND seat_label = $2
-			`, booking.EventID, seatLabel)
-				if err != nil {
-					return nil, false, err
-				}
-			}
 		}
 	}
 
@@ -292,11 +945,13 @@ func (r *BookingsRepository) CancelBookingTx(ctx context.Context, bookingID stri
 		return nil, false, err
 	}
 
-	booking.Status = "cancelled"
+	booking.Status = domain.BookingCancelled
 	return &booking, wasBooked, nil
 }
 
-func (r *BookingsRepository) FinalizeBooking(ctx context.Context, bookingID string, seats []byte, amountPaid float64) error {
+// FinalizeBooking books a pending booking's seats, recording its payment as paymentStatus:
+// paid, or authorized when the charge is only captured later.
+func (r *BookingsRepository) FinalizeBooking(ctx context.Context, bookingID string, seats domain.Seats, amountPaid float64, paymentStatus string) error {
 	return r.db.WithTx(ctx, func(tx pgx.Tx) error {
 		// Get event_id for updating seats table
 		var eventID string
@@ -308,32 +963,23 @@ func (r *BookingsRepository) FinalizeBooking(ctx context.Context, bookingID stri
 		// Update booking
 		_, err = tx.Exec(ctx, `
 		UPDATE bookings 
-		SET status = 'booked', seats = $1, amount_paid = $2, payment_status = 'paid', updated_at = now() 
+		SET status = 'booked', seats = $1, amount_paid = $2, payment_status = $4, updated_at = now() 
 		WHERE id = $3 AND status = 'pending'
-	`, seats, amountPaid, bookingID)
+	`, seats, amountPaid, bookingID, paymentStatus)
 		if err != nil {
 			return err
 		}
 
-		// Update seats table - mark seats as booked
-		// Parse seats JSON and update each seat individually
-		var seatLabels []string
-		if len(seats) > 0 {
-			err = json.Unmarshal(seats, &seatLabels)
+		// Update seats table - mark seats as booked, one at a time
+		for _, seatLabel := range seats {
+			_, err = tx.Exec(ctx, `
+			UPDATE seats 
+			SET status = 'booked', held_by_booking = $1, held_until = NULL, updated_at = now()
+			WHERE event_id = $2 AND seat_label = $3
+		`, bookingID, eventID, seatLabel)
 			if err != nil {
 				return err
 			}
-
-			for _, seatLabel := range seatLabels {
-				_, err = tx.Exec(ctx, `
-				UPDATE seats 
-				SET status = 'booked', held_by_booking = $1, held_until = NULL, updated_at = now()
-				WHERE event_id = $2 AND seat_label = $3
-			`, bookingID, eventID, seatLabel)
-				if err != nil {
-					return err
-				}
-			}
 		}
 
 		// Update event reserved count
@@ -349,6 +995,28 @@ func (r *BookingsRepository) FinalizeBooking(ctx context.Context, bookingID stri
 	})
 }
 
+// GetStatuses returns the status of each of ids that belongs to userID, keyed by booking ID.
+// Bookings of other users are left out as if they didn't exist.
+func (r *BookingsRepository) GetStatuses(ctx context.Context, userID string, ids []string) (map[string]string, error) {
+	query := `SELECT id, status FROM bookings WHERE id = ANY($1::uuid[]) AND user_id = $2`
+
+	rows, err := r.db.Pool.Query(ctx, query, ids, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	statuses := make(map[string]string, len(ids))
+	for rows.Next() {
+		var id, status string
+		if err := rows.Scan(&id, &status); err != nil {
+			return nil, err
+		}
+		statuses[id] = status
+	}
+	return statuses, rows.Err()
+}
+
 func (r *BookingsRepository) GetBookingStatus(ctx context.Context, bookingID string) (string, error) {
 	query := `SELECT status FROM bookings WHERE id = $1`
 
@@ -0,0 +1,87 @@
+package bookings
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/storetest"
+)
+
+func TestCreatePendingConcurrentIdempotencyKey(t *testing.T) {
+	db := storetest.DB(t)
+	ctx := context.Background()
+
+	var userID, eventID string
+	err := db.Pool.QueryRow(ctx, `INSERT INTO users (email) VALUES ($1) RETURNING id`, uuid.NewString()+"@example.com").Scan(&userID)
+	if err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+	err = db.Pool.QueryRow(ctx, `INSERT INTO events (name, capacity, start_time) VALUES ('Idempotency', 100, $1) RETURNING id`,
+		time.Now().Add(24*time.Hour)).Scan(&eventID)
+	if err != nil {
+		t.Fatalf("insert event: %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = db.Pool.Exec(ctx, `DELETE FROM bookings WHERE event_id = $1`, eventID)
+		_, _ = db.Pool.Exec(ctx, `DELETE FROM events WHERE id = $1`, eventID)
+		_, _ = db.Pool.Exec(ctx, `DELETE FROM users WHERE id = $1`, userID)
+	})
+
+	const callers = 16
+	repo := NewBookingsRepository(db, zap.NewNop())
+	key := uuid.NewString()
+	type result struct {
+		booking *Booking
+		created bool
+		err     error
+	}
+	results := make([]result, callers)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			b, created, err := repo.CreatePending(ctx, userID, eventID, &key, nil, "web", nil, nil)
+			results[i] = result{b, created, err}
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	created := 0
+	var id string
+	for i, r := range results {
+		if r.err != nil {
+			t.Fatalf("caller %d: %v", i, r.err)
+		}
+		if r.booking == nil {
+			t.Fatalf("caller %d: no booking", i)
+		}
+		if r.created {
+			created++
+		}
+		if id == "" {
+			id = r.booking.ID
+		} else if r.booking.ID != id {
+			t.Errorf("caller %d got booking %s, want %s", i, r.booking.ID, id)
+		}
+	}
+	if created != 1 {
+		t.Errorf("%d callers created a booking, want 1", created)
+	}
+
+	var n int
+	if err := db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM bookings WHERE event_id = $1 AND idempotency_key = $2`, eventID, key).Scan(&n); err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("%d bookings hold the key, want 1", n)
+	}
+}
@@ -0,0 +1,155 @@
+package bookings
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/domain"
+)
+
+const (
+	IssueSourcePayment = "payment"
+	IssueSourceCheck   = "check"
+)
+
+// SeatIssue is a booking whose seats can't be trusted: Stored is what the booking says it
+// holds, Assigned what the seats table has booked for it.
+type SeatIssue struct {
+	BookingID  string       `json:"booking_id"`
+	EventID    string       `json:"event_id"`
+	Source     string       `json:"source"`
+	Reason     string       `json:"reason"`
+	Stored     domain.Seats `json:"stored_seats"`
+	Assigned   domain.Seats `json:"assigned_seats"`
+	DetectedAt time.Time    `json:"detected_at"`
+	LastSeenAt time.Time    `json:"last_seen_at"`
+	ResolvedAt *time.Time   `json:"resolved_at,omitempty"`
+}
+
+const seatIssueColumns = `booking_id, event_id, source, reason, stored_seats, assigned_seats, detected_at, last_seen_at, resolved_at`
+
+func scanSeatIssue(row pgx.Row) (*SeatIssue, error) {
+	i := &SeatIssue{}
+	err := row.Scan(&i.BookingID, &i.EventID, &i.Source, &i.Reason, &i.Stored, &i.Assigned, &i.DetectedAt, &i.LastSeenAt, &i.ResolvedAt)
+	if err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+// SeatMismatches returns every booked booking whose stored seats differ from the seats the
+// seats table has booked for it, such as a booking finalized with a label its event's seat
+// map doesn't have.
+func (r *BookingsRepository) SeatMismatches(ctx context.Context) ([]*SeatIssue, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		WITH stored AS (
+			SELECT b.id, b.event_id,
+			       COALESCE((SELECT jsonb_agg(l ORDER BY l)
+			                 FROM jsonb_array_elements_text(CASE WHEN jsonb_typeof(b.seats) = 'array' THEN b.seats ELSE '[]'::jsonb END) AS l),
+			                '[]'::jsonb) AS seats
+			FROM bookings b
+			WHERE b.status = 'booked'
+		), assigned AS (
+			SELECT held_by_booking AS id, jsonb_agg(seat_label ORDER BY seat_label) AS seats
+			FROM seats
+			WHERE status = 'booked' AND held_by_booking IS NOT NULL
+			GROUP BY held_by_booking
+		)
+		SELECT s.id, s.event_id, s.seats, COALESCE(a.seats, '[]'::jsonb)
+		FROM stored s
+		LEFT JOIN assigned a ON a.id = s.id
+		WHERE s.seats <> COALESCE(a.seats, '[]'::jsonb)
+		ORDER BY s.event_id, s.id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []*SeatIssue{}
+	for rows.Next() {
+		i := &SeatIssue{Source: IssueSourceCheck}
+		if err := rows.Scan(&i.BookingID, &i.EventID, &i.Stored, &i.Assigned); err != nil {
+			return nil, err
+		}
+		out = append(out, i)
+	}
+	return out, rows.Err()
+}
+
+// FlagSeatIssue records issue, or refreshes the booking's open issue, and reports whether
+// the issue is new: the booking had none open.
+func (r *BookingsRepository) FlagSeatIssue(ctx context.Context, issue *SeatIssue) (bool, error) {
+	stored, assigned := issue.Stored, issue.Assigned
+	if stored == nil {
+		stored = domain.Seats{}
+	}
+	if assigned == nil {
+		assigned = domain.Seats{}
+	}
+	var opened bool
+	err := r.db.Pool.QueryRow(ctx, `
+		INSERT INTO seat_integrity_issues AS i (booking_id, event_id, source, reason, stored_seats, assigned_seats)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (booking_id) DO UPDATE
+		SET source = EXCLUDED.source, reason = EXCLUDED.reason,
+		    stored_seats = EXCLUDED.stored_seats, assigned_seats = EXCLUDED.assigned_seats,
+		    last_seen_at = now(),
+		    detected_at = CASE WHEN i.resolved_at IS NULL THEN i.detected_at ELSE now() END,
+		    resolved_at = NULL
+		RETURNING detected_at = last_seen_at
+	`, issue.BookingID, issue.EventID, issue.Source, issue.Reason, stored, assigned).Scan(&opened)
+	return opened, err
+}
+
+// ResolveCheckedIssues resolves the open issues the integrity check flagged, except those of
+// the bookings in still, which it found again. It returns how many it resolved.
+func (r *BookingsRepository) ResolveCheckedIssues(ctx context.Context, still []string) (int64, error) {
+	tag, err := r.db.Pool.Exec(ctx, `
+		UPDATE seat_integrity_issues
+		SET resolved_at = now()
+		WHERE resolved_at IS NULL AND source = 'check' AND NOT (booking_id = ANY($1::uuid[]))
+	`, still)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// ResolveSeatIssue resolves the booking's open issue, reporting whether it had one.
+func (r *BookingsRepository) ResolveSeatIssue(ctx context.Context, bookingID string) (bool, error) {
+	tag, err := r.db.Pool.Exec(ctx, `
+		UPDATE seat_integrity_issues SET resolved_at = now()
+		WHERE booking_id = $1 AND resolved_at IS NULL
+	`, bookingID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// ListSeatIssues returns open issues, or resolved ones too with all, newest first.
+func (r *BookingsRepository) ListSeatIssues(ctx context.Context, all bool, limit, offset int) ([]*SeatIssue, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT `+seatIssueColumns+` FROM seat_integrity_issues
+		WHERE $1 OR resolved_at IS NULL
+		ORDER BY detected_at DESC
+		LIMIT $2 OFFSET $3
+	`, all, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []*SeatIssue{}
+	for rows.Next() {
+		i, err := scanSeatIssue(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, i)
+	}
+	return out, rows.Err()
+}
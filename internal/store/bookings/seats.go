@@ -0,0 +1,207 @@
+package bookings
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/domain"
+)
+
+// ErrSeatContention is returned when concurrent bookings kept taking the seats picked for a
+// quantity booking.
+var ErrSeatContention = errors.New("seats kept being taken by concurrent bookings")
+
+// SeatsClaimedError is returned when bookings already have some of the seats a booking
+// asked for.
+type SeatsClaimedError struct {
+	Seats []string
+}
+
+func (e *SeatsClaimedError) Error() string {
+	return "seats already claimed: " + strings.Join(e.Seats, ", ")
+}
+
+// maxAssignAttempts bounds how often a quantity booking picks again after concurrent
+// bookings took part of its pick.
+const maxAssignAttempts = 3
+
+// OpenSeat is a seat a quantity booking can be assigned, with its place in the layout.
+// Seats listed by label rather than laid out have no section or row.
+type OpenSeat struct {
+	Label    string
+	Section  string
+	Row      string
+	Position int
+}
+
+// SeatPicker chooses n of the open seats, which are in layout order, for a quantity
+// booking. It returns fewer than n labels when it won't assign the booking.
+type SeatPicker func(open []OpenSeat, n int) []string
+
+// CreatePendingAssigned is CreatePending for a booking of n seats the event picks: pick
+// chooses them among the open seats and their rows stay locked until the booking is
+// inserted. It returns a nil booking when the event doesn't have n seats left.
+func (r *BookingsRepository) CreatePendingAssigned(ctx context.Context, userID string, eventID string, idempotencyKey *string, source string, phaseID *string, n int, pick SeatPicker, announce Announce) (*Booking, bool, error) {
+	var booking *Booking
+	err := r.db.WithTx(ctx, func(tx pgx.Tx) error {
+		seats, err := assignSeats(ctx, tx, eventID, n, pick)
+		if err != nil || seats == nil {
+			return err
+		}
+		booking, err = insertPending(ctx, tx, userID, eventID, idempotencyKey, seats, source, phaseID, announce)
+		return err
+	})
+	if err != nil {
+		return r.existingOnConflict(ctx, err, eventID, idempotencyKey)
+	}
+	return booking, booking != nil, nil
+}
+
+// assignSeats picks n open seats of the event and locks their rows for the rest of tx,
+// returning them as the booking's seats. Rows another transaction has locked are
+// skipped rather than waited on (FOR UPDATE SKIP LOCKED), so concurrent quantity bookings
+// pick around each other instead of queueing behind one another; seats lost that way are
+// left out and pick runs again. It returns nil when the event doesn't have n seats left.
+func assignSeats(ctx context.Context, tx pgx.Tx, eventID string, n int, pick SeatPicker) (domain.Seats, error) {
+	// Not nil: <> ALL(NULL) matches no seat
+	lost := []string{}
+	for attempt := 0; attempt < maxAssignAttempts; attempt++ {
+		open, err := openSeats(ctx, tx, eventID, lost)
+		if err != nil {
+			return nil, err
+		}
+		if len(open) < n {
+			return nil, nil
+		}
+		picked := pick(open, n)
+		if len(picked) < n {
+			return nil, nil
+		}
+		locked, err := lockSeats(ctx, tx, eventID, picked)
+		if err != nil {
+			return nil, err
+		}
+		if len(locked) == len(picked) {
+			return picked, nil
+		}
+		got := make(map[string]bool, len(locked))
+		for _, label := range locked {
+			got[label] = true
+		}
+		for _, label := range picked {
+			if !got[label] {
+				lost = append(lost, label)
+			}
+		}
+	}
+	return nil, ErrSeatContention
+}
+
+// openSeats reads the event's available seats no pending or booked booking claims, minus
+// exclude, in layout order. Rows aren't locked here: locking every open seat would leave
+// nothing for the bookings running alongside this one.
+func openSeats(ctx context.Context, tx pgx.Tx, eventID string, exclude []string) ([]OpenSeat, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT s.seat_label, s.section, s.row_label, s.position
+		FROM seats s
+		WHERE s.event_id = $1 AND s.status = 'available'
+		  AND s.seat_label <> ALL($2::text[])
+		  AND NOT EXISTS (
+		      SELECT 1 FROM bookings b
+		      WHERE b.event_id = $1 AND b.status IN ('pending', 'booked') AND b.seats ? s.seat_label
+		  )
+		ORDER BY s.position, s.seat_label
+	`, eventID, exclude)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var open []OpenSeat
+	for rows.Next() {
+		var s OpenSeat
+		if err := rows.Scan(&s.Label, &s.Section, &s.Row, &s.Position); err != nil {
+			return nil, err
+		}
+		open = append(open, s)
+	}
+	return open, rows.Err()
+}
+
+// lockSeats locks the rows of the available seats among labels, skipping those another
+// transaction holds, and returns the ones it locked that no booking claims. The claim is
+// checked in a statement of its own, after the locks are held, so it sees every booking
+// committed by a transaction that held them before.
+func lockSeats(ctx context.Context, tx pgx.Tx, eventID string, labels []string) ([]string, error) {
+	locked, err := queryLabels(ctx, tx, `
+		SELECT s.seat_label
+		FROM seats s
+		WHERE s.event_id = $1 AND s.seat_label = ANY($2::text[]) AND s.status = 'available'
+		FOR UPDATE SKIP LOCKED
+	`, eventID, labels)
+	if err != nil || len(locked) == 0 {
+		return locked, err
+	}
+	return queryLabels(ctx, tx, `
+		SELECT l
+		FROM unnest($2::text[]) AS l
+		WHERE NOT EXISTS (
+		      SELECT 1 FROM bookings b
+		      WHERE b.event_id = $1 AND b.status IN ('pending', 'booked') AND b.seats ? l
+		  )
+	`, eventID, locked)
+}
+
+// claimSeats locks the rows of a booking's chosen seats, waiting for any transaction
+// assigning them to a quantity booking, and fails with a SeatsClaimedError if a booking has
+// any of them once the locks are held. Rows are locked in label order so two selections
+// sharing seats can't deadlock.
+func claimSeats(ctx context.Context, tx pgx.Tx, eventID string, seats domain.Seats) error {
+	_, err := tx.Exec(ctx, `
+		SELECT 1
+		FROM seats
+		WHERE event_id = $1 AND seat_label = ANY($2::text[])
+		ORDER BY seat_label
+		FOR UPDATE
+	`, eventID, []string(seats))
+	if err != nil {
+		return err
+	}
+	taken, err := queryLabels(ctx, tx, `
+		SELECT l
+		FROM unnest($2::text[]) AS l
+		WHERE EXISTS (
+		      SELECT 1 FROM bookings b
+		      WHERE b.event_id = $1 AND b.status IN ('pending', 'booked') AND b.seats ? l
+		  )
+		ORDER BY l
+	`, eventID, []string(seats))
+	if err != nil {
+		return err
+	}
+	if len(taken) > 0 {
+		return &SeatsClaimedError{Seats: taken}
+	}
+	return nil
+}
+
+func queryLabels(ctx context.Context, tx pgx.Tx, query string, args ...any) ([]string, error) {
+	rows, err := tx.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var labels []string
+	for rows.Next() {
+		var label string
+		if err := rows.Scan(&label); err != nil {
+			return nil, err
+		}
+		labels = append(labels, label)
+	}
+	return labels, rows.Err()
+}
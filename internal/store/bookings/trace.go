@@ -0,0 +1,117 @@
+package bookings
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// TraceEntry is one thing that happened to a booking. Kind is what happened within its
+// source, e.g. a booking event's type or a payment's state, and Detail the source's own
+// fields for it.
+type TraceEntry struct {
+	At     time.Time       `json:"at"`
+	Source string          `json:"source"`
+	Kind   string          `json:"kind"`
+	Detail json.RawMessage `json:"detail,omitempty"`
+}
+
+// RecordEvent stores a status transition announced for a booking, for its trace.
+func (r *BookingsRepository) RecordEvent(ctx context.Context, bookingID, typ, status string, at time.Time, expiresAt *time.Time) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		INSERT INTO booking_events (booking_id, type, status, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, bookingID, typ, status, expiresAt, at)
+	return err
+}
+
+// traceQuery puts a booking's history together from every table that records part of it,
+// oldest first. Published outbox rows are deleted after a day, so only recent ones show.
+const traceQuery = `
+	SELECT at, source, kind, detail FROM (
+		SELECT created_at AS at, 'booking' AS source, 'created' AS kind,
+		       jsonb_build_object('status', status, 'seats', seats, 'source', source, 'amount_due', amount_due,
+		                          'currency', currency, 'payment_status', payment_status, 'updated_at', updated_at) AS detail
+		FROM bookings WHERE id = $1
+		UNION ALL
+		SELECT created_at, 'booking_event', type, jsonb_build_object('status', status, 'expires_at', expires_at)
+		FROM booking_events WHERE booking_id = $1
+		UNION ALL
+		SELECT created_at, 'audit', action, payload
+		FROM booking_audit WHERE booking_id = $1
+		UNION ALL
+		SELECT created_at, 'outbox', envelope->>'type',
+		       jsonb_build_object('topic', topic, 'published_at', published_at, 'failed_at', failed_at, 'attempts', attempts, 'last_error', last_error)
+		FROM outbox WHERE envelope->'payload'->>'booking_id' = $1::text
+		UNION ALL
+		SELECT handled_at, 'kafka', outcome,
+		       jsonb_build_object('type', type, 'topic', topic, 'partition', partition, 'offset', message_offset,
+		                          'attempts', attempts, 'error', error, 'duration_ms', duration_ms)
+		FROM message_journal WHERE booking_id = $1
+		UNION ALL
+		SELECT created_at, 'payment', state,
+		       jsonb_build_object('payment_id', payment_id, 'provider_ref', provider_ref, 'amount', amount, 'currency', currency,
+		                          'error', error, 'authorized_at', authorized_at, 'captured_at', captured_at,
+		                          'voided_at', voided_at, 'refunded_at', refunded_at)
+		FROM payments WHERE booking_id = $1
+		UNION ALL
+		SELECT claimed_at, 'payment_attempt', CASE WHEN completed_at IS NULL THEN 'in_progress' ELSE 'completed' END,
+		       jsonb_build_object('payment_id', payment_id, 'amount', amount, 'result', result, 'completed_at', completed_at)
+		FROM payment_transactions WHERE booking_id = $1
+		UNION ALL
+		SELECT created_at, 'provider_event', type,
+		       jsonb_build_object('id', id, 'provider', provider, 'provider_ref', provider_ref, 'amount', amount,
+		                          'currency', currency, 'processed_at', processed_at, 'last_error', last_error)
+		FROM provider_events WHERE booking_id = $1
+		UNION ALL
+		SELECT created_at, 'payment_adjustment', kind,
+		       jsonb_build_object('amount', amount, 'currency', currency, 'payment_id', payment_id, 'provider_ref', provider_ref)
+		FROM payment_adjustments WHERE booking_id = $1
+		UNION ALL
+		SELECT created_at, 'refund', reason,
+		       jsonb_build_object('id', id, 'payment_id', payment_id, 'amount', amount, 'currency', currency,
+		                          'cancellation_fee', cancellation_fee, 'provider_ref', provider_ref, 'breakdown', breakdown)
+		FROM refunds WHERE booking_id = $1
+		UNION ALL
+		SELECT created_at, 'transfer', status,
+		       jsonb_build_object('id', id, 'to_email', to_email, 'to_user_id', to_user_id, 'expires_at', expires_at, 'accepted_at', accepted_at)
+		FROM booking_transfers WHERE booking_id = $1
+		UNION ALL
+		SELECT checked_in_at, 'check_in', 'checked_in', jsonb_build_object('gate_token_id', gate_token_id)
+		FROM check_ins WHERE booking_id = $1
+		UNION ALL
+		SELECT created_at, 'email', status,
+		       jsonb_build_object('id', id, 'to', to_email, 'subject', subject, 'attempts', attempts,
+		                          'last_error', last_error, 'sent_at', sent_at)
+		FROM mail_queue WHERE booking_id = $1
+		UNION ALL
+		SELECT created_at, 'webhook', status,
+		       jsonb_build_object('id', id, 'webhook_id', webhook_id, 'event_type', event_type, 'attempts', attempts,
+		                          'response_status', response_status, 'last_error', last_error, 'delivered_at', delivered_at)
+		FROM user_webhook_deliveries WHERE booking_id = $1
+		UNION ALL
+		SELECT created_at, 'integration_webhook', status,
+		       jsonb_build_object('id', id, 'endpoint_id', endpoint_id, 'event_type', event_type, 'attempts', attempts,
+		                          'response_status', response_status, 'last_error', last_error, 'delivered_at', delivered_at)
+		FROM webhook_endpoint_deliveries WHERE booking_id = $1
+	) t
+	ORDER BY at, source`
+
+// Trace returns everything recorded about the booking, oldest first.
+func (r *BookingsRepository) Trace(ctx context.Context, bookingID string) ([]*TraceEntry, error) {
+	rows, err := r.db.Pool.Query(ctx, traceQuery, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []*TraceEntry{}
+	for rows.Next() {
+		e := &TraceEntry{}
+		if err := rows.Scan(&e.At, &e.Source, &e.Kind, &e.Detail); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,135 @@
+package bookings
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	TransferPending   = "pending"
+	TransferAccepted  = "accepted"
+	TransferCancelled = "cancelled"
+)
+
+var (
+	// ErrTransferClosed is returned by AcceptTransfer for a transfer that was accepted,
+	// cancelled or has expired.
+	ErrTransferClosed = errors.New("transfer is no longer pending")
+	// ErrNotTransferable is returned by AcceptTransfer when the booking is no longer the
+	// sender's booked, unused booking.
+	ErrNotTransferable = errors.New("booking can no longer be transferred")
+)
+
+// Transfer is a booking handed from its owner to the holder of ToEmail.
+type Transfer struct {
+	ID         string     `json:"id"`
+	BookingID  string     `json:"booking_id"`
+	EventID    string     `json:"event_id"`
+	FromUserID string     `json:"from_user_id"`
+	ToEmail    string     `json:"to_email"`
+	ToUserID   *string    `json:"to_user_id,omitempty"`
+	Status     string     `json:"status"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+}
+
+const transferColumns = `id, booking_id, event_id, from_user_id, to_email, to_user_id, status, expires_at, created_at, accepted_at`
+
+func scanTransfer(row pgx.Row) (*Transfer, error) {
+	t := &Transfer{}
+	err := row.Scan(&t.ID, &t.BookingID, &t.EventID, &t.FromUserID, &t.ToEmail, &t.ToUserID, &t.Status, &t.ExpiresAt, &t.CreatedAt, &t.AcceptedAt)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// CreateTransfer stores t, found again by tokenHash, and fills in its ID and timestamps. A
+// pending transfer of the same booking is cancelled first, so only the latest link works.
+func (r *BookingsRepository) CreateTransfer(ctx context.Context, t *Transfer, tokenHash []byte) error {
+	return r.db.WithTx(ctx, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			UPDATE booking_transfers SET status = 'cancelled'
+			WHERE booking_id = $1 AND status = 'pending'`, t.BookingID)
+		if err != nil {
+			return err
+		}
+		return tx.QueryRow(ctx, `
+			INSERT INTO booking_transfers (booking_id, event_id, from_user_id, to_email, token_hash, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id, status, created_at`,
+			t.BookingID, t.EventID, t.FromUserID, t.ToEmail, tokenHash, t.ExpiresAt).Scan(&t.ID, &t.Status, &t.CreatedAt)
+	})
+}
+
+// GetTransferByHash returns the transfer with tokenHash, or nil if there is none.
+func (r *BookingsRepository) GetTransferByHash(ctx context.Context, tokenHash []byte) (*Transfer, error) {
+	t, err := scanTransfer(r.db.Pool.QueryRow(ctx, `SELECT `+transferColumns+` FROM booking_transfers WHERE token_hash = $1`, tokenHash))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return t, nil
+}
+
+// CancelTransfer cancels the booking's pending transfer from fromUserID, reporting whether
+// there was one.
+func (r *BookingsRepository) CancelTransfer(ctx context.Context, bookingID, fromUserID string) (bool, error) {
+	tag, err := r.db.Pool.Exec(ctx, `
+		UPDATE booking_transfers SET status = 'cancelled'
+		WHERE booking_id = $1 AND from_user_id = $2 AND status = 'pending'`, bookingID, fromUserID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// AcceptTransfer moves the transfer's booking to toUserID and records it as a 'transferred'
+// row in booking_audit, in one transaction. The transfer must be pending and unexpired, and
+// the booking still booked by the sender and not checked in.
+func (r *BookingsRepository) AcceptTransfer(ctx context.Context, transferID, toUserID string) (*Transfer, error) {
+	var t *Transfer
+	err := r.db.WithTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		t, err = scanTransfer(tx.QueryRow(ctx, `
+			UPDATE booking_transfers
+			SET status = 'accepted', to_user_id = $2, accepted_at = now()
+			WHERE id = $1 AND status = 'pending' AND expires_at > now()
+			RETURNING `+transferColumns, transferID, toUserID))
+		if err == pgx.ErrNoRows {
+			return ErrTransferClosed
+		}
+		if err != nil {
+			return err
+		}
+		tag, err := tx.Exec(ctx, `
+			UPDATE bookings SET user_id = $3, updated_at = now()
+			WHERE id = $1 AND user_id = $2 AND status = 'booked'
+			  AND NOT EXISTS (SELECT 1 FROM check_ins WHERE booking_id = $1)`, t.BookingID, t.FromUserID, toUserID)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrNotTransferable
+		}
+		payload, err := json.Marshal(map[string]any{"transfer_id": t.ID, "from_user_id": t.FromUserID, "to_user_id": toUserID, "to_email": t.ToEmail})
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO booking_audit (booking_id, event_id, user_id, action, payload)
+			VALUES ($1, $2, $3, 'transferred', $4)`, t.BookingID, t.EventID, toUserID, payload)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
@@ -0,0 +1,89 @@
+package branding
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store"
+)
+
+// Branding is what emails are signed with: the platform's, or an organizer's for emails
+// about their events. Empty fields fall back to the platform's and then to the defaults.
+type Branding struct {
+	// OrganizerID is nil for the platform's branding
+	OrganizerID  *string   `json:"organizer_id,omitempty"`
+	Name         string    `json:"name"`
+	Footer       string    `json:"footer"`
+	SupportEmail string    `json:"support_email"`
+	LogoURL      string    `json:"logo_url"`
+	LegalText    string    `json:"legal_text"`
+	UpdatedBy    *string   `json:"updated_by,omitempty"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type BrandingRepository struct {
+	db  *store.DB
+	log *zap.Logger
+}
+
+func NewBrandingRepository(db *store.DB, log *zap.Logger) *BrandingRepository {
+	return &BrandingRepository{db: db, log: log}
+}
+
+const brandingColumns = `organizer_id, name, footer, support_email, logo_url, legal_text, updated_by, updated_at`
+
+func scanBranding(row pgx.Row) (*Branding, error) {
+	b := &Branding{}
+	err := row.Scan(&b.OrganizerID, &b.Name, &b.Footer, &b.SupportEmail, &b.LogoURL, &b.LegalText, &b.UpdatedBy, &b.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Get returns the branding of the organizer, or the platform's if organizerID is nil, and
+// nil if none was set.
+func (r *BrandingRepository) Get(ctx context.Context, organizerID *string) (*Branding, error) {
+	b, err := scanBranding(r.db.Pool.QueryRow(ctx, `
+		SELECT `+brandingColumns+` FROM email_branding
+		WHERE organizer_id IS NOT DISTINCT FROM $1
+	`, organizerID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+// Set replaces the branding of b.OrganizerID, or the platform's if it is nil, recording the
+// admin who made the change.
+func (r *BrandingRepository) Set(ctx context.Context, b *Branding, adminID string) (*Branding, error) {
+	return scanBranding(r.db.Pool.QueryRow(ctx, `
+		INSERT INTO email_branding (organizer_id, name, footer, support_email, logo_url, legal_text, updated_by)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, '')::uuid)
+		ON CONFLICT (organizer_id) DO UPDATE SET
+			name = EXCLUDED.name,
+			footer = EXCLUDED.footer,
+			support_email = EXCLUDED.support_email,
+			logo_url = EXCLUDED.logo_url,
+			legal_text = EXCLUDED.legal_text,
+			updated_by = EXCLUDED.updated_by,
+			updated_at = now()
+		RETURNING `+brandingColumns,
+		b.OrganizerID, b.Name, b.Footer, b.SupportEmail, b.LogoURL, b.LegalText, adminID))
+}
+
+// Delete removes the organizer's branding, so their emails go out with the platform's. It
+// reports whether there was any.
+func (r *BrandingRepository) Delete(ctx context.Context, organizerID string) (bool, error) {
+	tag, err := r.db.Pool.Exec(ctx, `DELETE FROM email_branding WHERE organizer_id = $1`, organizerID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
@@ -0,0 +1,151 @@
+package checkin
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store"
+)
+
+// GateToken is a gate staff device's credential for one event. Only the token's hash is
+// stored; Token is set just on the token returned when it is issued.
+type GateToken struct {
+	ID         string     `json:"id"`
+	EventID    string     `json:"event_id"`
+	Name       string     `json:"name"`
+	Token      string     `json:"token,omitempty"`
+	IssuedBy   *string    `json:"issued_by,omitempty"`
+	ValidFrom  time.Time  `json:"valid_from"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// CheckIn records a booking admitted at a gate.
+type CheckIn struct {
+	BookingID   string    `json:"booking_id"`
+	EventID     string    `json:"event_id"`
+	GateTokenID *string   `json:"gate_token_id,omitempty"`
+	CheckedInAt time.Time `json:"checked_in_at"`
+}
+
+type CheckInRepository struct {
+	db  *store.DB
+	log *zap.Logger
+}
+
+func NewCheckInRepository(db *store.DB, log *zap.Logger) *CheckInRepository {
+	return &CheckInRepository{db: db, log: log}
+}
+
+// CreateToken stores t under hash, the SHA-256 of its token.
+func (r *CheckInRepository) CreateToken(ctx context.Context, t *GateToken, hash []byte) error {
+	query := `
+		INSERT INTO gate_tokens (event_id, name, token_hash, issued_by, valid_from, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`
+
+	return r.db.Pool.QueryRow(ctx, query, t.EventID, t.Name, hash, t.IssuedBy, t.ValidFrom, t.ExpiresAt).
+		Scan(&t.ID, &t.CreatedAt)
+}
+
+// GetTokenByHash returns the token whose hash is hash, or nil if there is none, and
+// stamps its last use.
+func (r *CheckInRepository) GetTokenByHash(ctx context.Context, hash []byte) (*GateToken, error) {
+	query := `
+		UPDATE gate_tokens SET last_used_at = now()
+		WHERE token_hash = $1
+		RETURNING id, event_id, name, issued_by, valid_from, expires_at, revoked_at, last_used_at, created_at`
+
+	t := &GateToken{}
+	err := r.db.Pool.QueryRow(ctx, query, hash).Scan(
+		&t.ID, &t.EventID, &t.Name, &t.IssuedBy, &t.ValidFrom, &t.ExpiresAt, &t.RevokedAt, &t.LastUsedAt, &t.CreatedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// ListTokens returns the event's gate tokens, newest first.
+func (r *CheckInRepository) ListTokens(ctx context.Context, eventID string) ([]*GateToken, error) {
+	query := `
+		SELECT id, event_id, name, issued_by, valid_from, expires_at, revoked_at, last_used_at, created_at
+		FROM gate_tokens
+		WHERE event_id = $1
+		ORDER BY created_at DESC`
+
+	rows, err := r.db.Pool.Query(ctx, query, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*GateToken{}
+	for rows.Next() {
+		t := &GateToken{}
+		if err := rows.Scan(&t.ID, &t.EventID, &t.Name, &t.IssuedBy, &t.ValidFrom, &t.ExpiresAt, &t.RevokedAt, &t.LastUsedAt, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+
+	return tokens, rows.Err()
+}
+
+// RevokeToken revokes the event's token id. It returns false if the event has no such
+// token; revoking a revoked token again is not an error.
+func (r *CheckInRepository) RevokeToken(ctx context.Context, eventID, id string) (bool, error) {
+	query := `
+		UPDATE gate_tokens SET revoked_at = COALESCE(revoked_at, now())
+		WHERE id = $1 AND event_id = $2`
+
+	tag, err := r.db.Pool.Exec(ctx, query, id, eventID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// CheckIn records the booking as admitted through the gate token tokenID. If it was
+// admitted before, the earlier check-in is returned with false.
+func (r *CheckInRepository) CheckIn(ctx context.Context, bookingID, eventID, tokenID string) (*CheckIn, bool, error) {
+	query := `
+		INSERT INTO check_ins (booking_id, event_id, gate_token_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (booking_id) DO NOTHING
+		RETURNING booking_id, event_id, gate_token_id, checked_in_at`
+
+	ci := &CheckIn{}
+	err := r.db.Pool.QueryRow(ctx, query, bookingID, eventID, tokenID).Scan(&ci.BookingID, &ci.EventID, &ci.GateTokenID, &ci.CheckedInAt)
+	if err == nil {
+		return ci, true, nil
+	}
+	if err != pgx.ErrNoRows {
+		return nil, false, err
+	}
+
+	err = r.db.Pool.QueryRow(ctx, `
+		SELECT booking_id, event_id, gate_token_id, checked_in_at
+		FROM check_ins
+		WHERE booking_id = $1`, bookingID).Scan(&ci.BookingID, &ci.EventID, &ci.GateTokenID, &ci.CheckedInAt)
+	if err != nil {
+		return nil, false, err
+	}
+	return ci, false, nil
+}
+
+// CountCheckIns returns how many of the event's bookings have been admitted.
+func (r *CheckInRepository) CountCheckIns(ctx context.Context, eventID string) (int, error) {
+	var n int
+	err := r.db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM check_ins WHERE event_id = $1`, eventID).Scan(&n)
+	return n, err
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
 )
 
 // DB wraps a pgxpool.Pool for database operations.
@@ -13,15 +14,32 @@ type DB struct {
 	Pool *pgxpool.Pool
 }
 
-// NewDB creates a connection pool.
-func NewDB(ctx context.Context, url string, maxDBConnections int32) (*DB, error) {
+// Option customises the pool configuration used by NewDB.
+type Option func(*pgxpool.Config)
+
+// WithSlowQueryLog logs queries that take longer than threshold.
+func WithSlowQueryLog(log *zap.Logger, threshold time.Duration) Option {
+	return func(cfg *pgxpool.Config) {
+		cfg.ConnConfig.Tracer = NewQueryTracer(log, threshold)
+	}
+}
+
+// NewDB creates a connection pool. Query metrics are always recorded.
+func NewDB(ctx context.Context, url string, maxDBConnections int32, opts ...Option) (*DB, error) {
 	cfg, err := pgxpool.ParseConfig(url)
 	if err != nil {
 		return nil, err
 	}
 	cfg.MaxConns = maxDBConnections
 	cfg.MinConns = 2
+	if cfg.MinConns > cfg.MaxConns {
+		cfg.MinConns = cfg.MaxConns
+	}
 	cfg.MaxConnLifetime = time.Hour
+	cfg.ConnConfig.Tracer = NewQueryTracer(nil, 0)
+	for _, opt := range opts {
+		opt(cfg)
+	}
 	pool, err := pgxpool.NewWithConfig(ctx, cfg)
 	if err != nil {
 		return nil, err
@@ -29,6 +47,43 @@ func NewDB(ctx context.Context, url string, maxDBConnections int32) (*DB, error)
 	return &DB{Pool: pool}, nil
 }
 
+// WithApplicationName tags connections so workloads can be told apart in pg_stat_activity.
+func WithApplicationName(name string) Option {
+	return func(cfg *pgxpool.Config) {
+		cfg.ConnConfig.RuntimeParams["application_name"] = name
+	}
+}
+
+// Pools separates interactive request traffic from batch work (analytics, exports,
+// reconciliation) so a long-running batch query cannot starve booking transactions
+// of connections.
+type Pools struct {
+	Interactive *DB
+	Batch       *DB
+}
+
+// NewPools creates independently sized interactive and batch pools against the same database.
+func NewPools(ctx context.Context, url string, interactiveMax, batchMax int32, opts ...Option) (*Pools, error) {
+	interactive, err := NewDB(ctx, url, interactiveMax, append(opts, WithApplicationName("evently-interactive"))...)
+	if err != nil {
+		return nil, err
+	}
+	batch, err := NewDB(ctx, url, batchMax, append(opts, WithApplicationName("evently-batch"))...)
+	if err != nil {
+		interactive.Close()
+		return nil, err
+	}
+	return &Pools{Interactive: interactive, Batch: batch}, nil
+}
+
+func (p *Pools) Close() {
+	if p == nil {
+		return
+	}
+	p.Interactive.Close()
+	p.Batch.Close()
+}
+
 func (d *DB) Close() {
 	if d != nil && d.Pool != nil {
 		d.Pool.Close()
@@ -0,0 +1,17 @@
+package store
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// uniqueViolation is the Postgres error code for a unique constraint conflict.
+const uniqueViolation = "23505"
+
+// IsUniqueViolation reports whether err is Postgres refusing a write for conflicting with a
+// unique constraint or index.
+func IsUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
+}
@@ -3,31 +3,75 @@ package events
 import (
 	"context"
 	"fmt"
+	"math"
 	"time"
 
 	"github.com/jackc/pgx/v5"
 	"go.uber.org/zap"
 
+	"github.com/samirwankhede/lewly-pgpyewj/internal/domain"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/store"
 )
 
-type Event struct {
-	ID                       string    `json:"id"`
-	Name                     string    `json:"name"`
-	Venue                    string    `json:"venue"`
-	StartTime                time.Time `json:"start_time"`
-	EndTime                  time.Time `json:"end_time"`
-	Category                 string    `json:"category"`
-	Capacity                 int       `json:"capacity"`
-	Reserved                 int       `json:"reserved"`
-	Metadata                 []byte    `json:"metadata"`
-	Status                   string    `json:"status"`
-	TicketPrice              float64   `json:"ticket_price"`
-	CancellationFee          float64   `json:"cancellation_fee"`
-	Likes                    int       `json:"likes"`
-	MaximumTicketsPerBooking int       `json:"maximum_tickets_per_booking"`
-	CreatedAt                time.Time `json:"created_at"`
-	UpdatedAt                time.Time `json:"updated_at"`
+// Event is the canonical event type; see package domain.
+type Event = domain.Event
+
+// Event visibility. Only public events are listed or searchable; unlisted events are open
+// to anyone with their ID, and private events only to holders of an invitation code.
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+)
+
+// ValidVisibility reports whether v is one of the Visibility constants.
+func ValidVisibility(v string) bool {
+	return v == VisibilityPublic || v == VisibilityUnlisted || v == VisibilityPrivate
+}
+
+// Payment capture modes. Immediate events charge buyers when they pay; manual events only
+// authorize the card then, and capture later.
+const (
+	CaptureImmediate = "immediate"
+	CaptureManual    = "manual"
+)
+
+// ValidCapture reports whether c is one of the Capture constants.
+func ValidCapture(c string) bool {
+	return c == CaptureImmediate || c == CaptureManual
+}
+
+// DisplayPrice is an event's prices converted for display; see package domain.
+type DisplayPrice = domain.DisplayPrice
+
+// defaultUserTicketWindow applies when a per-user limit is set without a window.
+const defaultUserTicketWindow = 24 * time.Hour
+
+// UserTicketLimit is the rolling per-user ticket cap for an event. Scope names the counter
+// the limit is enforced on: the organizer when the limit is shared across its events,
+// otherwise the event itself.
+type UserTicketLimit struct {
+	Scope      string
+	MaxTickets int
+	Window     time.Duration
+}
+
+// NearbyFilter narrows a radius search around Latitude/Longitude. Nil or empty fields don't filter.
+type NearbyFilter struct {
+	Latitude  float64
+	Longitude float64
+	RadiusKm  float64
+	From      *time.Time
+	To        *time.Time
+	Category  string
+	MinPrice  *float64
+	MaxPrice  *float64
+}
+
+// NearbyEvent is an event with its distance from the search point.
+type NearbyEvent struct {
+	*Event
+	DistanceKm float64 `json:"distance_km"`
 }
 
 type EventsRepository struct {
@@ -41,28 +85,58 @@ func NewEventsRepository(db *store.DB, log *zap.Logger) *EventsRepository {
 
 func (r *EventsRepository) Create(ctx context.Context, event *Event) (*Event, error) {
 	err := r.db.WithTx(ctx, func(tx pgx.Tx) error {
-		query := `
-		INSERT INTO events (name, venue, start_time, end_time, category, capacity, metadata, status, ticket_price, cancellation_fee, maximum_tickets_per_booking)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-		RETURNING id, created_at, updated_at`
+		return insertEvent(ctx, tx, event)
+	})
+	return event, err
+}
 
-		err := tx.QueryRow(ctx, query,
-			event.Name, event.Venue, event.StartTime, event.EndTime, event.Category,
-			event.Capacity, event.Metadata, event.Status, event.TicketPrice,
-			event.CancellationFee, event.MaximumTicketsPerBooking).
-			Scan(&event.ID, &event.CreatedAt, &event.UpdatedAt)
+// CreateUnlessDuplicate creates event unless a live event with the same name and venue
+// (ignoring case and surrounding spaces) starts at the same time, and returns that event's ID
+// instead. Creates of the same event wait on a transaction-scoped advisory lock for its name,
+// venue and start, so a double submit racing itself still creates it once.
+func (r *EventsRepository) CreateUnlessDuplicate(ctx context.Context, event *Event) (string, error) {
+	var dupID string
+	err := r.db.WithTx(ctx, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			SELECT pg_advisory_xact_lock(hashtext('event:' || lower(btrim($1)) || '|' || lower(btrim($2)) || '|' || extract(epoch FROM $3::timestamptz)::text))`,
+			event.Name, event.Venue, event.StartTime)
 		if err != nil {
 			return err
 		}
-		return err
+		err = tx.QueryRow(ctx, `
+			SELECT id
+			FROM events
+			WHERE lower(btrim(name)) = lower(btrim($1)) AND lower(btrim(venue)) = lower(btrim($2))
+			  AND start_time = $3 AND status <> 'cancelled'
+			ORDER BY created_at
+			LIMIT 1`, event.Name, event.Venue, event.StartTime).Scan(&dupID)
+		if err != pgx.ErrNoRows {
+			return err
+		}
+		return insertEvent(ctx, tx, event)
 	})
-	return event, err
+	return dupID, err
+}
+
+func insertEvent(ctx context.Context, tx pgx.Tx, event *Event) error {
+	query := `
+		INSERT INTO events (name, venue, start_time, end_time, category, capacity, metadata, status, ticket_price, cancellation_fee, maximum_tickets_per_booking, organizer_id, max_tickets_per_user, user_ticket_window_hours, latitude, longitude, waitlist_enabled, seat_selection_enabled, likes_enabled, no_single_seat, payment_capture, capture_at, currency, visibility, sandbox, owner_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26)
+		RETURNING id, created_at, updated_at`
+
+	return tx.QueryRow(ctx, query,
+		event.Name, event.Venue, event.StartTime, event.EndTime, event.Category,
+		event.Capacity, event.Metadata, event.Status, event.TicketPrice,
+		event.CancellationFee, event.MaximumTicketsPerBooking, event.OrganizerID,
+		event.MaxTicketsPerUser, event.UserTicketWindowHours, event.Latitude, event.Longitude,
+		event.WaitlistEnabled, event.SeatSelectionEnabled, event.LikesEnabled, event.NoSingleSeat, event.PaymentCapture, event.CaptureAt, event.Currency, event.Visibility, event.Sandbox, event.OwnerID).
+		Scan(&event.ID, &event.CreatedAt, &event.UpdatedAt)
 }
 
 func (r *EventsRepository) Get(ctx context.Context, id string) (*Event, error) {
 	query := `
 		SELECT id, name, venue, start_time, end_time, category, capacity, reserved, metadata, 
-		       status, ticket_price, cancellation_fee, likes, maximum_tickets_per_booking, created_at, updated_at
+		       status, ticket_price, cancellation_fee, likes, maximum_tickets_per_booking, organizer_id, latitude, longitude, waitlist_enabled, seat_selection_enabled, likes_enabled, no_single_seat, payment_capture, capture_at, currency, visibility, sandbox, owner_id, created_at, updated_at
 		FROM events
 		WHERE id = $1`
 
@@ -71,7 +145,7 @@ func (r *EventsRepository) Get(ctx context.Context, id string) (*Event, error) {
 		&event.ID, &event.Name, &event.Venue, &event.StartTime, &event.EndTime,
 		&event.Category, &event.Capacity, &event.Reserved, &event.Metadata,
 		&event.Status, &event.TicketPrice, &event.CancellationFee, &event.Likes,
-		&event.MaximumTicketsPerBooking, &event.CreatedAt, &event.UpdatedAt,
+		&event.MaximumTicketsPerBooking, &event.OrganizerID, &event.Latitude, &event.Longitude, &event.WaitlistEnabled, &event.SeatSelectionEnabled, &event.LikesEnabled, &event.NoSingleSeat, &event.PaymentCapture, &event.CaptureAt, &event.Currency, &event.Visibility, &event.Sandbox, &event.OwnerID, &event.CreatedAt, &event.UpdatedAt,
 	)
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -83,12 +157,51 @@ func (r *EventsRepository) Get(ctx context.Context, id string) (*Event, error) {
 	return event, nil
 }
 
-func (r *EventsRepository) List(ctx context.Context, limit, offset int, q string, from, to *time.Time) ([]*Event, error) {
+// Capacities returns the capacity of each of ids that is still on sale, keyed by event ID.
+// Cancelled and expired events are left out.
+func (r *EventsRepository) Capacities(ctx context.Context, ids []string) (map[string]int, error) {
+	out := make(map[string]int, len(ids))
+	if len(ids) == 0 {
+		return out, nil
+	}
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT id, capacity FROM events
+		WHERE id = ANY($1::uuid[]) AND status NOT IN ('cancelled', 'expired')`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var capacity int
+		if err := rows.Scan(&id, &capacity); err != nil {
+			return nil, err
+		}
+		out[id] = capacity
+	}
+	return out, rows.Err()
+}
+
+// ListPosition is where a page of a listing ordered by start time ends: the start_time and
+// ID of its last event.
+type ListPosition struct {
+	StartTime time.Time `json:"s"`
+	ID        string    `json:"id"`
+}
+
+// Position returns the position just after e in the listings ordered by start time.
+func Position(e *Event) *ListPosition {
+	return &ListPosition{StartTime: e.StartTime, ID: e.ID}
+}
+
+// List pages through public events by start time, optionally filtered by name and start
+// time. after, if not nil, starts the page past a previous one.
+func (r *EventsRepository) List(ctx context.Context, limit, offset int, after *ListPosition, q string, from, to *time.Time) ([]*Event, error) {
 	query := `
 		SELECT id, name, venue, start_time, end_time, category, capacity, reserved, metadata, 
-		       status, ticket_price, cancellation_fee, likes, maximum_tickets_per_booking, created_at, updated_at
+		       status, ticket_price, cancellation_fee, likes, maximum_tickets_per_booking, organizer_id, latitude, longitude, waitlist_enabled, seat_selection_enabled, likes_enabled, no_single_seat, payment_capture, capture_at, currency, visibility, created_at, updated_at
 		FROM events
-		WHERE 1=1`
+		WHERE visibility = 'public'`
 
 	args := []interface{}{}
 	argIndex := 1
@@ -111,7 +224,13 @@ func (r *EventsRepository) List(ctx context.Context, limit, offset int, q string
 		argIndex++
 	}
 
-	query += ` ORDER BY start_time ASC LIMIT $` + fmt.Sprintf("%d", argIndex) + ` OFFSET $` + fmt.Sprintf("%d", argIndex+1)
+	if after != nil {
+		query += fmt.Sprintf(` AND (start_time, id) > ($%d, $%d::uuid)`, argIndex, argIndex+1)
+		args = append(args, after.StartTime, after.ID)
+		argIndex += 2
+	}
+
+	query += ` ORDER BY start_time ASC, id ASC LIMIT $` + fmt.Sprintf("%d", argIndex) + ` OFFSET $` + fmt.Sprintf("%d", argIndex+1)
 	args = append(args, limit, offset)
 
 	rows, err := r.db.Pool.Query(ctx, query, args...)
@@ -127,7 +246,7 @@ func (r *EventsRepository) List(ctx context.Context, limit, offset int, q string
 			&event.ID, &event.Name, &event.Venue, &event.StartTime, &event.EndTime,
 			&event.Category, &event.Capacity, &event.Reserved, &event.Metadata,
 			&event.Status, &event.TicketPrice, &event.CancellationFee, &event.Likes,
-			&event.MaximumTicketsPerBooking, &event.CreatedAt, &event.UpdatedAt,
+			&event.MaximumTicketsPerBooking, &event.OrganizerID, &event.Latitude, &event.Longitude, &event.WaitlistEnabled, &event.SeatSelectionEnabled, &event.LikesEnabled, &event.NoSingleSeat, &event.PaymentCapture, &event.CaptureAt, &event.Currency, &event.Visibility, &event.CreatedAt, &event.UpdatedAt,
 		)
 		if err != nil {
 			return nil, err
@@ -138,16 +257,27 @@ func (r *EventsRepository) List(ctx context.Context, limit, offset int, q string
 	return events, nil
 }
 
-func (r *EventsRepository) ListAll(ctx context.Context, limit, offset int) ([]*Event, error) {
+// params is the position as nullable query parameters.
+func (p *ListPosition) params() (*time.Time, *string) {
+	if p == nil {
+		return nil, nil
+	}
+	return &p.StartTime, &p.ID
+}
+
+// ListAll pages through public events that haven't ended by start time; after works as for List.
+func (r *EventsRepository) ListAll(ctx context.Context, limit, offset int, after *ListPosition) ([]*Event, error) {
+	afterAt, afterID := after.params()
 	query := `
 		SELECT id, name, venue, start_time, end_time, category, capacity, reserved, metadata, 
-		       status, ticket_price, cancellation_fee, likes, maximum_tickets_per_booking, created_at, updated_at
+		       status, ticket_price, cancellation_fee, likes, maximum_tickets_per_booking, organizer_id, latitude, longitude, waitlist_enabled, seat_selection_enabled, likes_enabled, no_single_seat, payment_capture, capture_at, currency, visibility, created_at, updated_at
 		FROM events
-		WHERE (end_time IS NULL OR end_time > NOW())
-		ORDER BY start_time ASC
+		WHERE visibility = 'public' AND (end_time IS NULL OR end_time > NOW())
+		  AND ($3::timestamptz IS NULL OR (start_time, id) > ($3, $4::uuid))
+		ORDER BY start_time ASC, id ASC
 		LIMIT $1 OFFSET $2`
 
-	rows, err := r.db.Pool.Query(ctx, query, limit, offset)
+	rows, err := r.db.Pool.Query(ctx, query, limit, offset, afterAt, afterID)
 	if err != nil {
 		return nil, err
 	}
@@ -160,7 +290,7 @@ func (r *EventsRepository) ListAll(ctx context.Context, limit, offset int) ([]*E
 			&event.ID, &event.Name, &event.Venue, &event.StartTime, &event.EndTime,
 			&event.Category, &event.Capacity, &event.Reserved, &event.Metadata,
 			&event.Status, &event.TicketPrice, &event.CancellationFee, &event.Likes,
-			&event.MaximumTicketsPerBooking, &event.CreatedAt, &event.UpdatedAt,
+			&event.MaximumTicketsPerBooking, &event.OrganizerID, &event.Latitude, &event.Longitude, &event.WaitlistEnabled, &event.SeatSelectionEnabled, &event.LikesEnabled, &event.NoSingleSeat, &event.PaymentCapture, &event.CaptureAt, &event.Currency, &event.Visibility, &event.CreatedAt, &event.UpdatedAt,
 		)
 		if err != nil {
 			return nil, err
@@ -171,16 +301,19 @@ func (r *EventsRepository) ListAll(ctx context.Context, limit, offset int) ([]*E
 	return events, nil
 }
 
-func (r *EventsRepository) ListUpcoming(ctx context.Context, limit, offset int) ([]*Event, error) {
+// ListUpcoming pages through public events yet to start by start time; after works as for List.
+func (r *EventsRepository) ListUpcoming(ctx context.Context, limit, offset int, after *ListPosition) ([]*Event, error) {
+	afterAt, afterID := after.params()
 	query := `
 		SELECT id, name, venue, start_time, end_time, category, capacity, reserved, metadata, 
-		       status, ticket_price, cancellation_fee, likes, maximum_tickets_per_booking, created_at, updated_at
+		       status, ticket_price, cancellation_fee, likes, maximum_tickets_per_booking, organizer_id, latitude, longitude, waitlist_enabled, seat_selection_enabled, likes_enabled, no_single_seat, payment_capture, capture_at, currency, visibility, created_at, updated_at
 		FROM events
-		WHERE start_time > NOW() AND status = 'upcoming'
-		ORDER BY start_time ASC
+		WHERE visibility = 'public' AND start_time > NOW() AND status = 'upcoming'
+		  AND ($3::timestamptz IS NULL OR (start_time, id) > ($3, $4::uuid))
+		ORDER BY start_time ASC, id ASC
 		LIMIT $1 OFFSET $2`
 
-	rows, err := r.db.Pool.Query(ctx, query, limit, offset)
+	rows, err := r.db.Pool.Query(ctx, query, limit, offset, afterAt, afterID)
 	if err != nil {
 		return nil, err
 	}
@@ -193,7 +326,7 @@ func (r *EventsRepository) ListUpcoming(ctx context.Context, limit, offset int)
 			&event.ID, &event.Name, &event.Venue, &event.StartTime, &event.EndTime,
 			&event.Category, &event.Capacity, &event.Reserved, &event.Metadata,
 			&event.Status, &event.TicketPrice, &event.CancellationFee, &event.Likes,
-			&event.MaximumTicketsPerBooking, &event.CreatedAt, &event.UpdatedAt,
+			&event.MaximumTicketsPerBooking, &event.OrganizerID, &event.Latitude, &event.Longitude, &event.WaitlistEnabled, &event.SeatSelectionEnabled, &event.LikesEnabled, &event.NoSingleSeat, &event.PaymentCapture, &event.CaptureAt, &event.Currency, &event.Visibility, &event.CreatedAt, &event.UpdatedAt,
 		)
 		if err != nil {
 			return nil, err
@@ -207,9 +340,9 @@ func (r *EventsRepository) ListUpcoming(ctx context.Context, limit, offset int)
 func (r *EventsRepository) ListPopular(ctx context.Context, limit, offset int) ([]*Event, error) {
 	query := `
 		SELECT id, name, venue, start_time, end_time, category, capacity, reserved, metadata, 
-		       status, ticket_price, cancellation_fee, likes, maximum_tickets_per_booking, created_at, updated_at
+		       status, ticket_price, cancellation_fee, likes, maximum_tickets_per_booking, organizer_id, latitude, longitude, waitlist_enabled, seat_selection_enabled, likes_enabled, no_single_seat, payment_capture, capture_at, currency, visibility, created_at, updated_at
 		FROM events
-		WHERE status = 'upcoming'
+		WHERE visibility = 'public' AND status = 'upcoming'
 		ORDER BY likes DESC, start_time ASC
 		LIMIT $1 OFFSET $2`
 
@@ -226,7 +359,40 @@ func (r *EventsRepository) ListPopular(ctx context.Context, limit, offset int) (
 			&event.ID, &event.Name, &event.Venue, &event.StartTime, &event.EndTime,
 			&event.Category, &event.Capacity, &event.Reserved, &event.Metadata,
 			&event.Status, &event.TicketPrice, &event.CancellationFee, &event.Likes,
-			&event.MaximumTicketsPerBooking, &event.CreatedAt, &event.UpdatedAt,
+			&event.MaximumTicketsPerBooking, &event.OrganizerID, &event.Latitude, &event.Longitude, &event.WaitlistEnabled, &event.SeatSelectionEnabled, &event.LikesEnabled, &event.NoSingleSeat, &event.PaymentCapture, &event.CaptureAt, &event.Currency, &event.Visibility, &event.CreatedAt, &event.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+func (r *EventsRepository) ListUpcomingByOrganizer(ctx context.Context, organizerID string, limit, offset int) ([]*Event, error) {
+	query := `
+		SELECT id, name, venue, start_time, end_time, category, capacity, reserved, metadata, 
+		       status, ticket_price, cancellation_fee, likes, maximum_tickets_per_booking, organizer_id, latitude, longitude, waitlist_enabled, seat_selection_enabled, likes_enabled, no_single_seat, payment_capture, capture_at, currency, visibility, created_at, updated_at
+		FROM events
+		WHERE organizer_id = $1 AND visibility = 'public' AND start_time > NOW() AND status = 'upcoming'
+		ORDER BY start_time ASC
+		LIMIT $2 OFFSET $3`
+
+	rows, err := r.db.Pool.Query(ctx, query, organizerID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*Event
+	for rows.Next() {
+		event := &Event{}
+		err := rows.Scan(
+			&event.ID, &event.Name, &event.Venue, &event.StartTime, &event.EndTime,
+			&event.Category, &event.Capacity, &event.Reserved, &event.Metadata,
+			&event.Status, &event.TicketPrice, &event.CancellationFee, &event.Likes,
+			&event.MaximumTicketsPerBooking, &event.OrganizerID, &event.Latitude, &event.Longitude, &event.WaitlistEnabled, &event.SeatSelectionEnabled, &event.LikesEnabled, &event.NoSingleSeat, &event.PaymentCapture, &event.CaptureAt, &event.Currency, &event.Visibility, &event.CreatedAt, &event.UpdatedAt,
 		)
 		if err != nil {
 			return nil, err
@@ -331,6 +497,74 @@ func (r *EventsRepository) IsLiked(ctx context.Context, eventID, userID string)
 	return true, nil
 }
 
+// Attendance sums up how an event sold: its paid bookings and the tickets in them, the
+// bookings cancelled, and the users still waiting when it ended.
+type Attendance struct {
+	Bookings          int     `json:"bookings"`
+	TicketsSold       int     `json:"tickets_sold"`
+	Capacity          int     `json:"capacity"`
+	SellThrough       float64 `json:"sell_through_percent"`
+	CancelledBookings int     `json:"cancelled_bookings"`
+	Waitlisted        int     `json:"waitlisted"`
+}
+
+// Attendance returns the event's attendance figures, or nil if there is no such event.
+func (r *EventsRepository) Attendance(ctx context.Context, eventID string) (*Attendance, error) {
+	query := `
+		-- name: events_attendance
+		SELECT e.capacity,
+		       count(b.id) FILTER (WHERE b.status = 'booked'),
+		       COALESCE(sum(CASE WHEN jsonb_typeof(b.seats) = 'array' THEN jsonb_array_length(b.seats) END)
+		                FILTER (WHERE b.status = 'booked'), 0),
+		       count(b.id) FILTER (WHERE b.status = 'cancelled'),
+		       (SELECT count(*) FROM waitlist w WHERE w.event_id = e.id AND NOT w.opted_out)
+		FROM events e
+		LEFT JOIN bookings b ON b.event_id = e.id
+		WHERE e.id = $1
+		GROUP BY e.id`
+
+	a := &Attendance{}
+	err := r.db.Pool.QueryRow(ctx, query, eventID).Scan(&a.Capacity, &a.Bookings, &a.TicketsSold, &a.CancelledBookings, &a.Waitlisted)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if a.Capacity > 0 {
+		a.SellThrough = math.Round(1000*float64(a.TicketsSold)/float64(a.Capacity)) / 10
+	}
+	return a, nil
+}
+
+// PreviousEdition returns the ID and start of the latest event of the same series that
+// ended before this one starts, or "" if there is none. A series is an organizer's events
+// sharing a name, ignoring case and surrounding spaces; events without an organizer have none.
+func (r *EventsRepository) PreviousEdition(ctx context.Context, eventID string) (string, time.Time, error) {
+	query := `
+		-- name: events_previous_edition
+		SELECT p.id, p.start_time
+		FROM events e
+		JOIN events p ON p.organizer_id = e.organizer_id
+		             AND lower(btrim(p.name)) = lower(btrim(e.name))
+		             AND p.end_time <= e.start_time
+		             AND p.status <> 'cancelled'
+		WHERE e.id = $1
+		ORDER BY p.start_time DESC
+		LIMIT 1`
+
+	var id string
+	var start time.Time
+	err := r.db.Pool.QueryRow(ctx, query, eventID).Scan(&id, &start)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return "", time.Time{}, nil
+		}
+		return "", time.Time{}, err
+	}
+	return id, start, nil
+}
+
 func (r *EventsRepository) GetAvailableSeats(ctx context.Context, eventID string) ([]string, error) {
 	query := `
 		SELECT seat_label 
@@ -356,16 +590,391 @@ func (r *EventsRepository) GetAvailableSeats(ctx context.Context, eventID string
 	return seats, nil
 }
 
-func (r *EventsRepository) UpdateExpiredEvents(ctx context.Context) (int, error) {
+// openSeats selects the labels of an event's seats that are available and not already
+// claimed by a pending booking, in label order.
+const openSeats = `
+	SELECT s.seat_label
+	FROM seats s
+	WHERE s.event_id = $1 AND s.status = 'available'
+	  AND NOT EXISTS (
+	      SELECT 1 FROM bookings b
+	      WHERE b.event_id = $1 AND b.status = 'pending' AND b.seats ? s.seat_label
+	  )
+	ORDER BY s.seat_label`
+
+// OpenSeats returns every seat of the event a booking could still take, for callers that
+// need to see the whole row around a selection.
+func (r *EventsRepository) OpenSeats(ctx context.Context, eventID string) ([]string, error) {
+	return r.querySeatLabels(ctx, openSeats, eventID)
+}
+
+// PriceTier is a price seats of an event can be sold at instead of its ticket price.
+type PriceTier struct {
+	Name  string  `json:"name"`
+	Price float64 `json:"price"`
+}
+
+// MapSeat is a seat as drawn on an event's seat map. Price is the seat's tier price, or the
+// event's ticket price for seats without a tier.
+type MapSeat struct {
+	Label     string  `json:"label"`
+	Section   string  `json:"-"`
+	Row       string  `json:"-"`
+	Tier      string  `json:"tier,omitempty"`
+	Price     float64 `json:"price"`
+	Available bool    `json:"available"`
+}
+
+// SeatMap returns every seat of a live event in layout order with its price and whether it
+// can still be chosen: it is available and no pending or booked booking claims it.
+func (r *EventsRepository) SeatMap(ctx context.Context, eventID string) ([]*MapSeat, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT s.seat_label, s.section, s.row_label, s.tier, COALESCE(t.price, e.ticket_price, 0),
+		       s.status = 'available' AND NOT EXISTS (
+		           SELECT 1 FROM bookings b
+		           WHERE b.event_id = $1 AND b.status IN ('pending', 'booked') AND b.seats ? s.seat_label
+		       )
+		FROM seats s
+		JOIN events e ON e.id = s.event_id
+		LEFT JOIN event_price_tiers t ON t.event_id = s.event_id AND t.name = s.tier
+		WHERE s.event_id = $1
+		ORDER BY s.position, s.seat_label`, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var seats []*MapSeat
+	for rows.Next() {
+		s := &MapSeat{}
+		if err := rows.Scan(&s.Label, &s.Section, &s.Row, &s.Tier, &s.Price, &s.Available); err != nil {
+			return nil, err
+		}
+		seats = append(seats, s)
+	}
+	return seats, rows.Err()
+}
+
+// PriceTiers returns the event's price tiers by name.
+func (r *EventsRepository) PriceTiers(ctx context.Context, eventID string) ([]PriceTier, error) {
+	rows, err := r.db.Pool.Query(ctx, `SELECT name, price FROM event_price_tiers WHERE event_id = $1 ORDER BY name`, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tiers := []PriceTier{}
+	for rows.Next() {
+		var t PriceTier
+		if err := rows.Scan(&t.Name, &t.Price); err != nil {
+			return nil, err
+		}
+		tiers = append(tiers, t)
+	}
+	return tiers, rows.Err()
+}
+
+// seatPricesQuery prices each of the labels $2 of event $1 for booking $4, in order: at its
+// price tier, or if it has no tier or isn't on the seat map, at the price of the sale phase
+// the booking was sold in, or the event's ticket price $3 if it wasn't sold in one.
+const seatPricesQuery = `
+	SELECT COALESCE(t.price, (
+		SELECT p.price FROM bookings b
+		JOIN event_sale_phases p ON p.id = b.sale_phase_id
+		WHERE b.event_id = $1 AND b.id = $4
+	), $3) AS price
+	FROM unnest($2::text[]) WITH ORDINALITY AS l(label, n)
+	LEFT JOIN seats s ON s.event_id = $1 AND s.seat_label = l.label
+	LEFT JOIN event_price_tiers t ON t.event_id = $1 AND t.name = s.tier
+	ORDER BY l.n`
+
+// BookingAmount prices seats of event for booking bookingID: each seat at its price tier, or
+// if it has no tier or isn't on the seat map, at the price of the sale phase the booking was
+// sold in, or the event's ticket price if it wasn't sold in one.
+func (r *EventsRepository) BookingAmount(ctx context.Context, event *Event, bookingID string, seats []string) (float64, error) {
+	var amount float64
+	err := r.db.Pool.QueryRow(ctx, `SELECT COALESCE(SUM(price), 0) FROM (`+seatPricesQuery+`) p`,
+		event.ID, seats, event.TicketPrice, bookingID).Scan(&amount)
+	return amount, err
+}
+
+// SeatPrices returns what each of seats of event lists at for booking bookingID, in order,
+// priced as BookingAmount prices them.
+func (r *EventsRepository) SeatPrices(ctx context.Context, event *Event, bookingID string, seats []string) ([]float64, error) {
+	rows, err := r.db.Pool.Query(ctx, seatPricesQuery, event.ID, seats, event.TicketPrice, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prices := make([]float64, 0, len(seats))
+	for rows.Next() {
+		var price float64
+		if err := rows.Scan(&price); err != nil {
+			return nil, err
+		}
+		prices = append(prices, price)
+	}
+	return prices, rows.Err()
+}
+
+// TakenSeats returns which of labels are booked, blocked or claimed by a pending booking of
+// the event. Labels the seat map doesn't list aren't reported.
+func (r *EventsRepository) TakenSeats(ctx context.Context, eventID string, labels []string) ([]string, error) {
+	return r.querySeatLabels(ctx, `
+		SELECT l
+		FROM unnest($2::text[]) AS l
+		WHERE EXISTS (
+		      SELECT 1 FROM seats s
+		      WHERE s.event_id = $1 AND s.seat_label = l AND s.status <> 'available'
+		  )
+		   OR EXISTS (
+		      SELECT 1 FROM bookings b
+		      WHERE b.event_id = $1 AND b.status IN ('pending', 'booked') AND b.seats ? l
+		  )
+		ORDER BY l`, eventID, labels)
+}
+
+// UnmappedSeats returns which of labels the event's seat map doesn't list.
+func (r *EventsRepository) UnmappedSeats(ctx context.Context, eventID string, labels []string) ([]string, error) {
+	return r.querySeatLabels(ctx, `
+		SELECT l
+		FROM unnest($2::text[]) AS l
+		WHERE NOT EXISTS (SELECT 1 FROM seats s WHERE s.event_id = $1 AND s.seat_label = l)
+		ORDER BY l`, eventID, labels)
+}
+
+func (r *EventsRepository) querySeatLabels(ctx context.Context, query string, args ...any) ([]string, error) {
+	rows, err := r.db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seats := []string{}
+	for rows.Next() {
+		var seat string
+		if err := rows.Scan(&seat); err != nil {
+			return nil, err
+		}
+		seats = append(seats, seat)
+	}
+	return seats, rows.Err()
+}
+
+// UpdateExpiredEvents marks events whose end_time is before now as expired and returns their IDs.
+func (r *EventsRepository) UpdateExpiredEvents(ctx context.Context, now time.Time) ([]string, error) {
 	query := `
 		UPDATE events 
 		SET status = 'expired', updated_at = now()
-		WHERE status != 'expired' AND end_time < NOW()`
+		WHERE status NOT IN ('expired', 'cancelled') AND end_time < $1
+		RETURNING id`
 
-	result, err := r.db.Pool.Exec(ctx, query)
+	rows, err := r.db.Pool.Query(ctx, query, now)
 	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// FilterLiveEventIDs returns the subset of ids that exist and are not in a terminal state.
+func (r *EventsRepository) FilterLiveEventIDs(ctx context.Context, ids []string) (map[string]bool, error) {
+	query := `
+		SELECT id
+		FROM events
+		WHERE id::text = ANY($1) AND status NOT IN ('expired', 'cancelled')`
+
+	rows, err := r.db.Pool.Query(ctx, query, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	live := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		live[id] = true
+	}
+
+	return live, rows.Err()
+}
+
+// GetUserTicketLimit resolves the per-user limit of an event. An event-level limit takes
+// precedence over its organizer's; nil means the event has no per-user limit.
+func (r *EventsRepository) GetUserTicketLimit(ctx context.Context, eventID string) (*UserTicketLimit, error) {
+	query := `
+		SELECT e.organizer_id, e.max_tickets_per_user, e.user_ticket_window_hours,
+		       o.max_tickets_per_user, o.user_ticket_window_hours
+		FROM events e
+		LEFT JOIN organizers o ON o.id = e.organizer_id
+		WHERE e.id = $1`
+
+	var organizerID *string
+	var eventMax, eventHours, orgMax, orgHours *int
+	err := r.db.Pool.QueryRow(ctx, query, eventID).Scan(&organizerID, &eventMax, &eventHours, &orgMax, &orgHours)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	window := defaultUserTicketWindow
+	switch {
+	case eventMax != nil:
+		if eventHours != nil {
+			window = time.Duration(*eventHours) * time.Hour
+		} else if orgHours != nil {
+			window = time.Duration(*orgHours) * time.Hour
+		}
+		return &UserTicketLimit{Scope: "event:" + eventID, MaxTickets: *eventMax, Window: window}, nil
+	case orgMax != nil:
+		if orgHours != nil {
+			window = time.Duration(*orgHours) * time.Hour
+		}
+		return &UserTicketLimit{Scope: "organizer:" + *organizerID, MaxTickets: *orgMax, Window: window}, nil
+	}
+	return nil, nil
+}
+
+// ExpectedTokens returns how many tokens the event's Redis bucket should hold: its capacity
+// minus the seats of every pending or booked booking, since each of those reserved tokens.
+func (r *EventsRepository) ExpectedTokens(ctx context.Context, eventID string) (int, error) {
+	query := `
+		SELECT e.capacity - COALESCE((
+			SELECT SUM(jsonb_array_length(COALESCE(b.seats, '[]'::jsonb)))
+			FROM bookings b
+			WHERE b.event_id = e.id AND b.status IN ('pending', 'booked')
+		), 0)
+		FROM events e
+		WHERE e.id = $1`
+
+	var tokens int
+	if err := r.db.Pool.QueryRow(ctx, query, eventID).Scan(&tokens); err != nil {
 		return 0, err
 	}
+	if tokens < 0 {
+		tokens = 0
+	}
+	return tokens, nil
+}
+
+// ExpectedTokensLive returns ExpectedTokens for every event that is not expired or cancelled,
+// keyed by event ID.
+func (r *EventsRepository) ExpectedTokensLive(ctx context.Context) (map[string]int, error) {
+	query := `
+		SELECT e.id, e.capacity - COALESCE(SUM(jsonb_array_length(COALESCE(b.seats, '[]'::jsonb))), 0)
+		FROM events e
+		LEFT JOIN bookings b ON b.event_id = e.id AND b.status IN ('pending', 'booked')
+		WHERE e.status NOT IN ('expired', 'cancelled')
+		GROUP BY e.id, e.capacity`
+
+	rows, err := r.db.Pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := make(map[string]int)
+	for rows.Next() {
+		var id string
+		var n int
+		if err := rows.Scan(&id, &n); err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			n = 0
+		}
+		tokens[id] = n
+	}
+	return tokens, rows.Err()
+}
+
+// ListNearby returns upcoming events with coordinates within f.RadiusKm of the search point,
+// nearest first. earth_box prefilters through the GiST index; earth_distance trims its corners.
+func (r *EventsRepository) ListNearby(ctx context.Context, f NearbyFilter, limit, offset int) ([]*NearbyEvent, error) {
+	query := `
+		SELECT id, name, venue, start_time, end_time, category, capacity, reserved, metadata,
+		       status, ticket_price, cancellation_fee, likes, maximum_tickets_per_booking, organizer_id, latitude, longitude, waitlist_enabled, seat_selection_enabled, likes_enabled, no_single_seat, payment_capture, capture_at, currency, visibility, created_at, updated_at,
+		       earth_distance(ll_to_earth($1, $2), ll_to_earth(latitude, longitude)) / 1000 AS distance_km
+		FROM events
+		WHERE latitude IS NOT NULL AND longitude IS NOT NULL
+		  AND earth_box(ll_to_earth($1, $2), $3) @> ll_to_earth(latitude, longitude)
+		  AND earth_distance(ll_to_earth($1, $2), ll_to_earth(latitude, longitude)) <= $3
+		  AND status = 'upcoming' AND start_time > now() AND visibility = 'public'`
+
+	args := []interface{}{f.Latitude, f.Longitude, f.RadiusKm * 1000}
+	argIndex := 4
+
+	if f.From != nil {
+		query += ` AND start_time >= $` + fmt.Sprintf("%d", argIndex)
+		args = append(args, *f.From)
+		argIndex++
+	}
+
+	if f.To != nil {
+		query += ` AND start_time <= $` + fmt.Sprintf("%d", argIndex)
+		args = append(args, *f.To)
+		argIndex++
+	}
+
+	if f.Category != "" {
+		query += ` AND category = $` + fmt.Sprintf("%d", argIndex)
+		args = append(args, f.Category)
+		argIndex++
+	}
+
+	if f.MinPrice != nil {
+		query += ` AND ticket_price >= $` + fmt.Sprintf("%d", argIndex)
+		args = append(args, *f.MinPrice)
+		argIndex++
+	}
+
+	if f.MaxPrice != nil {
+		query += ` AND ticket_price <= $` + fmt.Sprintf("%d", argIndex)
+		args = append(args, *f.MaxPrice)
+		argIndex++
+	}
+
+	query += ` ORDER BY distance_km ASC, start_time ASC LIMIT $` + fmt.Sprintf("%d", argIndex) + ` OFFSET $` + fmt.Sprintf("%d", argIndex+1)
+	args = append(args, limit, offset)
+
+	rows, err := r.db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*NearbyEvent{}
+	for rows.Next() {
+		event := &Event{}
+		var distance float64
+		err := rows.Scan(
+			&event.ID, &event.Name, &event.Venue, &event.StartTime, &event.EndTime,
+			&event.Category, &event.Capacity, &event.Reserved, &event.Metadata,
+			&event.Status, &event.TicketPrice, &event.CancellationFee, &event.Likes,
+			&event.MaximumTicketsPerBooking, &event.OrganizerID, &event.Latitude, &event.Longitude, &event.WaitlistEnabled, &event.SeatSelectionEnabled, &event.LikesEnabled, &event.NoSingleSeat, &event.PaymentCapture, &event.CaptureAt, &event.Currency, &event.Visibility, &event.CreatedAt, &event.UpdatedAt,
+			&distance,
+		)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, &NearbyEvent{Event: event, DistanceKm: distance})
+	}
 
-	return int(result.RowsAffected()), nil
+	return events, rows.Err()
 }
@@ -0,0 +1,54 @@
+package events
+
+import (
+	"context"
+	"time"
+)
+
+// FeedPosition is where a feed page ends: the updated_at and ID of its last event.
+type FeedPosition struct {
+	UpdatedAt time.Time `json:"u"`
+	ID        string    `json:"id"`
+}
+
+// ListFeed returns public events in (updated_at, id) order, the order the syndication feed
+// pages in. since keeps events updated after it; after starts past a previous page. Both
+// may be nil. Unlike the listings, ended and cancelled events stay in, so aggregators see
+// them change status.
+func (r *EventsRepository) ListFeed(ctx context.Context, since *time.Time, after *FeedPosition, limit int) ([]*Event, error) {
+	var afterAt *time.Time
+	var afterID *string
+	if after != nil {
+		afterAt, afterID = &after.UpdatedAt, &after.ID
+	}
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT id, name, venue, start_time, end_time, category, capacity, reserved, metadata, 
+		       status, ticket_price, cancellation_fee, likes, maximum_tickets_per_booking, organizer_id, latitude, longitude, waitlist_enabled, seat_selection_enabled, likes_enabled, no_single_seat, payment_capture, capture_at, currency, visibility, created_at, updated_at
+		FROM events
+		WHERE visibility = 'public'
+		  AND ($1::timestamptz IS NULL OR updated_at > $1)
+		  AND ($2::timestamptz IS NULL OR (updated_at, id) > ($2, $3::uuid))
+		ORDER BY updated_at, id
+		LIMIT $4
+	`, since, afterAt, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*Event
+	for rows.Next() {
+		event := &Event{}
+		err := rows.Scan(
+			&event.ID, &event.Name, &event.Venue, &event.StartTime, &event.EndTime,
+			&event.Category, &event.Capacity, &event.Reserved, &event.Metadata,
+			&event.Status, &event.TicketPrice, &event.CancellationFee, &event.Likes,
+			&event.MaximumTicketsPerBooking, &event.OrganizerID, &event.Latitude, &event.Longitude, &event.WaitlistEnabled, &event.SeatSelectionEnabled, &event.LikesEnabled, &event.NoSingleSeat, &event.PaymentCapture, &event.CaptureAt, &event.Currency, &event.Visibility, &event.CreatedAt, &event.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
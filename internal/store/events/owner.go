@@ -0,0 +1,55 @@
+package events
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Ownership reports whether the event exists and whether userID owns it.
+func (r *EventsRepository) Ownership(ctx context.Context, eventID, userID string) (exists, owns bool, err error) {
+	err = r.db.Pool.QueryRow(ctx, `
+		SELECT COALESCE(owner_id = $2::uuid, false) FROM events WHERE id = $1`, eventID, userID).Scan(&owns)
+	if err == pgx.ErrNoRows {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+	return true, owns, nil
+}
+
+// ListByOwner pages through every event the user owns, whatever its status or visibility,
+// soonest first.
+func (r *EventsRepository) ListByOwner(ctx context.Context, ownerID string, limit, offset int) ([]*Event, error) {
+	query := `
+		SELECT id, name, venue, start_time, end_time, category, capacity, reserved, metadata,
+		       status, ticket_price, cancellation_fee, likes, maximum_tickets_per_booking, organizer_id, latitude, longitude, waitlist_enabled, seat_selection_enabled, likes_enabled, no_single_seat, payment_capture, capture_at, currency, visibility, sandbox, owner_id, created_at, updated_at
+		FROM events
+		WHERE owner_id = $1
+		ORDER BY start_time ASC, id ASC
+		LIMIT $2 OFFSET $3`
+
+	rows, err := r.db.Pool.Query(ctx, query, ownerID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*Event{}
+	for rows.Next() {
+		event := &Event{}
+		err := rows.Scan(
+			&event.ID, &event.Name, &event.Venue, &event.StartTime, &event.EndTime,
+			&event.Category, &event.Capacity, &event.Reserved, &event.Metadata,
+			&event.Status, &event.TicketPrice, &event.CancellationFee, &event.Likes,
+			&event.MaximumTicketsPerBooking, &event.OrganizerID, &event.Latitude, &event.Longitude, &event.WaitlistEnabled, &event.SeatSelectionEnabled, &event.LikesEnabled, &event.NoSingleSeat, &event.PaymentCapture, &event.CaptureAt, &event.Currency, &event.Visibility, &event.Sandbox, &event.OwnerID, &event.CreatedAt, &event.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
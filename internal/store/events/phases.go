@@ -0,0 +1,245 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrSalePhaseInUse is returned when a sale phase that bookings were sold in would be removed.
+var ErrSalePhaseInUse = errors.New("sale phase has bookings")
+
+// SalePhase is one of the consecutive phases an event is sold in (early bird, regular,
+// door), with its own price, sale window and quantity. Its pool is Quantity plus what
+// earlier phases rolled into it minus what it rolled on once its window ended.
+type SalePhase struct {
+	ID        string    `json:"id"`
+	EventID   string    `json:"event_id"`
+	Name      string    `json:"name"`
+	Position  int       `json:"position"`
+	Price     float64   `json:"price"`
+	Quantity  int       `json:"quantity"`
+	RolledIn  int       `json:"rolled_in"`
+	RolledOut int       `json:"rolled_out"`
+	StartsAt  time.Time `json:"starts_at"`
+	EndsAt    time.Time `json:"ends_at"`
+	// Sold is the seats of the phase's pending and booked bookings
+	Sold      int       `json:"sold"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Pool is how many seats the phase can sell.
+func (p *SalePhase) Pool() int { return p.Quantity + p.RolledIn - p.RolledOut }
+
+// Remaining is how many seats of the phase's pool are unsold.
+func (p *SalePhase) Remaining() int {
+	if n := p.Pool() - p.Sold; n > 0 {
+		return n
+	}
+	return 0
+}
+
+// OpenAt reports whether the phase's sale window includes t.
+func (p *SalePhase) OpenAt(t time.Time) bool { return !t.Before(p.StartsAt) && t.Before(p.EndsAt) }
+
+// Rollover is unsold quantity an ended phase handed to the next one.
+type Rollover struct {
+	EventID string
+	From    string
+	To      string
+	Seats   int
+}
+
+// salePhaseSold is the seats sold in phase p, for queries over event_sale_phases p.
+const salePhaseSold = `
+	COALESCE((
+		SELECT SUM(jsonb_array_length(COALESCE(b.seats, '[]'::jsonb)))
+		FROM bookings b
+		WHERE b.event_id = p.event_id AND b.sale_phase_id = p.id AND b.status IN ('pending', 'booked')
+	), 0)`
+
+// SalePhases returns the event's sale phases in order, with what each has sold. An event
+// sold without phases has none.
+func (r *EventsRepository) SalePhases(ctx context.Context, eventID string) ([]*SalePhase, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT p.id, p.event_id, p.name, p.position, p.price, p.quantity, p.rolled_in, p.rolled_out,
+		       p.starts_at, p.ends_at, `+salePhaseSold+`, p.created_at, p.updated_at
+		FROM event_sale_phases p
+		WHERE p.event_id = $1
+		ORDER BY p.position`, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanSalePhases(rows)
+}
+
+func scanSalePhases(rows pgx.Rows) ([]*SalePhase, error) {
+	phases := []*SalePhase{}
+	for rows.Next() {
+		p := &SalePhase{}
+		if err := rows.Scan(&p.ID, &p.EventID, &p.Name, &p.Position, &p.Price, &p.Quantity, &p.RolledIn, &p.RolledOut,
+			&p.StartsAt, &p.EndsAt, &p.Sold, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		phases = append(phases, p)
+	}
+	return phases, rows.Err()
+}
+
+// ExpectedPhaseTokens returns how many tokens the phase's Redis bucket should hold: its
+// pool minus the seats of its pending and booked bookings.
+func (r *EventsRepository) ExpectedPhaseTokens(ctx context.Context, phaseID string) (int, error) {
+	var tokens int
+	err := r.db.Pool.QueryRow(ctx, `
+		SELECT p.quantity + p.rolled_in - p.rolled_out - `+salePhaseSold+`
+		FROM event_sale_phases p
+		WHERE p.id = $1`, phaseID).Scan(&tokens)
+	if err != nil {
+		return 0, err
+	}
+	if tokens < 0 {
+		tokens = 0
+	}
+	return tokens, nil
+}
+
+// SetSalePhases replaces the event's sale phases with phases, in order. Phases are matched
+// to the existing ones by name, so a phase that already sold keeps its ID and bookings;
+// removing one with bookings fails with ErrSalePhaseInUse. Rollovers are undone, for the
+// next RollOverSalePhases to redo against the new windows and quantities. An empty
+// phases sells the event without phases again.
+func (r *EventsRepository) SetSalePhases(ctx context.Context, eventID string, phases []*SalePhase) ([]*SalePhase, error) {
+	names := make([]string, len(phases))
+	for i, p := range phases {
+		names[i] = p.Name
+	}
+	err := r.db.WithTx(ctx, func(tx pgx.Tx) error {
+		var inUse bool
+		err := tx.QueryRow(ctx, `
+			SELECT EXISTS (
+				SELECT 1 FROM event_sale_phases p
+				JOIN bookings b ON b.event_id = p.event_id AND b.sale_phase_id = p.id
+				WHERE p.event_id = $1 AND p.name <> ALL($2::text[])
+			)`, eventID, names).Scan(&inUse)
+		if err != nil {
+			return err
+		}
+		if inUse {
+			return ErrSalePhaseInUse
+		}
+		if _, err := tx.Exec(ctx, `DELETE FROM event_sale_phases WHERE event_id = $1 AND name <> ALL($2::text[])`, eventID, names); err != nil {
+			return err
+		}
+		// Out of the way of the new positions, which are unique per event
+		if _, err := tx.Exec(ctx, `UPDATE event_sale_phases SET position = -position - 1 WHERE event_id = $1`, eventID); err != nil {
+			return err
+		}
+		for i, p := range phases {
+			_, err := tx.Exec(ctx, `
+				INSERT INTO event_sale_phases (event_id, name, position, price, quantity, starts_at, ends_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7)
+				ON CONFLICT (event_id, name) DO UPDATE
+				SET position = EXCLUDED.position, price = EXCLUDED.price, quantity = EXCLUDED.quantity,
+				    starts_at = EXCLUDED.starts_at, ends_at = EXCLUDED.ends_at,
+				    rolled_in = 0, rolled_out = 0, updated_at = now()`,
+				eventID, p.Name, i, p.Price, p.Quantity, p.StartsAt, p.EndsAt)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.SalePhases(ctx, eventID)
+}
+
+// EventsWithEndedSalePhases returns the events with a sale phase that ended at or before
+// cutoff and a later phase it could roll over into, up to limit, for events not yet over.
+func (r *EventsRepository) EventsWithEndedSalePhases(ctx context.Context, cutoff time.Time, limit int) ([]string, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT DISTINCT p.event_id
+		FROM event_sale_phases p
+		JOIN events e ON e.id = p.event_id
+		WHERE p.ends_at <= $1
+		  AND e.status NOT IN ('expired', 'cancelled')
+		  AND EXISTS (SELECT 1 FROM event_sale_phases n WHERE n.event_id = p.event_id AND n.position > p.position)
+		LIMIT $2`, cutoff, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// RollOverSalePhases moves the unsold quantity of each of the event's phases that ended at
+// or before cutoff into the phase after it, in order, so quantity an early phase didn't sell
+// carries on down the line. The phases are locked while this runs. Seats a phase frees
+// after it rolled over (cancellations) roll on the next time round. It returns the moves
+// made.
+func (r *EventsRepository) RollOverSalePhases(ctx context.Context, eventID string, cutoff time.Time) ([]Rollover, error) {
+	var moves []Rollover
+	err := r.db.WithTx(ctx, func(tx pgx.Tx) error {
+		moves = nil
+		rows, err := tx.Query(ctx, `
+			SELECT p.id, p.event_id, p.name, p.position, p.price, p.quantity, p.rolled_in, p.rolled_out,
+			       p.starts_at, p.ends_at, `+salePhaseSold+`, p.created_at, p.updated_at
+			FROM event_sale_phases p
+			WHERE p.event_id = $1
+			ORDER BY p.position
+			FOR UPDATE`, eventID)
+		if err != nil {
+			return err
+		}
+		phases, err := scanSalePhases(rows)
+		rows.Close()
+		if err != nil {
+			return err
+		}
+
+		for i := 0; i+1 < len(phases); i++ {
+			from, to := phases[i], phases[i+1]
+			if from.EndsAt.After(cutoff) {
+				break
+			}
+			unsold := from.Pool() - from.Sold
+			if unsold <= 0 {
+				continue
+			}
+			from.RolledOut += unsold
+			to.RolledIn += unsold
+			moves = append(moves, Rollover{EventID: eventID, From: from.ID, To: to.ID, Seats: unsold})
+		}
+		for _, m := range moves {
+			if _, err := tx.Exec(ctx, `
+				UPDATE event_sale_phases SET rolled_out = rolled_out + $2, updated_at = now() WHERE id = $1
+			`, m.From, m.Seats); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx, `
+				UPDATE event_sale_phases SET rolled_in = rolled_in + $2, updated_at = now() WHERE id = $1
+			`, m.To, m.Seats); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return moves, nil
+}
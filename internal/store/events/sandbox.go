@@ -0,0 +1,69 @@
+package events
+
+import (
+	"context"
+	"time"
+)
+
+// SandboxTester is a user allowed to book a sandbox event.
+type SandboxTester struct {
+	UserID    string    `json:"user_id"`
+	Email     string    `json:"email"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CanRehearse reports whether the user may book the sandbox event: admins always can, anyone
+// else only from its tester allowlist.
+func (r *EventsRepository) CanRehearse(ctx context.Context, eventID, userID string) (bool, error) {
+	var ok bool
+	err := r.db.Pool.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM users u
+			WHERE u.id = $2
+			  AND (u.role = 'admin' OR EXISTS (SELECT 1 FROM sandbox_testers t WHERE t.event_id = $1 AND t.user_id = u.id))
+		)`, eventID, userID).Scan(&ok)
+	return ok, err
+}
+
+// AddSandboxTester puts the user on the event's allowlist; adding them twice is a no-op.
+func (r *EventsRepository) AddSandboxTester(ctx context.Context, eventID, userID string) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		INSERT INTO sandbox_testers (event_id, user_id) VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`, eventID, userID)
+	return err
+}
+
+// RemoveSandboxTester takes the user off the event's allowlist, reporting whether they were on it.
+func (r *EventsRepository) RemoveSandboxTester(ctx context.Context, eventID, userID string) (bool, error) {
+	result, err := r.db.Pool.Exec(ctx, `DELETE FROM sandbox_testers WHERE event_id = $1 AND user_id = $2`, eventID, userID)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// SandboxTesters lists the event's allowlist, earliest added first.
+func (r *EventsRepository) SandboxTesters(ctx context.Context, eventID string) ([]*SandboxTester, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT u.id, u.email, u.name, t.created_at
+		FROM sandbox_testers t
+		JOIN users u ON u.id = t.user_id
+		WHERE t.event_id = $1
+		ORDER BY t.created_at, u.email
+	`, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	testers := []*SandboxTester{}
+	for rows.Next() {
+		t := &SandboxTester{}
+		if err := rows.Scan(&t.UserID, &t.Email, &t.Name, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		testers = append(testers, t)
+	}
+	return testers, rows.Err()
+}
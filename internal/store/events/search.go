@@ -0,0 +1,127 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// How a search result matched: on the full-text vector, or by trigram similarity when the
+// query matched nothing as words.
+const (
+	MatchText  = "text"
+	MatchFuzzy = "fuzzy"
+)
+
+// SearchFilter is a full-text search with optional filters. Nil or empty filters don't filter.
+type SearchFilter struct {
+	Query    string
+	Category string
+	MinPrice *float64
+	MaxPrice *float64
+	From     *time.Time
+	To       *time.Time
+}
+
+// SearchResult is an event with how well it matched.
+type SearchResult struct {
+	*Event
+	Rank  float64 `json:"rank"`
+	Match string  `json:"match"`
+}
+
+// Search returns public events that haven't ended or been cancelled and match f.Query,
+// best match first. The query is parsed like a web search box (quoted phrases, or, -word)
+// and matched against the name, venue, category and metadata strings, names weighing most.
+// If nothing matches at all, say because of a typo, names and venues similar to the query
+// are returned instead.
+func (r *EventsRepository) Search(ctx context.Context, f SearchFilter, limit, offset int) ([]*SearchResult, error) {
+	results, err := r.search(ctx, MatchText, f, limit, offset)
+	if err != nil || len(results) > 0 {
+		return results, err
+	}
+	if offset > 0 {
+		// An empty later page is the end of the text matches, not a miss
+		hits, err := r.search(ctx, MatchText, f, 1, 0)
+		if err != nil || len(hits) > 0 {
+			return []*SearchResult{}, err
+		}
+	}
+	return r.search(ctx, MatchFuzzy, f, limit, offset)
+}
+
+func (r *EventsRepository) search(ctx context.Context, match string, f SearchFilter, limit, offset int) ([]*SearchResult, error) {
+	rank := `ts_rank_cd(search_vector, websearch_to_tsquery('english', $1))`
+	cond := `search_vector @@ websearch_to_tsquery('english', $1)`
+	if match == MatchFuzzy {
+		rank = `GREATEST(similarity(name, $1), similarity(venue, $1))`
+		cond = `(name % $1 OR venue % $1)`
+	}
+	query := `
+		SELECT id, name, venue, start_time, end_time, category, capacity, reserved, metadata,
+		       status, ticket_price, cancellation_fee, likes, maximum_tickets_per_booking, organizer_id, latitude, longitude, waitlist_enabled, seat_selection_enabled, likes_enabled, no_single_seat, payment_capture, capture_at, currency, visibility, created_at, updated_at,
+		       (` + rank + `)::float8 AS rank
+		FROM events
+		WHERE ` + cond + `
+		  AND visibility = 'public' AND status NOT IN ('cancelled', 'expired') AND end_time > now()`
+
+	args := []interface{}{f.Query}
+	argIndex := 2
+
+	if f.Category != "" {
+		query += ` AND category = $` + fmt.Sprintf("%d", argIndex)
+		args = append(args, f.Category)
+		argIndex++
+	}
+
+	if f.MinPrice != nil {
+		query += ` AND ticket_price >= $` + fmt.Sprintf("%d", argIndex)
+		args = append(args, *f.MinPrice)
+		argIndex++
+	}
+
+	if f.MaxPrice != nil {
+		query += ` AND ticket_price <= $` + fmt.Sprintf("%d", argIndex)
+		args = append(args, *f.MaxPrice)
+		argIndex++
+	}
+
+	if f.From != nil {
+		query += ` AND start_time >= $` + fmt.Sprintf("%d", argIndex)
+		args = append(args, *f.From)
+		argIndex++
+	}
+
+	if f.To != nil {
+		query += ` AND start_time <= $` + fmt.Sprintf("%d", argIndex)
+		args = append(args, *f.To)
+		argIndex++
+	}
+
+	query += ` ORDER BY rank DESC, start_time ASC, id LIMIT $` + fmt.Sprintf("%d", argIndex) + ` OFFSET $` + fmt.Sprintf("%d", argIndex+1)
+	args = append(args, limit, offset)
+
+	rows, err := r.db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []*SearchResult{}
+	for rows.Next() {
+		event := &Event{}
+		var rank float64
+		err := rows.Scan(
+			&event.ID, &event.Name, &event.Venue, &event.StartTime, &event.EndTime,
+			&event.Category, &event.Capacity, &event.Reserved, &event.Metadata,
+			&event.Status, &event.TicketPrice, &event.CancellationFee, &event.Likes,
+			&event.MaximumTicketsPerBooking, &event.OrganizerID, &event.Latitude, &event.Longitude, &event.WaitlistEnabled, &event.SeatSelectionEnabled, &event.LikesEnabled, &event.NoSingleSeat, &event.PaymentCapture, &event.CaptureAt, &event.Currency, &event.Visibility, &event.CreatedAt, &event.UpdatedAt,
+			&rank,
+		)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, &SearchResult{Event: event, Rank: rank, Match: match})
+	}
+	return results, rows.Err()
+}
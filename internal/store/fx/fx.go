@@ -0,0 +1,78 @@
+package fx
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store"
+)
+
+// Snapshot is one day's exchange rates: Rates[c] is units of c per one unit of Base.
+type Snapshot struct {
+	AsOf      time.Time          `json:"as_of"`
+	Base      string             `json:"base"`
+	Rates     map[string]float64 `json:"rates"`
+	FetchedAt time.Time          `json:"fetched_at"`
+}
+
+type FXRepository struct {
+	db  *store.DB
+	log *zap.Logger
+}
+
+func NewFXRepository(db *store.DB, log *zap.Logger) *FXRepository {
+	return &FXRepository{db: db, log: log}
+}
+
+// Save stores snap, replacing any rates already stored for the same day.
+func (r *FXRepository) Save(ctx context.Context, snap *Snapshot) error {
+	return r.db.WithTx(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `DELETE FROM fx_rates WHERE as_of = $1`, snap.AsOf); err != nil {
+			return err
+		}
+		for currency, rate := range snap.Rates {
+			_, err := tx.Exec(ctx, `
+				INSERT INTO fx_rates (as_of, currency, base, rate, fetched_at)
+				VALUES ($1, $2, $3, $4, $5)
+			`, snap.AsOf, currency, snap.Base, rate, snap.FetchedAt)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Latest returns the most recent snapshot, or nil if none has been fetched yet.
+func (r *FXRepository) Latest(ctx context.Context) (*Snapshot, error) {
+	query := `
+		SELECT as_of, currency, base, rate, fetched_at
+		FROM fx_rates
+		WHERE as_of = (SELECT MAX(as_of) FROM fx_rates)`
+
+	rows, err := r.db.Pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snap *Snapshot
+	for rows.Next() {
+		var currency string
+		var rate float64
+		s := Snapshot{}
+		if err := rows.Scan(&s.AsOf, &currency, &s.Base, &rate, &s.FetchedAt); err != nil {
+			return nil, err
+		}
+		if snap == nil {
+			s.Rates = make(map[string]float64)
+			snap = &s
+		}
+		snap.Rates[currency] = rate
+	}
+
+	return snap, rows.Err()
+}
@@ -0,0 +1,82 @@
+package invitations
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// NewInvitee is a row of an invitee import: the account with Email is invited, or created
+// with Name and PasswordHash if there is none.
+type NewInvitee struct {
+	Email        string
+	Name         string
+	PasswordHash string
+}
+
+// ImportedInvitation is what importing one invitee did. NewUser is set when the account was
+// created, Issued when the invitation was; an invitee already invited keeps their code.
+type ImportedInvitation struct {
+	Invitation *Invitation
+	Email      string
+	NewUser    bool
+	Issued     bool
+}
+
+// ErrCodesExhausted is returned by Import when no free code was found for an invitee.
+var ErrCodesExhausted = errors.New("could not allocate a unique invitation code")
+
+// Import invites every invitee to the event in one transaction, creating the accounts that
+// don't exist yet, so a failure partway through leaves none of them created or invited.
+// code returns a fresh invitation code; a code already taken is replaced up to attempts times.
+func (r *InvitationsRepository) Import(ctx context.Context, eventID string, invitees []NewInvitee, code func() (string, error), attempts int) ([]*ImportedInvitation, error) {
+	out := make([]*ImportedInvitation, 0, len(invitees))
+	err := r.db.WithTx(ctx, func(tx pgx.Tx) error {
+		for _, in := range invitees {
+			userID, created, err := inviteeUser(ctx, tx, in)
+			if err != nil {
+				return err
+			}
+			res := &ImportedInvitation{Email: in.Email, NewUser: created}
+			for i := 0; i < attempts && res.Invitation == nil; i++ {
+				c, err := code()
+				if err != nil {
+					return err
+				}
+				if res.Invitation, res.Issued, err = issue(ctx, tx, eventID, userID, c); err != nil {
+					return err
+				}
+			}
+			if res.Invitation == nil {
+				return ErrCodesExhausted
+			}
+			out = append(out, res)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// inviteeUser returns the ID of the account with the invitee's email, creating it if there
+// is none.
+func inviteeUser(ctx context.Context, tx pgx.Tx, in NewInvitee) (string, bool, error) {
+	var id string
+	err := tx.QueryRow(ctx, `
+		INSERT INTO users (name, email, password_hash, role)
+		VALUES ($1, $2, $3, 'user')
+		ON CONFLICT (email) DO NOTHING
+		RETURNING id
+	`, in.Name, in.Email, in.PasswordHash).Scan(&id)
+	if err == nil {
+		return id, true, nil
+	}
+	if err != pgx.ErrNoRows {
+		return "", false, err
+	}
+	err = tx.QueryRow(ctx, `SELECT id FROM users WHERE email = $1`, in.Email).Scan(&id)
+	return id, false, err
+}
@@ -0,0 +1,149 @@
+package invitations
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store"
+)
+
+// Invitation grants one user access to a private event once its code is redeemed.
+type Invitation struct {
+	ID         string     `json:"id"`
+	EventID    string     `json:"event_id"`
+	UserID     string     `json:"user_id"`
+	Code       string     `json:"code"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RedeemedAt *time.Time `json:"redeemed_at,omitempty"`
+}
+
+// Invitee is an invitation with its user's email and whether they have booked, for admin listings.
+type Invitee struct {
+	Invitation
+	Email  string `json:"email"`
+	Name   string `json:"name"`
+	Booked bool   `json:"booked"`
+}
+
+type InvitationsRepository struct {
+	db  *store.DB
+	log *zap.Logger
+}
+
+func NewInvitationsRepository(db *store.DB, log *zap.Logger) *InvitationsRepository {
+	return &InvitationsRepository{db: db, log: log}
+}
+
+// issue invites the user to the event with code. If the user is already invited their
+// existing invitation is returned unchanged and created is false. It returns nil without
+// error if code is already taken by another invitation.
+func issue(ctx context.Context, tx pgx.Tx, eventID, userID, code string) (inv *Invitation, created bool, err error) {
+	inv = &Invitation{}
+	err = tx.QueryRow(ctx, `
+		INSERT INTO event_invitations (event_id, user_id, code)
+		VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING
+		RETURNING id, event_id, user_id, code, created_at, redeemed_at
+	`, eventID, userID, code).Scan(&inv.ID, &inv.EventID, &inv.UserID, &inv.Code, &inv.CreatedAt, &inv.RedeemedAt)
+	if err == nil {
+		return inv, true, nil
+	}
+	if err != pgx.ErrNoRows {
+		return nil, false, err
+	}
+
+	err = tx.QueryRow(ctx, `
+		SELECT id, event_id, user_id, code, created_at, redeemed_at
+		FROM event_invitations
+		WHERE event_id = $1 AND user_id = $2
+	`, eventID, userID).Scan(&inv.ID, &inv.EventID, &inv.UserID, &inv.Code, &inv.CreatedAt, &inv.RedeemedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return inv, false, nil
+}
+
+// GetByCode returns the event's invitation with the given code, or nil if there is none.
+func (r *InvitationsRepository) GetByCode(ctx context.Context, eventID, code string) (*Invitation, error) {
+	inv := &Invitation{}
+	err := r.db.Pool.QueryRow(ctx, `
+		SELECT id, event_id, user_id, code, created_at, redeemed_at
+		FROM event_invitations
+		WHERE event_id = $1 AND code = $2
+	`, eventID, code).Scan(&inv.ID, &inv.EventID, &inv.UserID, &inv.Code, &inv.CreatedAt, &inv.RedeemedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return inv, nil
+}
+
+// Redeem stamps the invitation as redeemed and returns it. Redeeming twice keeps the
+// first redemption time.
+func (r *InvitationsRepository) Redeem(ctx context.Context, id string) (*Invitation, error) {
+	inv := &Invitation{}
+	err := r.db.Pool.QueryRow(ctx, `
+		UPDATE event_invitations
+		SET redeemed_at = COALESCE(redeemed_at, now())
+		WHERE id = $1
+		RETURNING id, event_id, user_id, code, created_at, redeemed_at
+	`, id).Scan(&inv.ID, &inv.EventID, &inv.UserID, &inv.Code, &inv.CreatedAt, &inv.RedeemedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return inv, nil
+}
+
+// HasRedeemed reports whether the user holds a redeemed invitation to the event.
+func (r *InvitationsRepository) HasRedeemed(ctx context.Context, eventID, userID string) (bool, error) {
+	var ok bool
+	err := r.db.Pool.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM event_invitations
+			WHERE event_id = $1 AND user_id = $2 AND redeemed_at IS NOT NULL
+		)
+	`, eventID, userID).Scan(&ok)
+	return ok, err
+}
+
+// ListByEvent returns the event's invitees, oldest invitation first.
+func (r *InvitationsRepository) ListByEvent(ctx context.Context, eventID string) ([]*Invitee, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT i.id, i.event_id, i.user_id, i.code, i.created_at, i.redeemed_at, u.email, u.name,
+		       EXISTS (
+		           SELECT 1 FROM bookings b
+		           WHERE b.event_id = i.event_id AND b.user_id = i.user_id AND b.status = 'booked'
+		       )
+		FROM event_invitations i
+		JOIN users u ON u.id = i.user_id
+		WHERE i.event_id = $1
+		ORDER BY i.created_at, u.email
+	`, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []*Invitee{}
+	for rows.Next() {
+		inv := &Invitee{}
+		err := rows.Scan(&inv.ID, &inv.EventID, &inv.UserID, &inv.Code, &inv.CreatedAt, &inv.RedeemedAt,
+			&inv.Email, &inv.Name, &inv.Booked)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, inv)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,185 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store"
+)
+
+const (
+	StateQueued    = "queued"
+	StateRunning   = "running"
+	StateCompleted = "completed"
+	StateFailed    = "failed"
+)
+
+// Job is a long-running admin operation and how far it got. Processed counts items done so
+// far, split into Succeeded and Failed, out of Total once that is known.
+type Job struct {
+	ID           string          `json:"id"`
+	Kind         string          `json:"kind"`
+	EventID      *string         `json:"event_id,omitempty"`
+	State        string          `json:"state"`
+	Total        int             `json:"total"`
+	Processed    int             `json:"processed"`
+	Succeeded    int             `json:"succeeded"`
+	Failed       int             `json:"failed"`
+	ErrorSamples []string        `json:"error_samples"`
+	Result       json.RawMessage `json:"result,omitempty"`
+	Error        *string         `json:"error,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+	StartedAt    *time.Time      `json:"started_at,omitempty"`
+	FinishedAt   *time.Time      `json:"finished_at,omitempty"`
+}
+
+type JobsRepository struct {
+	db  *store.DB
+	log *zap.Logger
+}
+
+func NewJobsRepository(db *store.DB, log *zap.Logger) *JobsRepository {
+	return &JobsRepository{db: db, log: log}
+}
+
+const jobColumns = `id, kind, event_id, state, total, processed, succeeded, failed, error_samples, result, error,
+	created_at, updated_at, started_at, finished_at`
+
+func scanJob(row pgx.Row) (*Job, error) {
+	j := &Job{}
+	var samples, result []byte
+	err := row.Scan(&j.ID, &j.Kind, &j.EventID, &j.State, &j.Total, &j.Processed, &j.Succeeded, &j.Failed,
+		&samples, &result, &j.Error, &j.CreatedAt, &j.UpdatedAt, &j.StartedAt, &j.FinishedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(samples, &j.ErrorSamples); err != nil {
+		return nil, err
+	}
+	if result != nil {
+		j.Result = result
+	}
+	return j, nil
+}
+
+// Create queues a job of kind.
+func (r *JobsRepository) Create(ctx context.Context, kind string, eventID *string) (*Job, error) {
+	return scanJob(r.db.Pool.QueryRow(ctx, `
+		INSERT INTO admin_jobs (kind, event_id)
+		VALUES ($1, $2)
+		RETURNING `+jobColumns, kind, eventID))
+}
+
+// Get returns the job, or nil if there is none.
+func (r *JobsRepository) Get(ctx context.Context, id string) (*Job, error) {
+	j, err := scanJob(r.db.Pool.QueryRow(ctx, `SELECT `+jobColumns+` FROM admin_jobs WHERE id = $1`, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return j, nil
+}
+
+// List returns jobs newest first, only those of kind and in state when they're set.
+func (r *JobsRepository) List(ctx context.Context, kind, state string, limit, offset int) ([]*Job, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT `+jobColumns+`
+		FROM admin_jobs
+		WHERE ($1 = '' OR kind = $1) AND ($2 = '' OR state = $2)
+		ORDER BY created_at DESC
+		LIMIT $3 OFFSET $4
+	`, kind, state, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []*Job{}
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, j)
+	}
+	return out, rows.Err()
+}
+
+// Start marks a queued job running.
+func (r *JobsRepository) Start(ctx context.Context, id string) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		UPDATE admin_jobs
+		SET state = 'running', started_at = now(), updated_at = now()
+		WHERE id = $1 AND state = 'queued'
+	`, id)
+	return err
+}
+
+// SetTotal records how many items the job will process.
+func (r *JobsRepository) SetTotal(ctx context.Context, id string, total int) error {
+	_, err := r.db.Pool.Exec(ctx, `UPDATE admin_jobs SET total = $2, updated_at = now() WHERE id = $1`, id, total)
+	return err
+}
+
+// AddProgress adds to the job's counters and appends samples to its error samples.
+func (r *JobsRepository) AddProgress(ctx context.Context, id string, succeeded, failed int, samples []string) error {
+	if samples == nil {
+		samples = []string{}
+	}
+	raw, err := json.Marshal(samples)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Pool.Exec(ctx, `
+		UPDATE admin_jobs
+		SET processed = processed + $2 + $3,
+		    succeeded = succeeded + $2,
+		    failed = failed + $3,
+		    error_samples = error_samples || $4::jsonb,
+		    updated_at = now()
+		WHERE id = $1
+	`, id, succeeded, failed, raw)
+	return err
+}
+
+// Finish ends the job: completed with result, or failed with errMsg when it's set.
+func (r *JobsRepository) Finish(ctx context.Context, id string, result json.RawMessage, errMsg *string) error {
+	state := StateCompleted
+	if errMsg != nil {
+		state = StateFailed
+	}
+	_, err := r.db.Pool.Exec(ctx, `
+		UPDATE admin_jobs
+		SET state = $2, result = $3, error = $4, finished_at = now(), updated_at = now()
+		WHERE id = $1
+	`, id, state, result, errMsg)
+	return err
+}
+
+// Touch marks the jobs as still being worked on.
+func (r *JobsRepository) Touch(ctx context.Context, ids []string) error {
+	_, err := r.db.Pool.Exec(ctx, `UPDATE admin_jobs SET updated_at = now() WHERE id = ANY($1::uuid[])`, ids)
+	return err
+}
+
+// FailStale fails queued and running jobs not touched within timeout, whose server must
+// have stopped, and returns how many it failed.
+func (r *JobsRepository) FailStale(ctx context.Context, timeout time.Duration) (int64, error) {
+	tag, err := r.db.Pool.Exec(ctx, `
+		UPDATE admin_jobs
+		SET state = 'failed', error = 'interrupted: the server running the job stopped',
+		    finished_at = now(), updated_at = now()
+		WHERE state IN ('queued', 'running') AND updated_at < now() - make_interval(secs => $1)
+	`, timeout.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
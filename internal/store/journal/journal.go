@@ -0,0 +1,56 @@
+package journal
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store"
+)
+
+// Outcomes of a handled message.
+const (
+	OutcomeSucceeded    = "succeeded"
+	OutcomeDeadLettered = "dead_lettered"
+	// The handler failed and so did parking the message in the DLQ; Kafka redelivers it
+	OutcomeFailed = "failed"
+	// The worker stopped between retries; Kafka redelivers it
+	OutcomeAbandoned = "abandoned"
+	// The message had been handled already, before a commit that didn't happen
+	OutcomeDuplicate = "duplicate"
+)
+
+// Entry is one Kafka message the finalizer handled and how that ended.
+type Entry struct {
+	// BookingID is empty for a message that couldn't be decoded
+	BookingID string
+	Topic     string
+	Partition int
+	Offset    int64
+	Type      string
+	Outcome   string
+	Attempts  int
+	Error     string
+	Duration  time.Duration
+}
+
+// JournalRepository records the finalizer's handling of Kafka messages, so a booking's
+// trace can show what the worker did with its messages.
+type JournalRepository struct {
+	db  *store.DB
+	log *zap.Logger
+}
+
+func NewJournalRepository(db *store.DB, log *zap.Logger) *JournalRepository {
+	return &JournalRepository{db: db, log: log}
+}
+
+// Record adds e to the journal.
+func (r *JournalRepository) Record(ctx context.Context, e *Entry) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		INSERT INTO message_journal (booking_id, topic, partition, message_offset, type, outcome, attempts, error, duration_ms)
+		VALUES (NULLIF($1, '')::uuid, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9)
+	`, e.BookingID, e.Topic, e.Partition, e.Offset, e.Type, e.Outcome, e.Attempts, e.Error, e.Duration.Milliseconds())
+	return err
+}
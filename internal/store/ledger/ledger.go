@@ -0,0 +1,171 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store"
+)
+
+// Message identifies a Kafka message: a key always goes to the same partition, where the
+// offset is unique.
+type Message struct {
+	Topic     string
+	Key       string
+	Partition int
+	Offset    int64
+}
+
+// claimAttempts bounds Claim's retries when the claim it found is released meanwhile.
+const claimAttempts = 3
+
+// DefaultLease is how long a claim stays live without being renewed.
+const DefaultLease = 2 * time.Minute
+
+// ErrClaimLost is returned by Renew when the caller's claim was taken over or released.
+var ErrClaimLost = errors.New("message claim is no longer held")
+
+// Claim states.
+const (
+	// ClaimTaken is a new claim: the caller handles the message
+	ClaimTaken = "taken"
+	// ClaimTakenOver is a claim whose holder stopped renewing it, now the caller's: the
+	// caller handles the message, some of whose side effects may have happened
+	ClaimTakenOver = "taken_over"
+	// ClaimProcessed is a message handled before
+	ClaimProcessed = "processed"
+	// ClaimHeld is a message another consumer is handling under a live claim
+	ClaimHeld = "held"
+)
+
+// LedgerRepository keeps the Kafka messages the finalizer is handling or has handled, so one
+// redelivered because its offset wasn't committed isn't handled twice. Claims are made as
+// owner and expire unless renewed within the lease.
+type LedgerRepository struct {
+	db    *store.DB
+	log   *zap.Logger
+	owner string
+	lease time.Duration
+}
+
+func NewLedgerRepository(db *store.DB, log *zap.Logger) *LedgerRepository {
+	host, _ := os.Hostname()
+	owner := fmt.Sprintf("%s/%d/%s", host, os.Getpid(), uuid.NewString()[:8])
+	return &LedgerRepository{db: db, log: log, owner: owner, lease: DefaultLease}
+}
+
+// WithLease sets how long the claims this repository makes, and those it finds, stay live
+// without being renewed.
+func (r *LedgerRepository) WithLease(lease time.Duration) *LedgerRepository {
+	r.lease = lease
+	return r
+}
+
+// Lease is how long a claim stays live without being renewed.
+func (r *LedgerRepository) Lease() time.Duration {
+	return r.lease
+}
+
+// Claim records that m is being handled, before any of its side effects, and returns
+// ClaimTaken. A message processed before is left as it is and ClaimProcessed returned; one
+// being handled is ClaimHeld while its claim is live, and taken over with ClaimTakenOver
+// once it has gone unrenewed for the lease.
+func (r *LedgerRepository) Claim(ctx context.Context, m Message) (string, error) {
+	// A claim released between the insert and the read is tried again
+	for i := 0; i < claimAttempts; i++ {
+		var status string
+		err := r.db.Pool.QueryRow(ctx, `
+			INSERT INTO processed_messages (topic, message_key, partition, message_offset, status, claimed_by, claimed_at)
+			VALUES ($1, $2, $3, $4, 'processing', $5, now())
+			ON CONFLICT DO NOTHING
+			RETURNING status
+		`, m.Topic, m.Key, m.Partition, m.Offset, r.owner).Scan(&status)
+		if err == nil {
+			return ClaimTaken, nil
+		}
+		if err != pgx.ErrNoRows {
+			return "", err
+		}
+		err = r.db.Pool.QueryRow(ctx, `
+			UPDATE processed_messages SET claimed_by = $5, claimed_at = now(), processed_at = now()
+			WHERE topic = $1 AND message_key = $2 AND partition = $3 AND message_offset = $4
+			  AND status = 'processing' AND claimed_at < now() - make_interval(secs => $6)
+			RETURNING status
+		`, m.Topic, m.Key, m.Partition, m.Offset, r.owner, r.lease.Seconds()).Scan(&status)
+		if err == nil {
+			r.log.Warn("took over expired message claim", zap.String("topic", m.Topic), zap.Int("partition", m.Partition), zap.Int64("offset", m.Offset))
+			return ClaimTakenOver, nil
+		}
+		if err != pgx.ErrNoRows {
+			return "", err
+		}
+		err = r.db.Pool.QueryRow(ctx, `
+			SELECT status FROM processed_messages
+			WHERE topic = $1 AND message_key = $2 AND partition = $3 AND message_offset = $4
+		`, m.Topic, m.Key, m.Partition, m.Offset).Scan(&status)
+		if err == pgx.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if status == "processed" {
+			return ClaimProcessed, nil
+		}
+		return ClaimHeld, nil
+	}
+	return "", errors.New("message claim kept changing")
+}
+
+// Renew keeps the caller's claim on m live for another lease. It returns ErrClaimLost if
+// the claim was taken over or released.
+func (r *LedgerRepository) Renew(ctx context.Context, m Message) error {
+	result, err := r.db.Pool.Exec(ctx, `
+		UPDATE processed_messages SET claimed_at = now()
+		WHERE topic = $1 AND message_key = $2 AND partition = $3 AND message_offset = $4
+		  AND status = 'processing' AND claimed_by = $5
+	`, m.Topic, m.Key, m.Partition, m.Offset, r.owner)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrClaimLost
+	}
+	return nil
+}
+
+// MarkProcessed records that m, claimed by the caller, was handled.
+func (r *LedgerRepository) MarkProcessed(ctx context.Context, m Message) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		UPDATE processed_messages SET status = 'processed', processed_at = now()
+		WHERE topic = $1 AND message_key = $2 AND partition = $3 AND message_offset = $4
+	`, m.Topic, m.Key, m.Partition, m.Offset)
+	return err
+}
+
+// Release drops the caller's claim on m after handling it failed, so its redelivery is
+// handled again. A claim taken over by another consumer is left to it.
+func (r *LedgerRepository) Release(ctx context.Context, m Message) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		DELETE FROM processed_messages
+		WHERE topic = $1 AND message_key = $2 AND partition = $3 AND message_offset = $4
+		  AND status = 'processing' AND claimed_by = $5
+	`, m.Topic, m.Key, m.Partition, m.Offset, r.owner)
+	return err
+}
+
+// Purge deletes messages processed before before and returns how many.
+func (r *LedgerRepository) Purge(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.Pool.Exec(ctx, `DELETE FROM processed_messages WHERE processed_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,86 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/storetest"
+)
+
+// testMessage is a message on a topic of its own, deleted from the ledger when the test ends.
+func testMessage(t *testing.T, db *store.DB) Message {
+	t.Helper()
+	m := Message{Topic: "ledger-test-" + uuid.NewString(), Key: "booking", Partition: 0, Offset: 42}
+	t.Cleanup(func() {
+		_, _ = db.Pool.Exec(context.Background(), `DELETE FROM processed_messages WHERE topic = $1`, m.Topic)
+	})
+	return m
+}
+
+func claim(t *testing.T, r *LedgerRepository, m Message, want string) {
+	t.Helper()
+	got, err := r.Claim(context.Background(), m)
+	if err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+	if got != want {
+		t.Fatalf("Claim = %s, want %s", got, want)
+	}
+}
+
+func TestClaimLiveLeaseIsHeld(t *testing.T) {
+	db := storetest.DB(t)
+	ctx := context.Background()
+	m := testMessage(t, db)
+	first := NewLedgerRepository(db, zap.NewNop())
+	// A rebalance hands the message to another worker while the first is handling it
+	second := NewLedgerRepository(db, zap.NewNop())
+
+	claim(t, first, m, ClaimTaken)
+	claim(t, second, m, ClaimHeld)
+	if err := first.Renew(ctx, m); err != nil {
+		t.Fatalf("Renew: %v", err)
+	}
+	// Only the holder drops its claim
+	if err := second.Release(ctx, m); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	claim(t, second, m, ClaimHeld)
+
+	if err := first.MarkProcessed(ctx, m); err != nil {
+		t.Fatalf("MarkProcessed: %v", err)
+	}
+	claim(t, second, m, ClaimProcessed)
+}
+
+func TestClaimExpiredLeaseIsTakenOver(t *testing.T) {
+	db := storetest.DB(t)
+	ctx := context.Background()
+	m := testMessage(t, db)
+	// The first worker crashes after claiming the message
+	crashed := NewLedgerRepository(db, zap.NewNop())
+	next := NewLedgerRepository(db, zap.NewNop()).WithLease(200 * time.Millisecond)
+
+	claim(t, crashed, m, ClaimTaken)
+	claim(t, next, m, ClaimHeld)
+	time.Sleep(300 * time.Millisecond)
+	claim(t, next, m, ClaimTakenOver)
+
+	// The old holder can neither renew nor release the claim it lost
+	if err := crashed.Renew(ctx, m); !errors.Is(err, ErrClaimLost) {
+		t.Errorf("Renew by the old holder = %v, want ErrClaimLost", err)
+	}
+	if err := crashed.Release(ctx, m); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if err := next.Renew(ctx, m); err != nil {
+		t.Errorf("Renew by the new holder: %v", err)
+	}
+	claim(t, NewLedgerRepository(db, zap.NewNop()), m, ClaimHeld)
+}
@@ -0,0 +1,194 @@
+package members
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store"
+)
+
+// Membership statuses.
+const (
+	StatusInvited = "invited"
+	StatusActive  = "active"
+)
+
+var (
+	// ErrMemberExists is returned for inviting an email that is already an active member, or
+	// accepting as a user who already is one
+	ErrMemberExists = errors.New("already a member of this event")
+	// ErrInviteClosed is returned for accepting an invitation that was accepted or has expired
+	ErrInviteClosed = errors.New("invitation was accepted or has expired")
+)
+
+// Member is a co-organizer of an event, or someone invited to become one. UserID is set
+// once they accept.
+type Member struct {
+	ID          string     `json:"id"`
+	EventID     string     `json:"event_id"`
+	Email       string     `json:"email"`
+	UserID      *string    `json:"user_id,omitempty"`
+	Permissions []string   `json:"permissions"`
+	Status      string     `json:"status"`
+	InvitedBy   *string    `json:"invited_by,omitempty"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	AcceptedAt  *time.Time `json:"accepted_at,omitempty"`
+}
+
+// Membership is an active membership with the event it is for, as its member lists them.
+type Membership struct {
+	Member
+	EventName string    `json:"event_name"`
+	StartTime time.Time `json:"start_time"`
+}
+
+type MembersRepository struct {
+	db  *store.DB
+	log *zap.Logger
+}
+
+func NewMembersRepository(db *store.DB, log *zap.Logger) *MembersRepository {
+	return &MembersRepository{db: db, log: log}
+}
+
+const memberColumns = `id, event_id, email, user_id, permissions, status, invited_by, expires_at, created_at, updated_at, accepted_at`
+
+func scanMember(row pgx.Row) (*Member, error) {
+	m := &Member{}
+	err := row.Scan(&m.ID, &m.EventID, &m.Email, &m.UserID, &m.Permissions, &m.Status, &m.InvitedBy, &m.ExpiresAt, &m.CreatedAt, &m.UpdatedAt, &m.AcceptedAt)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Invite stores an invitation for m.Email to the event. A pending invitation of the same
+// email is replaced, with a new token; an active member can't be invited again.
+func (r *MembersRepository) Invite(ctx context.Context, m *Member, tokenHash []byte) (*Member, error) {
+	out, err := scanMember(r.db.Pool.QueryRow(ctx, `
+		INSERT INTO event_members (event_id, email, permissions, token_hash, invited_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (event_id, lower(email)) DO UPDATE
+		SET email = EXCLUDED.email, permissions = EXCLUDED.permissions, token_hash = EXCLUDED.token_hash,
+		    invited_by = EXCLUDED.invited_by, expires_at = EXCLUDED.expires_at, updated_at = now()
+		WHERE event_members.status = 'invited'
+		RETURNING `+memberColumns,
+		m.EventID, m.Email, m.Permissions, tokenHash, m.InvitedBy, m.ExpiresAt))
+	if err == pgx.ErrNoRows {
+		return nil, ErrMemberExists
+	}
+	return out, err
+}
+
+// GetByTokenHash returns the invitation whose token hashes to tokenHash, nil if none.
+func (r *MembersRepository) GetByTokenHash(ctx context.Context, tokenHash []byte) (*Member, error) {
+	m, err := scanMember(r.db.Pool.QueryRow(ctx, `SELECT `+memberColumns+` FROM event_members WHERE token_hash = $1`, tokenHash))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	return m, err
+}
+
+// Accept makes the pending invitation memberID an active membership of userID.
+func (r *MembersRepository) Accept(ctx context.Context, memberID, userID string) (*Member, error) {
+	m, err := scanMember(r.db.Pool.QueryRow(ctx, `
+		UPDATE event_members
+		SET status = 'active', user_id = $2, token_hash = NULL, accepted_at = now(), updated_at = now()
+		WHERE id = $1 AND status = 'invited' AND expires_at > now()
+		RETURNING `+memberColumns, memberID, userID))
+	if err == pgx.ErrNoRows {
+		return nil, ErrInviteClosed
+	}
+	if store.IsUniqueViolation(err) {
+		return nil, ErrMemberExists
+	}
+	return m, err
+}
+
+// List returns the event's members and pending invitations, oldest first.
+func (r *MembersRepository) List(ctx context.Context, eventID string) ([]*Member, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT `+memberColumns+` FROM event_members WHERE event_id = $1 ORDER BY created_at, id`, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []*Member{}
+	for rows.Next() {
+		m, err := scanMember(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+// SetPermissions replaces the permissions of the event's member or invitation memberID,
+// nil if there is none.
+func (r *MembersRepository) SetPermissions(ctx context.Context, eventID, memberID string, permissions []string) (*Member, error) {
+	m, err := scanMember(r.db.Pool.QueryRow(ctx, `
+		UPDATE event_members SET permissions = $3, updated_at = now()
+		WHERE event_id = $1 AND id = $2
+		RETURNING `+memberColumns, eventID, memberID, permissions))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	return m, err
+}
+
+// Remove deletes the event's member or invitation memberID, reporting whether there was one.
+func (r *MembersRepository) Remove(ctx context.Context, eventID, memberID string) (bool, error) {
+	result, err := r.db.Pool.Exec(ctx, `DELETE FROM event_members WHERE event_id = $1 AND id = $2`, eventID, memberID)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// Permissions returns what userID may do on the event as an active member; nil if they
+// aren't one.
+func (r *MembersRepository) Permissions(ctx context.Context, eventID, userID string) ([]string, error) {
+	var perms []string
+	err := r.db.Pool.QueryRow(ctx, `
+		SELECT permissions FROM event_members
+		WHERE event_id = $1 AND user_id = $2 AND status = 'active'`, eventID, userID).Scan(&perms)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	return perms, err
+}
+
+// ListByUser returns the user's active memberships, soonest event first.
+func (r *MembersRepository) ListByUser(ctx context.Context, userID string) ([]*Membership, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT m.id, m.event_id, m.email, m.user_id, m.permissions, m.status, m.invited_by, m.expires_at,
+		       m.created_at, m.updated_at, m.accepted_at, e.name, e.start_time
+		FROM event_members m
+		JOIN events e ON e.id = m.event_id
+		WHERE m.user_id = $1 AND m.status = 'active'
+		ORDER BY e.start_time, e.id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []*Membership{}
+	for rows.Next() {
+		m := &Membership{}
+		err := rows.Scan(&m.ID, &m.EventID, &m.Email, &m.UserID, &m.Permissions, &m.Status, &m.InvitedBy, &m.ExpiresAt,
+			&m.CreatedAt, &m.UpdatedAt, &m.AcceptedAt, &m.EventName, &m.StartTime)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
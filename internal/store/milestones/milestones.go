@@ -0,0 +1,126 @@
+package milestones
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store"
+)
+
+// Milestone is a sales goal of an event: Percent of capacity sold, 100 meaning sold out.
+type Milestone struct {
+	ID          int64      `json:"id"`
+	EventID     string     `json:"event_id"`
+	Percent     int        `json:"percent"`
+	NotifyEmail *string    `json:"notify_email,omitempty"`
+	WebhookURL  *string    `json:"webhook_url,omitempty"`
+	CrossedAt   *time.Time `json:"crossed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type MilestonesRepository struct {
+	db  *store.DB
+	log *zap.Logger
+}
+
+func NewMilestonesRepository(db *store.DB, log *zap.Logger) *MilestonesRepository {
+	return &MilestonesRepository{db: db, log: log}
+}
+
+// Replace sets the event's milestones to ms. Milestones that keep their percent keep
+// their crossed_at, so re-saving the configuration doesn't notify twice.
+func (r *MilestonesRepository) Replace(ctx context.Context, eventID string, ms []*Milestone) error {
+	return r.db.WithTx(ctx, func(tx pgx.Tx) error {
+		percents := make([]int, 0, len(ms))
+		for _, m := range ms {
+			percents = append(percents, m.Percent)
+		}
+		_, err := tx.Exec(ctx, `DELETE FROM sales_milestones WHERE event_id = $1 AND NOT (percent = ANY($2))`, eventID, percents)
+		if err != nil {
+			return err
+		}
+		for _, m := range ms {
+			_, err := tx.Exec(ctx, `
+				INSERT INTO sales_milestones (event_id, percent, notify_email, webhook_url)
+				VALUES ($1, $2, $3, $4)
+				ON CONFLICT (event_id, percent) DO UPDATE
+				SET notify_email = EXCLUDED.notify_email, webhook_url = EXCLUDED.webhook_url
+			`, eventID, m.Percent, m.NotifyEmail, m.WebhookURL)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *MilestonesRepository) ListByEvent(ctx context.Context, eventID string) ([]*Milestone, error) {
+	query := `
+		SELECT id, event_id, percent, notify_email, webhook_url, crossed_at, created_at
+		FROM sales_milestones
+		WHERE event_id = $1
+		ORDER BY percent`
+
+	rows, err := r.db.Pool.Query(ctx, query, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ms := []*Milestone{}
+	for rows.Next() {
+		m := &Milestone{}
+		if err := rows.Scan(&m.ID, &m.EventID, &m.Percent, &m.NotifyEmail, &m.WebhookURL, &m.CrossedAt, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		ms = append(ms, m)
+	}
+	return ms, rows.Err()
+}
+
+// MarkCrossed stamps every not yet crossed milestone at or below percent and returns them.
+// The update is atomic, so concurrent payments can't both claim the same milestone.
+func (r *MilestonesRepository) MarkCrossed(ctx context.Context, eventID string, percent int) ([]*Milestone, error) {
+	query := `
+		UPDATE sales_milestones
+		SET crossed_at = now()
+		WHERE event_id = $1 AND percent <= $2 AND crossed_at IS NULL
+		RETURNING id, event_id, percent, notify_email, webhook_url, crossed_at, created_at`
+
+	rows, err := r.db.Pool.Query(ctx, query, eventID, percent)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ms []*Milestone
+	for rows.Next() {
+		m := &Milestone{}
+		if err := rows.Scan(&m.ID, &m.EventID, &m.Percent, &m.NotifyEmail, &m.WebhookURL, &m.CrossedAt, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		ms = append(ms, m)
+	}
+	return ms, rows.Err()
+}
+
+// SoldTickets returns the seats of the event's booked bookings and its capacity.
+func (r *MilestonesRepository) SoldTickets(ctx context.Context, eventID string) (int, int, error) {
+	query := `
+		SELECT COALESCE((
+			SELECT SUM(jsonb_array_length(COALESCE(b.seats, '[]'::jsonb)))
+			FROM bookings b
+			WHERE b.event_id = e.id AND b.status = 'booked'
+		), 0), e.capacity
+		FROM events e
+		WHERE e.id = $1`
+
+	var sold, capacity int
+	if err := r.db.Pool.QueryRow(ctx, query, eventID).Scan(&sold, &capacity); err != nil {
+		return 0, 0, err
+	}
+	return sold, capacity, nil
+}
@@ -0,0 +1,187 @@
+package notifications
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	MailPending = "pending"
+	MailSending = "sending"
+	MailSent    = "sent"
+	MailDead    = "dead"
+)
+
+// QueuedMail is one email in the mail queue with its delivery state.
+type QueuedMail struct {
+	ID            string     `json:"id"`
+	To            string     `json:"to"`
+	Subject       string     `json:"subject"`
+	Body          string     `json:"-"`
+	OrganizerID   *string    `json:"organizer_id,omitempty"`
+	BookingID     *string    `json:"booking_id,omitempty"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	LastError     *string    `json:"last_error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+}
+
+const mailColumns = `id, to_email, subject, body, organizer_id, booking_id, status, attempts, next_attempt_at, last_error, created_at, updated_at, sent_at`
+
+func scanMail(row pgx.Row) (*QueuedMail, error) {
+	m := &QueuedMail{}
+	err := row.Scan(&m.ID, &m.To, &m.Subject, &m.Body, &m.OrganizerID, &m.BookingID, &m.Status, &m.Attempts, &m.NextAttemptAt, &m.LastError,
+		&m.CreatedAt, &m.UpdatedAt, &m.SentAt)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// EnqueueMail queues one email to be sent as soon as a worker claims it. organizerID, when
+// set, is the organizer whose branding it is sent with, and bookingID the booking it is about.
+func (r *NotificationsRepository) EnqueueMail(ctx context.Context, to, subject, body string, organizerID, bookingID *string) (*QueuedMail, error) {
+	return scanMail(r.db.Pool.QueryRow(ctx, `
+		INSERT INTO mail_queue (to_email, subject, body, organizer_id, booking_id)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+mailColumns,
+		to, subject, body, organizerID, bookingID))
+}
+
+// ClaimMail takes up to limit due emails to send, counting an attempt for each, including
+// ones whose claim is older than lease, which a crashed sender never finished. Concurrent
+// claims never overlap.
+func (r *NotificationsRepository) ClaimMail(ctx context.Context, limit int, lease time.Duration) ([]*QueuedMail, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		UPDATE mail_queue
+		SET status = 'sending', claimed_at = now(), attempts = attempts + 1, updated_at = now()
+		WHERE id IN (
+			SELECT id FROM mail_queue
+			WHERE (status = 'pending' AND next_attempt_at <= now())
+			   OR (status = 'sending' AND claimed_at < now() - make_interval(secs => $2))
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+mailColumns,
+		limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []*QueuedMail{}
+	for rows.Next() {
+		m, err := scanMail(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+// MarkMailSent records a successful send of a claimed email.
+func (r *NotificationsRepository) MarkMailSent(ctx context.Context, id string) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		UPDATE mail_queue
+		SET status = 'sent', sent_at = now(), claimed_at = NULL, last_error = NULL, updated_at = now()
+		WHERE id = $1 AND status = 'sending'
+	`, id)
+	return err
+}
+
+// RetryMail puts a claimed email whose send failed back in the queue, due at next.
+func (r *NotificationsRepository) RetryMail(ctx context.Context, id string, sendErr string, next time.Time) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		UPDATE mail_queue
+		SET status = 'pending', next_attempt_at = $3, last_error = $2, claimed_at = NULL, updated_at = now()
+		WHERE id = $1 AND status = 'sending'
+	`, id, sendErr, next)
+	return err
+}
+
+// KillMail gives up on a claimed email, leaving it dead for an admin to look at.
+func (r *NotificationsRepository) KillMail(ctx context.Context, id string, sendErr string) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		UPDATE mail_queue
+		SET status = 'dead', last_error = $2, claimed_at = NULL, updated_at = now()
+		WHERE id = $1 AND status = 'sending'
+	`, id, sendErr)
+	return err
+}
+
+// RequeueMail gives a dead email a fresh set of attempts, due now. It reports whether the
+// email was dead.
+func (r *NotificationsRepository) RequeueMail(ctx context.Context, id string) (bool, error) {
+	tag, err := r.db.Pool.Exec(ctx, `
+		UPDATE mail_queue
+		SET status = 'pending', attempts = 0, next_attempt_at = now(), updated_at = now()
+		WHERE id = $1 AND status = 'dead'
+	`, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// GetMail returns the queued email, or nil if there is none.
+func (r *NotificationsRepository) GetMail(ctx context.Context, id string) (*QueuedMail, error) {
+	m, err := scanMail(r.db.Pool.QueryRow(ctx, `SELECT `+mailColumns+` FROM mail_queue WHERE id = $1`, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return m, nil
+}
+
+// ListMail returns queued emails in status, or in any status if it is empty, newest first.
+func (r *NotificationsRepository) ListMail(ctx context.Context, status string, limit, offset int) ([]*QueuedMail, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT `+mailColumns+` FROM mail_queue
+		WHERE $1 = '' OR status = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`, status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []*QueuedMail{}
+	for rows.Next() {
+		m, err := scanMail(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+// CountMail returns how many queued emails are in each status.
+func (r *NotificationsRepository) CountMail(ctx context.Context) (map[string]int, error) {
+	rows, err := r.db.Pool.Query(ctx, `SELECT status, count(*) FROM mail_queue GROUP BY status`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[string]int{MailPending: 0, MailSending: 0, MailSent: 0, MailDead: 0}
+	for rows.Next() {
+		var status string
+		var n int
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, err
+		}
+		out[status] = n
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,218 @@
+package notifications
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store"
+)
+
+const (
+	StatusSending = "sending"
+	StatusDone    = "done"
+)
+
+// Batch is one email sent to many recipients, with its delivery progress.
+type Batch struct {
+	ID      string  `json:"id"`
+	Kind    string  `json:"kind"`
+	EventID *string `json:"event_id,omitempty"`
+	Subject string  `json:"subject"`
+	Body    string  `json:"-"`
+	// OrganizerID is the organizer whose branding the email is sent with; nil for the platform's
+	OrganizerID *string    `json:"organizer_id,omitempty"`
+	Status      string     `json:"status"`
+	Total       int        `json:"total"`
+	Sent        int        `json:"sent"`
+	Failed      int        `json:"failed"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// Result is the outcome of one send attempt. Retry puts the recipient back in the queue;
+// otherwise a non-empty Error marks them failed and an empty one sent.
+type Result struct {
+	Email string
+	Error string
+	Retry bool
+}
+
+type NotificationsRepository struct {
+	db  *store.DB
+	log *zap.Logger
+}
+
+func NewNotificationsRepository(db *store.DB, log *zap.Logger) *NotificationsRepository {
+	return &NotificationsRepository{db: db, log: log}
+}
+
+const batchColumns = `id, kind, event_id, subject, body, organizer_id, status, total, sent, failed, created_at, updated_at, completed_at`
+
+func scanBatch(row pgx.Row) (*Batch, error) {
+	b := &Batch{}
+	err := row.Scan(&b.ID, &b.Kind, &b.EventID, &b.Subject, &b.Body, &b.OrganizerID, &b.Status, &b.Total, &b.Sent, &b.Failed,
+		&b.CreatedAt, &b.UpdatedAt, &b.CompletedAt)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Create queues b for every email in emails, which must not repeat.
+func (r *NotificationsRepository) Create(ctx context.Context, b *Batch, emails []string) (*Batch, error) {
+	var out *Batch
+	err := r.db.WithTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		out, err = scanBatch(tx.QueryRow(ctx, `
+			INSERT INTO notification_batches (kind, event_id, subject, body, organizer_id, total)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING `+batchColumns,
+			b.Kind, b.EventID, b.Subject, b.Body, b.OrganizerID, len(emails)))
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO notification_recipients (batch_id, email)
+			SELECT $1, unnest($2::text[])
+		`, out.ID, emails)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Get returns the batch, or nil if there is none.
+func (r *NotificationsRepository) Get(ctx context.Context, id string) (*Batch, error) {
+	b, err := scanBatch(r.db.Pool.QueryRow(ctx, `SELECT `+batchColumns+` FROM notification_batches WHERE id = $1`, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+// List returns batches, newest first.
+func (r *NotificationsRepository) List(ctx context.Context, limit, offset int) ([]*Batch, error) {
+	return r.list(ctx, `SELECT `+batchColumns+` FROM notification_batches ORDER BY created_at DESC LIMIT $1 OFFSET $2`, limit, offset)
+}
+
+// ListUnfinished returns batches still sending, oldest first.
+func (r *NotificationsRepository) ListUnfinished(ctx context.Context) ([]*Batch, error) {
+	return r.list(ctx, `SELECT `+batchColumns+` FROM notification_batches WHERE status = 'sending' ORDER BY created_at`)
+}
+
+func (r *NotificationsRepository) list(ctx context.Context, query string, args ...any) ([]*Batch, error) {
+	rows, err := r.db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []*Batch{}
+	for rows.Next() {
+		b, err := scanBatch(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
+
+// Claim takes up to limit recipients of the batch to send to, including ones whose claim is
+// older than lease, which a crashed sender never finished. Concurrent claims never overlap.
+func (r *NotificationsRepository) Claim(ctx context.Context, batchID string, limit int, lease time.Duration) ([]string, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		UPDATE notification_recipients
+		SET status = 'sending', claimed_at = now(), attempts = attempts + 1
+		WHERE batch_id = $1 AND email IN (
+			SELECT email FROM notification_recipients
+			WHERE batch_id = $1
+			  AND (status = 'pending' OR (status = 'sending' AND claimed_at < now() - make_interval(secs => $3)))
+			ORDER BY email
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING email
+	`, batchID, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	emails := []string{}
+	for rows.Next() {
+		var email string
+		if err := rows.Scan(&email); err != nil {
+			return nil, err
+		}
+		emails = append(emails, email)
+	}
+	return emails, rows.Err()
+}
+
+// Record stores the results of sends to claimed recipients and bumps the batch's counters.
+// Recipients that failed more than maxAttempts times are marked failed even if Retry is set.
+func (r *NotificationsRepository) Record(ctx context.Context, batchID string, results []Result, maxAttempts int) error {
+	emails := make([]string, len(results))
+	errs := make([]string, len(results))
+	retry := make([]bool, len(results))
+	for i, res := range results {
+		emails[i], errs[i], retry[i] = res.Email, res.Error, res.Retry
+	}
+	return r.db.WithTx(ctx, func(tx pgx.Tx) error {
+		var sent, failed int
+		err := tx.QueryRow(ctx, `
+			WITH res AS (
+				SELECT * FROM unnest($2::text[], $3::text[], $4::bool[]) AS t(email, error, retry)
+			), upd AS (
+				UPDATE notification_recipients n
+				SET status = CASE
+				        WHEN res.error = '' THEN 'sent'
+				        WHEN res.retry AND n.attempts < $5 THEN 'pending'
+				        ELSE 'failed'
+				    END,
+				    sent_at = CASE WHEN res.error = '' THEN now() END,
+				    last_error = NULLIF(res.error, ''),
+				    claimed_at = NULL
+				FROM res
+				WHERE n.batch_id = $1 AND n.email = res.email AND n.status = 'sending'
+				RETURNING n.status
+			)
+			SELECT count(*) FILTER (WHERE status = 'sent'), count(*) FILTER (WHERE status = 'failed') FROM upd
+		`, batchID, emails, errs, retry, maxAttempts).Scan(&sent, &failed)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			UPDATE notification_batches
+			SET sent = sent + $2, failed = failed + $3, updated_at = now()
+			WHERE id = $1
+		`, batchID, sent, failed)
+		return err
+	})
+}
+
+// Finish marks the batch done once no recipient is left to send to, reporting whether it did.
+func (r *NotificationsRepository) Finish(ctx context.Context, batchID string) (bool, error) {
+	tag, err := r.db.Pool.Exec(ctx, `
+		UPDATE notification_batches
+		SET status = 'done', completed_at = now(), updated_at = now()
+		WHERE id = $1 AND status = 'sending' AND NOT EXISTS (
+			SELECT 1 FROM notification_recipients
+			WHERE batch_id = $1 AND status IN ('pending', 'sending')
+		)
+	`, batchID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
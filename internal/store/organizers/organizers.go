@@ -0,0 +1,132 @@
+package organizers
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store"
+)
+
+type Organizer struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Website     string `json:"website"`
+	LogoURL     string `json:"logo_url"`
+	Followers   int    `json:"followers"`
+	// MaxTicketsPerUser caps tickets a user may book across all of the organizer's
+	// events within UserTicketWindowHours; nil means no limit.
+	MaxTicketsPerUser     *int      `json:"max_tickets_per_user,omitempty"`
+	UserTicketWindowHours *int      `json:"user_ticket_window_hours,omitempty"`
+	CreatedAt             time.Time `json:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at"`
+}
+
+type OrganizersRepository struct {
+	db  *store.DB
+	log *zap.Logger
+}
+
+func NewOrganizersRepository(db *store.DB, log *zap.Logger) *OrganizersRepository {
+	return &OrganizersRepository{db: db, log: log}
+}
+
+func (r *OrganizersRepository) Create(ctx context.Context, o *Organizer) (*Organizer, error) {
+	query := `
+		INSERT INTO organizers (name, description, website, logo_url, max_tickets_per_user, user_ticket_window_hours)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at`
+
+	err := r.db.Pool.QueryRow(ctx, query, o.Name, o.Description, o.Website, o.LogoURL, o.MaxTicketsPerUser, o.UserTicketWindowHours).
+		Scan(&o.ID, &o.CreatedAt, &o.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+func (r *OrganizersRepository) Get(ctx context.Context, id string) (*Organizer, error) {
+	query := `
+		SELECT o.id, o.name, o.description, o.website, o.logo_url,
+		       (SELECT COUNT(*) FROM organizer_follows f WHERE f.organizer_id = o.id),
+		       o.max_tickets_per_user, o.user_ticket_window_hours,
+		       o.created_at, o.updated_at
+		FROM organizers o
+		WHERE o.id = $1`
+
+	o := &Organizer{}
+	err := r.db.Pool.QueryRow(ctx, query, id).Scan(
+		&o.ID, &o.Name, &o.Description, &o.Website, &o.LogoURL,
+		&o.Followers, &o.MaxTicketsPerUser, &o.UserTicketWindowHours, &o.CreatedAt, &o.UpdatedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return o, nil
+}
+
+func (r *OrganizersRepository) Follow(ctx context.Context, organizerID, userID string) error {
+	query := `
+		INSERT INTO organizer_follows (user_id, organizer_id)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id, organizer_id) DO NOTHING`
+
+	_, err := r.db.Pool.Exec(ctx, query, userID, organizerID)
+	return err
+}
+
+func (r *OrganizersRepository) Unfollow(ctx context.Context, organizerID, userID string) error {
+	query := `DELETE FROM organizer_follows WHERE user_id = $1 AND organizer_id = $2`
+
+	_, err := r.db.Pool.Exec(ctx, query, userID, organizerID)
+	return err
+}
+
+func (r *OrganizersRepository) IsFollowing(ctx context.Context, organizerID, userID string) (bool, error) {
+	query := `SELECT 1 FROM organizer_follows WHERE user_id = $1 AND organizer_id = $2`
+
+	var exists int
+	err := r.db.Pool.QueryRow(ctx, query, userID, organizerID).Scan(&exists)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// FollowerEmails returns the email addresses of everyone following the organizer.
+func (r *OrganizersRepository) FollowerEmails(ctx context.Context, organizerID string) ([]string, error) {
+	query := `
+		SELECT u.email
+		FROM organizer_follows f
+		JOIN users u ON u.id = f.user_id
+		WHERE f.organizer_id = $1`
+
+	rows, err := r.db.Pool.Query(ctx, query, organizerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var emails []string
+	for rows.Next() {
+		var email string
+		if err := rows.Scan(&email); err != nil {
+			return nil, err
+		}
+		emails = append(emails, email)
+	}
+
+	return emails, nil
+}
@@ -0,0 +1,30 @@
+package store
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/tracing"
+)
+
+// OutboxMessage is a Kafka message to publish once the transaction writing it commits.
+// Envelope is the JSON encoded envelope.
+type OutboxMessage struct {
+	Topic    string
+	Key      string
+	Envelope []byte
+}
+
+// EnqueueOutbox writes m to the outbox inside tx, so it is published if and only if tx
+// commits. The worker's relay publishes it, continuing ctx's trace.
+func EnqueueOutbox(ctx context.Context, tx pgx.Tx, m *OutboxMessage) error {
+	// NULL rather than a JSON null when the request isn't traced
+	var traceContext any
+	if tc := tracing.Inject(ctx); tc != nil {
+		traceContext = tc
+	}
+	_, err := tx.Exec(ctx, `INSERT INTO outbox (topic, message_key, envelope, trace_context) VALUES ($1, $2, $3, $4)`,
+		m.Topic, m.Key, m.Envelope, traceContext)
+	return err
+}
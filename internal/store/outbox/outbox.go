@@ -0,0 +1,120 @@
+package outbox
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store"
+)
+
+// Message is an outbox row waiting to be published.
+type Message struct {
+	ID       int64
+	Topic    string
+	Key      string
+	Envelope []byte
+	// TraceContext is the trace context of the request that wrote the message, if traced
+	TraceContext map[string]string
+	Attempts     int
+	CreatedAt    time.Time
+}
+
+type OutboxRepository struct {
+	db  *store.DB
+	log *zap.Logger
+}
+
+func NewOutboxRepository(db *store.DB, log *zap.Logger) *OutboxRepository {
+	return &OutboxRepository{db: db, log: log}
+}
+
+// Claim leases up to limit unpublished messages, oldest first, so only one relay publishes
+// each. A lease left by a crashed relay runs out after lease and the message is claimed
+// again.
+func (r *OutboxRepository) Claim(ctx context.Context, limit int, lease time.Duration) ([]*Message, error) {
+	query := `
+		-- name: outbox_claim
+		UPDATE outbox o
+		SET claimed_at = now(), attempts = o.attempts + 1
+		FROM (
+			SELECT id FROM outbox
+			WHERE published_at IS NULL AND failed_at IS NULL
+			  AND (claimed_at IS NULL OR claimed_at < now() - make_interval(secs => $2))
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		) due
+		WHERE o.id = due.id
+		RETURNING o.id, o.topic, o.message_key, o.envelope, o.trace_context, o.attempts, o.created_at`
+
+	rows, err := r.db.Pool.Query(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*Message
+	for rows.Next() {
+		m := &Message{}
+		if err := rows.Scan(&m.ID, &m.Topic, &m.Key, &m.Envelope, &m.TraceContext, &m.Attempts, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// RETURNING has no order; publish in the order the messages were written
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+// MarkPublished records that a claimed message reached Kafka.
+func (r *OutboxRepository) MarkPublished(ctx context.Context, id int64) error {
+	_, err := r.db.Pool.Exec(ctx, `UPDATE outbox SET published_at = now(), claimed_at = NULL, last_error = NULL WHERE id = $1`, id)
+	return err
+}
+
+// Release gives up a claim after a failed publish, keeping the error, so the message is
+// retried on a later pass.
+func (r *OutboxRepository) Release(ctx context.Context, id int64, reason string) error {
+	_, err := r.db.Pool.Exec(ctx, `UPDATE outbox SET claimed_at = NULL, last_error = $2 WHERE id = $1`, id, reason)
+	return err
+}
+
+// MarkFailed gives up on a claimed message after its last failed publish, keeping the
+// error. It is no longer claimed until an operator clears failed_at.
+func (r *OutboxRepository) MarkFailed(ctx context.Context, id int64, reason string) error {
+	_, err := r.db.Pool.Exec(ctx, `UPDATE outbox SET failed_at = now(), claimed_at = NULL, last_error = $2 WHERE id = $1`, id, reason)
+	return err
+}
+
+// Failed returns how many messages were given up on.
+func (r *OutboxRepository) Failed(ctx context.Context) (int, error) {
+	var n int
+	err := r.db.Pool.QueryRow(ctx, `SELECT count(*) FROM outbox WHERE failed_at IS NOT NULL`).Scan(&n)
+	return n, err
+}
+
+// Backlog returns how many messages are waiting to be published and when the oldest was
+// written; oldest is zero when there are none. Failed messages aren't counted.
+func (r *OutboxRepository) Backlog(ctx context.Context) (n int, oldest time.Time, err error) {
+	var at *time.Time
+	err = r.db.Pool.QueryRow(ctx, `SELECT count(*), min(created_at) FROM outbox WHERE published_at IS NULL AND failed_at IS NULL`).Scan(&n, &at)
+	if err != nil || at == nil {
+		return n, time.Time{}, err
+	}
+	return n, *at, nil
+}
+
+// PurgePublished deletes messages published before before and returns how many.
+func (r *OutboxRepository) PurgePublished(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.Pool.Exec(ctx, `DELETE FROM outbox WHERE published_at IS NOT NULL AND published_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
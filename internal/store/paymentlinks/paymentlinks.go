@@ -0,0 +1,107 @@
+package paymentlinks
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store"
+)
+
+type Link struct {
+	Code      string    `json:"code"`
+	BookingID string    `json:"booking_id"`
+	TargetURL string    `json:"target_url"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Click struct {
+	Code      string    `json:"code"`
+	Expired   bool      `json:"expired"`
+	UserAgent string    `json:"user_agent"`
+	ClickedAt time.Time `json:"clicked_at"`
+}
+
+type PaymentLinksRepository struct {
+	db  *store.DB
+	log *zap.Logger
+}
+
+func NewPaymentLinksRepository(db *store.DB, log *zap.Logger) *PaymentLinksRepository {
+	return &PaymentLinksRepository{db: db, log: log}
+}
+
+// Create stores l. It returns false without error if l.Code is already taken.
+func (r *PaymentLinksRepository) Create(ctx context.Context, l *Link) (bool, error) {
+	query := `
+		INSERT INTO payment_links (code, booking_id, target_url, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (code) DO NOTHING
+		RETURNING created_at`
+
+	err := r.db.Pool.QueryRow(ctx, query, l.Code, l.BookingID, l.TargetURL, l.ExpiresAt).Scan(&l.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (r *PaymentLinksRepository) Get(ctx context.Context, code string) (*Link, error) {
+	query := `
+		SELECT code, booking_id, target_url, expires_at, created_at
+		FROM payment_links
+		WHERE code = $1`
+
+	l := &Link{}
+	err := r.db.Pool.QueryRow(ctx, query, code).Scan(&l.Code, &l.BookingID, &l.TargetURL, &l.ExpiresAt, &l.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return l, nil
+}
+
+func (r *PaymentLinksRepository) RecordClick(ctx context.Context, l *Link, expired bool, userAgent string) error {
+	query := `
+		INSERT INTO payment_link_clicks (code, booking_id, expired, user_agent)
+		VALUES ($1, $2, $3, $4)`
+
+	_, err := r.db.Pool.Exec(ctx, query, l.Code, l.BookingID, expired, userAgent)
+	return err
+}
+
+// ListClicksByBooking returns every click on the booking's payment links, oldest first.
+func (r *PaymentLinksRepository) ListClicksByBooking(ctx context.Context, bookingID string) ([]*Click, error) {
+	query := `
+		SELECT code, expired, user_agent, clicked_at
+		FROM payment_link_clicks
+		WHERE booking_id = $1
+		ORDER BY clicked_at`
+
+	rows, err := r.db.Pool.Query(ctx, query, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	clicks := []*Click{}
+	for rows.Next() {
+		c := &Click{}
+		if err := rows.Scan(&c.Code, &c.Expired, &c.UserAgent, &c.ClickedAt); err != nil {
+			return nil, err
+		}
+		clicks = append(clicks, c)
+	}
+
+	return clicks, rows.Err()
+}
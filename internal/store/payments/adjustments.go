@@ -0,0 +1,32 @@
+package payments
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	AdjustmentCharge = "charge"
+	AdjustmentRefund = "refund"
+)
+
+// Adjustment is money moved for a paid booking after its payment: an extra charge when the
+// booking changed to dearer seats, a partial refund when it changed to cheaper ones.
+type Adjustment struct {
+	ID          string    `json:"id"`
+	BookingID   string    `json:"booking_id"`
+	Kind        string    `json:"kind"`
+	Amount      float64   `json:"amount"`
+	Currency    string    `json:"currency"`
+	PaymentID   *string   `json:"payment_id,omitempty"`
+	ProviderRef *string   `json:"provider_ref,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (r *PaymentsRepository) CreateAdjustment(ctx context.Context, a *Adjustment) error {
+	return r.db.Pool.QueryRow(ctx, `
+		INSERT INTO payment_adjustments (booking_id, kind, amount, currency, payment_id, provider_ref)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`,
+		a.BookingID, a.Kind, a.Amount, a.Currency, a.PaymentID, a.ProviderRef).Scan(&a.ID, &a.CreatedAt)
+}
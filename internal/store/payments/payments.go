@@ -0,0 +1,295 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store"
+)
+
+const (
+	StateAuthorized = "authorized"
+	StateCapturing  = "capturing"
+	StateCaptured   = "captured"
+	StateVoided     = "voided"
+	StateRefunded   = "refunded"
+	StateFailed     = "failed"
+)
+
+// ErrLivePayment is returned by Create when the booking already has an authorized or
+// captured payment.
+var ErrLivePayment = errors.New("booking already has a payment")
+
+// Payment is one attempt to take money for a booking. PaymentID is the caller's reference
+// for it and ProviderRef the processor's, needed to capture, void or refund it.
+type Payment struct {
+	ID           string     `json:"id"`
+	BookingID    string     `json:"booking_id"`
+	EventID      string     `json:"event_id"`
+	PaymentID    string     `json:"payment_id"`
+	ProviderRef  *string    `json:"provider_ref,omitempty"`
+	Amount       float64    `json:"amount"`
+	Currency     string     `json:"currency"`
+	State        string     `json:"state"`
+	Error        *string    `json:"error,omitempty"`
+	AuthorizedAt *time.Time `json:"authorized_at,omitempty"`
+	CapturedAt   *time.Time `json:"captured_at,omitempty"`
+	VoidedAt     *time.Time `json:"voided_at,omitempty"`
+	RefundedAt   *time.Time `json:"refunded_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+type PaymentsRepository struct {
+	db  *store.DB
+	log *zap.Logger
+}
+
+func NewPaymentsRepository(db *store.DB, log *zap.Logger) *PaymentsRepository {
+	return &PaymentsRepository{db: db, log: log}
+}
+
+const paymentColumns = `id, booking_id, event_id, payment_id, provider_ref, amount, currency, state, error,
+	authorized_at, captured_at, voided_at, refunded_at, created_at, updated_at`
+
+func scanPayment(row pgx.Row) (*Payment, error) {
+	p := &Payment{}
+	err := row.Scan(&p.ID, &p.BookingID, &p.EventID, &p.PaymentID, &p.ProviderRef, &p.Amount, &p.Currency, &p.State, &p.Error,
+		&p.AuthorizedAt, &p.CapturedAt, &p.VoidedAt, &p.RefundedAt, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (r *PaymentsRepository) list(ctx context.Context, query string, args ...any) ([]*Payment, error) {
+	rows, err := r.db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []*Payment{}
+	for rows.Next() {
+		p, err := scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// Create records a payment in p.State: authorized, captured, or failed with p.Error.
+func (r *PaymentsRepository) Create(ctx context.Context, p *Payment) (*Payment, error) {
+	out, err := scanPayment(r.db.Pool.QueryRow(ctx, `
+		INSERT INTO payments (booking_id, event_id, payment_id, provider_ref, amount, currency, state, error,
+		                      authorized_at, captured_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8,
+		        CASE WHEN $7 IN ('authorized', 'captured') THEN now() END,
+		        CASE WHEN $7 = 'captured' THEN now() END)
+		RETURNING `+paymentColumns,
+		p.BookingID, p.EventID, p.PaymentID, p.ProviderRef, p.Amount, p.Currency, p.State, p.Error))
+	if err != nil {
+		if store.IsUniqueViolation(err) {
+			return nil, ErrLivePayment
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetLive returns the booking's authorized, capturing or captured payment, or nil if it has
+// none.
+func (r *PaymentsRepository) GetLive(ctx context.Context, bookingID string) (*Payment, error) {
+	p, err := scanPayment(r.db.Pool.QueryRow(ctx, `
+		SELECT `+paymentColumns+`
+		FROM payments
+		WHERE booking_id = $1 AND state IN ('authorized', 'capturing', 'captured')
+	`, bookingID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return p, nil
+}
+
+// ListByEvent returns the event's payments in state, oldest first.
+func (r *PaymentsRepository) ListByEvent(ctx context.Context, eventID, state string) ([]*Payment, error) {
+	return r.list(ctx, `
+		SELECT `+paymentColumns+`
+		FROM payments
+		WHERE event_id = $1 AND state = $2
+		ORDER BY created_at
+	`, eventID, state)
+}
+
+// CountCapturable counts the event's authorizations still waiting to be captured.
+func (r *PaymentsRepository) CountCapturable(ctx context.Context, eventID string) (int, error) {
+	var n int
+	err := r.db.Pool.QueryRow(ctx, `
+		SELECT count(*) FROM payments WHERE event_id = $1 AND state IN ('authorized', 'capturing')
+	`, eventID).Scan(&n)
+	return n, err
+}
+
+// claimable matches authorizations free to capture: not yet claimed, or claimed longer
+// ago than the lease in $2 by a capture that never finished.
+const claimable = `(state = 'authorized' OR (state = 'capturing' AND claimed_at < now() - make_interval(secs => $2)))`
+
+// ClaimEvent takes up to limit of the event's authorizations to capture, skipping ones
+// updated since before so a capture that was just handed back isn't claimed again by the
+// same pass. Concurrent claims never overlap.
+func (r *PaymentsRepository) ClaimEvent(ctx context.Context, eventID string, before time.Time, limit int, lease time.Duration) ([]*Payment, error) {
+	return r.list(ctx, `
+		UPDATE payments
+		SET state = 'capturing', claimed_at = now(), updated_at = now()
+		WHERE id IN (
+			SELECT id FROM payments
+			WHERE event_id = $1 AND `+claimable+` AND updated_at < $4
+			ORDER BY created_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+paymentColumns,
+		eventID, lease.Seconds(), limit, before)
+}
+
+// ClaimBooking takes the booking's authorization to capture, or returns nil if it has none
+// free to claim.
+func (r *PaymentsRepository) ClaimBooking(ctx context.Context, bookingID string, lease time.Duration) (*Payment, error) {
+	p, err := scanPayment(r.db.Pool.QueryRow(ctx, `
+		UPDATE payments
+		SET state = 'capturing', claimed_at = now(), updated_at = now()
+		WHERE booking_id = $1 AND `+claimable+`
+		RETURNING `+paymentColumns,
+		bookingID, lease.Seconds()))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return p, nil
+}
+
+// Captured marks a claimed payment captured and its booking paid.
+func (r *PaymentsRepository) Captured(ctx context.Context, p *Payment) error {
+	return r.db.WithTx(ctx, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			UPDATE payments
+			SET state = 'captured', captured_at = now(), claimed_at = NULL, error = NULL, updated_at = now()
+			WHERE id = $1
+		`, p.ID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			UPDATE bookings
+			SET payment_status = 'paid', amount_paid = $3, updated_at = now()
+			WHERE event_id = $1 AND id = $2
+		`, p.EventID, p.BookingID, p.Amount)
+		return err
+	})
+}
+
+// Release hands a claimed payment back to be captured later, recording why this attempt
+// didn't.
+func (r *PaymentsRepository) Release(ctx context.Context, id, errMsg string) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		UPDATE payments
+		SET state = 'authorized', claimed_at = NULL, error = $2, updated_at = now()
+		WHERE id = $1 AND state = 'capturing'
+	`, id, errMsg)
+	return err
+}
+
+// Failed marks a claimed payment that can't be captured failed, along with its booking's
+// payment.
+func (r *PaymentsRepository) Failed(ctx context.Context, p *Payment, errMsg string) error {
+	return r.db.WithTx(ctx, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			UPDATE payments
+			SET state = 'failed', claimed_at = NULL, error = $2, updated_at = now()
+			WHERE id = $1
+		`, p.ID, errMsg)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			UPDATE bookings
+			SET payment_status = 'failed', updated_at = now()
+			WHERE event_id = $1 AND id = $2
+		`, p.EventID, p.BookingID)
+		return err
+	})
+}
+
+// Voided marks an authorization voided and, if the booking's payment is still just
+// authorized, the booking's payment too. It reports false if the payment was no longer
+// authorized, e.g. because a capture claimed it first.
+func (r *PaymentsRepository) Voided(ctx context.Context, p *Payment) (bool, error) {
+	voided := false
+	err := r.db.WithTx(ctx, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `
+			UPDATE payments
+			SET state = 'voided', voided_at = now(), updated_at = now()
+			WHERE id = $1 AND state = 'authorized'
+		`, p.ID)
+		if err != nil || tag.RowsAffected() == 0 {
+			return err
+		}
+		voided = true
+		_, err = tx.Exec(ctx, `
+			UPDATE bookings
+			SET payment_status = 'voided', updated_at = now()
+			WHERE event_id = $1 AND id = $2 AND payment_status = 'authorized'
+		`, p.EventID, p.BookingID)
+		return err
+	})
+	return voided, err
+}
+
+// Refunded marks a captured payment refunded.
+func (r *PaymentsRepository) Refunded(ctx context.Context, id string) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		UPDATE payments
+		SET state = 'refunded', refunded_at = now(), updated_at = now()
+		WHERE id = $1 AND state = 'captured'
+	`, id)
+	return err
+}
+
+// DueForCapture returns the events with authorizations to capture now: those whose capture
+// time (start time when unset) has passed, and those switched back to immediate capture.
+// Cancelled events are left for their refund to void.
+func (r *PaymentsRepository) DueForCapture(ctx context.Context, lease time.Duration) ([]string, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT DISTINCT p.event_id
+		FROM payments p
+		JOIN events e ON e.id = p.event_id
+		WHERE (p.state = 'authorized' OR (p.state = 'capturing' AND p.claimed_at < now() - make_interval(secs => $1)))
+		  AND e.status <> 'cancelled'
+		  AND (e.payment_capture = 'immediate' OR COALESCE(e.capture_at, e.start_time) <= now())
+	`, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,82 @@
+package payments
+
+import (
+	"context"
+	"time"
+)
+
+// ProviderEvent is a payment webhook event waiting to be applied: the provider reported
+// Amount taken (or authorized) under ProviderRef for the booking.
+type ProviderEvent struct {
+	ID          string    `json:"id"`
+	Provider    string    `json:"provider"`
+	Type        string    `json:"type"`
+	BookingID   *string   `json:"booking_id,omitempty"`
+	ProviderRef string    `json:"provider_ref"`
+	Amount      float64   `json:"amount"`
+	Currency    string    `json:"currency"`
+	Attempts    int       `json:"attempts"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// RecordEvent stores e for processing. It reports false, storing nothing, when an event with
+// the same ID was already recorded, as when a provider redelivers it.
+func (r *PaymentsRepository) RecordEvent(ctx context.Context, e *ProviderEvent) (bool, error) {
+	result, err := r.db.Pool.Exec(ctx, `
+		INSERT INTO provider_events (id, provider, type, booking_id, provider_ref, amount, currency)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (id) DO NOTHING`,
+		e.ID, e.Provider, e.Type, e.BookingID, e.ProviderRef, e.Amount, e.Currency)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// ClaimEvents leases up to limit unprocessed events with fewer than maxAttempts attempts,
+// oldest first. A lease left by a crashed instance runs out after lease.
+func (r *PaymentsRepository) ClaimEvents(ctx context.Context, limit, maxAttempts int, lease time.Duration) ([]*ProviderEvent, error) {
+	query := `
+		-- name: provider_events_claim
+		UPDATE provider_events e
+		SET claimed_at = now(), attempts = e.attempts + 1
+		FROM (
+			SELECT id FROM provider_events
+			WHERE processed_at IS NULL AND attempts < $2
+			  AND (claimed_at IS NULL OR claimed_at < now() - make_interval(secs => $3))
+			ORDER BY created_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		) due
+		WHERE e.id = due.id
+		RETURNING e.id, e.provider, e.type, e.booking_id, e.provider_ref, e.amount, e.currency, e.attempts, e.created_at`
+
+	rows, err := r.db.Pool.Query(ctx, query, limit, maxAttempts, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*ProviderEvent
+	for rows.Next() {
+		e := &ProviderEvent{}
+		if err := rows.Scan(&e.ID, &e.Provider, &e.Type, &e.BookingID, &e.ProviderRef, &e.Amount, &e.Currency, &e.Attempts, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// EventProcessed marks a claimed event applied.
+func (r *PaymentsRepository) EventProcessed(ctx context.Context, id string) error {
+	_, err := r.db.Pool.Exec(ctx, `UPDATE provider_events SET processed_at = now(), claimed_at = NULL, last_error = NULL WHERE id = $1`, id)
+	return err
+}
+
+// ReleaseEvent gives up a claim after a failed attempt, keeping the error, so the event is
+// retried on a later pass.
+func (r *PaymentsRepository) ReleaseEvent(ctx context.Context, id, errMsg string) error {
+	_, err := r.db.Pool.Exec(ctx, `UPDATE provider_events SET claimed_at = NULL, last_error = $2 WHERE id = $1`, id, errMsg)
+	return err
+}
@@ -0,0 +1,45 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Refund reasons.
+const (
+	RefundBookingCancelled = "booking_cancelled"
+	RefundEventCancelled   = "event_cancelled"
+	// RefundSeatsCancelled pays back some of a booking's seats; the booking stays paid
+	RefundSeatsCancelled = "seats_cancelled"
+)
+
+// Refund is money paid back for a cancelled booking, with the breakdown it was worked out
+// from. PaymentID is nil for bookings paid before payments were recorded.
+type Refund struct {
+	ID              string          `json:"id"`
+	BookingID       string          `json:"booking_id"`
+	PaymentID       *string         `json:"payment_id,omitempty"`
+	Reason          string          `json:"reason"`
+	Currency        string          `json:"currency"`
+	Amount          float64         `json:"amount"`
+	CancellationFee float64         `json:"cancellation_fee"`
+	Breakdown       json.RawMessage `json:"breakdown"`
+	ProviderRef     *string         `json:"provider_ref,omitempty"`
+	CreatedAt       time.Time       `json:"created_at"`
+}
+
+func (r *PaymentsRepository) CreateRefund(ctx context.Context, f *Refund) error {
+	return r.db.Pool.QueryRow(ctx, `
+		INSERT INTO refunds (booking_id, payment_id, reason, currency, amount, cancellation_fee, breakdown, provider_ref)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at`,
+		f.BookingID, f.PaymentID, f.Reason, f.Currency, f.Amount, f.CancellationFee, f.Breakdown, f.ProviderRef).Scan(&f.ID, &f.CreatedAt)
+}
+
+// FeesCharged is the cancellation fee already kept by the booking's refunds.
+func (r *PaymentsRepository) FeesCharged(ctx context.Context, bookingID string) (float64, error) {
+	var fees float64
+	err := r.db.Pool.QueryRow(ctx, `SELECT COALESCE(SUM(cancellation_fee), 0) FROM refunds WHERE booking_id = $1`, bookingID).Scan(&fees)
+	return fees, err
+}
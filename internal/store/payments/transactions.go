@@ -0,0 +1,71 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Transaction is a provider transaction ID a payment callback was processed under. Result
+// is the callback's response once it completed, nil while it is in flight.
+type Transaction struct {
+	PaymentID   string          `json:"payment_id"`
+	BookingID   string          `json:"booking_id"`
+	Amount      float64         `json:"amount"`
+	Result      json.RawMessage `json:"result,omitempty"`
+	ClaimedAt   time.Time       `json:"claimed_at"`
+	CompletedAt *time.Time      `json:"completed_at,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// ClaimTransaction claims t.PaymentID for processing t. It returns nil once claimed, or the
+// transaction already holding the ID: completed, still in flight, or recorded for another
+// booking. A claim for the same booking older than lease was abandoned and is taken over.
+func (r *PaymentsRepository) ClaimTransaction(ctx context.Context, t *Transaction, lease time.Duration) (*Transaction, error) {
+	result, err := r.db.Pool.Exec(ctx, `
+		INSERT INTO payment_transactions (payment_id, booking_id, amount)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (payment_id) DO UPDATE SET claimed_at = now()
+		WHERE payment_transactions.booking_id = $2
+		  AND payment_transactions.completed_at IS NULL
+		  AND payment_transactions.claimed_at < now() - make_interval(secs => $4)`,
+		t.PaymentID, t.BookingID, t.Amount, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	if result.RowsAffected() > 0 {
+		return nil, nil
+	}
+
+	held := &Transaction{}
+	err = r.db.Pool.QueryRow(ctx, `
+		SELECT payment_id, booking_id, amount, result, claimed_at, completed_at, created_at
+		FROM payment_transactions
+		WHERE payment_id = $1
+	`, t.PaymentID).Scan(&held.PaymentID, &held.BookingID, &held.Amount, &held.Result, &held.ClaimedAt, &held.CompletedAt, &held.CreatedAt)
+	if err == pgx.ErrNoRows {
+		// Released between the two statements; the caller's retry claims it
+		return &Transaction{PaymentID: t.PaymentID, BookingID: t.BookingID, ClaimedAt: time.Now()}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return held, nil
+}
+
+// CompleteTransaction stores the result of a claimed transaction, for duplicates to get back.
+func (r *PaymentsRepository) CompleteTransaction(ctx context.Context, paymentID string, result json.RawMessage) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		UPDATE payment_transactions SET result = $2, completed_at = now()
+		WHERE payment_id = $1 AND completed_at IS NULL`, paymentID, []byte(result))
+	return err
+}
+
+// ReleaseTransaction drops a claim whose processing failed before anything was settled, so a
+// retried callback is processed again.
+func (r *PaymentsRepository) ReleaseTransaction(ctx context.Context, paymentID string) error {
+	_, err := r.db.Pool.Exec(ctx, `DELETE FROM payment_transactions WHERE payment_id = $1 AND completed_at IS NULL`, paymentID)
+	return err
+}
@@ -0,0 +1,166 @@
+package prewarms
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store"
+)
+
+const (
+	StatusScheduled = "scheduled"
+	StatusReady     = "ready"
+	StatusDegraded  = "degraded"
+)
+
+// Check is one step of an on-sale warm-up and how it went.
+type Check struct {
+	Name       string `json:"name"`
+	OK         bool   `json:"ok"`
+	Detail     string `json:"detail"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// Prewarm is an on-sale scheduled to be warmed up, with its readiness report once it has
+// been.
+type Prewarm struct {
+	EventID       string    `json:"event_id"`
+	EventName     string    `json:"event_name"`
+	SaleStartTime time.Time `json:"sale_start_time"`
+	// Status is one of the Status constants; Checks and WarmedAt are set once it isn't
+	// StatusScheduled
+	Status    string     `json:"status"`
+	Checks    []Check    `json:"checks,omitempty"`
+	WarmedAt  *time.Time `json:"warmed_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+type PrewarmsRepository struct {
+	db  *store.DB
+	log *zap.Logger
+}
+
+func NewPrewarmsRepository(db *store.DB, log *zap.Logger) *PrewarmsRepository {
+	return &PrewarmsRepository{db: db, log: log}
+}
+
+const prewarmColumns = `p.event_id, e.name, p.sale_start_time, p.status, p.checks, p.warmed_at, p.created_at, p.updated_at`
+
+func scanPrewarm(row pgx.Row) (*Prewarm, error) {
+	p := &Prewarm{}
+	var checks []byte
+	err := row.Scan(&p.EventID, &p.EventName, &p.SaleStartTime, &p.Status, &checks, &p.WarmedAt, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if checks != nil {
+		if err := json.Unmarshal(checks, &p.Checks); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Schedule sets the event's on-sale to start at saleStart, replacing any earlier schedule
+// and its report.
+func (r *PrewarmsRepository) Schedule(ctx context.Context, eventID string, saleStart time.Time) (*Prewarm, error) {
+	_, err := r.db.Pool.Exec(ctx, `
+		INSERT INTO onsale_prewarms (event_id, sale_start_time)
+		VALUES ($1, $2)
+		ON CONFLICT (event_id) DO UPDATE
+		SET sale_start_time = EXCLUDED.sale_start_time, status = 'scheduled', checks = NULL, warmed_at = NULL, updated_at = now()
+	`, eventID, saleStart)
+	if err != nil {
+		return nil, err
+	}
+	return r.Get(ctx, eventID)
+}
+
+// Get returns the event's scheduled on-sale, or nil if it has none.
+func (r *PrewarmsRepository) Get(ctx context.Context, eventID string) (*Prewarm, error) {
+	p, err := scanPrewarm(r.db.Pool.QueryRow(ctx, `
+		SELECT `+prewarmColumns+`
+		FROM onsale_prewarms p
+		JOIN events e ON e.id = p.event_id
+		WHERE p.event_id = $1
+	`, eventID))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	return p, err
+}
+
+// Delete unschedules the event's on-sale. It reports false if none was scheduled.
+func (r *PrewarmsRepository) Delete(ctx context.Context, eventID string) (bool, error) {
+	tag, err := r.db.Pool.Exec(ctx, `DELETE FROM onsale_prewarms WHERE event_id = $1`, eventID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// List returns on-sales starting at or after since, soonest first.
+func (r *PrewarmsRepository) List(ctx context.Context, since time.Time, limit, offset int) ([]*Prewarm, error) {
+	return r.list(ctx, `
+		SELECT `+prewarmColumns+`
+		FROM onsale_prewarms p
+		JOIN events e ON e.id = p.event_id
+		WHERE p.sale_start_time >= $1
+		ORDER BY p.sale_start_time, p.event_id
+		LIMIT $2 OFFSET $3
+	`, since, limit, offset)
+}
+
+// Due returns the on-sales of live events starting after now and no later than until,
+// soonest first.
+func (r *PrewarmsRepository) Due(ctx context.Context, now, until time.Time) ([]*Prewarm, error) {
+	return r.list(ctx, `
+		SELECT `+prewarmColumns+`
+		FROM onsale_prewarms p
+		JOIN events e ON e.id = p.event_id
+		WHERE p.sale_start_time > $1 AND p.sale_start_time <= $2
+		  AND e.status NOT IN ('expired', 'cancelled')
+		ORDER BY p.sale_start_time, p.event_id
+	`, now, until)
+}
+
+func (r *PrewarmsRepository) list(ctx context.Context, query string, args ...any) ([]*Prewarm, error) {
+	rows, err := r.db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []*Prewarm{}
+	for rows.Next() {
+		p, err := scanPrewarm(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// SaveReport records the warm-up of the on-sale scheduled for saleStart. A report for a
+// schedule that has since changed is dropped, and it reports false.
+func (r *PrewarmsRepository) SaveReport(ctx context.Context, eventID string, saleStart time.Time, status string, checks []Check, warmedAt time.Time) (bool, error) {
+	raw, err := json.Marshal(checks)
+	if err != nil {
+		return false, err
+	}
+	tag, err := r.db.Pool.Exec(ctx, `
+		UPDATE onsale_prewarms
+		SET status = $3, checks = $4::jsonb, warmed_at = $5, updated_at = now()
+		WHERE event_id = $1 AND sale_start_time = $2
+	`, eventID, saleStart, status, raw, warmedAt)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
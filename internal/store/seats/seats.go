@@ -7,19 +7,13 @@ import (
 	"github.com/jackc/pgx/v5"
 	"go.uber.org/zap"
 
+	"github.com/samirwankhede/lewly-pgpyewj/internal/domain"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/store"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/events"
 )
 
-type Seat struct {
-	ID            string     `json:"id"`
-	EventID       string     `json:"event_id"`
-	SeatLabel     string     `json:"seat_label"`
-	Status        string     `json:"status"`
-	HeldUntil     *time.Time `json:"held_until,omitempty"`
-	HeldByBooking *string    `json:"held_by_booking,omitempty"`
-	CreatedAt     time.Time  `json:"created_at"`
-	UpdatedAt     time.Time  `json:"updated_at"`
-}
+// Seat is the canonical seat type; see package domain.
+type Seat = domain.Seat
 
 type SeatsRepository struct {
 	db  *store.DB
@@ -30,27 +24,61 @@ func NewSeatsRepository(db *store.DB, log *zap.Logger) *SeatsRepository {
 	return &SeatsRepository{db: db, log: log}
 }
 
-func (r *SeatsRepository) CreateSeats(ctx context.Context, eventID string, seatLabels []string) error {
+// NewSeat is a seat of a seat map being created. Section, Row and Tier may be empty.
+type NewSeat struct {
+	Label   string
+	Section string
+	Row     string
+	Tier    string
+}
+
+// CreateSeats writes the event's price tiers and its seat map, in layout order.
+func (r *SeatsRepository) CreateSeats(ctx context.Context, eventID string, seats []NewSeat, tiers []events.PriceTier) error {
+	labels := make([]string, len(seats))
+	sections := make([]string, len(seats))
+	rows := make([]string, len(seats))
+	seatTiers := make([]string, len(seats))
+	for i, s := range seats {
+		labels[i], sections[i], rows[i], seatTiers[i] = s.Label, s.Section, s.Row, s.Tier
+	}
+	names := make([]string, len(tiers))
+	prices := make([]float64, len(tiers))
+	for i, t := range tiers {
+		names[i], prices[i] = t.Name, t.Price
+	}
+
 	return r.db.WithTx(ctx, func(tx pgx.Tx) error {
-		for _, label := range seatLabels {
+		if len(tiers) > 0 {
 			_, err := tx.Exec(ctx, `
-				INSERT INTO seats (event_id, seat_label, status)
-				VALUES ($1, $2, 'available')
-			`, eventID, label)
+				INSERT INTO event_price_tiers (event_id, name, price)
+				SELECT $1, name, price
+				FROM unnest($2::text[], $3::numeric[]) AS t(name, price)
+			`, eventID, names, prices)
 			if err != nil {
 				return err
 			}
 		}
-		return nil
+		// One statement for the whole map; generated layouts can run to tens of thousands of seats
+		_, err := tx.Exec(ctx, `
+			INSERT INTO seats (event_id, seat_label, section, row_label, tier, position, status)
+			SELECT $1, label, section, row_label, tier, n, 'available'
+			FROM unnest($2::text[], $3::text[], $4::text[], $5::text[]) WITH ORDINALITY AS s(label, section, row_label, tier, n)
+		`, eventID, labels, sections, rows, seatTiers)
+		return err
 	})
 }
 
+// GetSeatsByEvent returns the seats of an event, reading from the archive for finished events.
 func (r *SeatsRepository) GetSeatsByEvent(ctx context.Context, eventID string) ([]*Seat, error) {
 	query := `
-		SELECT id, event_id, seat_label, status, held_until, held_by_booking, created_at, updated_at
+		SELECT id, event_id, seat_label, section, row_label, tier, status, held_until, held_by_booking, created_at, updated_at, position
 		FROM seats
 		WHERE event_id = $1
-		ORDER BY seat_label`
+		UNION ALL
+		SELECT id, event_id, seat_label, section, row_label, tier, status, held_until, held_by_booking, created_at, updated_at, position
+		FROM seats_archive
+		WHERE event_id = $1
+		ORDER BY position, seat_label`
 
 	rows, err := r.db.Pool.Query(ctx, query, eventID)
 	if err != nil {
@@ -61,9 +89,10 @@ func (r *SeatsRepository) GetSeatsByEvent(ctx context.Context, eventID string) (
 	var seats []*Seat
 	for rows.Next() {
 		seat := &Seat{}
+		var position int
 		err := rows.Scan(
-			&seat.ID, &seat.EventID, &seat.SeatLabel, &seat.Status,
-			&seat.HeldUntil, &seat.HeldByBooking, &seat.CreatedAt, &seat.UpdatedAt,
+			&seat.ID, &seat.EventID, &seat.SeatLabel, &seat.Section, &seat.Row, &seat.Tier, &seat.Status,
+			&seat.HeldUntil, &seat.HeldByBooking, &seat.CreatedAt, &seat.UpdatedAt, &position,
 		)
 		if err != nil {
 			return nil, err
@@ -164,3 +193,56 @@ func (r *SeatsRepository) GetAvailableSeats(ctx context.Context, eventID string)
 
 	return seats, nil
 }
+
+// ArchiveFinishedEvents moves the seats of expired or cancelled events that ended before
+// cutoff into seats_archive. Each event is moved in its own transaction so the hot table
+// is never locked for long. It returns the number of seats archived.
+func (r *SeatsRepository) ArchiveFinishedEvents(ctx context.Context, cutoff time.Time, maxEvents int) (int, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT e.id
+		FROM events e
+		WHERE e.status IN ('expired', 'cancelled') AND e.end_time < $1
+		  AND EXISTS (SELECT 1 FROM seats s WHERE s.event_id = e.id)
+		LIMIT $2`, cutoff, maxEvents)
+	if err != nil {
+		return 0, err
+	}
+	var eventIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		eventIDs = append(eventIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	archived := 0
+	for _, eventID := range eventIDs {
+		err := r.db.WithTx(ctx, func(tx pgx.Tx) error {
+			res, err := tx.Exec(ctx, `
+				WITH moved AS (
+					DELETE FROM seats WHERE event_id = $1
+					RETURNING id, event_id, seat_label, section, row_label, tier, position, status, held_until, held_by_booking, created_at, updated_at
+				)
+				INSERT INTO seats_archive (id, event_id, seat_label, section, row_label, tier, position, status, held_until, held_by_booking, created_at, updated_at)
+				SELECT id, event_id, seat_label, section, row_label, tier, position, status, held_until, held_by_booking, created_at, updated_at FROM moved
+				ON CONFLICT (event_id, id) DO NOTHING`, eventID)
+			if err != nil {
+				return err
+			}
+			archived += int(res.RowsAffected())
+			return nil
+		})
+		if err != nil {
+			return archived, err
+		}
+		r.log.Info("Archived seats", zap.String("event_id", eventID))
+	}
+
+	return archived, nil
+}
@@ -0,0 +1,110 @@
+package snapshots
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store"
+)
+
+// Snapshot is the inventory state of one event at TakenAt.
+type Snapshot struct {
+	ID              int64     `json:"id"`
+	EventID         string    `json:"event_id"`
+	Capacity        int       `json:"capacity"`
+	Reserved        int       `json:"reserved"`
+	Held            int       `json:"held"`
+	TokensRemaining int       `json:"tokens_remaining"`
+	PendingBookings int       `json:"pending_bookings"`
+	WaitlistSize    int       `json:"waitlist_size"`
+	TakenAt         time.Time `json:"taken_at"`
+}
+
+type SnapshotsRepository struct {
+	db  *store.DB
+	log *zap.Logger
+}
+
+func NewSnapshotsRepository(db *store.DB, log *zap.Logger) *SnapshotsRepository {
+	return &SnapshotsRepository{db: db, log: log}
+}
+
+// CollectLive reads the Postgres side of the inventory of every event that is not expired or
+// cancelled. TokensRemaining is left zero for the caller to fill in from Redis.
+func (r *SnapshotsRepository) CollectLive(ctx context.Context) ([]*Snapshot, error) {
+	query := `
+		SELECT e.id,
+		       COALESCE(ec.capacity, e.capacity),
+		       COALESCE(ec.reserved_count, e.reserved),
+		       COALESCE(ec.held_count, 0),
+		       (SELECT COUNT(*) FROM bookings b WHERE b.event_id = e.id AND b.status = 'pending'),
+		       (SELECT COUNT(*) FROM waitlist w WHERE w.event_id = e.id AND NOT w.opted_out AND w.expired_at IS NULL)
+		FROM events e
+		LEFT JOIN event_capacity ec ON ec.event_id = e.id
+		WHERE e.status NOT IN ('expired', 'cancelled')`
+
+	rows, err := r.db.Pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snaps []*Snapshot
+	for rows.Next() {
+		s := &Snapshot{}
+		if err := rows.Scan(&s.EventID, &s.Capacity, &s.Reserved, &s.Held, &s.PendingBookings, &s.WaitlistSize); err != nil {
+			return nil, err
+		}
+		snaps = append(snaps, s)
+	}
+
+	return snaps, rows.Err()
+}
+
+// Insert stores snaps in one transaction, all stamped with takenAt.
+func (r *SnapshotsRepository) Insert(ctx context.Context, snaps []*Snapshot, takenAt time.Time) error {
+	return r.db.WithTx(ctx, func(tx pgx.Tx) error {
+		for _, s := range snaps {
+			_, err := tx.Exec(ctx, `
+				INSERT INTO inventory_snapshots (event_id, capacity, reserved, held, tokens_remaining, pending_bookings, waitlist_size, taken_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			`, s.EventID, s.Capacity, s.Reserved, s.Held, s.TokensRemaining, s.PendingBookings, s.WaitlistSize, takenAt)
+			if err != nil {
+				return err
+			}
+			s.TakenAt = takenAt
+		}
+		return nil
+	})
+}
+
+// ListByEvent returns the event's snapshots taken between from and to, oldest first.
+func (r *SnapshotsRepository) ListByEvent(ctx context.Context, eventID string, from, to time.Time) ([]*Snapshot, error) {
+	query := `
+		SELECT id, event_id, capacity, reserved, held, tokens_remaining, pending_bookings, waitlist_size, taken_at
+		FROM inventory_snapshots
+		WHERE event_id = $1 AND taken_at BETWEEN $2 AND $3
+		ORDER BY taken_at`
+
+	rows, err := r.db.Pool.Query(ctx, query, eventID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snaps := []*Snapshot{}
+	for rows.Next() {
+		s := &Snapshot{}
+		err := rows.Scan(&s.ID, &s.EventID, &s.Capacity, &s.Reserved, &s.Held,
+			&s.TokensRemaining, &s.PendingBookings, &s.WaitlistSize, &s.TakenAt)
+		if err != nil {
+			return nil, err
+		}
+		snaps = append(snaps, s)
+	}
+
+	return snaps, rows.Err()
+}
@@ -0,0 +1,32 @@
+// Package storetest connects tests to a migrated Postgres database.
+package storetest
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/cmd/migrate/migrations"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store"
+)
+
+// DB connects to the database at TEST_POSTGRES_URL, migrated up, and closes it when the
+// test ends. The test is skipped when TEST_POSTGRES_URL isn't set.
+func DB(t *testing.T) *store.DB {
+	t.Helper()
+	url := os.Getenv("TEST_POSTGRES_URL")
+	if url == "" {
+		t.Skip("TEST_POSTGRES_URL is not set")
+	}
+	if err := migrations.Up(url, zap.NewNop()); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	db, err := store.NewDB(context.Background(), url, 32)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(db.Close)
+	return db
+}
@@ -0,0 +1,209 @@
+package subscriptions
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store"
+)
+
+// Subscription kinds and deliveries.
+const (
+	KindCategory = "category"
+	KindTag      = "tag"
+
+	DeliveryInstant = "instant"
+	DeliveryDigest  = "digest"
+)
+
+// Subscription is a category or tag a user wants to hear about new events for. Term is
+// stored lower-cased.
+type Subscription struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	Kind      string    `json:"kind"`
+	Term      string    `json:"term"`
+	Delivery  string    `json:"delivery"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Match is a user a newly published event was matched to.
+type Match struct {
+	UserID   string
+	Email    string
+	Delivery string
+}
+
+// DigestItem is a claimed, unsent digest match with the event it points at.
+type DigestItem struct {
+	UserID      string
+	Email       string
+	EventID     string
+	EventName   string
+	Venue       string
+	StartTime   time.Time
+	EventStatus string
+}
+
+type SubscriptionsRepository struct {
+	db  *store.DB
+	log *zap.Logger
+}
+
+func NewSubscriptionsRepository(db *store.DB, log *zap.Logger) *SubscriptionsRepository {
+	return &SubscriptionsRepository{db: db, log: log}
+}
+
+const subscriptionColumns = `id, user_id, kind, term, delivery, created_at`
+
+func scanSubscription(row pgx.Row) (*Subscription, error) {
+	s := &Subscription{}
+	if err := row.Scan(&s.ID, &s.UserID, &s.Kind, &s.Term, &s.Delivery, &s.CreatedAt); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Upsert subscribes the user to the term, or changes the delivery of an existing
+// subscription to it.
+func (r *SubscriptionsRepository) Upsert(ctx context.Context, s *Subscription) (*Subscription, error) {
+	return scanSubscription(r.db.Pool.QueryRow(ctx, `
+		INSERT INTO event_subscriptions (user_id, kind, term, delivery)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, kind, term) DO UPDATE SET delivery = EXCLUDED.delivery
+		RETURNING `+subscriptionColumns,
+		s.UserID, s.Kind, s.Term, s.Delivery))
+}
+
+// ListByUser returns the user's subscriptions, oldest first.
+func (r *SubscriptionsRepository) ListByUser(ctx context.Context, userID string) ([]*Subscription, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT `+subscriptionColumns+`
+		FROM event_subscriptions
+		WHERE user_id = $1
+		ORDER BY created_at
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []*Subscription{}
+	for rows.Next() {
+		s, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// Count returns how many subscriptions the user has.
+func (r *SubscriptionsRepository) Count(ctx context.Context, userID string) (int, error) {
+	var n int
+	err := r.db.Pool.QueryRow(ctx, `SELECT count(*) FROM event_subscriptions WHERE user_id = $1`, userID).Scan(&n)
+	return n, err
+}
+
+// Delete removes the user's subscription, reporting false if they have none with that ID.
+func (r *SubscriptionsRepository) Delete(ctx context.Context, userID, id string) (bool, error) {
+	result, err := r.db.Pool.Exec(ctx, `DELETE FROM event_subscriptions WHERE user_id = $1 AND id = $2`, userID, id)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// MatchEvent records a match for every user subscribed to the event's category, one of its
+// tags, or a term found in its venue, and returns the users newly matched. A user with both
+// instant and digest subscriptions matching gets the instant alert; instant matches are
+// recorded as sent, digest ones wait for ClaimDigests. An event already matched to a user
+// isn't returned again.
+func (r *SubscriptionsRepository) MatchEvent(ctx context.Context, eventID, category string, tags []string, venue string) ([]*Match, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		-- name: subscriptions_match_event
+		WITH matched AS (
+			SELECT DISTINCT ON (s.user_id) s.user_id, s.id, s.delivery
+			FROM event_subscriptions s
+			WHERE (s.kind = 'category' AND s.term = lower($2))
+			   OR (s.kind = 'tag' AND (s.term = ANY($3::text[]) OR strpos(lower($4), s.term) > 0))
+			ORDER BY s.user_id, s.delivery = 'instant' DESC
+		)
+		INSERT INTO event_subscription_matches (user_id, event_id, subscription_id, delivery, sent_at)
+		SELECT user_id, $1, id, delivery, CASE WHEN delivery = 'instant' THEN now() END
+		FROM matched
+		ON CONFLICT (user_id, event_id) DO NOTHING
+		RETURNING user_id, (SELECT email FROM users WHERE id = user_id), delivery
+	`, eventID, category, tags, venue)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*Match
+	for rows.Next() {
+		m := &Match{}
+		if err := rows.Scan(&m.UserID, &m.Email, &m.Delivery); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+// ClaimDigests leases the unsent digest matches of up to limit users whose oldest unsent
+// match was made before dueBefore, so only one instance sends each digest. A lease left by a
+// crash runs out after lease.
+func (r *SubscriptionsRepository) ClaimDigests(ctx context.Context, dueBefore time.Time, limit int, lease time.Duration) ([]*DigestItem, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		-- name: subscriptions_claim_digests
+		UPDATE event_subscription_matches m
+		SET claimed_at = now()
+		FROM events e, users u, (
+			SELECT user_id, event_id FROM event_subscription_matches
+			WHERE sent_at IS NULL
+			  AND (claimed_at IS NULL OR claimed_at < now() - make_interval(secs => $3))
+			  AND user_id IN (
+				SELECT user_id FROM event_subscription_matches
+				WHERE sent_at IS NULL
+				  AND (claimed_at IS NULL OR claimed_at < now() - make_interval(secs => $3))
+				GROUP BY user_id
+				HAVING min(created_at) < $1
+				ORDER BY min(created_at)
+				LIMIT $2
+			  )
+			FOR UPDATE SKIP LOCKED
+		) due
+		WHERE m.user_id = due.user_id AND m.event_id = due.event_id
+		  AND e.id = m.event_id AND u.id = m.user_id
+		RETURNING m.user_id, u.email, e.id, e.name, e.venue, e.start_time, e.status
+	`, dueBefore, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*DigestItem
+	for rows.Next() {
+		d := &DigestItem{}
+		if err := rows.Scan(&d.UserID, &d.Email, &d.EventID, &d.EventName, &d.Venue, &d.StartTime, &d.EventStatus); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// DigestSent marks the user's claimed matches for the events as sent.
+func (r *SubscriptionsRepository) DigestSent(ctx context.Context, userID string, eventIDs []string) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		UPDATE event_subscription_matches
+		SET sent_at = now(), claimed_at = NULL
+		WHERE user_id = $1 AND event_id = ANY($2::uuid[])
+	`, userID, eventIDs)
+	return err
+}
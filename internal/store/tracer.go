@@ -0,0 +1,136 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/metrics"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/tracing"
+)
+
+// QueryTracer records per-query duration and error metrics, logs slow statements and, when the
+// query runs inside a traced request or message, records it as a span.
+//
+// Queries are labelled by a leading "-- name: <name>" comment when present, otherwise
+// by the statement verb and first table, e.g. "select_bookings".
+type QueryTracer struct {
+	log       *zap.Logger
+	threshold time.Duration
+}
+
+// NewQueryTracer returns a tracer that logs queries slower than threshold to log.
+// A nil logger or zero threshold disables slow-query logging; metrics are always recorded.
+func NewQueryTracer(log *zap.Logger, threshold time.Duration) *QueryTracer {
+	return &QueryTracer{log: log, threshold: threshold}
+}
+
+type queryTraceKey struct{}
+
+type queryTrace struct {
+	name  string
+	sql   string
+	args  []any
+	start time.Time
+	span  trace.Span
+}
+
+func (t *QueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	name := QueryName(data.SQL)
+	ctx, span := tracing.Child(ctx, "db "+name,
+		attribute.String("db.system", "postgresql"),
+		attribute.String("db.operation", name),
+	)
+	if span.IsRecording() {
+		span.SetAttributes(attribute.String("db.statement", compactSQL(data.SQL)))
+	}
+	return context.WithValue(ctx, queryTraceKey{}, &queryTrace{
+		name:  name,
+		sql:   data.SQL,
+		args:  data.Args,
+		start: time.Now(),
+		span:  span,
+	})
+}
+
+func (t *QueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	qt, ok := ctx.Value(queryTraceKey{}).(*queryTrace)
+	if !ok {
+		return
+	}
+	elapsed := time.Since(qt.start)
+	tracing.End(qt.span, data.Err, pgx.ErrNoRows)
+	metrics.DBQueryDuration.WithLabelValues(qt.name).Observe(elapsed.Seconds())
+
+	if data.Err != nil && !errors.Is(data.Err, pgx.ErrNoRows) {
+		metrics.DBQueryErrorsTotal.WithLabelValues(qt.name, errorType(data.Err)).Inc()
+	}
+
+	if t.log != nil && t.threshold > 0 && elapsed >= t.threshold {
+		t.log.Warn("slow query",
+			zap.String("query", qt.name),
+			zap.Duration("duration", elapsed),
+			zap.String("sql", compactSQL(qt.sql)),
+			zap.Strings("args", redactArgs(qt.args)),
+			zap.Error(data.Err),
+		)
+	}
+}
+
+var (
+	queryNameComment = regexp.MustCompile(`^\s*--\s*name:\s*(\S+)`)
+	queryTable       = regexp.MustCompile(`(?i)\b(?:from|into|update)\s+([a-z_][a-z0-9_]*)`)
+)
+
+// QueryName derives the metric label for sql.
+func QueryName(sql string) string {
+	if m := queryNameComment.FindStringSubmatch(sql); m != nil {
+		return m[1]
+	}
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return "unknown"
+	}
+	verb := strings.ToLower(fields[0])
+	if m := queryTable.FindStringSubmatch(sql); m != nil {
+		return verb + "_" + strings.ToLower(m[1])
+	}
+	return verb
+}
+
+func errorType(err error) string {
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.As(err, &pgErr):
+		return "pg_" + pgErr.Code
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case pgconn.SafeToRetry(err):
+		return "connection"
+	}
+	return "other"
+}
+
+// redactArgs keeps only the position and Go type of each bound parameter.
+func redactArgs(args []any) []string {
+	out := make([]string, len(args))
+	for i, a := range args {
+		out[i] = fmt.Sprintf("$%d=<%T>", i+1, a)
+	}
+	return out
+}
+
+func compactSQL(sql string) string {
+	return strings.Join(strings.Fields(sql), " ")
+}
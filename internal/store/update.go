@@ -0,0 +1,147 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ColumnKind is the Go type a column's update value is converted to before it is bound.
+type ColumnKind int
+
+const (
+	KindText ColumnKind = iota
+	KindInt
+	KindNumber
+	KindBool
+	KindTime // RFC 3339 string or time.Time
+	KindJSON // any JSON value, stored encoded
+)
+
+// Column is a column an update may set. Nullable columns also accept nil.
+type Column struct {
+	Kind     ColumnKind
+	Nullable bool
+}
+
+// Columns whitelists the columns of a table that an Update may set, by name. Names are only
+// ever taken from here, never from the caller's input.
+type Columns map[string]Column
+
+// ErrNoUpdates is returned by Update when there is nothing to set.
+var ErrNoUpdates = errors.New("no columns to update")
+
+// ColumnError rejects an update of a column that isn't in the whitelist or whose value
+// doesn't convert to the column's kind.
+type ColumnError struct {
+	Column string
+	Reason string
+}
+
+func (e *ColumnError) Error() string { return e.Column + ": " + e.Reason }
+
+// Update is an UPDATE statement built from caller-supplied values against a whitelist.
+type Update struct {
+	SQL  string
+	Args []any
+}
+
+// BuildUpdate builds "UPDATE table SET ..., updated_at = now() WHERE key = $n" for values,
+// labelled name for query metrics. Columns are set in name order so equal updates produce
+// equal SQL.
+func BuildUpdate(name, table string, allowed Columns, values map[string]any, key string, keyValue any) (*Update, error) {
+	if len(values) == 0 {
+		return nil, ErrNoUpdates
+	}
+	fields := make([]string, 0, len(values))
+	for field := range values {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	sets := make([]string, 0, len(fields)+1)
+	args := make([]any, 0, len(fields)+1)
+	for _, field := range fields {
+		col, ok := allowed[field]
+		if !ok {
+			return nil, &ColumnError{Column: field, Reason: "not updatable"}
+		}
+		v, err := convert(col, values[field])
+		if err != nil {
+			return nil, &ColumnError{Column: field, Reason: err.Error()}
+		}
+		args = append(args, v)
+		sets = append(sets, field+" = $"+strconv.Itoa(len(args)))
+	}
+	sets = append(sets, "updated_at = now()")
+	args = append(args, keyValue)
+
+	sql := "-- name: " + name + "\nUPDATE " + table + " SET " + strings.Join(sets, ", ") + " WHERE " + key + " = $" + strconv.Itoa(len(args))
+	return &Update{SQL: sql, Args: args}, nil
+}
+
+// convert checks v against the column's kind. Numbers decoded from JSON arrive as float64.
+func convert(col Column, v any) (any, error) {
+	if v == nil {
+		if !col.Nullable {
+			return nil, errors.New("cannot be null")
+		}
+		return nil, nil
+	}
+	switch col.Kind {
+	case KindText:
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+		return nil, errors.New("must be a string")
+	case KindInt:
+		switch n := v.(type) {
+		case int:
+			return n, nil
+		case int64:
+			return int(n), nil
+		case float64:
+			if n == math.Trunc(n) && math.Abs(n) <= math.MaxInt32 {
+				return int(n), nil
+			}
+		}
+		return nil, errors.New("must be an integer")
+	case KindNumber:
+		switch n := v.(type) {
+		case float64:
+			return n, nil
+		case int:
+			return float64(n), nil
+		}
+		return nil, errors.New("must be a number")
+	case KindBool:
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+		return nil, errors.New("must be a boolean")
+	case KindTime:
+		switch t := v.(type) {
+		case time.Time:
+			return t, nil
+		case string:
+			parsed, err := time.Parse(time.RFC3339, t)
+			if err != nil {
+				return nil, errors.New("must be an RFC 3339 time")
+			}
+			return parsed, nil
+		}
+		return nil, errors.New("must be an RFC 3339 time")
+	case KindJSON:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("must be JSON: %v", err)
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("unknown column kind %d", col.Kind)
+}
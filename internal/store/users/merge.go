@@ -0,0 +1,132 @@
+package users
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// MergeResult counts what a merge moved onto the kept user.
+type MergeResult struct {
+	SourceID        string `json:"merged_user_id"`
+	TargetID        string `json:"user_id"`
+	Bookings        int64  `json:"bookings"`
+	WaitlistEntries int64  `json:"waitlist_entries"`
+	Likes           int64  `json:"likes"`
+	LinkedOAuth     bool   `json:"linked_oauth"`
+}
+
+// MergeInto moves everything sourceID owns onto targetID and deletes sourceID, in one
+// transaction. Where both users hold the same thing (a like, a follow, a waitlist spot on
+// the same event) the target keeps one: the better waitlist position, and each event's like
+// count drops by the duplicate. The source's OAuth identity moves over if the target has
+// none. It returns nil if either user no longer exists.
+func (r *UsersRepository) MergeInto(ctx context.Context, sourceID, targetID string) (*MergeResult, error) {
+	var res *MergeResult
+	err := r.db.WithTx(ctx, func(tx pgx.Tx) error {
+		// Lock both users in a fixed order so concurrent merges can't deadlock
+		rows, err := tx.Query(ctx, `SELECT id, oauth_provider, oauth_sub FROM users WHERE id = ANY($1::uuid[]) ORDER BY id FOR UPDATE`, []string{sourceID, targetID})
+		if err != nil {
+			return err
+		}
+		oauth := map[string][2]string{}
+		for rows.Next() {
+			var id, provider, sub string
+			if err := rows.Scan(&id, &provider, &sub); err != nil {
+				rows.Close()
+				return err
+			}
+			oauth[id] = [2]string{provider, sub}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if len(oauth) < 2 {
+			return nil
+		}
+		res = &MergeResult{SourceID: sourceID, TargetID: targetID}
+
+		tag, err := tx.Exec(ctx, `UPDATE bookings SET user_id = $2 WHERE user_id = $1`, sourceID, targetID)
+		if err != nil {
+			return err
+		}
+		res.Bookings = tag.RowsAffected()
+		if _, err := tx.Exec(ctx, `UPDATE booking_audit SET user_id = $2 WHERE user_id = $1`, sourceID, targetID); err != nil {
+			return err
+		}
+
+		// On events both are waitlisted for, keep the spot nearer the front
+		_, err = tx.Exec(ctx, `
+			DELETE FROM waitlist w
+			USING waitlist o
+			WHERE w.event_id = o.event_id
+			  AND w.user_id = ANY($1::uuid[]) AND o.user_id = ANY($1::uuid[]) AND w.user_id <> o.user_id
+			  AND (w.position > o.position OR (w.position = o.position AND w.user_id = $2))
+		`, []string{sourceID, targetID}, sourceID)
+		if err != nil {
+			return err
+		}
+		tag, err = tx.Exec(ctx, `UPDATE waitlist SET user_id = $2 WHERE user_id = $1`, sourceID, targetID)
+		if err != nil {
+			return err
+		}
+		res.WaitlistEntries = tag.RowsAffected()
+
+		// A like both gave is counted once
+		_, err = tx.Exec(ctx, `
+			UPDATE events e
+			SET likes = GREATEST(e.likes - 1, 0)
+			FROM event_likes s
+			JOIN event_likes t ON t.event_id = s.event_id AND t.user_id = $2
+			WHERE s.user_id = $1 AND e.id = s.event_id
+		`, sourceID, targetID)
+		if err != nil {
+			return err
+		}
+		tag, err = tx.Exec(ctx, `
+			UPDATE event_likes s SET user_id = $2
+			WHERE s.user_id = $1
+			  AND NOT EXISTS (SELECT 1 FROM event_likes t WHERE t.user_id = $2 AND t.event_id = s.event_id)
+		`, sourceID, targetID)
+		if err != nil {
+			return err
+		}
+		res.Likes = tag.RowsAffected()
+
+		// Rows the target already has an equivalent of are left to cascade with the source
+		for _, q := range []string{
+			`UPDATE organizer_follows s SET user_id = $2 WHERE s.user_id = $1
+			   AND NOT EXISTS (SELECT 1 FROM organizer_follows t WHERE t.user_id = $2 AND t.organizer_id = s.organizer_id)`,
+			`UPDATE event_invitations s SET user_id = $2 WHERE s.user_id = $1
+			   AND NOT EXISTS (SELECT 1 FROM event_invitations t WHERE t.user_id = $2 AND t.event_id = s.event_id)`,
+			`UPDATE event_subscriptions s SET user_id = $2 WHERE s.user_id = $1
+			   AND NOT EXISTS (SELECT 1 FROM event_subscriptions t WHERE t.user_id = $2 AND t.kind = s.kind AND t.term = s.term)`,
+			`UPDATE event_subscription_matches s SET user_id = $2 WHERE s.user_id = $1
+			   AND NOT EXISTS (SELECT 1 FROM event_subscription_matches t WHERE t.user_id = $2 AND t.event_id = s.event_id)`,
+			`UPDATE user_webhooks SET user_id = $2 WHERE user_id = $1`,
+		} {
+			if _, err := tx.Exec(ctx, q, sourceID, targetID); err != nil {
+				return err
+			}
+		}
+
+		// The identity is unique, so it leaves the source before joining the target
+		if src := oauth[sourceID]; src[1] != "" && oauth[targetID][1] == "" {
+			if _, err := tx.Exec(ctx, `UPDATE users SET oauth_provider = '', oauth_sub = '' WHERE id = $1`, sourceID); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx, `UPDATE users SET oauth_provider = $2, oauth_sub = $3 WHERE id = $1`, targetID, src[0], src[1]); err != nil {
+				return err
+			}
+			res.LinkedOAuth = true
+		}
+
+		_, err = tx.Exec(ctx, `DELETE FROM users WHERE id = $1`, sourceID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
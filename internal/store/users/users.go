@@ -11,16 +11,19 @@ import (
 )
 
 type User struct {
-	ID            string    `json:"id"`
-	Name          string    `json:"name"`
-	Email         string    `json:"email"`
-	Phone         string    `json:"phone"`
-	PasswordHash  string    `json:"-"` // Don't expose in JSON
-	OAuthProvider string    `json:"oauth_provider,omitempty"`
-	OAuthSub      string    `json:"oauth_sub,omitempty"`
-	Role          string    `json:"role"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	Email         string `json:"email"`
+	Phone         string `json:"phone"`
+	PasswordHash  string `json:"-"` // Don't expose in JSON
+	OAuthProvider string `json:"oauth_provider,omitempty"`
+	OAuthSub      string `json:"oauth_sub,omitempty"`
+	Role          string `json:"role"`
+	RoleVersion   int    `json:"-"`
+	// PreferredCurrency is the ISO 4217 code prices are displayed in, "" for each event's own
+	PreferredCurrency string    `json:"preferred_currency,omitempty"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
 }
 
 type UsersRepository struct {
@@ -34,12 +37,12 @@ func NewUsersRepository(db *store.DB, log *zap.Logger) *UsersRepository {
 
 func (r *UsersRepository) Create(ctx context.Context, user *User) (*User, error) {
 	query := `
-		INSERT INTO users (name, email, phone, password_hash, role)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id, created_at, updated_at`
+		INSERT INTO users (name, email, phone, password_hash, oauth_provider, oauth_sub, role)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, role_version, created_at, updated_at`
 
-	err := r.db.Pool.QueryRow(ctx, query, user.Name, user.Email, user.Phone, user.PasswordHash, user.Role).
-		Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+	err := r.db.Pool.QueryRow(ctx, query, user.Name, user.Email, user.Phone, user.PasswordHash, user.OAuthProvider, user.OAuthSub, user.Role).
+		Scan(&user.ID, &user.RoleVersion, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -49,14 +52,14 @@ func (r *UsersRepository) Create(ctx context.Context, user *User) (*User, error)
 
 func (r *UsersRepository) GetByID(ctx context.Context, id string) (*User, error) {
 	query := `
-		SELECT id, name, email, phone, password_hash, oauth_provider, oauth_sub, role, created_at, updated_at
+		SELECT id, name, email, phone, password_hash, oauth_provider, oauth_sub, role, role_version, preferred_currency, created_at, updated_at
 		FROM users
 		WHERE id = $1`
 
 	user := &User{}
 	err := r.db.Pool.QueryRow(ctx, query, id).Scan(
 		&user.ID, &user.Name, &user.Email, &user.Phone, &user.PasswordHash,
-		&user.OAuthProvider, &user.OAuthSub, &user.Role, &user.CreatedAt, &user.UpdatedAt,
+		&user.OAuthProvider, &user.OAuthSub, &user.Role, &user.RoleVersion, &user.PreferredCurrency, &user.CreatedAt, &user.UpdatedAt,
 	)
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -70,14 +73,14 @@ func (r *UsersRepository) GetByID(ctx context.Context, id string) (*User, error)
 
 func (r *UsersRepository) GetByEmail(ctx context.Context, email string) (*User, error) {
 	query := `
-		SELECT id, name, email, phone, password_hash, oauth_provider, oauth_sub, role, created_at, updated_at
+		SELECT id, name, email, phone, password_hash, oauth_provider, oauth_sub, role, role_version, preferred_currency, created_at, updated_at
 		FROM users
 		WHERE email = $1`
 
 	user := &User{}
 	err := r.db.Pool.QueryRow(ctx, query, email).Scan(
 		&user.ID, &user.Name, &user.Email, &user.Phone, &user.PasswordHash,
-		&user.OAuthProvider, &user.OAuthSub, &user.Role, &user.CreatedAt, &user.UpdatedAt,
+		&user.OAuthProvider, &user.OAuthSub, &user.Role, &user.RoleVersion, &user.PreferredCurrency, &user.CreatedAt, &user.UpdatedAt,
 	)
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -89,6 +92,48 @@ func (r *UsersRepository) GetByEmail(ctx context.Context, email string) (*User,
 	return user, nil
 }
 
+// GetByOAuth returns the user signed in with the provider's subject, or nil if there is none.
+func (r *UsersRepository) GetByOAuth(ctx context.Context, provider, sub string) (*User, error) {
+	query := `
+		SELECT id, name, email, phone, password_hash, oauth_provider, oauth_sub, role, role_version, preferred_currency, created_at, updated_at
+		FROM users
+		WHERE oauth_provider = $1 AND oauth_sub = $2`
+
+	user := &User{}
+	err := r.db.Pool.QueryRow(ctx, query, provider, sub).Scan(
+		&user.ID, &user.Name, &user.Email, &user.Phone, &user.PasswordHash,
+		&user.OAuthProvider, &user.OAuthSub, &user.Role, &user.RoleVersion, &user.PreferredCurrency, &user.CreatedAt, &user.UpdatedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// LinkOAuth attaches an OAuth identity to a user that has none yet. It returns
+// pgx.ErrNoRows if the user is gone or already linked to an identity.
+func (r *UsersRepository) LinkOAuth(ctx context.Context, userID, provider, sub string) error {
+	query := `
+		UPDATE users
+		SET oauth_provider = $1, oauth_sub = $2, updated_at = now()
+		WHERE id = $3 AND oauth_sub = ''`
+
+	result, err := r.db.Pool.Exec(ctx, query, provider, sub, userID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
 func (r *UsersRepository) UpdatePassword(ctx context.Context, userID, passwordHash string) error {
 	query := `
 		UPDATE users 
@@ -107,13 +152,13 @@ func (r *UsersRepository) UpdatePassword(ctx context.Context, userID, passwordHa
 	return nil
 }
 
-func (r *UsersRepository) UpdateProfile(ctx context.Context, userID, name, phone string) error {
+func (r *UsersRepository) UpdateProfile(ctx context.Context, userID, name, phone, preferredCurrency string) error {
 	query := `
 		UPDATE users 
-		SET name = $1, phone = $2, updated_at = now()
-		WHERE id = $3`
+		SET name = $1, phone = $2, preferred_currency = $3, updated_at = now()
+		WHERE id = $4`
 
-	result, err := r.db.Pool.Exec(ctx, query, name, phone, userID)
+	result, err := r.db.Pool.Exec(ctx, query, name, phone, preferredCurrency, userID)
 	if err != nil {
 		return err
 	}
@@ -128,7 +173,7 @@ func (r *UsersRepository) UpdateProfile(ctx context.Context, userID, name, phone
 func (r *UsersRepository) UpdateRole(ctx context.Context, userID, role string) error {
 	query := `
 		UPDATE users 
-		SET role = $1, updated_at = now()
+		SET role = $1, role_version = role_version + 1, updated_at = now()
 		WHERE id = $2`
 
 	result, err := r.db.Pool.Exec(ctx, query, role, userID)
@@ -143,6 +188,20 @@ func (r *UsersRepository) UpdateRole(ctx context.Context, userID, role string) e
 	return nil
 }
 
+// GetRole returns the user's current role and role version.
+func (r *UsersRepository) GetRole(ctx context.Context, userID string) (string, int, error) {
+	query := `SELECT role, role_version FROM users WHERE id = $1`
+
+	var role string
+	var version int
+	err := r.db.Pool.QueryRow(ctx, query, userID).Scan(&role, &version)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return role, version, nil
+}
+
 func (r *UsersRepository) Delete(ctx context.Context, userID string) error {
 	query := `DELETE FROM users WHERE id = $1`
 
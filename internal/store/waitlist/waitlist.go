@@ -2,13 +2,21 @@ package waitlist
 
 import (
 	"context"
+	"errors"
+	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"go.uber.org/zap"
 
+	"github.com/samirwankhede/lewly-pgpyewj/internal/domain"
 	"github.com/samirwankhede/lewly-pgpyewj/internal/store"
 )
 
+// ErrClosed is returned when joining the waitlist of an event that has started or whose
+// waitlist the status checker has closed.
+var ErrClosed = errors.New("waitlist is closed")
+
 type WaitlistEntry struct {
 	ID         string `json:"id"`
 	EventID    string `json:"event_id"`
@@ -16,7 +24,19 @@ type WaitlistEntry struct {
 	Position   int    `json:"position"`
 	OptedOut   bool   `json:"opted_out"`
 	NotifiedAt string `json:"notified_at,omitempty"`
-	CreatedAt  string `json:"created_at"`
+	// ExpiredAt is set when the waitlist closed with the entry still waiting
+	ExpiredAt string `json:"expired_at,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+// Promotion is the outcome of claiming the head of an event's waitlist for freed seats.
+type Promotion struct {
+	EntryID   string `json:"entry_id,omitempty"`
+	UserID    string `json:"user_id"`
+	Position  int    `json:"position,omitempty"`
+	BookingID string `json:"booking_id"`
+	// Claimed is false when the freed booking had already been promoted by an earlier call.
+	Claimed bool `json:"claimed"`
 }
 
 type WaitlistRepository struct {
@@ -28,13 +48,15 @@ func NewWaitlistRepository(db *store.DB, log *zap.Logger) *WaitlistRepository {
 	return &WaitlistRepository{db: db, log: log}
 }
 
+// Add appends the user to the event's waitlist and returns their position. It returns
+// ErrClosed once the event has started or its waitlist has been closed.
 func (r *WaitlistRepository) Add(ctx context.Context, eventID, userID string) (int, error) {
 	// Get the next position
 	var position int
 	err := r.db.Pool.QueryRow(ctx, `
 		SELECT COALESCE(MAX(position), 0) + 1 
 		FROM waitlist 
-		WHERE event_id = $1 AND opted_out = false
+		WHERE event_id = $1 AND opted_out = false AND expired_at IS NULL
 	`, eventID).Scan(&position)
 	if err != nil {
 		return 0, err
@@ -43,12 +65,17 @@ func (r *WaitlistRepository) Add(ctx context.Context, eventID, userID string) (i
 	// Insert the waitlist entry
 	query := `
 		INSERT INTO waitlist (event_id, user_id, position, opted_out)
-		VALUES ($1, $2, $3, false)
+		SELECT e.id, $2, $3, false
+		FROM events e
+		WHERE e.id = $1 AND e.waitlist_closed_at IS NULL AND e.start_time > now()
 		RETURNING id`
 
 	var id string
 	err = r.db.Pool.QueryRow(ctx, query, eventID, userID, position).Scan(&id)
 	if err != nil {
+		if err == pgx.ErrNoRows {
+			return 0, ErrClosed
+		}
 		return 0, err
 	}
 
@@ -92,7 +119,7 @@ func (r *WaitlistRepository) NextActive(ctx context.Context, eventID string) (st
 	query := `
 		SELECT id, user_id, position 
 		FROM waitlist 
-		WHERE event_id = $1 AND opted_out = false 
+		WHERE event_id = $1 AND opted_out = false AND expired_at IS NULL
 		ORDER BY position ASC 
 		LIMIT 1`
 
@@ -113,7 +140,7 @@ func (r *WaitlistRepository) Count(ctx context.Context, eventID string) (int, er
 	query := `
 		SELECT COUNT(*) 
 		FROM waitlist 
-		WHERE event_id = $1 AND opted_out = false`
+		WHERE event_id = $1 AND opted_out = false AND expired_at IS NULL`
 
 	var count int
 	err := r.db.Pool.QueryRow(ctx, query, eventID).Scan(&count)
@@ -124,15 +151,35 @@ func (r *WaitlistRepository) Count(ctx context.Context, eventID string) (int, er
 	return count, nil
 }
 
-func (r *WaitlistRepository) ListByEvent(ctx context.Context, eventID string, limit, offset int) ([]*WaitlistEntry, error) {
+// ListPosition is where a page of an event's waitlist ends: the position and ID of its last
+// entry.
+type ListPosition struct {
+	Position int    `json:"p"`
+	ID       string `json:"id"`
+}
+
+// Position returns the position just after e in its event's waitlist.
+func Position(e *WaitlistEntry) *ListPosition {
+	return &ListPosition{Position: e.Position, ID: e.ID}
+}
+
+// ListByEvent pages through the event's waitlist in position order. after, if not nil,
+// starts the page past a previous one.
+func (r *WaitlistRepository) ListByEvent(ctx context.Context, eventID string, limit, offset int, after *ListPosition) ([]*WaitlistEntry, error) {
+	var afterPos *int
+	var afterID *string
+	if after != nil {
+		afterPos, afterID = &after.Position, &after.ID
+	}
 	query := `
-		SELECT id, event_id, user_id, position, opted_out, notified_at, created_at
+		SELECT id, event_id, user_id, position, opted_out, notified_at, expired_at, created_at
 		FROM waitlist 
-		WHERE event_id = $1 
-		ORDER BY position ASC
+		WHERE event_id = $1
+		  AND ($4::int IS NULL OR (position, id) > ($4, $5::uuid))
+		ORDER BY position ASC, id ASC
 		LIMIT $2 OFFSET $3`
 
-	rows, err := r.db.Pool.Query(ctx, query, eventID, limit, offset)
+	rows, err := r.db.Pool.Query(ctx, query, eventID, limit, offset, afterPos, afterID)
 	if err != nil {
 		return nil, err
 	}
@@ -141,10 +188,10 @@ func (r *WaitlistRepository) ListByEvent(ctx context.Context, eventID string, li
 	var entries []*WaitlistEntry
 	for rows.Next() {
 		entry := &WaitlistEntry{}
-		var notifiedAt *string
+		var notifiedAt, expiredAt *string
 		err := rows.Scan(
 			&entry.ID, &entry.EventID, &entry.UserID, &entry.Position,
-			&entry.OptedOut, &notifiedAt, &entry.CreatedAt,
+			&entry.OptedOut, &notifiedAt, &expiredAt, &entry.CreatedAt,
 		)
 		if err != nil {
 			return nil, err
@@ -152,6 +199,9 @@ func (r *WaitlistRepository) ListByEvent(ctx context.Context, eventID string, li
 		if notifiedAt != nil {
 			entry.NotifiedAt = *notifiedAt
 		}
+		if expiredAt != nil {
+			entry.ExpiredAt = *expiredAt
+		}
 		entries = append(entries, entry)
 	}
 
@@ -172,3 +222,183 @@ func (r *WaitlistRepository) MarkNotified(ctx context.Context, id string) error
 
 	return nil
 }
+
+// promotionKey is the idempotency key of the booking created for the seats freed by sourceBookingID.
+func promotionKey(sourceBookingID string) string { return "waitlist-promotion:" + sourceBookingID }
+
+// ClaimNext hands the seats freed by sourceBookingID to the head of the event's waitlist: it
+// creates their pending booking, sold in the source booking's sale phase, and removes their
+// entry in one transaction. A per-event
+// advisory lock serializes concurrent promotions, and the booking's idempotency key makes a
+// repeated call for the same source return the earlier promotion with Claimed false.
+// It returns nil if nobody is waiting or the waitlist is closed.
+func (r *WaitlistRepository) ClaimNext(ctx context.Context, eventID, sourceBookingID string, seats domain.Seats) (*Promotion, error) {
+	return r.claim(ctx, eventID, promotionKey(sourceBookingID), &sourceBookingID, seats)
+}
+
+// ClaimSeats is ClaimNext for some of sourceBookingID's seats, given up while it keeps the
+// rest. Each set of seats is promoted once, apart from the source's own promotion when it is
+// cancelled in full.
+func (r *WaitlistRepository) ClaimSeats(ctx context.Context, eventID, sourceBookingID string, seats domain.Seats) (*Promotion, error) {
+	return r.claim(ctx, eventID, promotionKey(sourceBookingID)+":"+strings.Join(seats, ","), &sourceBookingID, seats)
+}
+
+// capacityOfferKey is the idempotency key of the booking offering seat of capacity increase increaseID.
+func capacityOfferKey(increaseID, seat string) string {
+	return "capacity-offer:" + increaseID + ":" + seat
+}
+
+// ClaimCapacityOffer is ClaimNext for seat, added to the event by capacity increase
+// increaseID: a repeated call for the same seat returns the earlier promotion.
+func (r *WaitlistRepository) ClaimCapacityOffer(ctx context.Context, eventID, increaseID, seat string) (*Promotion, error) {
+	return r.claim(ctx, eventID, capacityOfferKey(increaseID, seat), nil, domain.Seats{seat})
+}
+
+func (r *WaitlistRepository) claim(ctx context.Context, eventID, key string, sourceBookingID *string, seats domain.Seats) (*Promotion, error) {
+	var p *Promotion
+	err := r.db.WithTx(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('waitlist:' || $1))`, eventID); err != nil {
+			return err
+		}
+
+		var bookingID, userID string
+		err := tx.QueryRow(ctx, `
+			SELECT id, user_id FROM bookings WHERE event_id = $1 AND idempotency_key = $2
+		`, eventID, key).Scan(&bookingID, &userID)
+		if err == nil {
+			p = &Promotion{UserID: userID, BookingID: bookingID}
+			return nil
+		}
+		if err != pgx.ErrNoRows {
+			return err
+		}
+
+		// Nobody is promoted into an event that has started, even before the status checker
+		// gets round to closing its waitlist
+		var open bool
+		err = tx.QueryRow(ctx, `
+			SELECT waitlist_closed_at IS NULL AND start_time > now() FROM events WHERE id = $1
+		`, eventID).Scan(&open)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				return nil
+			}
+			return err
+		}
+		if !open {
+			return nil
+		}
+
+		next := &Promotion{Claimed: true}
+		err = tx.QueryRow(ctx, `
+			SELECT id, user_id, position
+			FROM waitlist
+			WHERE event_id = $1 AND opted_out = false AND expired_at IS NULL
+			ORDER BY position ASC
+			LIMIT 1
+			FOR UPDATE
+		`, eventID).Scan(&next.EntryID, &next.UserID, &next.Position)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				return nil
+			}
+			return err
+		}
+
+		err = tx.QueryRow(ctx, `
+			INSERT INTO bookings (user_id, event_id, status, idempotency_key, payment_status, seats, source, sale_phase_id)
+			VALUES ($1, $2, 'pending', $3, 'pending', $4, 'waitlist',
+			        (SELECT sale_phase_id FROM bookings WHERE event_id = $2 AND id = $5::uuid))
+			RETURNING id
+		`, next.UserID, eventID, key, seats, sourceBookingID).Scan(&next.BookingID)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(ctx, `DELETE FROM waitlist WHERE event_id = $1 AND id = $2`, eventID, next.EntryID); err != nil {
+			return err
+		}
+		p = next
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ClosedWaitlist is an event whose waitlist CloseDue closed, with the entries it expired.
+type ClosedWaitlist struct {
+	EventID string
+	Expired []*WaitlistEntry
+}
+
+// CloseDue closes the waitlists of up to limit events starting at or before cutoff and
+// marks the entries still waiting on them expired. Events locked by another checker are
+// skipped, so checkers can run side by side. It returns what it closed.
+func (r *WaitlistRepository) CloseDue(ctx context.Context, cutoff time.Time, limit int) ([]*ClosedWaitlist, error) {
+	var closed []*ClosedWaitlist
+	err := r.db.WithTx(ctx, func(tx pgx.Tx) error {
+		closed = nil
+		rows, err := tx.Query(ctx, `
+			UPDATE events SET waitlist_closed_at = now()
+			WHERE id IN (
+				SELECT id FROM events
+				WHERE waitlist_closed_at IS NULL AND start_time <= $1
+				ORDER BY start_time
+				LIMIT $2
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING id
+		`, cutoff, limit)
+		if err != nil {
+			return err
+		}
+		byEvent := map[string]*ClosedWaitlist{}
+		var ids []string
+		for rows.Next() {
+			c := &ClosedWaitlist{}
+			if err := rows.Scan(&c.EventID); err != nil {
+				rows.Close()
+				return err
+			}
+			byEvent[c.EventID] = c
+			ids = append(ids, c.EventID)
+			closed = append(closed, c)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		rows, err = tx.Query(ctx, `
+			UPDATE waitlist SET expired_at = now()
+			WHERE event_id = ANY($1::uuid[]) AND opted_out = false AND expired_at IS NULL
+			RETURNING id, event_id, user_id, position, opted_out, expired_at::text, created_at::text
+		`, ids)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			entry := &WaitlistEntry{}
+			err := rows.Scan(
+				&entry.ID, &entry.EventID, &entry.UserID, &entry.Position,
+				&entry.OptedOut, &entry.ExpiredAt, &entry.CreatedAt,
+			)
+			if err != nil {
+				return err
+			}
+			c := byEvent[entry.EventID]
+			c.Expired = append(c.Expired, entry)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return closed, nil
+}
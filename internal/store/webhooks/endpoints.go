@@ -0,0 +1,315 @@
+package webhooks
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Endpoint is a URL an admin has registered for a third-party integration, POSTed
+// platform-wide booking and event changes. An empty EventTypes means every type. Secret signs
+// the deliveries and is only shown on creation.
+type Endpoint struct {
+	ID                  string    `json:"id"`
+	URL                 string    `json:"url"`
+	Secret              string    `json:"-"`
+	Description         string    `json:"description"`
+	EventTypes          []string  `json:"event_types"`
+	Active              bool      `json:"active"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	DisabledReason      *string   `json:"disabled_reason,omitempty"`
+	CreatedBy           *string   `json:"created_by,omitempty"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+// EndpointDelivery is one change queued for an endpoint. BookingID is set for booking types.
+// URL and Secret are its endpoint's, filled in by ClaimDueEndpoints.
+type EndpointDelivery struct {
+	ID             string     `json:"id"`
+	EndpointID     string     `json:"endpoint_id"`
+	EventType      string     `json:"event_type"`
+	BookingID      *string    `json:"booking_id,omitempty"`
+	EventID        string     `json:"event_id"`
+	Payload        []byte     `json:"-"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at"`
+	ResponseStatus *int       `json:"response_status,omitempty"`
+	LastError      *string    `json:"last_error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+	URL            string     `json:"-"`
+	Secret         string     `json:"-"`
+}
+
+const endpointColumns = `id, url, secret, description, event_types, active, consecutive_failures, disabled_reason, created_by, created_at, updated_at`
+
+func scanEndpoint(row pgx.Row) (*Endpoint, error) {
+	e := &Endpoint{}
+	err := row.Scan(&e.ID, &e.URL, &e.Secret, &e.Description, &e.EventTypes, &e.Active, &e.ConsecutiveFailures, &e.DisabledReason, &e.CreatedBy, &e.CreatedAt, &e.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+const endpointDeliveryColumns = `id, endpoint_id, event_type, booking_id, event_id, payload, status, attempts, next_attempt_at, response_status, last_error, created_at, delivered_at`
+
+// scanEndpointDelivery scans endpointDeliveryColumns, followed by the endpoint's url and
+// secret when withEndpoint is set.
+func scanEndpointDelivery(row pgx.Row, withEndpoint bool) (*EndpointDelivery, error) {
+	d := &EndpointDelivery{}
+	dest := []any{&d.ID, &d.EndpointID, &d.EventType, &d.BookingID, &d.EventID, &d.Payload, &d.Status, &d.Attempts, &d.NextAttemptAt, &d.ResponseStatus, &d.LastError, &d.CreatedAt, &d.DeliveredAt}
+	if withEndpoint {
+		dest = append(dest, &d.URL, &d.Secret)
+	}
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// CreateEndpoint stores a new endpoint.
+func (r *WebhooksRepository) CreateEndpoint(ctx context.Context, e *Endpoint) (*Endpoint, error) {
+	return scanEndpoint(r.db.Pool.QueryRow(ctx, `
+		INSERT INTO webhook_endpoints (url, secret, description, event_types, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+endpointColumns,
+		e.URL, e.Secret, e.Description, e.EventTypes, e.CreatedBy))
+}
+
+// ListEndpoints returns every endpoint, oldest first.
+func (r *WebhooksRepository) ListEndpoints(ctx context.Context) ([]*Endpoint, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT `+endpointColumns+`
+		FROM webhook_endpoints
+		ORDER BY created_at
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []*Endpoint{}
+	for rows.Next() {
+		e, err := scanEndpoint(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// GetEndpoint returns the endpoint, or nil if there is none with that ID.
+func (r *WebhooksRepository) GetEndpoint(ctx context.Context, id string) (*Endpoint, error) {
+	e, err := scanEndpoint(r.db.Pool.QueryRow(ctx, `
+		SELECT `+endpointColumns+`
+		FROM webhook_endpoints
+		WHERE id = $1
+	`, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return e, nil
+}
+
+// DeleteEndpoint removes the endpoint and its deliveries, reporting whether it existed.
+func (r *WebhooksRepository) DeleteEndpoint(ctx context.Context, id string) (bool, error) {
+	tag, err := r.db.Pool.Exec(ctx, `DELETE FROM webhook_endpoints WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// EnableEndpoint turns a disabled endpoint back on with a clean failure count. Deliveries
+// queued before it was disabled are sent again.
+func (r *WebhooksRepository) EnableEndpoint(ctx context.Context, id string) (*Endpoint, error) {
+	e, err := scanEndpoint(r.db.Pool.QueryRow(ctx, `
+		UPDATE webhook_endpoints
+		SET active = true, consecutive_failures = 0, disabled_reason = NULL, updated_at = now()
+		WHERE id = $1
+		RETURNING `+endpointColumns,
+		id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return e, nil
+}
+
+// EnqueueBookingForEndpoints queues eventType for every active endpoint subscribed to it,
+// returning how many deliveries were queued. The payload describes the booking, its holder
+// and its event as they are now.
+func (r *WebhooksRepository) EnqueueBookingForEndpoints(ctx context.Context, bookingID, eventType string, at time.Time) (int, error) {
+	tag, err := r.db.Pool.Exec(ctx, `
+		INSERT INTO webhook_endpoint_deliveries (endpoint_id, event_type, booking_id, event_id, payload)
+		SELECT w.id, $2, b.id, e.id, jsonb_build_object(
+			'type', $2::text,
+			'occurred_at', $3::timestamptz,
+			'booking', jsonb_build_object(
+				'id', b.id, 'status', b.status, 'payment_status', b.payment_status, 'seats', b.seats,
+				'amount_due', b.amount_due, 'amount_paid', b.amount_paid, 'currency', b.currency,
+				'source', b.source, 'created_at', b.created_at),
+			'user', jsonb_build_object('id', u.id, 'name', u.name, 'email', u.email),
+			'event', jsonb_build_object(
+				'id', e.id, 'name', e.name, 'venue', e.venue, 'start_time', e.start_time, 'end_time', e.end_time))
+		FROM bookings b
+		JOIN events e ON e.id = b.event_id
+		JOIN users u ON u.id = b.user_id
+		JOIN webhook_endpoints w ON w.active
+		WHERE b.id = $1
+		  AND (cardinality(w.event_types) = 0 OR $2 = ANY(w.event_types))
+	`, bookingID, eventType, at)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// EnqueueEventForEndpoints queues eventType about the event for every active endpoint
+// subscribed to it, returning how many deliveries were queued.
+func (r *WebhooksRepository) EnqueueEventForEndpoints(ctx context.Context, eventID, eventType string, at time.Time) (int, error) {
+	tag, err := r.db.Pool.Exec(ctx, `
+		INSERT INTO webhook_endpoint_deliveries (endpoint_id, event_type, event_id, payload)
+		SELECT w.id, $2, e.id, jsonb_build_object(
+			'type', $2::text,
+			'occurred_at', $3::timestamptz,
+			'event', jsonb_build_object(
+				'id', e.id, 'name', e.name, 'venue', e.venue, 'start_time', e.start_time, 'end_time', e.end_time,
+				'status', e.status))
+		FROM events e
+		JOIN webhook_endpoints w ON w.active
+		WHERE e.id = $1
+		  AND (cardinality(w.event_types) = 0 OR $2 = ANY(w.event_types))
+	`, eventID, eventType, at)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// ListEndpointDeliveries returns the endpoint's most recent deliveries, newest first.
+func (r *WebhooksRepository) ListEndpointDeliveries(ctx context.Context, endpointID string, limit int) ([]*EndpointDelivery, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT `+endpointDeliveryColumns+`
+		FROM webhook_endpoint_deliveries
+		WHERE endpoint_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`, endpointID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []*EndpointDelivery{}
+	for rows.Next() {
+		d, err := scanEndpointDelivery(rows, false)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// ClaimDueEndpoints is ClaimDue for endpoint deliveries.
+func (r *WebhooksRepository) ClaimDueEndpoints(ctx context.Context, limit int, lease time.Duration) ([]*EndpointDelivery, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		UPDATE webhook_endpoint_deliveries d
+		SET status = 'sending', claimed_at = now(), attempts = d.attempts + 1
+		FROM webhook_endpoints w
+		WHERE w.id = d.endpoint_id AND d.id IN (
+			SELECT dd.id FROM webhook_endpoint_deliveries dd
+			JOIN webhook_endpoints ww ON ww.id = dd.endpoint_id AND ww.active
+			WHERE (dd.status = 'pending' AND dd.next_attempt_at <= now())
+			   OR (dd.status = 'sending' AND dd.claimed_at < now() - make_interval(secs => $2))
+			ORDER BY dd.next_attempt_at
+			LIMIT $1
+			FOR UPDATE OF dd SKIP LOCKED
+		)
+		RETURNING d.id, d.endpoint_id, d.event_type, d.booking_id, d.event_id, d.payload, d.status, d.attempts, d.next_attempt_at,
+		          d.response_status, d.last_error, d.created_at, d.delivered_at, w.url, w.secret
+	`, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []*EndpointDelivery{}
+	for rows.Next() {
+		d, err := scanEndpointDelivery(rows, true)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// EndpointDelivered records a successful delivery and clears its endpoint's failure count.
+func (r *WebhooksRepository) EndpointDelivered(ctx context.Context, d *EndpointDelivery, status int) error {
+	return r.db.WithTx(ctx, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			UPDATE webhook_endpoint_deliveries
+			SET status = 'delivered', response_status = $2, last_error = NULL, claimed_at = NULL, delivered_at = now()
+			WHERE id = $1
+		`, d.ID, status)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			UPDATE webhook_endpoints SET consecutive_failures = 0, updated_at = now()
+			WHERE id = $1 AND consecutive_failures > 0
+		`, d.EndpointID)
+		return err
+	})
+}
+
+// RetryEndpoint puts a failed attempt back in the queue for next. status is nil when no
+// response came back.
+func (r *WebhooksRepository) RetryEndpoint(ctx context.Context, id string, status *int, errMsg string, next time.Time) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		UPDATE webhook_endpoint_deliveries
+		SET status = 'pending', response_status = $2, last_error = $3, claimed_at = NULL, next_attempt_at = $4
+		WHERE id = $1
+	`, id, status, errMsg, next)
+	return err
+}
+
+// EndpointFailed gives up on a delivery and counts it against its endpoint, disabling the
+// endpoint once disableAfter deliveries in a row have failed. It reports whether this
+// disabled it.
+func (r *WebhooksRepository) EndpointFailed(ctx context.Context, d *EndpointDelivery, status *int, errMsg string, disableAfter int) (bool, error) {
+	disabled := false
+	err := r.db.WithTx(ctx, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			UPDATE webhook_endpoint_deliveries
+			SET status = 'failed', response_status = $2, last_error = $3, claimed_at = NULL
+			WHERE id = $1
+		`, d.ID, status, errMsg)
+		if err != nil {
+			return err
+		}
+		return tx.QueryRow(ctx, `
+			UPDATE webhook_endpoints
+			SET consecutive_failures = consecutive_failures + 1,
+			    active = active AND consecutive_failures + 1 < $2::int,
+			    disabled_reason = CASE WHEN active AND consecutive_failures + 1 >= $2::int
+			                           THEN 'disabled after ' || $2::int || ' failed deliveries in a row' ELSE disabled_reason END,
+			    updated_at = now()
+			WHERE id = $1
+			RETURNING NOT active AND consecutive_failures = $2::int
+		`, d.EndpointID, disableAfter).Scan(&disabled)
+	})
+	return disabled, err
+}
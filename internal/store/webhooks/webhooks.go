@@ -0,0 +1,310 @@
+package webhooks
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store"
+)
+
+// Delivery statuses.
+const (
+	DeliveryPending   = "pending"
+	DeliverySending   = "sending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook is a URL a user has asked to be POSTed their bookings' status changes. An empty
+// EventTypes means every type. Secret signs the deliveries and is only shown on creation.
+type Webhook struct {
+	ID                  string    `json:"id"`
+	UserID              string    `json:"user_id"`
+	URL                 string    `json:"url"`
+	Secret              string    `json:"-"`
+	EventTypes          []string  `json:"event_types"`
+	Active              bool      `json:"active"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	DisabledReason      *string   `json:"disabled_reason,omitempty"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+// Delivery is one status change queued for a webhook. URL and Secret are its webhook's,
+// filled in by ClaimDue.
+type Delivery struct {
+	ID             string     `json:"id"`
+	WebhookID      string     `json:"webhook_id"`
+	BookingID      string     `json:"booking_id"`
+	EventType      string     `json:"event_type"`
+	Payload        []byte     `json:"-"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at"`
+	ResponseStatus *int       `json:"response_status,omitempty"`
+	LastError      *string    `json:"last_error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+	URL            string     `json:"-"`
+	Secret         string     `json:"-"`
+}
+
+type WebhooksRepository struct {
+	db  *store.DB
+	log *zap.Logger
+}
+
+func NewWebhooksRepository(db *store.DB, log *zap.Logger) *WebhooksRepository {
+	return &WebhooksRepository{db: db, log: log}
+}
+
+const webhookColumns = `id, user_id, url, secret, event_types, active, consecutive_failures, disabled_reason, created_at, updated_at`
+
+func scanWebhook(row pgx.Row) (*Webhook, error) {
+	w := &Webhook{}
+	err := row.Scan(&w.ID, &w.UserID, &w.URL, &w.Secret, &w.EventTypes, &w.Active, &w.ConsecutiveFailures, &w.DisabledReason, &w.CreatedAt, &w.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+const deliveryColumns = `id, webhook_id, booking_id, event_type, payload, status, attempts, next_attempt_at, response_status, last_error, created_at, delivered_at`
+
+// scanDelivery scans deliveryColumns, followed by the webhook's url and secret when
+// withWebhook is set.
+func scanDelivery(row pgx.Row, withWebhook bool) (*Delivery, error) {
+	d := &Delivery{}
+	dest := []any{&d.ID, &d.WebhookID, &d.BookingID, &d.EventType, &d.Payload, &d.Status, &d.Attempts, &d.NextAttemptAt, &d.ResponseStatus, &d.LastError, &d.CreatedAt, &d.DeliveredAt}
+	if withWebhook {
+		dest = append(dest, &d.URL, &d.Secret)
+	}
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Create stores a new webhook.
+func (r *WebhooksRepository) Create(ctx context.Context, w *Webhook) (*Webhook, error) {
+	return scanWebhook(r.db.Pool.QueryRow(ctx, `
+		INSERT INTO user_webhooks (user_id, url, secret, event_types)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+webhookColumns,
+		w.UserID, w.URL, w.Secret, w.EventTypes))
+}
+
+// ListByUser returns the user's webhooks, oldest first.
+func (r *WebhooksRepository) ListByUser(ctx context.Context, userID string) ([]*Webhook, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT `+webhookColumns+`
+		FROM user_webhooks
+		WHERE user_id = $1
+		ORDER BY created_at
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []*Webhook{}
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, w)
+	}
+	return out, rows.Err()
+}
+
+// Get returns the user's webhook, or nil if the user has none with that ID.
+func (r *WebhooksRepository) Get(ctx context.Context, userID, id string) (*Webhook, error) {
+	w, err := scanWebhook(r.db.Pool.QueryRow(ctx, `
+		SELECT `+webhookColumns+`
+		FROM user_webhooks
+		WHERE user_id = $1 AND id = $2
+	`, userID, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return w, nil
+}
+
+// Delete removes the user's webhook and its deliveries, reporting whether it existed.
+func (r *WebhooksRepository) Delete(ctx context.Context, userID, id string) (bool, error) {
+	tag, err := r.db.Pool.Exec(ctx, `DELETE FROM user_webhooks WHERE user_id = $1 AND id = $2`, userID, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// Enable turns a disabled webhook back on with a clean failure count. Deliveries queued
+// before it was disabled are sent again.
+func (r *WebhooksRepository) Enable(ctx context.Context, userID, id string) (*Webhook, error) {
+	w, err := scanWebhook(r.db.Pool.QueryRow(ctx, `
+		UPDATE user_webhooks
+		SET active = true, consecutive_failures = 0, disabled_reason = NULL, updated_at = now()
+		WHERE user_id = $1 AND id = $2
+		RETURNING `+webhookColumns,
+		userID, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return w, nil
+}
+
+// Enqueue queues eventType for every active webhook of the booking's owner subscribed to it,
+// returning how many deliveries were queued. The payload describes the booking and its event
+// as they are now.
+func (r *WebhooksRepository) Enqueue(ctx context.Context, bookingID, eventType, status string, at time.Time, expiresAt *time.Time) (int, error) {
+	tag, err := r.db.Pool.Exec(ctx, `
+		INSERT INTO user_webhook_deliveries (webhook_id, booking_id, event_type, payload)
+		SELECT w.id, b.id, $2, jsonb_build_object(
+			'type', $2::text,
+			'occurred_at', $4::timestamptz,
+			'booking', jsonb_build_object(
+				'id', b.id, 'status', $3::text, 'payment_status', b.payment_status, 'seats', b.seats,
+				'amount_due', b.amount_due, 'amount_paid', b.amount_paid, 'currency', b.currency,
+				'expires_at', $5::timestamptz),
+			'event', jsonb_build_object(
+				'id', e.id, 'name', e.name, 'venue', e.venue, 'start_time', e.start_time, 'end_time', e.end_time))
+		FROM bookings b
+		JOIN events e ON e.id = b.event_id
+		JOIN user_webhooks w ON w.user_id = b.user_id
+		WHERE b.id = $1 AND w.active
+		  AND (cardinality(w.event_types) = 0 OR $2 = ANY(w.event_types))
+	`, bookingID, eventType, status, at, expiresAt)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// ListDeliveries returns the webhook's most recent deliveries, newest first.
+func (r *WebhooksRepository) ListDeliveries(ctx context.Context, webhookID string, limit int) ([]*Delivery, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT `+deliveryColumns+`
+		FROM user_webhook_deliveries
+		WHERE webhook_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []*Delivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows, false)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// ClaimDue takes up to limit deliveries of active webhooks that are due, oldest first, along
+// with their webhook's URL and secret. Deliveries claimed longer ago than lease by a sender
+// that never finished are due again. Concurrent claims never overlap.
+func (r *WebhooksRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]*Delivery, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		UPDATE user_webhook_deliveries d
+		SET status = 'sending', claimed_at = now(), attempts = d.attempts + 1
+		FROM user_webhooks w
+		WHERE w.id = d.webhook_id AND d.id IN (
+			SELECT dd.id FROM user_webhook_deliveries dd
+			JOIN user_webhooks ww ON ww.id = dd.webhook_id AND ww.active
+			WHERE (dd.status = 'pending' AND dd.next_attempt_at <= now())
+			   OR (dd.status = 'sending' AND dd.claimed_at < now() - make_interval(secs => $2))
+			ORDER BY dd.next_attempt_at
+			LIMIT $1
+			FOR UPDATE OF dd SKIP LOCKED
+		)
+		RETURNING d.id, d.webhook_id, d.booking_id, d.event_type, d.payload, d.status, d.attempts, d.next_attempt_at,
+		          d.response_status, d.last_error, d.created_at, d.delivered_at, w.url, w.secret
+	`, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []*Delivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows, true)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// Delivered records a successful delivery and clears its webhook's failure count.
+func (r *WebhooksRepository) Delivered(ctx context.Context, d *Delivery, status int) error {
+	return r.db.WithTx(ctx, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			UPDATE user_webhook_deliveries
+			SET status = 'delivered', response_status = $2, last_error = NULL, claimed_at = NULL, delivered_at = now()
+			WHERE id = $1
+		`, d.ID, status)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			UPDATE user_webhooks SET consecutive_failures = 0, updated_at = now()
+			WHERE id = $1 AND consecutive_failures > 0
+		`, d.WebhookID)
+		return err
+	})
+}
+
+// Retry puts a failed attempt back in the queue for next. status is nil when no response
+// came back.
+func (r *WebhooksRepository) Retry(ctx context.Context, id string, status *int, errMsg string, next time.Time) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		UPDATE user_webhook_deliveries
+		SET status = 'pending', response_status = $2, last_error = $3, claimed_at = NULL, next_attempt_at = $4
+		WHERE id = $1
+	`, id, status, errMsg, next)
+	return err
+}
+
+// Failed gives up on a delivery and counts it against its webhook, disabling the webhook
+// once disableAfter deliveries in a row have failed. It reports whether this disabled it.
+func (r *WebhooksRepository) Failed(ctx context.Context, d *Delivery, status *int, errMsg string, disableAfter int) (bool, error) {
+	disabled := false
+	err := r.db.WithTx(ctx, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			UPDATE user_webhook_deliveries
+			SET status = 'failed', response_status = $2, last_error = $3, claimed_at = NULL
+			WHERE id = $1
+		`, d.ID, status, errMsg)
+		if err != nil {
+			return err
+		}
+		return tx.QueryRow(ctx, `
+			UPDATE user_webhooks
+			SET consecutive_failures = consecutive_failures + 1,
+			    active = active AND consecutive_failures + 1 < $2::int,
+			    disabled_reason = CASE WHEN active AND consecutive_failures + 1 >= $2::int
+			                           THEN 'disabled after ' || $2::int || ' failed deliveries in a row' ELSE disabled_reason END,
+			    updated_at = now()
+			WHERE id = $1
+			RETURNING NOT active AND consecutive_failures = $2::int
+		`, d.WebhookID, disableAfter).Scan(&disabled)
+	})
+	return disabled, err
+}
@@ -0,0 +1,171 @@
+// Package tracing sets up OpenTelemetry tracing and the helpers the rest of the code uses to
+// make spans. Spans are exported over OTLP/HTTP when an endpoint is configured; without one
+// the global tracer provider stays the no-op default and every helper costs next to nothing.
+//
+// A booking is traced from its HTTP request, through the outbox row and the Kafka message
+// (both carry the W3C trace context), to the worker that finalizes it. Postgres queries and
+// Redis commands become child spans of whatever is being traced, never root spans of their
+// own, so background pollers don't flood the exporter.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// instrumentationName names the tracer every span comes from.
+const instrumentationName = "github.com/samirwankhede/lewly-pgpyewj"
+
+// Options configures Setup.
+type Options struct {
+	// ServiceName is reported as service.name, e.g. evently-api
+	ServiceName string
+	// Endpoint is the OTLP/HTTP collector base URL, e.g. http://otel-collector:4318; empty
+	// disables export
+	Endpoint string
+	// Headers are sent with every export, e.g. an API key for a hosted backend
+	Headers map[string]string
+	// SamplePercent of new traces is kept; traces started upstream follow the caller's choice
+	SamplePercent int
+	Environment   string
+}
+
+// Setup installs the tracer provider and W3C trace context propagation. The returned
+// function flushes and stops the exporter; call it on shutdown. With no endpoint only the
+// propagator is installed, so trace context is still passed along to services that export.
+func Setup(ctx context.Context, log *zap.Logger, opts Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if opts.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	u, err := url.Parse(opts.Endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("tracing: invalid OTLP endpoint %q", opts.Endpoint)
+	}
+	exporterOpts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(u.Host),
+		otlptracehttp.WithURLPath(strings.TrimRight(u.Path, "/") + "/v1/traces"),
+	}
+	if u.Scheme == "http" {
+		exporterOpts = append(exporterOpts, otlptracehttp.WithInsecure())
+	}
+	if len(opts.Headers) > 0 {
+		exporterOpts = append(exporterOpts, otlptracehttp.WithHeaders(opts.Headers))
+	}
+	exporter, err := otlptracehttp.New(ctx, exporterOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(opts.ServiceName),
+		semconv.DeploymentEnvironment(opts.Environment),
+	))
+	if err != nil {
+		return nil, err
+	}
+	percent := min(max(opts.SamplePercent, 0), 100)
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(float64(percent)/100))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		log.Warn("Tracing error", zap.Error(err))
+	}))
+	log.Info("Tracing enabled", zap.String("endpoint", opts.Endpoint), zap.Int("sample_percent", percent))
+	return provider.Shutdown, nil
+}
+
+// ParseHeaders parses comma-separated key=value pairs, the OTEL_EXPORTER_OTLP_HEADERS format.
+func ParseHeaders(s string) map[string]string {
+	headers := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if k = strings.TrimSpace(k); ok && k != "" {
+			headers[k] = strings.TrimSpace(v)
+		}
+	}
+	return headers
+}
+
+// Start starts a span, a root span if ctx carries none.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// StartKind is Start for a span of the given kind, e.g. a server or a consumer.
+func StartKind(ctx context.Context, kind trace.SpanKind, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithSpanKind(kind), trace.WithAttributes(attrs...))
+}
+
+// Child starts a client span only when ctx is already being traced; otherwise it returns
+// ctx and a span that records nothing.
+func Child(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	parent := trace.SpanFromContext(ctx)
+	if !parent.SpanContext().IsValid() {
+		return ctx, parent
+	}
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+// End ends span, marking it failed with err unless err is nil or one of ignore.
+func End(span trace.Span, err error, ignore ...error) {
+	if err != nil {
+		failed := true
+		for _, e := range ignore {
+			if errors.Is(err, e) {
+				failed = false
+			}
+		}
+		if failed {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}
+	span.End()
+}
+
+// TraceID returns the trace ID of ctx's span, or "" when it isn't traced.
+func TraceID(ctx context.Context) string {
+	sc := trace.SpanFromContext(ctx).SpanContext()
+	if !sc.IsValid() {
+		return ""
+	}
+	return sc.TraceID().String()
+}
+
+// Inject returns ctx's trace context as a map, for carrying it through storage; nil when
+// there is nothing to carry.
+func Inject(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+	return carrier
+}
+
+// Extract returns ctx continuing the trace context Inject produced.
+func Extract(ctx context.Context, carrier map[string]string) context.Context {
+	if len(carrier) == 0 {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(carrier))
+}
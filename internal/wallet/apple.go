@@ -0,0 +1,213 @@
+package wallet
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"time"
+)
+
+// AppleConfig names the pass type and the files its passes are signed with. Cert is the
+// pass type certificate Apple issued for PassTypeID and Key its private key; WWDRCert is
+// Apple's intermediate certificate. All are PEM files. Icon is an optional PNG shown on
+// lock screen notifications; a plain square is used without one.
+type AppleConfig struct {
+	PassTypeID   string
+	TeamID       string
+	Organization string
+	Cert         string
+	Key          string
+	WWDRCert     string
+	Icon         string
+}
+
+// ApplePasses builds signed .pkpass bundles.
+type ApplePasses struct {
+	cfg   AppleConfig
+	cert  *x509.Certificate
+	wwdr  *x509.Certificate
+	key   crypto.Signer
+	icons map[string][]byte
+}
+
+// NewApplePasses loads the certificates and key named in cfg.
+func NewApplePasses(cfg AppleConfig) (*ApplePasses, error) {
+	cert, err := loadCertificate(cfg.Cert)
+	if err != nil {
+		return nil, err
+	}
+	wwdr, err := loadCertificate(cfg.WWDRCert)
+	if err != nil {
+		return nil, err
+	}
+	key, err := loadKey(cfg.Key)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+
+	p := &ApplePasses{cfg: cfg, cert: cert, wwdr: wwdr, key: signer, icons: make(map[string][]byte)}
+	if cfg.Icon != "" {
+		icon, err := os.ReadFile(cfg.Icon)
+		if err != nil {
+			return nil, err
+		}
+		p.icons["icon.png"] = icon
+		p.icons["icon@2x.png"] = icon
+	} else {
+		// Wallet refuses passes without an icon
+		for name, size := range map[string]int{"icon.png": 29, "icon@2x.png": 58} {
+			icon, err := plainIcon(size)
+			if err != nil {
+				return nil, err
+			}
+			p.icons[name] = icon
+		}
+	}
+	return p, nil
+}
+
+type passField struct {
+	Key       string `json:"key"`
+	Label     string `json:"label"`
+	Value     string `json:"value"`
+	DateStyle string `json:"dateStyle,omitempty"`
+	TimeStyle string `json:"timeStyle,omitempty"`
+}
+
+type passBarcode struct {
+	Format          string `json:"format"`
+	Message         string `json:"message"`
+	MessageEncoding string `json:"messageEncoding"`
+	AltText         string `json:"altText,omitempty"`
+}
+
+type passLocation struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type passStructure struct {
+	PrimaryFields   []passField `json:"primaryFields"`
+	SecondaryFields []passField `json:"secondaryFields"`
+	AuxiliaryFields []passField `json:"auxiliaryFields"`
+	BackFields      []passField `json:"backFields"`
+}
+
+type passJSON struct {
+	FormatVersion      int            `json:"formatVersion"`
+	PassTypeIdentifier string         `json:"passTypeIdentifier"`
+	SerialNumber       string         `json:"serialNumber"`
+	TeamIdentifier     string         `json:"teamIdentifier"`
+	OrganizationName   string         `json:"organizationName"`
+	Description        string         `json:"description"`
+	RelevantDate       string         `json:"relevantDate"`
+	ExpirationDate     string         `json:"expirationDate,omitempty"`
+	Barcodes           []passBarcode  `json:"barcodes"`
+	Barcode            passBarcode    `json:"barcode"`
+	Locations          []passLocation `json:"locations,omitempty"`
+	EventTicket        passStructure  `json:"eventTicket"`
+}
+
+// Pass returns the ticket's .pkpass bundle, served as application/vnd.apple.pkpass.
+func (p *ApplePasses) Pass(t *Ticket) ([]byte, error) {
+	barcode := passBarcode{Format: "PKBarcodeFormatQR", Message: t.Barcode, MessageEncoding: "iso-8859-1", AltText: t.Serial}
+	pass := passJSON{
+		FormatVersion:      1,
+		PassTypeIdentifier: p.cfg.PassTypeID,
+		SerialNumber:       t.Serial,
+		TeamIdentifier:     p.cfg.TeamID,
+		OrganizationName:   p.cfg.Organization,
+		Description:        "Ticket for " + t.EventName,
+		RelevantDate:       t.StartTime.Format(time.RFC3339),
+		Barcodes:           []passBarcode{barcode},
+		// iOS 8 and earlier only read the single barcode
+		Barcode: barcode,
+		EventTicket: passStructure{
+			PrimaryFields: []passField{{Key: "event", Label: "EVENT", Value: t.EventName}},
+			SecondaryFields: []passField{
+				{Key: "venue", Label: "VENUE", Value: t.Venue},
+				{Key: "starts", Label: "STARTS", Value: t.StartTime.Format(time.RFC3339), DateStyle: "PKDateStyleMedium", TimeStyle: "PKDateStyleShort"},
+			},
+			AuxiliaryFields: []passField{{Key: "seats", Label: "SEATS", Value: t.seatsText()}},
+			BackFields: []passField{
+				{Key: "holder", Label: "Ticket holder", Value: t.HolderName},
+				{Key: "booking", Label: "Booking", Value: t.Serial},
+			},
+		},
+	}
+	if !t.EndTime.IsZero() {
+		pass.ExpirationDate = t.EndTime.Format(time.RFC3339)
+	}
+	if t.Latitude != nil && t.Longitude != nil {
+		pass.Locations = []passLocation{{Latitude: *t.Latitude, Longitude: *t.Longitude}}
+	}
+	passData, err := json.Marshal(pass)
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string][]byte{"pass.json": passData}
+	for name, icon := range p.icons {
+		files[name] = icon
+	}
+	// The manifest lists every file's SHA-1; the signature covers the manifest
+	manifest := make(map[string]string, len(files))
+	for name, data := range files {
+		sum := sha1.Sum(data)
+		manifest[name] = hex.EncodeToString(sum[:])
+	}
+	manifestData, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := signDetached(manifestData, p.cert, []*x509.Certificate{p.wwdr}, p.key, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	files["manifest.json"] = manifestData
+	files["signature"] = signature
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range []string{"pass.json", "icon.png", "icon@2x.png", "manifest.json", "signature"} {
+		w, err := zw.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(files[name]); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// plainIcon draws a solid size x size square.
+func plainIcon(size int) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	fill := color.RGBA{R: 0x4f, G: 0x46, B: 0xe5, A: 0xff}
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			img.Set(x, y, fill)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
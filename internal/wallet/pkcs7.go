@@ -0,0 +1,162 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// The detached PKCS#7 (CMS) signature a pass manifest needs is small enough to encode here
+// rather than take on a dependency for: one signer, SHA-256, and the signing certificate
+// plus its intermediates.
+var (
+	oidData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSigningTime   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+	oidSHA256        = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidRSA           = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidECDSASHA256   = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+)
+
+type algorithmIdentifier struct {
+	Algorithm  asn1.ObjectIdentifier
+	Parameters asn1.RawValue `asn1:"optional"`
+}
+
+type issuerAndSerial struct {
+	Issuer asn1.RawValue
+	Serial *big.Int
+}
+
+type signerInfo struct {
+	Version            int
+	SID                issuerAndSerial
+	DigestAlgorithm    algorithmIdentifier
+	SignedAttrs        asn1.RawValue
+	SignatureAlgorithm algorithmIdentifier
+	Signature          []byte
+}
+
+type encapContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	EncapContentInfo encapContentInfo
+	Certificates     asn1.RawValue
+	SignerInfos      asn1.RawValue
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue
+}
+
+type attribute struct {
+	Type  asn1.ObjectIdentifier
+	Value asn1.RawValue
+}
+
+// derSet encodes elems, each already DER, as a SET OF, sorted as DER requires.
+func derSet(elems ...[]byte) []byte {
+	sort.Slice(elems, func(i, j int) bool { return bytes.Compare(elems[i], elems[j]) < 0 })
+	b, _ := asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: bytes.Join(elems, nil)})
+	return b
+}
+
+func newAttribute(oid asn1.ObjectIdentifier, value any) ([]byte, error) {
+	v, err := asn1.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(attribute{Type: oid, Value: asn1.RawValue{FullBytes: derSet(v)}})
+}
+
+// signDetached returns a DER PKCS#7 SignedData over content without the content itself,
+// signed by key for cert and carrying cert followed by chain.
+func signDetached(content []byte, cert *x509.Certificate, chain []*x509.Certificate, key crypto.Signer, signedAt time.Time) ([]byte, error) {
+	digest := sha256.Sum256(content)
+	attrs := make([][]byte, 0, 3)
+	for _, a := range []struct {
+		oid   asn1.ObjectIdentifier
+		value any
+	}{
+		{oidContentType, oidData},
+		{oidMessageDigest, digest[:]},
+		{oidSigningTime, signedAt.UTC()},
+	} {
+		attr, err := newAttribute(a.oid, a.value)
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, attr)
+	}
+	// The signature covers the attributes encoded as a SET; in the SignerInfo they are
+	// tagged [0] instead
+	signed := derSet(attrs...)
+	attrsDigest := sha256.Sum256(signed)
+
+	sigAlg := algorithmIdentifier{Algorithm: oidRSA, Parameters: asn1.NullRawValue}
+	switch key.Public().(type) {
+	case *rsa.PublicKey:
+	case *ecdsa.PublicKey:
+		sigAlg = algorithmIdentifier{Algorithm: oidECDSASHA256}
+	default:
+		return nil, ErrInvalidKey
+	}
+	signature, err := key.Sign(rand.Reader, attrsDigest[:], crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+
+	var setContent asn1.RawValue
+	if _, err := asn1.Unmarshal(signed, &setContent); err != nil {
+		return nil, err
+	}
+	info, err := asn1.Marshal(signerInfo{
+		Version:            1,
+		SID:                issuerAndSerial{Issuer: asn1.RawValue{FullBytes: cert.RawIssuer}, Serial: cert.SerialNumber},
+		DigestAlgorithm:    algorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue},
+		SignedAttrs:        asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: setContent.Bytes},
+		SignatureAlgorithm: sigAlg,
+		Signature:          signature,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	digestAlg, err := asn1.Marshal(algorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue})
+	if err != nil {
+		return nil, err
+	}
+	certs := [][]byte{cert.Raw}
+	for _, c := range chain {
+		certs = append(certs, c.Raw)
+	}
+	sd, err := asn1.Marshal(signedData{
+		Version:          1,
+		DigestAlgorithms: asn1.RawValue{FullBytes: derSet(digestAlg)},
+		EncapContentInfo: encapContentInfo{ContentType: oidData},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: bytes.Join(certs, nil)},
+		SignerInfos:      asn1.RawValue{FullBytes: derSet(info)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: sd},
+	})
+}
@@ -0,0 +1,81 @@
+// Package wallet builds mobile wallet passes for tickets: signed Apple Wallet .pkpass
+// bundles and Google Wallet "save" links.
+//
+// Both carry the ticket's barcode as a QR code, so a pass scans at the gate like the ticket
+// it was made from.
+package wallet
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+var ErrInvalidKey = errors.New("wallet: unsupported or malformed key")
+
+// Ticket is what a pass shows. Serial identifies the pass and must stay the same when the
+// pass is generated again, so wallets replace it rather than adding a second one.
+type Ticket struct {
+	Serial     string
+	Barcode    string
+	EventID    string
+	EventName  string
+	Venue      string
+	StartTime  time.Time
+	EndTime    time.Time
+	Seats      []string
+	HolderName string
+	Latitude   *float64
+	Longitude  *float64
+}
+
+// seatsText is the ticket's seats for display, or "General admission" if none were assigned.
+func (t *Ticket) seatsText() string {
+	if len(t.Seats) == 0 {
+		return "General admission"
+	}
+	return strings.Join(t.Seats, ", ")
+}
+
+// readPEM returns the DER bytes of the first PEM block in the file.
+func readPEM(path string) ([]byte, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, fmt.Errorf("wallet: no PEM data in %s", path)
+	}
+	return block.Bytes, nil
+}
+
+func loadCertificate(path string) (*x509.Certificate, error) {
+	der, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
+
+// loadKey reads a PKCS#8, PKCS#1 or SEC 1 private key.
+func loadKey(path string) (any, error) {
+	der, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+	return nil, ErrInvalidKey
+}
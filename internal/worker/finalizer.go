@@ -3,10 +3,21 @@ package worker
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
 
+	"github.com/google/uuid"
 	kafkax "github.com/samirwankhede/lewly-pgpyewj/internal/kafka"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/metrics"
 	workerService "github.com/samirwankhede/lewly-pgpyewj/internal/service/worker"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/journal"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/store/ledger"
+	"github.com/samirwankhede/lewly-pgpyewj/internal/tracing"
 	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -16,57 +27,488 @@ type Finalizer struct {
 	c          *kafkax.Consumer
 	dlq        *kafkax.Producer
 	maxWorkers int
+	// maxAttempts is how many times a message is handled before it is dead-lettered
+	maxAttempts int
+	retryBase   time.Duration
+	retryMax    time.Duration
+	// drainTimeout is how long Run waits on shutdown for messages being handled to finish
+	drainTimeout time.Duration
+	// journal is nil unless handled messages are recorded for booking traces
+	journal *journal.JournalRepository
+	// ledger is nil unless messages are claimed before handling so redeliveries are skipped
+	ledger *ledger.LedgerRepository
 }
 
+const (
+	// journalTimeout bounds recording a handled message in the journal.
+	journalTimeout = 5 * time.Second
+	// ledgerTimeout bounds claiming a message in the ledger, marking it or releasing it.
+	ledgerTimeout = 5 * time.Second
+	// ledgerRetention is how long handled messages are kept in the ledger. A message is only
+	// redelivered until its offset is committed, which is well within this.
+	ledgerRetention = 7 * 24 * time.Hour
+	// claimPollInterval is how often a message another consumer holds a live claim on is
+	// checked for that claim finishing or expiring.
+	claimPollInterval = time.Second
+)
+
 func NewFinalizer(log *zap.Logger, service *workerService.FinalizeService, c *kafkax.Consumer, dlq *kafkax.Producer, maxWorkers int) *Finalizer {
 	return &Finalizer{
-		log:        log,
-		service:    service,
-		c:          c,
-		dlq:        dlq,
-		maxWorkers: maxWorkers,
+		log:         log,
+		service:     service,
+		c:           c,
+		dlq:         dlq,
+		maxWorkers:  maxWorkers,
+		maxAttempts: 1,
+	}
+}
+
+// WithDrain lets messages being handled when Run's context is done finish, and their
+// offsets be committed, for up to timeout before they are cancelled.
+func (f *Finalizer) WithDrain(timeout time.Duration) *Finalizer {
+	f.drainTimeout = timeout
+	return f
+}
+
+// WithJournal records every handled message, with its outcome, in the message journal.
+func (f *Finalizer) WithJournal(j *journal.JournalRepository) *Finalizer {
+	f.journal = j
+	return f
+}
+
+// WithLedger claims every message in the ledger before handling it, renews the claim while
+// handling it and marks it processed before its offset is committed. A redelivered message
+// found processed is skipped, so it doesn't send its emails or schedule its timeout again.
+// One still being handled by another consumer, as after a rebalance, is waited on, and
+// handled here only if that consumer stops renewing its claim, as when it crashed.
+func (f *Finalizer) WithLedger(l *ledger.LedgerRepository) *Finalizer {
+	f.ledger = l
+	return f
+}
+
+// WithRetries handles a failed message up to maxAttempts times in all before dead-lettering
+// it, waiting base between the first two attempts and doubling up to max after that.
+func (f *Finalizer) WithRetries(maxAttempts int, base, max time.Duration) *Finalizer {
+	f.maxAttempts = maxAttempts
+	if f.maxAttempts < 1 {
+		f.maxAttempts = 1
 	}
+	f.retryBase = base
+	f.retryMax = max
+	return f
 }
 
+// Run handles messages until ctx is done, then stops fetching and drains: messages already
+// being handled get up to the drain timeout to finish and be committed, after which the rest
+// are cancelled and left uncommitted for redelivery. It returns once none is being handled.
 func (f *Finalizer) Run(ctx context.Context) error {
 	workerCount := f.maxWorkers
 	sem := make(chan struct{}, workerCount) // concurrency limit
+	var wg sync.WaitGroup
+	// Handling outlives ctx, so work in flight at shutdown can finish
+	workCtx, cancelWork := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelWork()
+
+	for ctx.Err() == nil {
+		m, err := f.c.Fetch(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				f.log.Error("failed to read message", zap.Error(err))
+			}
+			continue
+		}
+
+		// Acquire semaphore
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			// Fetched but never handled; left uncommitted, it is redelivered
+			continue
+		}
+		wg.Add(1)
+		go func(m kafka.Message) {
+			defer wg.Done()
+			defer func() { <-sem }() // Release semaphore
+			f.handle(workCtx, m)
+		}(m)
+	}
+
+	f.drain(&wg, cancelWork)
+	return ctx.Err()
+}
+
+// drain waits for the messages being handled to finish, cancelling them once the drain
+// timeout has passed.
+func (f *Finalizer) drain(wg *sync.WaitGroup, cancelWork context.CancelFunc) {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	f.log.Info("draining finalizer", zap.Duration("timeout", f.drainTimeout))
+	t := time.NewTimer(f.drainTimeout)
+	defer t.Stop()
+	select {
+	case <-done:
+		f.log.Info("finalizer drained")
+		return
+	case <-t.C:
+	}
+	f.log.Warn("finalizer drain timed out, cancelling messages still being handled")
+	cancelWork()
+	<-done
+}
+
+// handle handles m and commits, dead-letters or abandons it.
+func (f *Finalizer) handle(ctx context.Context, m kafka.Message) {
+	start := time.Now()
+	typ, claim := f.claim(ctx, m)
+	switch claim {
+	case ledger.ClaimProcessed:
+		// Handled before the worker died, but not committed
+		metrics.WorkerMessagesTotal.WithLabelValues(typ, "duplicate").Inc()
+		_ = f.c.Commit(ctx, m)
+		f.record(ctx, m, typ, journal.OutcomeDuplicate, 0, nil, time.Since(start))
+		return
+	case ledger.ClaimHeld:
+		// Stopped while another consumer was still handling it; left uncommitted, it is
+		// redelivered
+		f.record(ctx, m, typ, journal.OutcomeAbandoned, 0, nil, time.Since(start))
+		return
+	case ledger.ClaimTakenOver:
+		// The consumer handling it stopped renewing its claim, most likely by crashing
+		// partway; finalizing a booking no longer pending does nothing, so it is handled
+		// again rather than left pending
+		metrics.WorkerMessagesTotal.WithLabelValues(typ, "taken_over").Inc()
+		f.log.Warn("handling message whose claim expired", zap.String("type", typ), zap.Int("partition", m.Partition), zap.Int64("offset", m.Offset))
+	}
+	// Continue the trace of the request that published the message
+	msgCtx, span := tracing.StartKind(kafkax.MessageContext(ctx, m), trace.SpanKindConsumer, "kafka consume "+m.Topic,
+		attribute.String("messaging.system", "kafka"),
+		attribute.String("messaging.source.name", m.Topic),
+		attribute.Int("messaging.kafka.partition", m.Partition),
+		attribute.Int64("messaging.kafka.message.offset", m.Offset),
+	)
+	stopRenewing := f.renew(ctx, m)
+	typ, attempts, err := f.process(msgCtx, m)
+	stopRenewing()
+	span.SetAttributes(attribute.String("messaging.message.type", typ), attribute.Int("messaging.attempts", attempts))
+	tracing.End(span, err)
+	metrics.WorkerMessagesTotal.WithLabelValues(typ, "consumed").Inc()
+	metrics.WorkerMessageDuration.WithLabelValues(typ).Observe(time.Since(start).Seconds())
+	if typ == kafkax.TypeFinalizeBooking {
+		metrics.BookingFinalizeDuration.Observe(time.Since(start).Seconds())
+	}
 
+	outcome := f.settle(ctx, m, typ, attempts, err)
+	f.record(ctx, m, typ, outcome, attempts, err, time.Since(start))
+}
+
+// settle commits or dead-letters m once it has been handled, and returns the outcome.
+func (f *Finalizer) settle(ctx context.Context, m kafka.Message, typ string, attempts int, err error) string {
+	if err == nil {
+		metrics.WorkerMessagesTotal.WithLabelValues(typ, "succeeded").Inc()
+		// Recorded before the commit, so a redelivery after a failed commit is skipped
+		f.markProcessed(ctx, m)
+		// Commit on success
+		_ = f.c.Commit(ctx, m)
+		return journal.OutcomeSucceeded
+	}
+
+	metrics.WorkerMessagesTotal.WithLabelValues(typ, "failed").Inc()
+	// Whatever becomes of it, a redelivery is handled again
+	f.release(ctx, m)
+	var verr *kafkax.ValidationError
+	if errors.As(err, &verr) {
+		// Redelivery can't fix a malformed message, so commit once it is parked in the DLQ
+		f.log.Warn("message failed schema validation", zap.Error(err))
+		if err := f.deadLetter(ctx, m, typ, "schema_validation", verr.Error(), attempts); err != nil {
+			return journal.OutcomeFailed
+		}
+		_ = f.c.Commit(ctx, m)
+		return journal.OutcomeDeadLettered
+	}
+	if ctx.Err() != nil {
+		// Cancelled when the drain timed out; left uncommitted, the message is redelivered
+		f.log.Warn("stopped handling message on shutdown", zap.Error(err), zap.Int("attempts", attempts))
+		return journal.OutcomeAbandoned
+	}
+	f.log.Error("failed to handle message", zap.Error(err), zap.Int("attempts", attempts))
+	// Retries are exhausted; send to DLQ for manual inspection, and commit once it is parked
+	// there so a redelivery doesn't dead-letter it again
+	if err := f.deadLetter(ctx, m, typ, "processing_error", err.Error(), attempts); err != nil {
+		return journal.OutcomeFailed
+	}
+	_ = f.c.Commit(ctx, m)
+	return journal.OutcomeDeadLettered
+}
+
+// claim claims m in the ledger before it is handled, and returns m's type with the claim's
+// state. While another consumer holds a live claim on m it waits for that claim to be
+// processed or to expire, returning ClaimHeld only if ctx is done first. A failed claim
+// handles m anyway rather than risk dropping it.
+func (f *Finalizer) claim(ctx context.Context, m kafka.Message) (string, string) {
+	if f.ledger == nil {
+		return "", ledger.ClaimTaken
+	}
+	var state string
 	for {
+		claimCtx, cancel := context.WithTimeout(ctx, ledgerTimeout)
+		var err error
+		state, err = f.ledger.Claim(claimCtx, ledgerMessage(m))
+		cancel()
+		if err != nil {
+			f.log.Warn("failed to claim message in ledger", zap.Error(err), zap.Int("partition", m.Partition), zap.Int64("offset", m.Offset))
+			return "", ledger.ClaimTaken
+		}
+		if state != ledger.ClaimHeld {
+			break
+		}
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
-		default:
-			m, err := f.c.Fetch(ctx)
-			if err != nil {
-				f.log.Error("failed to read message", zap.Error(err))
-				continue
+			return "", state
+		case <-time.After(claimPollInterval):
+		}
+	}
+	if state == ledger.ClaimTaken {
+		return "", state
+	}
+	env, _ := kafkax.DecodeMessage(m)
+	typ := messageType(env.Type)
+	if state == ledger.ClaimProcessed {
+		f.log.Info("skipping message already handled", zap.String("type", typ), zap.Int("partition", m.Partition), zap.Int64("offset", m.Offset))
+	}
+	return typ, state
+}
+
+// renew keeps the caller's claim on m live until the returned function is called.
+func (f *Finalizer) renew(ctx context.Context, m kafka.Message) func() {
+	if f.ledger == nil {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(f.ledger.Lease() / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
 			}
+			renewCtx, cancelRenew := context.WithTimeout(ctx, ledgerTimeout)
+			err := f.ledger.Renew(renewCtx, ledgerMessage(m))
+			cancelRenew()
+			if errors.Is(err, ledger.ErrClaimLost) {
+				f.log.Warn("message claim taken over while handling it", zap.Int("partition", m.Partition), zap.Int64("offset", m.Offset))
+				return
+			}
+			if err != nil && ctx.Err() == nil {
+				f.log.Warn("failed to renew message claim", zap.Error(err), zap.Int("partition", m.Partition), zap.Int64("offset", m.Offset))
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
 
-			// Acquire semaphore
-			sem <- struct{}{}
-			go func(m kafka.Message) {
-				defer func() { <-sem }() // Release semaphore
-
-				if err := f.handleMessage(ctx, m); err != nil {
-					f.log.Error("failed to handle message", zap.Error(err))
-					// Send to DLQ for manual inspection
-					_ = f.dlq.Publish(ctx, m.Key, m.Value)
-				} else {
-					// Commit on success
-					_ = f.c.Commit(ctx, m)
-				}
-			}(m)
+// markProcessed records m as handled in the ledger. It is written even while the worker
+// stops, when the commit that follows is most likely to fail.
+func (f *Finalizer) markProcessed(ctx context.Context, m kafka.Message) {
+	if f.ledger == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), ledgerTimeout)
+	defer cancel()
+	if err := f.ledger.MarkProcessed(ctx, ledgerMessage(m)); err != nil {
+		f.log.Warn("failed to record message in ledger", zap.Error(err), zap.Int("partition", m.Partition), zap.Int64("offset", m.Offset))
+	}
+}
+
+// release drops m's claim after handling it failed. It is written even while the worker
+// stops, as an abandoned message is redelivered.
+func (f *Finalizer) release(ctx context.Context, m kafka.Message) {
+	if f.ledger == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), ledgerTimeout)
+	defer cancel()
+	if err := f.ledger.Release(ctx, ledgerMessage(m)); err != nil {
+		f.log.Warn("failed to release message claim", zap.Error(err), zap.Int("partition", m.Partition), zap.Int64("offset", m.Offset))
+	}
+}
+
+func ledgerMessage(m kafka.Message) ledger.Message {
+	return ledger.Message{Topic: m.Topic, Key: string(m.Key), Partition: m.Partition, Offset: m.Offset}
+}
+
+// RunLedgerPurge deletes messages older than ledgerRetention from the ledger every hour
+// until ctx is done.
+func (f *Finalizer) RunLedgerPurge(ctx context.Context) {
+	if f.ledger == nil {
+		return
+	}
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		if n, err := f.ledger.Purge(ctx, time.Now().Add(-ledgerRetention)); err != nil {
+			f.log.Error("failed to purge message ledger", zap.Error(err))
+		} else if n > 0 {
+			f.log.Info("purged message ledger", zap.Int64("count", n))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
 		}
 	}
 }
 
-func (f *Finalizer) handleMessage(ctx context.Context, m kafka.Message) error {
-	var p workerService.FinalizePayload
-	if err := json.Unmarshal(m.Value, &p); err != nil {
+// record adds m's handling to the message journal, for its booking's trace. It is written
+// even while the worker stops, so an abandoned message shows as such.
+func (f *Finalizer) record(ctx context.Context, m kafka.Message, typ, outcome string, attempts int, err error, took time.Duration) {
+	if f.journal == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), journalTimeout)
+	defer cancel()
+	e := &journal.Entry{BookingID: bookingIDOf(m), Topic: m.Topic, Partition: m.Partition, Offset: m.Offset,
+		Type: typ, Outcome: outcome, Attempts: attempts, Duration: took}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	if err := f.journal.Record(ctx, e); err != nil {
+		f.log.Warn("failed to record message in journal", zap.Error(err), zap.Int("partition", m.Partition), zap.Int64("offset", m.Offset))
+	}
+}
+
+// bookingIDOf returns the booking m is about, or "" if that can't be read from it.
+func bookingIDOf(m kafka.Message) string {
+	env, _ := kafkax.DecodeMessage(m)
+	var p struct {
+		BookingID string `json:"booking_id"`
+	}
+	if json.Unmarshal(env.Payload, &p) != nil {
+		return ""
+	}
+	if _, err := uuid.Parse(p.BookingID); err != nil {
+		return ""
+	}
+	return p.BookingID
+}
+
+// process handles m, retrying a failure in place after a backoff until maxAttempts attempts
+// have been made, so a blip in the mail server or Postgres doesn't dead-letter a booking.
+// Messages that fail validation aren't retried, as they would fail the same way. It returns
+// m's type, the attempts made and the last error.
+func (f *Finalizer) process(ctx context.Context, m kafka.Message) (string, int, error) {
+	for attempt := 1; ; attempt++ {
+		typ, err := f.handleMessage(ctx, m)
+		var verr *kafkax.ValidationError
+		if err == nil || errors.As(err, &verr) || attempt >= f.maxAttempts {
+			return typ, attempt, err
+		}
+		wait := f.backoff(attempt)
+		metrics.WorkerMessagesTotal.WithLabelValues(typ, "retried").Inc()
+		f.log.Warn("failed to handle message, retrying", zap.Error(err), zap.String("type", typ), zap.Int("attempt", attempt), zap.Duration("backoff", wait))
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return typ, attempt, err
+		case <-t.C:
+		}
+	}
+}
+
+// backoff is how long to wait after the given attempt: retryBase doubled for every attempt
+// after the first, at most retryMax.
+func (f *Finalizer) backoff(attempt int) time.Duration {
+	d := f.retryBase
+	for i := 1; i < attempt && d < f.retryMax; i++ {
+		d *= 2
+	}
+	return min(d, f.retryMax)
+}
+
+// deadLetter parks m in the DLQ, counting it once the DLQ has accepted it.
+func (f *Finalizer) deadLetter(ctx context.Context, m kafka.Message, typ, reason, detail string, attempts int) error {
+	if err := f.dlq.Publish(ctx, m.Key, m.Value, dlqHeaders(m, reason, detail, attempts)...); err != nil {
+		f.log.Error("failed to publish to DLQ", zap.Error(err), zap.String("reason", reason))
 		return err
 	}
+	metrics.WorkerMessagesTotal.WithLabelValues(typ, "dead_lettered").Inc()
+	return nil
+}
+
+// RunDLQDepthGauge samples the DLQ's depth into metrics.DLQDepth every interval until ctx
+// is done, so a growing backlog can be alerted on.
+func (f *Finalizer) RunDLQDepthGauge(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		depth, err := f.dlq.Depth(ctx)
+		if err != nil {
+			f.log.Warn("failed to read DLQ depth", zap.Error(err))
+		} else {
+			metrics.DLQDepth.WithLabelValues(f.dlq.Topic()).Set(float64(depth))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// messageType bounds the type label to the known envelope types.
+func messageType(t string) string {
+	switch t {
+	case kafkax.TypeFinalizeBooking, kafkax.TypeBookingTimeout:
+		return t
+	}
+	return "unknown"
+}
+
+// handleMessage processes m and returns its envelope type for metrics, "unknown" when the
+// message can't be decoded.
+func (f *Finalizer) handleMessage(ctx context.Context, m kafka.Message) (string, error) {
+	env, err := kafkax.DecodeMessage(m)
+	// A message that failed validation may still have decoded far enough to have a type
+	typ := messageType(env.Type)
+	if err != nil {
+		return typ, err
+	}
+
+	var p workerService.FinalizePayload
+	if err := json.Unmarshal(env.Payload, &p); err != nil {
+		return typ, err
+	}
+	p.Type = env.Type
+
+	switch env.Type {
+	case kafkax.TypeBookingTimeout:
+		return typ, f.service.HandleBookingTimeout(ctx, p)
+	default:
+		return typ, f.service.HandleBookingFinalization(ctx, p)
+	}
+}
 
-	// Handle normal finalization
-	return f.service.HandleBookingFinalization(ctx, p)
+// dlqHeaders records why a message was dead-lettered, after how many attempts, and where it
+// came from.
+func dlqHeaders(m kafka.Message, reason, detail string, attempts int) []kafka.Header {
+	// Keep the original headers (content-type in particular) so the value can still be decoded
+	return append(append([]kafka.Header{}, m.Headers...),
+		kafka.Header{Key: kafkax.DLQReasonHeader, Value: []byte(reason)},
+		kafka.Header{Key: kafkax.DLQErrorHeader, Value: []byte(detail)},
+		kafka.Header{Key: kafkax.DLQAttemptsHeader, Value: []byte(strconv.Itoa(attempts))},
+		kafka.Header{Key: kafkax.DLQSourceTopicHeader, Value: []byte(m.Topic)},
+		kafka.Header{Key: kafkax.DLQSourcePartitionHeader, Value: []byte(strconv.Itoa(m.Partition))},
+		kafka.Header{Key: kafkax.DLQSourceOffsetHeader, Value: []byte(strconv.FormatInt(m.Offset, 10))},
+	)
 }
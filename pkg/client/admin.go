@@ -0,0 +1,601 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// CreateEventRequest describes a new event. Seats lists every seat label, SeatLayout generates
+// them, or Sections generates named blocks sold at PriceTiers; Capacity defaults to the
+// number of seats.
+type CreateEventRequest struct {
+	Name                     string        `json:"name"`
+	Venue                    string        `json:"venue"`
+	Category                 string        `json:"category,omitempty"`
+	StartTime                time.Time     `json:"start_time"`
+	EndTime                  time.Time     `json:"end_time"`
+	Capacity                 int           `json:"capacity,omitempty"`
+	TicketPrice              float64       `json:"ticket_price"`
+	CancellationFee          float64       `json:"cancellation_fee"`
+	Currency                 string        `json:"currency,omitempty"` // ISO 4217, USD when empty
+	MaximumTicketsPerBooking int           `json:"maximum_tickets_per_booking,omitempty"`
+	Seats                    []string      `json:"seats,omitempty"`
+	SeatLayout               *SeatLayout   `json:"seat_layout,omitempty"`
+	Sections                 []SeatSection `json:"sections,omitempty"`
+	PriceTiers               []PriceTier   `json:"price_tiers,omitempty"`
+	OrganizerID              *string       `json:"organizer_id,omitempty"`
+	MaxTicketsPerUser        *int          `json:"max_tickets_per_user,omitempty"`
+	UserTicketWindowHours    *int          `json:"user_ticket_window_hours,omitempty"`
+	Latitude                 *float64      `json:"latitude,omitempty"`
+	Longitude                *float64      `json:"longitude,omitempty"`
+	// Feature toggles default to on when nil
+	WaitlistEnabled      *bool `json:"waitlist_enabled,omitempty"`
+	SeatSelectionEnabled *bool `json:"seat_selection_enabled,omitempty"`
+	LikesEnabled         *bool `json:"likes_enabled,omitempty"`
+	// NoSingleSeat rejects seat selections that leave a lone empty seat in a row, and has
+	// general admission seats assigned in gap-free blocks
+	NoSingleSeat bool `json:"no_single_seat,omitempty"`
+	// PaymentCapture "manual" only authorizes cards at booking; they're charged by
+	// CapturePayment / CaptureEventPayments or automatically at CaptureAt (default: the start)
+	PaymentCapture string     `json:"payment_capture,omitempty"`
+	CaptureAt      *time.Time `json:"capture_at,omitempty"`
+	// Visibility is "public" (the default), "unlisted" or "private". Unlisted and private events
+	// are left out of listings; private ones also need an invitation, added with ImportInvitees
+	Visibility string `json:"visibility,omitempty"`
+	// Sandbox creates a rehearsal event only admins and its testers can book; it is never public
+	Sandbox bool `json:"sandbox,omitempty"`
+	// AllowDuplicate creates the event even if one with the same name, venue and start exists
+	AllowDuplicate bool `json:"allow_duplicate,omitempty"`
+}
+
+// SeatLayout has the server generate seats as Prefix + row + number, e.g. Rows {"A", "B"} with
+// SeatsPerRow 40 gives A1..A40, B1..B40. RowCount names rows A..Z, AA, ... instead of Rows.
+type SeatLayout struct {
+	Rows        []string `json:"rows,omitempty"`
+	RowCount    int      `json:"row_count,omitempty"`
+	SeatsPerRow int      `json:"seats_per_row"`
+	Prefix      string   `json:"prefix,omitempty"`
+	FirstNumber *int     `json:"first_number,omitempty"`
+}
+
+// SeatSection is a named block of the seat map laid out like SeatLayout; its seats sell at
+// the price of Tier, one of the event's PriceTiers, or at the ticket price without one.
+// Labels must be unique across sections, so give sections reusing row names a Prefix.
+type SeatSection struct {
+	Name string `json:"name"`
+	Tier string `json:"tier,omitempty"`
+	SeatLayout
+}
+
+// PriceTier is a price seats can be sold at instead of the event's ticket price.
+type PriceTier struct {
+	Name  string  `json:"name"`
+	Price float64 `json:"price"`
+}
+
+// TokenResync reports an event's token bucket before and after it was reset from Postgres.
+type TokenResync struct {
+	EventID string `json:"event_id"`
+	Before  int    `json:"before"`
+	After   int    `json:"after"`
+	// Phases has the same for each of the event's sale phase buckets
+	Phases []PhaseResync `json:"phases,omitempty"`
+}
+
+// PhaseResync reports a sale phase's token bucket before and after it was reset.
+type PhaseResync struct {
+	PhaseID string `json:"phase_id"`
+	Name    string `json:"name"`
+	Before  int    `json:"before"`
+	After   int    `json:"after"`
+}
+
+// ListAllEvents lists events in every status, for operators.
+func (c *Client) ListAllEvents(ctx context.Context, o ListOptions) ([]Event, *Pagination, error) {
+	return c.listEvents(ctx, "/v1/events/all", o.values())
+}
+
+func (c *Client) CreateEvent(ctx context.Context, req CreateEventRequest) (*Event, error) {
+	var e Event
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/admin/events", body: req, auth: true, admin: true}, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// CancelEvent starts a job that cancels an event and emails its ticket holders; follow it
+// with WaitJob. Its result is a CancellationResult. It is not retried automatically.
+func (c *Client) CancelEvent(ctx context.Context, eventID string) (*Job, error) {
+	var j Job
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/admin/events/" + url.PathEscape(eventID) + "/cancel", auth: true, admin: true, noRetry: true}, &j); err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+// CancellationResult is the result of an event_cancellation job.
+type CancellationResult struct {
+	Notified            int     `json:"notified"`
+	NotificationBatchID *string `json:"notification_batch_id,omitempty"`
+}
+
+// RefundCancelledEvent starts a job that refunds every paid booking of a cancelled event in
+// full; its result is an EventRefundResult. It is not retried automatically.
+func (c *Client) RefundCancelledEvent(ctx context.Context, eventID string) (*Job, error) {
+	var j Job
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/v1/payment/events/" + url.PathEscape(eventID) + "/refund", auth: true, admin: true, noRetry: true}, &j); err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+// CaptureEventPayments starts a job that charges every card authorization of a manual-capture
+// event; its result is a CaptureResult. It is not retried automatically.
+func (c *Client) CaptureEventPayments(ctx context.Context, eventID string) (*Job, error) {
+	var j Job
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/v1/payment/events/" + url.PathEscape(eventID) + "/capture", auth: true, admin: true, noRetry: true}, &j); err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+// CaptureResult is the result of a payment_capture job.
+type CaptureResult struct {
+	Captured int     `json:"captured"`
+	Failed   int     `json:"failed"`
+	Amount   float64 `json:"amount"`
+}
+
+// CapturePayment charges a booking's card authorization. It fails with 409 when the booking
+// is already paid or has nothing to capture, and 402 when the provider declines.
+func (c *Client) CapturePayment(ctx context.Context, bookingID string) (*Payment, error) {
+	var p Payment
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/v1/payment/bookings/" + url.PathEscape(bookingID) + "/capture", auth: true, admin: true, noRetry: true}, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// EventRefundResult is the result of an event_refund job.
+type EventRefundResult struct {
+	Refunded int     `json:"refunded"`
+	Amount   float64 `json:"amount"`
+	// Voided counts card authorizations released without a charge
+	Voided int `json:"voided"`
+}
+
+// NotificationBatch is an email broadcast to many recipients and how far it got. Status is
+// "sending" until every recipient was either sent to or failed, then "done".
+type NotificationBatch struct {
+	ID          string     `json:"id"`
+	Kind        string     `json:"kind"`
+	EventID     *string    `json:"event_id,omitempty"`
+	Subject     string     `json:"subject"`
+	Status      string     `json:"status"`
+	Total       int        `json:"total"`
+	Sent        int        `json:"sent"`
+	Failed      int        `json:"failed"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// NotificationBatches lists broadcasts, newest first.
+func (c *Client) NotificationBatches(ctx context.Context, o ListOptions) ([]NotificationBatch, *Pagination, error) {
+	var batches []NotificationBatch
+	page, err := c.do(ctx, request{method: http.MethodGet, path: "/admin/notifications", query: o.values(), auth: true, admin: true}, &batches)
+	if err != nil {
+		return nil, nil, err
+	}
+	return batches, page, nil
+}
+
+func (c *Client) NotificationBatch(ctx context.Context, id string) (*NotificationBatch, error) {
+	var b NotificationBatch
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/admin/notifications/" + url.PathEscape(id), auth: true, admin: true}, &b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// ResyncTokens resets the event's Redis token bucket from its bookings in Postgres.
+func (c *Client) ResyncTokens(ctx context.Context, eventID string) (*TokenResync, error) {
+	var res TokenResync
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/admin/events/" + url.PathEscape(eventID) + "/tokens/resync", auth: true, admin: true}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// EventComparison is one event's sales metrics from CompareEvents.
+type EventComparison struct {
+	EventID               string         `json:"event_id"`
+	Name                  string         `json:"name"`
+	StartTime             time.Time      `json:"start_time"`
+	Capacity              int            `json:"capacity"`
+	SeatsSold             int            `json:"seats_sold"`
+	SellThroughPct        float64        `json:"sell_through_pct"`
+	Revenue               float64        `json:"revenue"`
+	TimeToSellOutSeconds  *float64       `json:"time_to_sell_out_seconds"`
+	WaitlistPromotions    int            `json:"waitlist_promotions"`
+	WaitlistConversionPct float64        `json:"waitlist_conversion_pct"`
+	CancellationRatePct   float64        `json:"cancellation_rate_pct"`
+	SalesByChannel        []ChannelSales `json:"sales_by_channel"`
+	// Invitations is set for private events only
+	Invitations *InvitationStats `json:"invitations,omitempty"`
+}
+
+// InvitationStats is a private event's invitation funnel; percentages are of issued.
+type InvitationStats struct {
+	Issued        int     `json:"issued"`
+	Redeemed      int     `json:"redeemed"`
+	Booked        int     `json:"booked"`
+	RedemptionPct float64 `json:"redemption_pct"`
+	BookedPct     float64 `json:"booked_pct"`
+}
+
+// ChannelSales is what one booking source sold: paid bookings, their seats and revenue.
+type ChannelSales struct {
+	Source   string  `json:"source"`
+	Bookings int     `json:"bookings"`
+	Seats    int     `json:"seats"`
+	Revenue  float64 `json:"revenue"`
+}
+
+// CompareEvents returns side-by-side sales metrics for 2 to 20 events.
+func (c *Client) CompareEvents(ctx context.Context, eventIDs []string) ([]EventComparison, error) {
+	var res struct {
+		Events []EventComparison `json:"events"`
+	}
+	q := url.Values{"event_ids": {strings.Join(eventIDs, ",")}}
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/admin/analytics/compare", query: q, auth: true, admin: true}, &res); err != nil {
+		return nil, err
+	}
+	return res.Events, nil
+}
+
+// SalesPoint is one hour or day of a SalesCurve.
+type SalesPoint struct {
+	Start              time.Time `json:"start"`
+	Bookings           int       `json:"bookings"`
+	Tickets            int       `json:"tickets"`
+	CumulativeTickets  int       `json:"cumulative_tickets"`
+	SellThroughPercent float64   `json:"sell_through_percent"`
+}
+
+// SalesCurve is how an event's sale paced, one point per hour or day.
+type SalesCurve struct {
+	EventID     string       `json:"event_id"`
+	Interval    string       `json:"interval"`
+	Timezone    string       `json:"timezone"`
+	Capacity    int          `json:"capacity"`
+	TicketsSold int          `json:"tickets_sold"`
+	Points      []SalesPoint `json:"points"`
+}
+
+// SalesCurve returns tickets sold per interval ("hour" or "day") since the event was created,
+// bucketed in the IANA time zone tz ("UTC" when empty).
+func (c *Client) SalesCurve(ctx context.Context, eventID, interval, tz string) (*SalesCurve, error) {
+	q := url.Values{}
+	if interval != "" {
+		q.Set("interval", interval)
+	}
+	if tz != "" {
+		q.Set("tz", tz)
+	}
+	var res SalesCurve
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/admin/events/" + url.PathEscape(eventID) + "/sales-curve", query: q, auth: true, admin: true}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// CapacityIncrease reports seats IncreaseCapacity added and where they went.
+type CapacityIncrease struct {
+	ID               string    `json:"id"`
+	EventID          string    `json:"event_id"`
+	Seats            []string  `json:"seats"`
+	PreviousCapacity int       `json:"previous_capacity"`
+	NewCapacity      int       `json:"new_capacity"`
+	WaitlistOffers   int       `json:"waitlist_offers"`
+	TokensReleased   int       `json:"tokens_released"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// IncreaseCapacity adds seats, priced at tier when it isn't empty, to an event on sale. The
+// new seats are offered to its waitlist first and the rest go on sale. A 409 APIError lists
+// labels the event already has.
+func (c *Client) IncreaseCapacity(ctx context.Context, eventID string, seats []string, tier string) (*CapacityIncrease, error) {
+	var res CapacityIncrease
+	body := map[string]any{"seats": seats, "tier": tier}
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/admin/events/" + url.PathEscape(eventID) + "/capacity", body: body, auth: true, admin: true, noRetry: true}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// SalePhaseInput is one phase of SetSalePhases.
+type SalePhaseInput struct {
+	Name     string    `json:"name"`
+	Price    float64   `json:"price"`
+	Quantity int       `json:"quantity"`
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+}
+
+// SalePhase is one of the consecutive phases an event is sold in. Its pool is Quantity plus
+// what earlier phases rolled into it minus what it rolled on once its window ended.
+type SalePhase struct {
+	ID        string    `json:"id"`
+	EventID   string    `json:"event_id"`
+	Name      string    `json:"name"`
+	Position  int       `json:"position"`
+	Price     float64   `json:"price"`
+	Quantity  int       `json:"quantity"`
+	RolledIn  int       `json:"rolled_in"`
+	RolledOut int       `json:"rolled_out"`
+	StartsAt  time.Time `json:"starts_at"`
+	EndsAt    time.Time `json:"ends_at"`
+	Sold      int       `json:"sold"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SetSalePhases replaces the phases the event is sold in, in order. Windows must follow one
+// another and quantities fit in the capacity, or the APIError is a 400; removing a phase
+// that has bookings is a 409. An empty phases sells the event without phases again.
+func (c *Client) SetSalePhases(ctx context.Context, eventID string, phases []SalePhaseInput) ([]SalePhase, error) {
+	if phases == nil {
+		phases = []SalePhaseInput{}
+	}
+	var out struct {
+		Phases []SalePhase `json:"phases"`
+	}
+	body := map[string]any{"phases": phases}
+	if _, err := c.do(ctx, request{method: http.MethodPut, path: "/admin/events/" + url.PathEscape(eventID) + "/sale-phases", body: body, auth: true, admin: true}, &out); err != nil {
+		return nil, err
+	}
+	return out.Phases, nil
+}
+
+// AdminSalePhases returns the event's sale phases with their rollovers and what each sold.
+func (c *Client) AdminSalePhases(ctx context.Context, eventID string) ([]SalePhase, error) {
+	var out struct {
+		Phases []SalePhase `json:"phases"`
+	}
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/admin/events/" + url.PathEscape(eventID) + "/sale-phases", auth: true, admin: true}, &out); err != nil {
+		return nil, err
+	}
+	return out.Phases, nil
+}
+
+// MergeResult reports what MergeEvents moved from the duplicate into the target.
+type MergeResult struct {
+	SourceID      string `json:"source_id"`
+	TargetID      string `json:"target_id"`
+	Merged        bool   `json:"merged"`
+	BookingsMoved int    `json:"bookings_moved"`
+	WaitlistMoved int    `json:"waitlist_moved"`
+	LikesMoved    int    `json:"likes_moved"`
+}
+
+// MergeEvents folds duplicateID into targetID, moving bookings, waitlist and likes, and
+// cancels the duplicate. A 409 APIError lists pending bookings or conflicting seats.
+func (c *Client) MergeEvents(ctx context.Context, duplicateID, targetID string) (*MergeResult, error) {
+	var res MergeResult
+	body := map[string]string{"into": targetID}
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/admin/events/" + url.PathEscape(duplicateID) + "/merge", body: body, auth: true, admin: true, noRetry: true}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ImportedInvitee is one row of an invitee import and the invitation code it holds.
+type ImportedInvitee struct {
+	Email          string `json:"email"`
+	UserID         string `json:"user_id"`
+	Code           string `json:"code"`
+	NewUser        bool   `json:"new_user"`
+	AlreadyInvited bool   `json:"already_invited"`
+	// EmailError is set when the invitation email couldn't be sent
+	EmailError string `json:"email_error,omitempty"`
+}
+
+// RejectedInvitee is a CSV row an import skipped, with its line number.
+type RejectedInvitee struct {
+	Line   int    `json:"line"`
+	Email  string `json:"email"`
+	Reason string `json:"reason"`
+}
+
+// InviteeImport reports what ImportInvitees did: accounts created and matched, and
+// invitations newly issued.
+type InviteeImport struct {
+	EventID  string            `json:"event_id"`
+	Created  int               `json:"created"`
+	Matched  int               `json:"matched"`
+	Invited  int               `json:"invited"`
+	Invitees []ImportedInvitee `json:"invitees"`
+	Rejected []RejectedInvitee `json:"rejected"`
+}
+
+// ImportInvitees starts a job inviting the emails in csvData to a private event; its result
+// is an InviteeImport. The CSV has an email column and an optional name column, first and
+// second or named in a header row. Unknown emails get an account; invitees are emailed
+// their code. It is not retried automatically.
+func (c *Client) ImportInvitees(ctx context.Context, eventID string, csvData []byte) (*Job, error) {
+	var j Job
+	r := request{method: http.MethodPost, path: "/admin/events/" + url.PathEscape(eventID) + "/invitees", body: csvData, contentType: "text/csv", auth: true, admin: true, noRetry: true}
+	if _, err := c.do(ctx, r, &j); err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+// Invitee is an invitation to a private event and what its user did with it.
+type Invitee struct {
+	Invitation
+	Email  string `json:"email"`
+	Name   string `json:"name"`
+	Booked bool   `json:"booked"`
+}
+
+// InviteeList is a private event's invitees and its redemption funnel.
+type InviteeList struct {
+	EventID  string    `json:"event_id"`
+	Issued   int       `json:"issued"`
+	Redeemed int       `json:"redeemed"`
+	Booked   int       `json:"booked"`
+	Invitees []Invitee `json:"invitees"`
+}
+
+// ListInvitees returns a private event's invitees.
+func (c *Client) ListInvitees(ctx context.Context, eventID string) (*InviteeList, error) {
+	var res InviteeList
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/admin/events/" + url.PathEscape(eventID) + "/invitees", auth: true, admin: true}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// MailTemplate is an email template rendered with sample data and the platform's branding.
+type MailTemplate struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Subject     string `json:"subject"`
+	Body        string `json:"body"`
+	HTML        string `json:"html"`
+}
+
+// MailTemplates previews every email template the platform sends.
+func (c *Client) MailTemplates(ctx context.Context) ([]MailTemplate, error) {
+	var res struct {
+		Templates []MailTemplate `json:"templates"`
+	}
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/admin/mail/templates", auth: true, admin: true}, &res); err != nil {
+		return nil, err
+	}
+	return res.Templates, nil
+}
+
+// SendTestMail sends a template rendered with sample data to to and returns the address it
+// went to. With a token, an empty to sends it to the signed-in admin; API key callers must
+// name a recipient. It is not retried automatically.
+func (c *Client) SendTestMail(ctx context.Context, template, to string) (string, error) {
+	var res struct {
+		To string `json:"to"`
+	}
+	body := map[string]string{"template": template}
+	if to != "" {
+		body["to"] = to
+	}
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/admin/mail/test-send", body: body, auth: true, admin: true, noRetry: true}, &res); err != nil {
+		return "", err
+	}
+	return res.To, nil
+}
+
+// Branding is what emails are signed with. Empty fields fall back to the platform's
+// branding for an organizer, and to the defaults for the platform.
+type Branding struct {
+	OrganizerID  *string   `json:"organizer_id,omitempty"`
+	Name         string    `json:"name"`
+	Footer       string    `json:"footer"`
+	SupportEmail string    `json:"support_email"`
+	LogoURL      string    `json:"logo_url"`
+	LegalText    string    `json:"legal_text"`
+	UpdatedBy    *string   `json:"updated_by,omitempty"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// BrandingSettings is the branding stored for the platform or an organizer, nil if none was
+// set, and what emails are signed with once fallbacks are applied.
+type BrandingSettings struct {
+	Branding  *Branding `json:"branding"`
+	Effective *Branding `json:"effective"`
+}
+
+// brandingPath is the platform's branding, or the organizer's when organizerID is set.
+func brandingPath(organizerID string) string {
+	if organizerID == "" {
+		return "/admin/branding"
+	}
+	return "/admin/organizers/" + url.PathEscape(organizerID) + "/branding"
+}
+
+// EmailBranding returns the platform's email branding, or the organizer's when organizerID
+// is set.
+func (c *Client) EmailBranding(ctx context.Context, organizerID string) (*BrandingSettings, error) {
+	var out BrandingSettings
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: brandingPath(organizerID), auth: true, admin: true}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SetEmailBranding replaces the platform's email branding, or the organizer's when
+// organizerID is set. Only the name, footer, support email, logo URL and legal text of b are
+// sent. Instances other than the one handling the request pick it up within a minute.
+func (c *Client) SetEmailBranding(ctx context.Context, organizerID string, b Branding) (*BrandingSettings, error) {
+	body := map[string]string{"name": b.Name, "footer": b.Footer, "support_email": b.SupportEmail, "logo_url": b.LogoURL, "legal_text": b.LegalText}
+	var out BrandingSettings
+	if _, err := c.do(ctx, request{method: http.MethodPut, path: brandingPath(organizerID), body: body, auth: true, admin: true}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteEmailBranding removes the organizer's email branding, so their emails are signed
+// with the platform's.
+func (c *Client) DeleteEmailBranding(ctx context.Context, organizerID string) error {
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: brandingPath(organizerID), auth: true, admin: true}, nil)
+	return err
+}
+
+// GetBooking returns any user's booking.
+func (c *Client) GetBooking(ctx context.Context, bookingID string) (*Booking, error) {
+	var b Booking
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/admin/bookings/" + url.PathEscape(bookingID), auth: true, admin: true}, &b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// BookingTrace returns the booking's timeline: its status transitions, audit rows, Kafka
+// handling, payments, emails and webhook deliveries.
+func (c *Client) BookingTrace(ctx context.Context, bookingID string) (*BookingTrace, error) {
+	var t BookingTrace
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/admin/bookings/" + url.PathEscape(bookingID) + "/trace", auth: true, admin: true}, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// RequeueFinalize republishes the finalize message of a booking stuck in pending.
+func (c *Client) RequeueFinalize(ctx context.Context, bookingID string) error {
+	_, err := c.do(ctx, request{method: http.MethodPost, path: "/admin/bookings/" + url.PathEscape(bookingID) + "/finalize", auth: true, admin: true, noRetry: true}, nil)
+	return err
+}
+
+func (c *Client) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	body := map[string]string{"email": email}
+	var u User
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/admin/users/get-user", body: body, auth: true, admin: true}, &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// PromoteUser grants the user admin; their existing tokens stop working for admin routes.
+func (c *Client) PromoteUser(ctx context.Context, userID string) error {
+	_, err := c.do(ctx, request{method: http.MethodPost, path: "/admin/users/" + url.PathEscape(userID) + "/admin", auth: true, admin: true}, nil)
+	return err
+}
+
+func (c *Client) DemoteUser(ctx context.Context, userID string) error {
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: "/admin/users/" + url.PathEscape(userID) + "/admin", auth: true, admin: true}, nil)
+	return err
+}
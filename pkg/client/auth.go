@@ -0,0 +1,77 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// Signup creates an account and stores the returned token on the client.
+func (c *Client) Signup(ctx context.Context, req SignupRequest) (*Session, error) {
+	var s Session
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/v1/auth/signup", body: req}, &s); err != nil {
+		return nil, err
+	}
+	c.SetToken(s.Token)
+	return &s, nil
+}
+
+// Login authenticates and stores the returned token on the client.
+func (c *Client) Login(ctx context.Context, email, password string) (*Session, error) {
+	body := map[string]string{"email": email, "password": password}
+	var s Session
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/v1/auth/login", body: body}, &s); err != nil {
+		return nil, err
+	}
+	c.SetToken(s.Token)
+	return &s, nil
+}
+
+// Logout revokes the client's token on the server and forgets it.
+func (c *Client) Logout(ctx context.Context) error {
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/v1/auth/logout", auth: true}, nil); err != nil {
+		return err
+	}
+	c.SetToken("")
+	return nil
+}
+
+func (c *Client) Profile(ctx context.Context) (*User, error) {
+	var u User
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/v1/auth/profile", auth: true}, &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (c *Client) UpdateProfile(ctx context.Context, name, phone string) error {
+	body := map[string]string{"name": name, "phone": phone}
+	_, err := c.do(ctx, request{method: http.MethodPut, path: "/v1/auth/profile", body: body, auth: true}, nil)
+	return err
+}
+
+// SetPreferredCurrency updates the profile along with the currency bookings show their
+// amount in ("" to clear it). Name and phone are overwritten, as with UpdateProfile.
+func (c *Client) SetPreferredCurrency(ctx context.Context, name, phone, currency string) error {
+	body := map[string]string{"name": name, "phone": phone, "preferred_currency": currency}
+	_, err := c.do(ctx, request{method: http.MethodPut, path: "/v1/auth/profile", body: body, auth: true}, nil)
+	return err
+}
+
+func (c *Client) ChangePassword(ctx context.Context, current, next string) error {
+	body := map[string]string{"current_password": current, "new_password": next}
+	_, err := c.do(ctx, request{method: http.MethodPut, path: "/v1/auth/password", body: body, auth: true}, nil)
+	return err
+}
+
+// RequestPasswordOTP emails a one-time code for resetting a forgotten password.
+func (c *Client) RequestPasswordOTP(ctx context.Context, email string) error {
+	body := map[string]string{"email": email}
+	_, err := c.do(ctx, request{method: http.MethodPost, path: "/v1/auth/password/request-otp", body: body}, nil)
+	return err
+}
+
+func (c *Client) ResetPassword(ctx context.Context, email, otp, newPassword string) error {
+	body := map[string]string{"email": email, "otp": otp, "new_password": newPassword}
+	_, err := c.do(ctx, request{method: http.MethodPost, path: "/v1/auth/password/verify-otp", body: body}, nil)
+	return err
+}
@@ -0,0 +1,151 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/google/uuid"
+)
+
+// Book requests seats for an event with a fresh idempotency key, so the request is retried
+// safely on network errors and 5xx responses without double-booking.
+func (c *Client) Book(ctx context.Context, eventID string, seats []string) (*BookingResult, error) {
+	return c.BookWithKey(ctx, eventID, seats, uuid.NewString())
+}
+
+// BookWithKey is Book with a caller-chosen idempotency key, for callers that persist the key
+// to retry across process restarts. Repeating a key returns the original booking.
+func (c *Client) BookWithKey(ctx context.Context, eventID string, seats []string, idempotencyKey string) (*BookingResult, error) {
+	return c.book(ctx, eventID, map[string]any{"seats": seats}, idempotencyKey)
+}
+
+// BookQuantity books quantity tickets; the server picks the best available seats, together
+// in one row where it can. It works for general admission and seat selection events alike.
+func (c *Client) BookQuantity(ctx context.Context, eventID string, quantity int) (*BookingResult, error) {
+	return c.BookQuantityWithKey(ctx, eventID, quantity, uuid.NewString())
+}
+
+// BookQuantityWithKey is BookQuantity with a caller-chosen idempotency key.
+func (c *Client) BookQuantityWithKey(ctx context.Context, eventID string, quantity int, idempotencyKey string) (*BookingResult, error) {
+	return c.book(ctx, eventID, map[string]any{"quantity": quantity}, idempotencyKey)
+}
+
+// BookWithInvitation books seats (or quantity tickets when seats is empty) at a private
+// event, redeeming the user's invitation code if they haven't already.
+func (c *Client) BookWithInvitation(ctx context.Context, eventID string, seats []string, quantity int, code string) (*BookingResult, error) {
+	body := map[string]any{"invitation_code": code}
+	if len(seats) > 0 {
+		body["seats"] = seats
+	} else {
+		body["quantity"] = quantity
+	}
+	return c.book(ctx, eventID, body, uuid.NewString())
+}
+
+func (c *Client) book(ctx context.Context, eventID string, body map[string]any, idempotencyKey string) (*BookingResult, error) {
+	var res BookingResult
+	_, err := c.do(ctx, request{
+		method:         http.MethodPost,
+		path:           "/v1/bookings/" + url.PathEscape(eventID) + "/book",
+		body:           body,
+		auth:           true,
+		idempotencyKey: idempotencyKey,
+	}, &res)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// BookingStatus returns "pending", "booked", "cancelled", "waitlisted" or "expired".
+func (c *Client) BookingStatus(ctx context.Context, bookingID string) (string, error) {
+	var out struct {
+		Status string `json:"status"`
+	}
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/v1/bookings/" + url.PathEscape(bookingID) + "/status", auth: true}, &out); err != nil {
+		return "", err
+	}
+	return out.Status, nil
+}
+
+// CancelBooking cancels a booking; a cancellation fee link is emailed for paid bookings.
+// It is not retried automatically.
+func (c *Client) CancelBooking(ctx context.Context, bookingID string) (map[string]any, error) {
+	var out map[string]any
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/v1/bookings/" + url.PathEscape(bookingID) + "/cancel", auth: true}, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// BookingStatuses returns the statuses of up to 100 of the user's bookings in one call,
+// keyed by booking ID. IDs that don't exist or aren't the user's are returned in notFound.
+func (c *Client) BookingStatuses(ctx context.Context, bookingIDs []string) (map[string]string, []string, error) {
+	body := map[string][]string{"booking_ids": bookingIDs}
+	var out struct {
+		Statuses map[string]string `json:"statuses"`
+		NotFound []string          `json:"not_found"`
+	}
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/v1/bookings/status", body: body, auth: true, readOnly: true}, &out); err != nil {
+		return nil, nil, err
+	}
+	return out.Statuses, out.NotFound, nil
+}
+
+func (c *Client) MyBookings(ctx context.Context, o ListOptions) ([]Booking, *Pagination, error) {
+	var bookings []Booking
+	page, err := c.do(ctx, request{method: http.MethodGet, path: "/v1/bookings/user-bookings", query: o.values(), auth: true}, &bookings)
+	if err != nil {
+		return nil, nil, err
+	}
+	return bookings, page, nil
+}
+
+// SeatChange is a booking after ChangeSeats, with what was charged or refunded for it.
+type SeatChange struct {
+	Booking  Booking `json:"booking"`
+	Charged  float64 `json:"charged"`
+	Refunded float64 `json:"refunded"`
+}
+
+// ChangeSeats moves the user's booking to other seats, as many as it has. A paid booking
+// moving to dearer seats is charged the difference to paymentID; to cheaper ones it is
+// refunded. It is not retried automatically.
+func (c *Client) ChangeSeats(ctx context.Context, bookingID string, seats []string, paymentID string) (*SeatChange, error) {
+	var out SeatChange
+	body := map[string]any{"seats": seats}
+	if paymentID != "" {
+		body["payment_id"] = paymentID
+	}
+	if _, err := c.do(ctx, request{method: http.MethodPut, path: "/v1/bookings/" + url.PathEscape(bookingID) + "/seats", body: body, auth: true}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SeatCancellation is a booking after CancelSeats, with the refund of the cancelled seats;
+// Refund is nil for a booking that hadn't paid yet.
+type SeatCancellation struct {
+	Booking Booking     `json:"booking"`
+	Refund  *SeatRefund `json:"refund,omitempty"`
+}
+
+// SeatRefund is what cancelling seats paid back: what they paid less their cancellation fee.
+type SeatRefund struct {
+	Currency        string  `json:"currency"`
+	Paid            float64 `json:"paid"`
+	CancellationFee float64 `json:"cancellation_fee"`
+	Refund          float64 `json:"refund"`
+}
+
+// CancelSeats gives up some of the user's booking's seats and keeps the rest, refunding them
+// if the booking was paid. It is not retried automatically.
+func (c *Client) CancelSeats(ctx context.Context, bookingID string, seats []string) (*SeatCancellation, error) {
+	var out SeatCancellation
+	body := map[string]any{"seats": seats}
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/v1/bookings/" + url.PathEscape(bookingID) + "/seats/cancel", body: body, auth: true}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
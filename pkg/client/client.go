@@ -0,0 +1,355 @@
+// Package client is a typed Go client for the Evently HTTP API.
+//
+// It talks to the API using the v2 response envelope (Accept-Version: 2), so every call
+// returns either its typed result or an *APIError. Requests are retried with exponential
+// backoff on network errors, 429 and 5xx responses, but only when they are safe to repeat:
+// GET/PUT/DELETE, or POSTs that carry an idempotency key.
+//
+//	c := client.New("http://localhost:8080")
+//	if _, err := c.Login(ctx, "a@b.com", "secret"); err != nil { ... }
+//	res, err := c.Book(ctx, eventID, []string{"A1", "A2"})
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTimeout    = 15 * time.Second
+	defaultMaxRetries = 3
+	defaultBackoff    = 200 * time.Millisecond
+	maxBackoff        = 5 * time.Second
+
+	// IdempotencyKeyHeader carries the key that makes a booking request safe to retry.
+	IdempotencyKeyHeader = "Idempotency-Key"
+)
+
+// Client calls the Evently API. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	http       *http.Client
+	maxRetries int
+	backoff    time.Duration
+	userAgent  string
+
+	apiKey     string
+	channel    string
+	partnerKey string
+	currency   string
+
+	mu    sync.RWMutex
+	token string
+}
+
+type Option func(*Client)
+
+// WithHTTPClient replaces the default http.Client (15s timeout).
+func WithHTTPClient(h *http.Client) Option { return func(c *Client) { c.http = h } }
+
+// WithToken sets the bearer token used for authenticated calls.
+func WithToken(token string) Option { return func(c *Client) { c.token = token } }
+
+// WithAPIKey authenticates admin calls with an operator API key instead of a token.
+func WithAPIKey(key string) Option { return func(c *Client) { c.apiKey = key } }
+
+// WithChannel reports the client as "web" (the default), "mobile" or, for admin box office
+// staff, "box_office"; bookings record the channel they were made through.
+func WithChannel(channel string) Option { return func(c *Client) { c.channel = channel } }
+
+// WithPartnerKey sends a partner API key with user calls, so bookings made on a user's
+// behalf are attributed to the partner.
+func WithPartnerKey(key string) Option { return func(c *Client) { c.partnerKey = key } }
+
+// WithCurrency asks for event prices to also be shown in currency (an ISO 4217 code such
+// as "EUR"), in each event's DisplayPrice. Charges stay in the event's own currency.
+func WithCurrency(currency string) Option { return func(c *Client) { c.currency = currency } }
+
+// WithRetry sets how many times a retryable request is repeated and the initial backoff,
+// which doubles (with jitter) on each attempt. maxRetries 0 disables retries.
+func WithRetry(maxRetries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.backoff = backoff
+	}
+}
+
+// WithUserAgent sets the User-Agent header.
+func WithUserAgent(ua string) Option { return func(c *Client) { c.userAgent = ua } }
+
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		http:       &http.Client{Timeout: defaultTimeout},
+		maxRetries: defaultMaxRetries,
+		backoff:    defaultBackoff,
+		userAgent:  "evently-go-client",
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	return c
+}
+
+// SetToken replaces the bearer token, e.g. after Login.
+func (c *Client) SetToken(token string) {
+	c.mu.Lock()
+	c.token = token
+	c.mu.Unlock()
+}
+
+func (c *Client) Token() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.token
+}
+
+// APIError is a non-2xx response from the API.
+type APIError struct {
+	StatusCode int
+	Code       string         `json:"code"`
+	Message    string         `json:"message"`
+	Details    map[string]any `json:"details,omitempty"`
+	// RetryAfter is set from the Retry-After header on 429 responses.
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("evently: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// IsNotFound reports whether err is an APIError with status 404.
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// Pagination is returned alongside paged lists.
+type Pagination struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+	Count  int `json:"count"`
+	// NextCursor is set on lists paged by cursor when another page follows; pass it as
+	// ListOptions.Cursor to get it
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+type envelope struct {
+	Data json.RawMessage `json:"data"`
+	Meta *struct {
+		Pagination *Pagination `json:"pagination"`
+	} `json:"meta"`
+	Error *APIError `json:"error"`
+}
+
+type request struct {
+	method         string
+	path           string
+	query          url.Values
+	body           any
+	auth           bool
+	admin          bool
+	idempotencyKey string
+	// noRetry marks GETs with side effects
+	noRetry bool
+	// readOnly marks POSTs without side effects, which are safe to retry
+	readOnly bool
+	// contentType sends body, which must then be a []byte, as is instead of as JSON
+	contentType string
+}
+
+// retryable reports whether repeating r cannot cause a duplicate side effect.
+func (r request) retryable() bool {
+	if r.noRetry {
+		return false
+	}
+	if r.readOnly {
+		return true
+	}
+	switch r.method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return r.idempotencyKey != ""
+}
+
+// do sends r and decodes the envelope's data into out, returning the pagination if any.
+// A response that is an error but still carries data (e.g. a declined payment) is decoded
+// into out as well as returned as an *APIError.
+func (c *Client) do(ctx context.Context, r request, out any) (*Pagination, error) {
+	var payload []byte
+	if raw, ok := r.body.([]byte); ok && r.contentType != "" {
+		payload = raw
+	} else if r.body != nil {
+		b, err := json.Marshal(r.body)
+		if err != nil {
+			return nil, err
+		}
+		payload = b
+	}
+
+	attempts := 1
+	if r.retryable() {
+		attempts += c.maxRetries
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			if err := sleep(ctx, c.delay(attempt, lastErr)); err != nil {
+				return nil, err
+			}
+		}
+
+		page, err, retry := c.once(ctx, r, payload, out)
+		if err == nil || !retry {
+			return page, err
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+func (c *Client) once(ctx context.Context, r request, payload []byte, out any) (*Pagination, error, bool) {
+	u := c.baseURL + r.path
+	if len(r.query) > 0 {
+		u += "?" + r.query.Encode()
+	}
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method, u, body)
+	if err != nil {
+		return nil, err, false
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Accept-Version", "2")
+	req.Header.Set("User-Agent", c.userAgent)
+	if r.contentType != "" {
+		req.Header.Set("Content-Type", r.contentType)
+	} else if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if r.idempotencyKey != "" {
+		req.Header.Set(IdempotencyKeyHeader, r.idempotencyKey)
+	}
+	if r.auth && r.admin && c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	} else if r.auth {
+		token := c.Token()
+		if token == "" {
+			return nil, errors.New("evently: call requires a token; use Login or WithToken"), false
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		if c.partnerKey != "" {
+			req.Header.Set("X-Partner-Key", c.partnerKey)
+		}
+		if c.channel != "" {
+			req.Header.Set("X-Client-Channel", c.channel)
+		}
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		// Network errors are retryable unless the caller gave up
+		return nil, err, ctx.Err() == nil
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err, true
+	}
+
+	var env envelope
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &env); err != nil {
+			return nil, fmt.Errorf("evently: decode %s %s (status %d): %w", r.method, r.path, resp.StatusCode, err), resp.StatusCode >= 500
+		}
+	}
+	if out != nil && len(env.Data) > 0 && string(env.Data) != "null" {
+		if err := json.Unmarshal(env.Data, out); err != nil {
+			return nil, fmt.Errorf("evently: decode %s %s data: %w", r.method, r.path, err), false
+		}
+	}
+
+	var page *Pagination
+	if env.Meta != nil {
+		page = env.Meta.Pagination
+	}
+
+	if resp.StatusCode >= 300 {
+		apiErr := env.Error
+		if apiErr == nil {
+			apiErr = &APIError{Message: http.StatusText(resp.StatusCode)}
+		}
+		apiErr.StatusCode = resp.StatusCode
+		if s := resp.Header.Get("Retry-After"); s != "" {
+			if secs, err := strconv.Atoi(s); err == nil {
+				apiErr.RetryAfter = time.Duration(secs) * time.Second
+			}
+		}
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return page, apiErr, retry
+	}
+	return page, nil, false
+}
+
+// delay returns the backoff before attempt, honouring Retry-After when the server sent one.
+func (c *Client) delay(attempt int, lastErr error) time.Duration {
+	var apiErr *APIError
+	if errors.As(lastErr, &apiErr) && apiErr.RetryAfter > 0 {
+		return apiErr.RetryAfter
+	}
+	d := c.backoff << (attempt - 1)
+	if d > maxBackoff || d <= 0 {
+		d = maxBackoff
+	}
+	// Full jitter so many clients retrying an on-sale don't stampede together
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// ListOptions pages through list endpoints. Zero values use the server defaults. Cursor,
+// where an endpoint supports it, replaces Offset.
+type ListOptions struct {
+	Limit  int
+	Offset int
+	Cursor string
+}
+
+func (o ListOptions) values() url.Values {
+	v := url.Values{}
+	if o.Limit > 0 {
+		v.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Offset > 0 {
+		v.Set("offset", strconv.Itoa(o.Offset))
+	}
+	if o.Cursor != "" {
+		v.Set("cursor", o.Cursor)
+	}
+	return v
+}
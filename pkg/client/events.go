@@ -0,0 +1,279 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// EventFilter narrows ListEvents. Zero values don't filter.
+type EventFilter struct {
+	ListOptions
+	Query string
+	From  time.Time
+	To    time.Time
+}
+
+func (c *Client) ListEvents(ctx context.Context, f EventFilter) ([]Event, *Pagination, error) {
+	q := f.values()
+	if f.Query != "" {
+		q.Set("q", f.Query)
+	}
+	if !f.From.IsZero() {
+		q.Set("from", f.From.Format(time.RFC3339))
+	}
+	if !f.To.IsZero() {
+		q.Set("to", f.To.Format(time.RFC3339))
+	}
+	return c.listEvents(ctx, "/v1/events", q)
+}
+
+func (c *Client) ListUpcomingEvents(ctx context.Context, o ListOptions) ([]Event, *Pagination, error) {
+	return c.listEvents(ctx, "/v1/events/upcoming", o.values())
+}
+
+func (c *Client) ListPopularEvents(ctx context.Context, o ListOptions) ([]Event, *Pagination, error) {
+	return c.listEvents(ctx, "/v1/events/popular", o.values())
+}
+
+// withCurrency adds the display currency set by WithCurrency to an event query.
+func (c *Client) withCurrency(q url.Values) url.Values {
+	if c.currency != "" {
+		if q == nil {
+			q = url.Values{}
+		}
+		q.Set("currency", c.currency)
+	}
+	return q
+}
+
+func (c *Client) listEvents(ctx context.Context, path string, q url.Values) ([]Event, *Pagination, error) {
+	q = c.withCurrency(q)
+	var events []Event
+	page, err := c.do(ctx, request{method: http.MethodGet, path: path, query: q}, &events)
+	if err != nil {
+		return nil, nil, err
+	}
+	return events, page, nil
+}
+
+// NearbyFilter searches around a point. RadiusKm 0 uses the server default (25 km).
+type NearbyFilter struct {
+	ListOptions
+	Latitude  float64
+	Longitude float64
+	RadiusKm  float64
+	Category  string
+	From      time.Time
+	To        time.Time
+	MinPrice  *float64
+	MaxPrice  *float64
+}
+
+func (c *Client) ListNearbyEvents(ctx context.Context, f NearbyFilter) ([]NearbyEvent, *Pagination, error) {
+	q := f.values()
+	q.Set("lat", strconv.FormatFloat(f.Latitude, 'f', -1, 64))
+	q.Set("lng", strconv.FormatFloat(f.Longitude, 'f', -1, 64))
+	if f.RadiusKm > 0 {
+		q.Set("radius", strconv.FormatFloat(f.RadiusKm, 'f', -1, 64))
+	}
+	if f.Category != "" {
+		q.Set("category", f.Category)
+	}
+	if !f.From.IsZero() {
+		q.Set("from", f.From.Format(time.RFC3339))
+	}
+	if !f.To.IsZero() {
+		q.Set("to", f.To.Format(time.RFC3339))
+	}
+	if f.MinPrice != nil {
+		q.Set("min_price", strconv.FormatFloat(*f.MinPrice, 'f', -1, 64))
+	}
+	if f.MaxPrice != nil {
+		q.Set("max_price", strconv.FormatFloat(*f.MaxPrice, 'f', -1, 64))
+	}
+
+	var events []NearbyEvent
+	page, err := c.do(ctx, request{method: http.MethodGet, path: "/v1/events/nearby", query: c.withCurrency(q)}, &events)
+	if err != nil {
+		return nil, nil, err
+	}
+	return events, page, nil
+}
+
+// SearchFilter is a full-text event search; Query is required.
+type SearchFilter struct {
+	ListOptions
+	Query    string
+	Category string
+	From     time.Time
+	To       time.Time
+	MinPrice *float64
+	MaxPrice *float64
+}
+
+// SearchEvents searches public events by name, venue, category and metadata, best match first.
+func (c *Client) SearchEvents(ctx context.Context, f SearchFilter) ([]SearchResult, *Pagination, error) {
+	q := f.values()
+	q.Set("q", f.Query)
+	if f.Category != "" {
+		q.Set("category", f.Category)
+	}
+	if !f.From.IsZero() {
+		q.Set("from", f.From.Format(time.RFC3339))
+	}
+	if !f.To.IsZero() {
+		q.Set("to", f.To.Format(time.RFC3339))
+	}
+	if f.MinPrice != nil {
+		q.Set("min_price", strconv.FormatFloat(*f.MinPrice, 'f', -1, 64))
+	}
+	if f.MaxPrice != nil {
+		q.Set("max_price", strconv.FormatFloat(*f.MaxPrice, 'f', -1, 64))
+	}
+
+	var events []SearchResult
+	page, err := c.do(ctx, request{method: http.MethodGet, path: "/v1/events/search", query: c.withCurrency(q)}, &events)
+	if err != nil {
+		return nil, nil, err
+	}
+	return events, page, nil
+}
+
+func (c *Client) GetEvent(ctx context.Context, id string) (*EventDetails, error) {
+	return c.GetEventWithCode(ctx, id, "")
+}
+
+// GetEventWithCode is GetEvent for a private event, which is only found with one of its
+// invitation codes.
+func (c *Client) GetEventWithCode(ctx context.Context, id, code string) (*EventDetails, error) {
+	var d EventDetails
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/v1/events/" + url.PathEscape(id), query: c.withCurrency(codeQuery(code))}, &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// AvailableSeats lists the labels of the event's seats that can still be booked.
+func (c *Client) AvailableSeats(ctx context.Context, eventID string) ([]string, error) {
+	return c.AvailableSeatsWithCode(ctx, eventID, "")
+}
+
+// AvailableSeatsWithCode is AvailableSeats for a private event.
+func (c *Client) AvailableSeatsWithCode(ctx context.Context, eventID, code string) ([]string, error) {
+	var out struct {
+		Seats []string `json:"seats"`
+	}
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/v1/events/" + url.PathEscape(eventID) + "/seats", query: codeQuery(code)}, &out); err != nil {
+		return nil, err
+	}
+	return out.Seats, nil
+}
+
+// Sale phase statuses.
+const (
+	PhaseUpcoming = "upcoming"
+	PhaseOnSale   = "on_sale"
+	PhaseSoldOut  = "sold_out"
+	PhaseEnded    = "ended"
+)
+
+// PublicSalePhase is a phase an event is sold in, as buyers see it.
+type PublicSalePhase struct {
+	Name      string    `json:"name"`
+	Price     float64   `json:"price"`
+	StartsAt  time.Time `json:"starts_at"`
+	EndsAt    time.Time `json:"ends_at"`
+	Status    string    `json:"status"`
+	Remaining int       `json:"remaining"`
+}
+
+// SalePhases lists the phases the event is sold in, in order; none if it's sold without
+// phases. Pass code for a private event.
+func (c *Client) SalePhases(ctx context.Context, eventID, code string) ([]PublicSalePhase, error) {
+	var out struct {
+		Phases []PublicSalePhase `json:"phases"`
+	}
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/v1/events/" + url.PathEscape(eventID) + "/sale-phases", query: codeQuery(code)}, &out); err != nil {
+		return nil, err
+	}
+	return out.Phases, nil
+}
+
+// SeatMap is an event's seat map grouped by section, then row, in layout order. Seats lists
+// the labels that can still be booked.
+type SeatMap struct {
+	Seats    []string         `json:"seats"`
+	Tiers    []PriceTier      `json:"tiers"`
+	Sections []SeatMapSection `json:"sections"`
+}
+
+// SeatMapSection is a section of the seat map; seats laid out without sections are in one
+// section with an empty Name.
+type SeatMapSection struct {
+	Name string       `json:"name"`
+	Rows []SeatMapRow `json:"rows"`
+}
+
+// SeatMapRow is a row of a section; listed seats are in one row with an empty Row.
+type SeatMapRow struct {
+	Row   string    `json:"row"`
+	Seats []MapSeat `json:"seats"`
+}
+
+// MapSeat is a seat on the seat map with the price it sells at.
+type MapSeat struct {
+	Label     string  `json:"label"`
+	Tier      string  `json:"tier,omitempty"`
+	Price     float64 `json:"price"`
+	Available bool    `json:"available"`
+}
+
+// GetSeatMap returns the event's seat map; code is the invitation code of a private event.
+func (c *Client) GetSeatMap(ctx context.Context, eventID, code string) (*SeatMap, error) {
+	var m SeatMap
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/v1/events/" + url.PathEscape(eventID) + "/seats", query: codeQuery(code)}, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func codeQuery(code string) url.Values {
+	if code == "" {
+		return nil
+	}
+	return url.Values{"code": {code}}
+}
+
+func (c *Client) LikeEvent(ctx context.Context, eventID string) error {
+	_, err := c.do(ctx, request{method: http.MethodPost, path: "/v1/events/" + url.PathEscape(eventID) + "/like", auth: true}, nil)
+	return err
+}
+
+func (c *Client) UnlikeEvent(ctx context.Context, eventID string) error {
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: "/v1/events/" + url.PathEscape(eventID) + "/like", auth: true}, nil)
+	return err
+}
+
+// Invitation is a user's invitation to a private event.
+type Invitation struct {
+	ID         string     `json:"id"`
+	EventID    string     `json:"event_id"`
+	UserID     string     `json:"user_id"`
+	Code       string     `json:"code"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RedeemedAt *time.Time `json:"redeemed_at,omitempty"`
+}
+
+// RedeemInvitation claims the signed-in user's invitation to a private event, after which
+// they can book it. Codes are personal: someone else's code is a 403 APIError.
+func (c *Client) RedeemInvitation(ctx context.Context, eventID, code string) (*Invitation, error) {
+	var inv Invitation
+	body := map[string]string{"code": code}
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/v1/events/" + url.PathEscape(eventID) + "/invitations/redeem", body: body, auth: true}, &inv); err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
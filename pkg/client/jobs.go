@@ -0,0 +1,104 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Job states. A job is queued until a slot frees up, then running until it completes or fails.
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+)
+
+// Job is a long-running admin operation run in the background, such as an event
+// cancellation, its refunds or an invitee import. Processed counts items done so far out
+// of Total, split into Succeeded and Failed; ErrorSamples holds the first few item errors.
+type Job struct {
+	ID           string   `json:"id"`
+	Kind         string   `json:"kind"`
+	EventID      *string  `json:"event_id,omitempty"`
+	State        string   `json:"state"`
+	Total        int      `json:"total"`
+	Processed    int      `json:"processed"`
+	Succeeded    int      `json:"succeeded"`
+	Failed       int      `json:"failed"`
+	ErrorSamples []string `json:"error_samples"`
+	// Result is the kind's outcome once completed, e.g. an InviteeImport for invitee_import
+	Result     json.RawMessage `json:"result,omitempty"`
+	Error      *string         `json:"error,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+	StartedAt  *time.Time      `json:"started_at,omitempty"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+}
+
+// Done reports whether the job completed or failed.
+func (j *Job) Done() bool {
+	return j.State == JobCompleted || j.State == JobFailed
+}
+
+// JobFilter narrows ListJobs to one kind or state; empty fields match everything.
+type JobFilter struct {
+	Kind  string
+	State string
+}
+
+func (c *Client) GetJob(ctx context.Context, id string) (*Job, error) {
+	var j Job
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/admin/jobs/" + url.PathEscape(id), auth: true, admin: true}, &j); err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+// ListJobs lists admin jobs, newest first.
+func (c *Client) ListJobs(ctx context.Context, f JobFilter, o ListOptions) ([]Job, *Pagination, error) {
+	q := o.values()
+	if f.Kind != "" {
+		q.Set("kind", f.Kind)
+	}
+	if f.State != "" {
+		q.Set("state", f.State)
+	}
+	var jobs []Job
+	page, err := c.do(ctx, request{method: http.MethodGet, path: "/admin/jobs", query: q, auth: true, admin: true}, &jobs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return jobs, page, nil
+}
+
+// WaitJob polls the job every interval until it is done and returns it. A job that failed
+// is returned along with an error carrying its message.
+func (c *Client) WaitJob(ctx context.Context, id string, interval time.Duration) (*Job, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		j, err := c.GetJob(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if j.State == JobFailed {
+			msg := "unknown error"
+			if j.Error != nil {
+				msg = *j.Error
+			}
+			return j, fmt.Errorf("evently: job %s failed: %s", j.ID, msg)
+		}
+		if j.Done() {
+			return j, nil
+		}
+		select {
+		case <-ctx.Done():
+			return j, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
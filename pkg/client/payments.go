@@ -0,0 +1,72 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// PayBooking settles a pending booking. paymentID is the provider's reference. A declined
+// payment returns the result together with an *APIError (402). Not retried automatically.
+func (c *Client) PayBooking(ctx context.Context, bookingID string, amount float64, paymentID string) (*PaymentResult, error) {
+	q := url.Values{}
+	q.Set("booking_id", bookingID)
+	q.Set("amount", strconv.FormatFloat(amount, 'f', 2, 64))
+	q.Set("payment_id", paymentID)
+	// The payment endpoint is a GET for emailed links, but it isn't safe to repeat
+	var res PaymentResult
+	_, err := c.do(ctx, request{method: http.MethodGet, path: "/v1/payment/booking", query: q, noRetry: true}, &res)
+	return &res, err
+}
+
+// PayCancellationFee settles the cancellation fee of a cancelled booking and refunds the rest.
+func (c *Client) PayCancellationFee(ctx context.Context, bookingID string) (*PaymentResult, error) {
+	q := url.Values{}
+	q.Set("booking_id", bookingID)
+	var res PaymentResult
+	_, err := c.do(ctx, request{method: http.MethodGet, path: "/v1/payment/refund", query: q, noRetry: true}, &res)
+	return &res, err
+}
+
+// PaymentHold is a booking's payment deadline after an extension.
+type PaymentHold struct {
+	BookingID string    `json:"booking_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Payment is one attempt to take money for a booking. State is authorized, capturing,
+// captured, voided, refunded or failed.
+type Payment struct {
+	ID           string     `json:"id"`
+	BookingID    string     `json:"booking_id"`
+	EventID      string     `json:"event_id"`
+	PaymentID    string     `json:"payment_id"`
+	ProviderRef  *string    `json:"provider_ref,omitempty"`
+	Amount       float64    `json:"amount"`
+	Currency     string     `json:"currency"`
+	State        string     `json:"state"`
+	Error        *string    `json:"error,omitempty"`
+	AuthorizedAt *time.Time `json:"authorized_at,omitempty"`
+	CapturedAt   *time.Time `json:"captured_at,omitempty"`
+	VoidedAt     *time.Time `json:"voided_at,omitempty"`
+	RefundedAt   *time.Time `json:"refunded_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// ExtendPaymentWindow gives a pending booking more time while its payment is still in
+// progress (e.g. a 3DS challenge). Each booking can be extended once; seconds 0 asks for
+// the server's maximum.
+func (c *Client) ExtendPaymentWindow(ctx context.Context, bookingID string, seconds int) (*PaymentHold, error) {
+	body := map[string]any{"booking_id": bookingID}
+	if seconds > 0 {
+		body["seconds"] = seconds
+	}
+	var hold PaymentHold
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/v1/payment/extend", body: body, auth: true, noRetry: true}, &hold); err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
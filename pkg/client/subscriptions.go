@@ -0,0 +1,53 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Subscription kinds and deliveries.
+const (
+	SubscriptionCategory = "category"
+	SubscriptionTag      = "tag"
+
+	DeliveryInstant = "instant"
+	DeliveryDigest  = "digest"
+)
+
+// Subscription is a category or tag the signed-in user hears about new public events for,
+// right away or in a periodic digest.
+type Subscription struct {
+	ID        string    `json:"id"`
+	Kind      string    `json:"kind"`
+	Term      string    `json:"term"`
+	Delivery  string    `json:"delivery"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Subscribe follows a category or tag (matched against event metadata tags and venues, e.g.
+// "bangalore"). Subscribing to a term again changes its delivery.
+func (c *Client) Subscribe(ctx context.Context, kind, term, delivery string) (*Subscription, error) {
+	body := map[string]any{"kind": kind, "term": term, "delivery": delivery}
+	var s Subscription
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/v1/subscriptions", body: body, auth: true}, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (c *Client) ListSubscriptions(ctx context.Context) ([]Subscription, error) {
+	var out struct {
+		Subscriptions []Subscription `json:"subscriptions"`
+	}
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/v1/subscriptions", auth: true}, &out); err != nil {
+		return nil, err
+	}
+	return out.Subscriptions, nil
+}
+
+func (c *Client) Unsubscribe(ctx context.Context, id string) error {
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: "/v1/subscriptions/" + url.PathEscape(id), auth: true}, nil)
+	return err
+}
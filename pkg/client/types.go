@@ -0,0 +1,176 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type User struct {
+	ID                string `json:"id"`
+	Name              string `json:"name"`
+	Email             string `json:"email"`
+	Phone             string `json:"phone"`
+	Role              string `json:"role"`
+	PreferredCurrency string `json:"preferred_currency,omitempty"`
+}
+
+type Session struct {
+	Token   string    `json:"token"`
+	User    User      `json:"user"`
+	Expires time.Time `json:"expires"`
+}
+
+type SignupRequest struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Phone    string `json:"phone,omitempty"`
+}
+
+type Event struct {
+	ID                       string    `json:"id"`
+	Name                     string    `json:"name"`
+	Venue                    string    `json:"venue"`
+	StartTime                time.Time `json:"start_time"`
+	EndTime                  time.Time `json:"end_time"`
+	Category                 string    `json:"category"`
+	Capacity                 int       `json:"capacity"`
+	Reserved                 int       `json:"reserved"`
+	Status                   string    `json:"status"`
+	TicketPrice              float64   `json:"ticket_price"`
+	CancellationFee          float64   `json:"cancellation_fee"`
+	Likes                    int       `json:"likes"`
+	MaximumTicketsPerBooking int       `json:"maximum_tickets_per_booking"`
+	OrganizerID              *string   `json:"organizer_id,omitempty"`
+	MaxTicketsPerUser        *int      `json:"max_tickets_per_user,omitempty"`
+	UserTicketWindowHours    *int      `json:"user_ticket_window_hours,omitempty"`
+	Latitude                 *float64  `json:"latitude,omitempty"`
+	Longitude                *float64  `json:"longitude,omitempty"`
+	// Feature toggles: hide the waitlist, seat map or like button when off
+	WaitlistEnabled      bool `json:"waitlist_enabled"`
+	SeatSelectionEnabled bool `json:"seat_selection_enabled"`
+	LikesEnabled         bool `json:"likes_enabled"`
+	// NoSingleSeat means bookings may not leave a lone empty seat in a row; seat pickers can
+	// warn before the server rejects the selection
+	NoSingleSeat bool `json:"no_single_seat"`
+	// PaymentCapture is "immediate" or "manual"; manual events authorize cards at booking and
+	// charge them when the organizer confirms or at CaptureAt (the start time when unset)
+	PaymentCapture string     `json:"payment_capture"`
+	CaptureAt      *time.Time `json:"capture_at,omitempty"`
+	// Currency is what the event is priced and charged in
+	Currency string `json:"currency"`
+	// Visibility is "public", "unlisted" (left out of listings but reachable by ID) or
+	// "private" (reachable with an invitation code; only invitees can book)
+	Visibility string `json:"visibility"`
+	// Sandbox marks a rehearsal event, only bookable by admins and its testers
+	Sandbox bool `json:"sandbox,omitempty"`
+	// OwnerID is the organizer account that manages the event; empty for admin-created events
+	OwnerID   string    `json:"owner_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// DisplayPrice is set when the client asked for another currency (see WithCurrency)
+	DisplayPrice *DisplayPrice `json:"display_price,omitempty"`
+	// Availability is the listing badge: "available", "limited" or "sold_out". It is only
+	// set on listings and may lag sales by a few seconds
+	Availability string `json:"availability,omitempty"`
+}
+
+// DisplayPrice is an event's prices converted at the daily FX rate of AsOf, for display only.
+type DisplayPrice struct {
+	Currency        string    `json:"currency"`
+	TicketPrice     float64   `json:"ticket_price"`
+	CancellationFee float64   `json:"cancellation_fee"`
+	Rate            float64   `json:"rate"`
+	AsOf            time.Time `json:"as_of"`
+}
+
+// EventDetails is an event with its live token count.
+type EventDetails struct {
+	Event           Event `json:"event"`
+	TokensRemaining int   `json:"tokens_remaining"`
+}
+
+type NearbyEvent struct {
+	Event
+	DistanceKm float64 `json:"distance_km"`
+}
+
+// SearchResult is an event found by SearchEvents. Match is "text", or "fuzzy" when the
+// query matched nothing and similar names and venues were returned instead.
+type SearchResult struct {
+	Event
+	Rank  float64 `json:"rank"`
+	Match string  `json:"match"`
+}
+
+// BookingResult is the immediate outcome of a booking request: "pending" with a booking ID
+// (payment link follows by email) or "waitlisted" with a position.
+type BookingResult struct {
+	BookingID string `json:"booking_id"`
+	Status    string `json:"status"`
+	Position  int    `json:"position,omitempty"`
+}
+
+type Booking struct {
+	ID             string   `json:"id"`
+	UserID         string   `json:"user_id"`
+	EventID        string   `json:"event_id"`
+	Status         string   `json:"status"`
+	Seats          []string `json:"seats"`
+	IdempotencyKey string   `json:"idempotency_key,omitempty"`
+	AmountPaid     float64  `json:"amount_paid"`
+	PaymentStatus  string   `json:"payment_status"`
+	Source         string   `json:"source"` // web, mobile, box_office, waitlist or partner:<key id>
+	// Currency and AmountDue are the charge; the Display fields are what the buyer was shown
+	// in their preferred currency, if they have one
+	Currency        string     `json:"currency"`
+	AmountDue       float64    `json:"amount_due"`
+	DisplayCurrency *string    `json:"display_currency,omitempty"`
+	DisplayAmount   *float64   `json:"display_amount,omitempty"`
+	FXRate          *float64   `json:"fx_rate,omitempty"`
+	FXAsOf          *time.Time `json:"fx_as_of,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// BookingTrace is a booking with everything recorded about it, oldest first.
+type BookingTrace struct {
+	Booking Booking       `json:"booking"`
+	Entries []*TraceEntry `json:"entries"`
+}
+
+// TraceEntry is one thing that happened to a booking. Source is the record it comes from
+// (booking, booking_event, audit, outbox, kafka, payment, payment_attempt, provider_event,
+// payment_adjustment, transfer, check_in, email, webhook or integration_webhook), Kind what
+// happened, and Detail that record's own fields.
+type TraceEntry struct {
+	At     time.Time       `json:"at"`
+	Source string          `json:"source"`
+	Kind   string          `json:"kind"`
+	Detail json.RawMessage `json:"detail,omitempty"`
+}
+
+// SeatLabels returns the booking's seats.
+//
+// Deprecated: seats are sent as a JSON array of labels; read Seats.
+func (b *Booking) SeatLabels() ([]string, error) {
+	return b.Seats, nil
+}
+
+type WaitlistEntry struct {
+	ID         string `json:"id"`
+	EventID    string `json:"event_id"`
+	UserID     string `json:"user_id"`
+	Position   int    `json:"position"`
+	OptedOut   bool   `json:"opted_out"`
+	NotifiedAt string `json:"notified_at,omitempty"`
+	// ExpiredAt is set when the waitlist closed with the entry still waiting
+	ExpiredAt string `json:"expired_at,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+type PaymentResult struct {
+	Success   bool   `json:"success"`
+	Message   string `json:"message"`
+	BookingID string `json:"booking_id,omitempty"`
+}
@@ -0,0 +1,42 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// JoinWaitlist adds the user to an event's waitlist and returns their position.
+func (c *Client) JoinWaitlist(ctx context.Context, eventID string) (int, error) {
+	var out struct {
+		Position int `json:"position"`
+	}
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/v1/waitlist/" + url.PathEscape(eventID) + "/join", auth: true}, &out); err != nil {
+		return 0, err
+	}
+	return out.Position, nil
+}
+
+func (c *Client) OptOutOfWaitlist(ctx context.Context, eventID string) error {
+	_, err := c.do(ctx, request{method: http.MethodPost, path: "/v1/waitlist/" + url.PathEscape(eventID) + "/optout", auth: true}, nil)
+	return err
+}
+
+func (c *Client) WaitlistCount(ctx context.Context, eventID string) (int, error) {
+	var out struct {
+		Count int `json:"count"`
+	}
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/v1/waitlist/" + url.PathEscape(eventID) + "/count"}, &out); err != nil {
+		return 0, err
+	}
+	return out.Count, nil
+}
+
+func (c *Client) ListWaitlist(ctx context.Context, eventID string, o ListOptions) ([]WaitlistEntry, *Pagination, error) {
+	var entries []WaitlistEntry
+	page, err := c.do(ctx, request{method: http.MethodGet, path: "/v1/waitlist/" + url.PathEscape(eventID), query: o.values()}, &entries)
+	if err != nil {
+		return nil, nil, err
+	}
+	return entries, page, nil
+}
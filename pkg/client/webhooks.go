@@ -0,0 +1,204 @@
+package client
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Webhook is a URL the signed-in user receives their own bookings' status changes at.
+// EventTypes empty means every type.
+type Webhook struct {
+	ID                  string    `json:"id"`
+	URL                 string    `json:"url"`
+	EventTypes          []string  `json:"event_types"`
+	Active              bool      `json:"active"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	DisabledReason      *string   `json:"disabled_reason,omitempty"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+	// Secret signs deliveries; it is only returned by CreateWebhook
+	Secret string `json:"secret,omitempty"`
+}
+
+// WebhookDelivery is one status change sent, or still to be sent, to a webhook.
+type WebhookDelivery struct {
+	ID             string     `json:"id"`
+	WebhookID      string     `json:"webhook_id"`
+	BookingID      string     `json:"booking_id"`
+	EventType      string     `json:"event_type"`
+	Status         string     `json:"status"` // pending, sending, delivered or failed
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at"`
+	ResponseStatus *int       `json:"response_status,omitempty"`
+	LastError      *string    `json:"last_error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
+
+// CreateWebhook registers an https URL for the user's booking status changes; eventTypes
+// (e.g. "booking.payment_received") narrows them, none means all. Keep the returned Secret
+// to verify deliveries with VerifyWebhook.
+func (c *Client) CreateWebhook(ctx context.Context, webhookURL string, eventTypes ...string) (*Webhook, error) {
+	body := map[string]any{"url": webhookURL, "event_types": eventTypes}
+	var w Webhook
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/v1/webhooks", body: body, auth: true, noRetry: true}, &w); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+func (c *Client) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	var out struct {
+		Webhooks []Webhook `json:"webhooks"`
+	}
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/v1/webhooks", auth: true}, &out); err != nil {
+		return nil, err
+	}
+	return out.Webhooks, nil
+}
+
+func (c *Client) DeleteWebhook(ctx context.Context, id string) error {
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: "/v1/webhooks/" + url.PathEscape(id), auth: true}, nil)
+	return err
+}
+
+// EnableWebhook switches a webhook disabled after repeated failed deliveries back on.
+func (c *Client) EnableWebhook(ctx context.Context, id string) (*Webhook, error) {
+	var w Webhook
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/v1/webhooks/" + url.PathEscape(id) + "/enable", auth: true}, &w); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// WebhookDeliveries returns a webhook's most recent deliveries, newest first.
+func (c *Client) WebhookDeliveries(ctx context.Context, id string, limit int) ([]WebhookDelivery, error) {
+	q := url.Values{}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	var out struct {
+		Deliveries []WebhookDelivery `json:"deliveries"`
+	}
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/v1/webhooks/" + url.PathEscape(id) + "/deliveries", query: q, auth: true}, &out); err != nil {
+		return nil, err
+	}
+	return out.Deliveries, nil
+}
+
+// WebhookEndpoint is an admin-registered URL a third-party integration receives
+// platform-wide booking and event changes at. EventTypes empty means every type.
+type WebhookEndpoint struct {
+	ID                  string    `json:"id"`
+	URL                 string    `json:"url"`
+	Description         string    `json:"description"`
+	EventTypes          []string  `json:"event_types"`
+	Active              bool      `json:"active"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	DisabledReason      *string   `json:"disabled_reason,omitempty"`
+	CreatedBy           *string   `json:"created_by,omitempty"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+	// Secret signs deliveries; it is only returned by CreateWebhookEndpoint
+	Secret string `json:"secret,omitempty"`
+}
+
+// WebhookEndpointDelivery is one change sent, or still to be sent, to an endpoint.
+// BookingID is set for booking types.
+type WebhookEndpointDelivery struct {
+	ID             string     `json:"id"`
+	EndpointID     string     `json:"endpoint_id"`
+	EventType      string     `json:"event_type"`
+	BookingID      *string    `json:"booking_id,omitempty"`
+	EventID        string     `json:"event_id"`
+	Status         string     `json:"status"` // pending, sending, delivered or failed
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at"`
+	ResponseStatus *int       `json:"response_status,omitempty"`
+	LastError      *string    `json:"last_error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
+
+// CreateWebhookEndpoint registers an https URL for an integration (admin only); eventTypes
+// (booking.created, booking.finalized, booking.cancelled, event.cancelled) narrows what it
+// receives, none means all. Keep the returned Secret to verify deliveries with VerifyWebhook.
+func (c *Client) CreateWebhookEndpoint(ctx context.Context, endpointURL, description string, eventTypes ...string) (*WebhookEndpoint, error) {
+	body := map[string]any{"url": endpointURL, "description": description, "event_types": eventTypes}
+	var e WebhookEndpoint
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/admin/webhooks", body: body, auth: true, admin: true, noRetry: true}, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (c *Client) ListWebhookEndpoints(ctx context.Context) ([]WebhookEndpoint, error) {
+	var out struct {
+		Endpoints []WebhookEndpoint `json:"endpoints"`
+	}
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/admin/webhooks", auth: true, admin: true}, &out); err != nil {
+		return nil, err
+	}
+	return out.Endpoints, nil
+}
+
+func (c *Client) DeleteWebhookEndpoint(ctx context.Context, id string) error {
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: "/admin/webhooks/" + url.PathEscape(id), auth: true, admin: true}, nil)
+	return err
+}
+
+// EnableWebhookEndpoint switches an endpoint disabled after repeated failed deliveries back on.
+func (c *Client) EnableWebhookEndpoint(ctx context.Context, id string) (*WebhookEndpoint, error) {
+	var e WebhookEndpoint
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/admin/webhooks/" + url.PathEscape(id) + "/enable", auth: true, admin: true}, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// WebhookEndpointDeliveries returns an endpoint's delivery log, newest first.
+func (c *Client) WebhookEndpointDeliveries(ctx context.Context, id string, limit int) ([]WebhookEndpointDelivery, error) {
+	q := url.Values{}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	var out struct {
+		Deliveries []WebhookEndpointDelivery `json:"deliveries"`
+	}
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/admin/webhooks/" + url.PathEscape(id) + "/deliveries", query: q, auth: true, admin: true}, &out); err != nil {
+		return nil, err
+	}
+	return out.Deliveries, nil
+}
+
+// VerifyWebhook checks a delivery received at a webhook: r's Webhook-Timestamp and
+// Webhook-Signature headers against the raw body, signed with the webhook's secret, and
+// that the timestamp is within tolerance of now.
+func VerifyWebhook(secret string, r *http.Request, body []byte, tolerance time.Duration) bool {
+	ts := r.Header.Get("Webhook-Timestamp")
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return false
+	}
+	if d := time.Since(time.Unix(unix, 0)); d > tolerance || d < -tolerance {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+	want := mac.Sum(nil)
+	for _, sig := range strings.Split(r.Header.Get("Webhook-Signature"), ",") {
+		got, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(sig), "v1="))
+		if err == nil && hmac.Equal(got, want) {
+			return true
+		}
+	}
+	return false
+}
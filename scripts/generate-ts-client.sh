@@ -0,0 +1,18 @@
+#!/usr/bin/env sh
+# Generates the TypeScript client from docs/openapi.yaml into clients/typescript.
+# Needs either npx (Node 18+) or docker. Generated code is not committed.
+set -eu
+
+ROOT=$(cd "$(dirname "$0")/.." && pwd)
+OUT=${OUT:-clients/typescript}
+GENERATOR_VERSION=${GENERATOR_VERSION:-7.4.0}
+ARGS="generate -i docs/openapi.yaml -g typescript-fetch -o $OUT --additional-properties=supportsES6=true,typescriptThreePlus=true,npmName=@evently/client"
+
+cd "$ROOT"
+if command -v npx >/dev/null 2>&1; then
+	OPENAPI_GENERATOR_VERSION=$GENERATOR_VERSION npx --yes @openapitools/openapi-generator-cli $ARGS
+else
+	docker run --rm -u "$(id -u):$(id -g)" -v "$ROOT:/local" -w /local \
+		openapitools/openapi-generator-cli:v$GENERATOR_VERSION $ARGS
+fi
+echo "TypeScript client written to $OUT"